@@ -0,0 +1,190 @@
+package autocertwebhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+
+	jsonpatch "gopkg.in/evanphx/json-patch.v4"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Chain combines admit functions written independently, e.g. policy modules,
+// into the admit function of a single hook. They are called in order, and
+// every one must allow the request: the first response that does not is
+// returned. Each function sees the object as patched by the ones before it,
+// and the response patches the request's object with all their changes, so
+// mutating modules compose like separate mutating webhooks. Warnings and
+// audit annotations of all called functions are merged, later annotations
+// overriding earlier ones with the same key.
+func Chain(admits ...AdmitFunc) AdmitFunc {
+	return func(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		var original []byte
+		if ar.Request != nil {
+			original = ar.Request.Object.Raw
+		}
+		current := original
+		var warnings []string
+		var annotations map[string]string
+		var result *metav1.Status
+		for i, admit := range admits {
+			if ar.Request != nil {
+				// Copy the request so that the caller's is left alone
+				req := *ar.Request
+				req.Object.Raw = current
+				ar.Request = &req
+			}
+			resp := admit(ar)
+			if resp == nil {
+				return Errored(fmt.Errorf("admit function %d of the chain returned no response", i))
+			}
+			warnings = append(warnings, resp.Warnings...)
+			if len(resp.AuditAnnotations) > 0 {
+				if annotations == nil {
+					annotations = map[string]string{}
+				}
+				maps.Copy(annotations, resp.AuditAnnotations)
+			}
+			if !resp.Allowed {
+				resp.Warnings = SanitizeWarnings(warnings)
+				resp.AuditAnnotations = annotations
+				return resp
+			}
+			if resp.Result != nil {
+				result = resp.Result
+			}
+			if len(resp.Patch) == 0 {
+				continue
+			}
+			patched, err := applyPatch(current, resp)
+			if err != nil {
+				return Errored(fmt.Errorf("admit function %d of the chain: %w", i, err))
+			}
+			current = patched
+		}
+
+		resp := Allowed()
+		if !bytes.Equal(current, original) {
+			resp = PatchResponseFromRaw(original, current)
+			if !resp.Allowed {
+				return resp
+			}
+		}
+		resp.Result = result
+		resp.Warnings = SanitizeWarnings(warnings)
+		resp.AuditAnnotations = annotations
+		return resp
+	}
+}
+
+// applyPatch applies the JSON patch of resp to obj.
+func applyPatch(obj []byte, resp *admissionv1.AdmissionResponse) ([]byte, error) {
+	if resp.PatchType != nil && *resp.PatchType != admissionv1.PatchTypeJSONPatch {
+		return nil, fmt.Errorf("unsupported patch type %s", *resp.PatchType)
+	}
+	if len(obj) == 0 {
+		return nil, errors.New("cannot patch a request without an object")
+	}
+	patch, err := jsonpatch.DecodePatch(resp.Patch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode patch: %w", err)
+	}
+	patched, err := patch.Apply(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply patch: %w", err)
+	}
+	return patched, nil
+}
+
+// MutateFunc changes obj, decoded from the object of the request, in place.
+// An error fails the request.
+type MutateFunc[T any] func(ar admissionv1.AdmissionReview, obj *T) error
+
+// ValidateFunc checks obj, decoded from the object of the request. Field
+// errors deny the request.
+type ValidateFunc[T any] func(ar admissionv1.AdmissionReview, obj *T) field.ErrorList
+
+// MutateAndValidate returns an admit function for a mutating hook that
+// applies a policy in both phases at once: the object of the request is
+// decoded into a T once, mutate changes it and validate checks the mutated
+// object. If it is valid, the response patches the request's object with the
+// changes; otherwise the request is denied with the field errors, like a
+// native validation failure. Requests without an object, e.g. DELETE, are
+// allowed without calling either function.
+//
+// Mutating webhooks called later can still change the object, so register
+// ValidateObject(validate) on a validating hook as well where that matters.
+func MutateAndValidate[T any](mutate MutateFunc[T], validate ValidateFunc[T]) AdmitFunc {
+	return func(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		obj, err := decodeObject[T](ar)
+		if err != nil {
+			return Errored(err)
+		}
+		if obj == nil {
+			return Allowed()
+		}
+		// The patch is computed between two encodings of T, so that fields
+		// T does not know are left alone
+		original, err := json.Marshal(obj)
+		if err != nil {
+			return Errored(fmt.Errorf("failed to marshal original object: %w", err))
+		}
+
+		if err := mutate(ar, obj); err != nil {
+			return Errored(err)
+		}
+		if errs := validate(ar, obj); len(errs) > 0 {
+			return deniedObject(ar, errs)
+		}
+
+		modified, err := json.Marshal(obj)
+		if err != nil {
+			return Errored(fmt.Errorf("failed to marshal modified object: %w", err))
+		}
+		return PatchResponseFromRaw(original, modified)
+	}
+}
+
+// ValidateObject returns an admit function that decodes the object of the
+// request into a T and denies the request with the field errors of validate,
+// like a native validation failure. Requests without an object, e.g. DELETE,
+// are allowed without calling it.
+func ValidateObject[T any](validate ValidateFunc[T]) AdmitFunc {
+	return func(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		obj, err := decodeObject[T](ar)
+		if err != nil {
+			return Errored(err)
+		}
+		if obj == nil {
+			return Allowed()
+		}
+		if errs := validate(ar, obj); len(errs) > 0 {
+			return deniedObject(ar, errs)
+		}
+		return Allowed()
+	}
+}
+
+// decodeObject decodes the object of the request into a T. It returns nil
+// if the request has no object.
+func decodeObject[T any](ar admissionv1.AdmissionReview) (*T, error) {
+	if ar.Request == nil || len(ar.Request.Object.Raw) == 0 {
+		return nil, nil
+	}
+	obj := new(T)
+	if err := json.Unmarshal(ar.Request.Object.Raw, obj); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", ar.Request.Kind.Kind, err)
+	}
+	return obj, nil
+}
+
+// deniedObject denies the request with errs as the causes.
+func deniedObject(ar admissionv1.AdmissionReview, errs field.ErrorList) *admissionv1.AdmissionResponse {
+	gvk := schema.GroupVersionKind{Group: ar.Request.Kind.Group, Version: ar.Request.Kind.Version, Kind: ar.Request.Kind.Kind}
+	return DeniedWithFieldErrors(gvk, ar.Request.Name, errs)
+}
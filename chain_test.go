@@ -0,0 +1,229 @@
+package autocertwebhook
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	jsonpatch "gopkg.in/evanphx/json-patch.v4"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// podReview returns an AdmissionReview creating pod.
+func podReview(t *testing.T, pod *corev1.Pod) admissionv1.AdmissionReview {
+	t.Helper()
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatalf("Failed to marshal pod: %v", err)
+	}
+	return admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{
+		UID:       "1",
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		Name:      pod.Name,
+		Operation: admissionv1.Create,
+		Object:    runtime.RawExtension{Raw: raw},
+	}}
+}
+
+// defaultServiceAccount sets the service account of a pod if unset.
+func defaultServiceAccount(_ admissionv1.AdmissionReview, pod *corev1.Pod) error {
+	if pod.Spec.ServiceAccountName == "" {
+		pod.Spec.ServiceAccountName = "restricted"
+	}
+	return nil
+}
+
+// requireRestricted requires the restricted service account.
+func requireRestricted(_ admissionv1.AdmissionReview, pod *corev1.Pod) field.ErrorList {
+	if pod.Spec.ServiceAccountName != "restricted" {
+		return field.ErrorList{field.NotSupported(field.NewPath("spec", "serviceAccountName"), pod.Spec.ServiceAccountName, []string{"restricted"})}
+	}
+	return nil
+}
+
+// addLabel returns an admit function patching a label onto the object.
+func addLabel(key, value string) AdmitFunc {
+	return func(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		pod := &corev1.Pod{}
+		if err := json.Unmarshal(ar.Request.Object.Raw, pod); err != nil {
+			return Errored(err)
+		}
+		modified := pod.DeepCopy()
+		if modified.Labels == nil {
+			modified.Labels = map[string]string{}
+		}
+		modified.Labels[key] = value
+		resp := PatchResponse(pod, modified)
+		resp.Warnings = []string{"labeled " + key}
+		return resp
+	}
+}
+
+func TestChain(t *testing.T) {
+	t.Run("patches are applied in order and merged", func(t *testing.T) {
+		var seen map[string]string
+		inspect := func(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+			pod := &corev1.Pod{}
+			if err := json.Unmarshal(ar.Request.Object.Raw, pod); err != nil {
+				return Errored(err)
+			}
+			seen = pod.Labels
+			resp := Allowed()
+			resp.AuditAnnotations = map[string]string{"policy": "inspect"}
+			return resp
+		}
+		ar := podReview(t, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web"}})
+		original := string(ar.Request.Object.Raw)
+
+		resp := Chain(addLabel("team", "a"), inspect, addLabel("tier", "b"))(ar)
+		if !resp.Allowed {
+			t.Fatalf("Expected allowed, got %+v", resp.Result)
+		}
+		if seen["team"] != "a" {
+			t.Errorf("Expected the second function to see the first patch, got labels %v", seen)
+		}
+		if string(ar.Request.Object.Raw) != original {
+			t.Error("Expected the caller's request to be left alone")
+		}
+
+		patch, err := jsonpatch.DecodePatch(resp.Patch)
+		if err != nil {
+			t.Fatalf("Failed to decode patch: %v", err)
+		}
+		patched, err := patch.Apply(ar.Request.Object.Raw)
+		if err != nil {
+			t.Fatalf("Failed to apply patch: %v", err)
+		}
+		pod := &corev1.Pod{}
+		if err := json.Unmarshal(patched, pod); err != nil {
+			t.Fatalf("Failed to decode patched pod: %v", err)
+		}
+		if pod.Labels["team"] != "a" || pod.Labels["tier"] != "b" {
+			t.Errorf("Expected both labels, got %v", pod.Labels)
+		}
+		if len(resp.Warnings) != 2 || resp.AuditAnnotations["policy"] != "inspect" {
+			t.Errorf("Expected merged warnings and annotations, got %v and %v", resp.Warnings, resp.AuditAnnotations)
+		}
+	})
+
+	t.Run("first denial wins", func(t *testing.T) {
+		called := false
+		resp := Chain(addLabel("team", "a"), func(admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+			return Denied("no")
+		}, func(admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+			called = true
+			return Allowed()
+		})(podReview(t, &corev1.Pod{}))
+		if resp.Allowed || resp.Result.Message != "no" || resp.Patch != nil {
+			t.Errorf("Expected the denial without a patch, got %+v", resp)
+		}
+		if called {
+			t.Error("Expected the chain to stop at the denial")
+		}
+		if len(resp.Warnings) != 1 {
+			t.Errorf("Expected the warnings of the functions before the denial, got %v", resp.Warnings)
+		}
+	})
+
+	t.Run("no patches", func(t *testing.T) {
+		resp := Chain(func(admissionv1.AdmissionReview) *admissionv1.AdmissionResponse { return Allowed() })(podReview(t, &corev1.Pod{}))
+		if !resp.Allowed || resp.Patch != nil {
+			t.Errorf("Expected allowed without patch, got %+v", resp)
+		}
+	})
+
+	t.Run("nil response", func(t *testing.T) {
+		resp := Chain(func(admissionv1.AdmissionReview) *admissionv1.AdmissionResponse { return nil })(podReview(t, &corev1.Pod{}))
+		if resp == nil || resp.Allowed {
+			t.Errorf("Expected an error, got %+v", resp)
+		}
+	})
+
+	t.Run("invalid patch", func(t *testing.T) {
+		resp := Chain(func(admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+			patchType := admissionv1.PatchTypeJSONPatch
+			return &admissionv1.AdmissionResponse{Allowed: true, PatchType: &patchType, Patch: []byte(`[{"op":"remove","path":"/missing"}]`)}
+		})(podReview(t, &corev1.Pod{}))
+		if resp.Allowed {
+			t.Error("Expected an error for a patch that does not apply")
+		}
+	})
+}
+
+func TestMutateAndValidate(t *testing.T) {
+	admit := MutateAndValidate(defaultServiceAccount, requireRestricted)
+
+	t.Run("mutated object is validated and patched", func(t *testing.T) {
+		resp := admit(podReview(t, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web"}}))
+		if !resp.Allowed {
+			t.Fatalf("Expected allowed, got %+v", resp.Result)
+		}
+		var patches []struct {
+			Path  string
+			Value any
+		}
+		if err := json.Unmarshal(resp.Patch, &patches); err != nil {
+			t.Fatalf("Failed to decode patch: %v", err)
+		}
+		if len(patches) != 1 || patches[0].Path != "/spec/serviceAccountName" || patches[0].Value != "restricted" {
+			t.Errorf("Unexpected patch: %s", resp.Patch)
+		}
+	})
+
+	t.Run("unchanged object", func(t *testing.T) {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web"}, Spec: corev1.PodSpec{ServiceAccountName: "restricted"}}
+		resp := admit(podReview(t, pod))
+		if !resp.Allowed || resp.Patch != nil {
+			t.Errorf("Expected allowed without patch, got %+v", resp)
+		}
+	})
+
+	t.Run("invalid mutated object", func(t *testing.T) {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web"}, Spec: corev1.PodSpec{ServiceAccountName: "admin"}}
+		resp := admit(podReview(t, pod))
+		if resp.Allowed || resp.Result.Reason != metav1.StatusReasonInvalid || resp.Result.Details.Name != "web" {
+			t.Errorf("Expected an Invalid denial of web, got %+v", resp.Result)
+		}
+	})
+
+	t.Run("mutate error", func(t *testing.T) {
+		resp := MutateAndValidate(func(admissionv1.AdmissionReview, *corev1.Pod) error {
+			return errors.New("lookup failed")
+		}, requireRestricted)(podReview(t, &corev1.Pod{}))
+		if resp.Allowed || resp.Result.Message != "lookup failed" {
+			t.Errorf("Expected the mutate error, got %+v", resp.Result)
+		}
+	})
+
+	t.Run("undecodable object", func(t *testing.T) {
+		ar := podReview(t, &corev1.Pod{})
+		ar.Request.Object.Raw = []byte(`{"spec":[]}`)
+		if resp := admit(ar); resp.Allowed {
+			t.Error("Expected a decode error")
+		}
+	})
+
+	t.Run("no object", func(t *testing.T) {
+		ar := podReview(t, &corev1.Pod{})
+		ar.Request.Operation, ar.Request.Object = admissionv1.Delete, runtime.RawExtension{}
+		if resp := admit(ar); !resp.Allowed || resp.Patch != nil {
+			t.Errorf("Expected allowed without patch, got %+v", resp)
+		}
+	})
+}
+
+func TestValidateObject(t *testing.T) {
+	admit := ValidateObject(requireRestricted)
+
+	if resp := admit(podReview(t, &corev1.Pod{Spec: corev1.PodSpec{ServiceAccountName: "restricted"}})); !resp.Allowed {
+		t.Errorf("Expected allowed, got %+v", resp.Result)
+	}
+	resp := admit(podReview(t, &corev1.Pod{Spec: corev1.PodSpec{ServiceAccountName: "admin"}}))
+	if resp.Allowed || resp.Result.Reason != metav1.StatusReasonInvalid {
+		t.Errorf("Expected an Invalid denial, got %+v", resp.Result)
+	}
+}
@@ -0,0 +1,105 @@
+package autocertwebhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+
+	"github.com/jimyag/auto-cert-webhook/internal/cabundle"
+	"github.com/jimyag/auto-cert-webhook/pkg/certmanager"
+)
+
+// Cleanup deletes the resources the webhook manages instead of running it,
+// e.g. from a pre-delete hook of an uninstall. See Config.Uninstall.
+func Cleanup(admission Admission) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	return RunWithContext(ctx, uninstallAdmission{admission})
+}
+
+// CleanupWithClient deletes the resources the webhook manages with an existing
+// client, e.g. to tear down a test namespace.
+func CleanupWithClient(ctx context.Context, client kubernetes.Interface, admission Admission) error {
+	return RunWithClient(ctx, client, uninstallAdmission{admission})
+}
+
+// uninstallAdmission enables Config.Uninstall on an Admission.
+type uninstallAdmission struct {
+	Admission
+}
+
+func (a uninstallAdmission) Configure() Config {
+	cfg := a.Admission.Configure()
+	cfg.Uninstall = ptr.To(true)
+	return cfg
+}
+
+// cleanup removes the webhook configurations, or their CA bundle if they are
+// not managed, followed by the CA bundle ConfigMaps and their published
+// copies, the status ConfigMap, certificate secrets and leader election lease.
+// Resources that do not exist are skipped, so cleanup can be retried.
+func cleanup(ctx context.Context, client kubernetes.Interface, cfg *Config, webhookRefs []cabundle.WebhookRef) error {
+	// The webhook configurations go first, so that the API server stops
+	// calling the webhook before its certificates are removed
+	if err := cabundle.NewSyncer(client, cfg.Namespace, cfg.CABundleConfigMapName, webhookRefs).Cleanup(ctx); err != nil {
+		return err
+	}
+
+	type resource struct{ namespace, name string }
+	configMaps := []resource{{cfg.Namespace, cfg.CABundleConfigMapName}}
+	if cfg.StatusConfigMapName != "" {
+		configMaps = append(configMaps, resource{cfg.Namespace, cfg.StatusConfigMapName})
+	}
+	secrets := []resource{{cfg.Namespace, cfg.CASecretName}, {cfg.Namespace, cfg.CertSecretName}}
+	for _, svc := range cfg.Services {
+		configMaps = append(configMaps, resource{svc.Namespace, svc.CABundleConfigMapName})
+		secrets = append(secrets, resource{svc.Namespace, svc.CASecretName}, resource{svc.Namespace, svc.CertSecretName})
+	}
+
+	var errs []error
+	if len(cfg.CABundleNamespaces) > 0 || cfg.CABundleNamespaceSelector != "" {
+		// Copies in namespaces no longer targeted are deleted as well
+		opts := certmanager.PublishedCABundleListOptions(certmanager.Config{
+			Namespace:             cfg.Namespace,
+			CABundleConfigMapName: cfg.CABundleConfigMapName,
+		})
+		copies, err := client.CoreV1().ConfigMaps(metav1.NamespaceAll).List(ctx, opts)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to list CA bundle copies: %w", err))
+		} else {
+			for _, cm := range copies.Items {
+				configMaps = append(configMaps, resource{cm.Namespace, cm.Name})
+			}
+		}
+	}
+
+	deleted := func(kind string, r resource, err error) {
+		switch {
+		case apierrors.IsNotFound(err):
+		case err != nil:
+			errs = append(errs, fmt.Errorf("failed to delete %s %s/%s: %w", kind, r.namespace, r.name, err))
+		default:
+			klog.Infof("Deleted %s %s/%s", kind, r.namespace, r.name)
+		}
+	}
+	for _, r := range configMaps {
+		deleted("ConfigMap", r, client.CoreV1().ConfigMaps(r.namespace).Delete(ctx, r.name, metav1.DeleteOptions{}))
+	}
+	for _, r := range secrets {
+		deleted("Secret", r, client.CoreV1().Secrets(r.namespace).Delete(ctx, r.name, metav1.DeleteOptions{}))
+	}
+	lease := resource{cfg.Namespace, cfg.LeaderElectionID}
+	deleted("Lease", lease, client.CoordinationV1().Leases(lease.namespace).Delete(ctx, lease.name, metav1.DeleteOptions{}))
+
+	return errors.Join(errs...)
+}
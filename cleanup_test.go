@@ -0,0 +1,81 @@
+package autocertwebhook
+
+import (
+	"context"
+	"testing"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+
+	"github.com/jimyag/auto-cert-webhook/pkg/certmanager"
+)
+
+func TestCleanupWithClient(t *testing.T) {
+	objectMeta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: "ns"}
+	}
+	client := fake.NewSimpleClientset(
+		&admissionregistrationv1.ValidatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: "my-webhook"}},
+		&corev1.Secret{ObjectMeta: objectMeta("my-webhook-ca")},
+		&corev1.Secret{ObjectMeta: objectMeta("my-webhook-cert")},
+		&corev1.Secret{ObjectMeta: objectMeta("other")},
+		&corev1.ConfigMap{ObjectMeta: objectMeta("my-webhook-ca-bundle")},
+		&corev1.ConfigMap{ObjectMeta: objectMeta("my-webhook-status")},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-webhook-ca-bundle",
+			Namespace: "team-a",
+			Labels:    map[string]string{certmanager.CABundleSourceLabel: "ns"},
+		}},
+		&coordinationv1.Lease{ObjectMeta: objectMeta("my-webhook-leader")},
+	)
+	admission := &testAdmission{
+		cfg: Config{
+			Name:                        "my-webhook",
+			Namespace:                   "ns",
+			ManageWebhookConfigurations: ptr.To(true),
+			CABundleNamespaceSelector:   "ca-bundle=true",
+			StatusConfigMapName:         "my-webhook-status",
+		},
+		// Admit functions are not required to clean up
+		hooks: []Hook{{Path: "/validate", Type: Validating, Rules: []admissionregistrationv1.RuleWithOperations{{}}}},
+	}
+	ctx := context.Background()
+
+	if err := CleanupWithClient(ctx, client, admission); err != nil {
+		t.Fatalf("CleanupWithClient() error = %v", err)
+	}
+
+	if _, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, "my-webhook", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the webhook configuration to be deleted, got %v", err)
+	}
+	for _, name := range []string{"my-webhook-ca", "my-webhook-cert"} {
+		if _, err := client.CoreV1().Secrets("ns").Get(ctx, name, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+			t.Errorf("Expected secret %s to be deleted, got %v", name, err)
+		}
+	}
+	if _, err := client.CoreV1().Secrets("ns").Get(ctx, "other", metav1.GetOptions{}); err != nil {
+		t.Errorf("Expected an unmanaged secret to be kept: %v", err)
+	}
+	if _, err := client.CoreV1().ConfigMaps("ns").Get(ctx, "my-webhook-ca-bundle", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the CA bundle ConfigMap to be deleted, got %v", err)
+	}
+	if _, err := client.CoreV1().ConfigMaps("ns").Get(ctx, "my-webhook-status", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the status ConfigMap to be deleted, got %v", err)
+	}
+	if _, err := client.CoreV1().ConfigMaps("team-a").Get(ctx, "my-webhook-ca-bundle", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the published CA bundle copy to be deleted, got %v", err)
+	}
+	if _, err := client.CoordinationV1().Leases("ns").Get(ctx, "my-webhook-leader", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the lease to be deleted, got %v", err)
+	}
+
+	// Cleaning up again is a no-op
+	if err := CleanupWithClient(ctx, client, admission); err != nil {
+		t.Errorf("Second CleanupWithClient() error = %v", err)
+	}
+}
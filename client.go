@@ -0,0 +1,125 @@
+package autocertwebhook
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/jimyag/auto-cert-webhook/internal/writelimit"
+)
+
+// modulePath is the module path of this library, used to look up its version
+// in the build info of the binary.
+const modulePath = "github.com/jimyag/auto-cert-webhook"
+
+// defaultUserAgent returns the default user agent of the Kubernetes client,
+// "auto-cert-webhook/<version>".
+func defaultUserAgent() string {
+	return "auto-cert-webhook/" + moduleVersion()
+}
+
+// moduleVersion returns the version of this library the binary was built
+// with, or "unknown" if it is not recorded.
+func moduleVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	mod := &info.Main
+	if mod.Path != modulePath {
+		mod = nil
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				mod = dep
+				break
+			}
+		}
+	}
+	if mod == nil {
+		return "unknown"
+	}
+	if mod.Replace != nil && mod.Replace.Version != "" {
+		mod = mod.Replace
+	}
+	if mod.Version == "" || mod.Version == "(devel)" {
+		return "unknown"
+	}
+	return mod.Version
+}
+
+// newClientForConfig creates a client that funnels all API writes through a
+// shared, priority-aware rate limiter.
+func newClientForConfig(k8sCfg *rest.Config, cfg *Config) (kubernetes.Interface, error) {
+	writeLimiter := writelimit.New(writelimit.Config{
+		QPS:   cfg.APIWriteQPS,
+		Burst: cfg.APIWriteBurst,
+	})
+	k8sCfg.Wrap(writeLimiter.Wrap)
+
+	client, err := kubernetes.NewForConfig(k8sCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+	return client, nil
+}
+
+// tuneRestConfig applies the client settings of cfg to a client configuration.
+func tuneRestConfig(k8sCfg *rest.Config, cfg *Config) {
+	k8sCfg.QPS = cfg.ClientQPS
+	k8sCfg.Burst = cfg.ClientBurst
+	k8sCfg.UserAgent = cfg.UserAgent
+	if cfg.ClientTimeout > 0 {
+		timeout := cfg.ClientTimeout
+		k8sCfg.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+			return &timeoutRoundTripper{rt: rt, timeout: timeout}
+		})
+	}
+}
+
+// timeoutRoundTripper bounds requests other than watches. rest.Config.Timeout
+// is not used because it also cuts off the long-running watches of informers.
+type timeoutRoundTripper struct {
+	rt      http.RoundTripper
+	timeout time.Duration
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *timeoutRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Query().Get("watch") == "true" {
+		return t.rt.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.rt.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The deadline also covers reading the body
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// WrappedRoundTripper returns the wrapped round tripper.
+func (t *timeoutRoundTripper) WrappedRoundTripper() http.RoundTripper {
+	return t.rt
+}
+
+// cancelOnClose cancels a request context when the response body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close implements io.Closer.
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
@@ -0,0 +1,88 @@
+package autocertwebhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+func TestTuneRestConfig(t *testing.T) {
+	k8sCfg := &rest.Config{}
+	tuneRestConfig(k8sCfg, &Config{
+		ClientQPS:     20,
+		ClientBurst:   40,
+		ClientTimeout: time.Second,
+		UserAgent:     "my-webhook/v1",
+	})
+
+	if k8sCfg.QPS != 20 {
+		t.Errorf("QPS: got %v, want 20", k8sCfg.QPS)
+	}
+	if k8sCfg.Burst != 40 {
+		t.Errorf("Burst: got %d, want 40", k8sCfg.Burst)
+	}
+	if k8sCfg.UserAgent != "my-webhook/v1" {
+		t.Errorf("UserAgent: got %q, want %q", k8sCfg.UserAgent, "my-webhook/v1")
+	}
+	if k8sCfg.Timeout != 0 {
+		t.Errorf("Timeout: got %v, want 0 so that watches are not cut off", k8sCfg.Timeout)
+	}
+	if k8sCfg.WrapTransport == nil {
+		t.Error("Expected the request timeout to wrap the transport")
+	}
+}
+
+func TestNewClientForConfig(t *testing.T) {
+	k8sCfg := &rest.Config{Host: "https://127.0.0.1:6443"}
+	client, err := newClientForConfig(k8sCfg, &Config{APIWriteQPS: 5, APIWriteBurst: 10})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if client == nil {
+		t.Fatal("Expected a client")
+	}
+	if k8sCfg.WrapTransport == nil {
+		t.Error("Expected the write limiter to wrap the transport")
+	}
+}
+
+func TestTimeoutRoundTripper(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(200 * time.Millisecond):
+		}
+	}))
+	defer srv.Close()
+
+	rt := &timeoutRoundTripper{rt: http.DefaultTransport, timeout: 50 * time.Millisecond}
+
+	t.Run("times out requests", func(t *testing.T) {
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL+"/api/v1/namespaces/ns/secrets", nil)
+		_, err := rt.RoundTrip(req)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected deadline exceeded, got %v", err)
+		}
+	})
+
+	t.Run("does not time out watches", func(t *testing.T) {
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL+"/api/v1/namespaces/ns/secrets?watch=true", nil)
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		resp.Body.Close()
+	})
+}
+
+func TestDefaultUserAgent(t *testing.T) {
+	// Test binaries build this module as the main module at version (devel)
+	if got, want := defaultUserAgent(), "auto-cert-webhook/unknown"; got != want {
+		t.Errorf("defaultUserAgent: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,417 @@
+// Command acw provides operational tooling for webhooks built with auto-cert-webhook.
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/jimyag/auto-cert-webhook/pkg/bench"
+	"github.com/jimyag/auto-cert-webhook/pkg/certmanager"
+	"github.com/jimyag/auto-cert-webhook/pkg/doctor"
+	"github.com/jimyag/auto-cert-webhook/pkg/operator"
+)
+
+const usage = `Usage: acw <command> [flags]
+
+Commands:
+  doctor    Diagnose certificate, caBundle and endpoint problems of a webhook
+  bench     Send synthetic AdmissionReview load to a webhook and report latencies
+  rotate    Re-issue the serving certificate (or the CA) of a webhook now
+  backup    Export the CA certificate and private key of a webhook
+  restore   Restore a CA exported by backup into the CA secret of a webhook
+  operator  Manage the webhooks described by AutoCertWebhook resources ("operator crd" prints the CRD)
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "doctor":
+		os.Exit(runDoctor(os.Args[2:]))
+	case "bench":
+		os.Exit(runBench(os.Args[2:]))
+	case "rotate":
+		os.Exit(runRotate(os.Args[2:]))
+	case "backup":
+		os.Exit(runBackup(os.Args[2:]))
+	case "restore":
+		os.Exit(runRestore(os.Args[2:]))
+	case "operator":
+		os.Exit(runOperator(os.Args[2:]))
+	case "-h", "--help", "help":
+		fmt.Fprint(os.Stdout, usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+}
+
+// runDoctor runs the doctor command and returns the process exit code.
+// It exits with 1 if any critical finding is reported.
+func runDoctor(args []string) int {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+
+	var config doctor.Config
+	fs.StringVar(&config.Name, "name", "", "webhook name (required)")
+	fs.StringVar(&config.Namespace, "namespace", "", "namespace of the webhook (defaults to the kubeconfig context namespace)")
+	fs.StringVar(&config.ServiceName, "service-name", "", "service name (defaults to --name)")
+	fs.StringVar(&config.CASecretName, "ca-secret-name", "", "CA secret name (defaults to <name>-ca)")
+	fs.StringVar(&config.CertSecretName, "cert-secret-name", "", "serving certificate secret name (defaults to <name>-cert)")
+	fs.StringVar(&config.CABundleConfigMapName, "ca-bundle-configmap-name", "", "CA bundle configmap name (defaults to <name>-ca-bundle)")
+	fs.StringVar(&config.LeaderElectionID, "leader-election-id", "", "leader election lease name (defaults to <name>-leader)")
+	fs.StringVar(&config.CertDataKey, "cert-data-key", "", "key of the certificate in the secrets (defaults to tls.crt)")
+	fs.StringVar(&config.CABundleDataKey, "ca-bundle-data-key", "", "key of the CA bundle in the configmap (defaults to ca-bundle.crt)")
+	kubeconfig := fs.String("kubeconfig", "", "path to the kubeconfig file (defaults to $KUBECONFIG or ~/.kube/config)")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout for all API requests")
+	_ = fs.Parse(args)
+
+	if config.Name == "" {
+		fmt.Fprintln(os.Stderr, "--name is required")
+		fs.Usage()
+		return 2
+	}
+
+	client, namespace, err := newClient(*kubeconfig, config.Namespace)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	config.Namespace = namespace
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	report, err := doctor.Diagnose(ctx, client, config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "diagnosis failed: %v\n", err)
+		return 2
+	}
+	if err := report.Print(os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to print report: %v\n", err)
+		return 2
+	}
+	if report.HasCritical() {
+		return 1
+	}
+	return 0
+}
+
+// runRotate runs the rotate command and returns the process exit code.
+func runRotate(args []string) int {
+	fs := flag.NewFlagSet("rotate", flag.ExitOnError)
+
+	var config certmanager.Config
+	name := fs.String("name", "", "webhook name (required)")
+	fs.StringVar(&config.Namespace, "namespace", "", "namespace of the webhook (defaults to the kubeconfig context namespace)")
+	fs.StringVar(&config.CASecretName, "ca-secret-name", "", "CA secret name (defaults to <name>-ca)")
+	fs.StringVar(&config.CertSecretName, "cert-secret-name", "", "serving certificate secret name (defaults to <name>-cert)")
+	fs.StringVar(&config.CertDataKey, "cert-data-key", "", "key of the certificate in the secrets (defaults to tls.crt)")
+	fs.StringVar(&config.KeyDataKey, "key-data-key", "", "key of the private key in the secrets (defaults to tls.key)")
+	ca := fs.Bool("ca", false, "also replace the CA and remove the previous one from the CA bundle")
+	kubeconfig := fs.String("kubeconfig", "", "path to the kubeconfig file (defaults to $KUBECONFIG or ~/.kube/config)")
+	timeout := fs.Duration("timeout", 2*time.Minute, "how long to wait for the new certificates")
+	_ = fs.Parse(args)
+
+	if *name == "" {
+		fmt.Fprintln(os.Stderr, "--name is required")
+		fs.Usage()
+		return 2
+	}
+	if config.CASecretName == "" {
+		config.CASecretName = *name + "-ca"
+	}
+	if config.CertSecretName == "" {
+		config.CertSecretName = *name + "-cert"
+	}
+
+	client, namespace, err := newClient(*kubeconfig, config.Namespace)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	config.Namespace = namespace
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	result, err := certmanager.Rotate(ctx, client, config, certmanager.RotateOptions{CA: *ca})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rotation failed: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stdout, "CA serial number:      %s\n", result.CASerialNumber.Text(16))
+	fmt.Fprintf(os.Stdout, "Serving serial number: %s\n", result.ServingSerialNumber.Text(16))
+	return 0
+}
+
+// runBackup runs the backup command and returns the process exit code.
+func runBackup(args []string) int {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+
+	var config certmanager.Config
+	name := fs.String("name", "", "webhook name (required)")
+	fs.StringVar(&config.Namespace, "namespace", "", "namespace of the webhook (defaults to the kubeconfig context namespace)")
+	fs.StringVar(&config.CASecretName, "ca-secret-name", "", "CA secret name (defaults to <name>-ca)")
+	fs.StringVar(&config.CertDataKey, "cert-data-key", "", "key of the certificate in the secrets (defaults to tls.crt)")
+	fs.StringVar(&config.KeyDataKey, "key-data-key", "", "key of the private key in the secrets (defaults to tls.key)")
+	output := fs.String("output", "", "file to write the backup to (defaults to stdout)")
+	passphraseFile := fs.String("passphrase-file", "", "file holding the passphrase to encrypt the private key with (defaults to $ACW_CA_BACKUP_PASSPHRASE)")
+	kubeconfig := fs.String("kubeconfig", "", "path to the kubeconfig file (defaults to $KUBECONFIG or ~/.kube/config)")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout for all API requests")
+	_ = fs.Parse(args)
+
+	if *name == "" {
+		fmt.Fprintln(os.Stderr, "--name is required")
+		fs.Usage()
+		return 2
+	}
+	if config.CASecretName == "" {
+		config.CASecretName = *name + "-ca"
+	}
+	passphrase, err := readPassphrase(*passphraseFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if len(passphrase) == 0 {
+		fmt.Fprintln(os.Stderr, "warning: no passphrase given, the private key is exported unencrypted")
+	}
+
+	client, namespace, err := newClient(*kubeconfig, config.Namespace)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	config.Namespace = namespace
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	backup, err := certmanager.ExportCA(ctx, client, config, passphrase)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "backup failed: %v\n", err)
+		return 1
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(backup)
+	} else {
+		err = os.WriteFile(*output, backup, 0o600)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write backup: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runRestore runs the restore command and returns the process exit code.
+func runRestore(args []string) int {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+
+	var config certmanager.Config
+	name := fs.String("name", "", "webhook name (required)")
+	fs.StringVar(&config.Namespace, "namespace", "", "namespace of the webhook (defaults to the kubeconfig context namespace)")
+	fs.StringVar(&config.CASecretName, "ca-secret-name", "", "CA secret name (defaults to <name>-ca)")
+	fs.StringVar(&config.CertDataKey, "cert-data-key", "", "key of the certificate in the secrets (defaults to tls.crt)")
+	fs.StringVar(&config.KeyDataKey, "key-data-key", "", "key of the private key in the secrets (defaults to tls.key)")
+	file := fs.String("file", "", "backup file written by acw backup (required)")
+	passphraseFile := fs.String("passphrase-file", "", "file holding the passphrase the private key is encrypted with (defaults to $ACW_CA_BACKUP_PASSPHRASE)")
+	kubeconfig := fs.String("kubeconfig", "", "path to the kubeconfig file (defaults to $KUBECONFIG or ~/.kube/config)")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout for all API requests")
+	_ = fs.Parse(args)
+
+	if *name == "" || *file == "" {
+		fmt.Fprintln(os.Stderr, "--name and --file are required")
+		fs.Usage()
+		return 2
+	}
+	if config.CASecretName == "" {
+		config.CASecretName = *name + "-ca"
+	}
+	backup, err := os.ReadFile(*file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read backup: %v\n", err)
+		return 2
+	}
+	passphrase, err := readPassphrase(*passphraseFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	client, namespace, err := newClient(*kubeconfig, config.Namespace)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	config.Namespace = namespace
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	if err := certmanager.RestoreCA(ctx, client, config, backup, passphrase); err != nil {
+		fmt.Fprintf(os.Stderr, "restore failed: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stdout, "Restored CA into secret %s/%s\n", config.Namespace, config.CASecretName)
+	return 0
+}
+
+// runOperator runs the operator command and returns the process exit code.
+// "operator crd" prints the CustomResourceDefinition instead.
+func runOperator(args []string) int {
+	if len(args) > 0 && args[0] == "crd" {
+		_, _ = os.Stdout.Write(operator.CRD)
+		return 0
+	}
+
+	fs := flag.NewFlagSet("operator", flag.ExitOnError)
+
+	var config operator.Config
+	fs.StringVar(&config.Namespace, "namespace", "", "namespace of the leader election lease (defaults to the kubeconfig context or pod namespace)")
+	fs.StringVar(&config.WatchNamespace, "watch-namespace", "", "only manage the AutoCertWebhook resources of this namespace (defaults to all)")
+	fs.BoolVar(&config.LeaderElection, "leader-elect", true, "run only one active replica")
+	fs.StringVar(&config.LeaderElectionID, "leader-election-id", "", "leader election lease name (defaults to auto-cert-webhook-operator)")
+	fs.DurationVar(&config.SyncInterval, "sync-interval", time.Minute, "certificate sync and status refresh interval")
+	kubeconfig := fs.String("kubeconfig", "", "path to the kubeconfig file (defaults to $KUBECONFIG, ~/.kube/config or the in-cluster configuration)")
+	_ = fs.Parse(args)
+
+	restConfig, namespace, err := newRestConfig(*kubeconfig, config.Namespace)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	config.Namespace = namespace
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create client: %v\n", err)
+		return 2
+	}
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create dynamic client: %v\n", err)
+		return 2
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if err := operator.New(client, dynamicClient, config).Run(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "operator failed: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// readPassphrase reads a passphrase from a file, without trailing newlines,
+// or from $ACW_CA_BACKUP_PASSPHRASE if file is empty.
+func readPassphrase(file string) ([]byte, error) {
+	if file == "" {
+		return []byte(os.Getenv("ACW_CA_BACKUP_PASSPHRASE")), nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return bytes.TrimRight(data, "\r\n"), nil
+}
+
+// newClient creates a client from a kubeconfig file. An empty namespace
+// defaults to the namespace of the kubeconfig context.
+func newClient(kubeconfig, namespace string) (kubernetes.Interface, string, error) {
+	restConfig, namespace, err := newRestConfig(kubeconfig, namespace)
+	if err != nil {
+		return nil, "", err
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create client: %w", err)
+	}
+	return client, namespace, nil
+}
+
+// newRestConfig loads a kubeconfig file, or the in-cluster configuration
+// without one. An empty namespace defaults to the namespace of the kubeconfig
+// context.
+func newRestConfig(kubeconfig, namespace string) (*rest.Config, string, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{})
+
+	if namespace == "" {
+		ns, _, err := clientConfig.Namespace()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to determine namespace: %w", err)
+		}
+		namespace = ns
+	}
+
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	return restConfig, namespace, nil
+}
+
+// runBench runs the bench command and returns the process exit code.
+func runBench(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+
+	var config bench.Config
+	fs.StringVar(&config.URL, "url", "", "hook URL, e.g. https://localhost:8443/validate-pods (required)")
+	fs.IntVar(&config.Concurrency, "concurrency", 10, "number of concurrent clients")
+	fs.IntVar(&config.Requests, "requests", 0, "total number of requests (0 runs for --duration)")
+	fs.DurationVar(&config.Duration, "duration", 10*time.Second, "test duration when --requests is 0")
+	fs.IntVar(&config.ObjectSize, "object-size", 2048, "approximate size in bytes of the generated Pod")
+	fs.StringVar(&config.Namespace, "namespace", "default", "namespace of the generated Pods")
+	fs.DurationVar(&config.RequestTimeout, "timeout", 10*time.Second, "timeout of a single request")
+	fs.BoolVar(&config.InsecureSkipVerify, "insecure", false, "skip server certificate verification")
+	operation := fs.String("operation", "CREATE", "admission operation: CREATE, UPDATE, DELETE or CONNECT")
+	caFile := fs.String("ca-file", "", "PEM file with the CA used to verify the server certificate")
+	_ = fs.Parse(args)
+
+	if config.URL == "" {
+		fmt.Fprintln(os.Stderr, "--url is required")
+		fs.Usage()
+		return 2
+	}
+	config.Operation = admissionv1.Operation(*operation)
+
+	if *caFile != "" {
+		ca, err := os.ReadFile(*caFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read CA file: %v\n", err)
+			return 2
+		}
+		config.CACert = ca
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	result, err := bench.Run(ctx, config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "benchmark failed: %v\n", err)
+		return 2
+	}
+	if err := result.Print(os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to print result: %v\n", err)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,58 @@
+package autocertwebhook
+
+import (
+	"context"
+	"fmt"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/klog/v2"
+)
+
+// accessReviewer checks whether a user is allowed an action.
+type accessReviewer interface {
+	Allowed(ctx context.Context, user authenticationv1.UserInfo, attrs authorizationv1.ResourceAttributes) (bool, error)
+}
+
+// validateAccessCheck validates the required fields of an access check.
+func validateAccessCheck(check *AccessCheck) error {
+	if check.Verb == "" {
+		return fmt.Errorf("verb is required")
+	}
+	if check.Resource == "" {
+		return fmt.Errorf("resource is required")
+	}
+	return nil
+}
+
+// exemptByRBAC wraps an admit function so that requests of users allowed the
+// checked permission are admitted without calling it.
+func exemptByRBAC(reviewer accessReviewer, check AccessCheck, admit AdmitContextFunc) AdmitContextFunc {
+	return func(ctx context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		if ar.Request == nil {
+			return admit(ctx, ar)
+		}
+
+		allowed, err := reviewer.Allowed(ctx, ar.Request.UserInfo, authorizationv1.ResourceAttributes{
+			Namespace:   ar.Request.Namespace,
+			Verb:        check.Verb,
+			Group:       check.Group,
+			Resource:    check.Resource,
+			Subresource: check.Subresource,
+			Name:        check.Name,
+		})
+		if err != nil {
+			klog.Errorf("Failed to check exemption of %q for request %s, evaluating it: %v", ar.Request.UserInfo.Username, ar.Request.UID, err)
+			return admit(ctx, ar)
+		}
+		if !allowed {
+			return admit(ctx, ar)
+		}
+
+		klog.V(2).Infof("Request %s exempted: %q may %s %s", ar.Request.UID, ar.Request.UserInfo.Username, check.Verb, check.Resource)
+		resp := Allowed()
+		resp.AuditAnnotations = map[string]string{"exempted-by": "rbac"}
+		return resp
+	}
+}
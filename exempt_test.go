@@ -0,0 +1,102 @@
+package autocertwebhook
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+)
+
+// stubReviewer allows user "admin" and records the checked attributes.
+type stubReviewer struct {
+	err   error
+	attrs authorizationv1.ResourceAttributes
+}
+
+func (r *stubReviewer) Allowed(ctx context.Context, user authenticationv1.UserInfo, attrs authorizationv1.ResourceAttributes) (bool, error) {
+	r.attrs = attrs
+	return user.Username == "admin", r.err
+}
+
+func TestValidateAccessCheck(t *testing.T) {
+	if err := validateAccessCheck(&AccessCheck{Verb: "bypass", Resource: "podpolicies"}); err != nil {
+		t.Errorf("Expected valid check, got %v", err)
+	}
+	if err := validateAccessCheck(&AccessCheck{Resource: "podpolicies"}); err == nil {
+		t.Error("Expected error without verb")
+	}
+	if err := validateAccessCheck(&AccessCheck{Verb: "bypass"}); err == nil {
+		t.Error("Expected error without resource")
+	}
+}
+
+func TestExemptByRBAC(t *testing.T) {
+	check := AccessCheck{Verb: "bypass", Group: "policy.example.com", Resource: "podpolicies", Name: "strict"}
+	review := func(user string) admissionv1.AdmissionReview {
+		return admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{
+			UID:       "uid",
+			Namespace: "ns",
+			UserInfo:  authenticationv1.UserInfo{Username: user},
+		}}
+	}
+
+	tests := []struct {
+		name       string
+		user       string
+		err        error
+		wantCalled bool
+	}{
+		{name: "exempt user", user: "admin"},
+		{name: "other user", user: "alice", wantCalled: true},
+		{name: "review error", user: "admin", err: fmt.Errorf("unavailable"), wantCalled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			reviewer := &stubReviewer{err: tt.err}
+			admit := exemptByRBAC(reviewer, check, func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+				called = true
+				return Denied("denied")
+			})
+
+			resp := admit(context.Background(), review(tt.user))
+
+			if called != tt.wantCalled {
+				t.Errorf("Admit called: got %v, want %v", called, tt.wantCalled)
+			}
+			if !tt.wantCalled {
+				if !resp.Allowed {
+					t.Error("Expected exempt request to be allowed")
+				}
+				if resp.AuditAnnotations["exempted-by"] != "rbac" {
+					t.Errorf("AuditAnnotations: got %v", resp.AuditAnnotations)
+				}
+			}
+			want := authorizationv1.ResourceAttributes{
+				Namespace: "ns",
+				Verb:      "bypass",
+				Group:     "policy.example.com",
+				Resource:  "podpolicies",
+				Name:      "strict",
+			}
+			if reviewer.attrs != want {
+				t.Errorf("Attributes: got %+v, want %+v", reviewer.attrs, want)
+			}
+		})
+	}
+
+	t.Run("nil request", func(t *testing.T) {
+		called := false
+		admit := exemptByRBAC(&stubReviewer{}, check, func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+			called = true
+			return Allowed()
+		})
+		admit(context.Background(), admissionv1.AdmissionReview{})
+		if !called {
+			t.Error("Expected admit to be called for nil request")
+		}
+	})
+}
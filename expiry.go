@@ -0,0 +1,221 @@
+package autocertwebhook
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	listerscorev1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/klog/v2"
+
+	"github.com/jimyag/auto-cert-webhook/internal/metrics"
+)
+
+// ExpiryAlert reports a certificate that is close to expiry although it
+// should have been rotated, e.g. because the leader is stuck or lacks
+// permissions.
+type ExpiryAlert struct {
+	// Text is a human-readable summary, also used by Slack-style receivers.
+	Text string `json:"text"`
+
+	// Kind is "CA" or "serving".
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace"`
+	SecretName string `json:"secretName"`
+
+	// SerialNumber is the hexadecimal serial number of the certificate.
+	SerialNumber string    `json:"serialNumber"`
+	NotAfter     time.Time `json:"notAfter"`
+
+	// Pod is the pod that raised the alert.
+	Pod string `json:"pod"`
+}
+
+// expiryAlertTimeout bounds the POST of an alert to the alert webhook.
+const expiryAlertTimeout = 10 * time.Second
+
+// expiryMonitor raises an alert for each certificate that is within its
+// threshold of expiry, and keeps the certificate metrics current, including
+// those of the CA. Every pod runs one, so that a stuck leader is caught.
+type expiryMonitor struct {
+	lister    listerscorev1.SecretLister
+	synced    cache.InformerSynced
+	namespace string
+	pod       string
+	interval  time.Duration
+
+	// certKey is the key of the certificate in the secrets.
+	certKey string
+
+	// secrets maps the secrets to check to their kind.
+	secrets    map[string]string
+	thresholds map[string]time.Duration
+
+	webhookURL string
+	httpClient *http.Client
+	onAlert    func(ExpiryAlert)
+
+	// alerted holds the certificates already alerted on, by serial number.
+	alerted map[string]bool
+}
+
+// newExpiryMonitor creates the expiry monitor of the webhook's certificates.
+func newExpiryMonitor(informer cache.SharedIndexInformer, cfg *Config) *expiryMonitor {
+	interval := cfg.CertSyncInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &expiryMonitor{
+		lister:    listerscorev1.NewSecretLister(informer.GetIndexer()),
+		synced:    informer.HasSynced,
+		namespace: cfg.Namespace,
+		certKey:   cmp.Or(cfg.CertDataKey, corev1.TLSCertKey),
+		pod:       podName(),
+		interval:  interval,
+		secrets: map[string]string{
+			cfg.CASecretName:   "CA",
+			cfg.CertSecretName: "serving",
+		},
+		thresholds: map[string]time.Duration{
+			"CA":      expiryAlertThreshold(cfg.ExpiryAlertThreshold, cfg.CAValidity, cfg.CARefresh),
+			"serving": expiryAlertThreshold(cfg.ExpiryAlertThreshold, cfg.CertValidity, cfg.CertRefresh),
+		},
+		webhookURL: cfg.ExpiryAlertWebhookURL,
+		httpClient: &http.Client{Timeout: expiryAlertTimeout},
+		onAlert:    cfg.OnExpiryAlert,
+		alerted:    map[string]bool{},
+	}
+}
+
+// expiryAlertThreshold returns the configured threshold, or by default half
+// the time a certificate has left when it is normally rotated: at the refresh
+// interval or 80% of its validity, whichever comes first.
+func expiryAlertThreshold(configured, validity, refresh time.Duration) time.Duration {
+	if configured > 0 {
+		return configured
+	}
+	return max(validity-refresh, validity/5) / 2
+}
+
+// Run checks the certificates every interval until ctx is cancelled.
+func (m *expiryMonitor) Run(ctx context.Context) error {
+	for kind, threshold := range m.thresholds {
+		metrics.SetExpiryThreshold(strings.ToLower(kind), threshold)
+	}
+
+	if !cache.WaitForCacheSync(ctx.Done(), m.synced) {
+		return nil
+	}
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		m.check(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// check raises an alert for each certificate within its threshold of expiry
+// that was not alerted on yet.
+func (m *expiryMonitor) check(ctx context.Context) {
+	for name, kind := range m.secrets {
+		secret, err := m.lister.Secrets(m.namespace).Get(name)
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				klog.Errorf("Failed to get secret %s/%s: %v", m.namespace, name, err)
+			}
+			continue
+		}
+		if len(secret.Data[m.certKey]) == 0 {
+			continue
+		}
+		certs, err := certutil.ParseCertsPEM(secret.Data[m.certKey])
+		if err != nil {
+			klog.Errorf("Failed to parse certificate of secret %s/%s: %v", m.namespace, name, err)
+			continue
+		}
+
+		cert := certs[0]
+		metrics.UpdateCertMetrics(metrics.CertID{Type: strings.ToLower(kind), Namespace: m.namespace, SecretName: name}, cert)
+
+		remaining := time.Until(cert.NotAfter)
+		serial := cert.SerialNumber.Text(16)
+		if remaining > m.thresholds[kind] || m.alerted[serial] {
+			continue
+		}
+		m.alerted[serial] = true
+
+		alert := ExpiryAlert{
+			Text: fmt.Sprintf("%s certificate in secret %s/%s expires in %s (at %s) and has not been rotated",
+				kind, m.namespace, name, remaining.Round(time.Second), cert.NotAfter.UTC().Format(time.RFC3339)),
+			Kind:         kind,
+			Namespace:    m.namespace,
+			SecretName:   name,
+			SerialNumber: serial,
+			NotAfter:     cert.NotAfter,
+			Pod:          m.pod,
+		}
+		m.notify(ctx, alert)
+	}
+}
+
+// notify logs an alert and passes it to the callback and the alert webhook.
+func (m *expiryMonitor) notify(ctx context.Context, alert ExpiryAlert) {
+	klog.Warning(alert.Text)
+
+	if m.onAlert != nil {
+		m.onAlert(alert)
+	}
+	if m.webhookURL != "" {
+		if err := m.post(ctx, alert); err != nil {
+			klog.Errorf("Failed to send expiry alert to webhook: %v", err)
+		}
+	}
+}
+
+// post sends an alert as JSON to the alert webhook.
+func (m *expiryMonitor) post(ctx context.Context, alert ExpiryAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// podName returns the name of the current pod, falling back to the hostname.
+func podName() string {
+	if name := os.Getenv("POD_NAME"); name != "" {
+		return name
+	}
+	hostname, _ := os.Hostname()
+	return hostname
+}
@@ -0,0 +1,139 @@
+package autocertwebhook
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestExpiryAlertThreshold(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured time.Duration
+		validity   time.Duration
+		refresh    time.Duration
+		want       time.Duration
+	}{
+		{name: "configured", configured: time.Hour, validity: 24 * time.Hour, refresh: 12 * time.Hour, want: time.Hour},
+		{name: "refresh first", validity: 24 * time.Hour, refresh: 12 * time.Hour, want: 6 * time.Hour},
+		{name: "80% of validity first", validity: 10 * time.Hour, refresh: 9 * time.Hour, want: time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expiryAlertThreshold(tt.configured, tt.validity, tt.refresh); got != tt.want {
+				t.Errorf("expiryAlertThreshold() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpiryMonitor_check(t *testing.T) {
+	received := make(chan ExpiryAlert, 2)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert ExpiryAlert
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			t.Errorf("Failed to decode alert: %v", err)
+		}
+		received <- alert
+	}))
+	defer receiver.Close()
+
+	client := fake.NewSimpleClientset(
+		testCertSecret(t, "wh-ca", 48*time.Hour),
+		testCertSecret(t, "wh-cert", time.Hour),
+	)
+	factory := informers.NewSharedInformerFactoryWithOptions(client, 0, informers.WithNamespace("ns"))
+	informer := factory.Core().V1().Secrets().Informer()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer factory.Shutdown()
+	defer cancel()
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		t.Fatal("Failed to sync informer")
+	}
+
+	var alerts []ExpiryAlert
+	m := newExpiryMonitor(informer, &Config{
+		Namespace:             "ns",
+		CASecretName:          "wh-ca",
+		CertSecretName:        "wh-cert",
+		CAValidity:            48 * time.Hour,
+		CARefresh:             24 * time.Hour,
+		CertValidity:          24 * time.Hour,
+		CertRefresh:           12 * time.Hour,
+		ExpiryAlertWebhookURL: receiver.URL,
+		OnExpiryAlert: func(alert ExpiryAlert) {
+			alerts = append(alerts, alert)
+		},
+	})
+
+	m.check(ctx)
+	// A certificate is only alerted on once
+	m.check(ctx)
+
+	if len(alerts) != 1 {
+		t.Fatalf("Expected 1 alert, got %d: %+v", len(alerts), alerts)
+	}
+	if alerts[0].Kind != "serving" || alerts[0].SecretName != "wh-cert" {
+		t.Errorf("Expected an alert for the serving certificate, got %+v", alerts[0])
+	}
+
+	select {
+	case alert := <-received:
+		if alert.SerialNumber != alerts[0].SerialNumber || alert.Text == "" {
+			t.Errorf("Webhook received %+v, want %+v", alert, alerts[0])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the alert webhook to be called")
+	}
+	if len(received) != 0 {
+		t.Error("Expected the alert webhook to be called once")
+	}
+}
+
+// testCertSecret returns a TLS secret in namespace "ns" with a self-signed
+// certificate that expires after validity.
+func testCertSecret(t *testing.T, name string, validity time.Duration) *corev1.Secret {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatalf("Failed to generate serial number: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(validity),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"},
+		Data: map[string][]byte{
+			"tls.crt": pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		},
+	}
+}
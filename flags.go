@@ -0,0 +1,112 @@
+package autocertwebhook
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// ConfigFlags binds the environment-configurable fields of Config to
+// command-line flags named after their environment variables, e.g.
+// ACW_CERT_SYNC_INTERVAL becomes --cert-sync-interval. Flags that are set
+// take priority over code, environment variables and defaults.
+//
+// Usage with cobra:
+//
+//	flags := webhook.NewConfigFlags()
+//	flags.AddFlags(cmd.Flags())
+//
+// and set Config.Flags to flags in Configure.
+type ConfigFlags struct {
+	fs     *pflag.FlagSet
+	values Config
+	// bools holds the values of *bool fields, by flag name.
+	bools map[string]*bool
+	// fields maps the flag names to the index of their Config field.
+	fields map[string]int
+}
+
+// NewConfigFlags creates flags for the Config fields.
+func NewConfigFlags() *ConfigFlags {
+	return &ConfigFlags{
+		bools:  make(map[string]*bool),
+		fields: make(map[string]int),
+	}
+}
+
+// AddFlags registers a flag for each Config field that can be set through an
+// environment variable.
+func (f *ConfigFlags) AddFlags(fs *pflag.FlagSet) {
+	f.fs = fs
+	values := reflect.ValueOf(&f.values).Elem()
+	for i := 0; i < values.NumField(); i++ {
+		field := values.Type().Field(i)
+		env, ok := field.Tag.Lookup("envconfig")
+		if !ok || field.Tag.Get("ignored") == "true" {
+			continue
+		}
+		name := flagName(env)
+		usage := fmt.Sprintf("Same as the %s_%s environment variable", envPrefix, env)
+
+		switch p := values.Field(i).Addr().Interface().(type) {
+		case *string:
+			fs.StringVar(p, name, "", usage)
+		case *int:
+			fs.IntVar(p, name, 0, usage)
+		case *int32:
+			fs.Int32Var(p, name, 0, usage)
+		case *float32:
+			fs.Float32Var(p, name, 0, usage)
+		case *time.Duration:
+			fs.DurationVar(p, name, 0, usage)
+		case *[]string:
+			fs.StringSliceVar(p, name, nil, usage)
+		case *[]float64:
+			fs.Float64SliceVar(p, name, nil, usage)
+		case **bool:
+			b := new(bool)
+			fs.BoolVar(b, name, false, usage)
+			f.bools[name] = b
+		default:
+			panic(fmt.Sprintf("unsupported type %s of Config.%s", field.Type, field.Name))
+		}
+		if def, ok := field.Tag.Lookup("default"); ok {
+			fs.Lookup(name).DefValue = def
+		}
+		f.fields[name] = i
+	}
+}
+
+// changed returns whether the flag of an environment variable was set.
+func (f *ConfigFlags) changed(env string) bool {
+	return f != nil && f.fs != nil && f.fs.Changed(flagName(env))
+}
+
+// flagName returns the flag name of an environment variable without prefix,
+// e.g. "cert-sync-interval" for CERT_SYNC_INTERVAL.
+func flagName(env string) string {
+	return strings.ToLower(strings.ReplaceAll(env, "_", "-"))
+}
+
+// Apply sets the Config fields whose flags were set on the command line.
+func (f *ConfigFlags) Apply(cfg *Config) {
+	if f == nil || f.fs == nil {
+		return
+	}
+	values := reflect.ValueOf(&f.values).Elem()
+	target := reflect.ValueOf(cfg).Elem()
+	for name, i := range f.fields {
+		if !f.fs.Changed(name) {
+			continue
+		}
+		if b, ok := f.bools[name]; ok {
+			v := *b
+			target.Field(i).Set(reflect.ValueOf(&v))
+			continue
+		}
+		target.Field(i).Set(values.Field(i))
+	}
+}
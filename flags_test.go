@@ -0,0 +1,95 @@
+package autocertwebhook
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func TestConfigFlags(t *testing.T) {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags := NewConfigFlags()
+	flags.AddFlags(fs)
+
+	// Every environment variable has a flag
+	bound := make(map[int]bool)
+	for _, i := range flags.fields {
+		bound[i] = true
+	}
+	typ := reflect.TypeOf(Config{})
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if _, ok := field.Tag.Lookup("envconfig"); ok && field.Tag.Get("ignored") != "true" && !bound[i] {
+			t.Errorf("No flag for Config.%s", field.Name)
+		}
+	}
+	if got := fs.Lookup("cert-sync-interval").DefValue; got != "1m" {
+		t.Errorf("cert-sync-interval default: got %q, want 1m", got)
+	}
+
+	err := fs.Parse([]string{
+		"--name=from-flag",
+		"--port=9443",
+		"--cert-sync-interval=30s",
+		"--leader-election=false",
+		"--token-review-audiences=a,b",
+		"--metrics-latency-buckets=0.001,0.01",
+		"--rate-limit-qps=2.5",
+	})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	cfg := Config{Name: "from-code", MetricsPath: "/custom"}
+	flags.Apply(&cfg)
+
+	if cfg.Name != "from-flag" {
+		t.Errorf("Name: got %q, want from-flag", cfg.Name)
+	}
+	if cfg.MetricsPath != "/custom" {
+		t.Errorf("MetricsPath: got %q, want the code value", cfg.MetricsPath)
+	}
+	if cfg.Port != 9443 || cfg.CertSyncInterval != 30*time.Second || cfg.RateLimitQPS != 2.5 {
+		t.Errorf("Unexpected values: port %d, cert sync interval %v, rate limit %v", cfg.Port, cfg.CertSyncInterval, cfg.RateLimitQPS)
+	}
+	if cfg.LeaderElection == nil || *cfg.LeaderElection {
+		t.Errorf("LeaderElection: got %v, want false", cfg.LeaderElection)
+	}
+	if !reflect.DeepEqual(cfg.TokenReviewAudiences, []string{"a", "b"}) {
+		t.Errorf("TokenReviewAudiences: got %v", cfg.TokenReviewAudiences)
+	}
+	if !reflect.DeepEqual(cfg.MetricsLatencyBuckets, []float64{0.001, 0.01}) {
+		t.Errorf("MetricsLatencyBuckets: got %v", cfg.MetricsLatencyBuckets)
+	}
+	if cfg.MetricsEnabled != nil {
+		t.Errorf("MetricsEnabled: got %v, want unset", *cfg.MetricsEnabled)
+	}
+}
+
+func TestApplyEnvConfig_Flags(t *testing.T) {
+	t.Setenv("ACW_PORT", "7443")
+	t.Setenv("ACW_MAX_RESTARTS", "3")
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags := NewConfigFlags()
+	flags.AddFlags(fs)
+	if err := fs.Parse([]string{"--max-restarts=0"}); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	cfg := Config{Name: "wh", Flags: flags}
+	if err := applyEnvConfig(&cfg); err != nil {
+		t.Fatalf("applyEnvConfig() error = %v", err)
+	}
+	cfg.Flags.Apply(&cfg)
+
+	if cfg.Port != 7443 {
+		t.Errorf("Port: got %d, want the env value 7443", cfg.Port)
+	}
+	// Zero values set by flag are kept
+	if cfg.MaxRestarts != 0 {
+		t.Errorf("MaxRestarts: got %d, want the flag value 0", cfg.MaxRestarts)
+	}
+}
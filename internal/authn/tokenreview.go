@@ -0,0 +1,119 @@
+// Package authn authenticates callers of the webhook server.
+package authn
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// DefaultCacheTTL is how long TokenReview results are cached by default.
+	DefaultCacheTTL = 10 * time.Second
+
+	// cacheSize bounds the number of cached TokenReview results.
+	cacheSize = 4096
+
+	// reviewTimeout bounds a single TokenReview call.
+	reviewTimeout = 5 * time.Second
+)
+
+// Config holds the TokenReview authenticator configuration.
+type Config struct {
+	// Audiences the token must be valid for. If empty, the API server's
+	// audiences are used.
+	Audiences []string
+
+	// CacheTTL is how long results are cached. Defaults to DefaultCacheTTL;
+	// a negative value disables caching.
+	CacheTTL time.Duration
+}
+
+// TokenReviewer authenticates bearer tokens with the TokenReview API.
+type TokenReviewer struct {
+	client    kubernetes.Interface
+	audiences []string
+	ttl       time.Duration
+	cache     *cache.LRUExpireCache
+}
+
+// result is a cached TokenReview outcome.
+type result struct {
+	user          string
+	authenticated bool
+}
+
+// NewTokenReviewer creates a new TokenReview authenticator.
+func NewTokenReviewer(client kubernetes.Interface, config Config) *TokenReviewer {
+	if config.CacheTTL == 0 {
+		config.CacheTTL = DefaultCacheTTL
+	}
+	return &TokenReviewer{
+		client:    client,
+		audiences: config.Audiences,
+		ttl:       config.CacheTTL,
+		cache:     cache.NewLRUExpireCache(cacheSize),
+	}
+}
+
+// AuthenticateRequest authenticates the bearer token of a request and returns
+// the username it belongs to. Requests without a bearer token are not
+// authenticated. An error means the token could not be reviewed.
+func (t *TokenReviewer) AuthenticateRequest(r *http.Request) (string, bool, error) {
+	token, ok := bearerToken(r)
+	if !ok {
+		return "", false, nil
+	}
+
+	// Only a hash of the token is kept in memory.
+	key := sha256.Sum256([]byte(token))
+	if t.ttl > 0 {
+		if cached, ok := t.cache.Get(key); ok {
+			res := cached.(result)
+			return res.user, res.authenticated, nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), reviewTimeout)
+	defer cancel()
+	review, err := t.client.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token:     token,
+			Audiences: t.audiences,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return "", false, fmt.Errorf("failed to create token review: %w", err)
+	}
+
+	res := result{
+		user:          review.Status.User.Username,
+		authenticated: review.Status.Authenticated,
+	}
+	if !res.authenticated && review.Status.Error != "" {
+		klog.V(4).Infof("Token review rejected token: %s", review.Status.Error)
+	}
+	if t.ttl > 0 {
+		t.cache.Add(key, res, t.ttl)
+	}
+	return res.user, res.authenticated, nil
+}
+
+// bearerToken returns the bearer token of a request.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
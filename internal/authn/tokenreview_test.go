@@ -0,0 +1,129 @@
+package authn
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newFakeClient returns a client whose TokenReviews accept token "good" as
+// user "proxy" and count the reviews issued.
+func newFakeClient(reviews *int, audiences *[]string) *fake.Clientset {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		*reviews++
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		if audiences != nil {
+			*audiences = review.Spec.Audiences
+		}
+		switch review.Spec.Token {
+		case "good":
+			review.Status = authenticationv1.TokenReviewStatus{
+				Authenticated: true,
+				User:          authenticationv1.UserInfo{Username: "proxy"},
+			}
+		case "broken":
+			return true, nil, fmt.Errorf("connection refused")
+		default:
+			review.Status = authenticationv1.TokenReviewStatus{Error: "invalid bearer token"}
+		}
+		return true, review, nil
+	})
+	return client
+}
+
+func newRequest(authorization string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/validate", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	return req
+}
+
+func TestTokenReviewer_AuthenticateRequest(t *testing.T) {
+	var reviews int
+	var audiences []string
+	reviewer := NewTokenReviewer(newFakeClient(&reviews, &audiences), Config{Audiences: []string{"webhook"}})
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantUser      string
+		wantOK        bool
+		wantErr       bool
+	}{
+		{name: "no header"},
+		{name: "basic auth", authorization: "Basic dXNlcjpwYXNz"},
+		{name: "empty token", authorization: "Bearer  "},
+		{name: "valid token", authorization: "Bearer good", wantUser: "proxy", wantOK: true},
+		{name: "lowercase scheme", authorization: "bearer good", wantUser: "proxy", wantOK: true},
+		{name: "invalid token", authorization: "Bearer bad"},
+		{name: "review error", authorization: "Bearer broken", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, ok, err := reviewer.AuthenticateRequest(newRequest(tt.authorization))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AuthenticateRequest: got error %v, want error %v", err, tt.wantErr)
+			}
+			if user != tt.wantUser || ok != tt.wantOK {
+				t.Errorf("AuthenticateRequest: got (%q, %v), want (%q, %v)", user, ok, tt.wantUser, tt.wantOK)
+			}
+		})
+	}
+
+	if len(audiences) != 1 || audiences[0] != "webhook" {
+		t.Errorf("Audiences: got %v, want [webhook]", audiences)
+	}
+}
+
+func TestTokenReviewer_Cache(t *testing.T) {
+	t.Run("results are cached", func(t *testing.T) {
+		var reviews int
+		reviewer := NewTokenReviewer(newFakeClient(&reviews, nil), Config{})
+
+		for i := 0; i < 3; i++ {
+			if _, ok, _ := reviewer.AuthenticateRequest(newRequest("Bearer good")); !ok {
+				t.Fatal("Expected token to be authenticated")
+			}
+			if _, ok, _ := reviewer.AuthenticateRequest(newRequest("Bearer bad")); ok {
+				t.Fatal("Expected token to be rejected")
+			}
+		}
+		if reviews != 2 {
+			t.Errorf("Reviews: got %d, want 2", reviews)
+		}
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		var reviews int
+		reviewer := NewTokenReviewer(newFakeClient(&reviews, nil), Config{})
+
+		for i := 0; i < 2; i++ {
+			if _, _, err := reviewer.AuthenticateRequest(newRequest("Bearer broken")); err == nil {
+				t.Fatal("Expected error")
+			}
+		}
+		if reviews != 2 {
+			t.Errorf("Reviews: got %d, want 2", reviews)
+		}
+	})
+
+	t.Run("caching disabled", func(t *testing.T) {
+		var reviews int
+		reviewer := NewTokenReviewer(newFakeClient(&reviews, nil), Config{CacheTTL: -1})
+
+		for i := 0; i < 2; i++ {
+			reviewer.AuthenticateRequest(newRequest("Bearer good"))
+		}
+		if reviews != 2 {
+			t.Errorf("Reviews: got %d, want 2", reviews)
+		}
+	})
+}
@@ -0,0 +1,103 @@
+// Package authz checks the permissions of users with SubjectAccessReviews.
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// DefaultCacheTTL is how long SubjectAccessReview results are cached by default.
+	DefaultCacheTTL = 10 * time.Second
+
+	// cacheSize bounds the number of cached SubjectAccessReview results.
+	cacheSize = 4096
+
+	// reviewTimeout bounds a single SubjectAccessReview call.
+	reviewTimeout = 5 * time.Second
+)
+
+// Config holds the SubjectAccessReview configuration.
+type Config struct {
+	// CacheTTL is how long results are cached. Defaults to DefaultCacheTTL;
+	// a negative value disables caching.
+	CacheTTL time.Duration
+}
+
+// Reviewer checks permissions with the SubjectAccessReview API.
+type Reviewer struct {
+	client kubernetes.Interface
+	ttl    time.Duration
+	cache  *cache.LRUExpireCache
+}
+
+// New creates a new SubjectAccessReview reviewer.
+func New(client kubernetes.Interface, config Config) *Reviewer {
+	if config.CacheTTL == 0 {
+		config.CacheTTL = DefaultCacheTTL
+	}
+	return &Reviewer{
+		client: client,
+		ttl:    config.CacheTTL,
+		cache:  cache.NewLRUExpireCache(cacheSize),
+	}
+}
+
+// reviewKey identifies a cached review.
+type reviewKey struct {
+	User       authenticationv1.UserInfo          `json:"user"`
+	Attributes authorizationv1.ResourceAttributes `json:"attributes"`
+}
+
+// Allowed returns whether the user is allowed the action described by attrs.
+func (r *Reviewer) Allowed(ctx context.Context, user authenticationv1.UserInfo, attrs authorizationv1.ResourceAttributes) (bool, error) {
+	var key string
+	if r.ttl > 0 {
+		// JSON sorts map keys, so equal users and attributes give equal keys.
+		raw, err := json.Marshal(reviewKey{User: user, Attributes: attrs})
+		if err != nil {
+			return false, fmt.Errorf("failed to build cache key: %w", err)
+		}
+		key = string(raw)
+		if cached, ok := r.cache.Get(key); ok {
+			return cached.(bool), nil
+		}
+	}
+
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, reviewTimeout)
+	defer cancel()
+	review, err := r.client.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:               user.Username,
+			UID:                user.UID,
+			Groups:             user.Groups,
+			Extra:              extra,
+			ResourceAttributes: &attrs,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to create subject access review: %w", err)
+	}
+	if review.Status.EvaluationError != "" && !review.Status.Allowed {
+		// Authorizers could not decide; do not cache so the next request retries.
+		return false, fmt.Errorf("subject access review failed: %s", review.Status.EvaluationError)
+	}
+
+	if r.ttl > 0 {
+		r.cache.Add(key, review.Status.Allowed, r.ttl)
+	}
+	return review.Status.Allowed, nil
+}
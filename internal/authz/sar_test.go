@@ -0,0 +1,133 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newFakeClient returns a client whose SubjectAccessReviews allow users in
+// group "admins" and record the reviews issued.
+func newFakeClient(reviews *[]authorizationv1.SubjectAccessReviewSpec) *fake.Clientset {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		*reviews = append(*reviews, review.Spec)
+		switch review.Spec.User {
+		case "broken":
+			return true, nil, fmt.Errorf("connection refused")
+		case "undecided":
+			review.Status.EvaluationError = "webhook authorizer unavailable"
+			return true, review, nil
+		}
+		for _, group := range review.Spec.Groups {
+			if group == "admins" {
+				review.Status.Allowed = true
+			}
+		}
+		return true, review, nil
+	})
+	return client
+}
+
+var bypass = authorizationv1.ResourceAttributes{
+	Namespace: "ns",
+	Verb:      "bypass",
+	Group:     "policy.example.com",
+	Resource:  "podpolicies",
+}
+
+func TestReviewer_Allowed(t *testing.T) {
+	var reviews []authorizationv1.SubjectAccessReviewSpec
+	reviewer := New(newFakeClient(&reviews), Config{})
+
+	admin := authenticationv1.UserInfo{
+		Username: "alice",
+		UID:      "1",
+		Groups:   []string{"admins"},
+		Extra:    map[string]authenticationv1.ExtraValue{"scopes": {"a"}},
+	}
+	tests := []struct {
+		name    string
+		user    authenticationv1.UserInfo
+		want    bool
+		wantErr bool
+	}{
+		{name: "allowed", user: admin, want: true},
+		{name: "denied", user: authenticationv1.UserInfo{Username: "bob"}},
+		{name: "review error", user: authenticationv1.UserInfo{Username: "broken"}, wantErr: true},
+		{name: "evaluation error", user: authenticationv1.UserInfo{Username: "undecided"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, err := reviewer.Allowed(context.Background(), tt.user, bypass)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Allowed: got error %v, want error %v", err, tt.wantErr)
+			}
+			if allowed != tt.want {
+				t.Errorf("Allowed: got %v, want %v", allowed, tt.want)
+			}
+		})
+	}
+
+	spec := reviews[0]
+	if spec.User != "alice" || spec.UID != "1" || len(spec.Groups) != 1 || len(spec.Extra["scopes"]) != 1 {
+		t.Errorf("Unexpected review user: %+v", spec)
+	}
+	if spec.ResourceAttributes == nil || *spec.ResourceAttributes != bypass {
+		t.Errorf("ResourceAttributes: got %+v, want %+v", spec.ResourceAttributes, bypass)
+	}
+}
+
+func TestReviewer_Cache(t *testing.T) {
+	t.Run("results are cached per user and attributes", func(t *testing.T) {
+		var reviews []authorizationv1.SubjectAccessReviewSpec
+		reviewer := New(newFakeClient(&reviews), Config{})
+		user := authenticationv1.UserInfo{Username: "alice", Groups: []string{"admins"}}
+
+		for i := 0; i < 3; i++ {
+			if allowed, _ := reviewer.Allowed(context.Background(), user, bypass); !allowed {
+				t.Fatal("Expected user to be allowed")
+			}
+		}
+		other := bypass
+		other.Namespace = "other"
+		reviewer.Allowed(context.Background(), user, other)
+
+		if len(reviews) != 2 {
+			t.Errorf("Reviews: got %d, want 2", len(reviews))
+		}
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		var reviews []authorizationv1.SubjectAccessReviewSpec
+		reviewer := New(newFakeClient(&reviews), Config{})
+		user := authenticationv1.UserInfo{Username: "undecided"}
+
+		for i := 0; i < 2; i++ {
+			reviewer.Allowed(context.Background(), user, bypass)
+		}
+		if len(reviews) != 2 {
+			t.Errorf("Reviews: got %d, want 2", len(reviews))
+		}
+	})
+
+	t.Run("caching disabled", func(t *testing.T) {
+		var reviews []authorizationv1.SubjectAccessReviewSpec
+		reviewer := New(newFakeClient(&reviews), Config{CacheTTL: -1})
+		user := authenticationv1.UserInfo{Username: "alice"}
+
+		for i := 0; i < 2; i++ {
+			reviewer.Allowed(context.Background(), user, bypass)
+		}
+		if len(reviews) != 2 {
+			t.Errorf("Reviews: got %d, want 2", len(reviews))
+		}
+	})
+}
@@ -1,18 +1,34 @@
 package cabundle
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/library-go/pkg/operator/events"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
+
+	"github.com/jimyag/auto-cert-webhook/internal/metrics"
 )
 
 // WebhookType represents the type of webhook.
@@ -25,12 +41,51 @@ const (
 	MutatingWebhook WebhookType = "mutating"
 )
 
+// Annotations record the last CA bundle injection on a webhook configuration,
+// to tell who wrote its caBundle and when.
+const (
+	// InjectedByAnnotation is the identity of the pod that injected the CA
+	// bundle.
+	InjectedByAnnotation = "auto-cert-webhook.jimyag.io/injected-by"
+	// CABundleHashAnnotation is the SHA-256 hash of the injected CA bundle.
+	CABundleHashAnnotation = "auto-cert-webhook.jimyag.io/ca-bundle-hash"
+	// InjectedAtAnnotation is the time of the injection, in RFC 3339 format.
+	InjectedAtAnnotation = "auto-cert-webhook.jimyag.io/injected-at"
+)
+
+const (
+	// syncTriggerEvent marks a sync caused by a ConfigMap change.
+	syncTriggerEvent = "event"
+	// syncTriggerForced marks a sync performed at startup or by the periodic resync.
+	syncTriggerForced = "forced"
+)
+
+// caBundleSizeWarning is the total size of the caBundle fields of a webhook
+// configuration above which injections warn: a third of etcd's default 1.5MiB
+// request limit, as every webhook carries its own base64-encoded copy.
+const caBundleSizeWarning = 512 * 1024
+
+// defaultConflictBackoff bounds the retries of a webhook configuration write
+// that conflicts with a concurrent change, e.g. by another controller bumping
+// its resourceVersion: five attempts over about 1.5s.
+var defaultConflictBackoff = wait.Backoff{
+	Steps:    5,
+	Duration: 100 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.1,
+}
+
 // WebhookRef references a webhook configuration to update.
 type WebhookRef struct {
 	// Name is the name of the webhook configuration.
 	Name string
 	// Type is the type of webhook (validating or mutating).
 	Type WebhookType
+	// Entries describes the webhooks the configuration should contain.
+	// If non-nil, the syncer creates or updates the whole configuration instead
+	// of only patching caBundle on an existing one; an empty slice leaves the
+	// configuration without webhooks.
+	Entries []WebhookEntry
 }
 
 // Syncer synchronizes CA bundle to webhook configurations.
@@ -38,7 +93,30 @@ type Syncer struct {
 	client                kubernetes.Interface
 	namespace             string
 	caBundleConfigMapName string
+	caBundleKey           string
 	webhookRefs           []WebhookRef
+	resyncInterval        time.Duration
+
+	// conflictBackoff bounds the retries of conflicting writes.
+	conflictBackoff wait.Backoff
+
+	// factory is a shared informer factory for the namespace; nil means Start
+	// uses its own.
+	factory informers.SharedInformerFactory
+
+	// eventRecorder records injections and failures; nil disables events.
+	eventRecorder events.Recorder
+
+	// identity is recorded in InjectedByAnnotation.
+	identity string
+
+	// mu guards lastInjected and lastErr, as injections run from the
+	// informer and the resync loop.
+	mu sync.Mutex
+	// lastInjected is the last CA bundle injected into all configurations.
+	lastInjected string
+	// lastErr is the error of the last injection, nil if it succeeded.
+	lastErr error
 }
 
 // NewSyncer creates a new CA bundle syncer.
@@ -47,10 +125,45 @@ func NewSyncer(client kubernetes.Interface, namespace, caBundleConfigMapName str
 		client:                client,
 		namespace:             namespace,
 		caBundleConfigMapName: caBundleConfigMapName,
+		caBundleKey:           "ca-bundle.crt",
 		webhookRefs:           webhookRefs,
+		conflictBackoff:       defaultConflictBackoff,
 	}
 }
 
+// SetCABundleKey sets the key of the CA bundle in the ConfigMap, by default
+// ca-bundle.crt.
+func (s *Syncer) SetCABundleKey(key string) {
+	s.caBundleKey = key
+}
+
+// SetResyncInterval sets the interval at which the CA bundle is re-injected into
+// the webhook configurations even without ConfigMap changes, as a backstop for
+// missed events or manual edits. Zero or a negative value disables it.
+func (s *Syncer) SetResyncInterval(interval time.Duration) {
+	s.resyncInterval = interval
+}
+
+// SetEventRecorder makes the syncer emit an event when a new CA bundle is
+// injected and a warning when an injection fails.
+func (s *Syncer) SetEventRecorder(recorder events.Recorder) {
+	s.eventRecorder = recorder
+}
+
+// SetIdentity sets the identity of this instance recorded in
+// InjectedByAnnotation, e.g. the pod name.
+func (s *Syncer) SetIdentity(identity string) {
+	s.identity = identity
+}
+
+// SetInformerFactory makes the syncer watch the CA bundle configmap through a
+// shared informer factory for its namespace. The caller starts the factory.
+func (s *Syncer) SetInformerFactory(factory informers.SharedInformerFactory) {
+	s.factory = factory
+	// Request the informer now so that it starts with the factory.
+	factory.Core().V1().ConfigMaps().Informer()
+}
+
 // Start starts watching the CA bundle configmap and syncing to webhook configurations.
 func (s *Syncer) Start(ctx context.Context) error {
 	// Try to sync initially
@@ -59,15 +172,18 @@ func (s *Syncer) Start(ctx context.Context) error {
 	}
 
 	// Set up informer to watch for changes
-	factory := informers.NewSharedInformerFactoryWithOptions(
-		s.client,
-		0,
-		informers.WithNamespace(s.namespace),
-	)
+	factory := s.factory
+	if factory == nil {
+		factory = informers.NewSharedInformerFactoryWithOptions(
+			s.client,
+			0,
+			informers.WithNamespace(s.namespace),
+		)
+	}
 
 	cmInformer := factory.Core().V1().ConfigMaps().Informer()
 
-	_, err := cmInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+	registration, err := cmInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			cm, ok := obj.(*corev1.ConfigMap)
 			if !ok {
@@ -92,8 +208,17 @@ func (s *Syncer) Start(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to add event handler: %w", err)
 	}
+	// The handler must not outlive a leadership term of a shared informer.
+	defer func() {
+		_ = cmInformer.RemoveEventHandler(registration)
+	}()
 
-	factory.Start(ctx.Done())
+	if s.factory == nil {
+		_ = cmInformer.SetWatchErrorHandlerWithContext(metrics.WatchErrorHandler("configmaps"))
+		factory.Start(ctx.Done())
+		// Wait for the informer goroutines to exit before returning.
+		defer factory.Shutdown()
+	}
 
 	if !cache.WaitForCacheSync(ctx.Done(), cmInformer.HasSynced) {
 		return fmt.Errorf("failed to sync informer cache")
@@ -101,8 +226,25 @@ func (s *Syncer) Start(ctx context.Context) error {
 
 	klog.Infof("CA bundle syncer started watching configmap %s/%s", s.namespace, s.caBundleConfigMapName)
 
-	<-ctx.Done()
-	return nil
+	if s.resyncInterval <= 0 {
+		<-ctx.Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(s.resyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			klog.V(2).Infof("Forcing CA bundle re-injection from configmap %s/%s", s.namespace, s.caBundleConfigMapName)
+			if err := s.syncCABundle(ctx); err != nil {
+				klog.Errorf("Forced CA bundle sync failed: %v", err)
+			}
+		}
+	}
 }
 
 // syncCABundle syncs the CA bundle to all webhook configurations.
@@ -116,29 +258,95 @@ func (s *Syncer) syncCABundle(ctx context.Context) error {
 		return err
 	}
 
-	s.onConfigMapUpdate(ctx, cm)
+	s.injectCABundle(ctx, cm, syncTriggerForced)
 	return nil
 }
 
 // onConfigMapUpdate handles configmap updates.
 func (s *Syncer) onConfigMapUpdate(ctx context.Context, cm *corev1.ConfigMap) {
-	caBundle, ok := cm.Data["ca-bundle.crt"]
+	s.injectCABundle(ctx, cm, syncTriggerEvent)
+}
+
+// injectCABundle patches the CA bundle from the configmap into all webhook configurations.
+func (s *Syncer) injectCABundle(ctx context.Context, cm *corev1.ConfigMap, trigger string) {
+	caBundle, ok := cm.Data[s.caBundleKey]
 	if !ok || len(caBundle) == 0 {
-		klog.V(4).Infof("ConfigMap %s/%s has no ca-bundle.crt data yet", s.namespace, s.caBundleConfigMapName)
+		klog.V(4).Infof("ConfigMap %s/%s has no %s data yet", s.namespace, s.caBundleConfigMapName, s.caBundleKey)
 		return
 	}
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
 	for _, ref := range s.webhookRefs {
-		if err := s.patchWebhook(ctx, ref, []byte(caBundle)); err != nil {
+		if err := s.patchWebhookWithRetry(ctx, ref, []byte(caBundle)); err != nil {
 			klog.Errorf("Failed to patch webhook %s (%s): %v", ref.Name, ref.Type, err)
+			errs = append(errs, fmt.Errorf("%s webhook configuration %s: %w", ref.Type, ref.Name, err))
+			if s.eventRecorder != nil {
+				reason := "CABundleInjectionFailed"
+				if errors.IsConflict(err) {
+					reason = "CABundleInjectionConflict"
+				}
+				s.eventRecorder.Warningf(reason, "Failed to inject CA bundle into %s webhook configuration %s: %v", ref.Type, ref.Name, err)
+			}
 		} else {
 			klog.Infof("Updated CA bundle for webhook %s (%s)", ref.Name, ref.Type)
 		}
 	}
+	s.lastErr = stderrors.Join(errs...)
+	failed := s.lastErr != nil
+	metrics.RecordCABundleSync(trigger, !failed)
+
+	// Resyncs inject the same bundle again; only record changes
+	if failed {
+		s.lastInjected = ""
+	} else if caBundle != s.lastInjected {
+		s.lastInjected = caBundle
+		if s.eventRecorder != nil {
+			s.eventRecorder.Eventf("CABundleInjected", "Injected CA bundle from ConfigMap %s/%s into %d webhook configuration(s)", s.namespace, s.caBundleConfigMapName, len(s.webhookRefs))
+		}
+	}
+}
+
+// Status returns the CA bundle last injected into all webhook configurations,
+// empty if none or if the last injection failed, and the error of the last
+// injection.
+func (s *Syncer) Status() (injected string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastInjected, s.lastErr
+}
+
+// patchWebhookWithRetry patches a webhook configuration, reading it again
+// and retrying while the write conflicts with a concurrent change, up to the
+// attempts of conflictBackoff. The returned error is a conflict if they are
+// exhausted.
+func (s *Syncer) patchWebhookWithRetry(ctx context.Context, ref WebhookRef, caBundle []byte) error {
+	attempts := 0
+	err := retry.OnError(s.conflictBackoff, errors.IsConflict, func() error {
+		if attempts > 0 {
+			klog.V(2).Infof("Retrying CA bundle injection into %s webhook configuration %s after a conflict (attempt %d)", ref.Type, ref.Name, attempts+1)
+		}
+		attempts++
+		err := s.patchWebhook(ctx, ref, caBundle)
+		if errors.IsConflict(err) && attempts < s.conflictBackoff.Steps {
+			metrics.RecordCABundleConflict(false)
+		}
+		return err
+	})
+	if errors.IsConflict(err) {
+		metrics.RecordCABundleConflict(true)
+	}
+	return err
 }
 
 // patchWebhook patches the caBundle field of a webhook configuration.
 func (s *Syncer) patchWebhook(ctx context.Context, ref WebhookRef, caBundle []byte) error {
+	if ref.Entries != nil {
+		return s.applyWebhook(ctx, ref, caBundle)
+	}
+
 	switch ref.Type {
 	case ValidatingWebhook:
 		return s.patchValidatingWebhook(ctx, ref.Name, caBundle)
@@ -149,9 +357,16 @@ func (s *Syncer) patchWebhook(ctx context.Context, ref WebhookRef, caBundle []by
 	}
 }
 
-// buildCABundlePatch builds a JSON patch for updating caBundle on all webhooks.
-func buildCABundlePatch(webhookCount int, caBundle []byte) ([]byte, error) {
-	var patches []map[string]interface{}
+// buildCABundlePatch builds a JSON patch for updating caBundle on all webhooks
+// and setting the given annotations. The patch carries resourceVersion, so
+// that it conflicts instead of patching the wrong webhooks when the
+// configuration changed since it was read.
+func buildCABundlePatch(resourceVersion string, webhookCount int, caBundle []byte, current, annotations map[string]string) ([]byte, error) {
+	patches := []map[string]interface{}{{
+		"op":    "add",
+		"path":  "/metadata/resourceVersion",
+		"value": resourceVersion,
+	}}
 	for i := 0; i < webhookCount; i++ {
 		patches = append(patches, map[string]interface{}{
 			"op":    "replace",
@@ -159,9 +374,69 @@ func buildCABundlePatch(webhookCount int, caBundle []byte) ([]byte, error) {
 			"value": caBundle,
 		})
 	}
+	if current == nil {
+		patches = append(patches, map[string]interface{}{
+			"op":    "add",
+			"path":  "/metadata/annotations",
+			"value": annotations,
+		})
+	} else {
+		for _, key := range slices.Sorted(maps.Keys(annotations)) {
+			patches = append(patches, map[string]interface{}{
+				"op":    "add",
+				"path":  "/metadata/annotations/" + strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1"),
+				"value": annotations[key],
+			})
+		}
+	}
 	return json.Marshal(patches)
 }
 
+// injectionAnnotations returns the annotations recording an injection of
+// caBundle by this instance.
+func (s *Syncer) injectionAnnotations(caBundle []byte) map[string]string {
+	return map[string]string{
+		InjectedByAnnotation:   s.identity,
+		CABundleHashAnnotation: caBundleHash(caBundle),
+		InjectedAtAnnotation:   time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+// caBundleHash returns the hex-encoded SHA-256 hash of a CA bundle.
+func caBundleHash(caBundle []byte) string {
+	sum := sha256.Sum256(caBundle)
+	return hex.EncodeToString(sum[:])
+}
+
+// checkSize warns when the caBundle fields of a webhook configuration with the
+// given number of webhooks approach the object size limit, e.g. because old
+// CAs accumulate in the bundle.
+func (s *Syncer) checkSize(webhookType WebhookType, name string, webhooks int, caBundle []byte) {
+	size := base64.StdEncoding.EncodedLen(len(caBundle)) * webhooks
+	if size < caBundleSizeWarning {
+		return
+	}
+	klog.Warningf("CA bundle takes %d bytes in %s webhook configuration %s (%d webhooks); prune the CA bundle to stay below the object size limit", size, webhookType, name, webhooks)
+	if s.eventRecorder != nil {
+		s.eventRecorder.Warningf("CABundleTooLarge", "CA bundle takes %d bytes in %s webhook configuration %s", size, webhookType, name)
+	}
+}
+
+// injected reports whether a webhook configuration already records the
+// injection of caBundle into all of its webhooks, in which case a resync does
+// not write it again.
+func injected(annotations map[string]string, caBundles [][]byte, caBundle []byte) bool {
+	if annotations[CABundleHashAnnotation] != caBundleHash(caBundle) {
+		return false
+	}
+	for _, b := range caBundles {
+		if !bytes.Equal(b, caBundle) {
+			return false
+		}
+	}
+	return true
+}
+
 // patchValidatingWebhook patches a ValidatingWebhookConfiguration.
 func (s *Syncer) patchValidatingWebhook(ctx context.Context, name string, caBundle []byte) error {
 	current, err := s.client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, name, metav1.GetOptions{})
@@ -173,7 +448,17 @@ func (s *Syncer) patchValidatingWebhook(ctx context.Context, name string, caBund
 		return err
 	}
 
-	patchBytes, err := buildCABundlePatch(len(current.Webhooks), caBundle)
+	caBundles := make([][]byte, len(current.Webhooks))
+	for i, wh := range current.Webhooks {
+		caBundles[i] = wh.ClientConfig.CABundle
+	}
+	if injected(current.Annotations, caBundles, caBundle) {
+		klog.V(4).Infof("ValidatingWebhookConfiguration %s is up to date", name)
+		return nil
+	}
+	s.checkSize(ValidatingWebhook, name, len(current.Webhooks), caBundle)
+
+	patchBytes, err := buildCABundlePatch(current.ResourceVersion, len(current.Webhooks), caBundle, current.Annotations, s.injectionAnnotations(caBundle))
 	if err != nil {
 		return fmt.Errorf("failed to marshal patch: %w", err)
 	}
@@ -194,7 +479,17 @@ func (s *Syncer) patchMutatingWebhook(ctx context.Context, name string, caBundle
 		return err
 	}
 
-	patchBytes, err := buildCABundlePatch(len(current.Webhooks), caBundle)
+	caBundles := make([][]byte, len(current.Webhooks))
+	for i, wh := range current.Webhooks {
+		caBundles[i] = wh.ClientConfig.CABundle
+	}
+	if injected(current.Annotations, caBundles, caBundle) {
+		klog.V(4).Infof("MutatingWebhookConfiguration %s is up to date", name)
+		return nil
+	}
+	s.checkSize(MutatingWebhook, name, len(current.Webhooks), caBundle)
+
+	patchBytes, err := buildCABundlePatch(current.ResourceVersion, len(current.Webhooks), caBundle, current.Annotations, s.injectionAnnotations(caBundle))
 	if err != nil {
 		return fmt.Errorf("failed to marshal patch: %w", err)
 	}
@@ -203,4 +498,3 @@ func (s *Syncer) patchMutatingWebhook(ctx context.Context, name string, caBundle
 		ctx, name, types.JSONPatchType, patchBytes, metav1.PatchOptions{})
 	return err
 }
-
@@ -2,12 +2,22 @@ package cabundle
 
 import (
 	"context"
+	"errors"
+	"reflect"
 	"testing"
+	"time"
+
+	"github.com/openshift/library-go/pkg/operator/events"
 
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/utils/clock"
 )
 
 func TestSyncer_syncCABundle_NotFound(t *testing.T) {
@@ -119,6 +129,44 @@ func TestSyncer_patchValidatingWebhook(t *testing.T) {
 	}
 }
 
+func TestSyncer_patchWebhook_Annotations(t *testing.T) {
+	client := fake.NewSimpleClientset(&admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-webhook", Annotations: map[string]string{"owner": "me"}},
+		Webhooks:   []admissionregistrationv1.ValidatingWebhook{{Name: "test.webhook.svc"}},
+	})
+	syncer := NewSyncer(client, "test-ns", "ca-bundle", nil)
+	syncer.SetIdentity("pod-a")
+	ctx := context.Background()
+
+	if err := syncer.patchValidatingWebhook(ctx, "test-webhook", []byte("ca")); err != nil {
+		t.Fatalf("patchValidatingWebhook failed: %v", err)
+	}
+	updated, _ := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, "test-webhook", metav1.GetOptions{})
+	if got := updated.Annotations[InjectedByAnnotation]; got != "pod-a" {
+		t.Errorf("%s: got %q, want pod-a", InjectedByAnnotation, got)
+	}
+	if got := updated.Annotations[CABundleHashAnnotation]; got != caBundleHash([]byte("ca")) {
+		t.Errorf("%s: got %q", CABundleHashAnnotation, got)
+	}
+	if _, err := time.Parse(time.RFC3339, updated.Annotations[InjectedAtAnnotation]); err != nil {
+		t.Errorf("%s: %v", InjectedAtAnnotation, err)
+	}
+	if updated.Annotations["owner"] != "me" {
+		t.Errorf("Expected other annotations to be kept, got %v", updated.Annotations)
+	}
+
+	// Injecting the same bundle again does not write the configuration
+	client.ClearActions()
+	if err := syncer.patchValidatingWebhook(ctx, "test-webhook", []byte("ca")); err != nil {
+		t.Fatalf("patchValidatingWebhook failed: %v", err)
+	}
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "patch" {
+			t.Errorf("Unexpected patch of an up-to-date configuration")
+		}
+	}
+}
+
 func TestSyncer_patchMutatingWebhook(t *testing.T) {
 	webhookConfig := &admissionregistrationv1.MutatingWebhookConfiguration{
 		ObjectMeta: metav1.ObjectMeta{
@@ -467,3 +515,228 @@ func TestSyncer_MissingCABundleKey(t *testing.T) {
 			string(updated.Webhooks[0].ClientConfig.CABundle), "original-ca")
 	}
 }
+
+func TestSyncer_ForcedResync(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "ca-bundle",
+			Namespace: "test-ns",
+		},
+		Data: map[string]string{
+			"ca-bundle.crt": "test-ca-bundle-data",
+		},
+	}
+	webhookConfig := &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-webhook",
+		},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{
+			{Name: "test.webhook.svc"},
+		},
+	}
+
+	client := fake.NewSimpleClientset(cm, webhookConfig)
+	syncer := NewSyncer(client, "test-ns", "ca-bundle", []WebhookRef{
+		{Name: "test-webhook", Type: ValidatingWebhook},
+	})
+	syncer.SetResyncInterval(50 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- syncer.Start(ctx)
+	}()
+
+	getCABundle := func() string {
+		current, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, "test-webhook", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get webhook: %v", err)
+		}
+		return string(current.Webhooks[0].ClientConfig.CABundle)
+	}
+
+	waitFor := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			if getCABundle() == want {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("CABundle: got %q, want %q", getCABundle(), want)
+	}
+
+	waitFor("test-ca-bundle-data")
+
+	// Simulate a manual edit that is not reflected by any ConfigMap event.
+	tampered, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, "test-webhook", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get webhook: %v", err)
+	}
+	tampered.Webhooks[0].ClientConfig.CABundle = []byte("tampered")
+	if _, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Update(ctx, tampered, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Failed to update webhook: %v", err)
+	}
+
+	waitFor("test-ca-bundle-data")
+
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Errorf("Start returned error: %v", err)
+	}
+}
+
+func TestSyncer_ForcedResyncDisabled(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "ca-bundle", Namespace: "test-ns"},
+		Data:       map[string]string{"ca-bundle.crt": "test-ca-bundle-data"},
+	}
+	client := fake.NewSimpleClientset(cm, &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-webhook"},
+		Webhooks:   []admissionregistrationv1.ValidatingWebhook{{Name: "test.webhook.svc"}},
+	})
+	syncer := NewSyncer(client, "test-ns", "ca-bundle", []WebhookRef{
+		{Name: "test-webhook", Type: ValidatingWebhook},
+	})
+	// A negative interval, e.g. ACW_CA_BUNDLE_RESYNC_INTERVAL="-1s", disables resyncs
+	syncer.SetResyncInterval(-time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- syncer.Start(ctx)
+	}()
+
+	getCABundle := func() string {
+		current, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, "test-webhook", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get webhook: %v", err)
+		}
+		return string(current.Webhooks[0].ClientConfig.CABundle)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for getCABundle() != "test-ca-bundle-data" {
+		if time.Now().After(deadline) {
+			t.Fatalf("CABundle: got %q, want %q", getCABundle(), "test-ca-bundle-data")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	tampered, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, "test-webhook", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get webhook: %v", err)
+	}
+	tampered.Webhooks[0].ClientConfig.CABundle = []byte("tampered")
+	if _, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Update(ctx, tampered, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Failed to update webhook: %v", err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if got := getCABundle(); got != "tampered" {
+		t.Errorf("CABundle: got %q, want the manual edit to be kept without resyncs", got)
+	}
+
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Errorf("Start returned error: %v", err)
+	}
+}
+
+func TestSyncer_Events(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "ca-bundle", Namespace: "test-ns"},
+		Data:       map[string]string{"ca-bundle.crt": "ca-1"},
+	}
+	webhookConfig := &admissionregistrationv1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-webhook"},
+		Webhooks:   []admissionregistrationv1.MutatingWebhook{{Name: "test.webhook.svc"}},
+	}
+	client := fake.NewSimpleClientset(cm, webhookConfig)
+	recorder := events.NewInMemoryRecorder("test", clock.RealClock{})
+
+	syncer := NewSyncer(client, "test-ns", "ca-bundle", []WebhookRef{
+		{Name: "test-webhook", Type: MutatingWebhook},
+		{Name: "test-webhook", Type: "unknown"},
+	})
+	syncer.SetEventRecorder(recorder)
+	ctx := context.Background()
+
+	syncer.injectCABundle(ctx, cm, syncTriggerForced)
+	if got := eventReasons(recorder); !reflect.DeepEqual(got, []string{"CABundleInjectionFailed"}) {
+		t.Errorf("After failure: got events %v", got)
+	}
+
+	syncer.webhookRefs = syncer.webhookRefs[:1]
+	syncer.injectCABundle(ctx, cm, syncTriggerForced)
+	// A resync of the same bundle is not recorded again
+	syncer.injectCABundle(ctx, cm, syncTriggerForced)
+	cm.Data["ca-bundle.crt"] = "ca-2"
+	syncer.injectCABundle(ctx, cm, syncTriggerEvent)
+
+	want := []string{"CABundleInjectionFailed", "CABundleInjected", "CABundleInjected"}
+	if got := eventReasons(recorder); !reflect.DeepEqual(got, want) {
+		t.Errorf("Events: got %v, want %v", got, want)
+	}
+}
+
+func TestSyncer_patchWebhook_Conflict(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "ca-bundle", Namespace: "test-ns"},
+		Data:       map[string]string{"ca-bundle.crt": "ca-1"},
+	}
+	client := fake.NewSimpleClientset(cm, &admissionregistrationv1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-webhook", ResourceVersion: "1"},
+		Webhooks:   []admissionregistrationv1.MutatingWebhook{{Name: "test.webhook.svc"}},
+	})
+	// A concurrent controller changes the configuration before the first two patches
+	conflicts := 2
+	patches := 0
+	client.PrependReactor("patch", "mutatingwebhookconfigurations", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		patches++
+		if conflicts != 0 {
+			conflicts--
+			return true, nil, apierrors.NewConflict(admissionregistrationv1.Resource("mutatingwebhookconfigurations"), "test-webhook", errors.New("object has been modified"))
+		}
+		return false, nil, nil
+	})
+	recorder := events.NewInMemoryRecorder("test", clock.RealClock{})
+	syncer := NewSyncer(client, "test-ns", "ca-bundle", []WebhookRef{{Name: "test-webhook", Type: MutatingWebhook}})
+	syncer.SetEventRecorder(recorder)
+	syncer.conflictBackoff = wait.Backoff{Steps: 3, Duration: time.Millisecond}
+	ctx := context.Background()
+
+	syncer.injectCABundle(ctx, cm, syncTriggerForced)
+	if patches != 3 {
+		t.Errorf("Expected 3 patches, got %d", patches)
+	}
+	updated, _ := client.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, "test-webhook", metav1.GetOptions{})
+	if string(updated.Webhooks[0].ClientConfig.CABundle) != "ca-1" {
+		t.Errorf("CABundle not updated after conflicts: got %q", updated.Webhooks[0].ClientConfig.CABundle)
+	}
+
+	// Persistent conflicts exhaust the attempts and are reported
+	conflicts = -1
+	patches = 0
+	cm.Data["ca-bundle.crt"] = "ca-2"
+	syncer.injectCABundle(ctx, cm, syncTriggerForced)
+	if patches != 3 {
+		t.Errorf("Expected 3 patches, got %d", patches)
+	}
+	want := []string{"CABundleInjected", "CABundleInjectionConflict"}
+	if got := eventReasons(recorder); !reflect.DeepEqual(got, want) {
+		t.Errorf("Events: got %v, want %v", got, want)
+	}
+}
+
+// eventReasons returns the reasons of the recorded events.
+func eventReasons(recorder events.InMemoryRecorder) []string {
+	var reasons []string
+	for _, event := range recorder.Events() {
+		reasons = append(reasons, event.Reason)
+	}
+	return reasons
+}
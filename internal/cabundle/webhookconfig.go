@@ -0,0 +1,311 @@
+package cabundle
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	// defaultTimeoutSeconds matches the API server default for webhook timeouts.
+	defaultTimeoutSeconds int32 = 10
+)
+
+// WebhookEntry describes a single webhook entry of a managed webhook configuration.
+type WebhookEntry struct {
+	// Name is the unique, fully qualified name of the webhook entry.
+	Name string
+
+	// ServiceName is the name of the Service the API server calls.
+	ServiceName string
+
+	// ServiceNamespace is the namespace of the Service.
+	ServiceNamespace string
+
+	// ServicePort is the port of the Service.
+	ServicePort int32
+
+	// ServicePath is the URL path the API server calls.
+	ServicePath string
+
+	// Rules describes which operations on which resources the webhook handles.
+	Rules []admissionregistrationv1.RuleWithOperations
+
+	// FailurePolicy defines how errors calling the webhook are handled.
+	// If nil, defaults to Fail.
+	FailurePolicy *admissionregistrationv1.FailurePolicyType
+
+	// SideEffects states whether the webhook has side effects.
+	// If nil, defaults to None.
+	SideEffects *admissionregistrationv1.SideEffectClass
+
+	// TimeoutSeconds is the timeout for calling the webhook.
+	// If nil, defaults to 10.
+	TimeoutSeconds *int32
+
+	// NamespaceSelector limits the webhook to matching namespaces.
+	NamespaceSelector *metav1.LabelSelector
+
+	// ObjectSelector limits the webhook to matching objects.
+	ObjectSelector *metav1.LabelSelector
+
+	// ReinvocationPolicy states whether a mutating webhook is called again
+	// after later mutating webhooks changed the object. Ignored for
+	// validating webhooks. If nil, defaults to Never.
+	ReinvocationPolicy *admissionregistrationv1.ReinvocationPolicyType
+}
+
+// EntryName derives a unique, fully qualified webhook entry name from a
+// hook path, e.g. "/mutate-pods" becomes "mutate-pods.<name>.<namespace>.svc".
+func EntryName(path, name, namespace string) string {
+	prefix := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		default:
+			return '-'
+		}
+	}, strings.Trim(path, "/"))
+	prefix = strings.Trim(prefix, "-")
+	if prefix == "" {
+		prefix = "root"
+	}
+	return fmt.Sprintf("%s.%s.%s.svc", prefix, name, namespace)
+}
+
+// clientConfig builds the client config of a webhook entry.
+func (e WebhookEntry) clientConfig(caBundle []byte) admissionregistrationv1.WebhookClientConfig {
+	return admissionregistrationv1.WebhookClientConfig{
+		Service: &admissionregistrationv1.ServiceReference{
+			Name:      e.ServiceName,
+			Namespace: e.ServiceNamespace,
+			Path:      ptr.To(e.ServicePath),
+			Port:      ptr.To(e.ServicePort),
+		},
+		CABundle: caBundle,
+	}
+}
+
+// failurePolicy returns the failure policy of the entry with the API server default applied.
+func (e WebhookEntry) failurePolicy() *admissionregistrationv1.FailurePolicyType {
+	if e.FailurePolicy != nil {
+		return ptr.To(*e.FailurePolicy)
+	}
+	return ptr.To(admissionregistrationv1.Fail)
+}
+
+// sideEffects returns the side effect class of the entry, defaulting to None.
+func (e WebhookEntry) sideEffects() *admissionregistrationv1.SideEffectClass {
+	if e.SideEffects != nil {
+		return ptr.To(*e.SideEffects)
+	}
+	return ptr.To(admissionregistrationv1.SideEffectClassNone)
+}
+
+// timeoutSeconds returns the timeout of the entry with the API server default applied.
+func (e WebhookEntry) timeoutSeconds() *int32 {
+	if e.TimeoutSeconds != nil {
+		return ptr.To(*e.TimeoutSeconds)
+	}
+	return ptr.To(defaultTimeoutSeconds)
+}
+
+// reinvocationPolicy returns the reinvocation policy of the entry, defaulting to Never.
+func (e WebhookEntry) reinvocationPolicy() *admissionregistrationv1.ReinvocationPolicyType {
+	if e.ReinvocationPolicy != nil {
+		return ptr.To(*e.ReinvocationPolicy)
+	}
+	return ptr.To(admissionregistrationv1.NeverReinvocationPolicy)
+}
+
+// rules returns a copy of the rules of the entry with the API server default
+// scope "*" applied.
+func (e WebhookEntry) rules() []admissionregistrationv1.RuleWithOperations {
+	if e.Rules == nil {
+		return nil
+	}
+	rules := make([]admissionregistrationv1.RuleWithOperations, len(e.Rules))
+	for i, rule := range e.Rules {
+		rules[i] = *rule.DeepCopy()
+		if rules[i].Scope == nil {
+			rules[i].Scope = ptr.To(admissionregistrationv1.AllScopes)
+		}
+	}
+	return rules
+}
+
+// selectorOrEmpty returns a copy of the selector, or an empty selector matching everything.
+func selectorOrEmpty(selector *metav1.LabelSelector) *metav1.LabelSelector {
+	if selector == nil {
+		return &metav1.LabelSelector{}
+	}
+	return selector.DeepCopy()
+}
+
+// buildValidatingWebhooks builds the webhooks of a managed ValidatingWebhookConfiguration.
+// API server defaults are set explicitly so the result compares equal to the stored object.
+func buildValidatingWebhooks(entries []WebhookEntry, caBundle []byte) []admissionregistrationv1.ValidatingWebhook {
+	webhooks := make([]admissionregistrationv1.ValidatingWebhook, 0, len(entries))
+	for _, e := range entries {
+		webhooks = append(webhooks, admissionregistrationv1.ValidatingWebhook{
+			Name:                    e.Name,
+			ClientConfig:            e.clientConfig(caBundle),
+			Rules:                   e.rules(),
+			FailurePolicy:           e.failurePolicy(),
+			MatchPolicy:             ptr.To(admissionregistrationv1.Equivalent),
+			NamespaceSelector:       selectorOrEmpty(e.NamespaceSelector),
+			ObjectSelector:          selectorOrEmpty(e.ObjectSelector),
+			SideEffects:             e.sideEffects(),
+			TimeoutSeconds:          e.timeoutSeconds(),
+			AdmissionReviewVersions: []string{"v1"},
+		})
+	}
+	return webhooks
+}
+
+// buildMutatingWebhooks builds the webhooks of a managed MutatingWebhookConfiguration.
+// API server defaults are set explicitly so the result compares equal to the stored object.
+func buildMutatingWebhooks(entries []WebhookEntry, caBundle []byte) []admissionregistrationv1.MutatingWebhook {
+	webhooks := make([]admissionregistrationv1.MutatingWebhook, 0, len(entries))
+	for _, e := range entries {
+		webhooks = append(webhooks, admissionregistrationv1.MutatingWebhook{
+			Name:                    e.Name,
+			ClientConfig:            e.clientConfig(caBundle),
+			Rules:                   e.rules(),
+			FailurePolicy:           e.failurePolicy(),
+			MatchPolicy:             ptr.To(admissionregistrationv1.Equivalent),
+			NamespaceSelector:       selectorOrEmpty(e.NamespaceSelector),
+			ObjectSelector:          selectorOrEmpty(e.ObjectSelector),
+			SideEffects:             e.sideEffects(),
+			TimeoutSeconds:          e.timeoutSeconds(),
+			AdmissionReviewVersions: []string{"v1"},
+			ReinvocationPolicy:      e.reinvocationPolicy(),
+		})
+	}
+	return webhooks
+}
+
+// applyWebhook creates or updates a managed webhook configuration.
+func (s *Syncer) applyWebhook(ctx context.Context, ref WebhookRef, caBundle []byte) error {
+	switch ref.Type {
+	case ValidatingWebhook:
+		return s.applyValidatingWebhook(ctx, ref, caBundle)
+	case MutatingWebhook:
+		return s.applyMutatingWebhook(ctx, ref, caBundle)
+	default:
+		return fmt.Errorf("unknown webhook type: %s", ref.Type)
+	}
+}
+
+// applyValidatingWebhook creates or updates a managed ValidatingWebhookConfiguration.
+func (s *Syncer) applyValidatingWebhook(ctx context.Context, ref WebhookRef, caBundle []byte) error {
+	client := s.client.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+	desired := buildValidatingWebhooks(ref.Entries, caBundle)
+
+	current, err := client.Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		klog.Infof("Creating ValidatingWebhookConfiguration %s", ref.Name)
+		s.checkSize(ref.Type, ref.Name, len(desired), caBundle)
+		_, err = client.Create(ctx, &admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: ref.Name, Annotations: s.injectionAnnotations(caBundle)},
+			Webhooks:   desired,
+		}, metav1.CreateOptions{})
+		return err
+	}
+
+	if equality.Semantic.DeepEqual(current.Webhooks, desired) && current.Annotations[CABundleHashAnnotation] == caBundleHash(caBundle) {
+		klog.V(4).Infof("ValidatingWebhookConfiguration %s is up to date", ref.Name)
+		return nil
+	}
+	s.checkSize(ref.Type, ref.Name, len(desired), caBundle)
+
+	updated := current.DeepCopy()
+	updated.Webhooks = desired
+	if updated.Annotations == nil {
+		updated.Annotations = map[string]string{}
+	}
+	maps.Copy(updated.Annotations, s.injectionAnnotations(caBundle))
+	_, err = client.Update(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
+
+// applyMutatingWebhook creates or updates a managed MutatingWebhookConfiguration.
+func (s *Syncer) applyMutatingWebhook(ctx context.Context, ref WebhookRef, caBundle []byte) error {
+	client := s.client.AdmissionregistrationV1().MutatingWebhookConfigurations()
+	desired := buildMutatingWebhooks(ref.Entries, caBundle)
+
+	current, err := client.Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		klog.Infof("Creating MutatingWebhookConfiguration %s", ref.Name)
+		s.checkSize(ref.Type, ref.Name, len(desired), caBundle)
+		_, err = client.Create(ctx, &admissionregistrationv1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: ref.Name, Annotations: s.injectionAnnotations(caBundle)},
+			Webhooks:   desired,
+		}, metav1.CreateOptions{})
+		return err
+	}
+
+	if equality.Semantic.DeepEqual(current.Webhooks, desired) && current.Annotations[CABundleHashAnnotation] == caBundleHash(caBundle) {
+		klog.V(4).Infof("MutatingWebhookConfiguration %s is up to date", ref.Name)
+		return nil
+	}
+	s.checkSize(ref.Type, ref.Name, len(desired), caBundle)
+
+	updated := current.DeepCopy()
+	updated.Webhooks = desired
+	if updated.Annotations == nil {
+		updated.Annotations = map[string]string{}
+	}
+	maps.Copy(updated.Annotations, s.injectionAnnotations(caBundle))
+	_, err = client.Update(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
+
+// Cleanup deletes the managed webhook configurations and clears the CA bundle
+// of the others, which the user owns, so that none of them keeps trusting a CA
+// that is about to be deleted.
+func (s *Syncer) Cleanup(ctx context.Context) error {
+	for _, ref := range s.webhookRefs {
+		if ref.Entries == nil {
+			klog.Infof("Clearing the CA bundle of %s webhook configuration %s", ref.Type, ref.Name)
+			if err := s.patchWebhook(ctx, ref, nil); err != nil {
+				return fmt.Errorf("failed to clear the CA bundle of %s webhook configuration %s: %w", ref.Type, ref.Name, err)
+			}
+			continue
+		}
+
+		var err error
+		switch ref.Type {
+		case ValidatingWebhook:
+			err = s.client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Delete(ctx, ref.Name, metav1.DeleteOptions{})
+		case MutatingWebhook:
+			err = s.client.AdmissionregistrationV1().MutatingWebhookConfigurations().Delete(ctx, ref.Name, metav1.DeleteOptions{})
+		default:
+			err = fmt.Errorf("unknown webhook type: %s", ref.Type)
+		}
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to delete %s webhook configuration %s: %w", ref.Type, ref.Name, err)
+		}
+		klog.Infof("Deleted %s webhook configuration %s", ref.Type, ref.Name)
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+package cabundle
+
+import (
+	"context"
+	"testing"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+)
+
+func testEntries() []WebhookEntry {
+	return []WebhookEntry{
+		{
+			Name:             "mutate-pods.test-webhook.test-ns.svc",
+			ServiceName:      "test-webhook",
+			ServiceNamespace: "test-ns",
+			ServicePort:      443,
+			ServicePath:      "/mutate-pods",
+			Rules: []admissionregistrationv1.RuleWithOperations{
+				{
+					Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+					Rule: admissionregistrationv1.Rule{
+						APIGroups:   []string{""},
+						APIVersions: []string{"v1"},
+						Resources:   []string{"pods"},
+					},
+				},
+			},
+		},
+		{
+			Name:               "mutate-deployments.test-webhook.test-ns.svc",
+			ServiceName:        "other-svc",
+			ServiceNamespace:   "test-ns",
+			ServicePort:        9443,
+			ServicePath:        "/mutate-deployments",
+			FailurePolicy:      ptr.To(admissionregistrationv1.Ignore),
+			ReinvocationPolicy: ptr.To(admissionregistrationv1.IfNeededReinvocationPolicy),
+		},
+	}
+}
+
+func TestEntryName(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/mutate-pods", want: "mutate-pods.wh.ns.svc"},
+		{path: "/v1/Validate_Pods/", want: "v1-validate-pods.wh.ns.svc"},
+		{path: "/", want: "root.wh.ns.svc"},
+	}
+
+	for _, tt := range tests {
+		if got := EntryName(tt.path, "wh", "ns"); got != tt.want {
+			t.Errorf("EntryName(%q): got %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestBuildMutatingWebhooks(t *testing.T) {
+	webhooks := buildMutatingWebhooks(testEntries(), []byte("ca"))
+
+	if len(webhooks) != 2 {
+		t.Fatalf("Expected 2 webhooks, got %d", len(webhooks))
+	}
+
+	first := webhooks[0]
+	if first.ClientConfig.Service == nil {
+		t.Fatal("Expected service client config")
+	}
+	if first.ClientConfig.Service.Name != "test-webhook" || *first.ClientConfig.Service.Port != 443 {
+		t.Errorf("Service: got %s:%d", first.ClientConfig.Service.Name, *first.ClientConfig.Service.Port)
+	}
+	if *first.ClientConfig.Service.Path != "/mutate-pods" {
+		t.Errorf("Path: got %q, want %q", *first.ClientConfig.Service.Path, "/mutate-pods")
+	}
+	if string(first.ClientConfig.CABundle) != "ca" {
+		t.Errorf("CABundle: got %q, want %q", first.ClientConfig.CABundle, "ca")
+	}
+	if *first.FailurePolicy != admissionregistrationv1.Fail {
+		t.Errorf("FailurePolicy: got %v, want %v", *first.FailurePolicy, admissionregistrationv1.Fail)
+	}
+	if *first.SideEffects != admissionregistrationv1.SideEffectClassNone {
+		t.Errorf("SideEffects: got %v, want %v", *first.SideEffects, admissionregistrationv1.SideEffectClassNone)
+	}
+	if *first.TimeoutSeconds != defaultTimeoutSeconds {
+		t.Errorf("TimeoutSeconds: got %d, want %d", *first.TimeoutSeconds, defaultTimeoutSeconds)
+	}
+	if *first.ReinvocationPolicy != admissionregistrationv1.NeverReinvocationPolicy {
+		t.Errorf("ReinvocationPolicy: got %v, want %v", *first.ReinvocationPolicy, admissionregistrationv1.NeverReinvocationPolicy)
+	}
+
+	second := webhooks[1]
+	if second.ClientConfig.Service.Name != "other-svc" || *second.ClientConfig.Service.Port != 9443 {
+		t.Errorf("Service override: got %s:%d", second.ClientConfig.Service.Name, *second.ClientConfig.Service.Port)
+	}
+	if *second.FailurePolicy != admissionregistrationv1.Ignore {
+		t.Errorf("FailurePolicy: got %v, want %v", *second.FailurePolicy, admissionregistrationv1.Ignore)
+	}
+	if *second.ReinvocationPolicy != admissionregistrationv1.IfNeededReinvocationPolicy {
+		t.Errorf("ReinvocationPolicy: got %v, want %v", *second.ReinvocationPolicy, admissionregistrationv1.IfNeededReinvocationPolicy)
+	}
+}
+
+func TestSyncer_applyWebhook_Creates(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	syncer := NewSyncer(client, "test-ns", "ca-bundle", nil)
+	ctx := context.Background()
+
+	refs := []WebhookRef{
+		{Name: "test-webhook", Type: MutatingWebhook, Entries: testEntries()},
+		{Name: "test-webhook", Type: ValidatingWebhook, Entries: testEntries()[:1]},
+	}
+	for _, ref := range refs {
+		if err := syncer.patchWebhook(ctx, ref, []byte("ca")); err != nil {
+			t.Fatalf("patchWebhook(%s) failed: %v", ref.Type, err)
+		}
+	}
+
+	mutating, err := client.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, "test-webhook", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get mutating webhook: %v", err)
+	}
+	if len(mutating.Webhooks) != 2 {
+		t.Errorf("Expected 2 mutating webhooks, got %d", len(mutating.Webhooks))
+	}
+
+	validating, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, "test-webhook", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get validating webhook: %v", err)
+	}
+	if len(validating.Webhooks) != 1 {
+		t.Errorf("Expected 1 validating webhook, got %d", len(validating.Webhooks))
+	}
+	if got := validating.Annotations[CABundleHashAnnotation]; got != caBundleHash([]byte("ca")) {
+		t.Errorf("%s: got %q", CABundleHashAnnotation, got)
+	}
+}
+
+func TestSyncer_applyWebhook_Updates(t *testing.T) {
+	existing := &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "test-webhook",
+			Labels: map[string]string{"app": "test"},
+		},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{
+			{Name: "stale.test-webhook.test-ns.svc"},
+		},
+	}
+	client := fake.NewSimpleClientset(existing)
+	syncer := NewSyncer(client, "test-ns", "ca-bundle", nil)
+	ctx := context.Background()
+
+	ref := WebhookRef{Name: "test-webhook", Type: ValidatingWebhook, Entries: testEntries()}
+	if err := syncer.patchWebhook(ctx, ref, []byte("new-ca")); err != nil {
+		t.Fatalf("patchWebhook failed: %v", err)
+	}
+
+	updated, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, "test-webhook", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get webhook: %v", err)
+	}
+	if len(updated.Webhooks) != 2 {
+		t.Fatalf("Expected 2 webhooks, got %d", len(updated.Webhooks))
+	}
+	if updated.Webhooks[0].Name != "mutate-pods.test-webhook.test-ns.svc" {
+		t.Errorf("Stale webhook not replaced: got %q", updated.Webhooks[0].Name)
+	}
+	if string(updated.Webhooks[1].ClientConfig.CABundle) != "new-ca" {
+		t.Errorf("CABundle: got %q, want %q", updated.Webhooks[1].ClientConfig.CABundle, "new-ca")
+	}
+	if updated.Labels["app"] != "test" {
+		t.Error("Expected existing labels to be preserved")
+	}
+
+	// A second apply with the same input must not issue an update.
+	client.ClearActions()
+	if err := syncer.patchWebhook(ctx, ref, []byte("new-ca")); err != nil {
+		t.Fatalf("patchWebhook failed: %v", err)
+	}
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "update" {
+			t.Error("Expected no update for an up-to-date configuration")
+		}
+	}
+}
+
+func TestSyncer_applyWebhook_APIServerDefaults(t *testing.T) {
+	entries := testEntries()
+	annotations := map[string]string{CABundleHashAnnotation: caBundleHash([]byte("ca"))}
+
+	// The configurations as stored by the API server, which defaults the
+	// scope of rules to "*"
+	stored := func(e WebhookEntry) (admissionregistrationv1.WebhookClientConfig, []admissionregistrationv1.RuleWithOperations) {
+		var rules []admissionregistrationv1.RuleWithOperations
+		for _, rule := range e.Rules {
+			rule.Scope = ptr.To(admissionregistrationv1.AllScopes)
+			rules = append(rules, rule)
+		}
+		return admissionregistrationv1.WebhookClientConfig{
+			Service: &admissionregistrationv1.ServiceReference{
+				Name:      e.ServiceName,
+				Namespace: e.ServiceNamespace,
+				Path:      ptr.To(e.ServicePath),
+				Port:      ptr.To(e.ServicePort),
+			},
+			CABundle: []byte("ca"),
+		}, rules
+	}
+	validating := &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-webhook", Annotations: annotations},
+	}
+	mutating := &admissionregistrationv1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-webhook", Annotations: annotations},
+	}
+	for _, e := range entries {
+		clientConfig, rules := stored(e)
+		failurePolicy := admissionregistrationv1.Fail
+		if e.FailurePolicy != nil {
+			failurePolicy = *e.FailurePolicy
+		}
+		reinvocationPolicy := admissionregistrationv1.NeverReinvocationPolicy
+		if e.ReinvocationPolicy != nil {
+			reinvocationPolicy = *e.ReinvocationPolicy
+		}
+		validating.Webhooks = append(validating.Webhooks, admissionregistrationv1.ValidatingWebhook{
+			Name:                    e.Name,
+			ClientConfig:            clientConfig,
+			Rules:                   rules,
+			FailurePolicy:           ptr.To(failurePolicy),
+			MatchPolicy:             ptr.To(admissionregistrationv1.Equivalent),
+			NamespaceSelector:       &metav1.LabelSelector{},
+			ObjectSelector:          &metav1.LabelSelector{},
+			SideEffects:             ptr.To(admissionregistrationv1.SideEffectClassNone),
+			TimeoutSeconds:          ptr.To[int32](10),
+			AdmissionReviewVersions: []string{"v1"},
+		})
+		mutating.Webhooks = append(mutating.Webhooks, admissionregistrationv1.MutatingWebhook{
+			Name:                    e.Name,
+			ClientConfig:            clientConfig,
+			Rules:                   rules,
+			FailurePolicy:           ptr.To(failurePolicy),
+			MatchPolicy:             ptr.To(admissionregistrationv1.Equivalent),
+			NamespaceSelector:       &metav1.LabelSelector{},
+			ObjectSelector:          &metav1.LabelSelector{},
+			SideEffects:             ptr.To(admissionregistrationv1.SideEffectClassNone),
+			TimeoutSeconds:          ptr.To[int32](10),
+			AdmissionReviewVersions: []string{"v1"},
+			ReinvocationPolicy:      ptr.To(reinvocationPolicy),
+		})
+	}
+	client := fake.NewSimpleClientset(validating, mutating)
+	syncer := NewSyncer(client, "test-ns", "ca-bundle", nil)
+	ctx := context.Background()
+
+	for _, ref := range []WebhookRef{
+		{Name: "test-webhook", Type: ValidatingWebhook, Entries: entries},
+		{Name: "test-webhook", Type: MutatingWebhook, Entries: entries},
+	} {
+		if err := syncer.applyWebhook(ctx, ref, []byte("ca")); err != nil {
+			t.Fatalf("applyWebhook(%s) failed: %v", ref.Type, err)
+		}
+	}
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "update" {
+			t.Errorf("Expected no update of a configuration defaulted by the API server, got one of %s", action.GetResource().Resource)
+		}
+	}
+	if entries[0].Rules[0].Scope != nil {
+		t.Error("Expected the rules of the entry not to be modified")
+	}
+}
+
+func TestSyncer_Cleanup(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "managed"},
+			Webhooks:   []admissionregistrationv1.ValidatingWebhook{{Name: "validate.managed.test-ns.svc"}},
+		},
+		&admissionregistrationv1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "user"},
+			Webhooks: []admissionregistrationv1.MutatingWebhook{
+				{Name: "mutate.user.test-ns.svc", ClientConfig: admissionregistrationv1.WebhookClientConfig{CABundle: []byte("ca")}},
+			},
+		},
+	)
+	syncer := NewSyncer(client, "test-ns", "ca-bundle", []WebhookRef{
+		{Name: "managed", Type: ValidatingWebhook, Entries: testEntries()},
+		{Name: "user", Type: MutatingWebhook},
+		// Missing configurations are skipped
+		{Name: "missing", Type: MutatingWebhook, Entries: testEntries()},
+	})
+	ctx := context.Background()
+
+	if err := syncer.Cleanup(ctx); err != nil {
+		t.Fatalf("Cleanup() error = %v", err)
+	}
+
+	if _, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, "managed", metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("Expected the managed configuration to be deleted, got %v", err)
+	}
+	user, err := client.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, "user", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected the configuration of the user to be kept: %v", err)
+	}
+	if len(user.Webhooks[0].ClientConfig.CABundle) != 0 {
+		t.Errorf("Expected the CA bundle to be cleared, got %q", user.Webhooks[0].ClientConfig.CABundle)
+	}
+}
@@ -0,0 +1,216 @@
+// Package clientca loads the CA bundle used to verify the client certificates
+// presented by the API server, from a file or a ConfigMap, and keeps it
+// up to date.
+package clientca
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	"github.com/jimyag/auto-cert-webhook/internal/metrics"
+)
+
+const (
+	// DefaultConfigMapKey is the key of the client CA in the
+	// kube-system/extension-apiserver-authentication ConfigMap.
+	DefaultConfigMapKey = "client-ca-file"
+
+	// fileReloadInterval is how often a client CA file is checked for changes.
+	fileReloadInterval = time.Minute
+)
+
+// Provider provides the client CA pool.
+type Provider struct {
+	// file source
+	path    string
+	modTime time.Time
+
+	// ConfigMap source
+	client    kubernetes.Interface
+	namespace string
+	name      string
+	key       string
+	resync    time.Duration
+
+	pool atomic.Pointer[x509.CertPool]
+}
+
+// NewFile creates a provider that loads the client CA from a PEM file and
+// reloads it when the file changes.
+func NewFile(path string) (*Provider, error) {
+	p := &Provider{path: path}
+	if err := p.loadFile(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// NewConfigMap creates a provider that loads the client CA from the given key
+// of a ConfigMap, e.g. kube-system/extension-apiserver-authentication.
+func NewConfigMap(client kubernetes.Interface, namespace, name, key string) *Provider {
+	if key == "" {
+		key = DefaultConfigMapKey
+	}
+	return &Provider{
+		client:    client,
+		namespace: namespace,
+		name:      name,
+		key:       key,
+	}
+}
+
+// SetResyncPeriod sets how often the ConfigMap informer redelivers the cached
+// ConfigMap. Zero or a negative value disables it.
+func (p *Provider) SetResyncPeriod(period time.Duration) {
+	p.resync = period
+}
+
+// ClientCAs returns the current client CA pool. Until a bundle is loaded it
+// returns an empty pool, so that no client certificate verifies; a nil pool
+// would make crypto/tls fall back to the system roots.
+func (p *Provider) ClientCAs() *x509.CertPool {
+	if pool := p.pool.Load(); pool != nil {
+		return pool
+	}
+	return x509.NewCertPool()
+}
+
+// Start keeps the client CA up to date until the context is done.
+func (p *Provider) Start(ctx context.Context) error {
+	if p.client == nil {
+		return p.watchFile(ctx)
+	}
+	return p.watchConfigMap(ctx)
+}
+
+// watchFile reloads the client CA file when its modification time changes.
+func (p *Provider) watchFile(ctx context.Context) error {
+	ticker := time.NewTicker(fileReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			info, err := os.Stat(p.path)
+			if err != nil {
+				klog.Errorf("Failed to stat client CA file %s: %v", p.path, err)
+				continue
+			}
+			if info.ModTime().Equal(p.modTime) {
+				continue
+			}
+			if err := p.loadFile(); err != nil {
+				klog.Errorf("Failed to reload client CA file, keeping the previous bundle: %v", err)
+			}
+		}
+	}
+}
+
+// loadFile loads the client CA file.
+func (p *Provider) loadFile() error {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat client CA file: %w", err)
+	}
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("failed to read client CA file: %w", err)
+	}
+	if err := p.load(data); err != nil {
+		return fmt.Errorf("client CA file %s: %w", p.path, err)
+	}
+	p.modTime = info.ModTime()
+	klog.Infof("Loaded client CA from %s", p.path)
+	return nil
+}
+
+// watchConfigMap watches the client CA ConfigMap.
+func (p *Provider) watchConfigMap(ctx context.Context) error {
+	factory := informers.NewSharedInformerFactoryWithOptions(
+		p.client,
+		max(p.resync, 0),
+		informers.WithNamespace(p.namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", p.name).String()
+		}),
+	)
+
+	configMapInformer := factory.Core().V1().ConfigMaps().Informer()
+
+	_, err := configMapInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if cm, ok := obj.(*corev1.ConfigMap); ok {
+				p.onConfigMapUpdate(cm)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			if cm, ok := newObj.(*corev1.ConfigMap); ok {
+				p.onConfigMapUpdate(cm)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			// Keep the last known bundle: failing every admission request
+			// because the ConfigMap is briefly gone is worse than trusting
+			// a CA that was valid moments ago.
+			klog.Warningf("Client CA configmap %s/%s deleted, keeping the previous bundle", p.namespace, p.name)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add event handler: %w", err)
+	}
+
+	_ = configMapInformer.SetWatchErrorHandlerWithContext(metrics.WatchErrorHandler("configmaps"))
+	factory.Start(ctx.Done())
+	// Wait for the informer goroutines to exit before returning.
+	defer factory.Shutdown()
+
+	if !cache.WaitForCacheSync(ctx.Done(), configMapInformer.HasSynced) {
+		return fmt.Errorf("failed to sync informer cache")
+	}
+
+	klog.Infof("Client CA provider started watching configmap %s/%s", p.namespace, p.name)
+
+	<-ctx.Done()
+	return nil
+}
+
+// onConfigMapUpdate handles ConfigMap updates.
+func (p *Provider) onConfigMapUpdate(cm *corev1.ConfigMap) {
+	if cm.Name != p.name {
+		return
+	}
+	data, ok := cm.Data[p.key]
+	if !ok || data == "" {
+		klog.Warningf("Client CA configmap %s/%s has no %s data", p.namespace, p.name, p.key)
+		return
+	}
+	if err := p.load([]byte(data)); err != nil {
+		klog.Errorf("Failed to load client CA from configmap %s/%s: %v", p.namespace, p.name, err)
+		return
+	}
+	klog.Infof("Loaded client CA from configmap %s/%s", p.namespace, p.name)
+}
+
+// load parses a PEM bundle and makes it the current pool.
+func (p *Provider) load(data []byte) error {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("no certificates found in PEM data")
+	}
+	p.pool.Store(pool)
+	return nil
+}
@@ -0,0 +1,138 @@
+package clientca
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestProvider_EmptyPool(t *testing.T) {
+	p := NewConfigMap(fake.NewSimpleClientset(), "kube-system", "ca", "")
+
+	if p.key != DefaultConfigMapKey {
+		t.Errorf("key: got %q, want %q", p.key, DefaultConfigMapKey)
+	}
+	// An empty, non-nil pool keeps crypto/x509 from falling back to system roots
+	if pool := p.ClientCAs(); pool == nil || !pool.Equal(x509.NewCertPool()) {
+		t.Error("Expected an empty pool before the CA is loaded")
+	}
+}
+
+func TestNewFile(t *testing.T) {
+	dir := t.TempDir()
+	caPEM, ca := generateCA(t, "ca-1")
+
+	t.Run("valid file", func(t *testing.T) {
+		path := filepath.Join(dir, "ca.crt")
+		if err := os.WriteFile(path, caPEM, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		p, err := NewFile(path)
+		if err != nil {
+			t.Fatalf("NewFile failed: %v", err)
+		}
+		want := x509.NewCertPool()
+		want.AddCert(ca)
+		if !p.ClientCAs().Equal(want) {
+			t.Error("Pool does not contain the CA")
+		}
+
+		// Reload picks up a changed file
+		newPEM, newCA := generateCA(t, "ca-2")
+		if err := os.WriteFile(path, newPEM, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.loadFile(); err != nil {
+			t.Fatalf("loadFile failed: %v", err)
+		}
+		want = x509.NewCertPool()
+		want.AddCert(newCA)
+		if !p.ClientCAs().Equal(want) {
+			t.Error("Pool was not reloaded")
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		if _, err := NewFile(filepath.Join(dir, "missing.crt")); err == nil {
+			t.Error("Expected error for missing file")
+		}
+	})
+
+	t.Run("no certificates", func(t *testing.T) {
+		path := filepath.Join(dir, "empty.crt")
+		if err := os.WriteFile(path, []byte("not a certificate"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewFile(path); err == nil {
+			t.Error("Expected error for file without certificates")
+		}
+	})
+}
+
+func TestProvider_onConfigMapUpdate(t *testing.T) {
+	caPEM, ca := generateCA(t, "ca")
+	p := NewConfigMap(fake.NewSimpleClientset(), "kube-system", "extension-apiserver-authentication", "")
+
+	// Other ConfigMaps, missing keys and invalid data are ignored
+	p.onConfigMapUpdate(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "kube-system"},
+		Data:       map[string]string{DefaultConfigMapKey: string(caPEM)},
+	})
+	p.onConfigMapUpdate(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "extension-apiserver-authentication", Namespace: "kube-system"},
+		Data:       map[string]string{DefaultConfigMapKey: "invalid"},
+	})
+	if p.pool.Load() != nil {
+		t.Fatal("Expected no pool to be loaded")
+	}
+
+	p.onConfigMapUpdate(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "extension-apiserver-authentication", Namespace: "kube-system"},
+		Data:       map[string]string{DefaultConfigMapKey: string(caPEM)},
+	})
+	want := x509.NewCertPool()
+	want.AddCert(ca)
+	if !p.ClientCAs().Equal(want) {
+		t.Error("Pool does not contain the CA")
+	}
+}
+
+// generateCA creates a self-signed CA certificate.
+func generateCA(t *testing.T, commonName string) ([]byte, *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse CA: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), cert
+}
@@ -7,11 +7,15 @@ import (
 	"os"
 	"time"
 
+	"github.com/openshift/library-go/pkg/operator/events"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/leaderelection"
 	"k8s.io/client-go/tools/leaderelection/resourcelock"
 	"k8s.io/klog/v2"
+
+	"github.com/jimyag/auto-cert-webhook/internal/metrics"
 )
 
 // Config holds leader election configuration.
@@ -30,6 +34,13 @@ type Config struct {
 
 	// RetryPeriod is the period between retries.
 	RetryPeriod time.Duration
+
+	// EventRecorder, if set, records when this instance starts and stops
+	// leading.
+	EventRecorder events.Recorder
+
+	// Identity is the holder identity of this instance. Defaults to Identity().
+	Identity string
 }
 
 // Callbacks defines the callbacks for leader election events.
@@ -46,7 +57,10 @@ type Callbacks struct {
 
 // Run runs the leader election with the given callbacks.
 func Run(ctx context.Context, client kubernetes.Interface, config Config, callbacks Callbacks) error {
-	identity := getIdentity()
+	identity := config.Identity
+	if identity == "" {
+		identity = Identity()
+	}
 
 	lock := &resourcelock.LeaseLock{
 		LeaseMeta: metav1.ObjectMeta{
@@ -59,6 +73,10 @@ func Run(ctx context.Context, client kubernetes.Interface, config Config, callba
 		},
 	}
 
+	// Acquisition is timed from the start of the campaign
+	campaignStart := time.Now()
+	var transitions transitionCounter
+
 	leaderElector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
 		Lock:            lock,
 		LeaseDuration:   config.LeaseDuration,
@@ -68,17 +86,25 @@ func Run(ctx context.Context, client kubernetes.Interface, config Config, callba
 		Callbacks: leaderelection.LeaderCallbacks{
 			OnStartedLeading: func(ctx context.Context) {
 				klog.Infof("Started leading as %s", identity)
+				metrics.ObserveLeaderAcquireDuration(time.Since(campaignStart))
+				if config.EventRecorder != nil {
+					config.EventRecorder.Eventf("LeaderElected", "%s became the leader", identity)
+				}
 				if callbacks.OnStartedLeading != nil {
 					callbacks.OnStartedLeading(ctx)
 				}
 			},
 			OnStoppedLeading: func() {
 				klog.Infof("Stopped leading as %s", identity)
+				if config.EventRecorder != nil {
+					config.EventRecorder.Eventf("LeaderLost", "%s stopped leading", identity)
+				}
 				if callbacks.OnStoppedLeading != nil {
 					callbacks.OnStoppedLeading()
 				}
 			},
 			OnNewLeader: func(currentLeader string) {
+				transitions.observe(currentLeader)
 				if currentLeader == identity {
 					return
 				}
@@ -99,8 +125,24 @@ func Run(ctx context.Context, client kubernetes.Interface, config Config, callba
 	return nil
 }
 
-// getIdentity returns the identity for this instance.
-func getIdentity() string {
+// transitionCounter counts changes of the leader, including those between
+// other instances, so that flapping leadership is visible on every instance.
+type transitionCounter struct {
+	leader string
+}
+
+// observe records the current leader, counting a transition if it changed.
+// The first leader observed is not a transition.
+func (c *transitionCounter) observe(leader string) {
+	if c.leader != "" && leader != c.leader {
+		metrics.RecordLeaderTransition()
+	}
+	c.leader = leader
+}
+
+// Identity returns the identity of this instance: the pod name from the
+// POD_NAME environment variable, or else the hostname.
+func Identity() string {
 	// Try to get pod name from environment
 	identity := os.Getenv("POD_NAME")
 	if identity != "" {
@@ -5,14 +5,19 @@ import (
 	"os"
 	"testing"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/jimyag/auto-cert-webhook/internal/metrics"
 )
 
-func TestGetIdentity(t *testing.T) {
+func TestIdentity(t *testing.T) {
 	t.Run("from POD_NAME env", func(t *testing.T) {
 		os.Setenv("POD_NAME", "test-pod-123")
 		defer os.Unsetenv("POD_NAME")
 
-		identity := getIdentity()
+		identity := Identity()
 		if identity != "test-pod-123" {
 			t.Errorf("identity: got %q, want %q", identity, "test-pod-123")
 		}
@@ -21,7 +26,7 @@ func TestGetIdentity(t *testing.T) {
 	t.Run("falls back to hostname", func(t *testing.T) {
 		os.Unsetenv("POD_NAME")
 
-		identity := getIdentity()
+		identity := Identity()
 		if identity == "" {
 			t.Error("identity should not be empty")
 		}
@@ -92,3 +97,71 @@ func TestConfig(t *testing.T) {
 		t.Errorf("LeaseDuration: got %v, want %v", config.LeaseDuration, 60*time.Second)
 	}
 }
+
+func TestTransitionCounter(t *testing.T) {
+	before := gatherValue(t, "admission_webhook_leaderelection_transitions_total")
+
+	var c transitionCounter
+	for _, leader := range []string{"pod-a", "pod-a", "pod-b", "pod-a"} {
+		c.observe(leader)
+	}
+
+	if got := gatherValue(t, "admission_webhook_leaderelection_transitions_total") - before; got != 2 {
+		t.Errorf("transitions: got %v, want 2", got)
+	}
+}
+
+func TestRun_AcquireDuration(t *testing.T) {
+	t.Setenv("POD_NAME", "pod-a")
+	before := gatherValue(t, "admission_webhook_leaderelection_acquire_duration_seconds")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	started := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(ctx, fake.NewSimpleClientset(), Config{
+			Namespace:     "ns",
+			Name:          "wh-leader",
+			LeaseDuration: 15 * time.Second,
+			RenewDeadline: 10 * time.Second,
+			RetryPeriod:   100 * time.Millisecond,
+		}, Callbacks{OnStartedLeading: func(context.Context) { close(started) }})
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected to become the leader")
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if got := gatherValue(t, "admission_webhook_leaderelection_acquire_duration_seconds") - before; got != 1 {
+		t.Errorf("acquisitions observed: got %v, want 1", got)
+	}
+}
+
+// gatherValue returns the value of a counter, or the sample count of a
+// histogram, from the default registry.
+func gatherValue(t *testing.T, name string) float64 {
+	t.Helper()
+	metrics.Register()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		m := family.GetMetric()[0]
+		if m.GetHistogram() != nil {
+			return float64(m.GetHistogram().GetSampleCount())
+		}
+		return m.GetCounter().GetValue()
+	}
+	return 0
+}
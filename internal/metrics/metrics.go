@@ -1,12 +1,18 @@
 package metrics
 
 import (
+	"context"
 	"crypto/x509"
+	"io"
 	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
 )
 
 const (
@@ -14,6 +20,24 @@ const (
 	subsystem = "certificate"
 )
 
+// certLabels are the labels of the certificate metrics. The secret and
+// webhook labels tell apart the certificates of several webhooks scraped
+// into the same job.
+var certLabels = []string{"type", "secret_namespace", "secret_name", "webhook"}
+
+// caBundleLabels are the labels of the CA bundle metrics.
+var caBundleLabels = []string{"configmap_namespace", "configmap_name", "webhook"}
+
+// CertID identifies a certificate in the certificate metrics.
+type CertID struct {
+	// Type is "ca" or "serving".
+	Type string
+
+	// Namespace and SecretName locate the secret holding the certificate.
+	Namespace  string
+	SecretName string
+}
+
 var (
 	// certExpiryTimestamp is a gauge that tracks the expiry timestamp of certificates.
 	certExpiryTimestamp = prometheus.NewGaugeVec(
@@ -23,7 +47,7 @@ var (
 			Name:      "expiry_timestamp_seconds",
 			Help:      "The expiry timestamp of the certificate in seconds since epoch.",
 		},
-		[]string{"type"}, // "ca" or "serving"
+		certLabels,
 	)
 
 	// certNotBeforeTimestamp is a gauge that tracks the not-before timestamp of certificates.
@@ -34,7 +58,7 @@ var (
 			Name:      "not_before_timestamp_seconds",
 			Help:      "The not-before timestamp of the certificate in seconds since epoch.",
 		},
-		[]string{"type"},
+		certLabels,
 	)
 
 	// certValidDurationSeconds is a gauge that tracks the total valid duration of certificates.
@@ -45,33 +69,487 @@ var (
 			Name:      "valid_duration_seconds",
 			Help:      "The total valid duration of the certificate in seconds.",
 		},
-		[]string{"type"},
+		certLabels,
+	)
+
+	// certUnexpectedRegenerationsTotal counts CAs replaced outside rotation.
+	certUnexpectedRegenerationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "unexpected_regenerations_total",
+			Help:      "The total number of CAs replaced before the previous CA was due for a refresh, e.g. because its secret was deleted.",
+		},
+		certLabels,
+	)
+
+	// caBundleSyncsTotal counts CA bundle injections into webhook configurations.
+	caBundleSyncsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cabundle",
+			Name:      "syncs_total",
+			Help:      "The total number of CA bundle injections into webhook configurations.",
+		},
+		[]string{"trigger", "result"}, // trigger: "event" or "forced"; result: "success" or "error"
+	)
+
+	// caBundleConflictsTotal counts CA bundle injections that conflicted with a
+	// concurrent change of a webhook configuration.
+	caBundleConflictsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cabundle",
+			Name:      "conflicts_total",
+			Help:      "The total number of CA bundle injections that conflicted with a concurrent change of a webhook configuration.",
+		},
+		[]string{"result"}, // "retried" or "exhausted"
+	)
+
+	// caBundleCertificates tracks the number of CAs in CA bundles.
+	caBundleCertificates = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "cabundle",
+			Name:      "certificates",
+			Help:      "The number of CA certificates in the CA bundle.",
+		},
+		caBundleLabels,
+	)
+
+	// caBundleSizeBytes tracks the size of CA bundles.
+	caBundleSizeBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "cabundle",
+			Name:      "size_bytes",
+			Help:      "The size of the PEM-encoded CA bundle in bytes.",
+		},
+		caBundleLabels,
+	)
+
+	// admissionInFlightRequests tracks admission requests currently being handled.
+	admissionInFlightRequests = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "admission",
+			Name:      "in_flight_requests",
+			Help:      "The number of admission requests currently being handled.",
+		},
+		[]string{"path"},
+	)
+
+	// admissionRejectedTotal counts admission requests that were not evaluated
+	// because the server was overloaded or the client was not authenticated.
+	admissionRejectedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "admission",
+			Name:      "rejected_requests_total",
+			Help:      "The total number of admission requests that were not evaluated because the server was overloaded or the client was not authenticated.",
+		},
+		[]string{"path", "reason"},
+	)
+
+	// admissionDurationSeconds observes how long admit functions take. Samples
+	// of traced requests carry the trace ID as an exemplar.
+	admissionDurationSeconds = newAdmissionDurationSeconds(prometheus.DefBuckets, false)
+
+	// admissionDecisionsTotal counts the decisions of admit functions.
+	admissionDecisionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "admission",
+			Name:      "decisions_total",
+			Help:      "The total number of admission decisions by hook, resource, operation and result.",
+		},
+		[]string{"hook", "resource", "operation", "result"}, // result: "allowed", "patched", "denied", "errored", "disabled" or "excluded"
+	)
+
+	// admissionHookEnabled tells which hooks are enabled.
+	admissionHookEnabled = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "admission",
+			Name:      "hook_enabled",
+			Help:      "Whether the hook evaluates requests (1) or is disabled and allows them (0).",
+		},
+		[]string{"hook"},
+	)
+
+	// admissionPatchBytes observes the size of the patches of mutating hooks.
+	admissionPatchBytes = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "admission",
+			Name:      "patch_size_bytes",
+			Help:      "The size of the JSON patches returned by hooks in bytes.",
+			Buckets:   prometheus.ExponentialBuckets(64, 4, 8), // 64B to 1MiB
+		},
+		[]string{"hook"},
+	)
+
+	// admissionPatchOperations observes the operation count of the patches of
+	// mutating hooks.
+	admissionPatchOperations = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "admission",
+			Name:      "patch_operations",
+			Help:      "The number of operations in the JSON patches returned by hooks.",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 10), // 1 to 512
+		},
+		[]string{"hook"},
+	)
+
+	// leaderTransitionsTotal counts changes of the leader.
+	leaderTransitionsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "leaderelection",
+			Name:      "transitions_total",
+			Help:      "The total number of leader changes observed.",
+		},
+	)
+
+	// leaderAcquireDurationSeconds observes how long it took to become the
+	// leader after starting the campaign.
+	leaderAcquireDurationSeconds = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "leaderelection",
+			Name:      "acquire_duration_seconds",
+			Help:      "The time taken to acquire leadership after starting the campaign in seconds.",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12), // 100ms to about 7m
+		},
+	)
+
+	// selfTestsTotal counts the self-tests of webhook entries.
+	selfTestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "selftest",
+			Name:      "probes_total",
+			Help:      "The total number of self-tests of webhook entries by result.",
+		},
+		[]string{"entry", "result"}, // result: "success" or "failure"
+	)
+
+	// selfTestSuccess tells whether the last self-test of webhook entries succeeded.
+	selfTestSuccess = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "selftest",
+			Name:      "success",
+			Help:      "Whether the last self-test of the webhook entry succeeded (1) or not (0).",
+		},
+		[]string{"entry"},
+	)
+
+	// subsystemRestartsTotal counts restarts of failed subsystems.
+	subsystemRestartsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "subsystem",
+			Name:      "restarts_total",
+			Help:      "The total number of restarts of failed subsystems.",
+		},
+		[]string{"subsystem"},
+	)
+
+	// informerWatchErrorsTotal counts failed informer watches.
+	informerWatchErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "informer",
+			Name:      "watch_errors_total",
+			Help:      "The total number of failed informer list and watch calls.",
+		},
+		[]string{"resource"},
+	)
+
+	// certSecondsUntilExpiryDesc and certExpiringDesc are computed by
+	// expiryCollector at scrape time, so they stay current between
+	// certificate loads.
+	certSecondsUntilExpiryDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "seconds_until_expiry"),
+		"The number of seconds until the certificate expires, negative once it has expired.",
+		certLabels, nil,
 	)
+	certExpiringDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "expiring"),
+		"Whether the certificate expires within its alert threshold (1) or not (0).",
+		certLabels, nil,
+	)
+
+	// certMu guards webhookName, certNotAfter and expiryThresholds.
+	certMu sync.Mutex
+	// webhookName is the value of the webhook label.
+	webhookName string
+	// certNotAfter is the expiry time of the certificates.
+	certNotAfter = map[CertID]time.Time{}
+	// expiryThresholds is the alert threshold of the certificates by type.
+	expiryThresholds = map[string]time.Duration{}
 
 	registerOnce sync.Once
+	// registered is set once the metrics are registered.
+	registered atomic.Bool
 )
 
-// Register registers all certificate metrics with the default registry.
+// expiryCollector reports the time until certificates expire.
+type expiryCollector struct{}
+
+// Describe implements prometheus.Collector.
+func (expiryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- certSecondsUntilExpiryDesc
+	ch <- certExpiringDesc
+}
+
+// Collect implements prometheus.Collector.
+func (expiryCollector) Collect(ch chan<- prometheus.Metric) {
+	certMu.Lock()
+	defer certMu.Unlock()
+
+	now := time.Now()
+	for id, notAfter := range certNotAfter {
+		labels := []string{id.Type, id.Namespace, id.SecretName, webhookName}
+		remaining := notAfter.Sub(now)
+		ch <- prometheus.MustNewConstMetric(certSecondsUntilExpiryDesc, prometheus.GaugeValue, remaining.Seconds(), labels...)
+
+		threshold, ok := expiryThresholds[id.Type]
+		if !ok {
+			continue
+		}
+		expiring := 0.0
+		if remaining < threshold {
+			expiring = 1
+		}
+		ch <- prometheus.MustNewConstMetric(certExpiringDesc, prometheus.GaugeValue, expiring, labels...)
+	}
+}
+
+// Register registers all metrics with the default registry.
 func Register() {
 	registerOnce.Do(func() {
 		prometheus.MustRegister(certExpiryTimestamp)
 		prometheus.MustRegister(certNotBeforeTimestamp)
 		prometheus.MustRegister(certValidDurationSeconds)
+		prometheus.MustRegister(certUnexpectedRegenerationsTotal)
+		prometheus.MustRegister(caBundleSyncsTotal)
+		prometheus.MustRegister(caBundleConflictsTotal)
+		prometheus.MustRegister(caBundleCertificates)
+		prometheus.MustRegister(caBundleSizeBytes)
+		prometheus.MustRegister(admissionInFlightRequests)
+		prometheus.MustRegister(admissionRejectedTotal)
+		prometheus.MustRegister(admissionDurationSeconds)
+		prometheus.MustRegister(admissionDecisionsTotal)
+		prometheus.MustRegister(admissionHookEnabled)
+		prometheus.MustRegister(admissionPatchBytes)
+		prometheus.MustRegister(admissionPatchOperations)
+		prometheus.MustRegister(leaderTransitionsTotal)
+		prometheus.MustRegister(leaderAcquireDurationSeconds)
+		prometheus.MustRegister(selfTestsTotal)
+		prometheus.MustRegister(selfTestSuccess)
+		prometheus.MustRegister(subsystemRestartsTotal)
+		prometheus.MustRegister(informerWatchErrorsTotal)
+		prometheus.MustRegister(expiryCollector{})
+		registered.Store(true)
 	})
 }
 
+// nativeHistogramBucketFactor bounds the relative width of native histogram
+// buckets to 10%.
+const nativeHistogramBucketFactor = 1.1
+
+// newAdmissionDurationSeconds creates the admission latency histogram.
+func newAdmissionDurationSeconds(buckets []float64, native bool) *prometheus.HistogramVec {
+	opts := prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "admission",
+		Name:      "duration_seconds",
+		Help:      "The time taken to evaluate admission requests in seconds.",
+		Buckets:   buckets,
+	}
+	if native {
+		opts.NativeHistogramBucketFactor = nativeHistogramBucketFactor
+	}
+	return prometheus.NewHistogramVec(opts, []string{"path"})
+}
+
+// SetAdmissionDurationBuckets sets the buckets of the admission latency
+// histogram, or the default buckets if empty, and whether it is also exposed
+// as a native histogram. Call it before Register; later calls are ignored.
+func SetAdmissionDurationBuckets(buckets []float64, native bool) {
+	if registered.Load() {
+		klog.Warning("Ignoring admission latency buckets set after the metrics were registered")
+		return
+	}
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+	admissionDurationSeconds = newAdmissionDurationSeconds(buckets, native)
+}
+
+// SetWebhookName sets the webhook label of the certificate metrics. Call it
+// before the first UpdateCertMetrics.
+func SetWebhookName(name string) {
+	certMu.Lock()
+	defer certMu.Unlock()
+	webhookName = name
+}
+
 // UpdateCertMetrics updates metrics for a certificate.
-func UpdateCertMetrics(certType string, cert *x509.Certificate) {
+func UpdateCertMetrics(id CertID, cert *x509.Certificate) {
 	if cert == nil {
 		return
 	}
 
-	certExpiryTimestamp.WithLabelValues(certType).Set(float64(cert.NotAfter.Unix()))
-	certNotBeforeTimestamp.WithLabelValues(certType).Set(float64(cert.NotBefore.Unix()))
-	certValidDurationSeconds.WithLabelValues(certType).Set(cert.NotAfter.Sub(cert.NotBefore).Seconds())
+	certMu.Lock()
+	defer certMu.Unlock()
+
+	labels := []string{id.Type, id.Namespace, id.SecretName, webhookName}
+	certExpiryTimestamp.WithLabelValues(labels...).Set(float64(cert.NotAfter.Unix()))
+	certNotBeforeTimestamp.WithLabelValues(labels...).Set(float64(cert.NotBefore.Unix()))
+	certValidDurationSeconds.WithLabelValues(labels...).Set(cert.NotAfter.Sub(cert.NotBefore).Seconds())
+	certNotAfter[id] = cert.NotAfter
+}
+
+// RecordUnexpectedRegeneration records a certificate replaced outside its
+// rotation.
+func RecordUnexpectedRegeneration(id CertID) {
+	certMu.Lock()
+	defer certMu.Unlock()
+	certUnexpectedRegenerationsTotal.WithLabelValues(id.Type, id.Namespace, id.SecretName, webhookName).Inc()
+}
+
+// SetExpiryThreshold sets the remaining validity below which a certificate
+// of the given type is reported as expiring.
+func SetExpiryThreshold(certType string, threshold time.Duration) {
+	certMu.Lock()
+	defer certMu.Unlock()
+	expiryThresholds[certType] = threshold
+}
+
+// RecordCABundleSync records a CA bundle injection.
+func RecordCABundleSync(trigger string, success bool) {
+	result := "success"
+	if !success {
+		result = "error"
+	}
+	caBundleSyncsTotal.WithLabelValues(trigger, result).Inc()
+}
+
+// RecordCABundleConflict records a conflicting CA bundle injection into a
+// webhook configuration; exhausted reports that no retries are left.
+func RecordCABundleConflict(exhausted bool) {
+	result := "retried"
+	if exhausted {
+		result = "exhausted"
+	}
+	caBundleConflictsTotal.WithLabelValues(result).Inc()
+}
+
+// SetCABundle records the number of CAs and the size in bytes of the CA
+// bundle in a ConfigMap.
+func SetCABundle(configMapNamespace, configMapName string, certs, size int) {
+	certMu.Lock()
+	defer certMu.Unlock()
+
+	labels := []string{configMapNamespace, configMapName, webhookName}
+	caBundleCertificates.WithLabelValues(labels...).Set(float64(certs))
+	caBundleSizeBytes.WithLabelValues(labels...).Set(float64(size))
+}
+
+// IncAdmissionInFlight records the start of an admission request.
+func IncAdmissionInFlight(path string) {
+	admissionInFlightRequests.WithLabelValues(path).Inc()
+}
+
+// DecAdmissionInFlight records the end of an admission request.
+func DecAdmissionInFlight(path string) {
+	admissionInFlightRequests.WithLabelValues(path).Dec()
+}
+
+// RecordAdmissionRejected records an admission request that was not evaluated.
+func RecordAdmissionRejected(path, reason string) {
+	admissionRejectedTotal.WithLabelValues(path, reason).Inc()
+}
+
+// ObserveAdmissionDuration records how long an admission request took to
+// evaluate. A non-empty traceID is attached as an exemplar, linking the sample
+// to the trace of the request.
+func ObserveAdmissionDuration(path string, duration time.Duration, traceID string) {
+	observer := admissionDurationSeconds.WithLabelValues(path)
+	if traceID != "" {
+		observer.(prometheus.ExemplarObserver).ObserveWithExemplar(duration.Seconds(), prometheus.Labels{"trace_id": traceID})
+		return
+	}
+	observer.Observe(duration.Seconds())
+}
+
+// RecordAdmissionDecision records the decision of a hook on a request.
+func RecordAdmissionDecision(hook, resource, operation, result string) {
+	admissionDecisionsTotal.WithLabelValues(hook, resource, operation, result).Inc()
 }
 
-// Handler returns an HTTP handler for the metrics endpoint.
+// SetHookEnabled records whether a hook is enabled.
+func SetHookEnabled(hook string, enabled bool) {
+	value := 0.0
+	if enabled {
+		value = 1
+	}
+	admissionHookEnabled.WithLabelValues(hook).Set(value)
+}
+
+// ObserveAdmissionPatch records the size and operation count of a patch.
+func ObserveAdmissionPatch(hook string, size, operations int) {
+	admissionPatchBytes.WithLabelValues(hook).Observe(float64(size))
+	admissionPatchOperations.WithLabelValues(hook).Observe(float64(operations))
+}
+
+// RecordLeaderTransition records a change of the leader.
+func RecordLeaderTransition() {
+	leaderTransitionsTotal.Inc()
+}
+
+// ObserveLeaderAcquireDuration records how long it took to become the leader.
+func ObserveLeaderAcquireDuration(duration time.Duration) {
+	leaderAcquireDurationSeconds.Observe(duration.Seconds())
+}
+
+// RecordSelfTest records the result of the self-test of a webhook entry.
+func RecordSelfTest(entry string, success bool) {
+	result, value := "failure", 0.0
+	if success {
+		result, value = "success", 1
+	}
+	selfTestsTotal.WithLabelValues(entry, result).Inc()
+	selfTestSuccess.WithLabelValues(entry).Set(value)
+}
+
+// RecordSubsystemRestart records the restart of a failed subsystem.
+func RecordSubsystemRestart(name string) {
+	subsystemRestartsTotal.WithLabelValues(name).Inc()
+}
+
+// WatchErrorHandler returns an informer watch error handler that logs like
+// client-go's default handler and counts the errors for the given resource, so
+// that informers serving stale data are visible. The informer relists with
+// client-go's backoff afterwards.
+func WatchErrorHandler(resource string) cache.WatchErrorHandlerWithContext {
+	return func(ctx context.Context, r *cache.Reflector, err error) {
+		if err != io.EOF {
+			informerWatchErrorsTotal.WithLabelValues(resource).Inc()
+		}
+		cache.DefaultWatchErrorHandler(ctx, r, err)
+	}
+}
+
+// Handler returns an HTTP handler for the metrics endpoint. The OpenMetrics
+// format is offered so that exemplars reach scrapers that negotiate it.
 func Handler() http.Handler {
-	return promhttp.Handler()
+	return promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}),
+	)
 }
@@ -1,16 +1,22 @@
 package metrics
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"errors"
+	"io"
 	"math/big"
 	"testing"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
 )
 
 func TestUpdateCertMetrics(t *testing.T) {
@@ -24,23 +30,26 @@ func TestUpdateCertMetrics(t *testing.T) {
 	notAfter := notBefore.Add(24 * time.Hour)
 	cert := createTestCert(t, notBefore, notAfter)
 
+	serving := CertID{Type: "serving", Namespace: "ns", SecretName: "wh-cert"}
+	ca := CertID{Type: "ca", Namespace: "ns", SecretName: "wh-ca"}
+
 	t.Run("updates all metrics", func(t *testing.T) {
-		UpdateCertMetrics("serving", cert)
+		UpdateCertMetrics(serving, cert)
 
 		// Check expiry timestamp
-		expiry := getGaugeValue(t, certExpiryTimestamp, "serving")
+		expiry := getGaugeValue(t, certExpiryTimestamp, serving)
 		if expiry != float64(notAfter.Unix()) {
 			t.Errorf("certExpiryTimestamp: got %v, want %v", expiry, float64(notAfter.Unix()))
 		}
 
 		// Check not-before timestamp
-		notBeforeVal := getGaugeValue(t, certNotBeforeTimestamp, "serving")
+		notBeforeVal := getGaugeValue(t, certNotBeforeTimestamp, serving)
 		if notBeforeVal != float64(notBefore.Unix()) {
 			t.Errorf("certNotBeforeTimestamp: got %v, want %v", notBeforeVal, float64(notBefore.Unix()))
 		}
 
 		// Check valid duration
-		duration := getGaugeValue(t, certValidDurationSeconds, "serving")
+		duration := getGaugeValue(t, certValidDurationSeconds, serving)
 		expectedDuration := notAfter.Sub(notBefore).Seconds()
 		if duration != expectedDuration {
 			t.Errorf("certValidDurationSeconds: got %v, want %v", duration, expectedDuration)
@@ -50,12 +59,12 @@ func TestUpdateCertMetrics(t *testing.T) {
 	t.Run("handles different cert types", func(t *testing.T) {
 		certExpiryTimestamp.Reset()
 
-		UpdateCertMetrics("ca", cert)
-		UpdateCertMetrics("serving", cert)
+		UpdateCertMetrics(ca, cert)
+		UpdateCertMetrics(serving, cert)
 
 		// Both should have metrics
-		caExpiry := getGaugeValue(t, certExpiryTimestamp, "ca")
-		servingExpiry := getGaugeValue(t, certExpiryTimestamp, "serving")
+		caExpiry := getGaugeValue(t, certExpiryTimestamp, ca)
+		servingExpiry := getGaugeValue(t, certExpiryTimestamp, serving)
 
 		if caExpiry != float64(notAfter.Unix()) {
 			t.Errorf("CA expiry: got %v, want %v", caExpiry, float64(notAfter.Unix()))
@@ -67,7 +76,7 @@ func TestUpdateCertMetrics(t *testing.T) {
 
 	t.Run("nil certificate is handled", func(t *testing.T) {
 		// Should not panic
-		UpdateCertMetrics("test", nil)
+		UpdateCertMetrics(CertID{Type: "test"}, nil)
 	})
 }
 
@@ -87,10 +96,10 @@ func TestHandler(t *testing.T) {
 }
 
 // Helper to get gauge value
-func getGaugeValue(t *testing.T, gauge *prometheus.GaugeVec, label string) float64 {
+func getGaugeValue(t *testing.T, gauge *prometheus.GaugeVec, id CertID) float64 {
 	t.Helper()
 
-	metric, err := gauge.GetMetricWithLabelValues(label)
+	metric, err := gauge.GetMetricWithLabelValues(id.Type, id.Namespace, id.SecretName, webhookName)
 	if err != nil {
 		t.Fatalf("Failed to get metric: %v", err)
 	}
@@ -133,3 +142,215 @@ func createTestCert(t *testing.T, notBefore, notAfter time.Time) *x509.Certifica
 
 	return cert
 }
+
+func TestRecordCABundleSync(t *testing.T) {
+	caBundleSyncsTotal.Reset()
+
+	RecordCABundleSync("event", true)
+	RecordCABundleSync("forced", true)
+	RecordCABundleSync("forced", false)
+
+	if got := testutil.ToFloat64(caBundleSyncsTotal.WithLabelValues("event", "success")); got != 1 {
+		t.Errorf("event/success: got %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(caBundleSyncsTotal.WithLabelValues("forced", "success")); got != 1 {
+		t.Errorf("forced/success: got %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(caBundleSyncsTotal.WithLabelValues("forced", "error")); got != 1 {
+		t.Errorf("forced/error: got %v, want 1", got)
+	}
+}
+
+func TestRecordCABundleConflict(t *testing.T) {
+	caBundleConflictsTotal.Reset()
+
+	RecordCABundleConflict(false)
+	RecordCABundleConflict(false)
+	RecordCABundleConflict(true)
+
+	if got := testutil.ToFloat64(caBundleConflictsTotal.WithLabelValues("retried")); got != 2 {
+		t.Errorf("retried: got %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(caBundleConflictsTotal.WithLabelValues("exhausted")); got != 1 {
+		t.Errorf("exhausted: got %v, want 1", got)
+	}
+}
+
+func TestAdmissionInFlight(t *testing.T) {
+	admissionInFlightRequests.Reset()
+
+	IncAdmissionInFlight("/validate")
+	IncAdmissionInFlight("/validate")
+	DecAdmissionInFlight("/validate")
+
+	if got := testutil.ToFloat64(admissionInFlightRequests.WithLabelValues("/validate")); got != 1 {
+		t.Errorf("in-flight: got %v, want 1", got)
+	}
+}
+
+func TestRecordAdmissionRejected(t *testing.T) {
+	admissionRejectedTotal.Reset()
+
+	RecordAdmissionRejected("/validate", "in_flight_limit")
+
+	if got := testutil.ToFloat64(admissionRejectedTotal.WithLabelValues("/validate", "in_flight_limit")); got != 1 {
+		t.Errorf("rejected: got %v, want 1", got)
+	}
+}
+
+func TestWatchErrorHandler(t *testing.T) {
+	informerWatchErrorsTotal.Reset()
+	reflector := cache.NewReflector(&cache.ListWatch{}, &corev1.Secret{}, cache.NewStore(cache.MetaNamespaceKeyFunc), 0)
+	handler := WatchErrorHandler("secrets")
+
+	handler(context.Background(), reflector, errors.New("connection refused"))
+	handler(context.Background(), reflector, io.EOF)
+
+	if got := testutil.ToFloat64(informerWatchErrorsTotal.WithLabelValues("secrets")); got != 1 {
+		t.Errorf("watch errors: got %v, want 1", got)
+	}
+}
+
+func TestExpiryCollector(t *testing.T) {
+	certMu.Lock()
+	certNotAfter = map[CertID]time.Time{}
+	expiryThresholds = map[string]time.Duration{}
+	certMu.Unlock()
+	SetWebhookName("wh")
+	defer SetWebhookName("")
+
+	now := time.Now()
+	UpdateCertMetrics(CertID{Type: "ca", Namespace: "ns", SecretName: "wh-ca"}, createTestCert(t, now.Add(-time.Hour), now.Add(time.Hour)))
+	UpdateCertMetrics(CertID{Type: "serving", Namespace: "ns", SecretName: "wh-cert"}, createTestCert(t, now.Add(-time.Hour), now.Add(24*time.Hour)))
+	SetExpiryThreshold("ca", 2*time.Hour)
+	SetExpiryThreshold("serving", 2*time.Hour)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(expiryCollector{})
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+
+	values := map[string]float64{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["webhook"] != "wh" || labels["secret_namespace"] != "ns" {
+				t.Errorf("Unexpected labels %v", labels)
+			}
+			values[family.GetName()+"/"+labels["type"]] = metric.GetGauge().GetValue()
+		}
+	}
+
+	if got := values["admission_webhook_certificate_seconds_until_expiry/ca"]; got <= 0 || got > 3600 {
+		t.Errorf("seconds_until_expiry{type=ca} = %v, want about 3600", got)
+	}
+	if got := values["admission_webhook_certificate_expiring/ca"]; got != 1 {
+		t.Errorf("expiring{type=ca} = %v, want 1", got)
+	}
+	if got := values["admission_webhook_certificate_expiring/serving"]; got != 0 {
+		t.Errorf("expiring{type=serving} = %v, want 0", got)
+	}
+}
+
+func TestObserveAdmissionDuration(t *testing.T) {
+	admissionDurationSeconds.Reset()
+
+	ObserveAdmissionDuration("/validate", 20*time.Millisecond, "")
+	ObserveAdmissionDuration("/validate", 30*time.Millisecond, "4bf92f3577b34da6a3ce929d0e0e4736")
+
+	var m dto.Metric
+	if err := admissionDurationSeconds.WithLabelValues("/validate").(prometheus.Metric).Write(&m); err != nil {
+		t.Fatalf("Failed to write metric: %v", err)
+	}
+	if got := m.GetHistogram().GetSampleCount(); got != 2 {
+		t.Errorf("Sample count: got %d, want 2", got)
+	}
+
+	var traceIDs []string
+	for _, bucket := range m.GetHistogram().GetBucket() {
+		for _, label := range bucket.GetExemplar().GetLabel() {
+			if label.GetName() == "trace_id" {
+				traceIDs = append(traceIDs, label.GetValue())
+			}
+		}
+	}
+	if len(traceIDs) != 1 || traceIDs[0] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Exemplar trace IDs: got %v, want [4bf92f3577b34da6a3ce929d0e0e4736]", traceIDs)
+	}
+}
+
+func TestRecordAdmissionDecision(t *testing.T) {
+	admissionDecisionsTotal.Reset()
+
+	RecordAdmissionDecision("/validate", "pods", "CREATE", "denied")
+	RecordAdmissionDecision("/validate", "pods", "CREATE", "denied")
+	RecordAdmissionDecision("/validate", "deployments.apps", "UPDATE", "allowed")
+
+	if got := testutil.ToFloat64(admissionDecisionsTotal.WithLabelValues("/validate", "pods", "CREATE", "denied")); got != 2 {
+		t.Errorf("pods/CREATE/denied: got %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(admissionDecisionsTotal.WithLabelValues("/validate", "deployments.apps", "UPDATE", "allowed")); got != 1 {
+		t.Errorf("deployments.apps/UPDATE/allowed: got %v, want 1", got)
+	}
+}
+
+func TestObserveAdmissionPatch(t *testing.T) {
+	admissionPatchBytes.Reset()
+	admissionPatchOperations.Reset()
+
+	ObserveAdmissionPatch("/mutate", 100, 2)
+	ObserveAdmissionPatch("/mutate", 300, 4)
+
+	var size, ops dto.Metric
+	if err := admissionPatchBytes.WithLabelValues("/mutate").(prometheus.Metric).Write(&size); err != nil {
+		t.Fatalf("Failed to write metric: %v", err)
+	}
+	if err := admissionPatchOperations.WithLabelValues("/mutate").(prometheus.Metric).Write(&ops); err != nil {
+		t.Fatalf("Failed to write metric: %v", err)
+	}
+	if got := size.GetHistogram().GetSampleSum(); got != 400 {
+		t.Errorf("Patch size sum: got %v, want 400", got)
+	}
+	if got := ops.GetHistogram().GetSampleSum(); got != 6 {
+		t.Errorf("Patch operations sum: got %v, want 6", got)
+	}
+	if got := ops.GetHistogram().GetSampleCount(); got != 2 {
+		t.Errorf("Patch count: got %d, want 2", got)
+	}
+}
+
+func TestSetAdmissionDurationBuckets(t *testing.T) {
+	defer func(histogram *prometheus.HistogramVec, wasRegistered bool) {
+		admissionDurationSeconds = histogram
+		registered.Store(wasRegistered)
+	}(admissionDurationSeconds, registered.Load())
+	registered.Store(false)
+
+	SetAdmissionDurationBuckets([]float64{0.0005, 0.001}, true)
+	ObserveAdmissionDuration("/mutate", 200*time.Microsecond, "")
+
+	var m dto.Metric
+	if err := admissionDurationSeconds.WithLabelValues("/mutate").(prometheus.Metric).Write(&m); err != nil {
+		t.Fatalf("Failed to write metric: %v", err)
+	}
+	buckets := m.GetHistogram().GetBucket()
+	if len(buckets) != 2 || buckets[0].GetUpperBound() != 0.0005 || buckets[0].GetCumulativeCount() != 1 {
+		t.Errorf("Unexpected buckets %v", buckets)
+	}
+	if m.GetHistogram().GetSchema() == 0 && len(m.GetHistogram().GetPositiveSpan()) == 0 {
+		t.Error("Expected a native histogram")
+	}
+
+	// Buckets cannot change once registered
+	registered.Store(true)
+	histogram := admissionDurationSeconds
+	SetAdmissionDurationBuckets(nil, false)
+	if admissionDurationSeconds != histogram {
+		t.Error("Expected buckets set after registration to be ignored")
+	}
+}
@@ -2,7 +2,10 @@ package metrics
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net"
 	"net/http"
 	"time"
 
@@ -14,8 +17,36 @@ type ServerConfig struct {
 	// Port is the port to listen on.
 	Port int
 
+	// Network restricts the address family of the listener: "tcp4" or
+	// "tcp6". Empty or "tcp" accepts both on dual-stack hosts.
+	Network string
+
 	// Path is the path to serve metrics on.
 	Path string
+
+	// ShutdownTimeout bounds how long in-flight scrapes are drained on
+	// shutdown. Defaults to 5 seconds.
+	ShutdownTimeout time.Duration
+
+	// TLSConfig, if set, serves metrics over HTTPS, e.g. with the webhook's
+	// rotating serving certificate.
+	TLSConfig *tls.Config
+
+	// ClientCAs returns the CAs trusted to sign client certificates. Scrapes
+	// presenting a client certificate signed by one of them are authenticated.
+	// Requires TLSConfig.
+	ClientCAs func() *x509.CertPool
+
+	// Authenticator, if set, authenticates scrapes by their bearer token,
+	// e.g. with a TokenReview.
+	Authenticator Authenticator
+}
+
+// Authenticator authenticates the caller of a scrape.
+type Authenticator interface {
+	// AuthenticateRequest returns the authenticated user of the request and
+	// whether authentication succeeded.
+	AuthenticateRequest(r *http.Request) (user string, ok bool, err error)
 }
 
 // Server is a dedicated HTTP server for serving Prometheus metrics.
@@ -29,18 +60,27 @@ func NewServer(config ServerConfig) *Server {
 	if config.Path == "" {
 		config.Path = "/metrics"
 	}
+	if config.ShutdownTimeout <= 0 {
+		config.ShutdownTimeout = 5 * time.Second
+	}
 
 	return &Server{
 		config: config,
 	}
 }
 
-// Start starts the metrics server and blocks until the context is cancelled.
-func (s *Server) Start(ctx context.Context) error {
+// Handler registers the metrics and returns their handler with the server's
+// authentication, for serving metrics on another server instead of calling
+// Start.
+func (s *Server) Handler() http.Handler {
 	Register()
+	return s.authenticate(Handler())
+}
 
+// Start starts the metrics server and blocks until the context is cancelled.
+func (s *Server) Start(ctx context.Context) error {
 	mux := http.NewServeMux()
-	mux.Handle(s.config.Path, Handler())
+	mux.Handle(s.config.Path, s.Handler())
 
 	s.server = &http.Server{
 		Addr:              fmt.Sprintf(":%d", s.config.Port),
@@ -50,12 +90,38 @@ func (s *Server) Start(ctx context.Context) error {
 		WriteTimeout:      3 * time.Second,
 		IdleTimeout:       60 * time.Second,
 	}
+	if s.config.TLSConfig != nil {
+		tlsConfig := s.config.TLSConfig.Clone()
+		if s.config.ClientCAs != nil {
+			// As on the webhook server, certificates are verified in
+			// VerifyConnection so that a rotated client CA applies to new
+			// connections. Scrapes without one may still use a bearer token.
+			tlsConfig.ClientAuth = tls.RequestClientCert
+			tlsConfig.VerifyConnection = s.verifyClientCert
+		}
+		s.server.TLSConfig = tlsConfig
+	}
 
-	klog.Infof("Starting metrics server on port %d", s.config.Port)
+	network := s.config.Network
+	if network == "" {
+		network = "tcp"
+	}
+	ln, err := net.Listen(network, s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	klog.Infof("Starting metrics server on %s", ln.Addr())
 
 	errCh := make(chan error, 1)
 	go func() {
-		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if s.server.TLSConfig != nil {
+			err = s.server.ServeTLS(ln, "", "")
+		} else {
+			err = s.server.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
 			errCh <- err
 		}
 	}()
@@ -63,7 +129,7 @@ func (s *Server) Start(ctx context.Context) error {
 	select {
 	case <-ctx.Done():
 		klog.Infof("Shutting down metrics server on port %d", s.config.Port)
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
 		defer cancel()
 		return s.server.Shutdown(shutdownCtx)
 	case err := <-errCh:
@@ -71,3 +137,53 @@ func (s *Server) Start(ctx context.Context) error {
 		return err
 	}
 }
+
+// authenticate wraps handler to require a verified client certificate or an
+// authenticated bearer token, if either is configured.
+func (s *Server) authenticate(handler http.Handler) http.Handler {
+	if s.config.ClientCAs == nil && s.config.Authenticator == nil {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Client certificates were verified during the handshake
+		if s.config.ClientCAs != nil && r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if s.config.Authenticator != nil {
+			user, ok, err := s.config.Authenticator.AuthenticateRequest(r)
+			if err != nil {
+				klog.Errorf("Failed to authenticate scrape from %s: %v", r.RemoteAddr, err)
+			}
+			if ok {
+				klog.V(4).Infof("Authenticated scrape from %s as %q", r.RemoteAddr, user)
+				handler.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+		}
+		klog.V(2).Infof("Rejecting unauthenticated scrape from %s", r.RemoteAddr)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+}
+
+// verifyClientCert verifies the client certificate of a connection, if any,
+// against the current client CAs.
+func (s *Server) verifyClientCert(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return nil
+	}
+	opts := x509.VerifyOptions{
+		Roots:         s.config.ClientCAs(),
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	if _, err := cs.PeerCertificates[0].Verify(opts); err != nil {
+		klog.V(2).Infof("Rejecting client certificate %q of scrape: %v", cs.PeerCertificates[0].Subject.CommonName, err)
+		return fmt.Errorf("failed to verify client certificate: %w", err)
+	}
+	return nil
+}
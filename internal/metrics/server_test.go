@@ -2,7 +2,16 @@ package metrics
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 )
@@ -20,6 +29,10 @@ func TestNewServer(t *testing.T) {
 		if server.config.Path != "/metrics" {
 			t.Errorf("Path: got %q, want %q", server.config.Path, "/metrics")
 		}
+
+		if server.config.ShutdownTimeout != 5*time.Second {
+			t.Errorf("ShutdownTimeout: got %v, want %v", server.config.ShutdownTimeout, 5*time.Second)
+		}
 	})
 
 	t.Run("with custom path", func(t *testing.T) {
@@ -32,6 +45,17 @@ func TestNewServer(t *testing.T) {
 			t.Errorf("Path: got %q, want %q", server.config.Path, "/custom-metrics")
 		}
 	})
+
+	t.Run("with custom shutdown timeout", func(t *testing.T) {
+		server := NewServer(ServerConfig{
+			Port:            9090,
+			ShutdownTimeout: 30 * time.Second,
+		})
+
+		if server.config.ShutdownTimeout != 30*time.Second {
+			t.Errorf("ShutdownTimeout: got %v, want %v", server.config.ShutdownTimeout, 30*time.Second)
+		}
+	})
 }
 
 func TestServer_Start(t *testing.T) {
@@ -76,3 +100,142 @@ func TestServer_Start(t *testing.T) {
 		}
 	})
 }
+
+// tokenAuthenticator accepts the bearer token "valid".
+type tokenAuthenticator struct{}
+
+func (tokenAuthenticator) AuthenticateRequest(r *http.Request) (string, bool, error) {
+	switch r.Header.Get("Authorization") {
+	case "Bearer valid":
+		return "system:serviceaccount:monitoring:prometheus", true, nil
+	case "Bearer broken":
+		return "", false, errors.New("connection refused")
+	}
+	return "", false, nil
+}
+
+func TestServer_authenticate(t *testing.T) {
+	pool := x509.NewCertPool()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	clientCert := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{}}}
+
+	tests := []struct {
+		name   string
+		config ServerConfig
+		token  string
+		tls    *tls.ConnectionState
+		want   int
+	}{
+		{name: "unauthenticated server", want: http.StatusOK},
+		{name: "valid token", config: ServerConfig{Authenticator: tokenAuthenticator{}}, token: "valid", want: http.StatusOK},
+		{name: "invalid token", config: ServerConfig{Authenticator: tokenAuthenticator{}}, token: "invalid", want: http.StatusUnauthorized},
+		{name: "review error", config: ServerConfig{Authenticator: tokenAuthenticator{}}, token: "broken", want: http.StatusUnauthorized},
+		{name: "no token", config: ServerConfig{Authenticator: tokenAuthenticator{}}, want: http.StatusUnauthorized},
+		{name: "client certificate", config: ServerConfig{ClientCAs: func() *x509.CertPool { return pool }}, tls: clientCert, want: http.StatusOK},
+		{name: "no client certificate", config: ServerConfig{ClientCAs: func() *x509.CertPool { return pool }}, tls: &tls.ConnectionState{}, want: http.StatusUnauthorized},
+		{
+			name:   "token instead of client certificate",
+			config: ServerConfig{ClientCAs: func() *x509.CertPool { return pool }, Authenticator: tokenAuthenticator{}},
+			token:  "valid",
+			tls:    &tls.ConnectionState{},
+			want:   http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(tt.config).authenticate(ok)
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			r.TLS = tt.tls
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("Status: got %d, want %d", w.Code, tt.want)
+			}
+			if w.Code == http.StatusUnauthorized && tt.config.Authenticator != nil && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected a WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestServer_verifyClientCert(t *testing.T) {
+	ca, caKey := newTestCA(t)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	server := NewServer(ServerConfig{ClientCAs: func() *x509.CertPool { return pool }})
+
+	otherCA, otherKey := newTestCA(t)
+	tests := []struct {
+		name    string
+		certs   []*x509.Certificate
+		wantErr bool
+	}{
+		{name: "no certificate"},
+		{name: "trusted client", certs: []*x509.Certificate{newTestLeaf(t, ca, caKey, x509.ExtKeyUsageClientAuth)}},
+		{name: "untrusted client", certs: []*x509.Certificate{newTestLeaf(t, otherCA, otherKey, x509.ExtKeyUsageClientAuth)}, wantErr: true},
+		{name: "server certificate", certs: []*x509.Certificate{newTestLeaf(t, ca, caKey, x509.ExtKeyUsageServerAuth)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := server.verifyClientCert(tls.ConnectionState{PeerCertificates: tt.certs})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifyClientCert: got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// newTestCA creates a self-signed CA.
+func newTestCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse CA: %v", err)
+	}
+	return cert, key
+}
+
+// newTestLeaf creates a certificate with the given extended key usage signed by ca.
+func newTestLeaf(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, usage x509.ExtKeyUsage) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "prometheus"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledBufferSize is the largest buffer returned to the pool, so that a
+// few large requests do not pin memory.
+const maxPooledBufferSize = 1 << 20
+
+// bufferPool holds buffers for request bodies and encoded responses.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// getBuffer returns an empty buffer from the pool.
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer resets buf and returns it to the pool.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// maxCacheEntries bounds the number of responses a hook caches.
+const maxCacheEntries = 10000
+
+// responseCache caches the admission responses of a hook for a short time, so
+// that API server retries and reinvocations of a request are answered without
+// calling the admit function again. A nil cache does not cache.
+type responseCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[cacheKey]cacheEntry
+}
+
+// cacheKey identifies a request by UID and object. A reinvocation keeps the
+// UID, but the object may have been changed by other mutating webhooks since,
+// which a generation would not tell, as it only changes on persisted updates.
+type cacheKey struct {
+	uid    types.UID
+	object [sha256.Size]byte
+}
+
+// cacheEntry is a cached response and its expiry.
+type cacheEntry struct {
+	resp    *admissionv1.AdmissionResponse
+	expires time.Time
+}
+
+// newResponseCache creates a cache keeping responses for ttl. It returns nil
+// if ttl is zero or negative.
+func newResponseCache(ttl time.Duration) *responseCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &responseCache{ttl: ttl, now: time.Now, entries: map[cacheKey]cacheEntry{}}
+}
+
+// get returns a copy of the cached response to req, or nil if there is none.
+func (c *responseCache) get(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	if c == nil || req.UID == "" {
+		return nil
+	}
+	key := newCacheKey(req)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	if !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		return nil
+	}
+	return entry.resp.DeepCopy()
+}
+
+// put caches a copy of resp as the response to req. Once the cache is full,
+// expired entries are removed, and resp is dropped if none were.
+func (c *responseCache) put(req *admissionv1.AdmissionRequest, resp *admissionv1.AdmissionResponse) {
+	if c == nil || req.UID == "" || resp == nil {
+		return
+	}
+	key := newCacheKey(req)
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxCacheEntries {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxCacheEntries {
+			return
+		}
+	}
+	c.entries[key] = cacheEntry{resp: resp.DeepCopy(), expires: now.Add(c.ttl)}
+}
+
+// newCacheKey returns the cache key of req.
+func newCacheKey(req *admissionv1.AdmissionRequest) cacheKey {
+	return cacheKey{uid: req.UID, object: sha256.Sum256(req.Object.Raw)}
+}
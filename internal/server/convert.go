@@ -0,0 +1,54 @@
+package server
+
+import (
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+)
+
+// The v1 and v1beta1 AdmissionReview types have identical fields; v1beta1
+// reviews are converted to v1 before calling the admit function and the
+// response is converted back, so hooks only ever deal with v1.
+
+// convertRequestFromV1beta1 converts a v1beta1 admission request to v1.
+func convertRequestFromV1beta1(in *admissionv1beta1.AdmissionRequest) *admissionv1.AdmissionRequest {
+	if in == nil {
+		return nil
+	}
+	return &admissionv1.AdmissionRequest{
+		UID:                in.UID,
+		Kind:               in.Kind,
+		Resource:           in.Resource,
+		SubResource:        in.SubResource,
+		RequestKind:        in.RequestKind,
+		RequestResource:    in.RequestResource,
+		RequestSubResource: in.RequestSubResource,
+		Name:               in.Name,
+		Namespace:          in.Namespace,
+		Operation:          admissionv1.Operation(in.Operation),
+		UserInfo:           in.UserInfo,
+		Object:             in.Object,
+		OldObject:          in.OldObject,
+		DryRun:             in.DryRun,
+		Options:            in.Options,
+	}
+}
+
+// convertResponseToV1beta1 converts a v1 admission response to v1beta1.
+func convertResponseToV1beta1(in *admissionv1.AdmissionResponse) *admissionv1beta1.AdmissionResponse {
+	if in == nil {
+		return nil
+	}
+	out := &admissionv1beta1.AdmissionResponse{
+		UID:              in.UID,
+		Allowed:          in.Allowed,
+		Result:           in.Result,
+		Patch:            in.Patch,
+		AuditAnnotations: in.AuditAnnotations,
+		Warnings:         in.Warnings,
+	}
+	if in.PatchType != nil {
+		patchType := admissionv1beta1.PatchType(*in.PatchType)
+		out.PatchType = &patchType
+	}
+	return out
+}
@@ -1,17 +1,27 @@
 package server
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"strconv"
 	"sync"
+	"time"
 
 	admissionv1 "k8s.io/api/admission/v1"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/v2"
+
+	"github.com/jimyag/auto-cert-webhook/internal/metrics"
 )
 
 const (
@@ -20,9 +30,9 @@ const (
 )
 
 var (
-	scheme    *runtime.Scheme
-	codecs    serializer.CodecFactory
-	schemeErr error
+	scheme     *runtime.Scheme
+	codecs     serializer.CodecFactory
+	schemeErr  error
 	schemeOnce sync.Once
 )
 
@@ -34,6 +44,10 @@ func initScheme() error {
 			schemeErr = fmt.Errorf("failed to add admissionv1 scheme: %w", err)
 			return
 		}
+		if err := admissionv1beta1.AddToScheme(scheme); err != nil {
+			schemeErr = fmt.Errorf("failed to add admissionv1beta1 scheme: %w", err)
+			return
+		}
 		codecs = serializer.NewCodecFactory(scheme)
 	})
 	return schemeErr
@@ -41,7 +55,33 @@ func initScheme() error {
 
 // admissionHandler handles admission requests.
 type admissionHandler struct {
-	admit AdmitFunc
+	admit       AdmitFunc
+	path        string
+	allowYAML   bool
+	inFlight    *inFlightLimiter
+	rateLimiter *requestRateLimiter
+	loadShedder *loadShedder
+	failOpen    bool
+	// timeout, if positive, bounds the admit function.
+	timeout time.Duration
+	// maxBodySize is the request body limit in bytes. If zero,
+	// maxRequestBodySize applies.
+	maxBodySize int64
+	// cache, if set, answers repeated requests with the response of the
+	// admit function.
+	cache *responseCache
+	// disabled allows every request without calling admit.
+	disabled bool
+	// excludeNamespaces are namespaces whose requests are allowed without
+	// calling admit.
+	excludeNamespaces map[string]bool
+
+	// requireClientCert rejects requests without a client certificate. The
+	// certificate itself is verified during the TLS handshake.
+	requireClientCert bool
+
+	// authenticator, if set, must authenticate the caller.
+	authenticator Authenticator
 }
 
 func newAdmissionHandler(admit AdmitFunc) *admissionHandler {
@@ -49,6 +89,25 @@ func newAdmissionHandler(admit AdmitFunc) *admissionHandler {
 }
 
 func (h *admissionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.requireClientCert && (r.TLS == nil || len(r.TLS.PeerCertificates) == 0) {
+		metrics.RecordAdmissionRejected(h.path, "unauthenticated")
+		klog.Warningf("Rejecting request to admission path %s from %s: no client certificate", r.URL.Path, r.RemoteAddr)
+		http.Error(w, "client certificate required", http.StatusForbidden)
+		return
+	}
+	if h.authenticator != nil && !h.authenticate(w, r) {
+		return
+	}
+
+	// The API server only POSTs AdmissionReviews; reject probes and other
+	// methods before reading the body.
+	if r.Method != http.MethodPost {
+		klog.V(4).Infof("Rejecting %s request to admission path %s", r.Method, r.URL.Path)
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, fmt.Sprintf("method %s not allowed", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+
 	klog.V(2).Infof("Handling admission request: %s %s", r.Method, r.URL.Path)
 
 	// Initialize scheme lazily
@@ -58,17 +117,38 @@ func (h *admissionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var body []byte
+	contentType := r.Header.Get("Content-Type")
+	if !h.supportedContentType(contentType) {
+		klog.Errorf("Unsupported content type: %s", contentType)
+		http.Error(w, fmt.Sprintf("unsupported content type: %s", contentType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// Read the body into a pooled buffer. Decoded objects copy what they keep,
+	// so the buffer can be reused once the request is handled.
+	buf := getBuffer()
+	defer putBuffer(buf)
+	limit := h.maxBodySize
+	if limit <= 0 {
+		limit = maxRequestBodySize
+	}
 	if r.Body != nil {
 		defer r.Body.Close()
-		// Limit request body size to prevent memory exhaustion
-		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
-		if err != nil {
+		if r.ContentLength > 0 && r.ContentLength <= limit {
+			buf.Grow(int(r.ContentLength))
+		}
+		// Limit request body size to prevent memory exhaustion; one more
+		// byte tells an oversized body apart
+		if _, err := buf.ReadFrom(io.LimitReader(r.Body, limit+1)); err != nil {
 			klog.Errorf("Failed to read request body: %v", err)
 			http.Error(w, fmt.Sprintf("failed to read request body: %v", err), http.StatusBadRequest)
 			return
 		}
-		body = data
+	}
+	body := buf.Bytes()
+	if int64(len(body)) > limit {
+		h.rejectTooLarge(w, body, limit)
+		return
 	}
 
 	if len(body) == 0 {
@@ -77,19 +157,13 @@ func (h *admissionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	contentType := r.Header.Get("Content-Type")
-	if contentType != "application/json" {
-		klog.Errorf("Unsupported content type: %s", contentType)
-		http.Error(w, fmt.Sprintf("unsupported content type: %s", contentType), http.StatusUnsupportedMediaType)
-		return
+	if klogV := klog.V(4); klogV.Enabled() {
+		klogV.Infof("Request body: %s", body)
 	}
 
-	klog.V(4).Infof("Request body: %s", string(body))
-
 	// Decode the request
-	requestedAdmissionReview := admissionv1.AdmissionReview{}
-	deserializer := codecs.UniversalDeserializer()
-	if _, _, err := deserializer.Decode(body, nil, &requestedAdmissionReview); err != nil {
+	requestedAdmissionReview, err := decodeAdmissionReview(body)
+	if err != nil {
 		klog.Errorf("Failed to decode admission review: %v", err)
 		http.Error(w, fmt.Sprintf("failed to decode admission review: %v", err), http.StatusBadRequest)
 		return
@@ -103,6 +177,14 @@ func (h *admissionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		},
 	}
 
+	// The audit ID and UID are logged with every message about the request,
+	// and passed to the admit function, to correlate them with audit events
+	ctx := r.Context()
+	if req := requestedAdmissionReview.Request; req != nil {
+		ctx = withRequestInfo(ctx, r.Header.Get(AuditIDHeader), req.UID)
+	}
+	logger := klog.FromContext(ctx)
+
 	// Handle the request
 	if requestedAdmissionReview.Request == nil {
 		responseAdmissionReview.Response = &admissionv1.AdmissionResponse{
@@ -112,8 +194,42 @@ func (h *admissionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				Code:    http.StatusBadRequest,
 			},
 		}
+	} else if h.disabled {
+		req := requestedAdmissionReview.Request
+		metrics.RecordAdmissionDecision(h.path, resourceName(req), string(req.Operation), "disabled")
+		logger.V(4).Info("Allowing admission request: hook is disabled", "path", h.path)
+		responseAdmissionReview.Response = &admissionv1.AdmissionResponse{Allowed: true}
+	} else if req := requestedAdmissionReview.Request; h.excludeNamespaces[req.Namespace] && !isNamespaceRequest(req) {
+		metrics.RecordAdmissionDecision(h.path, resourceName(req), string(req.Operation), "excluded")
+		logger.V(4).Info("Allowing admission request: namespace is excluded", "namespace", req.Namespace)
+		responseAdmissionReview.Response = &admissionv1.AdmissionResponse{Allowed: true}
+	} else if resp := h.cache.get(req); resp != nil {
+		metrics.RecordAdmissionDecision(h.path, resourceName(req), string(req.Operation), "cached")
+		logger.V(4).Info("Answering admission request from cache", "path", h.path)
+		responseAdmissionReview.Response = resp
+	} else if ok, delay := h.rateLimiter.allow(h.path, requestedAdmissionReview.Request); !ok {
+		metrics.RecordAdmissionRejected(h.path, "rate_limit")
+		logger.V(2).Info("Rate limiting admission request",
+			"user", requestedAdmissionReview.Request.UserInfo.Username, "namespace", requestedAdmissionReview.Request.Namespace)
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(delay)))
+		http.Error(w, "too many admission requests", http.StatusTooManyRequests)
+		return
+	} else if h.failOpen && h.loadShedder.overloaded() {
+		metrics.RecordAdmissionRejected(h.path, "load_shed")
+		logger.V(2).Info("Allowing admission request without evaluation: server overloaded")
+		responseAdmissionReview.Response = allowedWithoutEvaluation()
+	} else if !h.inFlight.tryAcquire() {
+		metrics.RecordAdmissionRejected(h.path, "in_flight_limit")
+		if !h.failOpen {
+			logger.Info("Rejecting admission request: too many requests in flight")
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "too many admission requests in flight", http.StatusServiceUnavailable)
+			return
+		}
+		logger.Info("Allowing admission request without evaluation: too many requests in flight")
+		responseAdmissionReview.Response = allowedWithoutEvaluation()
 	} else {
-		responseAdmissionReview.Response = h.admit(requestedAdmissionReview)
+		responseAdmissionReview.Response = h.callAdmit(ctx, requestedAdmissionReview, sampledTraceID(r))
 	}
 
 	// Set the UID
@@ -126,18 +242,347 @@ func (h *admissionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		responseAdmissionReview.APIVersion = requestedAdmissionReview.APIVersion
 	}
 
-	klog.V(4).Infof("Sending admission response: %+v", responseAdmissionReview.Response)
+	if klogV := klog.V(4); klogV.Enabled() {
+		klogV.Infof("Sending admission response: %+v", responseAdmissionReview.Response)
+	}
 
 	// Write the response
-	respBytes, err := json.Marshal(responseAdmissionReview)
-	if err != nil {
+	out := getBuffer()
+	defer putBuffer(out)
+	if err := encodeAdmissionReview(out, responseAdmissionReview); err != nil {
 		klog.Errorf("Failed to marshal admission response: %v", err)
 		http.Error(w, fmt.Sprintf("failed to marshal admission response: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if _, err := w.Write(respBytes); err != nil {
+	if _, err := w.Write(out.Bytes()); err != nil {
+		klog.Errorf("Failed to write admission response: %v", err)
+	}
+}
+
+// authenticate authenticates the caller and writes a 401 response if that fails.
+func (h *admissionHandler) authenticate(w http.ResponseWriter, r *http.Request) bool {
+	user, ok, err := h.authenticator.AuthenticateRequest(r)
+	if err != nil {
+		klog.Errorf("Failed to authenticate request to admission path %s from %s: %v", r.URL.Path, r.RemoteAddr, err)
+	} else if !ok {
+		klog.Warningf("Rejecting unauthenticated request to admission path %s from %s", r.URL.Path, r.RemoteAddr)
+	}
+	if err != nil || !ok {
+		metrics.RecordAdmissionRejected(h.path, "unauthenticated")
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admission"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	klog.V(4).Infof("Authenticated request to admission path %s as %q", r.URL.Path, user)
+	return true
+}
+
+// callAdmit calls the admit function holding an in-flight slot acquired by
+// the caller, with ctx carrying the request info. traceID, if set, links the
+// latency sample to the request's trace.
+func (h *admissionHandler) callAdmit(ctx context.Context, ar admissionv1.AdmissionReview, traceID string) *admissionv1.AdmissionResponse {
+	start := time.Now()
+	resp, err := h.runAdmit(ctx, ar)
+	metrics.ObserveAdmissionDuration(h.path, time.Since(start), traceID)
+	if err != nil {
+		metrics.RecordAdmissionDecision(h.path, resourceName(ar.Request), string(ar.Request.Operation), "timeout")
+		klog.FromContext(ctx).Info("Admission request not answered", "path", h.path, "err", err)
+		return h.timeoutResponse(err)
+	}
+	metrics.RecordAdmissionDecision(h.path, resourceName(ar.Request), string(ar.Request.Operation), decision(resp))
+	if resp != nil && len(resp.Patch) > 0 {
+		metrics.ObserveAdmissionPatch(h.path, len(resp.Patch), patchOperations(resp.Patch))
+	}
+	h.cache.put(ar.Request, resp)
+	return resp
+}
+
+// runAdmit calls the admit function and releases the in-flight slot once it
+// returns. With a timeout, it returns an error when the timeout expires or the
+// request is abandoned first; the admit function then keeps its slot, so that
+// handlers ignoring their context still count against MaxInFlight.
+func (h *admissionHandler) runAdmit(ctx context.Context, ar admissionv1.AdmissionReview) (*admissionv1.AdmissionResponse, error) {
+	finish := h.loadShedder.start()
+	metrics.IncAdmissionInFlight(h.path)
+	release := func() {
+		metrics.DecAdmissionInFlight(h.path)
+		finish()
+		h.inFlight.release()
+	}
+	if h.timeout <= 0 {
+		defer release()
+		return h.admit(ctx, ar), nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+	result := make(chan *admissionv1.AdmissionResponse, 1)
+	go func() {
+		defer release()
+		// The server does not recover panics of this goroutine
+		defer func() {
+			if p := recover(); p != nil {
+				klog.FromContext(ctx).Error(nil, "Admit function panicked", "path", h.path, "panic", p)
+				result <- &admissionv1.AdmissionResponse{Result: &metav1.Status{
+					Status:  metav1.StatusFailure,
+					Message: "admission hook failed",
+					Reason:  metav1.StatusReasonInternalError,
+					Code:    http.StatusInternalServerError,
+				}}
+			}
+		}()
+		result <- h.admit(ctx, ar)
+	}()
+	select {
+	case resp := <-result:
+		return resp, nil
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("timed out after %v", h.timeout)
+		}
+		return nil, fmt.Errorf("abandoned: %w", ctx.Err())
+	}
+}
+
+// timeoutResponse returns the response for requests not answered in time,
+// allowed for fail-open hooks.
+func (h *admissionHandler) timeoutResponse(err error) *admissionv1.AdmissionResponse {
+	message := fmt.Sprintf("admission hook %s %v", h.path, err)
+	if h.failOpen {
+		return &admissionv1.AdmissionResponse{
+			Allowed:  true,
+			Warnings: []string{message + ", request allowed without evaluation"},
+		}
+	}
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: message,
+			Reason:  metav1.StatusReasonTimeout,
+			Code:    http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// rejectTooLarge answers a request whose body exceeds limit, given the
+// prefix that was read. If the prefix holds the UID of the AdmissionReview, as
+// with the API server, which encodes it before the objects, the response is
+// an AdmissionReview allowing the request for fail-open hooks and denying it
+// otherwise; if not, the request fails with 413.
+func (h *admissionHandler) rejectTooLarge(w http.ResponseWriter, prefix []byte, limit int64) {
+	metrics.RecordAdmissionRejected(h.path, "body_too_large")
+	message := fmt.Sprintf("admission request body exceeds the limit of %d bytes of hook %s", limit, h.path)
+	apiVersion, uid, ok := peekAdmissionReview(prefix)
+	if !ok {
+		klog.Warningf("Rejecting admission request to %s: %s", h.path, message)
+		http.Error(w, message, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	klog.Warningf("Answering admission request %s without evaluation: %s", uid, message)
+	resp := &admissionv1.AdmissionResponse{UID: uid}
+	if h.failOpen {
+		resp.Allowed = true
+		resp.Warnings = []string{message + ", request allowed without evaluation"}
+	} else {
+		resp.Result = &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: message,
+			Reason:  metav1.StatusReasonRequestEntityTooLarge,
+			Code:    http.StatusRequestEntityTooLarge,
+		}
+	}
+	review := admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: apiVersion, Kind: "AdmissionReview"},
+		Response: resp,
+	}
+	out := getBuffer()
+	defer putBuffer(out)
+	if err := encodeAdmissionReview(out, review); err != nil {
+		klog.Errorf("Failed to marshal admission response: %v", err)
+		http.Error(w, message, http.StatusRequestEntityTooLarge)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(out.Bytes()); err != nil {
 		klog.Errorf("Failed to write admission response: %v", err)
 	}
 }
+
+// peekAdmissionReview reads the apiVersion and request UID from the prefix
+// of a JSON AdmissionReview, skipping the values before them. It returns
+// false if the prefix ends before the UID.
+func peekAdmissionReview(prefix []byte) (apiVersion string, uid types.UID, ok bool) {
+	apiVersion = admissionv1.SchemeGroupVersion.String()
+	dec := json.NewDecoder(bytes.NewReader(prefix))
+	// openObject consumes the opening brace of an object
+	openObject := func() bool {
+		tok, err := dec.Token()
+		return err == nil && tok == json.Delim('{')
+	}
+	// key returns the next key of the current object
+	key := func() (string, bool) {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", false
+		}
+		k, isKey := tok.(string)
+		return k, isKey
+	}
+	skip := func() bool {
+		var v json.RawMessage
+		return dec.Decode(&v) == nil
+	}
+
+	if !openObject() {
+		return "", "", false
+	}
+	for {
+		k, ok := key()
+		if !ok {
+			return "", "", false
+		}
+		switch k {
+		case "apiVersion":
+			if err := dec.Decode(&apiVersion); err != nil {
+				return "", "", false
+			}
+		case "request":
+			if !openObject() {
+				return "", "", false
+			}
+			for {
+				k, ok := key()
+				if !ok {
+					return "", "", false
+				}
+				if k == "uid" {
+					if err := dec.Decode(&uid); err != nil || uid == "" {
+						return "", "", false
+					}
+					return apiVersion, uid, true
+				}
+				if !skip() {
+					return "", "", false
+				}
+			}
+		default:
+			if !skip() {
+				return "", "", false
+			}
+		}
+	}
+}
+
+// patchOperations returns the number of operations of a JSON patch, or zero
+// if it cannot be decoded.
+func patchOperations(patch []byte) int {
+	var ops []json.RawMessage
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return 0
+	}
+	return len(ops)
+}
+
+// resourceName returns the resource of a request as "resource.group", with
+// the subresource appended after a slash, e.g. "deployments.apps/scale".
+func resourceName(req *admissionv1.AdmissionRequest) string {
+	name := req.Resource.Resource
+	if req.Resource.Group != "" {
+		name += "." + req.Resource.Group
+	}
+	if req.SubResource != "" {
+		name += "/" + req.SubResource
+	}
+	return name
+}
+
+// decision classifies an admission response as "allowed", "patched",
+// "denied" or "errored". Denials with a 5xx code, such as those of Errored,
+// count as errors.
+func decision(resp *admissionv1.AdmissionResponse) string {
+	switch {
+	case resp == nil:
+		return "errored"
+	case resp.Allowed && len(resp.Patch) > 0:
+		return "patched"
+	case resp.Allowed:
+		return "allowed"
+	case resp.Result != nil && resp.Result.Code >= http.StatusInternalServerError:
+		return "errored"
+	default:
+		return "denied"
+	}
+}
+
+// allowedWithoutEvaluation returns the response for requests of fail-open hooks
+// that are not evaluated because the server is overloaded.
+func allowedWithoutEvaluation() *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Allowed:  true,
+		Warnings: []string{"admission webhook overloaded, request allowed without evaluation"},
+	}
+}
+
+// supportedContentType returns true if the request media type can be decoded.
+// Media type parameters such as charset are ignored.
+func (h *admissionHandler) supportedContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "application/json":
+		return true
+	case "application/yaml":
+		return h.allowYAML
+	default:
+		return false
+	}
+}
+
+// decodeAdmissionReview decodes a v1 or v1beta1 admission review in a single
+// pass; reviews without apiVersion and kind are decoded as v1. A v1beta1
+// review is converted to v1 and keeps its APIVersion so the response can be
+// sent back in the same version.
+func decodeAdmissionReview(body []byte) (admissionv1.AdmissionReview, error) {
+	defaultGVK := admissionv1.SchemeGroupVersion.WithKind("AdmissionReview")
+	obj, _, err := codecs.UniversalDeserializer().Decode(body, &defaultGVK, nil)
+	if err != nil {
+		return admissionv1.AdmissionReview{}, err
+	}
+
+	switch review := obj.(type) {
+	case *admissionv1.AdmissionReview:
+		return *review, nil
+	case *admissionv1beta1.AdmissionReview:
+		return admissionv1.AdmissionReview{
+			TypeMeta: review.TypeMeta,
+			Request:  convertRequestFromV1beta1(review.Request),
+		}, nil
+	default:
+		return admissionv1.AdmissionReview{}, fmt.Errorf("unexpected object %T, expected AdmissionReview", obj)
+	}
+}
+
+// encodeAdmissionReview encodes an admission review into buf in the version
+// set in its APIVersion.
+func encodeAdmissionReview(buf *bytes.Buffer, review admissionv1.AdmissionReview) error {
+	encoder := json.NewEncoder(buf)
+	if review.APIVersion == admissionv1beta1.SchemeGroupVersion.String() {
+		return encoder.Encode(admissionv1beta1.AdmissionReview{
+			TypeMeta: review.TypeMeta,
+			Response: convertResponseToV1beta1(review.Response),
+		})
+	}
+	return encoder.Encode(review)
+}
+
+// isNamespaceRequest returns true if the request is about a Namespace object,
+// whose own name the API server sends as the request namespace.
+func isNamespaceRequest(req *admissionv1.AdmissionRequest) bool {
+	return req.Kind.Group == "" && req.Kind.Kind == "Namespace"
+}
@@ -2,21 +2,29 @@ package server
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	admissionv1 "k8s.io/api/admission/v1"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
 )
 
 func TestAdmissionHandler_ServeHTTP(t *testing.T) {
 	t.Run("successful admission", func(t *testing.T) {
-		handler := newAdmissionHandler(func(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		handler := newAdmissionHandler(func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
 			return &admissionv1.AdmissionResponse{
 				Allowed: true,
 			}
@@ -49,7 +57,7 @@ func TestAdmissionHandler_ServeHTTP(t *testing.T) {
 	})
 
 	t.Run("denied admission", func(t *testing.T) {
-		handler := newAdmissionHandler(func(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		handler := newAdmissionHandler(func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
 			return &admissionv1.AdmissionResponse{
 				Allowed: false,
 				Result: &metav1.Status{
@@ -82,7 +90,7 @@ func TestAdmissionHandler_ServeHTTP(t *testing.T) {
 	})
 
 	t.Run("empty body", func(t *testing.T) {
-		handler := newAdmissionHandler(func(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		handler := newAdmissionHandler(func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
 			return &admissionv1.AdmissionResponse{Allowed: true}
 		})
 
@@ -98,7 +106,7 @@ func TestAdmissionHandler_ServeHTTP(t *testing.T) {
 	})
 
 	t.Run("wrong content type", func(t *testing.T) {
-		handler := newAdmissionHandler(func(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		handler := newAdmissionHandler(func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
 			return &admissionv1.AdmissionResponse{Allowed: true}
 		})
 
@@ -114,7 +122,7 @@ func TestAdmissionHandler_ServeHTTP(t *testing.T) {
 	})
 
 	t.Run("invalid JSON", func(t *testing.T) {
-		handler := newAdmissionHandler(func(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		handler := newAdmissionHandler(func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
 			return &admissionv1.AdmissionResponse{Allowed: true}
 		})
 
@@ -130,7 +138,7 @@ func TestAdmissionHandler_ServeHTTP(t *testing.T) {
 	})
 
 	t.Run("nil request in review", func(t *testing.T) {
-		handler := newAdmissionHandler(func(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		handler := newAdmissionHandler(func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
 			return &admissionv1.AdmissionResponse{Allowed: true}
 		})
 
@@ -164,7 +172,7 @@ func TestAdmissionHandler_ServeHTTP(t *testing.T) {
 	})
 
 	t.Run("preserves API version", func(t *testing.T) {
-		handler := newAdmissionHandler(func(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		handler := newAdmissionHandler(func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
 			return &admissionv1.AdmissionResponse{Allowed: true}
 		})
 
@@ -191,7 +199,7 @@ func TestAdmissionHandler_ServeHTTP(t *testing.T) {
 
 func TestAdmissionHandler_WithPatch(t *testing.T) {
 	patchType := admissionv1.PatchTypeJSONPatch
-	handler := newAdmissionHandler(func(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+	handler := newAdmissionHandler(func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
 		return &admissionv1.AdmissionResponse{
 			Allowed:   true,
 			Patch:     []byte(`[{"op":"add","path":"/metadata/labels/test","value":"true"}]`),
@@ -224,6 +232,801 @@ func TestAdmissionHandler_WithPatch(t *testing.T) {
 	}
 }
 
+func TestDecision(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *admissionv1.AdmissionResponse
+		want string
+	}{
+		{name: "allowed", resp: &admissionv1.AdmissionResponse{Allowed: true}, want: "allowed"},
+		{name: "patched", resp: &admissionv1.AdmissionResponse{Allowed: true, Patch: []byte(`[]`)}, want: "patched"},
+		{name: "denied", resp: &admissionv1.AdmissionResponse{Result: &metav1.Status{Code: http.StatusForbidden}}, want: "denied"},
+		{name: "denied without status", resp: &admissionv1.AdmissionResponse{}, want: "denied"},
+		{name: "errored", resp: &admissionv1.AdmissionResponse{Result: &metav1.Status{Code: http.StatusInternalServerError}}, want: "errored"},
+		{name: "nil", want: "errored"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decision(tt.resp); got != tt.want {
+				t.Errorf("decision() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatchOperations(t *testing.T) {
+	tests := []struct {
+		patch string
+		want  int
+	}{
+		{patch: `[{"op":"add","path":"/metadata/labels/a","value":"1"},{"op":"remove","path":"/metadata/labels/b"}]`, want: 2},
+		{patch: `[]`, want: 0},
+		{patch: `not json`, want: 0},
+	}
+	for _, tt := range tests {
+		if got := patchOperations([]byte(tt.patch)); got != tt.want {
+			t.Errorf("patchOperations(%s) = %d, want %d", tt.patch, got, tt.want)
+		}
+	}
+}
+
+func TestResourceName(t *testing.T) {
+	tests := []struct {
+		req  admissionv1.AdmissionRequest
+		want string
+	}{
+		{req: admissionv1.AdmissionRequest{Resource: metav1.GroupVersionResource{Version: "v1", Resource: "pods"}}, want: "pods"},
+		{req: admissionv1.AdmissionRequest{Resource: metav1.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}}, want: "deployments.apps"},
+		{req: admissionv1.AdmissionRequest{Resource: metav1.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, SubResource: "scale"}, want: "deployments.apps/scale"},
+	}
+	for _, tt := range tests {
+		if got := resourceName(&tt.req); got != tt.want {
+			t.Errorf("resourceName() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestAdmissionHandler_V1beta1(t *testing.T) {
+	patchType := admissionv1.PatchTypeJSONPatch
+	var got admissionv1.AdmissionReview
+	handler := newAdmissionHandler(func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		got = ar
+		return &admissionv1.AdmissionResponse{
+			Allowed:   true,
+			Patch:     []byte(`[{"op":"add","path":"/metadata/labels/test","value":"true"}]`),
+			PatchType: &patchType,
+			Warnings:  []string{"deprecated"},
+		}
+	})
+
+	review := admissionv1beta1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "admission.k8s.io/v1beta1",
+			Kind:       "AdmissionReview",
+		},
+		Request: &admissionv1beta1.AdmissionRequest{
+			UID:       "test-uid",
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			Resource:  metav1.GroupVersionResource{Version: "v1", Resource: "pods"},
+			Name:      "test-pod",
+			Namespace: "default",
+			Operation: admissionv1beta1.Update,
+			Object:    runtime.RawExtension{Raw: []byte(`{"metadata":{"name":"test-pod"}}`)},
+		},
+	}
+	body, _ := json.Marshal(review)
+
+	req := httptest.NewRequest(http.MethodPost, "/mutate", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	if got.Request == nil {
+		t.Fatal("Expected admit function to receive the request")
+	}
+	if got.Request.Operation != admissionv1.Update || got.Request.Name != "test-pod" {
+		t.Errorf("Request: got %s %s, want UPDATE test-pod", got.Request.Operation, got.Request.Name)
+	}
+	if string(got.Request.Object.Raw) != `{"metadata":{"name":"test-pod"}}` {
+		t.Errorf("Object: got %s", got.Request.Object.Raw)
+	}
+
+	var resp admissionv1beta1.AdmissionReview
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if resp.APIVersion != "admission.k8s.io/v1beta1" {
+		t.Errorf("Expected APIVersion %q, got %q", "admission.k8s.io/v1beta1", resp.APIVersion)
+	}
+	if resp.Response == nil || !resp.Response.Allowed {
+		t.Fatal("Expected Allowed=true")
+	}
+	if resp.Response.UID != "test-uid" {
+		t.Errorf("Expected UID %q, got %q", "test-uid", resp.Response.UID)
+	}
+	if resp.Response.PatchType == nil || *resp.Response.PatchType != admissionv1beta1.PatchTypeJSONPatch {
+		t.Error("Expected PatchType=JSONPatch")
+	}
+	if len(resp.Response.Warnings) != 1 {
+		t.Errorf("Expected 1 warning, got %v", resp.Response.Warnings)
+	}
+}
+
+func TestAdmissionHandler_MethodNotAllowed(t *testing.T) {
+	called := false
+	handler := newAdmissionHandler(func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		called = true
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	})
+
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete} {
+		t.Run(method, func(t *testing.T) {
+			req := httptest.NewRequest(method, "/validate", nil)
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusMethodNotAllowed {
+				t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, rec.Code)
+			}
+			if allow := rec.Header().Get("Allow"); allow != http.MethodPost {
+				t.Errorf("Allow: got %q, want %q", allow, http.MethodPost)
+			}
+		})
+	}
+
+	if called {
+		t.Error("Admit function should not be called for non-POST requests")
+	}
+}
+
+func TestAdmissionHandler_RequireClientCert(t *testing.T) {
+	handler := newAdmissionHandler(func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	})
+	handler.requireClientCert = true
+
+	newRequest := func() *http.Request {
+		body, _ := json.Marshal(createAdmissionReview("test-uid", nil))
+		req := httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+
+	t.Run("without TLS", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, newRequest())
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, rec.Code)
+		}
+	})
+
+	t.Run("without client certificate", func(t *testing.T) {
+		req := newRequest()
+		req.TLS = &tls.ConnectionState{}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, rec.Code)
+		}
+	})
+
+	t.Run("with client certificate", func(t *testing.T) {
+		req := newRequest()
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{}}}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+	})
+}
+
+// stubAuthenticator authenticates requests whose Authorization header is "Bearer good".
+type stubAuthenticator struct {
+	err error
+}
+
+func (a stubAuthenticator) AuthenticateRequest(r *http.Request) (string, bool, error) {
+	if a.err != nil {
+		return "", false, a.err
+	}
+	return "proxy", r.Header.Get("Authorization") == "Bearer good", nil
+}
+
+func TestAdmissionHandler_Authenticator(t *testing.T) {
+	tests := []struct {
+		name          string
+		authenticator stubAuthenticator
+		authorization string
+		wantCode      int
+	}{
+		{name: "authenticated", authorization: "Bearer good", wantCode: http.StatusOK},
+		{name: "no token", wantCode: http.StatusUnauthorized},
+		{name: "invalid token", authorization: "Bearer bad", wantCode: http.StatusUnauthorized},
+		{name: "review error", authenticator: stubAuthenticator{err: fmt.Errorf("unavailable")}, authorization: "Bearer good", wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			handler := newAdmissionHandler(func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+				called = true
+				return &admissionv1.AdmissionResponse{Allowed: true}
+			})
+			handler.authenticator = tt.authenticator
+
+			body, _ := json.Marshal(createAdmissionReview("test-uid", nil))
+			req := httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("Expected status %d, got %d", tt.wantCode, rec.Code)
+			}
+			if called != (tt.wantCode == http.StatusOK) {
+				t.Errorf("Admit called: got %v", called)
+			}
+			if tt.wantCode == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestAdmissionHandler_MaxInFlight(t *testing.T) {
+	for _, failOpen := range []bool{false, true} {
+		t.Run(fmt.Sprintf("failOpen=%v", failOpen), func(t *testing.T) {
+			started := make(chan struct{})
+			unblock := make(chan struct{})
+			handler := newAdmissionHandler(func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+				if ar.Request.UID == "blocking" {
+					close(started)
+					<-unblock
+				}
+				return &admissionv1.AdmissionResponse{Allowed: true}
+			})
+			handler.path = "/validate"
+			handler.inFlight = newInFlightLimiter(1)
+			handler.failOpen = failOpen
+
+			serve := func(uid string) *httptest.ResponseRecorder {
+				body, _ := json.Marshal(createAdmissionReview(uid, nil))
+				req := httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body))
+				req.Header.Set("Content-Type", "application/json")
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				return rec
+			}
+
+			done := make(chan *httptest.ResponseRecorder)
+			go func() { done <- serve("blocking") }()
+			<-started
+
+			rec := serve("overflow")
+			if failOpen {
+				if rec.Code != http.StatusOK {
+					t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+				}
+				var resp admissionv1.AdmissionReview
+				if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("Failed to unmarshal response: %v", err)
+				}
+				if !resp.Response.Allowed || len(resp.Response.Warnings) != 1 {
+					t.Errorf("Expected allowed response with a warning, got %+v", resp.Response)
+				}
+				if resp.Response.UID != "overflow" {
+					t.Errorf("Expected UID %q, got %q", "overflow", resp.Response.UID)
+				}
+			} else {
+				if rec.Code != http.StatusServiceUnavailable {
+					t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+				}
+				if rec.Header().Get("Retry-After") == "" {
+					t.Error("Expected Retry-After header")
+				}
+			}
+
+			close(unblock)
+			if rec := <-done; rec.Code != http.StatusOK {
+				t.Errorf("Expected blocking request status %d, got %d", http.StatusOK, rec.Code)
+			}
+
+			// The slot is released once the first request completes.
+			if rec := serve("after"); rec.Code != http.StatusOK {
+				t.Errorf("Expected status %d after release, got %d", http.StatusOK, rec.Code)
+			}
+		})
+	}
+}
+
+func TestAdmissionHandler_RequestInfo(t *testing.T) {
+	var auditID string
+	var uid types.UID
+	handler := newAdmissionHandler(func(ctx context.Context, _ admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		auditID, uid = AuditID(ctx), RequestUID(ctx)
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	})
+
+	body, _ := json.Marshal(createAdmissionReview("test-uid", []byte(`{}`)))
+	req := httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(AuditIDHeader, "a1b2c3")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if auditID != "a1b2c3" || uid != "test-uid" {
+		t.Errorf("Expected audit ID %q and UID %q, got %q and %q", "a1b2c3", "test-uid", auditID, uid)
+	}
+	if AuditID(context.Background()) != "" || RequestUID(context.Background()) != "" {
+		t.Error("Expected no request info outside of a request")
+	}
+}
+
+func TestAdmissionHandler_Timeout(t *testing.T) {
+	for _, failOpen := range []bool{false, true} {
+		t.Run(fmt.Sprintf("failOpen=%v", failOpen), func(t *testing.T) {
+			cancelled := make(chan struct{})
+			unblock := make(chan struct{})
+			handler := newAdmissionHandler(func(ctx context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+				if ar.Request.UID == "slow" {
+					<-ctx.Done()
+					close(cancelled)
+					<-unblock
+				}
+				return &admissionv1.AdmissionResponse{Allowed: true}
+			})
+			handler.path = "/validate"
+			handler.inFlight = newInFlightLimiter(1)
+			handler.failOpen = failOpen
+			handler.timeout = 10 * time.Millisecond
+
+			serve := func(uid string) (*httptest.ResponseRecorder, *admissionv1.AdmissionResponse) {
+				body, _ := json.Marshal(createAdmissionReview(uid, nil))
+				req := httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body))
+				req.Header.Set("Content-Type", "application/json")
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				var resp admissionv1.AdmissionReview
+				if rec.Code == http.StatusOK {
+					if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+						t.Fatalf("Failed to unmarshal response: %v", err)
+					}
+				}
+				return rec, resp.Response
+			}
+
+			_, resp := serve("slow")
+			<-cancelled
+			switch {
+			case resp == nil || resp.UID != "slow":
+				t.Fatalf("Expected a response to the slow request, got %+v", resp)
+			case failOpen && (!resp.Allowed || len(resp.Warnings) != 1):
+				t.Errorf("Expected an allowed response with a warning, got %+v", resp)
+			case !failOpen && (resp.Allowed || resp.Result.Code != http.StatusGatewayTimeout || resp.Result.Reason != metav1.StatusReasonTimeout):
+				t.Errorf("Expected a 504 Timeout denial, got %+v", resp)
+			}
+
+			// The slow admit function keeps its slot until it returns
+			if rec, _ := serve("blocked"); !failOpen && rec.Code != http.StatusServiceUnavailable {
+				t.Errorf("Expected status %d while the slot is held, got %d", http.StatusServiceUnavailable, rec.Code)
+			}
+			close(unblock)
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				_, resp := serve("after")
+				if resp != nil && resp.Allowed && len(resp.Warnings) == 0 {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatal("Timed out waiting for the slot to be released")
+				}
+				time.Sleep(time.Millisecond)
+			}
+		})
+	}
+
+	t.Run("panic", func(t *testing.T) {
+		handler := newAdmissionHandler(func(context.Context, admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+			panic("boom")
+		})
+		handler.path = "/validate"
+		handler.timeout = time.Second
+
+		body, _ := json.Marshal(createAdmissionReview("test-uid", nil))
+		req := httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var resp admissionv1.AdmissionReview
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if resp.Response.Allowed || resp.Response.Result.Code != http.StatusInternalServerError {
+			t.Errorf("Expected an internal error, got %+v", resp.Response)
+		}
+	})
+}
+
+func TestAdmissionHandler_MaxBodySize(t *testing.T) {
+	object := []byte(`{"apiVersion":"v1","kind":"ConfigMap","data":{"big":"` + strings.Repeat("x", 2048) + `"}}`)
+	for _, failOpen := range []bool{false, true} {
+		t.Run(fmt.Sprintf("failOpen=%v", failOpen), func(t *testing.T) {
+			handler := newAdmissionHandler(func(context.Context, admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+				t.Error("Admit function called for an oversized request")
+				return &admissionv1.AdmissionResponse{Allowed: true}
+			})
+			handler.path = "/validate"
+			handler.failOpen = failOpen
+			handler.maxBodySize = 1024
+
+			body, _ := json.Marshal(createAdmissionReview("big-uid", object))
+			req := httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+			}
+			var resp admissionv1.AdmissionReview
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			switch {
+			case resp.Response.UID != "big-uid":
+				t.Errorf("Expected UID %q, got %q", "big-uid", resp.Response.UID)
+			case failOpen && (!resp.Response.Allowed || len(resp.Response.Warnings) != 1):
+				t.Errorf("Expected an allowed response with a warning, got %+v", resp.Response)
+			case !failOpen && (resp.Response.Allowed || resp.Response.Result.Code != http.StatusRequestEntityTooLarge):
+				t.Errorf("Expected a 413 denial, got %+v", resp.Response)
+			}
+		})
+	}
+
+	t.Run("within the limit", func(t *testing.T) {
+		handler := newAdmissionHandler(func(context.Context, admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+			return &admissionv1.AdmissionResponse{Allowed: true}
+		})
+		handler.maxBodySize = 1 << 20
+		body, _ := json.Marshal(createAdmissionReview("test-uid", object))
+		req := httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"allowed":true`) {
+			t.Errorf("Got status %d and body %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("no UID before the limit", func(t *testing.T) {
+		handler := newAdmissionHandler(nil)
+		handler.maxBodySize = 16
+		req := httptest.NewRequest(http.MethodPost, "/validate", strings.NewReader(`{"request":{"object":{"data":"xxxxxxxx"},"uid":"late"}}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected status %d, got %d", http.StatusRequestEntityTooLarge, rec.Code)
+		}
+	})
+}
+
+func TestAdmissionHandler_ResponseCache(t *testing.T) {
+	calls := 0
+	handler := newAdmissionHandler(func(context.Context, admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		calls++
+		return &admissionv1.AdmissionResponse{Allowed: true, Warnings: []string{"evaluated"}}
+	})
+	handler.path = "/validate"
+	handler.cache = newResponseCache(time.Minute)
+	now := time.Now()
+	handler.cache.now = func() time.Time { return now }
+
+	serve := func(uid, object string) admissionv1.AdmissionReview {
+		t.Helper()
+		body, _ := json.Marshal(createAdmissionReview(uid, []byte(object)))
+		req := httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var resp admissionv1.AdmissionReview
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		return resp
+	}
+
+	steps := []struct {
+		name      string
+		uid       string
+		object    string
+		advance   time.Duration
+		wantCalls int
+	}{
+		{"first request", "uid-1", `{"kind":"Pod"}`, 0, 1},
+		{"retry", "uid-1", `{"kind":"Pod"}`, 0, 1},
+		{"reinvocation with a changed object", "uid-1", `{"kind":"Pod","metadata":{"labels":{"a":"b"}}}`, 0, 2},
+		{"other request", "uid-2", `{"kind":"Pod"}`, 0, 3},
+		{"retry after expiry", "uid-1", `{"kind":"Pod"}`, time.Minute, 4},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		resp := serve(step.uid, step.object)
+		if calls != step.wantCalls {
+			t.Errorf("%s: expected %d calls, got %d", step.name, step.wantCalls, calls)
+		}
+		if string(resp.Response.UID) != step.uid || !resp.Response.Allowed || len(resp.Response.Warnings) != 1 {
+			t.Errorf("%s: unexpected response %+v", step.name, resp.Response)
+		}
+	}
+}
+
+func TestPeekAdmissionReview(t *testing.T) {
+	tests := []struct {
+		name           string
+		prefix         string
+		wantAPIVersion string
+		wantUID        types.UID
+		wantOK         bool
+	}{
+		{"v1", `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","request":{"uid":"a","kind":{"kind":"Pod"},"object":{"da`, "admission.k8s.io/v1", "a", true},
+		{"v1beta1", `{"apiVersion":"admission.k8s.io/v1beta1","request":{"uid":"b","obj`, "admission.k8s.io/v1beta1", "b", true},
+		{"uid after other fields", `{"request":{"kind":{"group":"","kind":"Pod"},"uid":"c","object":{`, "admission.k8s.io/v1", "c", true},
+		{"truncated before uid", `{"request":{"object":{"data":"xx`, "", "", false},
+		{"not an object", `[1,2`, "", "", false},
+		{"no request", `{"kind":"AdmissionReview"}`, "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiVersion, uid, ok := peekAdmissionReview([]byte(tt.prefix))
+			if apiVersion != tt.wantAPIVersion || uid != tt.wantUID || ok != tt.wantOK {
+				t.Errorf("peekAdmissionReview() = %q, %q, %v, want %q, %q, %v", apiVersion, uid, ok, tt.wantAPIVersion, tt.wantUID, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestAdmissionHandler_RateLimit(t *testing.T) {
+	handler := newAdmissionHandler(func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	})
+	handler.path = "/validate"
+	handler.rateLimiter = newRequestRateLimiter(RateLimitConfig{QPS: 0.5, Burst: 1})
+
+	codes := make([]int, 0, 2)
+	for i := 0; i < 2; i++ {
+		body, _ := json.Marshal(createAdmissionReview("test-uid", nil))
+		req := httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		codes = append(codes, rec.Code)
+
+		if rec.Code == http.StatusTooManyRequests && rec.Header().Get("Retry-After") != "2" {
+			t.Errorf("Retry-After: got %q, want %q", rec.Header().Get("Retry-After"), "2")
+		}
+	}
+
+	if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests {
+		t.Errorf("Status codes: got %v, want [%d %d]", codes, http.StatusOK, http.StatusTooManyRequests)
+	}
+}
+
+func TestAdmissionHandler_Disabled(t *testing.T) {
+	handler := newAdmissionHandler(func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		t.Error("Admit function of a disabled hook was called")
+		return &admissionv1.AdmissionResponse{Allowed: false}
+	})
+	handler.path = "/validate"
+	handler.disabled = true
+
+	body, _ := json.Marshal(createAdmissionReview("test-uid", nil))
+	req := httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var resp admissionv1.AdmissionReview
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if resp.Response == nil || !resp.Response.Allowed || resp.Response.UID != "test-uid" {
+		t.Errorf("Expected the request to be allowed, got %+v", resp.Response)
+	}
+}
+
+func TestAdmissionHandler_SelfTestHeaderNotTrusted(t *testing.T) {
+	handler := newAdmissionHandler(func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		t.Error("Admit function was called for an unauthenticated request")
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	})
+	handler.path = "/validate"
+	handler.requireClientCert = true
+
+	// Self-tests carry no special header that skips authentication
+	body, _ := json.Marshal(createAdmissionReview("self-test-uid", nil))
+	req := httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Self-Test", "true")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, rec.Code)
+	}
+}
+
+func TestAdmissionHandler_ExcludeNamespaces(t *testing.T) {
+	s := New(nil, Config{HealthzPath: "/healthz", ReadyzPath: "/readyz", ExcludeNamespaces: []string{"kube-system"}})
+	var called []string
+	s.RegisterHook("/validate", "Validating", func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		called = append(called, ar.Request.Namespace)
+		return &admissionv1.AdmissionResponse{Allowed: false}
+	}, HookOptions{})
+
+	allowed := make(map[string]bool)
+	for _, ns := range []string{"kube-system", "default"} {
+		review := createAdmissionReview("test-uid", nil)
+		review.Request.Namespace = ns
+		body, _ := json.Marshal(review)
+		req := httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		s.mux.ServeHTTP(rec, req)
+
+		var resp admissionv1.AdmissionReview
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		allowed[ns] = resp.Response.Allowed
+	}
+
+	if !allowed["kube-system"] || allowed["default"] {
+		t.Errorf("Allowed: got %v, want only kube-system", allowed)
+	}
+	if len(called) != 1 || called[0] != "default" {
+		t.Errorf("Admit called for %v, want only default", called)
+	}
+}
+
+func TestAdmissionHandler_ExcludeNamespaces_NamespaceObject(t *testing.T) {
+	s := New(nil, Config{HealthzPath: "/healthz", ReadyzPath: "/readyz", ExcludeNamespaces: []string{"kube-system"}})
+	called := false
+	s.RegisterHook("/validate", "Validating", func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		called = true
+		return &admissionv1.AdmissionResponse{Allowed: false}
+	}, HookOptions{})
+
+	// Deleting the excluded namespace itself is evaluated
+	review := createAdmissionReview("test-uid", nil)
+	review.Request.Kind = metav1.GroupVersionKind{Version: "v1", Kind: "Namespace"}
+	review.Request.Resource = metav1.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	review.Request.Operation = admissionv1.Delete
+	review.Request.Name = "kube-system"
+	review.Request.Namespace = "kube-system"
+	body, _ := json.Marshal(review)
+	req := httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, req)
+
+	var resp admissionv1.AdmissionReview
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if !called || resp.Response.Allowed {
+		t.Errorf("Expected the admit function to deny the deletion, got called=%v allowed=%v", called, resp.Response.Allowed)
+	}
+}
+
+func TestAdmissionHandler_LoadShedding(t *testing.T) {
+	shedder := newLoadShedder(LoadSheddingConfig{InFlight: 1})
+	done := shedder.start() // simulate one request in flight
+	defer done()
+
+	for _, failOpen := range []bool{false, true} {
+		t.Run(fmt.Sprintf("failOpen=%v", failOpen), func(t *testing.T) {
+			called := false
+			handler := newAdmissionHandler(func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+				called = true
+				return &admissionv1.AdmissionResponse{Allowed: false}
+			})
+			handler.path = "/validate"
+			handler.loadShedder = shedder
+			handler.failOpen = failOpen
+
+			body, _ := json.Marshal(createAdmissionReview("test-uid", nil))
+			req := httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			var resp admissionv1.AdmissionReview
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+
+			if failOpen {
+				if called {
+					t.Error("Expected fail-open hook to be shed")
+				}
+				if !resp.Response.Allowed || len(resp.Response.Warnings) != 1 {
+					t.Errorf("Expected allowed response with a warning, got %+v", resp.Response)
+				}
+			} else {
+				if !called {
+					t.Error("Expected fail-closed hook to be evaluated")
+				}
+				if resp.Response.Allowed {
+					t.Error("Expected the hook's response")
+				}
+			}
+		})
+	}
+}
+
+func TestAdmissionHandler_ContentType(t *testing.T) {
+	review := createAdmissionReview("test-uid", nil)
+	jsonBody, _ := json.Marshal(review)
+	yamlBody, _ := yaml.Marshal(review)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		allowYAML   bool
+		wantCode    int
+	}{
+		{name: "json", contentType: "application/json", body: jsonBody, wantCode: http.StatusOK},
+		{name: "json with charset", contentType: "application/json; charset=utf-8", body: jsonBody, wantCode: http.StatusOK},
+		{name: "json upper case", contentType: "Application/JSON", body: jsonBody, wantCode: http.StatusOK},
+		{name: "missing", contentType: "", body: jsonBody, wantCode: http.StatusUnsupportedMediaType},
+		{name: "malformed", contentType: "application/json; charset", body: jsonBody, wantCode: http.StatusUnsupportedMediaType},
+		{name: "yaml not allowed", contentType: "application/yaml", body: yamlBody, wantCode: http.StatusUnsupportedMediaType},
+		{name: "yaml allowed", contentType: "application/yaml", body: yamlBody, allowYAML: true, wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newAdmissionHandler(func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+				return &admissionv1.AdmissionResponse{Allowed: true}
+			})
+			handler.allowYAML = tt.allowYAML
+
+			req := httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var resp admissionv1.AdmissionReview
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if resp.Response == nil || resp.Response.UID != "test-uid" {
+				t.Errorf("Expected response for UID %q, got %+v", "test-uid", resp.Response)
+			}
+		})
+	}
+}
+
 // Helper to create an AdmissionReview
 func createAdmissionReview(uid string, object []byte) admissionv1.AdmissionReview {
 	review := admissionv1.AdmissionReview{
@@ -258,7 +1061,7 @@ func createAdmissionReview(uid string, object []byte) admissionv1.AdmissionRevie
 
 // Test error reading body
 func TestAdmissionHandler_ReadBodyError(t *testing.T) {
-	handler := newAdmissionHandler(func(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+	handler := newAdmissionHandler(func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
 		return &admissionv1.AdmissionResponse{Allowed: true}
 	})
 
@@ -282,3 +1085,70 @@ type errorReader struct {
 func (e *errorReader) Read(p []byte) (n int, err error) {
 	return 0, e.err
 }
+
+// benchmarkPod is a representative pod object of a few kilobytes.
+func benchmarkPod(b *testing.B) []byte {
+	b.Helper()
+	containers := make([]map[string]interface{}, 0, 3)
+	for i := 0; i < 3; i++ {
+		containers = append(containers, map[string]interface{}{
+			"name":  fmt.Sprintf("container-%d", i),
+			"image": "registry.example.com/team/app:v1.2.3",
+			"env": []map[string]string{
+				{"name": "LOG_LEVEL", "value": "info"},
+				{"name": "REGION", "value": "eu-west-1"},
+			},
+			"resources": map[string]interface{}{
+				"requests": map[string]string{"cpu": "100m", "memory": "128Mi"},
+				"limits":   map[string]string{"cpu": "500m", "memory": "512Mi"},
+			},
+		})
+	}
+	pod, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata": map[string]interface{}{
+			"name":        "bench",
+			"namespace":   "default",
+			"labels":      map[string]string{"app": "bench", "tier": "backend"},
+			"annotations": map[string]string{"example.com/owner": "team"},
+		},
+		"spec": map[string]interface{}{"containers": containers},
+	})
+	if err != nil {
+		b.Fatalf("Failed to marshal pod: %v", err)
+	}
+	return pod
+}
+
+func BenchmarkAdmissionHandler_ServeHTTP(b *testing.B) {
+	patchType := admissionv1.PatchTypeJSONPatch
+	handler := newAdmissionHandler(func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		return &admissionv1.AdmissionResponse{
+			Allowed:   true,
+			Patch:     []byte(`[{"op":"add","path":"/metadata/labels/injected","value":"true"}]`),
+			PatchType: &patchType,
+		}
+	})
+	handler.path = "/mutate"
+
+	body, _ := json.Marshal(createAdmissionReview("bench-uid", benchmarkPod(b)))
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(body)))
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		reader := bytes.NewReader(body)
+		for pb.Next() {
+			reader.Reset(body)
+			req := httptest.NewRequest(http.MethodPost, "/mutate", reader)
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				b.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+			}
+		}
+	})
+}
@@ -0,0 +1,37 @@
+package server
+
+// inFlightLimiter caps the number of admission requests handled concurrently.
+// A nil limiter does not limit.
+type inFlightLimiter struct {
+	sem chan struct{}
+}
+
+// newInFlightLimiter creates a limiter allowing max concurrent requests.
+// It returns nil if max is zero or negative.
+func newInFlightLimiter(max int) *inFlightLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &inFlightLimiter{sem: make(chan struct{}, max)}
+}
+
+// tryAcquire reserves a slot without blocking and reports whether it succeeded.
+func (l *inFlightLimiter) tryAcquire() bool {
+	if l == nil {
+		return true
+	}
+	select {
+	case l.sem <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// release frees a slot reserved by tryAcquire.
+func (l *inFlightLimiter) release() {
+	if l == nil {
+		return
+	}
+	<-l.sem
+}
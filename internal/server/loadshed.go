@@ -0,0 +1,104 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// loadShedWindow is the window over which the average admission latency is measured.
+const loadShedWindow = time.Second
+
+// LoadSheddingConfig holds load shedding thresholds. Load shedding only
+// applies to fail-open hooks: while the server is overloaded their requests
+// are allowed without evaluation, leaving capacity for fail-closed hooks.
+type LoadSheddingConfig struct {
+	// InFlight is the number of concurrently handled requests at which the
+	// server is considered overloaded. Zero disables this threshold.
+	InFlight int
+
+	// Latency is the average admission latency over the last second at which
+	// the server is considered overloaded. Zero disables this threshold.
+	Latency time.Duration
+}
+
+// loadShedder tracks server load. A nil shedder never reports overload.
+type loadShedder struct {
+	config LoadSheddingConfig
+	now    func() time.Time
+
+	mu          sync.Mutex
+	inFlight    int
+	windowStart time.Time
+	windowSum   time.Duration
+	windowCount int
+	// lastAverage is the average latency of the previous window, or zero if
+	// it had no requests.
+	lastAverage time.Duration
+}
+
+// newLoadShedder creates a load shedder. It returns nil if both thresholds are disabled.
+func newLoadShedder(config LoadSheddingConfig) *loadShedder {
+	if config.InFlight <= 0 && config.Latency <= 0 {
+		return nil
+	}
+	return &loadShedder{config: config, now: time.Now}
+}
+
+// overloaded reports whether requests of fail-open hooks should be shed.
+func (l *loadShedder) overloaded() bool {
+	if l == nil {
+		return false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.config.InFlight > 0 && l.inFlight >= l.config.InFlight {
+		return true
+	}
+	l.rotate(l.now())
+	return l.config.Latency > 0 && l.lastAverage >= l.config.Latency
+}
+
+// start records the start of an admission request and returns a function
+// recording its end.
+func (l *loadShedder) start() func() {
+	if l == nil {
+		return func() {}
+	}
+
+	l.mu.Lock()
+	l.inFlight++
+	l.mu.Unlock()
+
+	started := l.now()
+	return func() {
+		now := l.now()
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		l.inFlight--
+		l.rotate(now)
+		l.windowSum += now.Sub(started)
+		l.windowCount++
+	}
+}
+
+// rotate starts a new latency window once the current one has elapsed.
+// A window without requests resets the average, so the server recovers even
+// when all traffic is being shed.
+func (l *loadShedder) rotate(now time.Time) {
+	elapsed := now.Sub(l.windowStart)
+	if elapsed < loadShedWindow {
+		return
+	}
+
+	l.lastAverage = 0
+	if l.windowCount > 0 && elapsed < 2*loadShedWindow {
+		l.lastAverage = l.windowSum / time.Duration(l.windowCount)
+	}
+	l.windowStart = now
+	l.windowSum = 0
+	l.windowCount = 0
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadShedder_Disabled(t *testing.T) {
+	shedder := newLoadShedder(LoadSheddingConfig{})
+	if shedder != nil {
+		t.Fatal("Expected nil shedder when thresholds are disabled")
+	}
+	done := shedder.start()
+	done()
+	if shedder.overloaded() {
+		t.Error("Expected nil shedder to never report overload")
+	}
+}
+
+func TestLoadShedder_InFlight(t *testing.T) {
+	shedder := newLoadShedder(LoadSheddingConfig{InFlight: 2})
+
+	first := shedder.start()
+	if shedder.overloaded() {
+		t.Error("Expected no overload with 1 request in flight")
+	}
+	second := shedder.start()
+	if !shedder.overloaded() {
+		t.Error("Expected overload with 2 requests in flight")
+	}
+
+	second()
+	first()
+	if shedder.overloaded() {
+		t.Error("Expected no overload after requests completed")
+	}
+}
+
+func TestLoadShedder_Latency(t *testing.T) {
+	now := time.Now()
+	shedder := newLoadShedder(LoadSheddingConfig{Latency: 100 * time.Millisecond})
+	shedder.now = func() time.Time { return now }
+
+	// Start the first window.
+	if shedder.overloaded() {
+		t.Fatal("Expected no overload without requests")
+	}
+
+	// Two slow requests in the first window.
+	for i := 0; i < 2; i++ {
+		done := shedder.start()
+		now = now.Add(200 * time.Millisecond)
+		done()
+	}
+	if shedder.overloaded() {
+		t.Error("Expected no overload before the window completes")
+	}
+
+	now = now.Add(loadShedWindow)
+	if !shedder.overloaded() {
+		t.Error("Expected overload after a slow window")
+	}
+
+	// A window without requests resets the average.
+	now = now.Add(loadShedWindow)
+	if shedder.overloaded() {
+		t.Error("Expected recovery after an idle window")
+	}
+
+	// Fast requests keep the server healthy.
+	done := shedder.start()
+	now = now.Add(10 * time.Millisecond)
+	done()
+	now = now.Add(loadShedWindow)
+	if shedder.overloaded() {
+		t.Error("Expected no overload after a fast window")
+	}
+}
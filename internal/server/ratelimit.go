@@ -0,0 +1,127 @@
+package server
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+const (
+	// RateLimitByPath keys rate limits by hook path only.
+	RateLimitByPath = "path"
+	// RateLimitByUser keys rate limits by hook path and requesting user.
+	RateLimitByUser = "user"
+	// RateLimitByNamespace keys rate limits by hook path and request namespace.
+	RateLimitByNamespace = "namespace"
+
+	// rateLimitSweepInterval is how often idle buckets are removed.
+	rateLimitSweepInterval = time.Minute
+)
+
+// RateLimitConfig holds admission request rate limiting configuration.
+type RateLimitConfig struct {
+	// QPS is the sustained number of requests per second allowed per key.
+	// Zero or a negative value disables rate limiting.
+	QPS float32
+
+	// Burst is the maximum number of requests allowed at once per key.
+	Burst int
+
+	// By selects the rate limit key: RateLimitByPath, RateLimitByUser or
+	// RateLimitByNamespace. Empty means RateLimitByPath.
+	By string
+}
+
+// requestRateLimiter rate-limits admission requests with one token bucket per key.
+// A nil limiter does not limit.
+type requestRateLimiter struct {
+	limit rate.Limit
+	burst int
+	by    string
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*rate.Limiter
+	lastSweep time.Time
+}
+
+// newRequestRateLimiter creates a request rate limiter.
+// It returns nil if rate limiting is disabled.
+func newRequestRateLimiter(config RateLimitConfig) *requestRateLimiter {
+	if config.QPS <= 0 {
+		return nil
+	}
+	if config.Burst <= 0 {
+		config.Burst = 1
+	}
+	return &requestRateLimiter{
+		limit:   rate.Limit(config.QPS),
+		burst:   config.Burst,
+		by:      config.By,
+		now:     time.Now,
+		buckets: make(map[string]*rate.Limiter),
+	}
+}
+
+// key returns the bucket key of a request to the given path.
+func (l *requestRateLimiter) key(path string, req *admissionv1.AdmissionRequest) string {
+	switch l.by {
+	case RateLimitByUser:
+		return path + "\x00" + req.UserInfo.Username
+	case RateLimitByNamespace:
+		return path + "\x00" + req.Namespace
+	default:
+		return path
+	}
+}
+
+// allow reports whether a request may proceed. If not, it returns how long
+// the client should wait before retrying.
+func (l *requestRateLimiter) allow(path string, req *admissionv1.AdmissionRequest) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	now := l.now()
+	key := l.key(path, req)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = rate.NewLimiter(l.limit, l.burst)
+		l.buckets[key] = bucket
+	}
+
+	reservation := bucket.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// sweep removes buckets that are full again, since they behave exactly like
+// new ones. This bounds memory when keying by user or namespace.
+func (l *requestRateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, bucket := range l.buckets {
+		if bucket.TokensAt(now) >= float64(l.burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// retryAfterSeconds converts a delay to a Retry-After value of at least one second.
+func retryAfterSeconds(delay time.Duration) int {
+	return int(math.Max(1, math.Ceil(delay.Seconds())))
+}
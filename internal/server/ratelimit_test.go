@@ -0,0 +1,119 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+func TestRequestRateLimiter_Disabled(t *testing.T) {
+	limiter := newRequestRateLimiter(RateLimitConfig{})
+	if limiter != nil {
+		t.Fatal("Expected nil limiter when QPS is zero")
+	}
+	if ok, _ := limiter.allow("/validate", &admissionv1.AdmissionRequest{}); !ok {
+		t.Error("Expected nil limiter to allow requests")
+	}
+}
+
+func TestRequestRateLimiter_Keys(t *testing.T) {
+	request := func(user, namespace string) *admissionv1.AdmissionRequest {
+		return &admissionv1.AdmissionRequest{
+			Namespace: namespace,
+			UserInfo:  authenticationv1.UserInfo{Username: user},
+		}
+	}
+
+	tests := []struct {
+		by        string
+		first     *admissionv1.AdmissionRequest
+		second    *admissionv1.AdmissionRequest
+		wantAllow bool
+	}{
+		{by: RateLimitByPath, first: request("alice", "a"), second: request("bob", "b"), wantAllow: false},
+		{by: RateLimitByUser, first: request("alice", "a"), second: request("bob", "a"), wantAllow: true},
+		{by: RateLimitByUser, first: request("alice", "a"), second: request("alice", "b"), wantAllow: false},
+		{by: RateLimitByNamespace, first: request("alice", "a"), second: request("alice", "b"), wantAllow: true},
+		{by: RateLimitByNamespace, first: request("alice", "a"), second: request("bob", "a"), wantAllow: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.by, func(t *testing.T) {
+			now := time.Now()
+			limiter := newRequestRateLimiter(RateLimitConfig{QPS: 1, Burst: 1, By: tt.by})
+			limiter.now = func() time.Time { return now }
+
+			if ok, _ := limiter.allow("/validate", tt.first); !ok {
+				t.Fatal("Expected first request to be allowed")
+			}
+			ok, delay := limiter.allow("/validate", tt.second)
+			if ok != tt.wantAllow {
+				t.Errorf("Second request allowed: got %v, want %v", ok, tt.wantAllow)
+			}
+			if !ok && (delay <= 0 || delay > time.Second) {
+				t.Errorf("Delay: got %v, want (0, 1s]", delay)
+			}
+
+			// Different paths never share a bucket.
+			if ok, _ := limiter.allow("/mutate", tt.first); !ok {
+				t.Error("Expected request to another path to be allowed")
+			}
+		})
+	}
+}
+
+func TestRequestRateLimiter_Refill(t *testing.T) {
+	now := time.Now()
+	limiter := newRequestRateLimiter(RateLimitConfig{QPS: 2, Burst: 2})
+	limiter.now = func() time.Time { return now }
+	req := &admissionv1.AdmissionRequest{}
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.allow("/validate", req); !ok {
+			t.Fatalf("Request %d within burst was rejected", i)
+		}
+	}
+	if ok, _ := limiter.allow("/validate", req); ok {
+		t.Fatal("Expected request beyond burst to be rejected")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := limiter.allow("/validate", req); !ok {
+		t.Error("Expected request to be allowed after refill")
+	}
+}
+
+func TestRequestRateLimiter_Sweep(t *testing.T) {
+	now := time.Now()
+	limiter := newRequestRateLimiter(RateLimitConfig{QPS: 10, Burst: 1, By: RateLimitByUser})
+	limiter.now = func() time.Time { return now }
+
+	for _, user := range []string{"alice", "bob", "carol"} {
+		limiter.allow("/validate", &admissionv1.AdmissionRequest{UserInfo: authenticationv1.UserInfo{Username: user}})
+	}
+	if len(limiter.buckets) != 3 {
+		t.Fatalf("Expected 3 buckets, got %d", len(limiter.buckets))
+	}
+
+	now = now.Add(2 * rateLimitSweepInterval)
+	limiter.allow("/validate", &admissionv1.AdmissionRequest{UserInfo: authenticationv1.UserInfo{Username: "dave"}})
+	if len(limiter.buckets) != 1 {
+		t.Errorf("Expected idle buckets to be swept, got %d buckets", len(limiter.buckets))
+	}
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	tests := map[time.Duration]int{
+		0:                       1,
+		100 * time.Millisecond:  1,
+		time.Second:             1,
+		1500 * time.Millisecond: 2,
+	}
+	for delay, want := range tests {
+		if got := retryAfterSeconds(delay); got != want {
+			t.Errorf("retryAfterSeconds(%v): got %d, want %d", delay, got, want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// AuditIDHeader is the header in which the API server sends the audit ID of
+// an admission request.
+const AuditIDHeader = "Audit-Id"
+
+// requestInfoKey is the context key of the requestInfo of an admission
+// request.
+type requestInfoKey struct{}
+
+// requestInfo identifies an admission request.
+type requestInfo struct {
+	auditID string
+	uid     types.UID
+}
+
+// withRequestInfo returns a context carrying the audit ID and UID of an
+// admission request, with a logger that logs them with every message.
+func withRequestInfo(ctx context.Context, auditID string, uid types.UID) context.Context {
+	ctx = context.WithValue(ctx, requestInfoKey{}, requestInfo{auditID: auditID, uid: uid})
+	return klog.NewContext(ctx, klog.FromContext(ctx).WithValues("auditID", auditID, "uid", uid))
+}
+
+// AuditID returns the audit ID of the admission request handled with ctx, or
+// "" if the API server did not send one.
+func AuditID(ctx context.Context) string {
+	info, _ := ctx.Value(requestInfoKey{}).(requestInfo)
+	return info.auditID
+}
+
+// RequestUID returns the UID of the admission request handled with ctx.
+func RequestUID(ctx context.Context) types.UID {
+	info, _ := ctx.Value(requestInfoKey{}).(requestInfo)
+	return info.uid
+}
@@ -3,44 +3,170 @@ package server
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	admissionv1 "k8s.io/api/admission/v1"
 	"k8s.io/klog/v2"
 
-	"github.com/jimyag/auto-cert-webhook/internal/certprovider"
+	"github.com/jimyag/auto-cert-webhook/internal/metrics"
+	"github.com/jimyag/auto-cert-webhook/pkg/certprovider"
 )
 
-// AdmitFunc is the function signature for handling admission requests.
-// This is defined here to match the public API type signature.
-type AdmitFunc = func(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse
+// AdmitFunc is the function signature for handling admission requests. ctx
+// is cancelled when the request is abandoned or the hook's timeout expires.
+type AdmitFunc = func(ctx context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse
 
 // Config holds server configuration.
 type Config struct {
+	// BindAddress is the host or IP address to listen on, or "unix://<path>"
+	// to listen on a Unix domain socket. Empty listens on all interfaces.
+	BindAddress string
 	Port        int
 	HealthzPath string
 	ReadyzPath  string
+
+	// Network restricts the address family of the listener: "tcp4" or
+	// "tcp6". Empty or "tcp" accepts both on dual-stack hosts.
+	Network string
+
+	// ShutdownDelay is how long the server keeps serving with a failing
+	// readiness endpoint after shutdown begins, so that Endpoints and
+	// EndpointSlices drop the pod before its listeners close.
+	ShutdownDelay time.Duration
+
+	// ShutdownTimeout bounds how long in-flight requests are drained after
+	// the shutdown delay. Defaults to 5 seconds.
+	ShutdownTimeout time.Duration
+
+	// AllowYAML makes admission handlers accept application/yaml requests.
+	AllowYAML bool
+
+	// ExcludeNamespaces are namespaces whose requests are allowed without
+	// calling the admit functions.
+	ExcludeNamespaces []string
+
+	// MaxInFlight caps the number of admission requests handled concurrently
+	// across all hooks. Zero or a negative value disables the limit.
+	MaxInFlight int
+
+	// RateLimit configures per-path (and optionally per-user or per-namespace)
+	// request rate limiting.
+	RateLimit RateLimitConfig
+
+	// LoadShedding configures when requests of fail-open hooks are allowed
+	// without evaluation because the server is overloaded.
+	LoadShedding LoadSheddingConfig
+
+	// TLS restricts the negotiated TLS versions, cipher suites and curves.
+	TLS TLSPolicy
+
+	// ClientCAs returns the CAs trusted to sign client certificates. When set,
+	// admission requests must present a client certificate signed by one of
+	// them; health endpoints stay reachable without one for kubelet probes.
+	ClientCAs func() *x509.CertPool
+
+	// Authenticator, if set, authenticates the caller of every admission
+	// request before it is decoded, e.g. with a TokenReview of its bearer token.
+	Authenticator Authenticator
+
+	// DisableHTTP2 restricts the server to HTTP/1.1.
+	DisableHTTP2 bool
+
+	// HTTP2MaxConcurrentStreams limits concurrent streams per HTTP/2
+	// connection. Zero uses the Go default.
+	HTTP2MaxConcurrentStreams int
+}
+
+// Authenticator authenticates the caller of an admission request.
+type Authenticator interface {
+	// AuthenticateRequest returns the authenticated user of the request and
+	// whether authentication succeeded.
+	AuthenticateRequest(r *http.Request) (user string, ok bool, err error)
+}
+
+// HookOptions holds per-hook handler options.
+type HookOptions struct {
+	// Port serves the hook on a separate listener on this port instead of
+	// Config.Port. Hooks with the same Port share a listener.
+	Port int
+
+	// FailOpen allows requests that cannot be evaluated because the server is
+	// overloaded instead of failing them, matching a FailurePolicy of Ignore.
+	// Requests of fail-open hooks are also subject to load shedding.
+	FailOpen bool
+
+	// Disabled allows every request without calling the admit function.
+	Disabled bool
+
+	// Timeout, if positive, bounds the admit function: once it expires its
+	// context is cancelled and the request is answered with a timeout
+	// response, allowed if FailOpen and denied otherwise.
+	Timeout time.Duration
+
+	// MaxBodySize is the request body limit in bytes. Larger requests are
+	// answered without evaluation, allowed if FailOpen and denied otherwise.
+	// If zero, defaults to 10 MiB.
+	MaxBodySize int64
+
+	// CacheTTL, if positive, caches the responses of the admit function for
+	// this long, keyed by request UID and object, and answers repeated
+	// requests from the cache.
+	CacheTTL time.Duration
 }
 
 // Server is the webhook HTTP server.
 type Server struct {
-	server       *http.Server
-	certProvider *certprovider.Provider
+	certProvider certprovider.Source
 	mux          *http.ServeMux
+	portMuxes    map[int]*http.ServeMux
 	config       Config
+	inFlight     *inFlightLimiter
+	rateLimiter  *requestRateLimiter
+	loadShedder  *loadShedder
+	draining     atomic.Bool
+	// excludeNamespaces holds Config.ExcludeNamespaces.
+	excludeNamespaces map[string]bool
+	// readinessChecks must pass, in addition to a loaded certificate, for
+	// the server to report ready.
+	readinessChecks []healthCheck
+	// livenessChecks must pass for the server to report healthy.
+	livenessChecks []healthCheck
+}
+
+// healthCheck is a named condition of readiness or liveness.
+type healthCheck struct {
+	name  string
+	check func(context.Context) error
 }
 
 // New creates a new webhook server.
-func New(certProvider *certprovider.Provider, config Config) *Server {
+func New(certProvider certprovider.Source, config Config) *Server {
 	mux := http.NewServeMux()
 
 	s := &Server{
 		certProvider: certProvider,
 		mux:          mux,
+		portMuxes:    make(map[int]*http.ServeMux),
 		config:       config,
+		inFlight:     newInFlightLimiter(config.MaxInFlight),
+		rateLimiter:  newRequestRateLimiter(config.RateLimit),
+		loadShedder:  newLoadShedder(config.LoadShedding),
+	}
+	if len(config.ExcludeNamespaces) > 0 {
+		s.excludeNamespaces = make(map[string]bool, len(config.ExcludeNamespaces))
+		for _, ns := range config.ExcludeNamespaces {
+			s.excludeNamespaces[ns] = true
+		}
 	}
 
 	// Register health endpoints
@@ -51,21 +177,221 @@ func New(certProvider *certprovider.Provider, config Config) *Server {
 }
 
 // RegisterHook registers a webhook handler at the given path.
-func (s *Server) RegisterHook(path string, hookType string, admit AdmitFunc) {
-	s.mux.Handle(path, newAdmissionHandler(admit))
+func (s *Server) RegisterHook(path string, hookType string, admit AdmitFunc, opts HookOptions) {
+	handler := newAdmissionHandler(admit)
+	handler.path = path
+	handler.allowYAML = s.config.AllowYAML
+	handler.inFlight = s.inFlight
+	handler.rateLimiter = s.rateLimiter
+	handler.loadShedder = s.loadShedder
+	handler.failOpen = opts.FailOpen
+	handler.disabled = opts.Disabled
+	handler.timeout = opts.Timeout
+	handler.maxBodySize = opts.MaxBodySize
+	handler.cache = newResponseCache(opts.CacheTTL)
+	handler.excludeNamespaces = s.excludeNamespaces
+	handler.requireClientCert = s.config.ClientCAs != nil
+	handler.authenticator = s.config.Authenticator
+	s.muxFor(opts.Port).Handle(path, handler)
+	metrics.SetHookEnabled(path, !opts.Disabled)
 	klog.V(2).Infof("Registered %s webhook at %s", hookType, path)
 }
 
-// Start starts the HTTPS server.
+// AddReadinessCheck makes the readiness endpoint fail while check returns an
+// error. check gets the context of the probe request. It must be called
+// before Start.
+func (s *Server) AddReadinessCheck(name string, check func(context.Context) error) {
+	s.readinessChecks = append(s.readinessChecks, healthCheck{name: name, check: check})
+}
+
+// AddLivenessCheck makes the health endpoint fail while check returns an
+// error. check gets the context of the probe request. It must be called
+// before Start.
+func (s *Server) AddLivenessCheck(name string, check func(context.Context) error) {
+	s.livenessChecks = append(s.livenessChecks, healthCheck{name: name, check: check})
+}
+
+// Handle registers an additional handler on the main listener, e.g. for
+// metrics. It is not subject to the admission limits, client certificate or
+// caller authentication of the hooks.
+func (s *Server) Handle(path string, handler http.Handler) {
+	s.mux.Handle(path, handler)
+}
+
+// Handler returns the handler of the main listener, for serving the hooks
+// from another server instead of calling Start. It has no hooks registered
+// on other ports.
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// muxFor returns the mux of the listener on the given port.
+func (s *Server) muxFor(port int) *http.ServeMux {
+	if port == 0 || port == s.config.Port {
+		return s.mux
+	}
+	mux, ok := s.portMuxes[port]
+	if !ok {
+		mux = http.NewServeMux()
+		s.portMuxes[port] = mux
+	}
+	return mux
+}
+
+// Start starts the HTTPS listeners: the main one on Config.Port, which also
+// serves the health endpoints, and one per additional hook port.
 func (s *Server) Start(ctx context.Context) error {
-	tlsConfig := &tls.Config{
-		GetCertificate: s.certProvider.GetCertificate,
-		MinVersion:     tls.VersionTLS12,
+	servers := []*http.Server{s.newHTTPServer()}
+	listeners := make([]net.Listener, 0, 1+len(s.portMuxes))
+	ln, err := s.listen(s.config.Port)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
 	}
+	listeners = append(listeners, ln)
 
-	s.server = &http.Server{
-		Addr:              fmt.Sprintf(":%d", s.config.Port),
-		Handler:           s.mux,
+	ports := make([]int, 0, len(s.portMuxes))
+	for port := range s.portMuxes {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+	for _, port := range ports {
+		ln, err := s.listen(port)
+		if err != nil {
+			for _, ln := range listeners {
+				ln.Close()
+			}
+			return fmt.Errorf("failed to listen on port %d: %w", port, err)
+		}
+		listeners = append(listeners, ln)
+		servers = append(servers, s.newHTTPServerFor(port, s.portMuxes[port]))
+	}
+
+	errChan := make(chan error, len(servers))
+	for i, srv := range servers {
+		go func() {
+			klog.Infof("Starting webhook server on %s", listeners[i].Addr())
+			if err := srv.ServeTLS(listeners[i], "", ""); err != nil && err != http.ErrServerClosed {
+				errChan <- err
+			}
+		}()
+	}
+
+	select {
+	case <-ctx.Done():
+	case err := <-errChan:
+		return s.fail(servers, err)
+	}
+
+	// Fail readiness first and keep serving until the pod is removed from the
+	// Service endpoints, so the API server stops sending requests before the
+	// listeners close. Shutdown then drains in-flight requests.
+	s.draining.Store(true)
+	if s.config.ShutdownDelay > 0 {
+		klog.Infof("Failing readiness and waiting %v before shutting down the webhook server", s.config.ShutdownDelay)
+		timer := time.NewTimer(s.config.ShutdownDelay)
+		select {
+		case <-timer.C:
+		case err := <-errChan:
+			timer.Stop()
+			return s.fail(servers, err)
+		}
+	}
+
+	klog.Info("Shutting down webhook server")
+	timeout := s.config.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return shutdownAll(shutdownCtx, servers)
+}
+
+// fail closes all servers after one of them failed.
+func (s *Server) fail(servers []*http.Server, err error) error {
+	klog.Errorf("Webhook server error: %v", err)
+	for _, srv := range servers {
+		srv.Close()
+	}
+	return err
+}
+
+// shutdownAll gracefully shuts down the servers concurrently and returns the
+// first error.
+func shutdownAll(ctx context.Context, servers []*http.Server) error {
+	errs := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func() {
+			errs <- srv.Shutdown(ctx)
+		}()
+	}
+	var firstErr error
+	for range servers {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+const (
+	// unixSocketPrefix marks a BindAddress as a Unix domain socket path.
+	unixSocketPrefix = "unix://"
+
+	// defaultShutdownTimeout bounds draining when no ShutdownTimeout is set.
+	defaultShutdownTimeout = 5 * time.Second
+)
+
+// address returns the TCP address to listen on for the given port.
+func (s *Server) address(port int) string {
+	host := strings.TrimSuffix(strings.TrimPrefix(s.config.BindAddress, "["), "]")
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// listen opens the listener of the configured bind address on the given port.
+// A Unix domain socket bind address is only used for the main port.
+func (s *Server) listen(port int) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(s.config.BindAddress, unixSocketPrefix); ok {
+		if port != s.config.Port {
+			return nil, fmt.Errorf("hook ports cannot be combined with a Unix domain socket bind address")
+		}
+		// A socket left behind by a previous process makes listen fail.
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %w", path, err)
+		}
+		return net.Listen("unix", path)
+	}
+	network := s.config.Network
+	if network == "" {
+		network = "tcp"
+	}
+	return net.Listen(network, s.address(port))
+}
+
+// newHTTPServer creates the HTTPS server of the main port.
+func (s *Server) newHTTPServer() *http.Server {
+	return s.newHTTPServerFor(s.config.Port, s.mux)
+}
+
+// newHTTPServerFor creates an HTTPS server for the given port and handler.
+func (s *Server) newHTTPServerFor(port int, handler http.Handler) *http.Server {
+	tlsConfig := &tls.Config{}
+	s.config.TLS.Apply(tlsConfig)
+	if s.certProvider != nil {
+		tlsConfig.GetCertificate = s.certProvider.GetCertificate
+	}
+	if s.config.ClientCAs != nil {
+		// Certificates are requested on every connection but only required by
+		// admission handlers. They are verified in VerifyConnection rather than
+		// through tls.Config.ClientCAs so that a rotated client CA applies to
+		// new connections without restarting the server.
+		tlsConfig.ClientAuth = tls.RequestClientCert
+		tlsConfig.VerifyConnection = s.verifyClientCert
+	}
+
+	srv := &http.Server{
+		Addr:              s.address(port),
+		Handler:           handler,
 		TLSConfig:         tlsConfig,
 		ReadHeaderTimeout: 10 * time.Second,
 		ReadTimeout:       30 * time.Second,
@@ -73,28 +399,44 @@ func (s *Server) Start(ctx context.Context) error {
 		IdleTimeout:       60 * time.Second,
 	}
 
-	errChan := make(chan error, 1)
-	go func() {
-		klog.Infof("Starting webhook server on port %d", s.config.Port)
-		if err := s.server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
-			errChan <- err
-		}
-	}()
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(!s.config.DisableHTTP2)
+	srv.Protocols = protocols
+	if s.config.DisableHTTP2 {
+		tlsConfig.NextProtos = []string{"http/1.1"}
+	} else if s.config.HTTP2MaxConcurrentStreams > 0 {
+		srv.HTTP2 = &http.HTTP2Config{MaxConcurrentStreams: s.config.HTTP2MaxConcurrentStreams}
+	}
+	return srv
+}
 
-	select {
-	case <-ctx.Done():
-		klog.Info("Shutting down webhook server")
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		return s.server.Shutdown(shutdownCtx)
-	case err := <-errChan:
-		klog.Errorf("Webhook server error: %v", err)
-		return err
+// verifyClientCert verifies the client certificate of a connection, if any,
+// against the current client CAs.
+func (s *Server) verifyClientCert(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return nil
+	}
+	opts := x509.VerifyOptions{
+		Roots:         s.config.ClientCAs(),
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
 	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	if _, err := cs.PeerCertificates[0].Verify(opts); err != nil {
+		klog.V(2).Infof("Rejecting client certificate %q: %v", cs.PeerCertificates[0].Subject.CommonName, err)
+		return fmt.Errorf("failed to verify client certificate: %w", err)
+	}
+	return nil
 }
 
 // healthzHandler handles health check requests.
 func (s *Server) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	if !runChecks(w, r, "healthz", "healthy", s.livenessChecks) {
+		return
+	}
 	if _, err := io.WriteString(w, "ok"); err != nil {
 		klog.Errorf("Failed to write healthz response: %v", err)
 	}
@@ -102,6 +444,13 @@ func (s *Server) healthzHandler(w http.ResponseWriter, r *http.Request) {
 
 // readyzHandler handles readiness check requests.
 func (s *Server) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if s.draining.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		if _, err := io.WriteString(w, "shutting down"); err != nil {
+			klog.Errorf("Failed to write readyz response: %v", err)
+		}
+		return
+	}
 	if !s.certProvider.Ready() {
 		klog.Error("Certificate not ready")
 		w.WriteHeader(http.StatusServiceUnavailable)
@@ -110,7 +459,26 @@ func (s *Server) readyzHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	if !runChecks(w, r, "readyz", "ready", s.readinessChecks) {
+		return
+	}
 	if _, err := io.WriteString(w, "ok"); err != nil {
 		klog.Errorf("Failed to write readyz response: %v", err)
 	}
 }
+
+// runChecks runs checks in order and, at the first failure, writes a 503
+// response naming it and returns false.
+func runChecks(w http.ResponseWriter, r *http.Request, endpoint, state string, checks []healthCheck) bool {
+	for _, c := range checks {
+		if err := c.check(r.Context()); err != nil {
+			klog.V(2).Infof("%s check %s failed: %v", endpoint, c.name, err)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			if _, err := fmt.Fprintf(w, "%s not %s: %v", c.name, state, err); err != nil {
+				klog.Errorf("Failed to write %s response: %v", endpoint, err)
+			}
+			return false
+		}
+	}
+	return true
+}
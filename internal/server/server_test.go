@@ -1,12 +1,29 @@
 package server
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/jimyag/auto-cert-webhook/pkg/certprovider"
 )
 
 // mockCertProvider is a mock implementation for testing
@@ -18,6 +35,10 @@ func (m *mockCertProvider) Ready() bool {
 	return m.ready.Load()
 }
 
+func (m *mockCertProvider) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return nil, errors.New("no certificate")
+}
+
 func TestNew(t *testing.T) {
 	provider := &mockCertProvider{}
 	config := Config{
@@ -65,6 +86,25 @@ func TestServer_healthzHandler(t *testing.T) {
 	}
 }
 
+func TestServer_healthzHandler_LivenessCheck(t *testing.T) {
+	server := New(&mockCertProvider{}, Config{Port: 8443, HealthzPath: "/healthz", ReadyzPath: "/readyz"})
+	var checkErr error = errors.New("connection refused")
+	server.AddLivenessCheck("database", func(context.Context) error { return checkErr })
+
+	rec := httptest.NewRecorder()
+	server.healthzHandler(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "database not healthy: connection refused" {
+		t.Errorf("Got status %d and body %q", rec.Code, rec.Body.String())
+	}
+
+	checkErr = nil
+	rec = httptest.NewRecorder()
+	server.healthzHandler(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Errorf("Expected ok once the check passes, got status %d and body %q", rec.Code, rec.Body.String())
+	}
+}
+
 func TestServer_readyzHandler(t *testing.T) {
 	t.Run("ready", func(t *testing.T) {
 		provider := &mockCertProvider{}
@@ -113,6 +153,27 @@ func TestServer_readyzHandler(t *testing.T) {
 			t.Errorf("Expected body %q, got %q", "certificate not ready", rec.Body.String())
 		}
 	})
+
+	t.Run("failing readiness check", func(t *testing.T) {
+		provider := &mockCertProvider{}
+		provider.ready.Store(true)
+		server := New(provider, Config{Port: 8443, HealthzPath: "/healthz", ReadyzPath: "/readyz"})
+		var checkErr error = errors.New("not propagated")
+		server.AddReadinessCheck("CA bundle", func(context.Context) error { return checkErr })
+
+		rec := httptest.NewRecorder()
+		server.readyzHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "CA bundle not ready: not propagated" {
+			t.Errorf("Got status %d and body %q", rec.Code, rec.Body.String())
+		}
+
+		checkErr = nil
+		rec = httptest.NewRecorder()
+		server.readyzHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected status %d once the check passes, got %d", http.StatusOK, rec.Code)
+		}
+	})
 }
 
 func TestServer_RegisterHook(t *testing.T) {
@@ -125,13 +186,13 @@ func TestServer_RegisterHook(t *testing.T) {
 
 	server := newTestServer(provider, config)
 
-	admitFunc := func(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+	admitFunc := func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
 		return &admissionv1.AdmissionResponse{Allowed: true}
 	}
 
 	// Register hooks
-	server.RegisterHook("/mutate", "Mutating", admitFunc)
-	server.RegisterHook("/validate", "Validating", admitFunc)
+	server.RegisterHook("/mutate", "Mutating", admitFunc, HookOptions{})
+	server.RegisterHook("/validate", "Validating", admitFunc, HookOptions{})
 
 	// Verify handlers are registered by making test requests
 	t.Run("mutating hook registered", func(t *testing.T) {
@@ -153,6 +214,15 @@ func TestServer_RegisterHook(t *testing.T) {
 			t.Error("Expected /validate to be registered")
 		}
 	})
+
+	t.Run("hooks only accept POST", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, rec.Code)
+		}
+	})
 }
 
 func TestServer_HealthEndpointsRegistered(t *testing.T) {
@@ -187,6 +257,321 @@ func TestServer_HealthEndpointsRegistered(t *testing.T) {
 	})
 }
 
+func TestServer_RegisterHook_Port(t *testing.T) {
+	server := newTestServer(&mockCertProvider{}, Config{Port: 8443, HealthzPath: "/healthz", ReadyzPath: "/readyz"})
+	admitFunc := func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+
+	server.RegisterHook("/mutate", "Mutating", admitFunc, HookOptions{Port: 8443})
+	server.RegisterHook("/validate", "Validating", admitFunc, HookOptions{Port: 9443})
+	server.RegisterHook("/validate-strict", "Validating", admitFunc, HookOptions{Port: 9443})
+
+	registered := func(mux *http.ServeMux, path string) bool {
+		_, pattern := mux.Handler(httptest.NewRequest(http.MethodPost, path, nil))
+		return pattern != ""
+	}
+
+	if len(server.portMuxes) != 1 || server.portMuxes[9443] == nil {
+		t.Fatalf("Expected one additional listener on 9443, got %v", server.portMuxes)
+	}
+	if !registered(server.mux, "/mutate") {
+		t.Error("Expected /mutate on the main port")
+	}
+	for _, path := range []string{"/validate", "/validate-strict"} {
+		if registered(server.mux, path) {
+			t.Errorf("Expected %s not to be served on the main port", path)
+		}
+		if !registered(server.portMuxes[9443], path) {
+			t.Errorf("Expected %s on port 9443", path)
+		}
+	}
+	// Health endpoints are only served on the main port
+	if registered(server.portMuxes[9443], "/healthz") {
+		t.Error("Expected /healthz only on the main port")
+	}
+
+	srv := server.newHTTPServerFor(9443, server.portMuxes[9443])
+	if srv.Addr != ":9443" {
+		t.Errorf("Addr: got %q, want %q", srv.Addr, ":9443")
+	}
+}
+
+func TestServer_Handle(t *testing.T) {
+	ca, _ := newTestCA(t)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	server := newTestServer(&mockCertProvider{}, Config{
+		Port:        8443,
+		HealthzPath: "/healthz",
+		ReadyzPath:  "/readyz",
+		ClientCAs:   func() *x509.CertPool { return pool },
+	})
+	server.Handle("/metrics", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "metrics")
+	}))
+
+	// Additional handlers do not require the client certificate of hooks
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rec := httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "metrics" {
+		t.Errorf("Expected /metrics to be served, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestServer_Start_GracefulDrain(t *testing.T) {
+	const delay = 200 * time.Millisecond
+	// The provider is never started; the server only needs it to serve TLS.
+	provider := certprovider.New(fake.NewSimpleClientset(), "ns", "cert")
+	server := New(provider, Config{
+		BindAddress:   "127.0.0.1",
+		HealthzPath:   "/healthz",
+		ReadyzPath:    "/readyz",
+		ShutdownDelay: delay,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Start(ctx)
+	}()
+
+	start := time.Now()
+	cancel()
+
+	// Readiness fails while the server keeps serving during the delay
+	deadline := time.After(delay / 2)
+	for !server.draining.Load() {
+		select {
+		case <-deadline:
+			t.Fatal("Expected server to start draining")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	rec := httptest.NewRecorder()
+	server.readyzHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("readyz: got status %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Start returned error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start did not return after shutdown")
+	}
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Errorf("Start returned after %v, want at least %v", elapsed, delay)
+	}
+}
+
+func TestServer_newHTTPServer(t *testing.T) {
+	t.Run("HTTP/2 enabled by default", func(t *testing.T) {
+		server := newTestServer(&mockCertProvider{}, Config{Port: 8443, HealthzPath: "/healthz", ReadyzPath: "/readyz"})
+		srv := server.newHTTPServer()
+
+		if srv.Addr != ":8443" {
+			t.Errorf("Addr: got %q, want %q", srv.Addr, ":8443")
+		}
+		if !srv.Protocols.HTTP1() || !srv.Protocols.HTTP2() {
+			t.Errorf("Protocols: got %v, want HTTP/1 and HTTP/2", srv.Protocols)
+		}
+		if srv.HTTP2 != nil {
+			t.Errorf("Expected default HTTP/2 config, got %+v", srv.HTTP2)
+		}
+	})
+
+	t.Run("max concurrent streams", func(t *testing.T) {
+		server := newTestServer(&mockCertProvider{}, Config{Port: 8443, HealthzPath: "/healthz", ReadyzPath: "/readyz", HTTP2MaxConcurrentStreams: 100})
+		srv := server.newHTTPServer()
+
+		if srv.HTTP2 == nil || srv.HTTP2.MaxConcurrentStreams != 100 {
+			t.Errorf("HTTP2: got %+v, want MaxConcurrentStreams=100", srv.HTTP2)
+		}
+	})
+
+	t.Run("HTTP/2 disabled", func(t *testing.T) {
+		server := newTestServer(&mockCertProvider{}, Config{Port: 8443, HealthzPath: "/healthz", ReadyzPath: "/readyz", DisableHTTP2: true, HTTP2MaxConcurrentStreams: 100})
+		srv := server.newHTTPServer()
+
+		if !srv.Protocols.HTTP1() || srv.Protocols.HTTP2() {
+			t.Errorf("Protocols: got %v, want HTTP/1 only", srv.Protocols)
+		}
+		if len(srv.TLSConfig.NextProtos) != 1 || srv.TLSConfig.NextProtos[0] != "http/1.1" {
+			t.Errorf("NextProtos: got %v, want [http/1.1]", srv.TLSConfig.NextProtos)
+		}
+	})
+}
+
+func TestServer_listen(t *testing.T) {
+	t.Run("address", func(t *testing.T) {
+		tests := map[string]string{
+			"":          ":8443",
+			"127.0.0.1": "127.0.0.1:8443",
+			"::1":       "[::1]:8443",
+			"[::1]":     "[::1]:8443",
+		}
+		for bindAddress, want := range tests {
+			server := &Server{config: Config{BindAddress: bindAddress, Port: 8443}}
+			if got := server.address(8443); got != want {
+				t.Errorf("address(%q): got %q, want %q", bindAddress, got, want)
+			}
+		}
+	})
+
+	t.Run("loopback", func(t *testing.T) {
+		server := &Server{config: Config{BindAddress: "127.0.0.1"}}
+		ln, err := server.listen(0)
+		if err != nil {
+			t.Fatalf("listen failed: %v", err)
+		}
+		defer ln.Close()
+
+		if addr := ln.Addr().(*net.TCPAddr); !addr.IP.IsLoopback() {
+			t.Errorf("Expected loopback address, got %v", addr)
+		}
+	})
+
+	t.Run("IPv4 only", func(t *testing.T) {
+		server := &Server{config: Config{Network: "tcp4"}}
+		ln, err := server.listen(0)
+		if err != nil {
+			t.Fatalf("listen failed: %v", err)
+		}
+		defer ln.Close()
+
+		if addr := ln.Addr().(*net.TCPAddr); addr.IP.To4() == nil {
+			t.Errorf("Expected IPv4 address, got %v", addr)
+		}
+	})
+
+	t.Run("unix socket only on the main port", func(t *testing.T) {
+		server := &Server{config: Config{BindAddress: "unix://" + filepath.Join(t.TempDir(), "webhook.sock"), Port: 8443}}
+		if _, err := server.listen(9443); err == nil {
+			t.Error("Expected error for hook port with Unix domain socket")
+		}
+	})
+
+	t.Run("unix socket", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "webhook.sock")
+		// A stale socket file is replaced
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		server := &Server{config: Config{BindAddress: "unix://" + path}}
+		ln, err := server.listen(0)
+		if err != nil {
+			t.Fatalf("listen failed: %v", err)
+		}
+		defer ln.Close()
+
+		if ln.Addr().Network() != "unix" {
+			t.Errorf("Network: got %q, want unix", ln.Addr().Network())
+		}
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		conn.Close()
+	})
+}
+
+func TestServer_ClientCertificates(t *testing.T) {
+	ca, caKey := newTestCA(t)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	server := newTestServer(&mockCertProvider{}, Config{
+		Port:        8443,
+		HealthzPath: "/healthz",
+		ReadyzPath:  "/readyz",
+		ClientCAs:   func() *x509.CertPool { return pool },
+	})
+
+	srv := server.newHTTPServer()
+	if srv.TLSConfig.ClientAuth != tls.RequestClientCert {
+		t.Errorf("ClientAuth: got %v, want RequestClientCert", srv.TLSConfig.ClientAuth)
+	}
+	if srv.TLSConfig.VerifyConnection == nil {
+		t.Fatal("Expected VerifyConnection to be set")
+	}
+
+	otherCA, otherKey := newTestCA(t)
+	tests := []struct {
+		name    string
+		certs   []*x509.Certificate
+		wantErr bool
+	}{
+		{name: "no certificate"},
+		{name: "trusted client", certs: []*x509.Certificate{newTestLeaf(t, ca, caKey, x509.ExtKeyUsageClientAuth)}},
+		{name: "untrusted client", certs: []*x509.Certificate{newTestLeaf(t, otherCA, otherKey, x509.ExtKeyUsageClientAuth)}, wantErr: true},
+		{name: "server certificate", certs: []*x509.Certificate{newTestLeaf(t, ca, caKey, x509.ExtKeyUsageServerAuth)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := srv.TLSConfig.VerifyConnection(tls.ConnectionState{PeerCertificates: tt.certs})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyConnection: got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// newTestCA creates a self-signed CA.
+func newTestCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse CA: %v", err)
+	}
+	return cert, key
+}
+
+// newTestLeaf creates a certificate with the given extended key usage signed by ca.
+func newTestLeaf(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, usage x509.ExtKeyUsage) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "kube-apiserver"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert
+}
+
 func TestConfig(t *testing.T) {
 	config := Config{
 		Port:        9443,
@@ -217,8 +602,9 @@ func newTestServer(provider *mockCertProvider, config Config) *testServer {
 
 	s := &testServer{
 		Server: &Server{
-			mux:    mux,
-			config: config,
+			mux:       mux,
+			portMuxes: make(map[int]*http.ServeMux),
+			config:    config,
 		},
 		mockProvider: provider,
 	}
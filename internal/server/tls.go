@@ -0,0 +1,119 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// TLSPolicy restricts the TLS parameters the server negotiates.
+// Zero values use the Go defaults, except MinVersion which defaults to TLS 1.2.
+type TLSPolicy struct {
+	// MinVersion is the minimum TLS version, e.g. tls.VersionTLS13.
+	MinVersion uint16
+
+	// MaxVersion is the maximum TLS version.
+	MaxVersion uint16
+
+	// CipherSuites lists the enabled TLS 1.0-1.2 cipher suites.
+	// TLS 1.3 cipher suites are not configurable.
+	CipherSuites []uint16
+
+	// CurvePreferences lists the enabled key exchange groups in preference order.
+	CurvePreferences []tls.CurveID
+}
+
+// Apply sets the policy on a TLS config.
+func (p TLSPolicy) Apply(config *tls.Config) {
+	config.MinVersion = tls.VersionTLS12
+	if p.MinVersion != 0 {
+		config.MinVersion = p.MinVersion
+	}
+	config.MaxVersion = p.MaxVersion
+	config.CipherSuites = p.CipherSuites
+	config.CurvePreferences = p.CurvePreferences
+}
+
+// tlsVersions maps version names to TLS versions.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsCurves maps key exchange group names to curve IDs.
+var tlsCurves = map[string]tls.CurveID{
+	"X25519":         tls.X25519,
+	"X25519MLKEM768": tls.X25519MLKEM768,
+	"P256":           tls.CurveP256,
+	"P384":           tls.CurveP384,
+	"P521":           tls.CurveP521,
+}
+
+// ParseTLSVersion parses a TLS version such as "1.2" or "VersionTLS12".
+// An empty string returns zero.
+func ParseTLSVersion(name string) (uint16, error) {
+	if name == "" {
+		return 0, nil
+	}
+	trimmed := strings.TrimPrefix(name, "VersionTLS1")
+	if trimmed != name {
+		trimmed = "1." + trimmed
+	}
+	if version, ok := tlsVersions[trimmed]; ok {
+		return version, nil
+	}
+	return 0, fmt.Errorf("unknown TLS version %q, expected one of 1.0, 1.1, 1.2 or 1.3", name)
+}
+
+// ParseCipherSuites parses IANA cipher suite names such as
+// "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256". Insecure suites are rejected.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	supported := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		supported[suite.Name] = suite.ID
+	}
+	insecure := make(map[string]bool)
+	for _, suite := range tls.InsecureCipherSuites() {
+		insecure[suite.Name] = true
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		id, ok := supported[name]
+		switch {
+		case ok:
+			ids = append(ids, id)
+		case insecure[name]:
+			return nil, fmt.Errorf("cipher suite %s is insecure", name)
+		default:
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		}
+	}
+	return ids, nil
+}
+
+// ParseCurvePreferences parses key exchange group names: X25519,
+// X25519MLKEM768, P256, P384 or P521.
+func ParseCurvePreferences(names []string) ([]tls.CurveID, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	curves := make([]tls.CurveID, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		curve, ok := tlsCurves[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown curve %q, expected one of X25519, X25519MLKEM768, P256, P384 or P521", name)
+		}
+		curves = append(curves, curve)
+	}
+	return curves, nil
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"crypto/tls"
+	"reflect"
+	"testing"
+)
+
+func TestParseTLSVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    uint16
+		wantErr bool
+	}{
+		{name: "", want: 0},
+		{name: "1.2", want: tls.VersionTLS12},
+		{name: "1.3", want: tls.VersionTLS13},
+		{name: "VersionTLS13", want: tls.VersionTLS13},
+		{name: "VersionTLS10", want: tls.VersionTLS10},
+		{name: "1.4", wantErr: true},
+		{name: "TLS1.3", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTLSVersion(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTLSVersion(%q): got error %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseTLSVersion(%q): got %x, want %x", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	got, err := ParseCipherSuites([]string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", " TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256"})
+	if err != nil {
+		t.Fatalf("ParseCipherSuites failed: %v", err)
+	}
+	want := []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseCipherSuites: got %v, want %v", got, want)
+	}
+
+	if _, err := ParseCipherSuites([]string{"TLS_RSA_WITH_RC4_128_SHA"}); err == nil {
+		t.Error("Expected error for insecure cipher suite")
+	}
+	if _, err := ParseCipherSuites([]string{"TLS_UNKNOWN"}); err == nil {
+		t.Error("Expected error for unknown cipher suite")
+	}
+	if got, err := ParseCipherSuites(nil); err != nil || got != nil {
+		t.Errorf("ParseCipherSuites(nil): got %v, %v", got, err)
+	}
+}
+
+func TestParseCurvePreferences(t *testing.T) {
+	got, err := ParseCurvePreferences([]string{"X25519", "P256"})
+	if err != nil {
+		t.Fatalf("ParseCurvePreferences failed: %v", err)
+	}
+	if want := []tls.CurveID{tls.X25519, tls.CurveP256}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseCurvePreferences: got %v, want %v", got, want)
+	}
+
+	if _, err := ParseCurvePreferences([]string{"P224"}); err == nil {
+		t.Error("Expected error for unsupported curve")
+	}
+}
+
+func TestTLSPolicy_Apply(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		config := &tls.Config{}
+		TLSPolicy{}.Apply(config)
+
+		if config.MinVersion != tls.VersionTLS12 {
+			t.Errorf("MinVersion: got %x, want %x", config.MinVersion, tls.VersionTLS12)
+		}
+		if config.MaxVersion != 0 || config.CipherSuites != nil || config.CurvePreferences != nil {
+			t.Errorf("Expected Go defaults, got %+v", config)
+		}
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		config := &tls.Config{}
+		TLSPolicy{
+			MinVersion:       tls.VersionTLS13,
+			MaxVersion:       tls.VersionTLS13,
+			CurvePreferences: []tls.CurveID{tls.X25519},
+		}.Apply(config)
+
+		if config.MinVersion != tls.VersionTLS13 || config.MaxVersion != tls.VersionTLS13 {
+			t.Errorf("Versions: got %x-%x, want TLS 1.3 only", config.MinVersion, config.MaxVersion)
+		}
+		if len(config.CurvePreferences) != 1 || config.CurvePreferences[0] != tls.X25519 {
+			t.Errorf("CurvePreferences: got %v", config.CurvePreferences)
+		}
+	})
+}
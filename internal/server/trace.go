@@ -0,0 +1,40 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+)
+
+// sampledTraceID returns the trace ID of the W3C traceparent header, which the
+// API server sends when APIServerTracing is enabled, if the trace is sampled.
+// Unsampled traces are not exported, so exemplars of them would lead nowhere.
+func sampledTraceID(r *http.Request) string {
+	// version-traceid-parentid-flags, e.g.
+	// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+	parts := strings.Split(r.Header.Get("traceparent"), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[3]) != 2 {
+		return ""
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return ""
+	}
+	traceID, flags := parts[1], parts[3]
+	if !isLowerHex(traceID) || traceID == strings.Repeat("0", 32) || !isLowerHex(flags) {
+		return ""
+	}
+	// The sampled flag is the lowest bit of the flags
+	if !strings.ContainsAny(flags[1:], "13579bdf") {
+		return ""
+	}
+	return traceID
+}
+
+// isLowerHex returns whether s consists of lowercase hexadecimal digits.
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
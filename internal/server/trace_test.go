@@ -0,0 +1,36 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSampledTraceID(t *testing.T) {
+	tests := []struct {
+		name        string
+		traceparent string
+		want        string
+	}{
+		{name: "sampled", traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", want: "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{name: "not sampled", traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"},
+		{name: "future version", traceparent: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-03-extra", want: "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{name: "invalid version", traceparent: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{name: "extra fields", traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"},
+		{name: "zero trace ID", traceparent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{name: "uppercase", traceparent: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"},
+		{name: "short trace ID", traceparent: "00-4bf92f35-00f067aa0ba902b7-01"},
+		{name: "missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/validate", nil)
+			if tt.traceparent != "" {
+				r.Header.Set("traceparent", tt.traceparent)
+			}
+			if got := sampledTraceID(r); got != tt.want {
+				t.Errorf("sampledTraceID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
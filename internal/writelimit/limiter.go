@@ -0,0 +1,190 @@
+// Package writelimit provides a shared, priority-aware rate limiter for
+// Kubernetes API writes.
+package writelimit
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/klog/v2"
+)
+
+// Priority is the priority class of an API write.
+type Priority int
+
+const (
+	// PriorityHigh is used for writes that keep certificates valid (secrets, configmaps).
+	PriorityHigh Priority = iota
+	// PriorityNormal is used for webhook configuration patches and other writes.
+	PriorityNormal
+	// PriorityLow is used for best-effort writes such as events.
+	PriorityLow
+)
+
+// String returns the name of the priority class.
+func (p Priority) String() string {
+	switch p {
+	case PriorityHigh:
+		return "high"
+	case PriorityNormal:
+		return "normal"
+	case PriorityLow:
+		return "low"
+	default:
+		return "unknown"
+	}
+}
+
+// Config holds the write limiter configuration.
+type Config struct {
+	// QPS is the sustained number of writes per second.
+	// Zero or a negative value disables rate limiting.
+	QPS float32
+
+	// Burst is the maximum number of writes issued at once.
+	Burst int
+}
+
+// Limiter rate-limits API writes with a single token bucket shared by all
+// priority classes. Lower priority writes only consume tokens above a reserve,
+// so bursts of events cannot starve certificate updates.
+type Limiter struct {
+	limiter *rate.Limiter
+	burst   int
+}
+
+// New creates a new write limiter.
+func New(config Config) *Limiter {
+	if config.Burst <= 0 {
+		config.Burst = 1
+	}
+	limit := rate.Inf
+	if config.QPS > 0 {
+		limit = rate.Limit(config.QPS)
+	}
+	return &Limiter{
+		limiter: rate.NewLimiter(limit, config.Burst),
+		burst:   config.Burst,
+	}
+}
+
+// reserve returns the number of tokens a write of the given priority must leave available.
+func (l *Limiter) reserve(p Priority) int {
+	switch p {
+	case PriorityHigh:
+		return 0
+	case PriorityNormal:
+		return l.burst / 4
+	default:
+		return l.burst / 2
+	}
+}
+
+// Wait blocks until a write of the given priority may proceed or the context is done.
+func (l *Limiter) Wait(ctx context.Context, p Priority) error {
+	if l.limiter.Limit() == rate.Inf {
+		return nil
+	}
+
+	reserve := l.reserve(p)
+	for reserve > 0 && l.limiter.Tokens() < float64(reserve+1) {
+		missing := float64(reserve+1) - l.limiter.Tokens()
+		delay := time.Duration(missing / float64(l.limiter.Limit()) * float64(time.Second))
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return l.limiter.Wait(ctx)
+}
+
+// Wrap wraps a round tripper so that all write requests pass through the limiter.
+// It can be used as rest.Config.WrapTransport.
+func (l *Limiter) Wrap(rt http.RoundTripper) http.RoundTripper {
+	return &roundTripper{limiter: l, next: rt}
+}
+
+// roundTripper rate-limits write requests.
+type roundTripper struct {
+	limiter *Limiter
+	next    http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if isWrite(req.Method) && !isReview(req.URL.Path) && !isLease(req.URL.Path) {
+		p := classify(req.URL.Path)
+		start := time.Now()
+		if err := r.limiter.Wait(req.Context(), p); err != nil {
+			return nil, err
+		}
+		if waited := time.Since(start); waited > time.Second {
+			klog.V(2).Infof("API write %s %s (%s priority) was throttled for %v", req.Method, req.URL.Path, p, waited)
+		}
+	}
+	return r.next.RoundTrip(req)
+}
+
+// isWrite returns true if the HTTP method modifies resources.
+func isWrite(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// isReview returns true for TokenReview and SubjectAccessReview requests.
+// They are POSTed but persist nothing, and are issued while serving admission
+// requests, so they must not queue behind certificate writes.
+func isReview(path string) bool {
+	return strings.HasPrefix(path, "/apis/authentication.k8s.io/") ||
+		strings.HasPrefix(path, "/apis/authorization.k8s.io/")
+}
+
+// isLease returns true for coordination.k8s.io Lease requests. Leader
+// election renews its lease through them, and loses leadership if a renewal
+// queues behind certificate writes past the renew deadline.
+func isLease(path string) bool {
+	return strings.HasPrefix(path, "/apis/coordination.k8s.io/")
+}
+
+// classify returns the priority class of a write to the given API path.
+func classify(path string) Priority {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	// Resource paths look like /api/v1/namespaces/<ns>/<resource>[/<name>] or
+	// /apis/<group>/<version>/<resource>[/<name>]; find the resource segment.
+	var group, resource string
+	switch {
+	case len(segments) >= 3 && segments[0] == "api":
+		resource = resourceSegment(segments[2:])
+	case len(segments) >= 4 && segments[0] == "apis":
+		group = segments[1]
+		resource = resourceSegment(segments[3:])
+	}
+
+	switch {
+	case resource == "events":
+		return PriorityLow
+	case group == "" && (resource == "secrets" || resource == "configmaps"):
+		return PriorityHigh
+	default:
+		return PriorityNormal
+	}
+}
+
+// resourceSegment returns the resource name from the path segments following the version.
+func resourceSegment(segments []string) string {
+	if len(segments) >= 3 && segments[0] == "namespaces" {
+		return segments[2]
+	}
+	return segments[0]
+}
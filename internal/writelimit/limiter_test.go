@@ -0,0 +1,149 @@
+package writelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPriority_String(t *testing.T) {
+	tests := map[Priority]string{
+		PriorityHigh:   "high",
+		PriorityNormal: "normal",
+		PriorityLow:    "low",
+		Priority(42):   "unknown",
+	}
+	for p, want := range tests {
+		if got := p.String(); got != want {
+			t.Errorf("Priority(%d).String(): got %q, want %q", p, got, want)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		path string
+		want Priority
+	}{
+		{path: "/api/v1/namespaces/ns/secrets/cert", want: PriorityHigh},
+		{path: "/api/v1/namespaces/ns/secrets", want: PriorityHigh},
+		{path: "/api/v1/namespaces/ns/configmaps/bundle", want: PriorityHigh},
+		{path: "/api/v1/namespaces/ns/events", want: PriorityLow},
+		{path: "/apis/events.k8s.io/v1/namespaces/ns/events", want: PriorityLow},
+		{path: "/apis/admissionregistration.k8s.io/v1/validatingwebhookconfigurations/wh", want: PriorityNormal},
+		{path: "/apis/coordination.k8s.io/v1/namespaces/ns/leases/leader", want: PriorityNormal},
+		{path: "/api/v1/namespaces", want: PriorityNormal},
+		{path: "/version", want: PriorityNormal},
+	}
+
+	for _, tt := range tests {
+		if got := classify(tt.path); got != tt.want {
+			t.Errorf("classify(%q): got %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestLimiter_Wait(t *testing.T) {
+	t.Run("unlimited", func(t *testing.T) {
+		l := New(Config{})
+		for i := 0; i < 100; i++ {
+			if err := l.Wait(context.Background(), PriorityLow); err != nil {
+				t.Fatalf("Wait failed: %v", err)
+			}
+		}
+	})
+
+	t.Run("high priority can use the full burst", func(t *testing.T) {
+		l := New(Config{QPS: 0.001, Burst: 4})
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		for i := 0; i < 4; i++ {
+			if err := l.Wait(ctx, PriorityHigh); err != nil {
+				t.Fatalf("Wait %d failed: %v", i, err)
+			}
+		}
+	})
+
+	t.Run("low priority leaves a reserve", func(t *testing.T) {
+		l := New(Config{QPS: 0.001, Burst: 4})
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		// Burst 4 keeps a reserve of 2 tokens for low priority writes.
+		for i := 0; i < 2; i++ {
+			if err := l.Wait(ctx, PriorityLow); err != nil {
+				t.Fatalf("Wait %d failed: %v", i, err)
+			}
+		}
+		if err := l.Wait(ctx, PriorityLow); err == nil {
+			t.Error("Expected low priority write to be held back by the reserve")
+		}
+
+		// The reserve is still available to high priority writes.
+		if err := l.Wait(context.Background(), PriorityHigh); err != nil {
+			t.Errorf("High priority Wait failed: %v", err)
+		}
+	})
+}
+
+func TestLimiter_Wrap(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	l := New(Config{QPS: 0.001, Burst: 1})
+	client := &http.Client{Transport: l.Wrap(http.DefaultTransport)}
+
+	doPath := func(method, path string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, method, srv.URL+path, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	do := func(method string) error {
+		return doPath(method, "/api/v1/namespaces/ns/secrets/cert")
+	}
+
+	// Reads are never limited.
+	for i := 0; i < 3; i++ {
+		if err := do(http.MethodGet); err != nil {
+			t.Fatalf("GET %d failed: %v", i, err)
+		}
+	}
+
+	if err := do(http.MethodPut); err != nil {
+		t.Fatalf("First PUT failed: %v", err)
+	}
+	if err := do(http.MethodPut); err == nil {
+		t.Error("Expected second PUT to be rate limited")
+	}
+
+	// Reviews persist nothing and are never limited.
+	for _, path := range []string{
+		"/apis/authentication.k8s.io/v1/tokenreviews",
+		"/apis/authorization.k8s.io/v1/subjectaccessreviews",
+	} {
+		if err := doPath(http.MethodPost, path); err != nil {
+			t.Errorf("POST %s failed: %v", path, err)
+		}
+	}
+
+	// Lease renewals go through while the bucket is exhausted.
+	for _, method := range []string{http.MethodPut, http.MethodPost} {
+		if err := doPath(method, "/apis/coordination.k8s.io/v1/namespaces/ns/leases/leader"); err != nil {
+			t.Errorf("%s lease failed: %v", method, err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package autocertwebhook
+
+import (
+	"encoding/json"
+	"fmt"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// MutateOnce wraps the admit function of a mutating hook so that objects are
+// mutated only once. When admit patches an object, the patch also sets the
+// marker annotation, e.g. "example.com/sidecar-injected"; objects that
+// already carry it are allowed without calling admit. This prevents duplicate
+// sidecars or list entries when the API server reinvokes the hook, on
+// retries, and on updates of objects mutated when they were created. Objects
+// copied from mutated ones carry the marker too, so remove it from such
+// copies to have them mutated again. Requests without an object, e.g. DELETE,
+// are passed to admit.
+func MutateOnce(marker string, admit AdmitFunc) AdmitFunc {
+	return func(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		if ar.Request == nil || len(ar.Request.Object.Raw) == 0 {
+			return admit(ar)
+		}
+		original := ar.Request.Object.Raw
+		var obj unstructured.Unstructured
+		if err := json.Unmarshal(original, &obj.Object); err != nil {
+			return Errored(fmt.Errorf("failed to decode %s: %w", ar.Request.Kind.Kind, err))
+		}
+		if _, ok := obj.GetAnnotations()[marker]; ok {
+			return Allowed()
+		}
+
+		resp := admit(ar)
+		if resp == nil || !resp.Allowed || len(resp.Patch) == 0 {
+			return resp
+		}
+		patched, err := applyPatch(original, resp)
+		if err != nil {
+			return Errored(err)
+		}
+		if err := json.Unmarshal(patched, &obj.Object); err != nil {
+			return Errored(fmt.Errorf("failed to decode patched %s: %w", ar.Request.Kind.Kind, err))
+		}
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[marker] = "true"
+		obj.SetAnnotations(annotations)
+		modified, err := json.Marshal(obj.Object)
+		if err != nil {
+			return Errored(fmt.Errorf("failed to marshal patched %s: %w", ar.Request.Kind.Kind, err))
+		}
+
+		marked := PatchResponseFromRaw(original, modified)
+		if !marked.Allowed {
+			return marked
+		}
+		resp.Patch, resp.PatchType = marked.Patch, marked.PatchType
+		return resp
+	}
+}
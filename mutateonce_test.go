@@ -0,0 +1,100 @@
+package autocertwebhook
+
+import (
+	"encoding/json"
+	"testing"
+
+	jsonpatch "gopkg.in/evanphx/json-patch.v4"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestMutateOnce(t *testing.T) {
+	const marker = "example.com/labeled"
+	calls := 0
+	admit := MutateOnce(marker, func(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		calls++
+		return addLabel("team", "a")(ar)
+	})
+
+	ar := podReview(t, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web"}})
+	resp := admit(ar)
+	if !resp.Allowed || len(resp.Warnings) != 1 {
+		t.Fatalf("Expected allowed with the warning of the admit function, got %+v", resp)
+	}
+	patch, err := jsonpatch.DecodePatch(resp.Patch)
+	if err != nil {
+		t.Fatalf("Failed to decode patch: %v", err)
+	}
+	patched, err := patch.Apply(ar.Request.Object.Raw)
+	if err != nil {
+		t.Fatalf("Failed to apply patch: %v", err)
+	}
+	pod := &corev1.Pod{}
+	if err := json.Unmarshal(patched, pod); err != nil {
+		t.Fatalf("Failed to decode patched pod: %v", err)
+	}
+	if pod.Labels["team"] != "a" || pod.Annotations[marker] != "true" {
+		t.Errorf("Expected the label and the marker, got labels %v and annotations %v", pod.Labels, pod.Annotations)
+	}
+
+	// Reinvocation with the mutated object
+	ar.Request.Object = runtime.RawExtension{Raw: patched}
+	if resp := admit(ar); !resp.Allowed || resp.Patch != nil {
+		t.Errorf("Expected allowed without patch, got %+v", resp)
+	}
+	if calls != 1 {
+		t.Errorf("Expected the admit function to be called once, got %d", calls)
+	}
+}
+
+func TestMutateOnce_Passthrough(t *testing.T) {
+	tests := []struct {
+		name  string
+		admit AdmitFunc
+		ar    func(admissionv1.AdmissionReview) admissionv1.AdmissionReview
+		check func(*admissionv1.AdmissionResponse) bool
+	}{
+		{
+			name:  "denial",
+			admit: func(admissionv1.AdmissionReview) *admissionv1.AdmissionResponse { return Denied("no") },
+			check: func(resp *admissionv1.AdmissionResponse) bool { return !resp.Allowed && resp.Result.Message == "no" },
+		},
+		{
+			name:  "no patch",
+			admit: func(admissionv1.AdmissionReview) *admissionv1.AdmissionResponse { return Allowed() },
+			check: func(resp *admissionv1.AdmissionResponse) bool { return resp.Allowed && resp.Patch == nil },
+		},
+		{
+			name:  "no object",
+			admit: func(admissionv1.AdmissionReview) *admissionv1.AdmissionResponse { return Allowed() },
+			ar: func(ar admissionv1.AdmissionReview) admissionv1.AdmissionReview {
+				ar.Request.Operation, ar.Request.Object = admissionv1.Delete, runtime.RawExtension{}
+				return ar
+			},
+			check: func(resp *admissionv1.AdmissionResponse) bool { return resp.Allowed && resp.Patch == nil },
+		},
+		{
+			name:  "undecodable object",
+			admit: func(admissionv1.AdmissionReview) *admissionv1.AdmissionResponse { return Allowed() },
+			ar: func(ar admissionv1.AdmissionReview) admissionv1.AdmissionReview {
+				ar.Request.Object.Raw = []byte(`[]`)
+				return ar
+			},
+			check: func(resp *admissionv1.AdmissionResponse) bool { return !resp.Allowed },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ar := podReview(t, &corev1.Pod{})
+			if tt.ar != nil {
+				ar = tt.ar(ar)
+			}
+			if resp := MutateOnce("example.com/marker", tt.admit)(ar); !tt.check(resp) {
+				t.Errorf("Unexpected response %+v", resp)
+			}
+		})
+	}
+}
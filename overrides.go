@@ -0,0 +1,110 @@
+package autocertwebhook
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// webhookOverrides holds the registration settings of Config that override
+// those of every hook.
+type webhookOverrides struct {
+	failurePolicy     *admissionregistrationv1.FailurePolicyType
+	sideEffects       *admissionregistrationv1.SideEffectClass
+	timeoutSeconds    *int32
+	namespaceSelector *metav1.LabelSelector
+}
+
+// parseWebhookOverrides parses and validates the registration overrides of
+// cfg. They require managed webhook configurations.
+func parseWebhookOverrides(cfg *Config, manageWebhooks bool) (webhookOverrides, error) {
+	var overrides webhookOverrides
+	var errs []error
+	var set []string
+
+	if cfg.WebhookFailurePolicy != "" {
+		set = append(set, "WebhookFailurePolicy")
+		switch policy := admissionregistrationv1.FailurePolicyType(cfg.WebhookFailurePolicy); policy {
+		case admissionregistrationv1.Fail, admissionregistrationv1.Ignore:
+			overrides.failurePolicy = &policy
+		default:
+			errs = append(errs, fieldErrorf("WebhookFailurePolicy", "webhook failure policy must be Fail or Ignore, got %q", cfg.WebhookFailurePolicy))
+		}
+	}
+
+	if cfg.WebhookSideEffects != "" {
+		set = append(set, "WebhookSideEffects")
+		switch sideEffects := admissionregistrationv1.SideEffectClass(cfg.WebhookSideEffects); sideEffects {
+		case admissionregistrationv1.SideEffectClassNone, admissionregistrationv1.SideEffectClassNoneOnDryRun:
+			overrides.sideEffects = &sideEffects
+		default:
+			errs = append(errs, fieldErrorf("WebhookSideEffects", "webhook side effects must be None or NoneOnDryRun, got %q", cfg.WebhookSideEffects))
+		}
+	}
+
+	if cfg.WebhookTimeoutSeconds != 0 {
+		set = append(set, "WebhookTimeoutSeconds")
+		if cfg.WebhookTimeoutSeconds < 1 || cfg.WebhookTimeoutSeconds > 30 {
+			errs = append(errs, fieldErrorf("WebhookTimeoutSeconds", "webhook timeout must be between 1 and 30 seconds, got %d", cfg.WebhookTimeoutSeconds))
+		} else {
+			timeout := cfg.WebhookTimeoutSeconds
+			overrides.timeoutSeconds = &timeout
+		}
+	}
+
+	if cfg.WebhookNamespaceSelector != "" {
+		set = append(set, "WebhookNamespaceSelector")
+		selector, err := parseLabelSelector(cfg.WebhookNamespaceSelector)
+		if err != nil {
+			errs = append(errs, fieldErrorf("WebhookNamespaceSelector", "invalid webhook namespace selector: %v", err))
+		} else {
+			overrides.namespaceSelector = selector
+		}
+	}
+
+	if len(set) > 0 && !manageWebhooks {
+		errs = append(errs, withFields(errors.New("webhook registration overrides require managed webhook configurations"), append(set, "ManageWebhookConfigurations")...))
+	}
+	return overrides, errors.Join(errs...)
+}
+
+// parseLabelSelector parses a label selector in kubectl syntax, e.g.
+// "env in (prod),!exempt", or as a JSON LabelSelector.
+func parseLabelSelector(s string) (*metav1.LabelSelector, error) {
+	if strings.HasPrefix(strings.TrimSpace(s), "{") {
+		var selector metav1.LabelSelector
+		if err := json.Unmarshal([]byte(s), &selector); err != nil {
+			return nil, err
+		}
+		// Reject invalid operators and values up front
+		if _, err := metav1.LabelSelectorAsSelector(&selector); err != nil {
+			return nil, err
+		}
+		return &selector, nil
+	}
+	return metav1.ParseToLabelSelector(s)
+}
+
+// apply returns the hooks with the overridden registration settings.
+func (o webhookOverrides) apply(hooks []Hook) []Hook {
+	overridden := make([]Hook, len(hooks))
+	for i, hook := range hooks {
+		if o.failurePolicy != nil {
+			hook.FailurePolicy = o.failurePolicy
+		}
+		if o.sideEffects != nil {
+			hook.SideEffects = o.sideEffects
+		}
+		if o.timeoutSeconds != nil {
+			hook.TimeoutSeconds = o.timeoutSeconds
+		}
+		if o.namespaceSelector != nil {
+			hook.NamespaceSelector = o.namespaceSelector
+		}
+		overridden[i] = hook
+	}
+	return overridden
+}
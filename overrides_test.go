@@ -0,0 +1,77 @@
+package autocertwebhook
+
+import (
+	"strings"
+	"testing"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestParseWebhookOverrides(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		managed bool
+		wantErr string
+	}{
+		{name: "none"},
+		{
+			name:    "all",
+			cfg:     Config{WebhookFailurePolicy: "Ignore", WebhookSideEffects: "NoneOnDryRun", WebhookTimeoutSeconds: 5, WebhookNamespaceSelector: "env in (prod),!exempt"},
+			managed: true,
+		},
+		{name: "JSON selector", cfg: Config{WebhookNamespaceSelector: `{"matchLabels":{"env":"prod"}}`}, managed: true},
+		{name: "invalid failure policy", cfg: Config{WebhookFailurePolicy: "ignore"}, managed: true, wantErr: "must be Fail or Ignore"},
+		{name: "invalid side effects", cfg: Config{WebhookSideEffects: "Some"}, managed: true, wantErr: "must be None or NoneOnDryRun"},
+		{name: "timeout too long", cfg: Config{WebhookTimeoutSeconds: 31}, managed: true, wantErr: "between 1 and 30 seconds"},
+		{name: "invalid selector", cfg: Config{WebhookNamespaceSelector: "env in prod"}, managed: true, wantErr: "invalid webhook namespace selector"},
+		{name: "invalid JSON selector operator", cfg: Config{WebhookNamespaceSelector: `{"matchExpressions":[{"key":"env","operator":"Near"}]}`}, managed: true, wantErr: "invalid webhook namespace selector"},
+		{name: "unmanaged", cfg: Config{WebhookTimeoutSeconds: 5}, wantErr: "require managed webhook configurations"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseWebhookOverrides(&tt.cfg, tt.managed)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestWebhookOverrides_apply(t *testing.T) {
+	hooks := []Hook{
+		{Path: "/validate", TimeoutSeconds: ptr.To[int32](3), ObjectSelector: &metav1.LabelSelector{}},
+		{Path: "/mutate", FailurePolicy: ptr.To(admissionregistrationv1.Fail)},
+	}
+	overrides, err := parseWebhookOverrides(&Config{WebhookFailurePolicy: "Ignore", WebhookNamespaceSelector: "env=prod"}, true)
+	if err != nil {
+		t.Fatalf("parseWebhookOverrides() error = %v", err)
+	}
+
+	got := overrides.apply(hooks)
+	for i, hook := range got {
+		if hook.FailurePolicy == nil || *hook.FailurePolicy != admissionregistrationv1.Ignore {
+			t.Errorf("hook[%d]: FailurePolicy = %v, want Ignore", i, hook.FailurePolicy)
+		}
+		if hook.NamespaceSelector == nil || hook.NamespaceSelector.MatchLabels["env"] != "prod" {
+			t.Errorf("hook[%d]: NamespaceSelector = %v", i, hook.NamespaceSelector)
+		}
+	}
+	// Settings that are not overridden are kept
+	if got[0].TimeoutSeconds == nil || *got[0].TimeoutSeconds != 3 || got[0].ObjectSelector == nil {
+		t.Errorf("hook[0]: settings not overridden were changed: %+v", got[0])
+	}
+	// The hooks of the caller are not modified
+	if *hooks[1].FailurePolicy != admissionregistrationv1.Fail {
+		t.Errorf("apply() modified the original hooks")
+	}
+}
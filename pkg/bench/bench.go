@@ -0,0 +1,385 @@
+// Package bench generates synthetic AdmissionReview load against a running
+// webhook server and reports latency percentiles, for capacity planning before
+// pointing production API servers at a hook.
+package bench
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+const (
+	defaultConcurrency    = 10
+	defaultDuration       = 10 * time.Second
+	defaultObjectSize     = 2 * 1024
+	defaultRequestTimeout = 10 * time.Second
+)
+
+// Config holds the load test configuration.
+type Config struct {
+	// URL is the hook URL, e.g. "https://localhost:8443/validate-pods". Required.
+	URL string
+
+	// Concurrency is the number of concurrent clients. Defaults to 10.
+	Concurrency int
+
+	// Requests is the total number of requests to send.
+	// If zero, requests are sent until Duration elapses.
+	Requests int
+
+	// Duration bounds the test when Requests is zero. Defaults to 10s.
+	Duration time.Duration
+
+	// ObjectSize is the approximate size in bytes of the Pod sent in each
+	// review. Defaults to 2KiB.
+	ObjectSize int
+
+	// Operation is the admission operation. Defaults to CREATE.
+	Operation admissionv1.Operation
+
+	// Namespace is the namespace of the generated Pods. Defaults to "default".
+	Namespace string
+
+	// RequestTimeout is the timeout of a single request. Defaults to 10s.
+	RequestTimeout time.Duration
+
+	// CACert is a PEM bundle used to verify the server certificate.
+	// If empty, the system roots are used.
+	CACert []byte
+
+	// InsecureSkipVerify disables server certificate verification.
+	InsecureSkipVerify bool
+
+	// Client overrides the HTTP client. CACert and InsecureSkipVerify are
+	// ignored when set.
+	Client *http.Client
+}
+
+// applyDefaults fills in default values.
+func (c *Config) applyDefaults() {
+	if c.Concurrency <= 0 {
+		c.Concurrency = defaultConcurrency
+	}
+	if c.Requests <= 0 && c.Duration <= 0 {
+		c.Duration = defaultDuration
+	}
+	if c.ObjectSize <= 0 {
+		c.ObjectSize = defaultObjectSize
+	}
+	if c.Operation == "" {
+		c.Operation = admissionv1.Create
+	}
+	if c.Namespace == "" {
+		c.Namespace = "default"
+	}
+	if c.RequestTimeout <= 0 {
+		c.RequestTimeout = defaultRequestTimeout
+	}
+}
+
+// Result is the outcome of a load test.
+type Result struct {
+	// Requests is the number of requests sent.
+	Requests int
+	// Allowed and Denied count valid admission responses.
+	Allowed int
+	Denied  int
+	// Errors counts transport errors, non-200 responses and invalid responses.
+	Errors int
+	// StatusCodes counts responses by HTTP status code.
+	StatusCodes map[int]int
+	// Duration is the wall time of the test.
+	Duration time.Duration
+
+	// Latency statistics of all requests that received a response.
+	Min  time.Duration
+	Mean time.Duration
+	P50  time.Duration
+	P90  time.Duration
+	P99  time.Duration
+	Max  time.Duration
+}
+
+// Throughput returns the number of requests per second.
+func (r *Result) Throughput() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Requests) / r.Duration.Seconds()
+}
+
+// Print writes a human-readable summary.
+func (r *Result) Print(w io.Writer) error {
+	codes := make([]int, 0, len(r.StatusCodes))
+	for code := range r.StatusCodes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	statuses := make([]string, 0, len(codes))
+	for _, code := range codes {
+		statuses = append(statuses, fmt.Sprintf("%d=%d", code, r.StatusCodes[code]))
+	}
+
+	_, err := fmt.Fprintf(w,
+		"requests:   %d in %v (%.1f/s)\n"+
+			"responses:  %d allowed, %d denied, %d errors\n"+
+			"status:     %s\n"+
+			"latency:    min %v, mean %v, p50 %v, p90 %v, p99 %v, max %v\n",
+		r.Requests, r.Duration.Round(time.Millisecond), r.Throughput(),
+		r.Allowed, r.Denied, r.Errors,
+		strings.Join(statuses, " "),
+		r.Min, r.Mean, r.P50, r.P90, r.P99, r.Max)
+	return err
+}
+
+// sample is the outcome of a single request.
+type sample struct {
+	latency time.Duration
+	code    int
+	allowed bool
+	err     bool
+}
+
+// Run sends AdmissionReviews to the hook until the request count or duration
+// is reached, or the context is done, and returns the aggregated result.
+func Run(ctx context.Context, config Config) (*Result, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("URL is required")
+	}
+	config.applyDefaults()
+
+	client := config.Client
+	if client == nil {
+		var err error
+		if client, err = newClient(config); err != nil {
+			return nil, err
+		}
+	}
+
+	object, err := generatePod(config.Namespace, config.ObjectSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Requests <= 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Duration)
+		defer cancel()
+	}
+
+	var (
+		sent    atomic.Int64
+		mu      sync.Mutex
+		samples []sample
+		wg      sync.WaitGroup
+	)
+	start := time.Now()
+	for i := 0; i < config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var local []sample
+			for ctx.Err() == nil {
+				if config.Requests > 0 && sent.Add(1) > int64(config.Requests) {
+					break
+				}
+				s, ok := send(ctx, client, config, object)
+				if !ok {
+					break
+				}
+				local = append(local, s)
+			}
+			mu.Lock()
+			samples = append(samples, local...)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return summarize(samples, time.Since(start)), nil
+}
+
+// send sends a single review. It returns false if the request was aborted
+// because the test ended.
+func send(ctx context.Context, client *http.Client, config Config, object []byte) (sample, bool) {
+	body, err := json.Marshal(newReview(config, object))
+	if err != nil {
+		return sample{err: true}, true
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, config.RequestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, config.URL, bytes.NewReader(body))
+	if err != nil {
+		return sample{err: true}, true
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return sample{}, false
+		}
+		return sample{err: true}, true
+	}
+	defer resp.Body.Close()
+
+	var review admissionv1.AdmissionReview
+	decodeErr := json.NewDecoder(resp.Body).Decode(&review)
+	s := sample{latency: time.Since(start), code: resp.StatusCode}
+	switch {
+	case resp.StatusCode != http.StatusOK, decodeErr != nil, review.Response == nil:
+		s.err = true
+	default:
+		s.allowed = review.Response.Allowed
+	}
+	return s, true
+}
+
+// summarize aggregates samples into a result.
+func summarize(samples []sample, duration time.Duration) *Result {
+	result := &Result{
+		Requests:    len(samples),
+		StatusCodes: make(map[int]int),
+		Duration:    duration,
+	}
+
+	latencies := make([]time.Duration, 0, len(samples))
+	var total time.Duration
+	for _, s := range samples {
+		switch {
+		case s.err:
+			result.Errors++
+		case s.allowed:
+			result.Allowed++
+		default:
+			result.Denied++
+		}
+		if s.code == 0 {
+			continue
+		}
+		result.StatusCodes[s.code]++
+		latencies = append(latencies, s.latency)
+		total += s.latency
+	}
+	if len(latencies) == 0 {
+		return result
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	result.Min = latencies[0]
+	result.Max = latencies[len(latencies)-1]
+	result.Mean = total / time.Duration(len(latencies))
+	result.P50 = percentile(latencies, 0.50)
+	result.P90 = percentile(latencies, 0.90)
+	result.P99 = percentile(latencies, 0.99)
+	return result
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(p*float64(len(sorted))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// newReview returns an AdmissionReview for a Pod with a unique UID.
+func newReview(config Config, object []byte) admissionv1.AdmissionReview {
+	req := &admissionv1.AdmissionRequest{
+		UID:       uuid.NewUUID(),
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		Resource:  metav1.GroupVersionResource{Version: "v1", Resource: "pods"},
+		Name:      "bench",
+		Namespace: config.Namespace,
+		Operation: config.Operation,
+	}
+	switch config.Operation {
+	case admissionv1.Delete:
+		req.OldObject = runtime.RawExtension{Raw: object}
+	case admissionv1.Update:
+		req.Object = runtime.RawExtension{Raw: object}
+		req.OldObject = runtime.RawExtension{Raw: object}
+	default:
+		req.Object = runtime.RawExtension{Raw: object}
+	}
+
+	return admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: admissionv1.SchemeGroupVersion.String(),
+			Kind:       "AdmissionReview",
+		},
+		Request: req,
+	}
+}
+
+// generatePod returns a serialized Pod padded with an annotation to about size bytes.
+func generatePod(namespace string, size int) ([]byte, error) {
+	pod := &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "bench",
+			Namespace: namespace,
+			Labels:    map[string]string{"app": "bench"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Image: "registry.example.com/bench:latest"}},
+		},
+	}
+
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal pod: %w", err)
+	}
+	// The padding annotation adds its key and quotes on top of its value.
+	const overhead = len(`,"annotations":{"bench/padding":""}`)
+	if padding := size - len(raw) - overhead; padding > 0 {
+		pod.Annotations = map[string]string{"bench/padding": strings.Repeat("x", padding)}
+		if raw, err = json.Marshal(pod); err != nil {
+			return nil, fmt.Errorf("failed to marshal pod: %w", err)
+		}
+	}
+	return raw, nil
+}
+
+// newClient creates an HTTP client for the configured TLS settings.
+func newClient(config Config) (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+	if len(config.CACert) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(config.CACert) {
+			return nil, fmt.Errorf("no certificates found in CA bundle")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.MaxIdleConnsPerHost = config.Concurrency
+	return &http.Client{Transport: transport}, nil
+}
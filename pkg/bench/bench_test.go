@@ -0,0 +1,139 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestRun(t *testing.T) {
+	var count atomic.Int64
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var review admissionv1.AdmissionReview
+		if err := json.NewDecoder(r.Body).Decode(&review); err != nil || review.Request == nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		// Deny every third request, fail every fifth.
+		n := count.Add(1)
+		if n%5 == 0 {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		review.Response = &admissionv1.AdmissionResponse{UID: review.Request.UID, Allowed: n%3 != 0}
+		review.Request = nil
+		_ = json.NewEncoder(w).Encode(review)
+	}))
+	defer server.Close()
+
+	result, err := Run(context.Background(), Config{
+		URL:         server.URL + "/validate",
+		Concurrency: 4,
+		Requests:    60,
+		Client:      server.Client(),
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if result.Requests != 60 {
+		t.Errorf("Requests: got %d, want 60", result.Requests)
+	}
+	if result.Errors != 12 {
+		t.Errorf("Errors: got %d, want 12", result.Errors)
+	}
+	if result.Allowed+result.Denied != 48 {
+		t.Errorf("Allowed+Denied: got %d, want 48", result.Allowed+result.Denied)
+	}
+	if result.StatusCodes[http.StatusOK] != 48 || result.StatusCodes[http.StatusServiceUnavailable] != 12 {
+		t.Errorf("StatusCodes: got %v", result.StatusCodes)
+	}
+	if !(result.Min <= result.P50 && result.P50 <= result.P90 && result.P90 <= result.P99 && result.P99 <= result.Max) {
+		t.Errorf("Latencies not ordered: %+v", result)
+	}
+
+	var buf bytes.Buffer
+	if err := result.Print(&buf); err != nil {
+		t.Fatalf("Print failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "200=48 503=12") {
+		t.Errorf("Expected status summary, got:\n%s", buf.String())
+	}
+}
+
+func TestRun_Duration(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(admissionv1.AdmissionReview{Response: &admissionv1.AdmissionResponse{Allowed: true}})
+	}))
+	defer server.Close()
+
+	start := time.Now()
+	result, err := Run(context.Background(), Config{
+		URL:                server.URL,
+		Concurrency:        2,
+		Duration:           200 * time.Millisecond,
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Run took %v, expected about 200ms", elapsed)
+	}
+	if result.Requests == 0 || result.Errors != 0 {
+		t.Errorf("Expected successful requests, got %d requests and %d errors", result.Requests, result.Errors)
+	}
+}
+
+func TestRun_RequiresURL(t *testing.T) {
+	if _, err := Run(context.Background(), Config{}); err == nil {
+		t.Error("Expected error without URL")
+	}
+}
+
+func TestGeneratePod(t *testing.T) {
+	for _, size := range []int{100, 2048, 64 * 1024} {
+		raw, err := generatePod("default", size)
+		if err != nil {
+			t.Fatalf("generatePod(%d) failed: %v", size, err)
+		}
+
+		pod := &corev1.Pod{}
+		if err := json.Unmarshal(raw, pod); err != nil {
+			t.Fatalf("Generated pod is invalid: %v", err)
+		}
+		if size > 1024 && len(raw) != size {
+			t.Errorf("generatePod(%d): got %d bytes", size, len(raw))
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := make([]time.Duration, 100)
+	for i := range sorted {
+		sorted[i] = time.Duration(i+1) * time.Millisecond
+	}
+
+	tests := map[float64]time.Duration{
+		0.50: 50 * time.Millisecond,
+		0.90: 90 * time.Millisecond,
+		0.99: 99 * time.Millisecond,
+	}
+	for p, want := range tests {
+		if got := percentile(sorted, p); got != want {
+			t.Errorf("percentile(%v): got %v, want %v", p, got, want)
+		}
+	}
+	if got := percentile(sorted[:1], 0.99); got != time.Millisecond {
+		t.Errorf("percentile of one sample: got %v, want 1ms", got)
+	}
+}
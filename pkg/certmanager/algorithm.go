@@ -0,0 +1,183 @@
+package certmanager
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+
+	ocrypto "github.com/openshift/library-go/pkg/crypto"
+	"github.com/openshift/library-go/pkg/operator/certrotation"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/klog/v2"
+)
+
+// SignatureAlgorithms are the valid values of Config.SignatureAlgorithm,
+// named like x509.SignatureAlgorithm. The first is the one of library-go.
+var SignatureAlgorithms = []string{
+	x509.SHA256WithRSA.String(),
+	x509.SHA384WithRSA.String(),
+	x509.ECDSAWithSHA256.String(),
+	x509.ECDSAWithSHA384.String(),
+}
+
+// rsaKeyBits is the size of the RSA keys generated, the same as library-go's.
+const rsaKeyBits = 2048
+
+// signatureAlgorithm returns the configured signature algorithm.
+func (m *Manager) signatureAlgorithm() x509.SignatureAlgorithm {
+	switch m.config.SignatureAlgorithm {
+	case x509.SHA384WithRSA.String():
+		return x509.SHA384WithRSA
+	case x509.ECDSAWithSHA256.String():
+		return x509.ECDSAWithSHA256
+	case x509.ECDSAWithSHA384.String():
+		return x509.ECDSAWithSHA384
+	default:
+		return x509.SHA256WithRSA
+	}
+}
+
+// newKey generates a private key for certificates signed with algorithm:
+// RSA keys for RSA signatures, and P-256 or P-384 keys matching the hash of
+// ECDSA signatures.
+func newKey(algorithm x509.SignatureAlgorithm) (crypto.Signer, error) {
+	switch algorithm {
+	case x509.ECDSAWithSHA256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case x509.ECDSAWithSHA384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	default:
+		return rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	}
+}
+
+// keyMatches returns whether key is of the type newKey generates for
+// algorithm.
+func keyMatches(key crypto.Signer, algorithm x509.SignatureAlgorithm) bool {
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return algorithm == x509.SHA256WithRSA || algorithm == x509.SHA384WithRSA
+	case *ecdsa.PrivateKey:
+		return (algorithm == x509.ECDSAWithSHA256 && key.Curve == elliptic.P256()) ||
+			(algorithm == x509.ECDSAWithSHA384 && key.Curve == elliptic.P384())
+	default:
+		return false
+	}
+}
+
+// keyUsage returns usage without key encipherment for keys other than RSA,
+// which cannot encipher keys.
+func keyUsage(key crypto.Signer, usage x509.KeyUsage) x509.KeyUsage {
+	if _, ok := key.(*rsa.PrivateKey); !ok {
+		usage &^= x509.KeyUsageKeyEncipherment
+	}
+	return usage
+}
+
+// caSecretsGetter replaces the CAs library-go writes to the CA secret, which
+// are always signed with SHA256-RSA and named after the secret, with CAs of
+// the same validity signed with the algorithm and subject of the Manager.
+type caSecretsGetter struct {
+	corev1client.SecretsGetter
+	m *Manager
+}
+
+// Secrets implements corev1client.SecretsGetter.
+func (g caSecretsGetter) Secrets(namespace string) corev1client.SecretInterface {
+	return caSecrets{g.SecretsGetter.Secrets(namespace), g}
+}
+
+type caSecrets struct {
+	corev1client.SecretInterface
+	getter caSecretsGetter
+}
+
+// Create implements corev1client.SecretInterface.
+func (s caSecrets) Create(ctx context.Context, secret *corev1.Secret, opts metav1.CreateOptions) (*corev1.Secret, error) {
+	if err := s.getter.resign(secret); err != nil {
+		return nil, err
+	}
+	return s.SecretInterface.Create(ctx, secret, opts)
+}
+
+// Update implements corev1client.SecretInterface.
+func (s caSecrets) Update(ctx context.Context, secret *corev1.Secret, opts metav1.UpdateOptions) (*corev1.Secret, error) {
+	if err := s.getter.resign(secret); err != nil {
+		return nil, err
+	}
+	return s.SecretInterface.Update(ctx, secret, opts)
+}
+
+// resign replaces the CA in secret if it is outdated.
+func (g caSecretsGetter) resign(secret *corev1.Secret) error {
+	m := g.m
+	if secret.Name != m.config.CASecretName || !certificateOutdated(secret, m.caOutdated) {
+		return nil
+	}
+	certs, err := certutil.ParseCertsPEM(secret.Data["tls.crt"])
+	if err != nil {
+		return err
+	}
+	original := certs[0]
+	algorithm := m.signatureAlgorithm()
+	subject, err := m.config.CASubject.name(m.caSubjectData(), original.Subject.CommonName)
+	if err != nil {
+		return err
+	}
+
+	key, err := newKey(algorithm)
+	if err != nil {
+		return fmt.Errorf("failed to generate CA key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	skid := subjectKeyID(key.Public())
+	template := &x509.Certificate{
+		Subject:               subject,
+		SignatureAlgorithm:    algorithm,
+		NotBefore:             original.NotBefore,
+		NotAfter:              original.NotAfter,
+		SerialNumber:          serial,
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		AuthorityKeyId:        skid,
+		SubjectKeyId:          skid,
+	}
+	template.KeyUsage = keyUsage(key, template.KeyUsage)
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return fmt.Errorf("failed to sign CA: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return err
+	}
+
+	certPEM, keyPEM := &bytes.Buffer{}, &bytes.Buffer{}
+	config := &ocrypto.TLSCertificateConfig{Certs: []*x509.Certificate{cert}, Key: key}
+	if err := config.WriteCertConfig(certPEM, keyPEM); err != nil {
+		return err
+	}
+	secret.Data["tls.crt"] = certPEM.Bytes()
+	secret.Data["tls.key"] = keyPEM.Bytes()
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[certrotation.CertificateIssuer] = cert.Issuer.CommonName
+	klog.V(2).Infof("Signed CA %q in secret %s/%s with %s", cert.Subject.CommonName, secret.Namespace, secret.Name, algorithm)
+	return nil
+}
@@ -0,0 +1,138 @@
+package certmanager
+
+import (
+	"context"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/openshift/library-go/pkg/crypto"
+	"github.com/openshift/library-go/pkg/operator/events"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/utils/clock"
+)
+
+func TestManager_SignatureAlgorithm(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	// run syncs a manager signing with algorithm until the CA and serving
+	// certificate in the secrets are signed with it, and returns them.
+	run := func(algorithm x509.SignatureAlgorithm) (*x509.Certificate, *x509.Certificate) {
+		t.Helper()
+		m := newTestManager(client)
+		m.config.SignatureAlgorithm = algorithm.String()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Start(ctx)
+		}()
+		defer func() {
+			cancel()
+			<-done
+		}()
+
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			ca, serving, bundle := issuedCertificates(t, client)
+			if ca != nil && serving != nil && ca.SignatureAlgorithm == algorithm && serving.SignatureAlgorithm == algorithm {
+				// The serving certificate may be signed by a CA overwritten
+				// in the fake clientset, which does not detect conflicts,
+				// but still in the bundle.
+				pool := x509.NewCertPool()
+				for _, cert := range bundle {
+					pool.AddCert(cert)
+				}
+				if _, err := serving.Verify(x509.VerifyOptions{DNSName: "wh.ns.svc", Roots: pool}); err == nil {
+					return ca, serving
+				}
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for certificates signed with %s", algorithm)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	ca, serving := run(x509.ECDSAWithSHA384)
+	for name, cert := range map[string]*x509.Certificate{"CA": ca, "serving certificate": serving} {
+		if cert.PublicKeyAlgorithm != x509.ECDSA {
+			t.Errorf("%s has a %s key, want ECDSA", name, cert.PublicKeyAlgorithm)
+		}
+	}
+	if serving.KeyUsage&x509.KeyUsageKeyEncipherment != 0 {
+		t.Error("Expected no key encipherment usage for an ECDSA key")
+	}
+
+	// Changing the algorithm replaces both certificates.
+	rsaCA, rsaServing := run(x509.SHA256WithRSA)
+	if rsaCA.SerialNumber.Cmp(ca.SerialNumber) == 0 || rsaCA.PublicKeyAlgorithm != x509.RSA {
+		t.Errorf("Expected a new RSA CA, got serial %s with a %s key", rsaCA.SerialNumber, rsaCA.PublicKeyAlgorithm)
+	}
+	if rsaServing.PublicKeyAlgorithm != x509.RSA {
+		t.Errorf("Serving certificate has a %s key, want RSA", rsaServing.PublicKeyAlgorithm)
+	}
+}
+
+// issuedCertificates returns the CA, serving certificate and CA bundle in the
+// API server, nil if not issued yet.
+func issuedCertificates(t *testing.T, client kubernetes.Interface) (ca, serving *x509.Certificate, bundle []*x509.Certificate) {
+	t.Helper()
+	ctx := context.Background()
+	for name, cert := range map[string]**x509.Certificate{"wh-ca": &ca, "wh-cert": &serving} {
+		secret, err := client.CoreV1().Secrets("ns").Get(ctx, name, metav1.GetOptions{})
+		if err != nil || len(secret.Data["tls.crt"]) == 0 {
+			continue
+		}
+		certs, err := certutil.ParseCertsPEM(secret.Data["tls.crt"])
+		if err != nil {
+			t.Fatalf("Failed to parse certificate of secret %s: %v", name, err)
+		}
+		*cert = certs[0]
+	}
+	if cm, err := client.CoreV1().ConfigMaps("ns").Get(ctx, "wh-ca-bundle", metav1.GetOptions{}); err == nil {
+		bundle, _ = certutil.ParseCertsPEM([]byte(cm.Data["ca-bundle.crt"]))
+	}
+	return ca, serving, bundle
+}
+
+func TestManager_checkCAReplacement_signatureAlgorithm(t *testing.T) {
+	previous, err := crypto.MakeSelfSignedCAConfig("previous", 48*time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	certPEM, keyPEM, err := previous.GetPEMBytes()
+	if err != nil {
+		t.Fatalf("Failed to encode CA: %v", err)
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "wh-ca", Namespace: "ns"},
+		Data:       map[string][]byte{"tls.crt": certPEM, "tls.key": keyPEM},
+	}
+	m := newTestManager(fake.NewSimpleClientset())
+	m.config.SignatureAlgorithm = x509.ECDSAWithSHA256.String()
+	getter := caSecretsGetter{m.k8sClient.CoreV1(), m}
+	if err := getter.resign(secret); err != nil {
+		t.Fatalf("resign() error = %v", err)
+	}
+	current, err := certutil.ParseCertsPEM(secret.Data["tls.crt"])
+	if err != nil {
+		t.Fatalf("Failed to parse resigned CA: %v", err)
+	}
+	if current[0].SignatureAlgorithm != x509.ECDSAWithSHA256 || current[0].Subject.CommonName != "previous" {
+		t.Fatalf("resign() = %s CA %q, want ECDSA-SHA256 CA %q", current[0].SignatureAlgorithm, current[0].Subject.CommonName, "previous")
+	}
+
+	recorder := events.NewInMemoryRecorder("test", clock.RealClock{})
+	m.SetEventRecorder(recorder)
+	m.checkCAReplacement(current[0], previous.Certs)
+	if len(recorder.Events()) != 0 {
+		t.Errorf("Expected a change of the signature algorithm not to be reported, got %v", recorder.Events())
+	}
+}
@@ -0,0 +1,256 @@
+package certmanager
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"maps"
+	"strconv"
+	"time"
+
+	"github.com/openshift/library-go/pkg/operator/certrotation"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/klog/v2"
+)
+
+// encryptedKeyType is the PEM block type of a private key encrypted with a
+// passphrase by ExportCA. Its headers hold the parameters of the encryption:
+// the key is sealed with AES-256-GCM under a key derived from the passphrase
+// with PBKDF2-SHA256.
+const encryptedKeyType = "AUTO-CERT-WEBHOOK ENCRYPTED PRIVATE KEY"
+
+// pbkdf2Iterations is the PBKDF2 iteration count of new backups, as
+// recommended by OWASP for PBKDF2-HMAC-SHA256.
+const pbkdf2Iterations = 600000
+
+// ExportCA returns the certificate and private key of the CA secret of
+// config as PEM, to be restored with RestoreCA or Config.CABackup after the
+// secret is lost, e.g. in a cluster rebuild. Restoring it instead of minting a
+// new CA keeps the CA trusted by external clients valid. If passphrase is not
+// empty, the private key is encrypted with it.
+func ExportCA(ctx context.Context, client kubernetes.Interface, config Config, passphrase []byte) ([]byte, error) {
+	client = dataKeysOf(config).client(client)
+	secret, err := client.CoreV1().Secrets(config.Namespace).Get(ctx, config.CASecretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CA secret %s/%s: %w", config.Namespace, config.CASecretName, err)
+	}
+	certPEM, keyPEM := secret.Data["tls.crt"], secret.Data["tls.key"]
+	if len(certPEM) == 0 || len(keyPEM) == 0 {
+		return nil, fmt.Errorf("CA secret %s/%s holds no CA yet", config.Namespace, config.CASecretName)
+	}
+
+	if len(passphrase) > 0 {
+		block, _ := pem.Decode(keyPEM)
+		if block == nil {
+			return nil, fmt.Errorf("CA secret %s/%s holds no PEM private key", config.Namespace, config.CASecretName)
+		}
+		encrypted, err := encryptKey(block, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt CA private key: %w", err)
+		}
+		keyPEM = pem.EncodeToMemory(encrypted)
+	}
+	return append(bytes.Clone(certPEM), keyPEM...), nil
+}
+
+// RestoreCA writes a CA exported by ExportCA into the CA secret of config,
+// creating the secret if it does not exist. It fails if the secret already
+// holds another CA, which has to be deleted first. The CA bundle and serving
+// certificate follow on the next sync of the running Manager.
+func RestoreCA(ctx context.Context, client kubernetes.Interface, config Config, backup, passphrase []byte) error {
+	client = dataKeysOf(config).client(client)
+	restored, err := caSecretFromBackup(config, backup, passphrase)
+	if err != nil {
+		return err
+	}
+
+	secrets := client.CoreV1().Secrets(config.Namespace)
+	current, err := secrets.Get(ctx, config.CASecretName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = secrets.Create(ctx, restored, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if len(current.Data["tls.crt"]) > 0 {
+		if bytes.Equal(current.Data["tls.crt"], restored.Data["tls.crt"]) {
+			return nil
+		}
+		return fmt.Errorf("CA secret %s/%s already holds another CA; delete it before restoring", config.Namespace, config.CASecretName)
+	}
+	if current.Data == nil {
+		current.Data = map[string][]byte{}
+	}
+	maps.Copy(current.Data, restored.Data)
+	if current.Annotations == nil {
+		current.Annotations = map[string]string{}
+	}
+	maps.Copy(current.Annotations, restored.Annotations)
+	_, err = secrets.Update(ctx, current, metav1.UpdateOptions{})
+	return err
+}
+
+// caSecretFromBackup returns the CA secret of config holding the CA of a
+// backup, annotated with its validity the way library-go expects.
+func caSecretFromBackup(config Config, backup, passphrase []byte) (*corev1.Secret, error) {
+	var certPEM, keyPEM []byte
+	rest := backup
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case certutil.CertificateBlockType:
+			certPEM = append(certPEM, pem.EncodeToMemory(block)...)
+		case encryptedKeyType:
+			if len(passphrase) == 0 {
+				return nil, fmt.Errorf("CA backup is encrypted, but no passphrase was given")
+			}
+			decrypted, err := decryptKey(block, passphrase)
+			if err != nil {
+				return nil, err
+			}
+			keyPEM = pem.EncodeToMemory(decrypted)
+		default:
+			keyPEM = pem.EncodeToMemory(block)
+		}
+	}
+	if len(certPEM) == 0 || len(keyPEM) == 0 {
+		return nil, fmt.Errorf("CA backup must contain a certificate and a private key")
+	}
+
+	certs, err := certutil.ParseCertsPEM(certPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA backup certificate: %w", err)
+	}
+	ca := certs[0]
+	if !ca.IsCA {
+		return nil, fmt.Errorf("CA backup certificate %q is not a CA", ca.Subject.CommonName)
+	}
+	if time.Now().After(ca.NotAfter) {
+		klog.Warningf("Restored CA %q expired at %s and will be replaced", ca.Subject.CommonName, ca.NotAfter.UTC().Format(time.RFC3339))
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      config.CASecretName,
+			Namespace: config.Namespace,
+			Annotations: map[string]string{
+				certrotation.CertificateNotBeforeAnnotation: ca.NotBefore.Format(time.RFC3339),
+				certrotation.CertificateNotAfterAnnotation:  ca.NotAfter.Format(time.RFC3339),
+				certrotation.CertificateIssuer:              ca.Issuer.CommonName,
+			},
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			"tls.crt": certPEM,
+			"tls.key": keyPEM,
+		},
+	}, nil
+}
+
+// restoreCASecret creates the CA secret from Config.CABackup.
+func (m *Manager) restoreCASecret(ctx context.Context) (*corev1.Secret, error) {
+	secret, err := caSecretFromBackup(m.config, m.config.CABackup, m.config.CABackupPassphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore CA backup: %w", err)
+	}
+	secret, err = m.k8sClient.CoreV1().Secrets(m.config.Namespace).Create(ctx, secret, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if certs, err := certutil.ParseCertsPEM(secret.Data["tls.crt"]); err == nil {
+		klog.Infof("Restored CA secret %s/%s from backup", secret.Namespace, secret.Name)
+		m.eventRecorder.Eventf("CARestored", "Restored CA in secret %s/%s from backup: serial %s, expires %s",
+			secret.Namespace, secret.Name, certs[0].SerialNumber.Text(16), certs[0].NotAfter.UTC().Format(time.RFC3339))
+	}
+	return secret, nil
+}
+
+// encryptKey encrypts a PEM private key block with passphrase.
+func encryptKey(block *pem.Block, passphrase []byte) (*pem.Block, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := keyCipher(passphrase, salt, pbkdf2Iterations)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return &pem.Block{
+		Type: encryptedKeyType,
+		Headers: map[string]string{
+			"Key-Type":   block.Type,
+			"KDF":        "PBKDF2-SHA256",
+			"Iterations": strconv.Itoa(pbkdf2Iterations),
+			"Salt":       hex.EncodeToString(salt),
+			"Nonce":      hex.EncodeToString(nonce),
+		},
+		// The key type is authenticated so that it cannot be swapped.
+		Bytes: aead.Seal(nil, nonce, block.Bytes, []byte(block.Type)),
+	}, nil
+}
+
+// decryptKey decrypts a PEM private key block encrypted by encryptKey.
+func decryptKey(block *pem.Block, passphrase []byte) (*pem.Block, error) {
+	if kdf := block.Headers["KDF"]; kdf != "PBKDF2-SHA256" {
+		return nil, fmt.Errorf("unsupported key derivation function %q in CA backup", kdf)
+	}
+	iterations, err := strconv.Atoi(block.Headers["Iterations"])
+	if err != nil || iterations <= 0 {
+		return nil, fmt.Errorf("invalid iteration count %q in CA backup", block.Headers["Iterations"])
+	}
+	salt, err := hex.DecodeString(block.Headers["Salt"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt in CA backup: %w", err)
+	}
+	nonce, err := hex.DecodeString(block.Headers["Nonce"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid nonce in CA backup: %w", err)
+	}
+	aead, err := keyCipher(passphrase, salt, iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce length %d in CA backup", len(nonce))
+	}
+	keyType := block.Headers["Key-Type"]
+	der, err := aead.Open(nil, nonce, block.Bytes, []byte(keyType))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt CA backup private key, wrong passphrase?")
+	}
+	return &pem.Block{Type: keyType, Bytes: der}, nil
+}
+
+// keyCipher returns the AES-256-GCM cipher keyed by passphrase.
+func keyCipher(passphrase, salt []byte, iterations int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, string(passphrase), salt, iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,122 @@
+package certmanager
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/library-go/pkg/crypto"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestExportRestoreCA(t *testing.T) {
+	ca, err := crypto.MakeSelfSignedCAConfig("test-ca", 48*time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	certPEM, keyPEM, err := ca.GetPEMBytes()
+	if err != nil {
+		t.Fatalf("Failed to encode CA: %v", err)
+	}
+	config := Config{Namespace: "ns", CASecretName: "wh-ca"}
+	source := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "wh-ca", Namespace: "ns"},
+		Data:       map[string][]byte{"tls.crt": certPEM, "tls.key": keyPEM},
+	})
+
+	tests := []struct {
+		name       string
+		passphrase string
+	}{
+		{"plain", ""},
+		{"encrypted", "secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			backup, err := ExportCA(ctx, source, config, []byte(tt.passphrase))
+			if err != nil {
+				t.Fatalf("ExportCA() error = %v", err)
+			}
+			if encrypted := strings.Contains(string(backup), encryptedKeyType); encrypted != (tt.passphrase != "") {
+				t.Errorf("ExportCA() encrypted = %v, want %v", encrypted, tt.passphrase != "")
+			}
+			if tt.passphrase != "" {
+				if err := RestoreCA(ctx, fake.NewSimpleClientset(), config, backup, []byte("wrong")); err == nil {
+					t.Error("RestoreCA() with a wrong passphrase succeeded")
+				}
+			}
+
+			target := fake.NewSimpleClientset()
+			if err := RestoreCA(ctx, target, config, backup, []byte(tt.passphrase)); err != nil {
+				t.Fatalf("RestoreCA() error = %v", err)
+			}
+			secret, err := target.CoreV1().Secrets("ns").Get(ctx, "wh-ca", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("Failed to get restored secret: %v", err)
+			}
+			if !bytes.Equal(secret.Data["tls.crt"], certPEM) || !bytes.Equal(secret.Data["tls.key"], keyPEM) {
+				t.Error("Restored secret does not hold the exported CA")
+			}
+			if _, err := crypto.GetCAFromBytes(secret.Data["tls.crt"], secret.Data["tls.key"]); err != nil {
+				t.Errorf("Restored CA is unusable: %v", err)
+			}
+
+			// Restoring again is a no-op
+			if err := RestoreCA(ctx, target, config, backup, []byte(tt.passphrase)); err != nil {
+				t.Errorf("RestoreCA() of the same CA error = %v", err)
+			}
+		})
+	}
+
+	t.Run("another CA", func(t *testing.T) {
+		other, err := crypto.MakeSelfSignedCAConfig("other-ca", 48*time.Hour)
+		if err != nil {
+			t.Fatalf("Failed to create CA: %v", err)
+		}
+		otherCert, otherKey, err := other.GetPEMBytes()
+		if err != nil {
+			t.Fatalf("Failed to encode CA: %v", err)
+		}
+		if err := RestoreCA(context.Background(), source, config, append(otherCert, otherKey...), nil); err == nil {
+			t.Error("RestoreCA() over another CA succeeded")
+		}
+	})
+}
+
+func TestManager_restoresCABackup(t *testing.T) {
+	ca, err := crypto.MakeSelfSignedCAConfig("test-ca", 48*time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	certPEM, keyPEM, err := ca.GetPEMBytes()
+	if err != nil {
+		t.Fatalf("Failed to encode CA: %v", err)
+	}
+
+	client := fake.NewSimpleClientset()
+	m := newTestManager(client)
+	m.config.CABackup = append(certPEM, keyPEM...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Start(ctx)
+	}()
+
+	status := waitForStatus(t, m, func(status Status) bool {
+		return status.CA != nil && status.ServingCertificate != nil
+	})
+	if status.CA.SerialNumber.Cmp(ca.Certs[0].SerialNumber) != 0 {
+		t.Errorf("CA serial number = %s, want the restored %s", status.CA.SerialNumber.Text(16), ca.Certs[0].SerialNumber.Text(16))
+	}
+
+	cancel()
+	<-done
+}
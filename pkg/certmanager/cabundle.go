@@ -0,0 +1,161 @@
+package certmanager
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"slices"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/klog/v2"
+
+	"github.com/jimyag/auto-cert-webhook/internal/metrics"
+)
+
+// pruneCABundle removes the CAs that expire first from the CA bundle
+// ConfigMap until at most CABundleMaxCerts remain, keeping current, and
+// returns the remaining bundle.
+func (m *Manager) pruneCABundle(ctx context.Context, current *x509.Certificate, bundle []*x509.Certificate) ([]*x509.Certificate, error) {
+	limit := m.config.CABundleMaxCerts
+	if limit <= 0 || len(bundle) <= limit {
+		return bundle, nil
+	}
+
+	// Rank the current CA first, then the others by descending expiry.
+	ranked := slices.Clone(bundle)
+	slices.SortStableFunc(ranked, func(a, b *x509.Certificate) int {
+		if bytes.Equal(a.Raw, current.Raw) {
+			return -1
+		}
+		if bytes.Equal(b.Raw, current.Raw) {
+			return 1
+		}
+		return b.NotAfter.Compare(a.NotAfter)
+	})
+	pruned := ranked[limit:]
+	// Keep the order of the bundle, which library-go would restore anyway.
+	kept := slices.DeleteFunc(slices.Clone(bundle), func(cert *x509.Certificate) bool {
+		return slices.Contains(pruned, cert)
+	})
+
+	if err := m.updateCABundle(ctx, kept); err != nil {
+		return nil, err
+	}
+	klog.Infof("Pruned %d CA(s) from CA bundle %s/%s to keep at most %d", len(pruned), m.config.Namespace, m.config.CABundleConfigMapName, limit)
+	return kept, nil
+}
+
+// removeReplacedCAs removes the CAs that current replaced more than CAOverlap
+// ago from the CA bundle ConfigMap and returns the remaining bundle. The
+// overlap starts at the NotBefore of current.
+func (m *Manager) removeReplacedCAs(ctx context.Context, current *x509.Certificate, bundle []*x509.Certificate) ([]*x509.Certificate, error) {
+	overlap := m.config.CAOverlap
+	if overlap <= 0 || time.Now().Before(current.NotBefore.Add(overlap)) {
+		return bundle, nil
+	}
+	replaced := func(cert *x509.Certificate) bool {
+		return cert.NotBefore.Before(current.NotBefore)
+	}
+	if !slices.ContainsFunc(bundle, replaced) {
+		return bundle, nil
+	}
+	kept := slices.DeleteFunc(slices.Clone(bundle), replaced)
+
+	if err := m.updateCABundle(ctx, kept); err != nil {
+		return nil, err
+	}
+	klog.Infof("Removed %d CA(s) replaced more than %v ago from CA bundle %s/%s", len(bundle)-len(kept), overlap, m.config.Namespace, m.config.CABundleConfigMapName)
+	return kept, nil
+}
+
+// updateCABundle replaces the certificates of the CA bundle ConfigMap.
+func (m *Manager) updateCABundle(ctx context.Context, certs []*x509.Certificate) error {
+	pemBytes, err := certutil.EncodeCertificates(certs...)
+	if err != nil {
+		return err
+	}
+	client := m.k8sClient.CoreV1().ConfigMaps(m.config.Namespace)
+	cm, err := client.Get(ctx, m.config.CABundleConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	cm.Data["ca-bundle.crt"] = string(pemBytes)
+	_, err = client.Update(ctx, cm, metav1.UpdateOptions{})
+	return err
+}
+
+// recordCABundle updates the CA bundle metrics.
+func (m *Manager) recordCABundle(bundle []*x509.Certificate) {
+	pemBytes, err := certutil.EncodeCertificates(bundle...)
+	if err != nil {
+		return
+	}
+	metrics.SetCABundle(m.config.Namespace, m.config.CABundleConfigMapName, len(bundle), len(pemBytes))
+}
+
+// cachedCABundle returns the CAs of the CA bundle ConfigMap in the informer
+// cache, or nil if it cannot be read.
+func (m *Manager) cachedCABundle() []*x509.Certificate {
+	cm, err := m.configMapLister.ConfigMaps(m.config.Namespace).Get(m.config.CABundleConfigMapName)
+	if err != nil || cm.Data["ca-bundle.crt"] == "" {
+		return nil
+	}
+	certs, err := certutil.ParseCertsPEM([]byte(cm.Data["ca-bundle.crt"]))
+	if err != nil {
+		return nil
+	}
+	return certs
+}
+
+// checkCAReplacement reports a CA that is new to the CA bundle although the
+// newest CA there was neither due for a refresh, replaced on request nor
+// outdated, e.g. signed with another algorithm: the
+// CA was regenerated outside its rotation, e.g. because its secret was
+// deleted or overwritten, and clients that only trust the previous CA reject
+// the webhook until they get the new bundle.
+func (m *Manager) checkCAReplacement(ca *x509.Certificate, previousBundle []*x509.Certificate) {
+	if bytes.Equal(m.lastCA, ca.Raw) {
+		return
+	}
+	m.lastCA = ca.Raw
+
+	var previous *x509.Certificate
+	for _, cert := range previousBundle {
+		if bytes.Equal(cert.Raw, ca.Raw) {
+			return
+		}
+		if previous == nil || cert.NotBefore.After(previous.NotBefore) {
+			previous = cert
+		}
+	}
+	if previous == nil || m.revoked(previous) || !time.Now().Before(m.caRefreshTime(previous)) || m.caOutdated(previous) {
+		return
+	}
+
+	metrics.RecordUnexpectedRegeneration(metrics.CertID{Type: "ca", Namespace: m.config.Namespace, SecretName: m.config.CASecretName})
+	klog.Warningf("CA in secret %s/%s was replaced by serial %s (SHA-256 %s) before the previous CA (serial %s, SHA-256 %s) was due for a refresh at %s; was the secret deleted or overwritten?",
+		m.config.Namespace, m.config.CASecretName, ca.SerialNumber.Text(16), fingerprint(ca),
+		previous.SerialNumber.Text(16), fingerprint(previous), m.caRefreshTime(previous).UTC().Format(time.RFC3339))
+	m.eventRecorder.Warningf("CARegeneratedUnexpectedly", "CA in secret %s/%s was replaced by serial %s before the previous CA (serial %s) was due for a refresh",
+		m.config.Namespace, m.config.CASecretName, ca.SerialNumber.Text(16), previous.SerialNumber.Text(16))
+}
+
+// caRefreshTime returns when library-go refreshes a CA: after CARefresh, or
+// once 80% of its validity has passed, whichever comes first.
+func (m *Manager) caRefreshTime(ca *x509.Certificate) time.Time {
+	refresh := ca.NotAfter.Add(-ca.NotAfter.Sub(ca.NotBefore) / 5)
+	if m.config.CARefresh > 0 && ca.NotBefore.Add(m.config.CARefresh).Before(refresh) {
+		refresh = ca.NotBefore.Add(m.config.CARefresh)
+	}
+	return refresh
+}
+
+// fingerprint returns the hex-encoded SHA-256 fingerprint of cert.
+func fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
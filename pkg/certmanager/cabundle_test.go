@@ -0,0 +1,165 @@
+package certmanager
+
+import (
+	"context"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/openshift/library-go/pkg/crypto"
+	"github.com/openshift/library-go/pkg/operator/events"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/utils/clock"
+)
+
+func TestManager_pruneCABundle(t *testing.T) {
+	var cas []*x509.Certificate
+	for _, lifetime := range []time.Duration{time.Hour, 2 * time.Hour, 3 * time.Hour} {
+		ca, err := crypto.MakeSelfSignedCAConfigForDuration("test-ca", lifetime)
+		if err != nil {
+			t.Fatalf("Failed to create CA: %v", err)
+		}
+		cas = append(cas, ca.Certs[0])
+	}
+	pemBytes, err := certutil.EncodeCertificates(cas...)
+	if err != nil {
+		t.Fatalf("Failed to encode CAs: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		maxCerts int
+		want     []*x509.Certificate
+	}{
+		{"no limit", 0, cas},
+		{"within limit", 3, cas},
+		{"keeps current and latest expiry", 2, []*x509.Certificate{cas[0], cas[2]}},
+		{"keeps current", 1, []*x509.Certificate{cas[0]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "wh-ca-bundle", Namespace: "ns"},
+				Data:       map[string]string{"ca-bundle.crt": string(pemBytes)},
+			})
+			m := newTestManager(client)
+			m.config.CABundleMaxCerts = tt.maxCerts
+
+			got, err := m.pruneCABundle(context.Background(), cas[0], cas)
+			if err != nil {
+				t.Fatalf("pruneCABundle() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("pruneCABundle() kept %d CAs, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("pruneCABundle()[%d] expires %v, want %v", i, got[i].NotAfter, tt.want[i].NotAfter)
+				}
+			}
+
+			cm, err := client.CoreV1().ConfigMaps("ns").Get(context.Background(), "wh-ca-bundle", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("Failed to get configmap: %v", err)
+			}
+			stored, err := certutil.ParseCertsPEM([]byte(cm.Data["ca-bundle.crt"]))
+			if err != nil {
+				t.Fatalf("Failed to parse CA bundle: %v", err)
+			}
+			if len(stored) != len(tt.want) {
+				t.Errorf("CA bundle has %d CAs, want %d", len(stored), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestManager_unexpectedCARegeneration(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	m := newTestManager(client)
+	recorder := events.NewInMemoryRecorder("test", clock.RealClock{})
+	m.SetEventRecorder(recorder)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Start(ctx)
+	}()
+
+	initial := waitForStatus(t, m, func(status Status) bool {
+		return status.CA != nil && len(status.CABundle) == 1
+	})
+
+	if err := client.CoreV1().Secrets("ns").Delete(ctx, "wh-ca", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Failed to delete CA secret: %v", err)
+	}
+	waitForStatus(t, m, func(status Status) bool {
+		return status.CA != nil && status.CA.SerialNumber.Cmp(initial.CA.SerialNumber) != 0 && len(status.CABundle) == 2
+	})
+
+	unexpected := 0
+	for _, event := range recorder.Events() {
+		if event.Reason == "CARegeneratedUnexpectedly" {
+			unexpected++
+		}
+	}
+	if unexpected != 1 {
+		t.Errorf("Got %d CARegeneratedUnexpectedly events, want 1", unexpected)
+	}
+
+	cancel()
+	<-done
+}
+
+func TestManager_removeReplacedCAs(t *testing.T) {
+	previous, err := crypto.MakeSelfSignedCAConfigForDuration("previous-ca", 48*time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	// NotBefore has a one-second granularity
+	time.Sleep(time.Second)
+	current, err := crypto.MakeSelfSignedCAConfigForDuration("current-ca", 48*time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	bundle := []*x509.Certificate{previous.Certs[0], current.Certs[0]}
+	pemBytes, err := certutil.EncodeCertificates(bundle...)
+	if err != nil {
+		t.Fatalf("Failed to encode CAs: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		overlap time.Duration
+		want    int
+	}{
+		{"until expiry", 0, 2},
+		{"within overlap", time.Hour, 2},
+		{"after overlap", time.Nanosecond, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "wh-ca-bundle", Namespace: "ns"},
+				Data:       map[string]string{"ca-bundle.crt": string(pemBytes)},
+			})
+			m := newTestManager(client)
+			m.config.CAOverlap = tt.overlap
+
+			got, err := m.removeReplacedCAs(context.Background(), current.Certs[0], bundle)
+			if err != nil {
+				t.Fatalf("removeReplacedCAs() error = %v", err)
+			}
+			if len(got) != tt.want {
+				t.Fatalf("removeReplacedCAs() kept %d CAs, want %d", len(got), tt.want)
+			}
+			if got[len(got)-1] != current.Certs[0] {
+				t.Error("removeReplacedCAs() removed the current CA")
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package certmanager
+
+import (
+	"cmp"
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	listerscorev1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/util/watchlist"
+)
+
+// DefaultCABundleKey is the default key of the CA bundle in the CA bundle
+// ConfigMap, the one library-go writes.
+const DefaultCABundleKey = "ca-bundle.crt"
+
+// SecretTypes are the supported types of the managed secrets.
+var SecretTypes = []corev1.SecretType{corev1.SecretTypeTLS, corev1.SecretTypeOpaque}
+
+// dataKeys maps the data keys library-go and the Manager use, tls.crt and
+// tls.key in secrets and ca-bundle.crt in ConfigMaps, to the configured
+// ones, and stores the secrets with their configured or existing type.
+type dataKeys struct {
+	cert, key, caBundle string
+
+	// secretType is the type of created secrets.
+	secretType corev1.SecretType
+}
+
+// dataKeysOf returns the data keys of config.
+func dataKeysOf(config Config) dataKeys {
+	keys := dataKeys{
+		cert:       cmp.Or(config.CertDataKey, corev1.TLSCertKey),
+		key:        cmp.Or(config.KeyDataKey, corev1.TLSPrivateKeyKey),
+		caBundle:   cmp.Or(config.CABundleDataKey, DefaultCABundleKey),
+		secretType: config.SecretType,
+	}
+	if keys.secretType == "" {
+		keys.secretType = corev1.SecretTypeTLS
+		if keys.customSecretKeys() {
+			keys.secretType = corev1.SecretTypeOpaque
+		}
+	}
+	return keys
+}
+
+// customSecretKeys returns whether the secret keys are renamed. Secrets of
+// type kubernetes.io/tls must hold tls.crt and tls.key, so such secrets are
+// stored as Opaque.
+func (k dataKeys) customSecretKeys() bool {
+	return k.cert != corev1.TLSCertKey || k.key != corev1.TLSPrivateKeyKey
+}
+
+// client returns client, translating the data keys and types of the
+// secrets and the data key of the ConfigMaps it reads and writes.
+func (k dataKeys) client(client kubernetes.Interface) kubernetes.Interface {
+	return keyedClient{client, k}
+}
+
+// readSecret returns secret as if stored with the default keys as a
+// kubernetes.io/tls secret. library-go would otherwise convert a secret of
+// another type, dropping its data if it holds no certificate yet, which the
+// API server rejects anyway as the type of a secret is immutable.
+func (k dataKeys) readSecret(secret *corev1.Secret) *corev1.Secret {
+	if secret == nil || (!k.customSecretKeys() && secret.Type == corev1.SecretTypeTLS) {
+		return secret
+	}
+	secret = secret.DeepCopy()
+	renameKey(secret.Data, k.cert, corev1.TLSCertKey)
+	renameKey(secret.Data, k.key, corev1.TLSPrivateKeyKey)
+	secret.Type = corev1.SecretTypeTLS
+	return secret
+}
+
+// writeSecret returns secret as stored with the configured keys and
+// secretType, the type of the stored secret or, if empty, the configured
+// one.
+func (k dataKeys) writeSecret(secret *corev1.Secret, secretType corev1.SecretType) *corev1.Secret {
+	if secret == nil {
+		return secret
+	}
+	secret = secret.DeepCopy()
+	renameKey(secret.Data, corev1.TLSCertKey, k.cert)
+	renameKey(secret.Data, corev1.TLSPrivateKeyKey, k.key)
+	secret.Type = cmp.Or(secretType, k.secretType)
+	return secret
+}
+
+// readConfigMap returns cm as stored with the default key.
+func (k dataKeys) readConfigMap(cm *corev1.ConfigMap) *corev1.ConfigMap {
+	if cm == nil || k.caBundle == DefaultCABundleKey {
+		return cm
+	}
+	cm = cm.DeepCopy()
+	renameKey(cm.Data, k.caBundle, DefaultCABundleKey)
+	return cm
+}
+
+// writeConfigMap returns cm as stored with the configured key.
+func (k dataKeys) writeConfigMap(cm *corev1.ConfigMap) *corev1.ConfigMap {
+	if cm == nil || k.caBundle == DefaultCABundleKey {
+		return cm
+	}
+	cm = cm.DeepCopy()
+	renameKey(cm.Data, DefaultCABundleKey, k.caBundle)
+	return cm
+}
+
+// renameKey moves the value of from to to in data.
+func renameKey[V any](data map[string]V, from, to string) {
+	if value, ok := data[from]; ok {
+		delete(data, from)
+		data[to] = value
+	}
+}
+
+type keyedClient struct {
+	kubernetes.Interface
+	keys dataKeys
+}
+
+// IsWatchListSemanticsUnSupported tells informers started on c whether the
+// wrapped client supports watch lists, as the fake clientset does not.
+func (c keyedClient) IsWatchListSemanticsUnSupported() bool {
+	return watchlist.DoesClientNotSupportWatchListSemantics(c.Interface)
+}
+
+// CoreV1 implements kubernetes.Interface.
+func (c keyedClient) CoreV1() corev1client.CoreV1Interface {
+	return keyedCoreV1{c.Interface.CoreV1(), c.keys}
+}
+
+type keyedCoreV1 struct {
+	corev1client.CoreV1Interface
+	keys dataKeys
+}
+
+// Secrets implements corev1client.CoreV1Interface.
+func (c keyedCoreV1) Secrets(namespace string) corev1client.SecretInterface {
+	return keyedSecrets{c.CoreV1Interface.Secrets(namespace), c.keys}
+}
+
+// ConfigMaps implements corev1client.CoreV1Interface.
+func (c keyedCoreV1) ConfigMaps(namespace string) corev1client.ConfigMapInterface {
+	return keyedConfigMaps{c.CoreV1Interface.ConfigMaps(namespace), c.keys}
+}
+
+type keyedSecrets struct {
+	corev1client.SecretInterface
+	keys dataKeys
+}
+
+// Get implements corev1client.SecretInterface.
+func (s keyedSecrets) Get(ctx context.Context, name string, opts metav1.GetOptions) (*corev1.Secret, error) {
+	secret, err := s.SecretInterface.Get(ctx, name, opts)
+	return s.keys.readSecret(secret), err
+}
+
+// Create implements corev1client.SecretInterface.
+func (s keyedSecrets) Create(ctx context.Context, secret *corev1.Secret, opts metav1.CreateOptions) (*corev1.Secret, error) {
+	secret, err := s.SecretInterface.Create(ctx, s.keys.writeSecret(secret, ""), opts)
+	return s.keys.readSecret(secret), err
+}
+
+// Update implements corev1client.SecretInterface. The secret keeps its type,
+// read from the API server since readSecret hides it.
+func (s keyedSecrets) Update(ctx context.Context, secret *corev1.Secret, opts metav1.UpdateOptions) (*corev1.Secret, error) {
+	current, err := s.SecretInterface.Get(ctx, secret.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	secret, err = s.SecretInterface.Update(ctx, s.keys.writeSecret(secret, current.Type), opts)
+	return s.keys.readSecret(secret), err
+}
+
+type keyedConfigMaps struct {
+	corev1client.ConfigMapInterface
+	keys dataKeys
+}
+
+// Get implements corev1client.ConfigMapInterface.
+func (c keyedConfigMaps) Get(ctx context.Context, name string, opts metav1.GetOptions) (*corev1.ConfigMap, error) {
+	cm, err := c.ConfigMapInterface.Get(ctx, name, opts)
+	return c.keys.readConfigMap(cm), err
+}
+
+// Create implements corev1client.ConfigMapInterface.
+func (c keyedConfigMaps) Create(ctx context.Context, cm *corev1.ConfigMap, opts metav1.CreateOptions) (*corev1.ConfigMap, error) {
+	cm, err := c.ConfigMapInterface.Create(ctx, c.keys.writeConfigMap(cm), opts)
+	return c.keys.readConfigMap(cm), err
+}
+
+// Update implements corev1client.ConfigMapInterface.
+func (c keyedConfigMaps) Update(ctx context.Context, cm *corev1.ConfigMap, opts metav1.UpdateOptions) (*corev1.ConfigMap, error) {
+	cm, err := c.ConfigMapInterface.Update(ctx, c.keys.writeConfigMap(cm), opts)
+	return c.keys.readConfigMap(cm), err
+}
+
+// keyedSecretLister translates the data keys of the secrets it lists.
+type keyedSecretLister struct {
+	listerscorev1.SecretLister
+	keys dataKeys
+}
+
+// Secrets implements listerscorev1.SecretLister.
+func (l keyedSecretLister) Secrets(namespace string) listerscorev1.SecretNamespaceLister {
+	return keyedSecretNamespaceLister{l.SecretLister.Secrets(namespace), l.keys}
+}
+
+type keyedSecretNamespaceLister struct {
+	listerscorev1.SecretNamespaceLister
+	keys dataKeys
+}
+
+// Get implements listerscorev1.SecretNamespaceLister.
+func (l keyedSecretNamespaceLister) Get(name string) (*corev1.Secret, error) {
+	secret, err := l.SecretNamespaceLister.Get(name)
+	return l.keys.readSecret(secret), err
+}
+
+// List implements listerscorev1.SecretNamespaceLister.
+func (l keyedSecretNamespaceLister) List(selector labels.Selector) ([]*corev1.Secret, error) {
+	secrets, err := l.SecretNamespaceLister.List(selector)
+	for i := range secrets {
+		secrets[i] = l.keys.readSecret(secrets[i])
+	}
+	return secrets, err
+}
+
+// keyedConfigMapLister translates the data key of the ConfigMaps it lists.
+type keyedConfigMapLister struct {
+	listerscorev1.ConfigMapLister
+	keys dataKeys
+}
+
+// ConfigMaps implements listerscorev1.ConfigMapLister.
+func (l keyedConfigMapLister) ConfigMaps(namespace string) listerscorev1.ConfigMapNamespaceLister {
+	return keyedConfigMapNamespaceLister{l.ConfigMapLister.ConfigMaps(namespace), l.keys}
+}
+
+type keyedConfigMapNamespaceLister struct {
+	listerscorev1.ConfigMapNamespaceLister
+	keys dataKeys
+}
+
+// Get implements listerscorev1.ConfigMapNamespaceLister.
+func (l keyedConfigMapNamespaceLister) Get(name string) (*corev1.ConfigMap, error) {
+	cm, err := l.ConfigMapNamespaceLister.Get(name)
+	return l.keys.readConfigMap(cm), err
+}
+
+// List implements listerscorev1.ConfigMapNamespaceLister.
+func (l keyedConfigMapNamespaceLister) List(selector labels.Selector) ([]*corev1.ConfigMap, error) {
+	cms, err := l.ConfigMapNamespaceLister.List(selector)
+	for i := range cms {
+		cms[i] = l.keys.readConfigMap(cms[i])
+	}
+	return cms, err
+}
@@ -0,0 +1,152 @@
+package certmanager
+
+import (
+	"context"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	certutil "k8s.io/client-go/util/cert"
+)
+
+func TestManager_DataKeys(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	config := newTestManager(client).config
+	config.CertDataKey = "cert.pem"
+	config.KeyDataKey = "key.pem"
+	config.CABundleDataKey = "ca.pem"
+	m := New(client, config)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Start(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// The status is read through the translated listers.
+	waitForStatus(t, m, func(s Status) bool {
+		return s.CA != nil && s.ServingCertificate != nil && len(s.CABundle) > 0
+	})
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		serving, roots := storedWithCustomKeys(t, client)
+		if serving != nil {
+			// The serving certificate may be signed by a CA overwritten in
+			// the fake clientset, but still in the bundle.
+			if _, err := serving.Verify(x509.VerifyOptions{DNSName: "wh.ns.svc", Roots: roots}); err == nil {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for certificates stored with the custom keys")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// storedWithCustomKeys checks that the secrets and ConfigMap in the API
+// server use the keys of TestManager_DataKeys, and returns the serving
+// certificate and the CA bundle, nil if not issued yet.
+func storedWithCustomKeys(t *testing.T, client *fake.Clientset) (*x509.Certificate, *x509.CertPool) {
+	t.Helper()
+	ctx := context.Background()
+
+	var serving *x509.Certificate
+	for _, name := range []string{"wh-ca", "wh-cert"} {
+		secret, err := client.CoreV1().Secrets("ns").Get(ctx, name, metav1.GetOptions{})
+		if err != nil || len(secret.Data["cert.pem"]) == 0 {
+			return nil, nil
+		}
+		if secret.Type != corev1.SecretTypeOpaque {
+			t.Errorf("Secret %s has type %s, want %s", name, secret.Type, corev1.SecretTypeOpaque)
+		}
+		for _, key := range []string{corev1.TLSCertKey, corev1.TLSPrivateKeyKey} {
+			if _, ok := secret.Data[key]; ok {
+				t.Errorf("Secret %s holds the default key %s", name, key)
+			}
+		}
+		if len(secret.Data["key.pem"]) == 0 {
+			t.Errorf("Secret %s holds no key.pem", name)
+		}
+		certs, err := certutil.ParseCertsPEM(secret.Data["cert.pem"])
+		if err != nil {
+			t.Fatalf("Failed to parse certificate of secret %s: %v", name, err)
+		}
+		serving = certs[0]
+	}
+
+	cm, err := client.CoreV1().ConfigMaps("ns").Get(ctx, "wh-ca-bundle", metav1.GetOptions{})
+	if err != nil {
+		return nil, nil
+	}
+	if _, ok := cm.Data[DefaultCABundleKey]; ok {
+		t.Errorf("ConfigMap holds the default key %s", DefaultCABundleKey)
+	}
+	bundle, err := certutil.ParseCertsPEM([]byte(cm.Data["ca.pem"]))
+	if err != nil {
+		return nil, nil
+	}
+	roots := x509.NewCertPool()
+	for _, cert := range bundle {
+		roots.AddCert(cert)
+	}
+	return serving, roots
+}
+
+func TestManager_OpaqueSecrets(t *testing.T) {
+	// The CA secret exists beforehand as Opaque with other material, the
+	// serving certificate secret is created as kubernetes.io/tls.
+	client := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "wh-ca", Namespace: "ns"},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{"other": []byte("material")},
+	})
+	m := newTestManager(client)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Start(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		ca, serving, _ := issuedCertificates(t, client)
+		if ca != nil && serving != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for certificates")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for name, want := range map[string]corev1.SecretType{"wh-ca": corev1.SecretTypeOpaque, "wh-cert": corev1.SecretTypeTLS} {
+		secret, err := client.CoreV1().Secrets("ns").Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get secret %s: %v", name, err)
+		}
+		if secret.Type != want {
+			t.Errorf("Secret %s has type %s, want %s", name, secret.Type, want)
+		}
+		if len(secret.Data[corev1.TLSPrivateKeyKey]) == 0 {
+			t.Errorf("Secret %s holds no %s", name, corev1.TLSPrivateKeyKey)
+		}
+	}
+	secret, _ := client.CoreV1().Secrets("ns").Get(ctx, "wh-ca", metav1.GetOptions{})
+	if got := string(secret.Data["other"]); got != "material" {
+		t.Errorf("Expected the other data of the CA secret to be kept, got %q", got)
+	}
+}
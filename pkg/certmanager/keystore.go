@@ -0,0 +1,82 @@
+package certmanager
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"software.sslmate.com/src/go-pkcs12"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+	"k8s.io/klog/v2"
+)
+
+// KeystoreKey is the key of the PKCS#12 keystore in the serving certificate
+// secret, written if Config.KeystorePasswordSecretName is set.
+const KeystoreKey = "keystore.p12"
+
+// DefaultKeystorePasswordKey is the default key of the keystore password in
+// its secret.
+const DefaultKeystorePasswordKey = "password"
+
+// ensureKeystore writes the serving certificate and key in secret, with the
+// CA chain, as a PKCS#12 keystore into the secret, for consumers such as Java
+// applications that cannot read PEM. It is re-encoded when the certificate
+// or the password changes.
+func (m *Manager) ensureKeystore(ctx context.Context, secret *corev1.Secret) error {
+	if m.config.KeystorePasswordSecretName == "" || len(secret.Data["tls.crt"]) == 0 {
+		return nil
+	}
+	password, err := m.keystorePassword()
+	if err != nil {
+		return err
+	}
+
+	certs, err := certutil.ParseCertsPEM(secret.Data["tls.crt"])
+	if err != nil {
+		return fmt.Errorf("failed to parse serving certificate: %w", err)
+	}
+	if p12 := secret.Data[KeystoreKey]; len(p12) > 0 {
+		_, cert, _, err := pkcs12.DecodeChain(p12, password)
+		if err == nil && bytes.Equal(cert.Raw, certs[0].Raw) {
+			return nil
+		}
+	}
+
+	key, err := keyutil.ParsePrivateKeyPEM(secret.Data["tls.key"])
+	if err != nil {
+		return fmt.Errorf("failed to parse serving key: %w", err)
+	}
+	p12, err := pkcs12.Modern2023.Encode(key, certs[0], certs[1:], password)
+	if err != nil {
+		return fmt.Errorf("failed to encode keystore: %w", err)
+	}
+
+	secret = secret.DeepCopy()
+	secret.Data[KeystoreKey] = p12
+	if _, err := m.k8sClient.CoreV1().Secrets(secret.Namespace).Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+	klog.Infof("Updated keystore %s in secret %s/%s", KeystoreKey, secret.Namespace, secret.Name)
+	return nil
+}
+
+// keystorePassword reads the keystore password from its secret.
+func (m *Manager) keystorePassword() (string, error) {
+	name, key := m.config.KeystorePasswordSecretName, m.config.KeystorePasswordSecretKey
+	if key == "" {
+		key = DefaultKeystorePasswordKey
+	}
+	secret, err := m.secretLister.Secrets(m.config.Namespace).Get(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to get keystore password secret %s/%s: %w", m.config.Namespace, name, err)
+	}
+	password, ok := secret.Data[key]
+	if !ok {
+		return "", fmt.Errorf("keystore password secret %s/%s has no key %q", m.config.Namespace, name, key)
+	}
+	return string(password), nil
+}
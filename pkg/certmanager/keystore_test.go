@@ -0,0 +1,70 @@
+package certmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	certutil "k8s.io/client-go/util/cert"
+)
+
+func TestManager_ensureKeystore(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "wh-keystore", Namespace: "ns"},
+		Data:       map[string][]byte{"pass": []byte("first")},
+	})
+	m := newTestManager(client)
+	m.config.KeystorePasswordSecretName = "wh-keystore"
+	m.config.KeystorePasswordSecretKey = "pass"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Start(ctx)
+	}()
+
+	// waitForKeystore waits until the keystore opens with password and holds
+	// the serving certificate.
+	waitForKeystore := func(password string) {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			secret, err := client.CoreV1().Secrets("ns").Get(ctx, "wh-cert", metav1.GetOptions{})
+			if err == nil && len(secret.Data[KeystoreKey]) > 0 {
+				key, cert, caCerts, err := pkcs12.DecodeChain(secret.Data[KeystoreKey], password)
+				certs, _ := certutil.ParseCertsPEM(secret.Data["tls.crt"])
+				if err == nil && key != nil && len(certs) > 1 && cert.Equal(certs[0]) {
+					if len(caCerts) != len(certs)-1 {
+						t.Errorf("Keystore holds %d CA certificates, want %d", len(caCerts), len(certs)-1)
+					}
+					return
+				}
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for the keystore with password %q", password)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitForKeystore("first")
+
+	// A new password re-encodes the keystore.
+	password, err := client.CoreV1().Secrets("ns").Get(ctx, "wh-keystore", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get password secret: %v", err)
+	}
+	password.Data["pass"] = []byte("second")
+	if _, err := client.CoreV1().Secrets("ns").Update(ctx, password, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Failed to update password secret: %v", err)
+	}
+	waitForKeystore("second")
+
+	cancel()
+	<-done
+}
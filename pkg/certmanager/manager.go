@@ -0,0 +1,684 @@
+// Package certmanager maintains a self-signed CA, a serving certificate
+// signed by it and a CA bundle ConfigMap in Kubernetes, and rotates them
+// before they expire. It is the rotation machinery behind the webhook
+// framework and can be used directly by other controllers:
+//
+//	mgr := certmanager.New(client, certmanager.Config{
+//		Namespace:             "my-namespace",
+//		ServiceName:           "my-service",
+//		CASecretName:          "my-service-ca",
+//		CertSecretName:        "my-service-cert",
+//		CABundleConfigMapName: "my-service-ca-bundle",
+//		CAValidity:            48 * time.Hour,
+//		CARefresh:             24 * time.Hour,
+//		CertValidity:          24 * time.Hour,
+//		CertRefresh:           12 * time.Hour,
+//	})
+//	err := mgr.Start(ctx)
+//
+// Run a single Manager per set of resources, e.g. under leader election.
+package certmanager
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/openshift/library-go/pkg/crypto"
+	"github.com/openshift/library-go/pkg/operator/certrotation"
+	"github.com/openshift/library-go/pkg/operator/events"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	listerscorev1 "k8s.io/client-go/listers/core/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+
+	"github.com/jimyag/auto-cert-webhook/internal/metrics"
+)
+
+const (
+	// defaultSyncInterval is the interval between syncs if
+	// Config.SyncInterval is not set.
+	defaultSyncInterval = time.Minute
+
+	// retryInitialBackoff is the delay before retrying a failed sync.
+	retryInitialBackoff = time.Second
+
+	// syncJitterFactor is the maximum jitter added to the sync delays, as a
+	// fraction of the delay.
+	syncJitterFactor = 0.2
+)
+
+// Config holds the certificate manager configuration.
+type Config struct {
+	// Namespace is the namespace where certificates are stored.
+	Namespace string
+
+	// ServiceName is the name of the service for the webhook.
+	ServiceName string
+
+	// ServiceIPSANs adds the ClusterIPs of the service to the serving
+	// certificate, for clients that call the webhook by IP.
+	ServiceIPSANs bool
+
+	// HeadlessServiceName adds the names of a headless service, e.g. the
+	// governing service of a StatefulSet, to the serving certificate, with
+	// the names of the pods behind it: <pod>.<service>.<namespace>.svc for
+	// the pods StatefulSetName-0 to StatefulSetName-(StatefulSetReplicas-1),
+	// or the wildcard *.<service>.<namespace>.svc without StatefulSetName.
+	HeadlessServiceName string
+	StatefulSetName     string
+	StatefulSetReplicas int
+
+	// ExternalHostnames are DNS names, possibly wildcards, and IP addresses
+	// added to the serving certificate, e.g. of an ingress or LoadBalancer,
+	// for callers outside the cluster.
+	ExternalHostnames []string
+
+	// CASecretName is the name of the CA secret.
+	CASecretName string
+
+	// CertSecretName is the name of the serving certificate secret.
+	CertSecretName string
+
+	// CABundleConfigMapName is the name of the CA bundle configmap.
+	CABundleConfigMapName string
+
+	// CABundleNamespaces and the namespaces matching
+	// CABundleNamespaceSelector get a copy of the CA bundle ConfigMap, with
+	// the same name and labeled with CABundleSourceLabel, for workloads that
+	// call the service directly. Copies in namespaces no longer targeted are
+	// deleted. Namespace is always skipped.
+	CABundleNamespaces        []string
+	CABundleNamespaceSelector labels.Selector
+
+	// CAValidity is the validity duration of the CA certificate.
+	CAValidity time.Duration
+
+	// CARefresh is the refresh interval for the CA certificate.
+	CARefresh time.Duration
+
+	// CAOverlap is how long a replaced CA stays in the CA bundle after the
+	// new CA is issued, for clients to pick up the new bundle before
+	// certificates of the replaced CA stop being trusted. Zero keeps replaced
+	// CAs until they expire.
+	CAOverlap time.Duration
+
+	// CABackup is a CA exported by ExportCA that is restored when the CA
+	// secret does not exist, instead of creating a new CA, e.g. after a
+	// cluster rebuild.
+	CABackup []byte
+
+	// CABackupPassphrase decrypts the private key of CABackup.
+	CABackupPassphrase []byte
+
+	// CABundleMaxCerts caps the number of CAs kept in the CA bundle. Beyond
+	// it, the CAs that expire first are removed; the current CA is always
+	// kept. Expired CAs are removed regardless. Zero means no limit.
+	CABundleMaxCerts int
+
+	// CertValidity is the validity duration of the server certificate.
+	CertValidity time.Duration
+
+	// CertRefresh is the refresh interval for the server certificate.
+	CertRefresh time.Duration
+
+	// ServingKeyRotationPolicy is KeyRotationPolicyAlways to generate a new
+	// private key with every serving certificate, or KeyRotationPolicyNever to
+	// keep the existing one. Empty means KeyRotationPolicyAlways.
+	ServingKeyRotationPolicy string
+
+	// KeystorePasswordSecretName is the secret, in Namespace, of the password
+	// of a PKCS#12 keystore written to KeystoreKey of the serving
+	// certificate secret. Empty means no keystore.
+	KeystorePasswordSecretName string
+
+	// KeystorePasswordSecretKey is the key of the password in
+	// KeystorePasswordSecretName. Defaults to DefaultKeystorePasswordKey.
+	KeystorePasswordSecretKey string
+
+	// CertDataKey and KeyDataKey are the keys of the certificate and private
+	// key in the CA and serving certificate secrets, tls.crt and tls.key by
+	// default. Secrets with other keys must be Opaque, since the API server
+	// requires tls.crt and tls.key in kubernetes.io/tls secrets.
+	CertDataKey string
+	KeyDataKey  string
+
+	// CABundleDataKey is the key of the CA bundle in the CA bundle ConfigMap.
+	// Defaults to DefaultCABundleKey.
+	CABundleDataKey string
+
+	// SecretType is the type of the CA and serving certificate secrets the
+	// Manager creates, one of SecretTypes. Existing secrets keep their type
+	// and any other data they hold. Empty means kubernetes.io/tls, or Opaque
+	// with custom CertDataKey or KeyDataKey.
+	SecretType corev1.SecretType
+
+	// CASubject and ServingSubject customize the subjects of the CA and
+	// serving certificates. The zero values keep library-go's.
+	CASubject      Subject
+	ServingSubject Subject
+
+	// SignatureAlgorithm is the signature algorithm of the CA and serving
+	// certificates, one of SignatureAlgorithms. Certificates signed with
+	// another algorithm are replaced. Empty means SHA256-RSA.
+	SignatureAlgorithm string
+
+	// SyncInterval is the interval between certificate sync checks, plus up
+	// to 20% of jitter. Failed syncs are retried sooner, with a backoff from
+	// one second up to SyncInterval. Defaults to one minute.
+	SyncInterval time.Duration
+}
+
+// Manager handles certificate rotation using openshift/library-go.
+type Manager struct {
+	config Config
+
+	k8sClient     kubernetes.Interface
+	eventRecorder events.Recorder
+
+	// factory is a shared informer factory for the namespace; nil means Start
+	// uses its own.
+	factory informers.SharedInformerFactory
+
+	// mu guards the listers and the last sync result, which Status reads.
+	mu              sync.Mutex
+	secretLister    listerscorev1.SecretLister
+	configMapLister listerscorev1.ConfigMapLister
+	running         bool
+	lastSyncTime    time.Time
+	lastSyncError   error
+	lastSuccessTime time.Time
+	failures        int
+
+	// publishedLister and namespaceLister read the copies of the CA bundle
+	// in other namespaces and the namespaces matching
+	// CABundleNamespaceSelector; nil unless they are published.
+	publishedLister listerscorev1.ConfigMapLister
+	namespaceLister listerscorev1.NamespaceLister
+
+	// syncNow triggers a sync before the next interval.
+	syncNow chan struct{}
+
+	// revokedCAs are the fingerprints of the CAs replaced on request, which
+	// are kept out of the CA bundle, as of the last sync. They are persisted
+	// in revokedCAsAnnotation of the CA secret.
+	revokedCAs []string
+
+	// lastCA is the DER certificate of the CA of the last sync.
+	lastCA []byte
+}
+
+// New creates a new certificate manager.
+func New(client kubernetes.Interface, config Config) *Manager {
+	controllerRef, err := events.GetControllerReferenceForCurrentPod(context.TODO(), client, config.Namespace, nil)
+	if err != nil {
+		klog.V(4).Infof("Unable to get controller reference: %v", err)
+	}
+
+	eventRecorder := events.NewRecorder(client.CoreV1().Events(config.Namespace), config.Namespace, controllerRef, clock.RealClock{})
+
+	return &Manager{
+		config:        config,
+		k8sClient:     dataKeysOf(config).client(client),
+		eventRecorder: eventRecorder,
+		syncNow:       make(chan struct{}, 1),
+	}
+}
+
+// Namespace returns the namespace of the managed certificates.
+func (m *Manager) Namespace() string {
+	return m.config.Namespace
+}
+
+// ServiceName returns the name of the service the certificate is issued for.
+func (m *Manager) ServiceName() string {
+	return m.config.ServiceName
+}
+
+// SetEventRecorder sets the recorder of the events about issued
+// certificates. By default they are attached to the controller of the
+// current pod, e.g. its Deployment.
+func (m *Manager) SetEventRecorder(recorder events.Recorder) {
+	m.eventRecorder = recorder
+}
+
+// SetInformerFactory makes the manager read secrets and ConfigMaps through a
+// shared informer factory for its namespace. The caller starts the factory.
+func (m *Manager) SetInformerFactory(factory informers.SharedInformerFactory) {
+	m.factory = factory
+	// Request the informers now so that they start with the factory.
+	factory.Core().V1().Secrets().Informer()
+	factory.Core().V1().ConfigMaps().Informer()
+}
+
+// Start starts the certificate manager and blocks until the context is cancelled.
+func (m *Manager) Start(ctx context.Context) error {
+	factory := m.factory
+	if factory == nil {
+		factory = informers.NewSharedInformerFactoryWithOptions(
+			m.k8sClient,
+			0,
+			informers.WithNamespace(m.config.Namespace),
+		)
+	}
+
+	secretInformer := factory.Core().V1().Secrets().Informer()
+	configMapInformer := factory.Core().V1().ConfigMaps().Informer()
+
+	if m.factory == nil {
+		_ = secretInformer.SetWatchErrorHandlerWithContext(metrics.WatchErrorHandler("secrets"))
+		_ = configMapInformer.SetWatchErrorHandlerWithContext(metrics.WatchErrorHandler("configmaps"))
+		factory.Start(ctx.Done())
+		// Wait for the informer goroutines to exit before returning.
+		defer factory.Shutdown()
+	}
+
+	if !toolscache.WaitForCacheSync(ctx.Done(), secretInformer.HasSynced, configMapInformer.HasSynced) {
+		return fmt.Errorf("could not sync informer cache")
+	}
+
+	// Sync right away when a rotation is requested
+	registration, err := secretInformer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: m.onSecretChange,
+		UpdateFunc: func(_, newObj interface{}) {
+			m.onSecretChange(newObj)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add event handler: %w", err)
+	}
+	// Remove the handler so that a restart does not register it twice.
+	defer func() {
+		_ = secretInformer.RemoveEventHandler(registration)
+	}()
+
+	m.mu.Lock()
+	m.secretLister = factory.Core().V1().Secrets().Lister()
+	m.configMapLister = factory.Core().V1().ConfigMaps().Lister()
+	keys := dataKeysOf(m.config)
+	m.secretLister = keyedSecretLister{m.secretLister, keys}
+	m.configMapLister = keyedConfigMapLister{m.configMapLister, keys}
+	m.mu.Unlock()
+
+	if m.publishing() {
+		stop, err := m.startPublishInformers(ctx)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
+
+	// Start the sync loop, running immediately on start
+	m.setRunning(true)
+	defer m.setRunning(false)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			klog.Info("Certificate manager stopped")
+			return nil
+		case <-timer.C:
+		case <-m.syncNow:
+		}
+
+		if err := m.recordSync(m.sync(ctx)); err != nil {
+			failures++
+			delay := m.syncDelay(failures)
+			klog.Errorf("Certificate sync failed, retrying in %v: %v", delay.Round(time.Millisecond), err)
+			timer.Reset(delay)
+			continue
+		}
+		failures = 0
+		timer.Reset(m.syncDelay(0))
+	}
+}
+
+// syncDelay returns the delay before the next sync after the given number of
+// consecutive failed syncs: the sync interval after a success, or a backoff
+// doubling from retryInitialBackoff up to the sync interval after failures,
+// so that transient errors are retried quickly. Delays are jittered so that
+// the Managers of webhooks started together spread their API requests.
+func (m *Manager) syncDelay(failures int) time.Duration {
+	interval := m.config.SyncInterval
+	if interval <= 0 {
+		interval = defaultSyncInterval
+	}
+	delay := interval
+	if failures > 0 {
+		delay = retryInitialBackoff
+		for i := 1; i < failures && delay < interval; i++ {
+			delay *= 2
+		}
+		delay = min(delay, interval)
+	}
+	return wait.Jitter(delay, syncJitterFactor)
+}
+
+// sync performs a single synchronization cycle.
+func (m *Manager) sync(ctx context.Context) error {
+	klog.V(4).Info("Syncing certificates")
+	secret := func(name string) string { return "Secret " + m.config.Namespace + "/" + name }
+	caBundle := "ConfigMap " + m.config.Namespace + "/" + m.config.CABundleConfigMapName
+
+	// Ensure CA
+	previousBundle := m.cachedCABundle()
+	ca, err := m.ensureCA(ctx)
+	if err != nil {
+		return &SyncError{Resource: secret(m.config.CASecretName), Err: fmt.Errorf("failed to ensure CA: %w", err)}
+	}
+	m.checkCAReplacement(ca.Config.Certs[0], previousBundle)
+
+	// Ensure CA Bundle
+	bundle, err := m.ensureCABundle(ctx, ca)
+	if err != nil {
+		return &SyncError{Resource: caBundle, Err: fmt.Errorf("failed to ensure CA bundle: %w", err)}
+	}
+	if bundle, err = m.removeRevokedCAs(ctx, bundle); err != nil {
+		return &SyncError{Resource: caBundle, Err: fmt.Errorf("failed to remove replaced CAs from CA bundle: %w", err)}
+	}
+	if bundle, err = m.removeReplacedCAs(ctx, ca.Config.Certs[0], bundle); err != nil {
+		return &SyncError{Resource: caBundle, Err: fmt.Errorf("failed to remove replaced CAs from CA bundle: %w", err)}
+	}
+	if bundle, err = m.pruneCABundle(ctx, ca.Config.Certs[0], bundle); err != nil {
+		return &SyncError{Resource: caBundle, Err: fmt.Errorf("failed to prune CA bundle: %w", err)}
+	}
+	if err := m.forgetRevokedCAs(ctx, bundle); err != nil {
+		return &SyncError{Resource: secret(m.config.CASecretName), Err: fmt.Errorf("failed to forget revoked CAs: %w", err)}
+	}
+	m.recordCABundle(bundle)
+
+	hostnames, err := m.servingHostnames(ctx)
+	if err != nil {
+		return &SyncError{Resource: "Service " + m.config.Namespace + "/" + m.config.ServiceName, Err: fmt.Errorf("failed to determine serving certificate hostnames: %w", err)}
+	}
+
+	// Ensure serving certificate
+	if err := m.ensureServingCert(ctx, ca, bundle, hostnames); err != nil {
+		return &SyncError{Resource: secret(m.config.CertSecretName), Err: fmt.Errorf("failed to ensure serving certificate: %w", err)}
+	}
+
+	// Copies in other namespaces go last, so that failing to write them does
+	// not hold up the serving certificate
+	if err := m.publishCABundle(ctx, bundle); err != nil {
+		return &SyncError{Resource: caBundle, Err: fmt.Errorf("failed to publish CA bundle: %w", err)}
+	}
+
+	klog.V(4).Info("Certificate sync completed")
+	return nil
+}
+
+// onSecretChange triggers a sync when a rotation of a managed certificate is
+// requested.
+func (m *Manager) onSecretChange(obj interface{}) {
+	secret, ok := obj.(*corev1.Secret)
+	if !ok || secret.Namespace != m.config.Namespace ||
+		(secret.Name != m.config.CASecretName && secret.Name != m.config.CertSecretName) {
+		return
+	}
+	if !rotationRequested(secret) {
+		return
+	}
+	m.triggerSync()
+}
+
+// triggerSync runs a sync before the next interval.
+func (m *Manager) triggerSync() {
+	select {
+	case m.syncNow <- struct{}{}:
+	default:
+	}
+}
+
+// recordSync records the result of a sync for Status and returns err.
+func (m *Manager) recordSync(err error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastSyncTime = time.Now()
+	m.lastSyncError = err
+	if err != nil {
+		m.failures++
+	} else {
+		m.lastSuccessTime = m.lastSyncTime
+		m.failures = 0
+	}
+	return err
+}
+
+// setRunning records whether the sync loop runs, for Status and Healthy.
+func (m *Manager) setRunning(running bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.running = running
+}
+
+// ensureCA ensures the CA certificate exists and is valid.
+func (m *Manager) ensureCA(ctx context.Context) (*crypto.CA, error) {
+	secret, err := m.secretLister.Secrets(m.config.Namespace).Get(m.config.CASecretName)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, err
+		}
+
+		if len(m.config.CABackup) > 0 {
+			secret, err = m.restoreCASecret(ctx)
+		} else {
+			secret, err = m.createSecret(ctx, m.config.Namespace, m.config.CASecretName)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var previous *x509.Certificate
+	if rotationRequested(secret) {
+		klog.Infof("Rotation of CA %s/%s requested", secret.Namespace, secret.Name)
+		if certs, err := certutil.ParseCertsPEM(secret.Data["tls.crt"]); err == nil {
+			previous = certs[0]
+		}
+	}
+
+	sr := certrotation.RotatedSigningCASecret{
+		Name:          secret.Name,
+		Namespace:     secret.Namespace,
+		Validity:      m.config.CAValidity,
+		Refresh:       m.config.CARefresh,
+		Lister:        rotatingSecretLister{m.secretLister, m.caOutdated, true},
+		Client:        m.k8sClient.CoreV1(),
+		EventRecorder: m.eventRecorder,
+	}
+	if m.signatureAlgorithm() != x509.SHA256WithRSA || !m.config.CASubject.isZero() {
+		sr.Client = caSecretsGetter{m.k8sClient.CoreV1(), m}
+	}
+
+	ca, updated, err := sr.EnsureSigningCertKeyPair(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if ca == nil {
+		// library-go ignores update conflicts
+		return nil, fmt.Errorf("CA secret %s/%s changed during update", secret.Namespace, secret.Name)
+	}
+
+	m.revokedCAs = revokedFingerprints(secret)
+	if previous != nil && !bytes.Equal(ca.Config.Certs[0].Raw, previous.Raw) {
+		// Written to the CA secret along with the new CA
+		m.revokedCAs = append(m.revokedCAs, fingerprint(previous))
+	}
+	if updated {
+		m.recordIssued("CA", secret, ca.Config.Certs[0])
+	}
+
+	return ca, nil
+}
+
+// ensureCABundle ensures the CA bundle configmap exists and contains the current CA.
+func (m *Manager) ensureCABundle(ctx context.Context, ca *crypto.CA) ([]*x509.Certificate, error) {
+	br := certrotation.CABundleConfigMap{
+		Name:          m.config.CABundleConfigMapName,
+		Namespace:     m.config.Namespace,
+		Lister:        m.configMapLister,
+		Client:        m.k8sClient.CoreV1(),
+		EventRecorder: m.eventRecorder,
+	}
+
+	signerName := fmt.Sprintf("%s/%s", m.config.Namespace, m.config.CASecretName)
+	certs, err := br.EnsureConfigMapCABundle(ctx, ca, signerName)
+	if err != nil {
+		return nil, err
+	}
+
+	return certs, nil
+}
+
+// ensureServingCert ensures the serving certificate exists and is valid.
+func (m *Manager) ensureServingCert(ctx context.Context, ca *crypto.CA, bundle []*x509.Certificate, hostnames []string) error {
+	secret, err := m.secretLister.Secrets(m.config.Namespace).Get(m.config.CertSecretName)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+
+		secret, err = m.createSecret(ctx, m.config.Namespace, m.config.CertSecretName)
+		if err != nil {
+			return err
+		}
+	}
+
+	tr := certrotation.RotatedSelfSignedCertKeySecret{
+		Name:      secret.Name,
+		Namespace: secret.Namespace,
+		Validity:  m.config.CertValidity,
+		Refresh:   m.config.CertRefresh,
+		CertCreator: &servingRotation{
+			ServingRotation: &certrotation.ServingRotation{
+				Hostnames: func() []string {
+					return hostnames
+				},
+			},
+			key:       m.reusableKey(secret),
+			algorithm: m.signatureAlgorithm(),
+			subject:   m.config.ServingSubject,
+			data: SubjectData{
+				Namespace:   m.config.Namespace,
+				SecretName:  m.config.CertSecretName,
+				ServiceName: m.config.ServiceName,
+			},
+		},
+		Lister: rotatingSecretLister{m.secretLister, func(cert *x509.Certificate) bool {
+			return m.servingOutdated(cert) || !signedByAny(cert, bundle)
+		}, false},
+		Client:        m.k8sClient.CoreV1(),
+		EventRecorder: m.eventRecorder,
+	}
+
+	if rotationRequested(secret) {
+		klog.Infof("Rotation of serving certificate %s/%s requested", secret.Namespace, secret.Name)
+	}
+	updated, err := tr.EnsureTargetCertKeyPair(ctx, ca, bundle)
+	if err != nil {
+		return err
+	}
+	if updated == nil {
+		return nil
+	}
+	if !bytes.Equal(updated.Data["tls.crt"], secret.Data["tls.crt"]) {
+		if certs, err := certutil.ParseCertsPEM(updated.Data["tls.crt"]); err == nil {
+			m.recordIssued("ServingCertificate", secret, certs[0])
+		}
+	}
+
+	if err := m.ensureKeystore(ctx, updated); err != nil {
+		return fmt.Errorf("failed to ensure keystore: %w", err)
+	}
+	return nil
+}
+
+// recordIssued emits a <kind>Created or <kind>Rotated event for a certificate
+// issued into secret, which holds the previous certificate.
+func (m *Manager) recordIssued(kind string, secret *corev1.Secret, cert *x509.Certificate) {
+	reason, verb := kind+"Created", "Created"
+	if len(secret.Data["tls.crt"]) > 0 {
+		reason, verb = kind+"Rotated", "Rotated"
+	}
+	m.eventRecorder.Eventf(reason, "%s certificate in secret %s/%s: serial %s, expires %s",
+		verb, secret.Namespace, secret.Name, cert.SerialNumber.Text(16), cert.NotAfter.UTC().Format(time.RFC3339))
+}
+
+// servingHostnames returns the DNS names and IP addresses of the serving
+// certificate. IP addresses become IP SANs.
+func (m *Manager) servingHostnames(ctx context.Context) ([]string, error) {
+	hostnames := []string{
+		m.config.ServiceName,
+		fmt.Sprintf("%s.%s", m.config.ServiceName, m.config.Namespace),
+		fmt.Sprintf("%s.%s.svc", m.config.ServiceName, m.config.Namespace),
+	}
+	if headless := m.config.HeadlessServiceName; headless != "" {
+		hostnames = append(hostnames,
+			headless,
+			fmt.Sprintf("%s.%s", headless, m.config.Namespace),
+			fmt.Sprintf("%s.%s.svc", headless, m.config.Namespace),
+		)
+		if m.config.StatefulSetName == "" {
+			hostnames = append(hostnames, fmt.Sprintf("*.%s.%s.svc", headless, m.config.Namespace))
+		}
+		for i := range m.config.StatefulSetReplicas {
+			hostnames = append(hostnames, fmt.Sprintf("%s-%d.%s.%s.svc", m.config.StatefulSetName, i, headless, m.config.Namespace))
+		}
+	}
+	hostnames = append(hostnames, m.config.ExternalHostnames...)
+	if !m.config.ServiceIPSANs {
+		return hostnames, nil
+	}
+
+	svc, err := m.k8sClient.CoreV1().Services(m.config.Namespace).Get(ctx, m.config.ServiceName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			klog.Warningf("Service %s/%s not found, serving certificate has no IP SANs", m.config.Namespace, m.config.ServiceName)
+			return hostnames, nil
+		}
+		return nil, err
+	}
+	// ClusterIPs lists both families of dual-stack services; older API
+	// servers only set ClusterIP.
+	clusterIPs := svc.Spec.ClusterIPs
+	if len(clusterIPs) == 0 && svc.Spec.ClusterIP != "" {
+		clusterIPs = []string{svc.Spec.ClusterIP}
+	}
+	for _, ip := range clusterIPs {
+		if ip != corev1.ClusterIPNone {
+			hostnames = append(hostnames, ip)
+		}
+	}
+	return hostnames, nil
+}
+
+// createSecret creates a new secret for a certificate.
+func (m *Manager) createSecret(ctx context.Context, namespace, name string) (*corev1.Secret, error) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			"tls.crt": {},
+			"tls.key": {},
+		},
+	}
+
+	return m.k8sClient.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{})
+}
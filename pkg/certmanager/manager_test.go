@@ -0,0 +1,131 @@
+package certmanager
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestManager_servingHostnames(t *testing.T) {
+	dnsNames := []string{"wh", "wh.ns", "wh.ns.svc"}
+	service := func(clusterIP string, clusterIPs ...string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "wh", Namespace: "ns"},
+			Spec:       corev1.ServiceSpec{ClusterIP: clusterIP, ClusterIPs: clusterIPs},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		serviceIPSANs bool
+		headless      string
+		statefulSet   string
+		replicas      int
+		external      []string
+		service       *corev1.Service
+		want          []string
+	}{
+		{name: "DNS names only", service: service("10.0.0.1", "10.0.0.1"), want: dnsNames},
+		{
+			name:          "dual-stack",
+			serviceIPSANs: true,
+			service:       service("10.0.0.1", "10.0.0.1", "fd00::1"),
+			want:          append(dnsNames[:3:3], "10.0.0.1", "fd00::1"),
+		},
+		{
+			name:          "IPv6 only",
+			serviceIPSANs: true,
+			service:       service("fd00::1", "fd00::1"),
+			want:          append(dnsNames[:3:3], "fd00::1"),
+		},
+		{
+			name:          "ClusterIP only",
+			serviceIPSANs: true,
+			service:       service("10.0.0.1"),
+			want:          append(dnsNames[:3:3], "10.0.0.1"),
+		},
+		{name: "headless", serviceIPSANs: true, service: service(corev1.ClusterIPNone, corev1.ClusterIPNone), want: dnsNames},
+		{name: "service missing", serviceIPSANs: true, want: dnsNames},
+		{
+			name:     "headless service",
+			headless: "wh-pods",
+			want:     append(dnsNames[:3:3], "wh-pods", "wh-pods.ns", "wh-pods.ns.svc", "*.wh-pods.ns.svc"),
+		},
+		{
+			name:        "StatefulSet pods",
+			headless:    "wh-pods",
+			statefulSet: "wh",
+			replicas:    2,
+			want:        append(dnsNames[:3:3], "wh-pods", "wh-pods.ns", "wh-pods.ns.svc", "wh-0.wh-pods.ns.svc", "wh-1.wh-pods.ns.svc"),
+		},
+		{
+			name:          "external hostnames",
+			serviceIPSANs: true,
+			external:      []string{"wh.example.com", "203.0.113.10"},
+			service:       service("10.0.0.1", "10.0.0.1"),
+			want:          append(dnsNames[:3:3], "wh.example.com", "203.0.113.10", "10.0.0.1"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			if tt.service != nil {
+				client = fake.NewSimpleClientset(tt.service)
+			}
+			m := &Manager{
+				config: Config{
+					Namespace:           "ns",
+					ServiceName:         "wh",
+					ServiceIPSANs:       tt.serviceIPSANs,
+					HeadlessServiceName: tt.headless,
+					StatefulSetName:     tt.statefulSet,
+					StatefulSetReplicas: tt.replicas,
+					ExternalHostnames:   tt.external,
+				},
+				k8sClient: client,
+			}
+
+			got, err := m.servingHostnames(context.Background())
+			if err != nil {
+				t.Fatalf("servingHostnames failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("servingHostnames: got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManager_syncDelay(t *testing.T) {
+	m := New(fake.NewSimpleClientset(), Config{Namespace: "ns", SyncInterval: 10 * time.Second})
+	tests := []struct {
+		name     string
+		failures int
+		want     time.Duration
+	}{
+		{"success", 0, 10 * time.Second},
+		{"first failure", 1, time.Second},
+		{"third failure", 3, 4 * time.Second},
+		{"capped at the sync interval", 10, 10 * time.Second},
+		{"many failures", 1000, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxDelay := time.Duration(float64(tt.want) * (1 + syncJitterFactor))
+			if got := m.syncDelay(tt.failures); got < tt.want || got > maxDelay {
+				t.Errorf("syncDelay(%d) = %v, want between %v and %v", tt.failures, got, tt.want, maxDelay)
+			}
+		})
+	}
+
+	m.config.SyncInterval = 0
+	if got := m.syncDelay(0); got < defaultSyncInterval {
+		t.Errorf("syncDelay(0) = %v without a sync interval, want at least %v", got, defaultSyncInterval)
+	}
+}
@@ -0,0 +1,226 @@
+package certmanager
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/informers"
+	listerscorev1 "k8s.io/client-go/listers/core/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/klog/v2"
+)
+
+// CABundleSourceLabel marks the copies of a CA bundle ConfigMap published to
+// other namespaces, with the namespace of the original as its value.
+// ConfigMaps without it are never overwritten or deleted.
+const CABundleSourceLabel = "auto-cert-webhook.jimyag.io/ca-bundle-source"
+
+// PublishedCABundleListOptions selects the copies of the CA bundle ConfigMap
+// of config in all namespaces, e.g. to delete them on uninstall.
+func PublishedCABundleListOptions(config Config) metav1.ListOptions {
+	return metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("metadata.name", config.CABundleConfigMapName).String(),
+		LabelSelector: labels.Set{CABundleSourceLabel: config.Namespace}.String(),
+	}
+}
+
+// publishing returns whether the CA bundle is published to other namespaces.
+func (m *Manager) publishing() bool {
+	return len(m.config.CABundleNamespaces) > 0 || m.config.CABundleNamespaceSelector != nil
+}
+
+// startPublishInformers starts the informers of the published copies of the
+// CA bundle and of the namespaces matching CABundleNamespaceSelector, and
+// waits for their caches. A copy deleted or modified by someone else, and a
+// namespace starting or ceasing to match, triggers a sync. The returned
+// function stops the informers.
+func (m *Manager) startPublishInformers(ctx context.Context) (func(), error) {
+	listOptions := PublishedCABundleListOptions(m.config)
+	configMaps := informers.NewSharedInformerFactoryWithOptions(m.k8sClient, 0,
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = listOptions.FieldSelector
+			opts.LabelSelector = listOptions.LabelSelector
+		}),
+	)
+	copyInformer := configMaps.Core().V1().ConfigMaps().Informer()
+	synced := []toolscache.InformerSynced{copyInformer.HasSynced}
+	factories := []informers.SharedInformerFactory{configMaps}
+
+	var namespaceLister listerscorev1.NamespaceLister
+	if selector := m.config.CABundleNamespaceSelector; selector != nil {
+		namespaces := informers.NewSharedInformerFactoryWithOptions(m.k8sClient, 0,
+			informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+				opts.LabelSelector = selector.String()
+			}),
+		)
+		namespaceInformer := namespaces.Core().V1().Namespaces().Informer()
+		if _, err := namespaceInformer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+			AddFunc: func(interface{}) { m.triggerSync() },
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				oldNS, ok1 := oldObj.(*corev1.Namespace)
+				newNS, ok2 := newObj.(*corev1.Namespace)
+				if ok1 && ok2 && (!labels.Equals(oldNS.Labels, newNS.Labels) || oldNS.Status.Phase != newNS.Status.Phase) {
+					m.triggerSync()
+				}
+			},
+			DeleteFunc: func(interface{}) { m.triggerSync() },
+		}); err != nil {
+			return nil, fmt.Errorf("failed to add namespace event handler: %w", err)
+		}
+		namespaceLister = namespaces.Core().V1().Namespaces().Lister()
+		synced = append(synced, namespaceInformer.HasSynced)
+		factories = append(factories, namespaces)
+	}
+
+	if _, err := copyInformer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(_, newObj interface{}) { m.triggerSync() },
+		DeleteFunc: func(interface{}) { m.triggerSync() },
+	}); err != nil {
+		return nil, fmt.Errorf("failed to add ConfigMap event handler: %w", err)
+	}
+
+	for _, factory := range factories {
+		factory.Start(ctx.Done())
+	}
+	stop := func() {
+		for _, factory := range factories {
+			factory.Shutdown()
+		}
+	}
+	if !toolscache.WaitForCacheSync(ctx.Done(), synced...) {
+		stop()
+		return nil, fmt.Errorf("could not sync published CA bundle informer cache")
+	}
+
+	m.mu.Lock()
+	m.publishedLister = keyedConfigMapLister{configMaps.Core().V1().ConfigMaps().Lister(), dataKeysOf(m.config)}
+	m.namespaceLister = namespaceLister
+	m.mu.Unlock()
+	return stop, nil
+}
+
+// publishCABundle copies the CA bundle into CABundleNamespaces and the
+// namespaces matching CABundleNamespaceSelector, and deletes the copies in
+// namespaces no longer targeted.
+func (m *Manager) publishCABundle(ctx context.Context, bundle []*x509.Certificate) error {
+	if m.publishedLister == nil {
+		return nil
+	}
+	pemBytes, err := certutil.EncodeCertificates(bundle...)
+	if err != nil {
+		return err
+	}
+	targets, err := m.publishNamespaces()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, namespace := range sets.List(targets) {
+		if err := m.publishCABundleTo(ctx, namespace, string(pemBytes)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	copies, err := m.publishedLister.List(labels.Everything())
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+	for _, cm := range copies {
+		if targets.Has(cm.Namespace) || cm.Name != m.config.CABundleConfigMapName ||
+			cm.Labels[CABundleSourceLabel] != m.config.Namespace {
+			continue
+		}
+		err := m.k8sClient.CoreV1().ConfigMaps(cm.Namespace).Delete(ctx, cm.Name, metav1.DeleteOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+		case err != nil:
+			errs = append(errs, fmt.Errorf("failed to delete CA bundle copy %s/%s: %w", cm.Namespace, cm.Name, err))
+		default:
+			klog.Infof("Deleted CA bundle copy %s/%s from a namespace no longer targeted", cm.Namespace, cm.Name)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// publishNamespaces returns the namespaces the CA bundle is published to,
+// other than its own.
+func (m *Manager) publishNamespaces() (sets.Set[string], error) {
+	targets := sets.New(m.config.CABundleNamespaces...)
+	if m.namespaceLister != nil {
+		namespaces, err := m.namespaceLister.List(m.config.CABundleNamespaceSelector)
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range namespaces {
+			// Terminating namespaces reject new objects
+			if ns.Status.Phase != corev1.NamespaceTerminating {
+				targets.Insert(ns.Name)
+			}
+		}
+	}
+	targets.Delete(m.config.Namespace)
+	return targets, nil
+}
+
+// publishCABundleTo creates or updates the copy of the CA bundle in namespace.
+func (m *Manager) publishCABundleTo(ctx context.Context, namespace, caBundle string) error {
+	name := m.config.CABundleConfigMapName
+	client := m.k8sClient.CoreV1().ConfigMaps(namespace)
+
+	current, err := m.publishedLister.ConfigMaps(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels:    map[string]string{CABundleSourceLabel: m.config.Namespace},
+			},
+			Data: map[string]string{"ca-bundle.crt": caBundle},
+		}
+		_, err = client.Create(ctx, cm, metav1.CreateOptions{})
+		switch {
+		case apierrors.IsAlreadyExists(err):
+			// The copy may have been created after the cache was read
+			existing, getErr := client.Get(ctx, name, metav1.GetOptions{})
+			if getErr == nil && existing.Labels[CABundleSourceLabel] == m.config.Namespace {
+				return nil
+			}
+			return fmt.Errorf("ConfigMap %s/%s exists and is not a copy of CA bundle %s/%s", namespace, name, m.config.Namespace, name)
+		case apierrors.IsNotFound(err):
+			// The namespace does not exist (yet)
+			klog.V(2).Infof("Namespace %s not found, CA bundle %s/%s not published to it", namespace, m.config.Namespace, name)
+			return nil
+		case err != nil:
+			return fmt.Errorf("failed to publish CA bundle to %s/%s: %w", namespace, name, err)
+		}
+		klog.Infof("Published CA bundle %s/%s to namespace %s", m.config.Namespace, name, namespace)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if current.Data["ca-bundle.crt"] == caBundle {
+		return nil
+	}
+
+	cm := current.DeepCopy()
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data["ca-bundle.crt"] = caBundle
+	if _, err := client.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update CA bundle copy %s/%s: %w", namespace, name, err)
+	}
+	klog.V(2).Infof("Updated CA bundle copy %s/%s", namespace, name)
+	return nil
+}
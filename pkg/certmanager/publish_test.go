@@ -0,0 +1,85 @@
+package certmanager
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestManager_PublishCABundle(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "selected", Labels: map[string]string{"ca-bundle": "true"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "listed"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "taken"}},
+		// A ConfigMap of the same name that is not a copy
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "wh-ca-bundle", Namespace: "taken"},
+			Data:       map[string]string{"ca-bundle.crt": "foreign"},
+		},
+	)
+	m := newTestManager(client)
+	m.config.CABundleNamespaces = []string{"listed", "taken", "ns"}
+	m.config.CABundleNamespaceSelector = labels.SelectorFromSet(labels.Set{"ca-bundle": "true"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Start(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// The sync fails on the foreign ConfigMap after publishing the others
+	waitForStatus(t, m, func(s Status) bool {
+		return s.LastSyncError != nil && strings.Contains(s.LastSyncError.Error(), "not a copy")
+	})
+
+	source, err := client.CoreV1().ConfigMaps("ns").Get(ctx, "wh-ca-bundle", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get CA bundle: %v", err)
+	}
+	for _, namespace := range []string{"selected", "listed"} {
+		cm, err := client.CoreV1().ConfigMaps(namespace).Get(ctx, "wh-ca-bundle", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Expected a copy in namespace %s: %v", namespace, err)
+		}
+		if cm.Data["ca-bundle.crt"] != source.Data["ca-bundle.crt"] {
+			t.Errorf("Copy in namespace %s differs from the CA bundle", namespace)
+		}
+		if got := cm.Labels[CABundleSourceLabel]; got != "ns" {
+			t.Errorf("Copy in namespace %s has source label %q, want %q", namespace, got, "ns")
+		}
+	}
+	if _, err := client.CoreV1().ConfigMaps("other").Get(ctx, "wh-ca-bundle", metav1.GetOptions{}); err == nil {
+		t.Error("Expected no copy in a namespace not targeted")
+	}
+	if cm, _ := client.CoreV1().ConfigMaps("taken").Get(ctx, "wh-ca-bundle", metav1.GetOptions{}); cm.Data["ca-bundle.crt"] != "foreign" {
+		t.Error("Expected the foreign ConfigMap to be left alone")
+	}
+
+	// A namespace that no longer matches loses its copy
+	ns, _ := client.CoreV1().Namespaces().Get(ctx, "selected", metav1.GetOptions{})
+	ns.Labels = nil
+	if _, err := client.CoreV1().Namespaces().Update(ctx, ns, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Failed to update namespace: %v", err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, err := client.CoreV1().ConfigMaps("selected").Get(ctx, "wh-ca-bundle", metav1.GetOptions{}); err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the copy to be deleted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,265 @@
+package certmanager
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/openshift/library-go/pkg/operator/certrotation"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	listerscorev1 "k8s.io/client-go/listers/core/v1"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/klog/v2"
+)
+
+// RotateAnnotation on the CA or serving certificate secret forces a new
+// certificate on the next sync, e.g. after a suspected key compromise:
+//
+//	kubectl annotate secret my-service-ca auto-cert-webhook.jimyag.io/rotate="$(date -u +%FT%TZ)"
+//
+// The value is not interpreted. The annotation is removed with the rotation.
+// A CA replaced this way is also removed from the CA bundle, so certificates
+// it signed are no longer trusted and the serving certificate is re-issued.
+const RotateAnnotation = "auto-cert-webhook.jimyag.io/rotate"
+
+// revokedCAsAnnotation on the CA secret lists the comma-separated SHA-256
+// fingerprints of the CAs replaced through RotateAnnotation until they have
+// left the CA bundle, so that a new leader still keeps them out of it.
+const revokedCAsAnnotation = "auto-cert-webhook.jimyag.io/revoked-cas"
+
+// RotateOptions selects the certificates Rotate replaces.
+type RotateOptions struct {
+	// CA also replaces the CA, which removes the previous CA from the CA
+	// bundle. Otherwise only the serving certificate is re-issued.
+	CA bool
+
+	// PollInterval is how often the secrets are checked for the new
+	// certificates. Defaults to 1s.
+	PollInterval time.Duration
+}
+
+// RotateResult holds the serial numbers of the certificates after a rotation.
+type RotateResult struct {
+	CASerialNumber      *big.Int
+	ServingSerialNumber *big.Int
+}
+
+// Rotate requests new certificates through RotateAnnotation and waits until
+// the running Manager for config, e.g. on the leader replica, has issued
+// them. It only needs access to the secrets, so it can be used from any
+// replica or from outside the cluster during incident response.
+func Rotate(ctx context.Context, client kubernetes.Interface, config Config, opts RotateOptions) (RotateResult, error) {
+	client = dataKeysOf(config).client(client)
+	pollInterval := opts.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = time.Second
+	}
+
+	before, err := currentSerialNumbers(ctx, client, config)
+	if err != nil {
+		return RotateResult{}, err
+	}
+	if before.CASerialNumber == nil || before.ServingSerialNumber == nil {
+		return RotateResult{}, fmt.Errorf("certificates in namespace %s not issued yet", config.Namespace)
+	}
+
+	target := config.CertSecretName
+	if opts.CA {
+		target = config.CASecretName
+	}
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"annotations": map[string]string{RotateAnnotation: time.Now().UTC().Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		return RotateResult{}, err
+	}
+	if _, err := client.CoreV1().Secrets(config.Namespace).Patch(ctx, target, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return RotateResult{}, fmt.Errorf("failed to annotate secret %s/%s: %w", config.Namespace, target, err)
+	}
+
+	var after RotateResult
+	err = wait.PollUntilContextCancel(ctx, pollInterval, false, func(ctx context.Context) (bool, error) {
+		after, err = currentSerialNumbers(ctx, client, config)
+		if err != nil {
+			return false, err
+		}
+		if after.ServingSerialNumber == nil || after.ServingSerialNumber.Cmp(before.ServingSerialNumber) == 0 {
+			return false, nil
+		}
+		return !opts.CA || (after.CASerialNumber != nil && after.CASerialNumber.Cmp(before.CASerialNumber) != 0), nil
+	})
+	if err != nil {
+		return RotateResult{}, fmt.Errorf("waiting for new certificates: %w", err)
+	}
+	return after, nil
+}
+
+// currentSerialNumbers reads the serial numbers of the issued certificates
+// from the API server; they are nil for certificates not issued yet.
+func currentSerialNumbers(ctx context.Context, client kubernetes.Interface, config Config) (RotateResult, error) {
+	var result RotateResult
+	for name, serial := range map[string]**big.Int{
+		config.CASecretName:   &result.CASerialNumber,
+		config.CertSecretName: &result.ServingSerialNumber,
+	} {
+		secret, err := client.CoreV1().Secrets(config.Namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return RotateResult{}, err
+		}
+		if len(secret.Data["tls.crt"]) == 0 {
+			continue
+		}
+		certs, err := certutil.ParseCertsPEM(secret.Data["tls.crt"])
+		if err != nil {
+			return RotateResult{}, fmt.Errorf("failed to parse certificate of secret %s/%s: %w", config.Namespace, name, err)
+		}
+		*serial = certs[0].SerialNumber
+	}
+	return result, nil
+}
+
+// rotationRequested returns whether a secret asks for a new certificate.
+func rotationRequested(secret *corev1.Secret) bool {
+	_, ok := secret.Annotations[RotateAnnotation]
+	return ok
+}
+
+// rotatingSecretLister makes the secrets that ask for a new certificate, or
+// whose certificate is outdated, look as if they had no expiry, which
+// library-go rotates. As the annotation is dropped from the returned copy,
+// the rotation also removes it.
+type rotatingSecretLister struct {
+	listerscorev1.SecretLister
+
+	// outdated returns whether a certificate no longer matches the Config.
+	outdated func(*x509.Certificate) bool
+
+	// revoke adds the certificate replaced on request to
+	// revokedCAsAnnotation, which the rotation then writes along with the
+	// new certificate.
+	revoke bool
+}
+
+// Secrets implements listerscorev1.SecretLister.
+func (l rotatingSecretLister) Secrets(namespace string) listerscorev1.SecretNamespaceLister {
+	return rotatingSecretNamespaceLister{l.SecretLister.Secrets(namespace), l.outdated, l.revoke}
+}
+
+type rotatingSecretNamespaceLister struct {
+	listerscorev1.SecretNamespaceLister
+	outdated func(*x509.Certificate) bool
+	revoke   bool
+}
+
+// Get implements listerscorev1.SecretNamespaceLister.
+func (l rotatingSecretNamespaceLister) Get(name string) (*corev1.Secret, error) {
+	secret, err := l.SecretNamespaceLister.Get(name)
+	if err != nil || (!rotationRequested(secret) && !certificateOutdated(secret, l.outdated)) {
+		return secret, err
+	}
+	secret = secret.DeepCopy()
+	if l.revoke && rotationRequested(secret) {
+		if certs, err := certutil.ParseCertsPEM(secret.Data["tls.crt"]); err == nil {
+			revoked := append(revokedFingerprints(secret), fingerprint(certs[0]))
+			secret.Annotations[revokedCAsAnnotation] = strings.Join(revoked, ",")
+		}
+	}
+	delete(secret.Annotations, RotateAnnotation)
+	delete(secret.Annotations, certrotation.CertificateNotAfterAnnotation)
+	return secret, nil
+}
+
+// certificateOutdated returns whether secret holds a certificate for which
+// outdated returns true.
+func certificateOutdated(secret *corev1.Secret, outdated func(*x509.Certificate) bool) bool {
+	if len(secret.Data["tls.crt"]) == 0 {
+		return false
+	}
+	certs, err := certutil.ParseCertsPEM(secret.Data["tls.crt"])
+	return err == nil && outdated(certs[0])
+}
+
+// signedByAny returns whether cert is signed by one of the CAs. library-go
+// only compares the issuer's common name with the CA bundle, which misses a
+// CA replaced by one issued within the same second under the same name.
+func signedByAny(cert *x509.Certificate, cas []*x509.Certificate) bool {
+	return slices.ContainsFunc(cas, func(ca *x509.Certificate) bool {
+		return cert.CheckSignatureFrom(ca) == nil
+	})
+}
+
+// revokedFingerprints returns the fingerprints of revokedCAsAnnotation.
+func revokedFingerprints(secret *corev1.Secret) []string {
+	return slices.DeleteFunc(strings.Split(secret.Annotations[revokedCAsAnnotation], ","), func(fp string) bool {
+		return fp == ""
+	})
+}
+
+// revoked returns whether cert is a CA replaced on request.
+func (m *Manager) revoked(cert *x509.Certificate) bool {
+	return slices.Contains(m.revokedCAs, fingerprint(cert))
+}
+
+// removeRevokedCAs removes the CAs replaced on request from the CA bundle
+// ConfigMap and returns the remaining bundle.
+func (m *Manager) removeRevokedCAs(ctx context.Context, bundle []*x509.Certificate) ([]*x509.Certificate, error) {
+	if !slices.ContainsFunc(bundle, m.revoked) {
+		return bundle, nil
+	}
+	kept := slices.DeleteFunc(slices.Clone(bundle), m.revoked)
+
+	if err := m.updateCABundle(ctx, kept); err != nil {
+		return nil, err
+	}
+	klog.Infof("Removed %d replaced CA(s) from CA bundle %s/%s", len(bundle)-len(kept), m.config.Namespace, m.config.CABundleConfigMapName)
+	return kept, nil
+}
+
+// forgetRevokedCAs removes the CAs that have left the CA bundle from
+// revokedCAsAnnotation of the CA secret.
+func (m *Manager) forgetRevokedCAs(ctx context.Context, bundle []*x509.Certificate) error {
+	inBundle := func(fp string) bool {
+		return slices.ContainsFunc(bundle, func(cert *x509.Certificate) bool {
+			return fingerprint(cert) == fp
+		})
+	}
+	if !slices.ContainsFunc(m.revokedCAs, func(fp string) bool { return !inBundle(fp) }) {
+		return nil
+	}
+
+	client := m.k8sClient.CoreV1().Secrets(m.config.Namespace)
+	secret, err := client.Get(ctx, m.config.CASecretName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	revoked := revokedFingerprints(secret)
+	kept := slices.DeleteFunc(slices.Clone(revoked), func(fp string) bool { return !inBundle(fp) })
+	if len(kept) < len(revoked) {
+		if len(kept) == 0 {
+			delete(secret.Annotations, revokedCAsAnnotation)
+		} else {
+			secret.Annotations[revokedCAsAnnotation] = strings.Join(kept, ",")
+		}
+		if _, err := client.Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+	}
+	m.revokedCAs = kept
+	return nil
+}
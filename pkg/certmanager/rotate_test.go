@@ -0,0 +1,229 @@
+package certmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	listerscorev1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/clock"
+)
+
+func TestManager_RotateAnnotation(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	m := newTestManager(client)
+	recorder := events.NewInMemoryRecorder("test", clock.RealClock{})
+	m.SetEventRecorder(recorder)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Start(ctx)
+	}()
+
+	initial := waitForStatus(t, m, func(status Status) bool {
+		return status.CA != nil && status.ServingCertificate != nil && len(status.CABundle) > 0
+	})
+
+	annotate := func(name string) {
+		t.Helper()
+		secret, err := client.CoreV1().Secrets("ns").Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get secret %s: %v", name, err)
+		}
+		secret.Annotations[RotateAnnotation] = "true"
+		if _, err := client.CoreV1().Secrets("ns").Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+			t.Fatalf("Failed to annotate secret %s: %v", name, err)
+		}
+	}
+
+	t.Run("serving certificate", func(t *testing.T) {
+		annotate("wh-cert")
+		status := waitForStatus(t, m, func(status Status) bool {
+			return status.ServingCertificate != nil &&
+				status.ServingCertificate.SerialNumber.Cmp(initial.ServingCertificate.SerialNumber) != 0
+		})
+		if status.CA.SerialNumber.Cmp(initial.CA.SerialNumber) != 0 {
+			t.Error("Expected the CA to be kept")
+		}
+		initial = status
+	})
+
+	t.Run("CA", func(t *testing.T) {
+		annotate("wh-ca")
+		// The CAs share their subject, so wait on serial numbers: the new CA
+		// alone in the bundle and a serving certificate re-issued by it.
+		waitForStatus(t, m, func(status Status) bool {
+			return status.CA != nil && status.CA.SerialNumber.Cmp(initial.CA.SerialNumber) != 0 &&
+				len(status.CABundle) == 1 && status.CABundle[0].SerialNumber.Cmp(status.CA.SerialNumber) == 0 &&
+				status.ServingCertificate != nil &&
+				status.ServingCertificate.SerialNumber.Cmp(initial.ServingCertificate.SerialNumber) != 0
+		})
+	})
+
+	// Stop the manager before reading the events it records
+	cancel()
+	<-done
+
+	for _, name := range []string{"wh-ca", "wh-cert"} {
+		secret, err := client.CoreV1().Secrets("ns").Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get secret %s: %v", name, err)
+		}
+		if _, ok := secret.Annotations[RotateAnnotation]; ok {
+			t.Errorf("Expected the rotate annotation to be removed from %s", name)
+		}
+		if _, ok := secret.Annotations[revokedCAsAnnotation]; ok {
+			t.Errorf("Expected the revoked CAs annotation to be removed from %s once the CA left the bundle", name)
+		}
+	}
+
+	reasons := map[string]bool{}
+	for _, event := range recorder.Events() {
+		reasons[event.Reason] = true
+	}
+	for _, reason := range []string{"CACreated", "ServingCertificateCreated", "ServingCertificateRotated", "CARotated"} {
+		if !reasons[reason] {
+			t.Errorf("Expected a %s event, got %v", reason, reasons)
+		}
+	}
+	if reasons["CARegeneratedUnexpectedly"] {
+		t.Error("Expected a requested CA rotation not to be reported as unexpected")
+	}
+}
+
+func TestManager_RotateAnnotation_Failover(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	run := func(m *Manager, cond func(Status) bool) Status {
+		t.Helper()
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Start(ctx)
+		}()
+		status := waitForStatus(t, m, cond)
+		cancel()
+		<-done
+		return status
+	}
+	ctx := context.Background()
+
+	initial := run(newTestManager(client), func(status Status) bool {
+		return status.CA != nil && status.ServingCertificate != nil && len(status.CABundle) > 0
+	})
+	bundle, err := client.CoreV1().ConfigMaps("ns").Get(ctx, "wh-ca-bundle", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get CA bundle: %v", err)
+	}
+	oldBundle := bundle.Data["ca-bundle.crt"]
+
+	// The previous leader replaced the CA on request and failed before
+	// updating the CA bundle
+	caSecret, err := client.CoreV1().Secrets("ns").Get(ctx, "wh-ca", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get CA secret: %v", err)
+	}
+	caSecret.Annotations[RotateAnnotation] = "true"
+	m := newTestManager(client)
+	m.secretLister = secretListerOf(t, caSecret)
+	if _, err := m.ensureCA(ctx); err != nil {
+		t.Fatalf("Failed to rotate CA: %v", err)
+	}
+	caSecret, err = client.CoreV1().Secrets("ns").Get(ctx, "wh-ca", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get CA secret: %v", err)
+	}
+	if len(revokedFingerprints(caSecret)) != 1 {
+		t.Fatalf("Expected the replaced CA to be recorded on the CA secret, got %v", caSecret.Annotations)
+	}
+	if bundle, err = client.CoreV1().ConfigMaps("ns").Get(ctx, "wh-ca-bundle", metav1.GetOptions{}); err != nil || bundle.Data["ca-bundle.crt"] != oldBundle {
+		t.Fatalf("Expected the CA bundle to be unchanged, got %v", err)
+	}
+
+	// A new leader removes the replaced CA from the bundle right away and
+	// does not report the rotation as unexpected
+	m = newTestManager(client)
+	recorder := events.NewInMemoryRecorder("test", clock.RealClock{})
+	m.SetEventRecorder(recorder)
+	run(m, func(status Status) bool {
+		return status.CA != nil && status.CA.SerialNumber.Cmp(initial.CA.SerialNumber) != 0 &&
+			len(status.CABundle) == 1 && status.CABundle[0].SerialNumber.Cmp(status.CA.SerialNumber) == 0
+	})
+	for _, event := range recorder.Events() {
+		if event.Reason == "CARegeneratedUnexpectedly" {
+			t.Error("Expected a requested CA rotation not to be reported as unexpected after a failover")
+		}
+	}
+	caSecret, err = client.CoreV1().Secrets("ns").Get(ctx, "wh-ca", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get CA secret: %v", err)
+	}
+	if _, ok := caSecret.Annotations[revokedCAsAnnotation]; ok {
+		t.Errorf("Expected the revoked CAs annotation to be removed once the CA left the bundle, got %v", caSecret.Annotations)
+	}
+}
+
+// secretListerOf returns a lister of secrets.
+func secretListerOf(t *testing.T, secrets ...*corev1.Secret) listerscorev1.SecretLister {
+	t.Helper()
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, secret := range secrets {
+		if err := indexer.Add(secret); err != nil {
+			t.Fatalf("Failed to add secret: %v", err)
+		}
+	}
+	return listerscorev1.NewSecretLister(indexer)
+}
+
+func TestRotate(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	m := newTestManager(client)
+
+	if _, err := Rotate(context.Background(), client, m.config, RotateOptions{}); err == nil {
+		t.Error("Expected an error before certificates are issued")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Start(ctx)
+	}()
+
+	initial := waitForStatus(t, m, func(status Status) bool {
+		return status.CA != nil && status.ServingCertificate != nil && len(status.CABundle) > 0
+	})
+
+	rotateCtx, rotateCancel := context.WithTimeout(ctx, 10*time.Second)
+	defer rotateCancel()
+
+	result, err := Rotate(rotateCtx, client, m.config, RotateOptions{PollInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	if result.ServingSerialNumber.Cmp(initial.ServingCertificate.SerialNumber) == 0 {
+		t.Error("Expected a new serving certificate")
+	}
+	if result.CASerialNumber.Cmp(initial.CA.SerialNumber) != 0 {
+		t.Error("Expected the CA to be kept")
+	}
+
+	result, err = Rotate(rotateCtx, client, m.config, RotateOptions{CA: true, PollInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	if result.CASerialNumber.Cmp(initial.CA.SerialNumber) == 0 {
+		t.Error("Expected a new CA")
+	}
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,149 @@
+package certmanager
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+	"time"
+
+	ocrypto "github.com/openshift/library-go/pkg/crypto"
+	"github.com/openshift/library-go/pkg/operator/certrotation"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/keyutil"
+	"k8s.io/klog/v2"
+)
+
+// Key rotation policies of the serving certificate, named after the
+// rotationPolicy of cert-manager.
+const (
+	// KeyRotationPolicyAlways generates a new private key with every
+	// serving certificate.
+	KeyRotationPolicyAlways = "Always"
+
+	// KeyRotationPolicyNever keeps the private key of the serving certificate
+	// across rotations, e.g. for clients that pin its public key.
+	KeyRotationPolicyNever = "Never"
+)
+
+// KeyRotationPolicies are the valid values of Config.ServingKeyRotationPolicy.
+var KeyRotationPolicies = []string{KeyRotationPolicyAlways, KeyRotationPolicyNever}
+
+// servingRotation issues serving certificates like certrotation.ServingRotation,
+// but for key, if set, instead of a new private key, signed with algorithm
+// and with subject, rendered with data.
+type servingRotation struct {
+	*certrotation.ServingRotation
+	key       crypto.Signer
+	algorithm x509.SignatureAlgorithm
+	subject   Subject
+	data      SubjectData
+}
+
+// NewCertificate issues a serving certificate signed by signer.
+func (r *servingRotation) NewCertificate(signer *ocrypto.CA, validity time.Duration) (*ocrypto.TLSCertificateConfig, error) {
+	if r.key == nil && r.algorithm == x509.SHA256WithRSA && r.subject.isZero() {
+		return r.ServingRotation.NewCertificate(signer, validity)
+	}
+	key := r.key
+	if key == nil {
+		var err error
+		if key, err = newKey(r.algorithm); err != nil {
+			return nil, err
+		}
+	}
+	hostnames := sets.List(sets.New(r.Hostnames()...))
+	if len(hostnames) == 0 {
+		return nil, fmt.Errorf("no hostnames set")
+	}
+
+	// The template matches the one of ocrypto.CA.MakeServerCertForDuration.
+	now := time.Now()
+	data := r.data
+	data.Hostname, data.Timestamp = hostnames[0], now.Unix()
+	subject, err := r.subject.name(data, hostnames[0])
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		Subject:               subject,
+		SignatureAlgorithm:    r.algorithm,
+		NotBefore:             now.Add(-1 * time.Second),
+		NotAfter:              now.Add(validity),
+		SerialNumber:          big.NewInt(1),
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		AuthorityKeyId:        signer.Config.Certs[0].SubjectKeyId,
+		SubjectKeyId:          subjectKeyID(key.Public()),
+	}
+	template.KeyUsage = keyUsage(key, template.KeyUsage)
+	template.IPAddresses, template.DNSNames = ocrypto.IPAddressesDNSNames(hostnames)
+	for _, fn := range r.CertificateExtensionFn {
+		if err := fn(template); err != nil {
+			return nil, err
+		}
+	}
+
+	cert, err := signer.SignCertificate(template, key.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &ocrypto.TLSCertificateConfig{
+		Certs: append([]*x509.Certificate{cert}, signer.Config.Certs...),
+		Key:   key,
+	}, nil
+}
+
+// reusableKey returns the private key of the serving certificate in secret to
+// be kept by its next certificate under KeyRotationPolicyNever, or nil for a
+// new key. A rotation requested through RotateAnnotation always gets a new
+// key, since the previous one may be compromised, and so does a change of the
+// signature algorithm that needs another type of key.
+func (m *Manager) reusableKey(secret *corev1.Secret) crypto.Signer {
+	if m.config.ServingKeyRotationPolicy != KeyRotationPolicyNever ||
+		len(secret.Data["tls.key"]) == 0 || rotationRequested(secret) {
+		return nil
+	}
+	key, err := keyutil.ParsePrivateKeyPEM(secret.Data["tls.key"])
+	if err != nil {
+		klog.Warningf("Failed to parse private key of secret %s/%s, generating a new one: %v", secret.Namespace, secret.Name, err)
+		return nil
+	}
+	// These are the key types library-go can write back to the secret.
+	var signer crypto.Signer
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		signer = key
+	case *ecdsa.PrivateKey:
+		signer = key
+	default:
+		klog.Warningf("Unsupported private key type %T in secret %s/%s, generating a new one", key, secret.Namespace, secret.Name)
+		return nil
+	}
+	if !keyMatches(signer, m.signatureAlgorithm()) {
+		klog.Infof("Private key of secret %s/%s does not match signature algorithm %s, generating a new one", secret.Namespace, secret.Name, m.signatureAlgorithm())
+		return nil
+	}
+	return signer
+}
+
+// subjectKeyID returns the subject key identifier of pub. For RSA keys it is
+// computed like library-go does for the keys it generates.
+func subjectKeyID(pub crypto.PublicKey) []byte {
+	if pub, ok := pub.(*rsa.PublicKey); ok {
+		sum := sha1.Sum(pub.N.Bytes())
+		return sum[:]
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil
+	}
+	sum := sha1.Sum(der)
+	return sum[:]
+}
@@ -0,0 +1,76 @@
+package certmanager
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/openshift/library-go/pkg/crypto"
+	"github.com/openshift/library-go/pkg/operator/certrotation"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestManager_reusableKey(t *testing.T) {
+	caConfig, err := crypto.MakeSelfSignedCAConfig("test-ca", 48*time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	ca := &crypto.CA{Config: caConfig, SerialGenerator: &crypto.RandomSerialGenerator{}}
+	rotation := &certrotation.ServingRotation{Hostnames: func() []string { return []string{"wh.ns.svc", "wh"} }}
+
+	current, err := (&servingRotation{ServingRotation: rotation}).NewCertificate(ca, time.Hour)
+	if err != nil {
+		t.Fatalf("NewCertificate() error = %v", err)
+	}
+	certPEM, keyPEM, err := current.GetPEMBytes()
+	if err != nil {
+		t.Fatalf("Failed to encode certificate: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		policy      string
+		annotations map[string]string
+		wantReused  bool
+	}{
+		{name: "default", policy: ""},
+		{name: "always", policy: KeyRotationPolicyAlways},
+		{name: "never", policy: KeyRotationPolicyNever, wantReused: true},
+		{name: "never, rotation requested", policy: KeyRotationPolicyNever, annotations: map[string]string{RotateAnnotation: "true"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(fake.NewSimpleClientset())
+			m.config.ServingKeyRotationPolicy = tt.policy
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "wh-cert", Namespace: "ns", Annotations: tt.annotations},
+				Data:       map[string][]byte{"tls.crt": certPEM, "tls.key": keyPEM},
+			}
+
+			next, err := (&servingRotation{ServingRotation: rotation, key: m.reusableKey(secret)}).NewCertificate(ca, time.Hour)
+			if err != nil {
+				t.Fatalf("NewCertificate() error = %v", err)
+			}
+			cert := next.Certs[0]
+			if reused := cert.PublicKey.(*rsa.PublicKey).Equal(current.Certs[0].PublicKey); reused != tt.wantReused {
+				t.Errorf("key reused = %v, want %v", reused, tt.wantReused)
+			}
+			if cert.SerialNumber.Cmp(current.Certs[0].SerialNumber) == 0 {
+				t.Error("Expected a new certificate")
+			}
+			if cert.Subject.CommonName != current.Certs[0].Subject.CommonName || len(cert.DNSNames) != 2 {
+				t.Errorf("certificate for %q %v, want %q %v", cert.Subject.CommonName, cert.DNSNames,
+					current.Certs[0].Subject.CommonName, current.Certs[0].DNSNames)
+			}
+			pool := x509.NewCertPool()
+			pool.AddCert(ca.Config.Certs[0])
+			if _, err := cert.Verify(x509.VerifyOptions{DNSName: "wh.ns.svc", Roots: pool}); err != nil {
+				t.Errorf("Verify() error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package certmanager
+
+import (
+	"crypto/x509"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	listerscorev1 "k8s.io/client-go/listers/core/v1"
+	certutil "k8s.io/client-go/util/cert"
+)
+
+// CertificateInfo describes a certificate.
+type CertificateInfo struct {
+	// DNSNames and IPAddresses are the subject alternative names.
+	DNSNames    []string
+	IPAddresses []net.IP
+
+	SerialNumber *big.Int
+	Subject      string
+	Issuer       string
+	NotBefore    time.Time
+	NotAfter     time.Time
+}
+
+// Status is the state of the managed certificates.
+type Status struct {
+	// CA is the current signing CA, nil if it does not exist yet.
+	CA *CertificateInfo
+
+	// ServingCertificate is the current serving certificate, nil if it does
+	// not exist yet.
+	ServingCertificate *CertificateInfo
+
+	// CABundle lists the CAs in the CA bundle ConfigMap. During a CA rotation
+	// it holds both the previous and the new CA.
+	CABundle []CertificateInfo
+
+	// Running is whether the Manager is started, e.g. on the leader replica.
+	Running bool
+
+	// LastSyncTime is when the last sync finished, zero before the first.
+	LastSyncTime time.Time
+
+	// LastSyncError is the error of the last sync, nil if it succeeded. It is
+	// a *SyncError naming the resource the sync failed on.
+	LastSyncError error
+
+	// LastSuccessfulSyncTime is when the last successful sync finished, zero
+	// before the first.
+	LastSuccessfulSyncTime time.Time
+
+	// ConsecutiveFailures is the number of syncs that failed in a row.
+	ConsecutiveFailures int
+}
+
+// SyncError is the error of a failed sync.
+type SyncError struct {
+	// Resource is the kind, namespace and name of the resource the sync
+	// failed on, e.g. "Secret my-namespace/my-service-ca".
+	Resource string
+
+	Err error
+}
+
+func (e *SyncError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SyncError) Unwrap() error {
+	return e.Err
+}
+
+// UnhealthyAfterFailures is the number of syncs failing in a row after which
+// Healthy reports the Manager unhealthy, a few seconds after the first
+// failure with the retry backoff.
+const UnhealthyAfterFailures = 3
+
+// Healthy returns an error while the last UnhealthyAfterFailures syncs of the
+// running Manager failed, e.g. as a readiness check, so that certificates
+// silently failing to rotate on the leader become visible. A Manager that is
+// not running, e.g. on a replica that is not the leader, is healthy.
+func (m *Manager) Healthy() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.running || m.failures < UnhealthyAfterFailures {
+		return nil
+	}
+	since := "ever"
+	if !m.lastSuccessTime.IsZero() {
+		since = "since " + m.lastSuccessTime.UTC().Format(time.RFC3339)
+	}
+	return fmt.Errorf("certificate sync for service %s/%s failed %d times in a row, no successful sync %s: %w",
+		m.config.Namespace, m.config.ServiceName, m.failures, since, m.lastSyncError)
+}
+
+// Status returns the state of the managed certificates, read from the
+// informer cache. It fails if the manager has not started.
+func (m *Manager) Status() (Status, error) {
+	m.mu.Lock()
+	secretLister, configMapLister := m.secretLister, m.configMapLister
+	status := Status{
+		Running:                m.running,
+		LastSyncTime:           m.lastSyncTime,
+		LastSyncError:          m.lastSyncError,
+		LastSuccessfulSyncTime: m.lastSuccessTime,
+		ConsecutiveFailures:    m.failures,
+	}
+	m.mu.Unlock()
+
+	if secretLister == nil || configMapLister == nil {
+		return Status{}, fmt.Errorf("certificate manager not started")
+	}
+
+	var err error
+	if status.CA, err = m.secretCertificateInfo(secretLister, m.config.CASecretName); err != nil {
+		return Status{}, err
+	}
+	if status.ServingCertificate, err = m.secretCertificateInfo(secretLister, m.config.CertSecretName); err != nil {
+		return Status{}, err
+	}
+
+	cm, err := configMapLister.ConfigMaps(m.config.Namespace).Get(m.config.CABundleConfigMapName)
+	if err != nil && !errors.IsNotFound(err) {
+		return Status{}, err
+	}
+	if err == nil && cm.Data["ca-bundle.crt"] != "" {
+		certs, err := certutil.ParseCertsPEM([]byte(cm.Data["ca-bundle.crt"]))
+		if err != nil {
+			return Status{}, fmt.Errorf("failed to parse CA bundle %s/%s: %w", m.config.Namespace, m.config.CABundleConfigMapName, err)
+		}
+		for _, cert := range certs {
+			status.CABundle = append(status.CABundle, newCertificateInfo(cert))
+		}
+	}
+
+	return status, nil
+}
+
+// secretCertificateInfo returns the first certificate of a TLS secret, or nil
+// if the secret or its certificate does not exist yet.
+func (m *Manager) secretCertificateInfo(lister listerscorev1.SecretLister, name string) (*CertificateInfo, error) {
+	secret, err := lister.Secrets(m.config.Namespace).Get(name)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(secret.Data["tls.crt"]) == 0 {
+		return nil, nil
+	}
+	certs, err := certutil.ParseCertsPEM(secret.Data["tls.crt"])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate of secret %s/%s: %w", m.config.Namespace, name, err)
+	}
+	info := newCertificateInfo(certs[0])
+	return &info, nil
+}
+
+// newCertificateInfo describes cert.
+func newCertificateInfo(cert *x509.Certificate) CertificateInfo {
+	return CertificateInfo{
+		DNSNames:     cert.DNSNames,
+		IPAddresses:  cert.IPAddresses,
+		SerialNumber: cert.SerialNumber,
+		Subject:      cert.Subject.String(),
+		Issuer:       cert.Issuer.String(),
+		NotBefore:    cert.NotBefore,
+		NotAfter:     cert.NotAfter,
+	}
+}
@@ -0,0 +1,137 @@
+package certmanager
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestManager_Status(t *testing.T) {
+	m := newTestManager(fake.NewSimpleClientset())
+
+	if _, err := m.Status(); err == nil {
+		t.Error("Expected an error before the manager starts")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Start(ctx)
+	}()
+
+	status := waitForStatus(t, m, func(status Status) bool {
+		return status.ServingCertificate != nil && len(status.CABundle) > 0
+	})
+
+	if status.CA == nil {
+		t.Fatal("Expected CA info")
+	}
+	if status.ServingCertificate.Issuer != status.CA.Subject {
+		t.Errorf("Serving certificate issuer: got %q, want %q", status.ServingCertificate.Issuer, status.CA.Subject)
+	}
+	if status.CABundle[0].SerialNumber.Cmp(status.CA.SerialNumber) != 0 {
+		t.Error("Expected the CA bundle to contain the CA")
+	}
+	if len(status.ServingCertificate.DNSNames) == 0 {
+		t.Error("Expected serving certificate DNS names")
+	}
+	if status.LastSyncTime.IsZero() {
+		t.Error("Expected LastSyncTime to be set")
+	}
+
+	cancel()
+	<-done
+}
+
+func TestManager_Healthy(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	var failing atomic.Bool
+	failing.Store(true)
+	client.PrependReactor("create", "secrets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if failing.Load() {
+			return true, nil, errors.New("quota exceeded")
+		}
+		return false, nil, nil
+	})
+	m := newTestManager(client)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Start(ctx)
+	}()
+
+	status := waitForStatus(t, m, func(status Status) bool {
+		return status.ConsecutiveFailures >= UnhealthyAfterFailures
+	})
+	if err := m.Healthy(); err == nil {
+		t.Error("Expected the manager to be unhealthy")
+	}
+	if !status.Running || !status.LastSuccessfulSyncTime.IsZero() {
+		t.Errorf("Expected a running manager without a successful sync, got %+v", status)
+	}
+	var syncErr *SyncError
+	if !errors.As(status.LastSyncError, &syncErr) || syncErr.Resource != "Secret ns/wh-ca" {
+		t.Errorf("Expected the sync to fail on the CA secret, got %v", status.LastSyncError)
+	}
+
+	failing.Store(false)
+	status = waitForStatus(t, m, func(status Status) bool {
+		return status.LastSyncError == nil && status.ServingCertificate != nil
+	})
+	if err := m.Healthy(); err != nil {
+		t.Errorf("Expected the manager to recover, got %v", err)
+	}
+	if status.ConsecutiveFailures != 0 || status.LastSuccessfulSyncTime.IsZero() {
+		t.Errorf("Expected the failures to be reset, got %+v", status)
+	}
+
+	cancel()
+	<-done
+	// A stopped manager, e.g. after losing leadership, is healthy
+	if err := m.Healthy(); err != nil {
+		t.Errorf("Expected a stopped manager to be healthy, got %v", err)
+	}
+}
+
+// newTestManager creates a manager that syncs every 10ms.
+func newTestManager(client kubernetes.Interface) *Manager {
+	return New(client, Config{
+		Namespace:             "ns",
+		ServiceName:           "wh",
+		CASecretName:          "wh-ca",
+		CertSecretName:        "wh-cert",
+		CABundleConfigMapName: "wh-ca-bundle",
+		CAValidity:            48 * time.Hour,
+		CARefresh:             24 * time.Hour,
+		CertValidity:          24 * time.Hour,
+		CertRefresh:           12 * time.Hour,
+		SyncInterval:          10 * time.Millisecond,
+	})
+}
+
+// waitForStatus waits until the status of a running manager satisfies cond.
+func waitForStatus(t *testing.T, m *Manager, cond func(Status) bool) Status {
+	t.Helper()
+
+	deadline := time.After(10 * time.Second)
+	for {
+		status, err := m.Status()
+		if err == nil && cond(status) {
+			return status
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("Timed out waiting for status, got %+v, %v", status, err)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package certmanager
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Subject customizes the subject of issued certificates, e.g. to satisfy
+// auditing or naming conventions.
+type Subject struct {
+	// Organization and OrganizationalUnit are the O and OU attributes.
+	// Certificates with others are replaced on the next sync.
+	Organization       []string
+	OrganizationalUnit []string
+
+	// CommonName is a text/template of the common name, executed with
+	// SubjectData, e.g. "{{.ServiceName}} CA {{.Timestamp}}". Empty keeps
+	// library-go's "<namespace>_<secret name>@<Unix time>" for CAs and the
+	// first DNS name for serving certificates. Setting it replaces a CA with
+	// library-go's name on the next sync; other changes apply from the next
+	// rotation.
+	CommonName string
+}
+
+// SubjectData is the data of the Subject.CommonName template.
+type SubjectData struct {
+	// Namespace and SecretName identify the secret of the certificate.
+	Namespace  string
+	SecretName string
+
+	// ServiceName is the service the certificates are issued for.
+	ServiceName string
+
+	// Hostname is the first DNS name of a serving certificate, and empty for
+	// CAs.
+	Hostname string
+
+	// Timestamp is the Unix time of issuance.
+	Timestamp int64
+}
+
+// isZero returns whether s customizes nothing.
+func (s Subject) isZero() bool {
+	return len(s.Organization) == 0 && len(s.OrganizationalUnit) == 0 && s.CommonName == ""
+}
+
+// Validate checks that the CommonName template of s renders. For a CA, it
+// must render a distinct name for each CA, by including the Timestamp, as
+// library-go tells CAs apart by their common name, other than library-go's.
+func (s Subject) Validate(ca bool) error {
+	data := SubjectData{Namespace: "ns", SecretName: "secret", ServiceName: "svc", Timestamp: 1}
+	if !ca {
+		data.Hostname = "svc.ns.svc"
+	}
+	name, err := s.name(data, "default")
+	if err != nil {
+		return err
+	}
+	if name.CommonName == "" {
+		return fmt.Errorf("common name template %q renders an empty common name", s.CommonName)
+	}
+	if ca && s.CommonName != "" {
+		if libraryGoCAName(data.Namespace, data.SecretName, name.CommonName) {
+			return fmt.Errorf("common name template %q of the CA renders the default common name; leave it empty instead", s.CommonName)
+		}
+		data.Timestamp = 2
+		if other, _ := s.name(data, "default"); other.CommonName == name.CommonName {
+			return fmt.Errorf("common name template %q of the CA must include {{.Timestamp}}", s.CommonName)
+		}
+	}
+	return nil
+}
+
+// name returns the subject for data, with the common name defaultName if
+// s has no CommonName template.
+func (s Subject) name(data SubjectData, defaultName string) (pkix.Name, error) {
+	name := pkix.Name{
+		CommonName:         defaultName,
+		Organization:       s.Organization,
+		OrganizationalUnit: s.OrganizationalUnit,
+	}
+	if s.CommonName == "" {
+		return name, nil
+	}
+	tmpl, err := template.New("commonName").Option("missingkey=error").Parse(s.CommonName)
+	if err != nil {
+		return pkix.Name{}, fmt.Errorf("invalid common name template %q: %w", s.CommonName, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return pkix.Name{}, fmt.Errorf("failed to render common name template %q: %w", s.CommonName, err)
+	}
+	name.CommonName = b.String()
+	return name, nil
+}
+
+// matches returns whether name has the organization and organizational units
+// of s.
+func (s Subject) matches(name pkix.Name) bool {
+	return slices.Equal(name.Organization, s.Organization) && slices.Equal(name.OrganizationalUnit, s.OrganizationalUnit)
+}
+
+// caOutdated returns whether ca no longer matches the Config and has to be
+// replaced: it is signed with another algorithm, has another organization,
+// or still has library-go's common name although a template is set.
+func (m *Manager) caOutdated(ca *x509.Certificate) bool {
+	subject := m.config.CASubject
+	return ca.SignatureAlgorithm != m.signatureAlgorithm() || !subject.matches(ca.Subject) ||
+		(subject.CommonName != "" && libraryGoCAName(m.config.Namespace, m.config.CASecretName, ca.Subject.CommonName))
+}
+
+// servingOutdated returns whether the serving certificate cert no longer
+// matches the Config and has to be replaced.
+func (m *Manager) servingOutdated(cert *x509.Certificate) bool {
+	return cert.SignatureAlgorithm != m.signatureAlgorithm() || !m.config.ServingSubject.matches(cert.Subject)
+}
+
+// libraryGoCAName returns whether commonName is the one library-go gives the
+// CAs of the secret namespace/name: "<namespace>_<name>@<Unix time>".
+func libraryGoCAName(namespace, name, commonName string) bool {
+	timestamp, ok := strings.CutPrefix(commonName, namespace+"_"+name+"@")
+	_, err := strconv.ParseInt(timestamp, 10, 64)
+	return ok && err == nil
+}
+
+// caSubjectData returns the template data of a CA issued now.
+func (m *Manager) caSubjectData() SubjectData {
+	return SubjectData{
+		Namespace:   m.config.Namespace,
+		SecretName:  m.config.CASecretName,
+		ServiceName: m.config.ServiceName,
+		Timestamp:   time.Now().Unix(),
+	}
+}
@@ -0,0 +1,123 @@
+package certmanager
+
+import (
+	"context"
+	"crypto/x509"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/library-go/pkg/operator/certrotation"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSubject_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject Subject
+		ca      bool
+		wantErr bool
+	}{
+		{"defaults", Subject{}, true, false},
+		{"organization only", Subject{Organization: []string{"Example"}}, true, false},
+		{"CA with timestamp", Subject{CommonName: "{{.ServiceName}} CA {{.Timestamp}}"}, true, false},
+		{"CA without timestamp", Subject{CommonName: "{{.ServiceName}} CA"}, true, true},
+		{"CA with library-go's name", Subject{CommonName: "{{.Namespace}}_{{.SecretName}}@{{.Timestamp}}"}, true, true},
+		{"serving without timestamp", Subject{CommonName: "{{.Hostname}}"}, false, false},
+		{"unknown field", Subject{CommonName: "{{.Unknown}}"}, false, true},
+		{"invalid template", Subject{CommonName: "{{.ServiceName"}, false, true},
+		{"empty name", Subject{CommonName: "{{if false}}x{{end}}"}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.subject.Validate(tt.ca); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestManager_Subject(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	m := newTestManager(client)
+	m.config.CASubject = Subject{
+		Organization:       []string{"Example"},
+		OrganizationalUnit: []string{"Platform"},
+		CommonName:         "{{.ServiceName}} CA {{.Timestamp}}",
+	}
+	m.config.ServingSubject = Subject{
+		Organization: []string{"Example"},
+		CommonName:   "{{.ServiceName}}.{{.Namespace}}",
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Start(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		ca, serving, bundle := issuedCertificates(t, client)
+		if ca != nil && serving != nil && strings.HasPrefix(ca.Subject.CommonName, "wh CA ") && serving.Subject.CommonName == "wh.ns" {
+			pool := x509.NewCertPool()
+			for _, cert := range bundle {
+				pool.AddCert(cert)
+			}
+			// The serving certificate may be signed by a CA overwritten in
+			// the fake clientset, but still in the bundle.
+			if _, err := serving.Verify(x509.VerifyOptions{DNSName: "wh.ns.svc", Roots: pool}); err == nil {
+				if !slices.Equal(ca.Subject.Organization, []string{"Example"}) || !slices.Equal(ca.Subject.OrganizationalUnit, []string{"Platform"}) {
+					t.Errorf("CA subject = %s, want O=Example, OU=Platform", ca.Subject)
+				}
+				if !slices.Equal(serving.Subject.Organization, []string{"Example"}) {
+					t.Errorf("Serving certificate subject = %s, want O=Example", serving.Subject)
+				}
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for certificates with the configured subjects")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	secret, err := client.CoreV1().Secrets("ns").Get(ctx, "wh-ca", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get CA secret: %v", err)
+	}
+	if issuer := secret.Annotations[certrotation.CertificateIssuer]; !strings.HasPrefix(issuer, "wh CA ") {
+		t.Errorf("Expected the issuer annotation to name the CA, got %q", issuer)
+	}
+}
+
+func TestManager_caOutdated(t *testing.T) {
+	m := newTestManager(fake.NewSimpleClientset())
+	libraryGo := &x509.Certificate{SignatureAlgorithm: x509.SHA256WithRSA}
+	libraryGo.Subject.CommonName = "ns_wh-ca@1700000000"
+	if m.caOutdated(libraryGo) {
+		t.Error("Expected a library-go CA to match the default subject")
+	}
+
+	m.config.CASubject = Subject{CommonName: "{{.ServiceName}} CA {{.Timestamp}}"}
+	if !m.caOutdated(libraryGo) {
+		t.Error("Expected a CA named by library-go to be outdated with a common name template")
+	}
+	custom := &x509.Certificate{SignatureAlgorithm: x509.SHA256WithRSA}
+	custom.Subject.CommonName = "wh CA 1700000000"
+	if m.caOutdated(custom) {
+		t.Error("Expected a CA named by the template not to be outdated")
+	}
+
+	m.config.CASubject.Organization = []string{"Example"}
+	if !m.caOutdated(custom) {
+		t.Error("Expected a CA without the organization to be outdated")
+	}
+}
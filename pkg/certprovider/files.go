@@ -0,0 +1,229 @@
+package certprovider
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	"github.com/jimyag/auto-cert-webhook/internal/metrics"
+)
+
+const (
+	// dataLink is the symlink to the directory holding the current files, as
+	// in Kubernetes projected volumes.
+	dataLink = "..data"
+
+	// dataDirPrefix is the prefix of the directories holding file versions.
+	dataDirPrefix = "..data_"
+)
+
+// Default names of the files written to the certificate directory, which
+// are also the default keys of the certificate and key in the secret.
+const (
+	certFile = "tls.crt"
+	keyFile  = "tls.key"
+	caFile   = "ca.crt"
+)
+
+// caBundleKey is the default key of the CA bundle in the CA bundle ConfigMap.
+const caBundleKey = "ca-bundle.crt"
+
+// DataKeys are the names of the TLS material in the certificate secret, the
+// CA bundle ConfigMap and the certificate directory. Empty fields keep the
+// defaults.
+type DataKeys struct {
+	// Cert and Key are the keys of the certificate and private key in the
+	// secret, tls.crt and tls.key by default, and their file names in the
+	// certificate directory.
+	Cert, Key string
+
+	// CA is the file name of the CA bundle in the certificate directory,
+	// ca.crt by default.
+	CA string
+
+	// CABundle is the key of the CA bundle in its ConfigMap, ca-bundle.crt
+	// by default.
+	CABundle string
+}
+
+// withDefaults returns k with the defaults for empty fields.
+func (k DataKeys) withDefaults() DataKeys {
+	k.Cert = cmp.Or(k.Cert, certFile)
+	k.Key = cmp.Or(k.Key, keyFile)
+	k.CA = cmp.Or(k.CA, caFile)
+	k.CABundle = cmp.Or(k.CABundle, caBundleKey)
+	return k
+}
+
+// certFiles holds the contents of the certificate files.
+type certFiles struct {
+	cert, key, ca []byte
+
+	// written is what was last written successfully.
+	written map[string][]byte
+}
+
+// setCertFiles records a certificate and key to be written to the
+// certificate directory.
+func (p *Provider) setCertFiles(certPEM, keyPEM []byte) {
+	if p.certDir == "" {
+		return
+	}
+	p.filesMu.Lock()
+	defer p.filesMu.Unlock()
+	p.files.cert, p.files.key = certPEM, keyPEM
+	p.syncFilesLocked()
+}
+
+// setCAFile records a CA bundle to be written to the certificate directory.
+func (p *Provider) setCAFile(caPEM []byte) {
+	p.filesMu.Lock()
+	defer p.filesMu.Unlock()
+	p.files.ca = caPEM
+	p.syncFilesLocked()
+}
+
+// syncFilesLocked writes the certificate files if they changed since the last
+// successful write, so that failed writes are retried on the next update or
+// resync. filesMu must be held.
+func (p *Provider) syncFilesLocked() {
+	if p.files.cert == nil {
+		return
+	}
+	files := map[string][]byte{
+		p.keys.Cert: p.files.cert,
+		p.keys.Key:  p.files.key,
+	}
+	if p.files.ca != nil {
+		files[p.keys.CA] = p.files.ca
+	}
+	if maps.EqualFunc(files, p.files.written, bytes.Equal) {
+		return
+	}
+	if err := writeFiles(p.certDir, files, p.keys.Key); err != nil {
+		klog.Errorf("Failed to write certificate files to %s: %v", p.certDir, err)
+		return
+	}
+	p.files.written = files
+	klog.Infof("Certificate files written to %s", p.certDir)
+}
+
+// watchCABundle watches the CA bundle ConfigMap and writes its bundle to the
+// certificate directory. It returns the sync function of the informer and a
+// function that stops watching.
+func (p *Provider) watchCABundle(ctx context.Context) (cache.InformerSynced, func(), error) {
+	factory := p.factory
+	if factory == nil {
+		factory = p.newScopedFactory(p.caBundleName)
+	}
+	informer := factory.Core().V1().ConfigMaps().Informer()
+
+	onUpdate := func(obj interface{}) {
+		cm, ok := obj.(*corev1.ConfigMap)
+		if !ok || cm.Name != p.caBundleName {
+			return
+		}
+		if caPEM := cm.Data[p.keys.CABundle]; caPEM != "" {
+			p.setCAFile([]byte(caPEM))
+		}
+	}
+	registration, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: onUpdate,
+		UpdateFunc: func(_, newObj interface{}) {
+			onUpdate(newObj)
+		},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to add event handler: %w", err)
+	}
+
+	stop := func() {
+		_ = informer.RemoveEventHandler(registration)
+	}
+	if p.factory == nil {
+		_ = informer.SetWatchErrorHandlerWithContext(metrics.WatchErrorHandler("configmaps"))
+		factory.Start(ctx.Done())
+		stop = func() {
+			_ = informer.RemoveEventHandler(registration)
+			factory.Shutdown()
+		}
+	}
+	return informer.HasSynced, stop, nil
+}
+
+// writeFiles replaces the files in dir atomically: they are written to a new
+// directory and the ..data symlink is switched to it, so that readers going
+// through the file symlinks never see a certificate and key that do not
+// match. The keyName file is only readable by the owner and group.
+func writeFiles(dir string, files map[string][]byte, keyName string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	versionDir, err := os.MkdirTemp(dir, dataDirPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.Chmod(versionDir, 0o755); err != nil {
+		os.RemoveAll(versionDir)
+		return fmt.Errorf("failed to set directory permissions: %w", err)
+	}
+	for name, data := range files {
+		perm := os.FileMode(0o644)
+		if name == keyName {
+			perm = 0o640
+		}
+		if err := os.WriteFile(filepath.Join(versionDir, name), data, perm); err != nil {
+			os.RemoveAll(versionDir)
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+
+	previous, _ := os.Readlink(filepath.Join(dir, dataLink))
+	if err := replaceSymlink(filepath.Join(dir, dataLink), filepath.Base(versionDir)); err != nil {
+		os.RemoveAll(versionDir)
+		return err
+	}
+
+	for name := range files {
+		link := filepath.Join(dir, name)
+		target := filepath.Join(dataLink, name)
+		if current, err := os.Readlink(link); err == nil && current == target {
+			continue
+		}
+		if err := replaceSymlink(link, target); err != nil {
+			return err
+		}
+	}
+
+	if previous != "" && previous != filepath.Base(versionDir) {
+		if err := os.RemoveAll(filepath.Join(dir, previous)); err != nil {
+			return fmt.Errorf("failed to remove previous files: %w", err)
+		}
+	}
+	return nil
+}
+
+// replaceSymlink atomically points link at target.
+func replaceSymlink(link, target string) error {
+	tmp := link + ".tmp"
+	if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", tmp, err)
+	}
+	if err := os.Symlink(target, tmp); err != nil {
+		return fmt.Errorf("failed to create symlink: %w", err)
+	}
+	if err := os.Rename(tmp, link); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace %s: %w", link, err)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package certprovider
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWriteFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "tls")
+
+	if err := writeFiles(dir, map[string][]byte{certFile: []byte("cert1"), keyFile: []byte("key1")}, keyFile); err != nil {
+		t.Fatalf("writeFiles() error = %v", err)
+	}
+	first, err := os.Readlink(filepath.Join(dir, dataLink))
+	if err != nil {
+		t.Fatalf("Expected %s symlink: %v", dataLink, err)
+	}
+
+	if err := writeFiles(dir, map[string][]byte{certFile: []byte("cert2"), keyFile: []byte("key2")}, keyFile); err != nil {
+		t.Fatalf("writeFiles() error = %v", err)
+	}
+
+	for name, want := range map[string]string{certFile: "cert2", keyFile: "key2"} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+
+	info, err := os.Stat(filepath.Join(dir, keyFile))
+	if err != nil {
+		t.Fatalf("Failed to stat %s: %v", keyFile, err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o640 {
+		t.Errorf("%s permissions: got %o, want %o", keyFile, perm, 0o640)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, first)); !os.IsNotExist(err) {
+		t.Errorf("Expected previous directory %s to be removed, got %v", first, err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read directory: %v", err)
+	}
+	// ..data, its target and the two file symlinks
+	if len(entries) != 4 {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("Expected 4 entries, got %v", names)
+	}
+}
+
+func TestProvider_setCertFiles(t *testing.T) {
+	certPEM, keyPEM := generateTestCert(t)
+	dir := t.TempDir()
+	provider := New(fake.NewSimpleClientset(), "test-ns", "test-secret")
+	provider.SetCertDir(dir, "test-ca-bundle")
+
+	// The CA bundle alone is not written
+	provider.setCAFile([]byte("ca"))
+	if _, err := os.Stat(filepath.Join(dir, caFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected no %s before the certificate is loaded, got %v", caFile, err)
+	}
+
+	provider.onSecretUpdate(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-secret", Namespace: "test-ns"},
+		Data: map[string][]byte{
+			"tls.crt": certPEM,
+			"tls.key": keyPEM,
+		},
+	})
+
+	for name, want := range map[string][]byte{certFile: certPEM, keyFile: keyPEM, caFile: []byte("ca")} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestProvider_Start_CertDir(t *testing.T) {
+	certPEM, keyPEM := generateTestCert(t)
+	dir := t.TempDir()
+	client := fake.NewSimpleClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-secret", Namespace: "test-ns"},
+			Data: map[string][]byte{
+				"tls.crt": certPEM,
+				"tls.key": keyPEM,
+			},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-ca-bundle", Namespace: "test-ns"},
+			Data:       map[string]string{caBundleKey: "ca"},
+		},
+	)
+	provider := New(client, "test-ns", "test-secret")
+	provider.SetCertDir(dir, "test-ca-bundle")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- provider.Start(ctx)
+	}()
+
+	_, err := client.CoreV1().ConfigMaps("test-ns").Update(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-ca-bundle", Namespace: "test-ns"},
+		Data:       map[string]string{caBundleKey: "rotated-ca"},
+	}, metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to update ConfigMap: %v", err)
+	}
+
+	deadline := time.After(5 * time.Second)
+	for {
+		got, _ := os.ReadFile(filepath.Join(dir, caFile))
+		if string(got) == "rotated-ca" {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("Expected %s to be updated, got %q", caFile, got)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, certFile))
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", certFile, err)
+	}
+	if !bytes.Equal(got, certPEM) {
+		t.Errorf("%s does not match the secret", certFile)
+	}
+
+	cancel()
+	<-done
+}
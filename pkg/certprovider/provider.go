@@ -0,0 +1,368 @@
+// Package certprovider serves a TLS certificate from a Kubernetes secret and
+// reloads it when the secret changes, e.g. one maintained by the certmanager
+// package. It can be used by any TLS server:
+//
+//	provider := certprovider.New(client, "my-namespace", "my-service-cert")
+//	go provider.Start(ctx)
+//	server := &http.Server{TLSConfig: &tls.Config{GetCertificate: provider.GetCertificate}}
+package certprovider
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+	"net"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jimyag/auto-cert-webhook/internal/metrics"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// Source provides the serving certificate of a TLS server.
+type Source interface {
+	// GetCertificate returns the current certificate; it can be used as
+	// tls.Config.GetCertificate.
+	GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error)
+
+	// Ready returns whether a certificate is loaded.
+	Ready() bool
+}
+
+var _ Source = (*Provider)(nil)
+
+// Provider provides dynamic TLS certificates loaded from Kubernetes secrets.
+type Provider struct {
+	client    kubernetes.Interface
+	namespace string
+	name      string
+
+	// factory is a shared informer factory for the namespace; nil means Start
+	// uses its own, scoped to the certificate secret.
+	factory informers.SharedInformerFactory
+
+	// resyncPeriod is how often the secret is re-read from the API server,
+	// bypassing the informer cache. Zero disables it.
+	resyncPeriod time.Duration
+
+	// certDir is the directory the certificate files are written to; empty
+	// disables writing them.
+	certDir string
+	// caBundleName is the ConfigMap holding the CA bundle written as ca.crt.
+	caBundleName string
+
+	// keys are the names of the certificate, key and CA bundle.
+	keys DataKeys
+
+	filesMu sync.Mutex
+	files   certFiles
+
+	rotateMu sync.Mutex
+	onRotate []func(tls.Certificate)
+
+	current atomic.Pointer[tls.Certificate]
+	ready   atomic.Bool
+}
+
+// New creates a new certificate provider.
+func New(client kubernetes.Interface, namespace, secretName string) *Provider {
+	return &Provider{
+		client:    client,
+		namespace: namespace,
+		name:      secretName,
+		keys:      DataKeys{}.withDefaults(),
+	}
+}
+
+// SetDataKeys sets the keys of the certificate and key in the secret and of
+// the CA bundle in its ConfigMap, and the names of the files in the
+// certificate directory, for secrets that follow other key conventions.
+func (p *Provider) SetDataKeys(keys DataKeys) {
+	p.keys = keys.withDefaults()
+}
+
+// SetInformerFactory makes the provider watch the secret through a shared
+// informer factory for its namespace. The caller starts the factory.
+func (p *Provider) SetInformerFactory(factory informers.SharedInformerFactory) {
+	p.factory = factory
+	// Request the informer now so that it starts with the factory.
+	factory.Core().V1().Secrets().Informer()
+	if p.caBundleName != "" {
+		factory.Core().V1().ConfigMaps().Informer()
+	}
+}
+
+// OnRotate registers a function that is called with every newly loaded
+// certificate, including the first one, e.g. to update connection pools or
+// other servers that share the certificate. Functions are called in the order
+// they were registered and must not block.
+func (p *Provider) OnRotate(fn func(tls.Certificate)) {
+	p.rotateMu.Lock()
+	defer p.rotateMu.Unlock()
+	p.onRotate = append(p.onRotate, fn)
+}
+
+// SetCertDir makes the provider also write the certificate and key to dir as
+// tls.crt and tls.key, and the CA bundle from the caBundleConfigMapName
+// ConfigMap as ca.crt, for processes that only read TLS material from files.
+// An empty caBundleConfigMapName skips ca.crt. Call it before
+// SetInformerFactory.
+func (p *Provider) SetCertDir(dir, caBundleConfigMapName string) {
+	p.certDir = dir
+	p.caBundleName = caBundleConfigMapName
+}
+
+// SetResyncPeriod sets how often the secret is re-read from the API server,
+// so that a broken watch cannot leave a stale certificate in use
+// indefinitely. Zero or a negative value disables it.
+func (p *Provider) SetResyncPeriod(period time.Duration) {
+	p.resyncPeriod = period
+}
+
+// Start starts watching the secret and loading certificates.
+func (p *Provider) Start(ctx context.Context) error {
+	// Try to load the initial certificate
+	if err := p.loadCertificate(ctx); err != nil {
+		klog.Warningf("Initial certificate load failed (will retry via informer): %v", err)
+	}
+
+	// Set up informer to watch for changes. Without a shared factory only the
+	// certificate secret is listed and watched, so namespaces with many
+	// secrets do not bloat the cache.
+	factory := p.factory
+	if factory == nil {
+		factory = p.newScopedFactory(p.name)
+	}
+
+	secretInformer := factory.Core().V1().Secrets().Informer()
+
+	registration, err := secretInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			secret, ok := obj.(*corev1.Secret)
+			if !ok {
+				klog.Warningf("unexpected object type in AddFunc: %T", obj)
+				return
+			}
+			if secret.Name == p.name {
+				p.onSecretUpdate(secret)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			secret, ok := newObj.(*corev1.Secret)
+			if !ok {
+				klog.Warningf("unexpected object type in UpdateFunc: %T", newObj)
+				return
+			}
+			if secret.Name == p.name {
+				p.onSecretUpdate(secret)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			secret, ok := obj.(*corev1.Secret)
+			if !ok {
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					return
+				}
+				secret, ok = tombstone.Obj.(*corev1.Secret)
+				if !ok {
+					return
+				}
+			}
+			if secret.Name == p.name {
+				klog.Warningf("Certificate secret %s/%s deleted", p.namespace, p.name)
+				p.current.Store(nil)
+				p.ready.Store(false)
+			}
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add event handler: %w", err)
+	}
+	// Remove the handler so that a restart does not register it twice.
+	defer func() {
+		_ = secretInformer.RemoveEventHandler(registration)
+	}()
+
+	synced := []cache.InformerSynced{secretInformer.HasSynced}
+
+	if p.factory == nil {
+		_ = secretInformer.SetWatchErrorHandlerWithContext(metrics.WatchErrorHandler("secrets"))
+		factory.Start(ctx.Done())
+		// Wait for the informer goroutines to exit before returning.
+		defer factory.Shutdown()
+	}
+
+	if p.certDir != "" && p.caBundleName != "" {
+		caBundleSynced, stop, err := p.watchCABundle(ctx)
+		if err != nil {
+			return err
+		}
+		defer stop()
+		synced = append(synced, caBundleSynced)
+	}
+
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+		return fmt.Errorf("failed to sync informer cache")
+	}
+
+	klog.Infof("Certificate provider started watching secret %s/%s", p.namespace, p.name)
+
+	if p.resyncPeriod <= 0 {
+		<-ctx.Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(p.resyncPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := p.loadCertificate(ctx); err != nil {
+				klog.Errorf("Failed to re-read certificate secret %s/%s: %v", p.namespace, p.name, err)
+			}
+		}
+	}
+}
+
+// newScopedFactory creates an informer factory that only lists and watches
+// the object with the given name.
+func (p *Provider) newScopedFactory(name string) informers.SharedInformerFactory {
+	return informers.NewSharedInformerFactoryWithOptions(
+		p.client,
+		max(p.resyncPeriod, 0),
+		informers.WithNamespace(p.namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}),
+	)
+}
+
+// loadCertificate loads the certificate from the secret.
+func (p *Provider) loadCertificate(ctx context.Context) error {
+	secret, err := p.client.CoreV1().Secrets(p.namespace).Get(ctx, p.name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			klog.V(4).Infof("Secret %s/%s not found yet", p.namespace, p.name)
+			return nil
+		}
+		return err
+	}
+
+	p.onSecretUpdate(secret)
+	return nil
+}
+
+// onSecretUpdate handles secret updates.
+func (p *Provider) onSecretUpdate(secret *corev1.Secret) {
+	certPEM, ok := secret.Data[p.keys.Cert]
+	if !ok || len(certPEM) == 0 {
+		klog.V(4).Infof("Secret %s/%s has no %s data yet", p.namespace, p.name, p.keys.Cert)
+		return
+	}
+
+	keyPEM, ok := secret.Data[p.keys.Key]
+	if !ok || len(keyPEM) == 0 {
+		klog.V(4).Infof("Secret %s/%s has no %s data yet", p.namespace, p.name, p.keys.Key)
+		return
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		klog.Errorf("Failed to parse certificate from secret %s/%s: %v", p.namespace, p.name, err)
+		return
+	}
+
+	p.setCertFiles(certPEM, keyPEM)
+
+	// Update metrics
+	if cert.Leaf == nil {
+		parsed, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			klog.Warningf("Failed to parse certificate leaf from secret %s/%s: %v", p.namespace, p.name, err)
+		} else {
+			cert.Leaf = parsed
+		}
+	}
+	if cert.Leaf != nil {
+		metrics.UpdateCertMetrics(metrics.CertID{Type: "serving", Namespace: p.namespace, SecretName: p.name}, cert.Leaf)
+	}
+
+	// Resyncs deliver the same secret again; only log actual changes
+	if current := p.current.Load(); current != nil && bytes.Equal(current.Certificate[0], cert.Certificate[0]) {
+		p.ready.Store(true)
+		return
+	}
+
+	p.current.Store(&cert)
+	p.ready.Store(true)
+	klog.Infof("Certificate reloaded from secret %s/%s", p.namespace, p.name)
+
+	p.rotateMu.Lock()
+	callbacks := slices.Clone(p.onRotate)
+	p.rotateMu.Unlock()
+	for _, fn := range callbacks {
+		fn(cert)
+	}
+}
+
+// Info describes a serving certificate.
+type Info struct {
+	// DNSNames and IPAddresses are the subject alternative names.
+	DNSNames    []string
+	IPAddresses []net.IP
+
+	SerialNumber *big.Int
+	Subject      string
+	Issuer       string
+	NotBefore    time.Time
+	NotAfter     time.Time
+}
+
+// Info returns the metadata of the current certificate, or nil if none is
+// loaded.
+func (p *Provider) Info() *Info {
+	cert := p.current.Load()
+	if cert == nil || cert.Leaf == nil {
+		return nil
+	}
+	return &Info{
+		DNSNames:     cert.Leaf.DNSNames,
+		IPAddresses:  cert.Leaf.IPAddresses,
+		SerialNumber: cert.Leaf.SerialNumber,
+		Subject:      cert.Leaf.Subject.String(),
+		Issuer:       cert.Leaf.Issuer.String(),
+		NotBefore:    cert.Leaf.NotBefore,
+		NotAfter:     cert.Leaf.NotAfter,
+	}
+}
+
+// GetCertificate returns the current certificate for TLS configuration.
+func (p *Provider) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert := p.current.Load()
+	if cert == nil {
+		return nil, fmt.Errorf("certificate not yet loaded from secret %s/%s", p.namespace, p.name)
+	}
+	return cert, nil
+}
+
+// Ready returns true if the certificate is loaded and ready.
+func (p *Provider) Ready() bool {
+	return p.ready.Load()
+}
@@ -1,9 +1,11 @@
 package certprovider
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
@@ -13,7 +15,9 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestNew(t *testing.T) {
@@ -200,6 +204,34 @@ func TestProvider_loadCertificate_Exists(t *testing.T) {
 	}
 }
 
+func TestProvider_loadCertificate_OpaqueWithOtherData(t *testing.T) {
+	certPEM, keyPEM := generateTestCert(t)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-secret",
+			Namespace: "test-ns",
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			"cert.pem": certPEM,
+			"key.pem":  keyPEM,
+			"other":    []byte("material"),
+		},
+	}
+
+	client := fake.NewSimpleClientset(secret)
+	provider := New(client, "test-ns", "test-secret")
+	provider.SetDataKeys(DataKeys{Cert: "cert.pem", Key: "key.pem"})
+
+	if err := provider.loadCertificate(context.Background()); err != nil {
+		t.Fatalf("loadCertificate failed: %v", err)
+	}
+	if !provider.Ready() {
+		t.Error("Provider should be ready after loading certificate")
+	}
+}
+
 func TestProvider_CertificateReload(t *testing.T) {
 	certPEM1, keyPEM1 := generateTestCert(t)
 	certPEM2, keyPEM2 := generateTestCert(t)
@@ -264,6 +296,156 @@ func TestProvider_CertificateReload(t *testing.T) {
 	}
 }
 
+func TestProvider_Start_WatchesOnlyCertSecret(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	provider := New(client, "test-ns", "test-secret")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- provider.Start(ctx)
+	}()
+
+	// Wait for the informer to list and watch
+	deadline := time.After(5 * time.Second)
+	for {
+		var list, watch bool
+		for _, action := range client.Actions() {
+			if action.GetResource().Resource != "secrets" {
+				continue
+			}
+			switch a := action.(type) {
+			case k8stesting.ListAction:
+				list = true
+				if got := a.GetListRestrictions().Fields.String(); got != "metadata.name=test-secret" {
+					t.Errorf("List field selector: got %q, want %q", got, "metadata.name=test-secret")
+				}
+			case k8stesting.WatchAction:
+				watch = true
+				if got := a.GetWatchRestrictions().Fields.String(); got != "metadata.name=test-secret" {
+					t.Errorf("Watch field selector: got %q, want %q", got, "metadata.name=test-secret")
+				}
+			}
+		}
+		if list && watch {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("Expected list and watch of secrets, got list=%v watch=%v", list, watch)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	cancel()
+	<-done
+}
+
+func TestProvider_Start_SharedInformerFactory(t *testing.T) {
+	certPEM, keyPEM := generateTestCert(t)
+	client := fake.NewSimpleClientset()
+	factory := informers.NewSharedInformerFactoryWithOptions(client, 0, informers.WithNamespace("test-ns"))
+	provider := New(client, "test-ns", "test-secret")
+	provider.SetInformerFactory(factory)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	factory.Start(ctx.Done())
+	defer factory.Shutdown()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- provider.Start(ctx)
+	}()
+
+	_, err := client.CoreV1().Secrets("test-ns").Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-secret", Namespace: "test-ns"},
+		Data: map[string][]byte{
+			"tls.crt": certPEM,
+			"tls.key": keyPEM,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	deadline := time.After(5 * time.Second)
+	for !provider.Ready() {
+		select {
+		case <-deadline:
+			t.Fatal("Expected provider to load the certificate from the shared informer")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	cancel()
+	<-done
+}
+
+func TestProvider_Start_Resync(t *testing.T) {
+	certPEM, keyPEM := generateTestCert(t)
+	client := fake.NewSimpleClientset()
+	provider := New(client, "test-ns", "test-secret")
+	provider.SetResyncPeriod(20 * time.Millisecond)
+
+	// The informer watches another cluster, so only the resync can see the secret
+	factory := informers.NewSharedInformerFactoryWithOptions(fake.NewSimpleClientset(), 0, informers.WithNamespace("test-ns"))
+	provider.SetInformerFactory(factory)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	factory.Start(ctx.Done())
+	defer factory.Shutdown()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- provider.Start(ctx)
+	}()
+
+	_, err := client.CoreV1().Secrets("test-ns").Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-secret", Namespace: "test-ns"},
+		Data: map[string][]byte{
+			"tls.crt": certPEM,
+			"tls.key": keyPEM,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	deadline := time.After(5 * time.Second)
+	for !provider.Ready() {
+		select {
+		case <-deadline:
+			t.Fatal("Expected provider to re-read the secret from the API server")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	cancel()
+	<-done
+}
+
+func TestProvider_onSecretUpdate_Unchanged(t *testing.T) {
+	certPEM, keyPEM := generateTestCert(t)
+	provider := New(fake.NewSimpleClientset(), "test-ns", "test-secret")
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-secret", Namespace: "test-ns"},
+		Data: map[string][]byte{
+			"tls.crt": certPEM,
+			"tls.key": keyPEM,
+		},
+	}
+
+	provider.onSecretUpdate(secret)
+	first := provider.current.Load()
+	provider.onSecretUpdate(secret)
+
+	if provider.current.Load() != first {
+		t.Error("Expected an unchanged certificate to be kept")
+	}
+}
+
 // generateTestCert generates a self-signed test certificate
 func generateTestCert(t *testing.T) (certPEM, keyPEM []byte) {
 	t.Helper()
@@ -295,3 +477,74 @@ func generateTestCert(t *testing.T) (certPEM, keyPEM []byte) {
 
 	return certPEM, keyPEM
 }
+
+func TestProvider_OnRotate(t *testing.T) {
+	certPEM, keyPEM := generateTestCert(t)
+	provider := New(fake.NewSimpleClientset(), "test-ns", "test-secret")
+
+	var rotated []tls.Certificate
+	provider.OnRotate(func(cert tls.Certificate) {
+		rotated = append(rotated, cert)
+	})
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-secret", Namespace: "test-ns"},
+		Data: map[string][]byte{
+			"tls.crt": certPEM,
+			"tls.key": keyPEM,
+		},
+	}
+	provider.onSecretUpdate(secret)
+	// A resync of the same certificate is not a rotation
+	provider.onSecretUpdate(secret)
+
+	if len(rotated) != 1 {
+		t.Fatalf("Expected 1 rotation, got %d", len(rotated))
+	}
+	if !bytes.Equal(rotated[0].Certificate[0], provider.current.Load().Certificate[0]) {
+		t.Error("Expected the callback to receive the loaded certificate")
+	}
+
+	newCertPEM, newKeyPEM := generateTestCert(t)
+	secret.Data = map[string][]byte{
+		"tls.crt": newCertPEM,
+		"tls.key": newKeyPEM,
+	}
+	provider.onSecretUpdate(secret)
+
+	if len(rotated) != 2 {
+		t.Errorf("Expected 2 rotations, got %d", len(rotated))
+	}
+}
+
+func TestProvider_Info(t *testing.T) {
+	certPEM, keyPEM := generateTestCert(t)
+	provider := New(fake.NewSimpleClientset(), "test-ns", "test-secret")
+
+	if info := provider.Info(); info != nil {
+		t.Errorf("Expected no info before a certificate is loaded, got %+v", info)
+	}
+
+	provider.onSecretUpdate(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-secret", Namespace: "test-ns"},
+		Data: map[string][]byte{
+			"tls.crt": certPEM,
+			"tls.key": keyPEM,
+		},
+	})
+
+	info := provider.Info()
+	if info == nil {
+		t.Fatal("Expected info after the certificate is loaded")
+	}
+	leaf := provider.current.Load().Leaf
+	if info.SerialNumber.Cmp(leaf.SerialNumber) != 0 {
+		t.Errorf("SerialNumber: got %v, want %v", info.SerialNumber, leaf.SerialNumber)
+	}
+	if !info.NotAfter.Equal(leaf.NotAfter) {
+		t.Errorf("NotAfter: got %v, want %v", info.NotAfter, leaf.NotAfter)
+	}
+	if info.Issuer != leaf.Issuer.String() {
+		t.Errorf("Issuer: got %q, want %q", info.Issuer, leaf.Issuer.String())
+	}
+}
@@ -0,0 +1,454 @@
+// Package doctor inspects a running auto-cert-webhook deployment through the
+// Kubernetes API and reports prioritized findings for support triage.
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// expiryWarningThreshold is the remaining validity below which an expiring certificate is reported.
+	expiryWarningThreshold = 6 * time.Hour
+
+	// maxEventFindings limits the number of recent warning events reported.
+	maxEventFindings = 5
+)
+
+// Severity is the severity of a finding.
+type Severity int
+
+const (
+	// SeverityCritical means the webhook is, or soon will be, unable to serve requests.
+	SeverityCritical Severity = iota
+	// SeverityWarning means something needs attention but the webhook still works.
+	SeverityWarning
+	// SeverityInfo is informational.
+	SeverityInfo
+)
+
+// String returns the name of the severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityCritical:
+		return "CRITICAL"
+	case SeverityWarning:
+		return "WARNING"
+	case SeverityInfo:
+		return "INFO"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Config identifies the deployment to inspect. Empty resource names default to
+// the same values the webhook framework uses.
+type Config struct {
+	// Name is the webhook name. Required.
+	Name string
+
+	// Namespace is the namespace where the webhook is deployed. Required.
+	Namespace string
+
+	// ServiceName defaults to Name.
+	ServiceName string
+
+	// CASecretName defaults to "<Name>-ca".
+	CASecretName string
+
+	// CertSecretName defaults to "<Name>-cert".
+	CertSecretName string
+
+	// CABundleConfigMapName defaults to "<Name>-ca-bundle".
+	CABundleConfigMapName string
+
+	// LeaderElectionID defaults to "<Name>-leader".
+	LeaderElectionID string
+
+	// CertDataKey is the key of the certificate in CertSecretName and
+	// defaults to "tls.crt".
+	CertDataKey string
+
+	// CABundleDataKey is the key of the CA bundle in CABundleConfigMapName
+	// and defaults to "ca-bundle.crt".
+	CABundleDataKey string
+}
+
+// applyDefaults fills in default resource names.
+func (c *Config) applyDefaults() {
+	if c.ServiceName == "" {
+		c.ServiceName = c.Name
+	}
+	if c.CASecretName == "" {
+		c.CASecretName = c.Name + "-ca"
+	}
+	if c.CertSecretName == "" {
+		c.CertSecretName = c.Name + "-cert"
+	}
+	if c.CABundleConfigMapName == "" {
+		c.CABundleConfigMapName = c.Name + "-ca-bundle"
+	}
+	if c.LeaderElectionID == "" {
+		c.LeaderElectionID = c.Name + "-leader"
+	}
+	if c.CertDataKey == "" {
+		c.CertDataKey = corev1.TLSCertKey
+	}
+	if c.CABundleDataKey == "" {
+		c.CABundleDataKey = "ca-bundle.crt"
+	}
+}
+
+// Finding is a single diagnostic result.
+type Finding struct {
+	// Severity is the severity of the finding.
+	Severity Severity
+
+	// Check is the name of the check that produced the finding.
+	Check string
+
+	// Message describes the finding.
+	Message string
+}
+
+// Report is the result of a diagnosis.
+type Report struct {
+	// Findings are sorted by severity, most severe first.
+	Findings []Finding
+}
+
+// HasCritical returns true if the report contains critical findings.
+func (r *Report) HasCritical() bool {
+	for _, f := range r.Findings {
+		if f.Severity == SeverityCritical {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes a human-readable report.
+func (r *Report) Print(w io.Writer) error {
+	for _, f := range r.Findings {
+		if _, err := fmt.Fprintf(w, "[%-8s] %-14s %s\n", f.Severity, f.Check, f.Message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// doctor runs the checks and collects findings.
+type doctor struct {
+	client kubernetes.Interface
+	config Config
+	now    time.Time
+
+	findings []Finding
+}
+
+// Diagnose inspects the deployment and returns a report of prioritized findings.
+func Diagnose(ctx context.Context, client kubernetes.Interface, config Config) (*Report, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("webhook name is required")
+	}
+	if config.Namespace == "" {
+		return nil, fmt.Errorf("namespace is required")
+	}
+	config.applyDefaults()
+
+	d := &doctor{
+		client: client,
+		config: config,
+		now:    time.Now(),
+	}
+
+	bundle := d.checkCABundle(ctx)
+	d.checkServingCert(ctx, bundle)
+	d.checkWebhookConfigurations(ctx, bundle)
+	d.checkEndpoints(ctx)
+	d.checkLease(ctx)
+	d.checkEvents(ctx)
+
+	sort.SliceStable(d.findings, func(i, j int) bool {
+		return d.findings[i].Severity < d.findings[j].Severity
+	})
+	return &Report{Findings: d.findings}, nil
+}
+
+// add records a finding.
+func (d *doctor) add(severity Severity, check, format string, args ...interface{}) {
+	d.findings = append(d.findings, Finding{
+		Severity: severity,
+		Check:    check,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// checkCABundle loads the CA bundle configmap and returns the bundle PEM.
+func (d *doctor) checkCABundle(ctx context.Context) []byte {
+	const check = "ca-bundle"
+
+	cm, err := d.client.CoreV1().ConfigMaps(d.config.Namespace).Get(ctx, d.config.CABundleConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		d.add(SeverityCritical, check, "cannot get configmap %s/%s: %v", d.config.Namespace, d.config.CABundleConfigMapName, err)
+		return nil
+	}
+
+	bundle := []byte(cm.Data[d.config.CABundleDataKey])
+	certs, err := parseCertificates(bundle)
+	if err != nil || len(certs) == 0 {
+		d.add(SeverityCritical, check, "configmap %s/%s has no valid %s", d.config.Namespace, d.config.CABundleConfigMapName, d.config.CABundleDataKey)
+		return nil
+	}
+
+	valid := 0
+	for _, cert := range certs {
+		if d.now.Before(cert.NotAfter) {
+			valid++
+		}
+	}
+	if valid == 0 {
+		d.add(SeverityCritical, check, "all %d CA certificates in the bundle have expired", len(certs))
+	} else {
+		d.add(SeverityInfo, check, "bundle contains %d CA certificates (%d valid)", len(certs), valid)
+	}
+	return bundle
+}
+
+// checkServingCert verifies the serving certificate against the CA bundle.
+func (d *doctor) checkServingCert(ctx context.Context, bundle []byte) {
+	const check = "serving-cert"
+
+	secret, err := d.client.CoreV1().Secrets(d.config.Namespace).Get(ctx, d.config.CertSecretName, metav1.GetOptions{})
+	if err != nil {
+		d.add(SeverityCritical, check, "cannot get secret %s/%s: %v", d.config.Namespace, d.config.CertSecretName, err)
+		return
+	}
+
+	certs, err := parseCertificates(secret.Data[d.config.CertDataKey])
+	if err != nil || len(certs) == 0 {
+		d.add(SeverityCritical, check, "secret %s/%s has no valid %s", d.config.Namespace, d.config.CertSecretName, d.config.CertDataKey)
+		return
+	}
+	leaf := certs[0]
+
+	remaining := leaf.NotAfter.Sub(d.now)
+	switch {
+	case remaining <= 0:
+		d.add(SeverityCritical, check, "serving certificate expired at %s", leaf.NotAfter.UTC().Format(time.RFC3339))
+	case remaining < expiryWarningThreshold:
+		d.add(SeverityWarning, check, "serving certificate expires in %s; rotation may be stuck", remaining.Round(time.Minute))
+	default:
+		d.add(SeverityInfo, check, "serving certificate valid until %s", leaf.NotAfter.UTC().Format(time.RFC3339))
+	}
+
+	if len(bundle) == 0 {
+		return
+	}
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(bundle)
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	dnsName := fmt.Sprintf("%s.%s.svc", d.config.ServiceName, d.config.Namespace)
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:       dnsName,
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   d.now,
+	}); err != nil {
+		d.add(SeverityCritical, check, "serving certificate does not verify against the CA bundle for %s: %v", dnsName, err)
+	}
+}
+
+// checkWebhookConfigurations compares the caBundle of each webhook entry with the CA bundle.
+func (d *doctor) checkWebhookConfigurations(ctx context.Context, bundle []byte) {
+	const check = "webhook-config"
+
+	found := false
+
+	mutating, err := d.client.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, d.config.Name, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+	case err != nil:
+		d.add(SeverityWarning, check, "cannot get MutatingWebhookConfiguration %s: %v", d.config.Name, err)
+	default:
+		found = true
+		for _, wh := range mutating.Webhooks {
+			d.compareCABundle(check, "MutatingWebhookConfiguration", wh.Name, wh.ClientConfig.CABundle, bundle)
+		}
+	}
+
+	validating, err := d.client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, d.config.Name, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+	case err != nil:
+		d.add(SeverityWarning, check, "cannot get ValidatingWebhookConfiguration %s: %v", d.config.Name, err)
+	default:
+		found = true
+		for _, wh := range validating.Webhooks {
+			d.compareCABundle(check, "ValidatingWebhookConfiguration", wh.Name, wh.ClientConfig.CABundle, bundle)
+		}
+	}
+
+	if !found {
+		d.add(SeverityCritical, check, "no MutatingWebhookConfiguration or ValidatingWebhookConfiguration named %s", d.config.Name)
+	}
+}
+
+// compareCABundle reports whether a webhook entry trusts the current CA bundle.
+func (d *doctor) compareCABundle(check, kind, webhook string, caBundle, bundle []byte) {
+	switch {
+	case len(caBundle) == 0:
+		d.add(SeverityCritical, check, "%s %s/%s has an empty caBundle", kind, d.config.Name, webhook)
+	case len(bundle) > 0 && !bytes.Equal(bytes.TrimSpace(caBundle), bytes.TrimSpace(bundle)):
+		d.add(SeverityCritical, check, "%s %s/%s caBundle differs from configmap %s", kind, d.config.Name, webhook, d.config.CABundleConfigMapName)
+	default:
+		d.add(SeverityInfo, check, "%s %s/%s caBundle is up to date", kind, d.config.Name, webhook)
+	}
+}
+
+// checkEndpoints reports the number of ready endpoints behind the service.
+func (d *doctor) checkEndpoints(ctx context.Context) {
+	const check = "endpoints"
+
+	if _, err := d.client.CoreV1().Services(d.config.Namespace).Get(ctx, d.config.ServiceName, metav1.GetOptions{}); err != nil {
+		d.add(SeverityCritical, check, "cannot get service %s/%s: %v", d.config.Namespace, d.config.ServiceName, err)
+		return
+	}
+
+	slices, err := d.client.DiscoveryV1().EndpointSlices(d.config.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: discoveryv1.LabelServiceName + "=" + d.config.ServiceName,
+	})
+	if err != nil {
+		d.add(SeverityWarning, check, "cannot list endpoint slices for service %s/%s: %v", d.config.Namespace, d.config.ServiceName, err)
+		return
+	}
+
+	ready, total := 0, 0
+	for _, slice := range slices.Items {
+		for _, ep := range slice.Endpoints {
+			total++
+			if ep.Conditions.Ready == nil || *ep.Conditions.Ready {
+				ready++
+			}
+		}
+	}
+
+	switch {
+	case ready == 0:
+		d.add(SeverityCritical, check, "service %s/%s has no ready endpoints (%d total)", d.config.Namespace, d.config.ServiceName, total)
+	case ready < total:
+		d.add(SeverityWarning, check, "service %s/%s has %d of %d endpoints ready", d.config.Namespace, d.config.ServiceName, ready, total)
+	default:
+		d.add(SeverityInfo, check, "service %s/%s has %d ready endpoints", d.config.Namespace, d.config.ServiceName, ready)
+	}
+}
+
+// checkLease reports the current leader and whether its lease is being renewed.
+func (d *doctor) checkLease(ctx context.Context) {
+	const check = "leader"
+
+	lease, err := d.client.CoordinationV1().Leases(d.config.Namespace).Get(ctx, d.config.LeaderElectionID, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			d.add(SeverityInfo, check, "lease %s/%s not found (leader election disabled?)", d.config.Namespace, d.config.LeaderElectionID)
+			return
+		}
+		d.add(SeverityWarning, check, "cannot get lease %s/%s: %v", d.config.Namespace, d.config.LeaderElectionID, err)
+		return
+	}
+
+	holder := ""
+	if lease.Spec.HolderIdentity != nil {
+		holder = *lease.Spec.HolderIdentity
+	}
+	if holder == "" {
+		d.add(SeverityWarning, check, "lease %s/%s has no holder; certificates are not being rotated", d.config.Namespace, d.config.LeaderElectionID)
+		return
+	}
+
+	if lease.Spec.RenewTime != nil && lease.Spec.LeaseDurationSeconds != nil {
+		expires := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+		if d.now.After(expires) {
+			d.add(SeverityWarning, check, "lease held by %s expired at %s; the leader may be stuck", holder, expires.UTC().Format(time.RFC3339))
+			return
+		}
+	}
+	d.add(SeverityInfo, check, "lease held by %s", holder)
+}
+
+// checkEvents reports recent warning events about the webhook's resources.
+func (d *doctor) checkEvents(ctx context.Context) {
+	const check = "events"
+
+	events, err := d.client.CoreV1().Events(d.config.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		d.add(SeverityWarning, check, "cannot list events in namespace %s: %v", d.config.Namespace, err)
+		return
+	}
+
+	var warnings []corev1.Event
+	for _, event := range events.Items {
+		if event.Type == corev1.EventTypeWarning && strings.HasPrefix(event.InvolvedObject.Name, d.config.Name) {
+			warnings = append(warnings, event)
+		}
+	}
+	sort.Slice(warnings, func(i, j int) bool {
+		return eventTime(warnings[i]).After(eventTime(warnings[j]))
+	})
+
+	for i, event := range warnings {
+		if i == maxEventFindings {
+			break
+		}
+		d.add(SeverityWarning, check, "%s %s/%s: %s: %s",
+			eventTime(event).UTC().Format(time.RFC3339), event.InvolvedObject.Kind, event.InvolvedObject.Name, event.Reason, event.Message)
+	}
+}
+
+// eventTime returns the most recent timestamp of an event.
+func eventTime(event corev1.Event) time.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	if !event.EventTime.IsZero() {
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// parseCertificates parses all PEM certificates in data.
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs, nil
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+}
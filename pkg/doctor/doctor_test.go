@@ -0,0 +1,235 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+)
+
+func TestSeverity_String(t *testing.T) {
+	tests := map[Severity]string{
+		SeverityCritical: "CRITICAL",
+		SeverityWarning:  "WARNING",
+		SeverityInfo:     "INFO",
+		Severity(42):     "UNKNOWN",
+	}
+	for s, want := range tests {
+		if got := s.String(); got != want {
+			t.Errorf("Severity(%d).String(): got %q, want %q", s, got, want)
+		}
+	}
+}
+
+func TestDiagnose_RequiresNameAndNamespace(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	if _, err := Diagnose(context.Background(), client, Config{Namespace: "ns"}); err == nil {
+		t.Error("Expected error without name")
+	}
+	if _, err := Diagnose(context.Background(), client, Config{Name: "wh"}); err == nil {
+		t.Error("Expected error without namespace")
+	}
+}
+
+func TestDiagnose_Healthy(t *testing.T) {
+	caPEM, certPEM := generateChain(t, "wh.ns.svc", time.Now().Add(24*time.Hour))
+	client := fake.NewSimpleClientset(healthyObjects(caPEM, certPEM)...)
+
+	report, err := Diagnose(context.Background(), client, Config{Name: "wh", Namespace: "ns"})
+	if err != nil {
+		t.Fatalf("Diagnose failed: %v", err)
+	}
+
+	if report.HasCritical() {
+		var buf bytes.Buffer
+		_ = report.Print(&buf)
+		t.Errorf("Expected no critical findings, got:\n%s", buf.String())
+	}
+}
+
+func TestDiagnose_Problems(t *testing.T) {
+	caPEM, certPEM := generateChain(t, "wh.ns.svc", time.Now().Add(time.Hour))
+	objects := healthyObjects(caPEM, certPEM)
+
+	// Stale caBundle in the webhook configuration
+	objects[3].(*admissionregistrationv1.ValidatingWebhookConfiguration).Webhooks[0].ClientConfig.CABundle = []byte("stale")
+	// No ready endpoints
+	objects[5].(*discoveryv1.EndpointSlice).Endpoints[0].Conditions.Ready = ptr.To(false)
+	// A recent warning event
+	objects = append(objects, &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "wh-cert.1", Namespace: "ns"},
+		InvolvedObject: corev1.ObjectReference{Kind: "Secret", Name: "wh-cert"},
+		Type:           corev1.EventTypeWarning,
+		Reason:         "RotationFailed",
+		Message:        "forbidden",
+		LastTimestamp:  metav1.Now(),
+	})
+
+	client := fake.NewSimpleClientset(objects...)
+
+	report, err := Diagnose(context.Background(), client, Config{Name: "wh", Namespace: "ns"})
+	if err != nil {
+		t.Fatalf("Diagnose failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := report.Print(&buf); err != nil {
+		t.Fatalf("Print failed: %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"caBundle differs from configmap",
+		"has no ready endpoints",
+		"serving certificate expires in",
+		"RotationFailed",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected report to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	// Findings are ordered by severity
+	for i := 1; i < len(report.Findings); i++ {
+		if report.Findings[i].Severity < report.Findings[i-1].Severity {
+			t.Fatalf("Findings not sorted by severity:\n%s", out)
+		}
+	}
+	if !report.HasCritical() {
+		t.Error("Expected critical findings")
+	}
+}
+
+func TestDiagnose_Missing(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	report, err := Diagnose(context.Background(), client, Config{Name: "wh", Namespace: "ns"})
+	if err != nil {
+		t.Fatalf("Diagnose failed: %v", err)
+	}
+
+	checks := make(map[string]bool)
+	for _, f := range report.Findings {
+		if f.Severity == SeverityCritical {
+			checks[f.Check] = true
+		}
+	}
+	for _, check := range []string{"ca-bundle", "serving-cert", "webhook-config", "endpoints"} {
+		if !checks[check] {
+			t.Errorf("Expected critical finding for %s", check)
+		}
+	}
+}
+
+// healthyObjects returns the objects of a healthy deployment named "wh" in namespace "ns".
+func healthyObjects(caPEM, certPEM []byte) []runtime.Object {
+	return []runtime.Object{
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "wh-ca-bundle", Namespace: "ns"},
+			Data:       map[string]string{"ca-bundle.crt": string(caPEM)},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "wh-cert", Namespace: "ns"},
+			Data:       map[string][]byte{corev1.TLSCertKey: certPEM},
+		},
+		&admissionregistrationv1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "wh"},
+			Webhooks: []admissionregistrationv1.MutatingWebhook{
+				{Name: "mutate.wh.ns.svc", ClientConfig: admissionregistrationv1.WebhookClientConfig{CABundle: caPEM}},
+			},
+		},
+		&admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "wh"},
+			Webhooks: []admissionregistrationv1.ValidatingWebhook{
+				{Name: "validate.wh.ns.svc", ClientConfig: admissionregistrationv1.WebhookClientConfig{CABundle: caPEM}},
+			},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "wh", Namespace: "ns"},
+		},
+		&discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "wh-abcde",
+				Namespace: "ns",
+				Labels:    map[string]string{discoveryv1.LabelServiceName: "wh"},
+			},
+			Endpoints: []discoveryv1.Endpoint{
+				{Addresses: []string{"10.0.0.1"}, Conditions: discoveryv1.EndpointConditions{Ready: ptr.To(true)}},
+			},
+		},
+		&coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: "wh-leader", Namespace: "ns"},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       ptr.To("wh-0"),
+				LeaseDurationSeconds: ptr.To(int32(30)),
+				RenewTime:            &metav1.MicroTime{Time: time.Now()},
+			},
+		},
+	}
+}
+
+// generateChain creates a CA and a serving certificate for dnsName signed by it.
+func generateChain(t *testing.T, dnsName string, notAfter time.Time) (caPEM, certPEM []byte) {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate CA key: %v", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(48 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatalf("Failed to parse CA: %v", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: dnsName},
+		DNSNames:     []string{dnsName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	caPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	return caPEM, certPEM
+}
@@ -0,0 +1,322 @@
+// Package operator reconciles AutoCertWebhook custom resources: a single
+// controller issues and rotates the certificates, publishes the CA bundle and
+// manages the webhook configurations of many webhooks, with the certmanager
+// and cabundle packages the library runs in each webhook as its engine.
+package operator
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+
+	"github.com/jimyag/auto-cert-webhook/internal/leaderelection"
+)
+
+// CRD is the CustomResourceDefinition of AutoCertWebhook, to apply before
+// running the operator.
+//
+//go:embed crd.yaml
+var CRD []byte
+
+const (
+	// defaultLeaderElectionID is the lease name if Config.LeaderElectionID
+	// is not set.
+	defaultLeaderElectionID = "auto-cert-webhook-operator"
+
+	// defaultSyncInterval is the sync interval if Config.SyncInterval is not
+	// set.
+	defaultSyncInterval = time.Minute
+
+	// notReadyRequeueDelay is the delay before refreshing the status of a
+	// webhook that is not ready.
+	notReadyRequeueDelay = 5 * time.Second
+)
+
+// Config holds the operator configuration.
+type Config struct {
+	// Namespace is the namespace of the leader election lease, typically the
+	// operator's own.
+	Namespace string
+
+	// WatchNamespace restricts the operator to the AutoCertWebhook objects
+	// of one namespace. Empty means all namespaces.
+	WatchNamespace string
+
+	// LeaderElection makes only one replica of the operator reconcile.
+	LeaderElection bool
+
+	// LeaderElectionID is the name of the lease. Defaults to
+	// "auto-cert-webhook-operator".
+	LeaderElectionID string
+
+	// SyncInterval is the certificate sync interval of every webhook and the
+	// interval at which their status is refreshed. Defaults to one minute.
+	SyncInterval time.Duration
+}
+
+// Operator reconciles AutoCertWebhook objects.
+type Operator struct {
+	client   kubernetes.Interface
+	dynamic  dynamic.Interface
+	config   Config
+	identity string
+
+	queue  workqueue.TypedRateLimitingInterface[string]
+	lister cache.GenericLister
+
+	// runners are the running webhooks by key. Only the worker accesses it.
+	runners map[string]*webhookRunner
+}
+
+// New creates an operator.
+func New(client kubernetes.Interface, dynamicClient dynamic.Interface, config Config) *Operator {
+	if config.LeaderElectionID == "" {
+		config.LeaderElectionID = defaultLeaderElectionID
+	}
+	if config.SyncInterval <= 0 {
+		config.SyncInterval = defaultSyncInterval
+	}
+	return &Operator{
+		client:   client,
+		dynamic:  dynamicClient,
+		config:   config,
+		identity: leaderelection.Identity(),
+		runners:  map[string]*webhookRunner{},
+	}
+}
+
+// Run reconciles AutoCertWebhook objects until ctx is cancelled, on the
+// leader only if LeaderElection is set. It returns an error when leadership is
+// lost, so that the process restarts and campaigns again.
+func (o *Operator) Run(ctx context.Context) error {
+	if !o.config.LeaderElection {
+		return o.run(ctx)
+	}
+
+	var runErr error
+	if err := leaderelection.Run(ctx, o.client, leaderelection.Config{
+		Namespace:     o.config.Namespace,
+		Name:          o.config.LeaderElectionID,
+		LeaseDuration: 30 * time.Second,
+		RenewDeadline: 10 * time.Second,
+		RetryPeriod:   5 * time.Second,
+		Identity:      o.identity,
+	}, leaderelection.Callbacks{
+		OnStartedLeading: func(ctx context.Context) {
+			runErr = o.run(ctx)
+		},
+	}); err != nil {
+		return err
+	}
+	if runErr != nil || ctx.Err() != nil {
+		return runErr
+	}
+	return errors.New("lost leadership")
+}
+
+// run watches AutoCertWebhook objects and reconciles them until ctx is
+// cancelled, then stops the webhooks. Their resources are kept.
+func (o *Operator) run(ctx context.Context) error {
+	o.queue = workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[string]())
+	defer o.queue.ShutDown()
+
+	// Resyncs refresh the status of every webhook
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(o.dynamic, o.config.SyncInterval, o.config.WatchNamespace, nil)
+	informer := factory.ForResource(GroupVersionResource)
+	enqueue := func(obj interface{}) {
+		key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+		if err != nil {
+			utilruntime.HandleError(err)
+			return
+		}
+		o.queue.Add(key)
+	}
+	if _, err := informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    enqueue,
+		UpdateFunc: func(_, newObj interface{}) { enqueue(newObj) },
+		DeleteFunc: enqueue,
+	}); err != nil {
+		return fmt.Errorf("failed to add event handler: %w", err)
+	}
+	o.lister = informer.Lister()
+
+	factory.Start(ctx.Done())
+	defer factory.Shutdown()
+	if !cache.WaitForCacheSync(ctx.Done(), informer.Informer().HasSynced) {
+		return fmt.Errorf("failed to sync AutoCertWebhook informer cache")
+	}
+	klog.Infof("Operator started watching %s", GroupVersionResource.GroupResource())
+
+	go func() {
+		<-ctx.Done()
+		o.queue.ShutDown()
+	}()
+	for o.processNextItem(ctx) {
+	}
+
+	for key, runner := range o.runners {
+		runner.stop()
+		delete(o.runners, key)
+	}
+	return nil
+}
+
+// processNextItem reconciles the next key of the queue. It returns false
+// once the queue is shut down.
+func (o *Operator) processNextItem(ctx context.Context) bool {
+	key, shutdown := o.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer o.queue.Done(key)
+
+	if err := o.reconcile(ctx, key); err != nil {
+		klog.Errorf("Failed to reconcile AutoCertWebhook %s, retrying: %v", key, err)
+		o.queue.AddRateLimited(key)
+		return true
+	}
+	o.queue.Forget(key)
+	return true
+}
+
+// reconcile brings the webhook of an AutoCertWebhook in line with its spec,
+// or cleans it up once the object is being deleted, and updates its status.
+func (o *Operator) reconcile(ctx context.Context, key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	obj, err := o.lister.ByNamespace(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		// Deleted without the finalizer, e.g. after the CRD was removed
+		o.stopRunner(key)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("unexpected object type %T", obj)
+	}
+	var acw AutoCertWebhook
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &acw); err != nil {
+		return fmt.Errorf("failed to decode AutoCertWebhook %s: %w", key, err)
+	}
+
+	if acw.DeletionTimestamp != nil {
+		return o.finalize(ctx, key, u, &acw)
+	}
+	if !slices.Contains(acw.Finalizers, Finalizer) {
+		// The update triggers the next reconcile
+		u = u.DeepCopy()
+		u.SetFinalizers(append(u.GetFinalizers(), Finalizer))
+		_, err := o.dynamic.Resource(GroupVersionResource).Namespace(namespace).Update(ctx, u, metav1.UpdateOptions{})
+		return err
+	}
+
+	cfg, err := newWebhookConfig(&acw, o.config.SyncInterval)
+	if err != nil {
+		// A running webhook keeps its certificates rotated until the spec
+		// is fixed
+		klog.Errorf("Invalid spec of AutoCertWebhook %s: %v", key, err)
+		return o.updateStatus(ctx, u, &acw, invalidConditions(err))
+	}
+
+	runner := o.runners[key]
+	if runner == nil || runner.generation != acw.Generation {
+		if runner != nil {
+			klog.Infof("Restarting webhook %s for generation %d", key, acw.Generation)
+			runner.stop()
+		} else {
+			klog.Infof("Starting webhook %s", key)
+		}
+		runner = startWebhook(ctx, o.client, cfg, acw.Generation, o.identity)
+		o.runners[key] = runner
+	}
+
+	conds := runner.conditions()
+	if meta.IsStatusConditionFalse(conds, ConditionReady) {
+		// Report readiness sooner than the next resync
+		o.queue.AddAfter(key, notReadyRequeueDelay)
+	}
+	return o.updateStatus(ctx, u, &acw, conds)
+}
+
+// finalize stops the webhook of an AutoCertWebhook being deleted, deletes its
+// resources and removes the finalizer.
+func (o *Operator) finalize(ctx context.Context, key string, u *unstructured.Unstructured, acw *AutoCertWebhook) error {
+	if !slices.Contains(acw.Finalizers, Finalizer) {
+		o.stopRunner(key)
+		return nil
+	}
+
+	// The resources are those of the running spec, if any
+	var cfg *webhookConfig
+	if runner := o.runners[key]; runner != nil {
+		cfg = runner.config
+	} else if c, err := newWebhookConfig(acw, o.config.SyncInterval); err == nil {
+		cfg = c
+	}
+	o.stopRunner(key)
+	if cfg != nil {
+		if err := cleanupWebhook(ctx, o.client, cfg); err != nil {
+			return err
+		}
+	}
+
+	u = u.DeepCopy()
+	u.SetFinalizers(slices.DeleteFunc(u.GetFinalizers(), func(f string) bool { return f == Finalizer }))
+	_, err := o.dynamic.Resource(GroupVersionResource).Namespace(u.GetNamespace()).Update(ctx, u, metav1.UpdateOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err == nil {
+		klog.Infof("Cleaned up webhook %s", key)
+	}
+	return err
+}
+
+// stopRunner stops the webhook of key, if running.
+func (o *Operator) stopRunner(key string) {
+	if runner := o.runners[key]; runner != nil {
+		klog.Infof("Stopping webhook %s", key)
+		runner.stop()
+		delete(o.runners, key)
+	}
+}
+
+// updateStatus writes conds to the status of an AutoCertWebhook if they
+// changed.
+func (o *Operator) updateStatus(ctx context.Context, u *unstructured.Unstructured, acw *AutoCertWebhook, conds []metav1.Condition) error {
+	// acw is decoded for this reconcile only
+	if !setConditions(&acw.Status, acw.Generation, conds) {
+		return nil
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&acw.Status)
+	if err != nil {
+		return err
+	}
+	u = u.DeepCopy()
+	if err := unstructured.SetNestedField(u.Object, content, "status"); err != nil {
+		return err
+	}
+	_, err = o.dynamic.Resource(GroupVersionResource).Namespace(u.GetNamespace()).UpdateStatus(ctx, u, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,174 @@
+package operator
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/yaml"
+)
+
+// testWebhook returns an AutoCertWebhook as unstructured.
+func testWebhook(t *testing.T, spec AutoCertWebhookSpec) *unstructured.Unstructured {
+	t.Helper()
+	acw := &AutoCertWebhook{
+		TypeMeta:   metav1.TypeMeta{APIVersion: GroupVersionResource.GroupVersion().String(), Kind: "AutoCertWebhook"},
+		ObjectMeta: metav1.ObjectMeta{Name: "wh", Namespace: "ns", Generation: 1},
+		Spec:       spec,
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(acw)
+	if err != nil {
+		t.Fatalf("Failed to convert AutoCertWebhook: %v", err)
+	}
+	return &unstructured.Unstructured{Object: content}
+}
+
+// waitFor polls condition until it holds or fails the test after 10s.
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestOperator(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{GroupVersionResource: "AutoCertWebhookList"},
+		testWebhook(t, AutoCertWebhookSpec{
+			ServiceName: "wh-svc",
+			Hooks: []HookSpec{{
+				Path:  "/validate-pods",
+				Type:  Validating,
+				Rules: []admissionregistrationv1.RuleWithOperations{{Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create}}},
+			}},
+		}),
+	)
+	resource := dynamicClient.Resource(GroupVersionResource).Namespace("ns")
+	op := New(client, dynamicClient, Config{SyncInterval: 100 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- op.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+	}()
+
+	get := func() *AutoCertWebhook {
+		t.Helper()
+		u, err := resource.Get(ctx, "wh", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get AutoCertWebhook: %v", err)
+		}
+		var acw AutoCertWebhook
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &acw); err != nil {
+			t.Fatalf("Failed to decode AutoCertWebhook: %v", err)
+		}
+		return &acw
+	}
+
+	waitFor(t, "the webhook to be ready", func() bool {
+		return meta.IsStatusConditionTrue(get().Status.Conditions, ConditionReady)
+	})
+	acw := get()
+	if !slices.Contains(acw.Finalizers, Finalizer) {
+		t.Errorf("Expected finalizer %s, got %v", Finalizer, acw.Finalizers)
+	}
+	if acw.Status.ObservedGeneration != 1 {
+		t.Errorf("Observed generation: got %d, want 1", acw.Status.ObservedGeneration)
+	}
+	for _, name := range []string{"wh-ca", "wh-cert"} {
+		if _, err := client.CoreV1().Secrets("ns").Get(ctx, name, metav1.GetOptions{}); err != nil {
+			t.Errorf("Expected secret %s: %v", name, err)
+		}
+	}
+	cfg, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, "ns-wh", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected webhook configuration ns-wh: %v", err)
+	}
+	if len(cfg.Webhooks) != 1 || cfg.Webhooks[0].Name != "validate-pods.wh.ns.svc" ||
+		cfg.Webhooks[0].ClientConfig.Service.Name != "wh-svc" || len(cfg.Webhooks[0].ClientConfig.CABundle) == 0 {
+		t.Errorf("Unexpected webhooks: %+v", cfg.Webhooks)
+	}
+
+	// Deleting the object cleans up its resources before the finalizer is removed
+	u, _ := resource.Get(ctx, "wh", metav1.GetOptions{})
+	u.SetDeletionTimestamp(&metav1.Time{Time: time.Now()})
+	if _, err := resource.Update(ctx, u, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Failed to mark AutoCertWebhook deleted: %v", err)
+	}
+	waitFor(t, "the finalizer to be removed", func() bool {
+		return !slices.Contains(get().Finalizers, Finalizer)
+	})
+	if _, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, "ns-wh", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the webhook configuration to be deleted, got %v", err)
+	}
+	for _, name := range []string{"wh-ca", "wh-cert"} {
+		if _, err := client.CoreV1().Secrets("ns").Get(ctx, name, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+			t.Errorf("Expected secret %s to be deleted, got %v", name, err)
+		}
+	}
+}
+
+func TestNewWebhookConfig_Invalid(t *testing.T) {
+	acw := &AutoCertWebhook{
+		ObjectMeta: metav1.ObjectMeta{Name: "wh", Namespace: "ns"},
+		Spec: AutoCertWebhookSpec{
+			CertRefresh: &metav1.Duration{Duration: 48 * time.Hour},
+			Hooks: []HookSpec{
+				{Path: "/validate", Type: Validating},
+				{Path: "/validate", Type: Validating},
+				{Path: "mutate", Type: Mutating},
+				{Path: "/convert", Type: "Converting"},
+				{Path: "/validate-pods", Type: Validating, ReinvocationPolicy: ptr.To(admissionregistrationv1.IfNeededReinvocationPolicy)},
+			},
+		},
+	}
+	_, err := newWebhookConfig(acw, time.Minute)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	for _, want := range []string{"certificate refresh", "duplicate", "must start with /", "type must be", "reinvocation policy"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in %v", want, err)
+		}
+	}
+}
+
+func TestCRD(t *testing.T) {
+	var crd struct {
+		Spec struct {
+			Group    string
+			Names    struct{ Plural string }
+			Versions []struct{ Name string }
+		}
+	}
+	if err := yaml.Unmarshal(CRD, &crd); err != nil {
+		t.Fatalf("Failed to parse CRD: %v", err)
+	}
+	if crd.Spec.Group != GroupVersionResource.Group || crd.Spec.Names.Plural != GroupVersionResource.Resource ||
+		len(crd.Spec.Versions) != 1 || crd.Spec.Versions[0].Name != GroupVersionResource.Version {
+		t.Errorf("CRD does not match %v: %+v", GroupVersionResource, crd.Spec)
+	}
+}
@@ -0,0 +1,130 @@
+package operator
+
+import (
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// GroupVersionResource is the resource of AutoCertWebhook objects.
+var GroupVersionResource = schema.GroupVersionResource{
+	Group:    "auto-cert-webhook.jimyag.io",
+	Version:  "v1alpha1",
+	Resource: "autocertwebhooks",
+}
+
+// Finalizer is added to AutoCertWebhook objects so that the operator deletes
+// their certificates and webhook configurations before they go away.
+const Finalizer = "auto-cert-webhook.jimyag.io/cleanup"
+
+// Condition types of an AutoCertWebhook.
+const (
+	// ConditionCertificatesReady is true when the CA and serving
+	// certificates exist, are valid and syncing them succeeds.
+	ConditionCertificatesReady = "CertificatesReady"
+	// ConditionBundleSynced is true when the CA bundle is injected into the
+	// webhook configurations.
+	ConditionBundleSynced = "BundleSynced"
+	// ConditionReady is true when both other conditions are.
+	ConditionReady = "Ready"
+)
+
+// HookType is the type of an admission hook.
+type HookType string
+
+const (
+	// Validating hooks get an entry in the ValidatingWebhookConfiguration.
+	Validating HookType = "Validating"
+	// Mutating hooks get an entry in the MutatingWebhookConfiguration.
+	Mutating HookType = "Mutating"
+)
+
+// AutoCertWebhook describes a webhook Service whose certificates, CA bundle
+// and webhook configurations the operator manages. It lives in the namespace
+// of the Service, where the certificate secrets and CA bundle are kept.
+type AutoCertWebhook struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AutoCertWebhookSpec   `json:"spec"`
+	Status AutoCertWebhookStatus `json:"status,omitempty"`
+}
+
+// AutoCertWebhookSpec is the desired state of an AutoCertWebhook.
+type AutoCertWebhookSpec struct {
+	// ServiceName is the name of the Service the API server calls. Defaults
+	// to the name of the AutoCertWebhook.
+	ServiceName string `json:"serviceName,omitempty"`
+
+	// ServicePort is the port of the Service. Defaults to 443.
+	ServicePort int32 `json:"servicePort,omitempty"`
+
+	// WebhookConfigurationName is the name of the Validating- and
+	// MutatingWebhookConfiguration holding the hooks. Defaults to
+	// <namespace>-<name>, as webhook configurations are cluster-scoped.
+	WebhookConfigurationName string `json:"webhookConfigurationName,omitempty"`
+
+	// CASecretName, CertSecretName and CABundleConfigMapName name the CA
+	// secret, serving certificate secret and CA bundle ConfigMap. They
+	// default to <name>-ca, <name>-cert and <name>-ca-bundle.
+	CASecretName          string `json:"caSecretName,omitempty"`
+	CertSecretName        string `json:"certSecretName,omitempty"`
+	CABundleConfigMapName string `json:"caBundleConfigMapName,omitempty"`
+
+	// CAValidity and CARefresh are the validity and refresh interval of the
+	// CA, 48h and 24h by default.
+	CAValidity *metav1.Duration `json:"caValidity,omitempty"`
+	CARefresh  *metav1.Duration `json:"caRefresh,omitempty"`
+
+	// CertValidity and CertRefresh are the validity and refresh interval of
+	// the serving certificate, 24h and 12h by default.
+	CertValidity *metav1.Duration `json:"certValidity,omitempty"`
+	CertRefresh  *metav1.Duration `json:"certRefresh,omitempty"`
+
+	// ExternalHostnames are DNS names and IP addresses added to the serving
+	// certificate, for callers outside the cluster.
+	ExternalHostnames []string `json:"externalHostnames,omitempty"`
+
+	// Hooks are the entries of the webhook configurations.
+	Hooks []HookSpec `json:"hooks"`
+}
+
+// HookSpec describes an entry of a webhook configuration.
+type HookSpec struct {
+	// Path is the URL path the API server calls.
+	Path string `json:"path"`
+
+	// Type is Validating or Mutating.
+	Type HookType `json:"type"`
+
+	// Rules describes which operations on which resources the hook handles.
+	Rules []admissionregistrationv1.RuleWithOperations `json:"rules"`
+
+	// FailurePolicy defaults to Fail.
+	FailurePolicy *admissionregistrationv1.FailurePolicyType `json:"failurePolicy,omitempty"`
+
+	// SideEffects defaults to None.
+	SideEffects *admissionregistrationv1.SideEffectClass `json:"sideEffects,omitempty"`
+
+	// TimeoutSeconds defaults to 10.
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+
+	// NamespaceSelector and ObjectSelector limit the hook to matching
+	// namespaces and objects.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	ObjectSelector    *metav1.LabelSelector `json:"objectSelector,omitempty"`
+
+	// ReinvocationPolicy is Never or IfNeeded, for Mutating hooks only.
+	// Defaults to Never.
+	ReinvocationPolicy *admissionregistrationv1.ReinvocationPolicyType `json:"reinvocationPolicy,omitempty"`
+}
+
+// AutoCertWebhookStatus is the observed state of an AutoCertWebhook.
+type AutoCertWebhookStatus struct {
+	// ObservedGeneration is the generation of the spec the operator runs.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions are the CertificatesReady, BundleSynced and Ready
+	// conditions.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
@@ -0,0 +1,294 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"github.com/jimyag/auto-cert-webhook/internal/cabundle"
+	"github.com/jimyag/auto-cert-webhook/pkg/certmanager"
+)
+
+// Defaults of an AutoCertWebhookSpec, matching the library's.
+const (
+	defaultServicePort  = 443
+	defaultCAValidity   = 48 * time.Hour
+	defaultCARefresh    = 24 * time.Hour
+	defaultCertValidity = 24 * time.Hour
+	defaultCertRefresh  = 12 * time.Hour
+)
+
+// restartDelay is the delay before restarting a failed certificate manager
+// or CA bundle syncer.
+const restartDelay = 5 * time.Second
+
+// webhookConfig is the configuration of the certificate manager and CA bundle
+// syncer of an AutoCertWebhook.
+type webhookConfig struct {
+	cert certmanager.Config
+	refs []cabundle.WebhookRef
+}
+
+// newWebhookConfig validates the spec of an AutoCertWebhook, applies its
+// defaults and returns the configuration of its certificate manager and CA
+// bundle syncer.
+func newWebhookConfig(acw *AutoCertWebhook, syncInterval time.Duration) (*webhookConfig, error) {
+	spec := acw.Spec
+	name, namespace := acw.Name, acw.Namespace
+	orDefault := func(value, def string) string {
+		if value == "" {
+			return def
+		}
+		return value
+	}
+	duration := func(d *metav1.Duration, def time.Duration) time.Duration {
+		if d == nil {
+			return def
+		}
+		return d.Duration
+	}
+
+	cfg := certmanager.Config{
+		Namespace:             namespace,
+		ServiceName:           orDefault(spec.ServiceName, name),
+		ExternalHostnames:     spec.ExternalHostnames,
+		CASecretName:          orDefault(spec.CASecretName, name+"-ca"),
+		CertSecretName:        orDefault(spec.CertSecretName, name+"-cert"),
+		CABundleConfigMapName: orDefault(spec.CABundleConfigMapName, name+"-ca-bundle"),
+		CAValidity:            duration(spec.CAValidity, defaultCAValidity),
+		CARefresh:             duration(spec.CARefresh, defaultCARefresh),
+		CertValidity:          duration(spec.CertValidity, defaultCertValidity),
+		CertRefresh:           duration(spec.CertRefresh, defaultCertRefresh),
+		SyncInterval:          syncInterval,
+	}
+
+	var errs []error
+	if msgs := validation.IsDNS1035Label(cfg.ServiceName); len(msgs) > 0 {
+		errs = append(errs, fmt.Errorf("invalid service name %q: %s", cfg.ServiceName, strings.Join(msgs, ", ")))
+	}
+	if cfg.CARefresh <= 0 || cfg.CARefresh >= cfg.CAValidity {
+		errs = append(errs, fmt.Errorf("CA refresh %v must be positive and less than the CA validity %v", cfg.CARefresh, cfg.CAValidity))
+	}
+	if cfg.CertRefresh <= 0 || cfg.CertRefresh >= cfg.CertValidity {
+		errs = append(errs, fmt.Errorf("certificate refresh %v must be positive and less than the certificate validity %v", cfg.CertRefresh, cfg.CertValidity))
+	}
+
+	configName := orDefault(spec.WebhookConfigurationName, namespace+"-"+name)
+	if msgs := validation.IsDNS1123Subdomain(configName); len(msgs) > 0 {
+		errs = append(errs, fmt.Errorf("invalid webhook configuration name %q: %s", configName, strings.Join(msgs, ", ")))
+	}
+	port := spec.ServicePort
+	if port == 0 {
+		port = defaultServicePort
+	}
+
+	entries := map[cabundle.WebhookType][]cabundle.WebhookEntry{}
+	seen := map[string]bool{}
+	for i, hook := range spec.Hooks {
+		var webhookType cabundle.WebhookType
+		switch hook.Type {
+		case Validating:
+			webhookType = cabundle.ValidatingWebhook
+		case Mutating:
+			webhookType = cabundle.MutatingWebhook
+		default:
+			errs = append(errs, fmt.Errorf("hooks[%d]: type must be %s or %s, got %q", i, Validating, Mutating, hook.Type))
+			continue
+		}
+		if hook.ReinvocationPolicy != nil && webhookType != cabundle.MutatingWebhook {
+			errs = append(errs, fmt.Errorf("hooks[%d]: reinvocation policy is only valid for %s hooks", i, Mutating))
+		}
+		if !strings.HasPrefix(hook.Path, "/") {
+			errs = append(errs, fmt.Errorf("hooks[%d]: path must start with /, got %q", i, hook.Path))
+			continue
+		}
+		entryName := cabundle.EntryName(hook.Path, name, namespace)
+		if seen[string(webhookType)+entryName] {
+			errs = append(errs, fmt.Errorf("hooks[%d]: duplicate %s hook path %q", i, hook.Type, hook.Path))
+			continue
+		}
+		seen[string(webhookType)+entryName] = true
+		entries[webhookType] = append(entries[webhookType], cabundle.WebhookEntry{
+			Name:               entryName,
+			ServiceName:        cfg.ServiceName,
+			ServiceNamespace:   namespace,
+			ServicePort:        port,
+			ServicePath:        hook.Path,
+			Rules:              hook.Rules,
+			FailurePolicy:      hook.FailurePolicy,
+			SideEffects:        hook.SideEffects,
+			TimeoutSeconds:     hook.TimeoutSeconds,
+			NamespaceSelector:  hook.NamespaceSelector,
+			ObjectSelector:     hook.ObjectSelector,
+			ReinvocationPolicy: hook.ReinvocationPolicy,
+		})
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	// Both configurations are managed, so that removing the last hook of a
+	// type empties its configuration
+	refs := []cabundle.WebhookRef{
+		{Name: configName, Type: cabundle.ValidatingWebhook, Entries: entries[cabundle.ValidatingWebhook]},
+		{Name: configName, Type: cabundle.MutatingWebhook, Entries: entries[cabundle.MutatingWebhook]},
+	}
+	for i := range refs {
+		if refs[i].Entries == nil {
+			refs[i].Entries = []cabundle.WebhookEntry{}
+		}
+	}
+	return &webhookConfig{cert: cfg, refs: refs}, nil
+}
+
+// webhookRunner runs the certificate manager and CA bundle syncer of an
+// AutoCertWebhook.
+type webhookRunner struct {
+	// generation is the generation of the spec the runner was started for.
+	generation int64
+
+	config  *webhookConfig
+	certMgr *certmanager.Manager
+	syncer  *cabundle.Syncer
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// startWebhook starts the engine of an AutoCertWebhook. Failed components
+// are restarted until ctx is cancelled or the runner is stopped.
+func startWebhook(ctx context.Context, client kubernetes.Interface, cfg *webhookConfig, generation int64, identity string) *webhookRunner {
+	ctx, cancel := context.WithCancel(ctx)
+	r := &webhookRunner{
+		generation: generation,
+		config:     cfg,
+		certMgr:    certmanager.New(client, cfg.cert),
+		syncer:     cabundle.NewSyncer(client, cfg.cert.Namespace, cfg.cert.CABundleConfigMapName, cfg.refs),
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+	r.syncer.SetIdentity(identity)
+
+	var wg sync.WaitGroup
+	run := func(name string, start func(context.Context) error) {
+		wg.Go(func() {
+			wait.UntilWithContext(ctx, func(ctx context.Context) {
+				if err := start(ctx); err != nil {
+					klog.Errorf("%s of %s/%s failed, restarting in %v: %v", name, cfg.cert.Namespace, cfg.cert.ServiceName, restartDelay, err)
+				}
+			}, restartDelay)
+		})
+	}
+	run("Certificate manager", r.certMgr.Start)
+	run("CA bundle syncer", r.syncer.Start)
+	go func() {
+		wg.Wait()
+		close(r.done)
+	}()
+	return r
+}
+
+// stop stops the runner and waits for its components to return.
+func (r *webhookRunner) stop() {
+	r.cancel()
+	<-r.done
+}
+
+// conditions returns the CertificatesReady, BundleSynced and Ready
+// conditions of the runner.
+func (r *webhookRunner) conditions() []metav1.Condition {
+	ready := metav1.Condition{Type: ConditionCertificatesReady, Status: metav1.ConditionTrue, Reason: "Issued"}
+	st, err := r.certMgr.Status()
+	switch {
+	case err != nil:
+		ready.Status, ready.Reason, ready.Message = metav1.ConditionFalse, "NotStarted", err.Error()
+	case st.CA == nil || st.ServingCertificate == nil:
+		ready.Status, ready.Reason, ready.Message = metav1.ConditionFalse, "NotIssued", "certificates not issued yet"
+	default:
+		if err := r.certMgr.Healthy(); err != nil {
+			ready.Status, ready.Reason, ready.Message = metav1.ConditionFalse, "SyncFailing", err.Error()
+		} else {
+			ready.Message = "serving certificate expires at " + st.ServingCertificate.NotAfter.UTC().Format(time.RFC3339)
+		}
+	}
+
+	synced := metav1.Condition{Type: ConditionBundleSynced, Status: metav1.ConditionTrue, Reason: "Injected",
+		Message: "the CA bundle is injected into the webhook configurations"}
+	switch injected, err := r.syncer.Status(); {
+	case err != nil:
+		synced.Status, synced.Reason, synced.Message = metav1.ConditionFalse, "InjectionFailed", err.Error()
+	case injected == "":
+		synced.Status, synced.Reason, synced.Message = metav1.ConditionFalse, "Pending", "the CA bundle is not injected yet"
+	}
+
+	overall := metav1.Condition{Type: ConditionReady, Status: metav1.ConditionTrue, Reason: "Ready", Message: "the webhook is ready"}
+	for _, cond := range []metav1.Condition{ready, synced} {
+		if cond.Status != metav1.ConditionTrue {
+			overall.Status, overall.Reason, overall.Message = metav1.ConditionFalse, cond.Reason, cond.Message
+			break
+		}
+	}
+	return []metav1.Condition{ready, synced, overall}
+}
+
+// invalidConditions returns the conditions of an AutoCertWebhook with an
+// invalid spec.
+func invalidConditions(err error) []metav1.Condition {
+	var conds []metav1.Condition
+	for _, t := range []string{ConditionCertificatesReady, ConditionBundleSynced, ConditionReady} {
+		conds = append(conds, metav1.Condition{Type: t, Status: metav1.ConditionFalse, Reason: "InvalidSpec", Message: err.Error()})
+	}
+	return conds
+}
+
+// setConditions sets conds on status, keeping the transition time of the
+// conditions whose status holds, and reports whether status changed.
+func setConditions(status *AutoCertWebhookStatus, generation int64, conds []metav1.Condition) bool {
+	changed := status.ObservedGeneration != generation
+	status.ObservedGeneration = generation
+	for _, cond := range conds {
+		cond.ObservedGeneration = generation
+		if meta.SetStatusCondition(&status.Conditions, cond) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// cleanupWebhook deletes the webhook configurations, CA bundle ConfigMap and
+// certificate secrets of an AutoCertWebhook. Resources that do not exist are
+// skipped.
+func cleanupWebhook(ctx context.Context, client kubernetes.Interface, cfg *webhookConfig) error {
+	if err := cabundle.NewSyncer(client, cfg.cert.Namespace, cfg.cert.CABundleConfigMapName, cfg.refs).Cleanup(ctx); err != nil {
+		return err
+	}
+
+	namespace := cfg.cert.Namespace
+	var errs []error
+	deleted := func(kind, name string, err error) {
+		switch {
+		case apierrors.IsNotFound(err):
+		case err != nil:
+			errs = append(errs, fmt.Errorf("failed to delete %s %s/%s: %w", kind, namespace, name, err))
+		default:
+			klog.Infof("Deleted %s %s/%s", kind, namespace, name)
+		}
+	}
+	name := cfg.cert.CABundleConfigMapName
+	deleted("ConfigMap", name, client.CoreV1().ConfigMaps(namespace).Delete(ctx, name, metav1.DeleteOptions{}))
+	for _, name := range []string{cfg.cert.CASecretName, cfg.cert.CertSecretName} {
+		deleted("Secret", name, client.CoreV1().Secrets(namespace).Delete(ctx, name, metav1.DeleteOptions{}))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,197 @@
+// Package policy provides reusable admission policies built on top of the
+// auto-cert-webhook framework.
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog/v2"
+
+	webhook "github.com/jimyag/auto-cert-webhook"
+)
+
+const (
+	// DefaultBreakGlassAnnotation is the annotation that allows deleting a protected namespace.
+	DefaultBreakGlassAnnotation = "auto-cert-webhook.jimyag.io/allow-delete"
+
+	// defaultLookupCacheTTL is the default duration protected resource lookups are cached.
+	defaultLookupCacheTTL = 30 * time.Second
+
+	// lookupTimeout bounds a single protected resource lookup.
+	lookupTimeout = 5 * time.Second
+)
+
+// NamespaceGuardConfig holds the namespace guard configuration.
+type NamespaceGuardConfig struct {
+	// ProtectedLabels protects namespaces carrying any of these labels.
+	// An empty value matches any value of the label.
+	ProtectedLabels map[string]string
+
+	// ProtectedResources protects namespaces that still contain at least one
+	// object of any of these resources. Requires a dynamic client.
+	ProtectedResources []schema.GroupVersionResource
+
+	// BreakGlassAnnotation allows deletion of a protected namespace when it is
+	// set to "true" on the namespace.
+	// If empty, defaults to DefaultBreakGlassAnnotation.
+	BreakGlassAnnotation string
+
+	// LookupCacheTTL is how long protected resources found in a namespace are
+	// cached. Namespaces without protected resources are looked up on every
+	// request, so that a resource created since is never missed.
+	// If zero, defaults to 30s.
+	LookupCacheTTL time.Duration
+}
+
+// NamespaceGuard is a validating policy that prevents deletion of protected namespaces.
+type NamespaceGuard struct {
+	config NamespaceGuardConfig
+	client dynamic.Interface
+
+	mu    sync.Mutex
+	cache map[string]lookupResult
+	now   func() time.Time
+}
+
+// lookupResult is a cached protected resource found in a namespace.
+type lookupResult struct {
+	resource string
+	expires  time.Time
+}
+
+// NewNamespaceGuard creates a new namespace guard.
+// The client may be nil if no ProtectedResources are configured.
+func NewNamespaceGuard(client dynamic.Interface, config NamespaceGuardConfig) *NamespaceGuard {
+	if config.BreakGlassAnnotation == "" {
+		config.BreakGlassAnnotation = DefaultBreakGlassAnnotation
+	}
+	if config.LookupCacheTTL <= 0 {
+		config.LookupCacheTTL = defaultLookupCacheTTL
+	}
+
+	return &NamespaceGuard{
+		config: config,
+		client: client,
+		cache:  make(map[string]lookupResult),
+		now:    time.Now,
+	}
+}
+
+// Hook returns a validating hook serving the guard at the given path.
+// The webhook configuration should match DELETE operations on namespaces.
+func (g *NamespaceGuard) Hook(path string) webhook.Hook {
+	return webhook.Hook{
+		Path:  path,
+		Type:  webhook.Validating,
+		Admit: g.Admit,
+	}
+}
+
+// Admit handles the admission request.
+func (g *NamespaceGuard) Admit(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+	req := ar.Request
+	if req == nil || req.Operation != admissionv1.Delete || req.Resource.Resource != "namespaces" {
+		return webhook.Allowed()
+	}
+
+	// On DELETE the namespace being removed is sent as OldObject.
+	ns := &corev1.Namespace{}
+	if len(req.OldObject.Raw) > 0 {
+		if err := json.Unmarshal(req.OldObject.Raw, ns); err != nil {
+			return webhook.Errored(fmt.Errorf("failed to decode namespace: %w", err))
+		}
+	}
+	if ns.Name == "" {
+		ns.Name = req.Name
+	}
+
+	if ns.Annotations[g.config.BreakGlassAnnotation] == "true" {
+		klog.Infof("Allowing deletion of namespace %s via break-glass annotation %s", ns.Name, g.config.BreakGlassAnnotation)
+		return webhook.AllowedWithWarnings(fmt.Sprintf("namespace %s is deleted using break-glass annotation %s", ns.Name, g.config.BreakGlassAnnotation))
+	}
+
+	for key, value := range g.config.ProtectedLabels {
+		actual, ok := ns.Labels[key]
+		if !ok || (value != "" && actual != value) {
+			continue
+		}
+		return webhook.Denied(fmt.Sprintf("namespace %s is protected by label %s=%s; set annotation %s=true to allow deletion",
+			ns.Name, key, actual, g.config.BreakGlassAnnotation))
+	}
+
+	resource, err := g.protectedResource(ns.Name)
+	if err != nil {
+		return webhook.Errored(fmt.Errorf("failed to look up protected resources in namespace %s: %w", ns.Name, err))
+	}
+	if resource != "" {
+		return webhook.Denied(fmt.Sprintf("namespace %s still contains %s; set annotation %s=true to allow deletion",
+			ns.Name, resource, g.config.BreakGlassAnnotation))
+	}
+
+	return webhook.Allowed()
+}
+
+// protectedResource returns the first protected resource found in the namespace,
+// or an empty string if there is none. Found resources are cached for
+// LookupCacheTTL; empty results are not cached.
+func (g *NamespaceGuard) protectedResource(namespace string) (string, error) {
+	if len(g.config.ProtectedResources) == 0 {
+		return "", nil
+	}
+	if g.client == nil {
+		return "", fmt.Errorf("no client configured for protected resources")
+	}
+
+	g.mu.Lock()
+	cached, ok := g.cache[namespace]
+	g.mu.Unlock()
+	if ok && g.now().Before(cached.expires) {
+		return cached.resource, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+
+	found := ""
+	for _, gvr := range g.config.ProtectedResources {
+		list, err := g.client.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{Limit: 1})
+		if err != nil {
+			return "", err
+		}
+		if len(list.Items) > 0 {
+			found = formatGVR(gvr)
+			break
+		}
+	}
+
+	if found == "" {
+		return "", nil
+	}
+
+	g.mu.Lock()
+	now := g.now()
+	for ns, result := range g.cache {
+		if !now.Before(result.expires) {
+			delete(g.cache, ns)
+		}
+	}
+	g.cache[namespace] = lookupResult{resource: found, expires: now.Add(g.config.LookupCacheTTL)}
+	g.mu.Unlock()
+
+	return found, nil
+}
+
+// formatGVR formats a resource as "resource.group", or "resource" for the core group.
+func formatGVR(gvr schema.GroupVersionResource) string {
+	return strings.TrimSuffix(gvr.Resource+"."+gvr.Group, ".")
+}
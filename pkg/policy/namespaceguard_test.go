@@ -0,0 +1,192 @@
+package policy
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+var configMapsGVR = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+func TestNewNamespaceGuard_Defaults(t *testing.T) {
+	guard := NewNamespaceGuard(nil, NamespaceGuardConfig{})
+
+	if guard.config.BreakGlassAnnotation != DefaultBreakGlassAnnotation {
+		t.Errorf("BreakGlassAnnotation: got %q, want %q", guard.config.BreakGlassAnnotation, DefaultBreakGlassAnnotation)
+	}
+	if guard.config.LookupCacheTTL != defaultLookupCacheTTL {
+		t.Errorf("LookupCacheTTL: got %v, want %v", guard.config.LookupCacheTTL, defaultLookupCacheTTL)
+	}
+}
+
+func TestNamespaceGuard_Hook(t *testing.T) {
+	guard := NewNamespaceGuard(nil, NamespaceGuardConfig{})
+	hook := guard.Hook("/validate-namespaces")
+
+	if hook.Path != "/validate-namespaces" {
+		t.Errorf("Path: got %q, want %q", hook.Path, "/validate-namespaces")
+	}
+	if hook.Type != "Validating" {
+		t.Errorf("Type: got %q, want %q", hook.Type, "Validating")
+	}
+	if hook.Admit == nil {
+		t.Error("Expected non-nil Admit")
+	}
+}
+
+func TestNamespaceGuard_Admit_Labels(t *testing.T) {
+	guard := NewNamespaceGuard(nil, NamespaceGuardConfig{
+		ProtectedLabels: map[string]string{
+			"protected": "",
+			"tier":      "platform",
+		},
+	})
+
+	tests := []struct {
+		name        string
+		operation   admissionv1.Operation
+		labels      map[string]string
+		annotations map[string]string
+		allowed     bool
+	}{
+		{name: "unprotected namespace", operation: admissionv1.Delete, allowed: true},
+		{name: "label with any value", operation: admissionv1.Delete, labels: map[string]string{"protected": "yes"}, allowed: false},
+		{name: "label with matching value", operation: admissionv1.Delete, labels: map[string]string{"tier": "platform"}, allowed: false},
+		{name: "label with other value", operation: admissionv1.Delete, labels: map[string]string{"tier": "apps"}, allowed: true},
+		{
+			name:        "break-glass annotation",
+			operation:   admissionv1.Delete,
+			labels:      map[string]string{"protected": "yes"},
+			annotations: map[string]string{DefaultBreakGlassAnnotation: "true"},
+			allowed:     true,
+		},
+		{name: "non-delete operation", operation: admissionv1.Update, labels: map[string]string{"protected": "yes"}, allowed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ar := namespaceReview(t, tt.operation, "team-a", tt.labels, tt.annotations)
+			resp := guard.Admit(ar)
+			if resp.Allowed != tt.allowed {
+				t.Errorf("Allowed: got %v, want %v", resp.Allowed, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestNamespaceGuard_Admit_Resources(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed to add core scheme: %v", err)
+	}
+	client := dynamicfake.NewSimpleDynamicClient(scheme, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "keep-me", Namespace: "team-a"},
+	})
+
+	guard := NewNamespaceGuard(client, NamespaceGuardConfig{
+		ProtectedResources: []schema.GroupVersionResource{configMapsGVR},
+	})
+
+	t.Run("namespace with protected resources", func(t *testing.T) {
+		resp := guard.Admit(namespaceReview(t, admissionv1.Delete, "team-a", nil, nil))
+		if resp.Allowed {
+			t.Error("Expected deletion to be denied")
+		}
+	})
+
+	t.Run("namespace without protected resources", func(t *testing.T) {
+		resp := guard.Admit(namespaceReview(t, admissionv1.Delete, "team-b", nil, nil))
+		if !resp.Allowed {
+			t.Errorf("Expected deletion to be allowed, got %v", resp.Result)
+		}
+	})
+
+	t.Run("lookups are cached", func(t *testing.T) {
+		client.ClearActions()
+		guard.Admit(namespaceReview(t, admissionv1.Delete, "team-a", nil, nil))
+		if len(client.Actions()) != 0 {
+			t.Errorf("Expected cached lookup, got %d actions", len(client.Actions()))
+		}
+
+		guard.now = func() time.Time { return time.Now().Add(time.Hour) }
+		guard.Admit(namespaceReview(t, admissionv1.Delete, "team-a", nil, nil))
+		if len(client.Actions()) == 0 {
+			t.Error("Expected expired cache entry to trigger a lookup")
+		}
+	})
+
+	t.Run("empty lookups are not cached", func(t *testing.T) {
+		client.ClearActions()
+		guard.Admit(namespaceReview(t, admissionv1.Delete, "team-b", nil, nil))
+		if len(client.Actions()) == 0 {
+			t.Error("Expected a lookup for a namespace without protected resources")
+		}
+		if _, ok := guard.cache["team-b"]; ok {
+			t.Error("Expected no cache entry for a namespace without protected resources")
+		}
+	})
+
+	t.Run("expired entries are pruned", func(t *testing.T) {
+		guard.now = time.Now
+		guard.cache = map[string]lookupResult{"team-c": {resource: "configmaps", expires: time.Now()}}
+		guard.Admit(namespaceReview(t, admissionv1.Delete, "team-a", nil, nil))
+		if _, ok := guard.cache["team-c"]; ok {
+			t.Error("Expected the expired cache entry to be pruned")
+		}
+	})
+}
+
+func TestNamespaceGuard_Admit_NoClient(t *testing.T) {
+	guard := NewNamespaceGuard(nil, NamespaceGuardConfig{
+		ProtectedResources: []schema.GroupVersionResource{configMapsGVR},
+	})
+
+	resp := guard.Admit(namespaceReview(t, admissionv1.Delete, "team-a", nil, nil))
+	if resp.Allowed {
+		t.Error("Expected lookup without client to fail")
+	}
+}
+
+func TestFormatGVR(t *testing.T) {
+	if got := formatGVR(configMapsGVR); got != "configmaps" {
+		t.Errorf("Core group: got %q, want %q", got, "configmaps")
+	}
+	gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	if got := formatGVR(gvr); got != "deployments.apps" {
+		t.Errorf("Named group: got %q, want %q", got, "deployments.apps")
+	}
+}
+
+// namespaceReview builds an AdmissionReview for a namespace operation.
+func namespaceReview(t *testing.T, op admissionv1.Operation, name string, labels, annotations map[string]string) admissionv1.AdmissionReview {
+	t.Helper()
+
+	ns := corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+	}
+	raw, err := json.Marshal(ns)
+	if err != nil {
+		t.Fatalf("Failed to marshal namespace: %v", err)
+	}
+
+	return admissionv1.AdmissionReview{
+		Request: &admissionv1.AdmissionRequest{
+			UID:       "test-uid",
+			Name:      name,
+			Operation: op,
+			Resource:  metav1.GroupVersionResource{Version: "v1", Resource: "namespaces"},
+			OldObject: runtime.RawExtension{Raw: raw},
+		},
+	}
+}
@@ -0,0 +1,148 @@
+package autocertwebhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/appscode/jsonpatch"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PodTemplateMutateFunc mutates a pod template in place.
+type PodTemplateMutateFunc func(template *corev1.PodTemplateSpec) error
+
+// podTemplatePath returns the path of the pod template inside an object of the
+// given kind. For Pods the path is empty: the pod's own metadata and spec form
+// the template.
+func podTemplatePath(gvk metav1.GroupVersionKind) ([]string, bool) {
+	switch {
+	case gvk.Group == "" && gvk.Kind == "Pod":
+		return nil, true
+	case gvk.Group == "" && gvk.Kind == "PodTemplate":
+		return []string{"template"}, true
+	case gvk.Group == "" && gvk.Kind == "ReplicationController":
+		return []string{"spec", "template"}, true
+	case gvk.Group == "apps" && (gvk.Kind == "Deployment" || gvk.Kind == "StatefulSet" ||
+		gvk.Kind == "DaemonSet" || gvk.Kind == "ReplicaSet"):
+		return []string{"spec", "template"}, true
+	case gvk.Group == "batch" && gvk.Kind == "Job":
+		return []string{"spec", "template"}, true
+	case gvk.Group == "batch" && gvk.Kind == "CronJob":
+		return []string{"spec", "jobTemplate", "spec", "template"}, true
+	default:
+		return nil, false
+	}
+}
+
+// HasPodTemplate returns true if the request object is a Pod or a workload
+// that embeds a pod template (Deployment, StatefulSet, DaemonSet, ReplicaSet,
+// ReplicationController, PodTemplate, Job or CronJob).
+func HasPodTemplate(ar admissionv1.AdmissionReview) bool {
+	if ar.Request == nil {
+		return false
+	}
+	_, ok := podTemplatePath(ar.Request.Kind)
+	return ok
+}
+
+// DecodePodTemplate decodes the pod template embedded in the request object, so
+// pod policies can be applied to workloads as well as to Pods. For a Pod the
+// template holds the pod's metadata and spec. On DELETE requests, where the
+// object is empty, it decodes the old object.
+func DecodePodTemplate(ar admissionv1.AdmissionReview) (*corev1.PodTemplateSpec, error) {
+	template, _, err := decodePodTemplate(ar)
+	return template, err
+}
+
+// MutatePodTemplate decodes the pod template embedded in the request object,
+// calls mutate on it and returns a patch response whose operations are rooted
+// at the template's location in the object, e.g. /spec/template for a
+// Deployment or /spec/jobTemplate/spec/template for a CronJob.
+func MutatePodTemplate(ar admissionv1.AdmissionReview, mutate PodTemplateMutateFunc) *admissionv1.AdmissionResponse {
+	template, path, err := decodePodTemplate(ar)
+	if err != nil {
+		return ErroredWithCode(err, http.StatusBadRequest)
+	}
+
+	original, err := json.Marshal(template)
+	if err != nil {
+		return Errored(fmt.Errorf("failed to marshal pod template: %w", err))
+	}
+	if err := mutate(template); err != nil {
+		return Errored(err)
+	}
+	modified, err := json.Marshal(template)
+	if err != nil {
+		return Errored(fmt.Errorf("failed to marshal pod template: %w", err))
+	}
+
+	patches, err := jsonpatch.CreatePatch(original, modified)
+	if err != nil {
+		return Errored(fmt.Errorf("failed to create patch: %w", err))
+	}
+
+	prefix := pointer(path)
+	for i := range patches {
+		patches[i].Path = prefix + patches[i].Path
+	}
+	return PatchResponseFromPatches(patches)
+}
+
+// decodePodTemplate decodes the pod template of the request object and returns it with its path.
+func decodePodTemplate(ar admissionv1.AdmissionReview) (*corev1.PodTemplateSpec, []string, error) {
+	raw, path, err := podTemplateRaw(ar)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &corev1.PodTemplateSpec{}
+	if err := json.Unmarshal(raw, template); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode pod template at %s: %w", pointer(path), err)
+	}
+	return template, path, nil
+}
+
+// podTemplateRaw returns the raw pod template of the request object and its path.
+func podTemplateRaw(ar admissionv1.AdmissionReview) ([]byte, []string, error) {
+	if ar.Request == nil {
+		return nil, nil, fmt.Errorf("admission review contains no request")
+	}
+	path, ok := podTemplatePath(ar.Request.Kind)
+	if !ok {
+		return nil, nil, fmt.Errorf("kind %s does not contain a pod template", ar.Request.Kind.String())
+	}
+
+	raw := ar.Request.Object.Raw
+	if len(raw) == 0 {
+		raw = ar.Request.OldObject.Raw
+	}
+	if len(raw) == 0 {
+		return nil, nil, fmt.Errorf("request contains no object")
+	}
+
+	for _, key := range path {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode object: %w", err)
+		}
+		next, ok := fields[key]
+		if !ok || string(next) == "null" {
+			return nil, nil, fmt.Errorf("%s has no pod template at %s", ar.Request.Kind.Kind, pointer(path))
+		}
+		raw = next
+	}
+	return raw, path, nil
+}
+
+// pointer returns the JSON pointer for the given path. Path keys never
+// contain characters that need escaping.
+func pointer(path []string) string {
+	p := ""
+	for _, key := range path {
+		p += "/" + key
+	}
+	return p
+}
@@ -0,0 +1,179 @@
+package autocertwebhook
+
+import (
+	"encoding/json"
+	"testing"
+
+	jsonpatch "gopkg.in/evanphx/json-patch.v4"
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func podTemplateReview(t *testing.T, gvk metav1.GroupVersionKind, obj interface{}) admissionv1.AdmissionReview {
+	t.Helper()
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatalf("Failed to marshal object: %v", err)
+	}
+	return admissionv1.AdmissionReview{
+		Request: &admissionv1.AdmissionRequest{
+			Kind:      gvk,
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+		},
+	}
+}
+
+func testPodSpec() corev1.PodSpec {
+	return corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "nginx"}}}
+}
+
+func TestMutatePodTemplate(t *testing.T) {
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web"}},
+		Spec:       testPodSpec(),
+	}
+
+	tests := []struct {
+		name       string
+		gvk        metav1.GroupVersionKind
+		obj        interface{}
+		wantPrefix string
+		template   func(raw []byte) (*corev1.PodTemplateSpec, error)
+	}{
+		{
+			name:       "deployment",
+			gvk:        metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+			obj:        &appsv1.Deployment{Spec: appsv1.DeploymentSpec{Template: template}},
+			wantPrefix: "/spec/template/",
+			template: func(raw []byte) (*corev1.PodTemplateSpec, error) {
+				obj := &appsv1.Deployment{}
+				err := json.Unmarshal(raw, obj)
+				return &obj.Spec.Template, err
+			},
+		},
+		{
+			name: "cronjob",
+			gvk:  metav1.GroupVersionKind{Group: "batch", Version: "v1", Kind: "CronJob"},
+			obj: &batchv1.CronJob{Spec: batchv1.CronJobSpec{
+				JobTemplate: batchv1.JobTemplateSpec{Spec: batchv1.JobSpec{Template: template}},
+			}},
+			wantPrefix: "/spec/jobTemplate/spec/template/",
+			template: func(raw []byte) (*corev1.PodTemplateSpec, error) {
+				obj := &batchv1.CronJob{}
+				err := json.Unmarshal(raw, obj)
+				return &obj.Spec.JobTemplate.Spec.Template, err
+			},
+		},
+		{
+			name:       "pod",
+			gvk:        metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			obj:        &corev1.Pod{ObjectMeta: template.ObjectMeta, Spec: template.Spec},
+			wantPrefix: "/",
+			template: func(raw []byte) (*corev1.PodTemplateSpec, error) {
+				obj := &corev1.Pod{}
+				err := json.Unmarshal(raw, obj)
+				return &corev1.PodTemplateSpec{ObjectMeta: obj.ObjectMeta, Spec: obj.Spec}, err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ar := podTemplateReview(t, tt.gvk, tt.obj)
+
+			if !HasPodTemplate(ar) {
+				t.Fatal("HasPodTemplate() should return true")
+			}
+
+			resp := MutatePodTemplate(ar, func(template *corev1.PodTemplateSpec) error {
+				template.Labels["injected"] = "true"
+				template.Spec.Containers[0].Image = "nginx:1.27"
+				return nil
+			})
+			if !resp.Allowed || resp.Patch == nil {
+				t.Fatalf("Expected allowed patch response, got %+v", resp)
+			}
+
+			var ops []map[string]interface{}
+			if err := json.Unmarshal(resp.Patch, &ops); err != nil {
+				t.Fatalf("Failed to unmarshal patch: %v", err)
+			}
+			for _, op := range ops {
+				if path, _ := op["path"].(string); len(path) < len(tt.wantPrefix) || path[:len(tt.wantPrefix)] != tt.wantPrefix {
+					t.Errorf("Patch path %q does not start with %q", path, tt.wantPrefix)
+				}
+			}
+
+			patch, err := jsonpatch.DecodePatch(resp.Patch)
+			if err != nil {
+				t.Fatalf("Failed to decode patch: %v", err)
+			}
+			patched, err := patch.Apply(ar.Request.Object.Raw)
+			if err != nil {
+				t.Fatalf("Failed to apply patch: %v", err)
+			}
+			got, err := tt.template(patched)
+			if err != nil {
+				t.Fatalf("Failed to decode patched object: %v", err)
+			}
+			if got.Labels["injected"] != "true" {
+				t.Errorf("Labels: got %v, want injected=true", got.Labels)
+			}
+			if got.Spec.Containers[0].Image != "nginx:1.27" {
+				t.Errorf("Image: got %q, want %q", got.Spec.Containers[0].Image, "nginx:1.27")
+			}
+		})
+	}
+}
+
+func TestMutatePodTemplate_NoChanges(t *testing.T) {
+	ar := podTemplateReview(t, metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "StatefulSet"},
+		&appsv1.StatefulSet{Spec: appsv1.StatefulSetSpec{Template: corev1.PodTemplateSpec{Spec: testPodSpec()}}})
+
+	resp := MutatePodTemplate(ar, func(*corev1.PodTemplateSpec) error { return nil })
+	if !resp.Allowed || resp.Patch != nil {
+		t.Errorf("Expected allowed response without patch, got %+v", resp)
+	}
+}
+
+func TestDecodePodTemplate(t *testing.T) {
+	ar := podTemplateReview(t, metav1.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"},
+		&batchv1.Job{Spec: batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: testPodSpec()}}})
+
+	template, err := DecodePodTemplate(ar)
+	if err != nil {
+		t.Fatalf("DecodePodTemplate failed: %v", err)
+	}
+	if len(template.Spec.Containers) != 1 || template.Spec.Containers[0].Image != "nginx" {
+		t.Errorf("Containers: got %+v", template.Spec.Containers)
+	}
+
+	t.Run("unsupported kind", func(t *testing.T) {
+		ar := podTemplateReview(t, metav1.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, &corev1.ConfigMap{})
+		if HasPodTemplate(ar) {
+			t.Error("HasPodTemplate() should return false for ConfigMap")
+		}
+		if _, err := DecodePodTemplate(ar); err == nil {
+			t.Error("Expected error for ConfigMap")
+		}
+		resp := MutatePodTemplate(ar, func(*corev1.PodTemplateSpec) error { return nil })
+		if resp.Allowed || resp.Result.Code != 400 {
+			t.Errorf("Expected 400 error response, got %+v", resp)
+		}
+	})
+
+	t.Run("missing template", func(t *testing.T) {
+		ar := admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{
+			Kind:   metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "DaemonSet"},
+			Object: runtime.RawExtension{Raw: []byte(`{"spec":{}}`)},
+		}}
+		if _, err := DecodePodTemplate(ar); err == nil {
+			t.Error("Expected error for missing template")
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package autocertwebhook
+
+import (
+	"flag"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// profile is a preset of Config values selected by Config.Profile.
+type profile struct {
+	// values holds the preset values by Config field name.
+	values map[string]any
+	// verbosity is the minimum klog verbosity of the profile.
+	verbosity int
+}
+
+// profiles are the presets available through Config.Profile.
+var profiles = map[string]profile{
+	// dev rotates certificates quickly so that rotation problems surface
+	// early, logs verbosely and runs a single replica.
+	"dev": {
+		values: map[string]any{
+			"CAValidity":     2 * time.Hour,
+			"CARefresh":      time.Hour,
+			"CertValidity":   time.Hour,
+			"CertRefresh":    30 * time.Minute,
+			"LeaderElection": false,
+		},
+		verbosity: 4,
+	},
+	// prod keeps certificates long-lived and limits the load the webhook
+	// accepts and puts on the API server.
+	"prod": {
+		values: map[string]any{
+			"CAValidity":          365 * 24 * time.Hour,
+			"CARefresh":           180 * 24 * time.Hour,
+			"CertValidity":        30 * 24 * time.Hour,
+			"CertRefresh":         15 * 24 * time.Hour,
+			"MaxInFlightRequests": 200,
+			"APIWriteQPS":         float32(2),
+			"APIWriteBurst":       5,
+		},
+	},
+}
+
+// profileNames returns the names of the profiles, sorted.
+func profileNames() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, strconv.Quote(name))
+	}
+	slices.Sort(names)
+	return names
+}
+
+// applyProfile sets the values of the profile selected by cfg.Profile on the
+// fields that are left to their defaults, i.e. not set in code, environment
+// variables or flags.
+func applyProfile(cfg *Config, sources configSources) error {
+	if cfg.Profile == "" {
+		return nil
+	}
+	p, ok := profiles[cfg.Profile]
+	if !ok {
+		return fieldErrorf("Profile", "profile must be one of %s, got %q", strings.Join(profileNames(), ", "), cfg.Profile)
+	}
+	target := reflect.ValueOf(cfg).Elem()
+	for field, value := range p.values {
+		if sources.source(field) != "default" {
+			continue
+		}
+		v := reflect.ValueOf(value)
+		if f := target.FieldByName(field); f.Kind() == reflect.Ptr {
+			ptr := reflect.New(v.Type())
+			ptr.Elem().Set(v)
+			f.Set(ptr)
+		} else {
+			f.Set(v)
+		}
+	}
+	return nil
+}
+
+// raiseVerbosity raises the klog verbosity to level unless it is already
+// higher, e.g. set with -v.
+func raiseVerbosity(level int) {
+	if klog.V(klog.Level(level)).Enabled() {
+		return
+	}
+	fs := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(fs)
+	if err := fs.Set("v", strconv.Itoa(level)); err != nil {
+		klog.Warningf("Failed to raise log verbosity to %d: %v", level, err)
+	}
+}
@@ -0,0 +1,84 @@
+package autocertwebhook
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func TestApplyProfile(t *testing.T) {
+	t.Setenv("ACW_PROFILE", "dev")
+	t.Setenv("ACW_CERT_VALIDITY", "3h")
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags := NewConfigFlags()
+	flags.AddFlags(fs)
+	if err := fs.Parse([]string{"--cert-refresh=2h"}); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	cfg := Config{Name: "wh", CAValidity: 4 * time.Hour, Flags: flags}
+	sources := newConfigSources(deepCopyConfig(&cfg), flags)
+	if err := applyEnvConfig(&cfg); err != nil {
+		t.Fatalf("applyEnvConfig() error = %v", err)
+	}
+	cfg.Flags.Apply(&cfg)
+	if err := applyProfile(&cfg, sources); err != nil {
+		t.Fatalf("applyProfile() error = %v", err)
+	}
+
+	// Code, environment variables and flags take priority over the profile
+	if cfg.CAValidity != 4*time.Hour {
+		t.Errorf("CAValidity: got %v, want the code value 4h", cfg.CAValidity)
+	}
+	if cfg.CertValidity != 3*time.Hour {
+		t.Errorf("CertValidity: got %v, want the env value 3h", cfg.CertValidity)
+	}
+	if cfg.CertRefresh != 2*time.Hour {
+		t.Errorf("CertRefresh: got %v, want the flag value 2h", cfg.CertRefresh)
+	}
+	if cfg.CARefresh != time.Hour {
+		t.Errorf("CARefresh: got %v, want the profile value 1h", cfg.CARefresh)
+	}
+	if cfg.LeaderElection == nil || *cfg.LeaderElection {
+		t.Errorf("LeaderElection: got %v, want false", cfg.LeaderElection)
+	}
+	// Fields the profile does not set keep their defaults
+	if cfg.APIWriteQPS != 5 {
+		t.Errorf("APIWriteQPS: got %v, want the default 5", cfg.APIWriteQPS)
+	}
+
+	sources.profile = cfg.Profile
+	if got := sources.source("CARefresh"); got != "profile dev" {
+		t.Errorf("source(CARefresh) = %q, want profile dev", got)
+	}
+}
+
+func TestApplyProfile_Unknown(t *testing.T) {
+	cfg := Config{Profile: "staging"}
+	err := applyProfile(&cfg, newConfigSources(&cfg, nil))
+	if err == nil || !strings.Contains(err.Error(), `profile must be one of "dev", "prod", got "staging"`) {
+		t.Errorf("applyProfile() error = %v", err)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	for name, p := range profiles {
+		for field := range p.values {
+			if _, ok := reflect.TypeOf(Config{}).FieldByName(field); !ok {
+				t.Fatalf("%s: unknown field %s", name, field)
+			}
+		}
+		// The certificate durations of every profile are consistent
+		cfg := Config{Profile: name}
+		if err := applyProfile(&cfg, newConfigSources(&Config{}, nil)); err != nil {
+			t.Fatalf("%s: applyProfile() error = %v", name, err)
+		}
+		if err := validateCertDurations(&cfg); err != nil {
+			t.Errorf("%s: invalid certificate durations: %v", name, err)
+		}
+	}
+}
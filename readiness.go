@@ -0,0 +1,156 @@
+package autocertwebhook
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"github.com/jimyag/auto-cert-webhook/internal/cabundle"
+)
+
+// webhookReadinessInterval caps the interval between checks of the webhook
+// configurations.
+const webhookReadinessInterval = 10 * time.Second
+
+// webhookReadiness checks that the webhook configurations exist and,
+// optionally, that every entry trusts the pod's serving certificate, so that
+// the pod does not report ready while the API server would not call it or
+// would reject its certificate. Every pod runs one.
+type webhookReadiness struct {
+	client kubernetes.Interface
+	refs   []cabundle.WebhookRef
+	// getCert returns the serving certificate to verify with the caBundle of
+	// each entry. If nil, only the existence of the configurations is checked.
+	getCert  func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+	interval time.Duration
+
+	mu  sync.Mutex
+	err error
+}
+
+// newWebhookReadiness creates the readiness check of the given webhook
+// configurations.
+func newWebhookReadiness(client kubernetes.Interface, refs []cabundle.WebhookRef, getCert func(*tls.ClientHelloInfo) (*tls.Certificate, error), interval time.Duration) *webhookReadiness {
+	if interval <= 0 || interval > webhookReadinessInterval {
+		interval = webhookReadinessInterval
+	}
+	return &webhookReadiness{
+		client:   client,
+		refs:     refs,
+		getCert:  getCert,
+		interval: interval,
+		err:      errors.New("not checked yet"),
+	}
+}
+
+// Run checks the webhook configurations every interval until ctx is cancelled.
+func (c *webhookReadiness) Run(ctx context.Context) error {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		err := c.check(ctx)
+		c.mu.Lock()
+		if err == nil && c.err != nil {
+			klog.Info("Webhook configurations are ready")
+		}
+		c.err = err
+		c.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Ready returns the result of the last check.
+func (c *webhookReadiness) Ready() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// check gets the webhook configurations and verifies the serving certificate
+// with the caBundle of each of their entries.
+func (c *webhookReadiness) check(ctx context.Context) error {
+	var verify func(name string, caBundle []byte) error
+	if c.getCert != nil {
+		var err error
+		if verify, err = c.verifier(); err != nil {
+			return err
+		}
+	}
+
+	for _, ref := range c.refs {
+		configs, err := webhookClientConfigs(ctx, c.client, ref)
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("%s %s does not exist; apply it or enable ManageWebhookConfigurations", webhookConfigurationKind(ref.Type), ref.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get %s webhook configuration %s: %w", ref.Type, ref.Name, err)
+		}
+		if verify == nil {
+			continue
+		}
+		for name, cc := range configs {
+			if err := verify(name, cc.CABundle); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// verifier returns a function that verifies the current serving certificate
+// with the caBundle of a webhook entry.
+func (c *webhookReadiness) verifier() (func(name string, caBundle []byte) error, error) {
+	cert, err := c.getCert(nil)
+	if err != nil {
+		return nil, err
+	}
+	leaf := cert.Leaf
+	if leaf == nil {
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, fmt.Errorf("failed to parse serving certificate: %w", err)
+		}
+	}
+	intermediates := x509.NewCertPool()
+	for _, der := range cert.Certificate[1:] {
+		if ca, err := x509.ParseCertificate(der); err == nil {
+			intermediates.AddCert(ca)
+		}
+	}
+
+	return func(name string, caBundle []byte) error {
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(caBundle) {
+			return fmt.Errorf("webhook %s has no CA bundle", name)
+		}
+		if _, err := leaf.Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}); err != nil {
+			return fmt.Errorf("CA bundle of webhook %s does not trust the serving certificate: %w", name, err)
+		}
+		return nil
+	}, nil
+}
+
+// webhookConfigurationKind returns the resource kind of a webhook type.
+func webhookConfigurationKind(t cabundle.WebhookType) string {
+	if t == cabundle.MutatingWebhook {
+		return "MutatingWebhookConfiguration"
+	}
+	return "ValidatingWebhookConfiguration"
+}
@@ -0,0 +1,86 @@
+package autocertwebhook
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	certutil "k8s.io/client-go/util/cert"
+
+	"github.com/jimyag/auto-cert-webhook/internal/cabundle"
+)
+
+// testServingCert returns a serving certificate chain and the PEM of the CA
+// that signed it.
+func testServingCert(t *testing.T) (*tls.Certificate, []byte) {
+	t.Helper()
+	certPEM, keyPEM, err := certutil.GenerateSelfSignedCertKey("svc.ns.svc", nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %v", err)
+	}
+	return &cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[1]})
+}
+
+func TestWebhookReadiness_CABundle(t *testing.T) {
+	cert, caPEM := testServingCert(t)
+	_, otherCAPEM := testServingCert(t)
+
+	client := fake.NewSimpleClientset(&admissionregistrationv1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "wh"},
+		Webhooks: []admissionregistrationv1.MutatingWebhook{
+			{Name: "mutate.wh.ns.svc", ClientConfig: admissionregistrationv1.WebhookClientConfig{CABundle: otherCAPEM}},
+		},
+	})
+	readiness := newWebhookReadiness(client, []cabundle.WebhookRef{{Name: "wh", Type: cabundle.MutatingWebhook}},
+		func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return cert, nil }, 0)
+	ctx := context.Background()
+
+	if err := readiness.Ready(); err == nil {
+		t.Error("Expected not to be ready before the first check")
+	}
+	if err := readiness.check(ctx); err == nil || !strings.Contains(err.Error(), "does not trust the serving certificate") {
+		t.Errorf("check() with another CA: got %v", err)
+	}
+
+	// A bundle that also contains the current CA, as during CA rotation, is trusted
+	cfg, _ := client.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, "wh", metav1.GetOptions{})
+	cfg.Webhooks[0].ClientConfig.CABundle = append(otherCAPEM, caPEM...)
+	client.AdmissionregistrationV1().MutatingWebhookConfigurations().Update(ctx, cfg, metav1.UpdateOptions{})
+	if err := readiness.check(ctx); err != nil {
+		t.Errorf("check() with the current CA: %v", err)
+	}
+
+	readiness.refs = append(readiness.refs, cabundle.WebhookRef{Name: "wh", Type: cabundle.ValidatingWebhook})
+	if err := readiness.check(ctx); err == nil {
+		t.Error("Expected a missing webhook configuration to fail the check")
+	}
+}
+
+func TestWebhookReadiness_Exists(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	readiness := newWebhookReadiness(client, []cabundle.WebhookRef{{Name: "wh", Type: cabundle.ValidatingWebhook}}, nil, 0)
+	ctx := context.Background()
+
+	err := readiness.check(ctx)
+	if err == nil || !strings.Contains(err.Error(), "ValidatingWebhookConfiguration wh does not exist") {
+		t.Errorf("check() without the configuration: got %v", err)
+	}
+
+	// Without a serving certificate to verify, an empty caBundle is fine
+	client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Create(ctx, &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "wh"},
+		Webhooks:   []admissionregistrationv1.ValidatingWebhook{{Name: "validate.wh.ns.svc"}},
+	}, metav1.CreateOptions{})
+	if err := readiness.check(ctx); err != nil {
+		t.Errorf("check() with the configuration: %v", err)
+	}
+}
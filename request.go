@@ -0,0 +1,60 @@
+package autocertwebhook
+
+import (
+	"context"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	"github.com/jimyag/auto-cert-webhook/internal/server"
+)
+
+// SideEffectFunc performs a side effect for an admitted request, such as
+// writing to an external system.
+type SideEffectFunc func(ar admissionv1.AdmissionReview) error
+
+// IsDryRun returns true if the request is a dry run, in which case the hook
+// must not make any changes outside the admission response.
+func IsDryRun(ar admissionv1.AdmissionReview) bool {
+	return ar.Request != nil && ar.Request.DryRun != nil && *ar.Request.DryRun
+}
+
+// AuditID returns the audit ID the API server assigned to the admission
+// request handled with ctx, the context of an AdmitContextFunc, to correlate
+// the hook's logs with the request's audit events. It returns "" if the API
+// server did not send one.
+func AuditID(ctx context.Context) string {
+	return server.AuditID(ctx)
+}
+
+// RequestUID returns the UID of the admission request handled with ctx, the
+// context of an AdmitContextFunc.
+func RequestUID(ctx context.Context) types.UID {
+	return server.RequestUID(ctx)
+}
+
+// NoSideEffects wraps an admit function so that the given side effects run only
+// for allowed requests that are not dry runs. This helps honor the
+// SideEffects=NoneOnDryRun contract declared in the webhook configuration.
+// Side effect errors are logged and do not change the admission response.
+func NoSideEffects(admit AdmitFunc, sideEffects ...SideEffectFunc) AdmitFunc {
+	return func(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		resp := admit(ar)
+		if resp == nil || !resp.Allowed {
+			return resp
+		}
+
+		if IsDryRun(ar) {
+			klog.V(4).Infof("Skipping %d side effects for dry-run request %s", len(sideEffects), ar.Request.UID)
+			return resp
+		}
+
+		for _, sideEffect := range sideEffects {
+			if err := sideEffect(ar); err != nil {
+				klog.Errorf("Side effect failed for request %s: %v", ar.Request.UID, err)
+			}
+		}
+		return resp
+	}
+}
@@ -0,0 +1,106 @@
+package autocertwebhook
+
+import (
+	"errors"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestIsDryRun(t *testing.T) {
+	tests := []struct {
+		name string
+		ar   admissionv1.AdmissionReview
+		want bool
+	}{
+		{name: "nil request", ar: admissionv1.AdmissionReview{}, want: false},
+		{name: "dry run unset", ar: admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{}}, want: false},
+		{name: "dry run false", ar: admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{DryRun: ptr.To(false)}}, want: false},
+		{name: "dry run true", ar: admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{DryRun: ptr.To(true)}}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsDryRun(tt.ar); got != tt.want {
+				t.Errorf("IsDryRun: got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNoSideEffects(t *testing.T) {
+	calls := 0
+	sideEffect := func(ar admissionv1.AdmissionReview) error {
+		calls++
+		return nil
+	}
+	failing := func(ar admissionv1.AdmissionReview) error {
+		calls++
+		return errors.New("external system unavailable")
+	}
+
+	t.Run("runs side effects for allowed requests", func(t *testing.T) {
+		calls = 0
+		admit := NoSideEffects(func(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+			return Allowed()
+		}, sideEffect, sideEffect)
+
+		resp := admit(admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{}})
+
+		if !resp.Allowed {
+			t.Error("Expected Allowed=true")
+		}
+		if calls != 2 {
+			t.Errorf("Expected 2 side effect calls, got %d", calls)
+		}
+	})
+
+	t.Run("skips side effects for dry runs", func(t *testing.T) {
+		calls = 0
+		admit := NoSideEffects(func(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+			return Allowed()
+		}, sideEffect)
+
+		resp := admit(admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{DryRun: ptr.To(true)}})
+
+		if !resp.Allowed {
+			t.Error("Expected Allowed=true")
+		}
+		if calls != 0 {
+			t.Errorf("Expected no side effect calls, got %d", calls)
+		}
+	})
+
+	t.Run("skips side effects for denied requests", func(t *testing.T) {
+		calls = 0
+		admit := NoSideEffects(func(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+			return Denied("no")
+		}, sideEffect)
+
+		resp := admit(admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{}})
+
+		if resp.Allowed {
+			t.Error("Expected Allowed=false")
+		}
+		if calls != 0 {
+			t.Errorf("Expected no side effect calls, got %d", calls)
+		}
+	})
+
+	t.Run("side effect errors do not change the response", func(t *testing.T) {
+		calls = 0
+		admit := NoSideEffects(func(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+			return Allowed()
+		}, failing, sideEffect)
+
+		resp := admit(admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{}})
+
+		if !resp.Allowed {
+			t.Error("Expected Allowed=true")
+		}
+		if calls != 2 {
+			t.Errorf("Expected 2 side effect calls, got %d", calls)
+		}
+	})
+}
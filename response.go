@@ -4,10 +4,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/appscode/jsonpatch"
 	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
 // Allowed returns an admission response that allows the request.
@@ -27,6 +33,16 @@ func AllowedWithMessage(message string) *admissionv1.AdmissionResponse {
 	}
 }
 
+// AllowedWithWarnings returns an admission response that allows the request
+// and returns the given warnings to the API client.
+// Warnings are passed through SanitizeWarnings.
+func AllowedWithWarnings(warnings ...string) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Allowed:  true,
+		Warnings: SanitizeWarnings(warnings),
+	}
+}
+
 // Denied returns an admission response that denies the request.
 func Denied(message string) *admissionv1.AdmissionResponse {
 	return &admissionv1.AdmissionResponse{
@@ -40,6 +56,15 @@ func Denied(message string) *admissionv1.AdmissionResponse {
 	}
 }
 
+// DeniedWithWarnings returns an admission response that denies the request
+// and returns the given warnings to the API client.
+// Warnings are passed through SanitizeWarnings.
+func DeniedWithWarnings(message string, warnings ...string) *admissionv1.AdmissionResponse {
+	resp := Denied(message)
+	resp.Warnings = SanitizeWarnings(warnings)
+	return resp
+}
+
 // DeniedWithReason returns an admission response that denies the request with a specific reason.
 func DeniedWithReason(message string, reason metav1.StatusReason, code int32) *admissionv1.AdmissionResponse {
 	return &admissionv1.AdmissionResponse{
@@ -53,6 +78,17 @@ func DeniedWithReason(message string, reason metav1.StatusReason, code int32) *a
 	}
 }
 
+// DeniedWithFieldErrors returns an admission response that denies the request
+// with an Invalid status listing each field error as a cause, the same way the
+// API server reports native validation failures.
+func DeniedWithFieldErrors(gvk schema.GroupVersionKind, name string, errs field.ErrorList) *admissionv1.AdmissionResponse {
+	status := apierrors.NewInvalid(gvk.GroupKind(), name, errs).Status()
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result:  &status,
+	}
+}
+
 // Errored returns an admission response for an error.
 func Errored(err error) *admissionv1.AdmissionResponse {
 	return &admissionv1.AdmissionResponse{
@@ -135,3 +171,66 @@ func PatchResponseFromPatches(patches []jsonpatch.JsonPatchOperation) *admission
 		PatchType: &patchType,
 	}
 }
+
+const (
+	// maxWarningsTotalRunes is the total warning size (in runes) the API server
+	// accepts before it starts truncating individual warnings.
+	maxWarningsTotalRunes = 4 * 1024
+
+	// maxWarningRunes is the size (in runes) the API server truncates each
+	// warning to once maxWarningsTotalRunes is exceeded.
+	maxWarningRunes = 256
+)
+
+// SanitizeWarnings prepares warnings so the API server relays them to the client
+// unchanged. It drops empty and duplicate warnings, replaces invalid UTF-8 and
+// control characters (which the API server would silently drop), and applies the
+// API server size limits: if the total size exceeds 4096 runes, each warning is
+// truncated to 256 runes and warnings beyond the total limit are dropped.
+func SanitizeWarnings(warnings []string) []string {
+	if len(warnings) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(warnings))
+	cleaned := make([]string, 0, len(warnings))
+	total := 0
+	for _, w := range warnings {
+		w = strings.ToValidUTF8(w, "\uFFFD")
+		w = strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) {
+				return ' '
+			}
+			return r
+		}, w)
+		w = strings.TrimSpace(w)
+		if w == "" || seen[w] {
+			continue
+		}
+		seen[w] = true
+		cleaned = append(cleaned, w)
+		total += utf8.RuneCountInString(w)
+	}
+
+	if total <= maxWarningsTotalRunes {
+		if len(cleaned) == 0 {
+			return nil
+		}
+		return cleaned
+	}
+
+	truncated := make([]string, 0, len(cleaned))
+	total = 0
+	for _, w := range cleaned {
+		if runes := []rune(w); len(runes) > maxWarningRunes {
+			w = string(runes[:maxWarningRunes])
+		}
+		n := utf8.RuneCountInString(w)
+		if total+n > maxWarningsTotalRunes {
+			break
+		}
+		total += n
+		truncated = append(truncated, w)
+	}
+	return truncated
+}
@@ -3,12 +3,17 @@ package autocertwebhook
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/appscode/jsonpatch"
 	admissionv1 "k8s.io/api/admission/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
 func TestAllowed(t *testing.T) {
@@ -294,3 +299,133 @@ func TestPatchResponse_UnmarshalableModified(t *testing.T) {
 		t.Errorf("Expected code %d, got %d", http.StatusInternalServerError, resp.Result.Code)
 	}
 }
+
+func TestAllowedWithWarnings(t *testing.T) {
+	resp := AllowedWithWarnings("deprecated field", "image uses latest tag")
+
+	if !resp.Allowed {
+		t.Error("AllowedWithWarnings() should return Allowed=true")
+	}
+	if len(resp.Warnings) != 2 {
+		t.Fatalf("Warnings: got %d, want %d", len(resp.Warnings), 2)
+	}
+	if resp.Warnings[0] != "deprecated field" {
+		t.Errorf("Warnings[0]: got %q, want %q", resp.Warnings[0], "deprecated field")
+	}
+}
+
+func TestDeniedWithWarnings(t *testing.T) {
+	resp := DeniedWithWarnings("request denied", "missing owner label")
+
+	if resp.Allowed {
+		t.Error("DeniedWithWarnings() should return Allowed=false")
+	}
+	if resp.Result == nil {
+		t.Fatal("DeniedWithWarnings() should have non-nil Result")
+	}
+	if resp.Result.Code != http.StatusForbidden {
+		t.Errorf("Code: got %d, want %d", resp.Result.Code, http.StatusForbidden)
+	}
+	if len(resp.Warnings) != 1 || resp.Warnings[0] != "missing owner label" {
+		t.Errorf("Warnings: got %v, want %v", resp.Warnings, []string{"missing owner label"})
+	}
+}
+
+func TestSanitizeWarnings(t *testing.T) {
+	t.Run("nil input", func(t *testing.T) {
+		if got := SanitizeWarnings(nil); got != nil {
+			t.Errorf("Expected nil, got %v", got)
+		}
+	})
+
+	t.Run("drops empty and duplicate warnings", func(t *testing.T) {
+		got := SanitizeWarnings([]string{"a", "", "  ", "a", "b"})
+		if len(got) != 2 || got[0] != "a" || got[1] != "b" {
+			t.Errorf("Expected [a b], got %v", got)
+		}
+	})
+
+	t.Run("replaces control characters", func(t *testing.T) {
+		got := SanitizeWarnings([]string{"line1\nline2\tend"})
+		if len(got) != 1 || got[0] != "line1 line2 end" {
+			t.Errorf("Expected %q, got %v", "line1 line2 end", got)
+		}
+	})
+
+	t.Run("replaces invalid UTF-8", func(t *testing.T) {
+		got := SanitizeWarnings([]string{"bad\xffbyte"})
+		if len(got) != 1 || got[0] != "bad�byte" {
+			t.Errorf("Expected %q, got %v", "bad�byte", got)
+		}
+	})
+
+	t.Run("keeps warnings within total limit", func(t *testing.T) {
+		long := strings.Repeat("x", 1000)
+		got := SanitizeWarnings([]string{long})
+		if len(got) != 1 || got[0] != long {
+			t.Error("Expected warning within limit to be kept unchanged")
+		}
+	})
+
+	t.Run("truncates when total limit is exceeded", func(t *testing.T) {
+		var warnings []string
+		for i := 0; i < 30; i++ {
+			warnings = append(warnings, fmt.Sprintf("%02d%s", i, strings.Repeat("y", 500)))
+		}
+
+		got := SanitizeWarnings(warnings)
+
+		total := 0
+		for _, w := range got {
+			n := utf8.RuneCountInString(w)
+			if n > maxWarningRunes {
+				t.Errorf("Warning exceeds %d runes: %d", maxWarningRunes, n)
+			}
+			total += n
+		}
+		if total > maxWarningsTotalRunes {
+			t.Errorf("Total warning size exceeds %d runes: %d", maxWarningsTotalRunes, total)
+		}
+		if len(got) != maxWarningsTotalRunes/maxWarningRunes {
+			t.Errorf("Expected %d warnings, got %d", maxWarningsTotalRunes/maxWarningRunes, len(got))
+		}
+	})
+}
+
+func TestDeniedWithFieldErrors(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	errs := field.ErrorList{
+		field.Required(field.NewPath("metadata", "labels").Key("owner"), "owner label is required"),
+		field.Invalid(field.NewPath("spec", "replicas"), 0, "must be at least 1"),
+	}
+
+	resp := DeniedWithFieldErrors(gvk, "web", errs)
+
+	if resp.Allowed {
+		t.Error("DeniedWithFieldErrors() should return Allowed=false")
+	}
+	if resp.Result == nil {
+		t.Fatal("DeniedWithFieldErrors() should have non-nil Result")
+	}
+	if resp.Result.Reason != metav1.StatusReasonInvalid {
+		t.Errorf("Reason: got %q, want %q", resp.Result.Reason, metav1.StatusReasonInvalid)
+	}
+	if resp.Result.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Code: got %d, want %d", resp.Result.Code, http.StatusUnprocessableEntity)
+	}
+	if resp.Result.Details == nil {
+		t.Fatal("Expected non-nil Details")
+	}
+	if resp.Result.Details.Kind != "Deployment" || resp.Result.Details.Group != "apps" || resp.Result.Details.Name != "web" {
+		t.Errorf("Details: got %+v", resp.Result.Details)
+	}
+	if len(resp.Result.Details.Causes) != 2 {
+		t.Fatalf("Causes: got %d, want %d", len(resp.Result.Details.Causes), 2)
+	}
+	if resp.Result.Details.Causes[0].Field != "metadata.labels[owner]" {
+		t.Errorf("Causes[0].Field: got %q, want %q", resp.Result.Details.Causes[0].Field, "metadata.labels[owner]")
+	}
+	if resp.Result.Details.Causes[1].Type != metav1.CauseTypeFieldValueInvalid {
+		t.Errorf("Causes[1].Type: got %q, want %q", resp.Result.Details.Causes[1].Type, metav1.CauseTypeFieldValueInvalid)
+	}
+}
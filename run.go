@@ -2,24 +2,44 @@ package autocertwebhook
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"net"
 	"os"
 	"os/signal"
 	"reflect"
+	"slices"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
+	"github.com/openshift/library-go/pkg/operator/events"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
 
+	"github.com/jimyag/auto-cert-webhook/internal/authn"
+	"github.com/jimyag/auto-cert-webhook/internal/authz"
 	"github.com/jimyag/auto-cert-webhook/internal/cabundle"
-	"github.com/jimyag/auto-cert-webhook/internal/certmanager"
-	"github.com/jimyag/auto-cert-webhook/internal/certprovider"
+	"github.com/jimyag/auto-cert-webhook/internal/clientca"
 	"github.com/jimyag/auto-cert-webhook/internal/leaderelection"
 	"github.com/jimyag/auto-cert-webhook/internal/metrics"
 	"github.com/jimyag/auto-cert-webhook/internal/server"
+	"github.com/jimyag/auto-cert-webhook/pkg/certmanager"
+	"github.com/jimyag/auto-cert-webhook/pkg/certprovider"
 )
 
 const (
@@ -27,6 +47,10 @@ const (
 	// automatically mounted by Kubernetes in pods with ServiceAccount.
 	serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
 
+	// serviceAccountTokenFile is the path to the token of the pod's
+	// ServiceAccount, which self-tests present as a bearer token.
+	serviceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
 	// envPrefix is the environment variable prefix for all configuration.
 	envPrefix = "ACW"
 
@@ -46,41 +70,151 @@ func Run(admission Admission) error {
 
 // RunWithContext starts the webhook server with the given context.
 func RunWithContext(ctx context.Context, admission Admission) error {
+	return run(ctx, admission, func(cfg *Config) (kubernetes.Interface, error) {
+		k8sCfg, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get in-cluster config: %w", err)
+		}
+		tuneRestConfig(k8sCfg, cfg)
+		return newClientForConfig(k8sCfg, cfg)
+	})
+}
+
+// RunWithRestConfig starts the webhook server with a client built from the
+// given configuration instead of the in-cluster one, e.g. one with custom
+// TLS, proxies or impersonation. The configuration is copied; its QPS,
+// burst, timeout and user agent are kept, so ClientQPS, ClientBurst,
+// ClientTimeout and UserAgent do not apply. API writes are still limited.
+func RunWithRestConfig(ctx context.Context, restConfig *rest.Config, admission Admission) error {
+	return run(ctx, admission, func(cfg *Config) (kubernetes.Interface, error) {
+		return newClientForConfig(rest.CopyConfig(restConfig), cfg)
+	})
+}
+
+// RunWithClient starts the webhook server with an existing client. The
+// client is used as is: the client options and the API write limit
+// (APIWriteQPS, APIWriteBurst) do not apply.
+func RunWithClient(ctx context.Context, client kubernetes.Interface, admission Admission) error {
+	return run(ctx, admission, func(*Config) (kubernetes.Interface, error) {
+		return client, nil
+	})
+}
+
+// run starts the webhook server with a client created by newClient once the
+// configuration is validated.
+func run(ctx context.Context, admission Admission, newClient func(cfg *Config) (kubernetes.Interface, error)) error {
 	// Get user configuration
 	cfg := admission.Configure()
 	hooks := admission.Webhooks()
 
 	// Apply environment variables (priority: code > env > default)
+	codeCfg := deepCopyConfig(&cfg)
 	if err := applyEnvConfig(&cfg); err != nil {
 		return err
 	}
+	// Apply command-line flags (priority: flags > code > env > default)
+	cfg.Flags.Apply(&cfg)
+
+	// All configuration problems are collected and reported at once
+	var errs []error
+	sources := newConfigSources(codeCfg, cfg.Flags)
+
+	// Apply the profile to the values left to their defaults
+	// (priority: flags > code > env > profile > default)
+	errs = appendErr(errs, applyProfile(&cfg, sources))
+	sources.profile = cfg.Profile
 
 	if cfg.Name == "" {
-		return fmt.Errorf("webhook name is required in Configure() or ACW_NAME environment variable")
+		errs = append(errs, fieldErrorf("Name", "webhook name is required in Configure(), ACW_NAME or --name"))
 	}
 
 	if len(hooks) == 0 {
-		return fmt.Errorf("at least one webhook hook is required in Webhooks()")
+		errs = append(errs, fmt.Errorf("at least one webhook hook is required in Webhooks()"))
 	}
 
+	manageWebhooks := cfg.ManageWebhookConfigurations != nil && *cfg.ManageWebhookConfigurations
+	certOnly := cfg.CertOnly != nil && *cfg.CertOnly
+	uninstall := cfg.Uninstall != nil && *cfg.Uninstall
+
 	// Validate hooks
 	seenPaths := make(map[string]int)
 	for i, hook := range hooks {
-		if hook.Path == "" {
-			return fmt.Errorf("hook[%d]: path is required", i)
+		switch {
+		case hook.Path == "":
+			errs = append(errs, fmt.Errorf("hook[%d]: path is required", i))
+		case hook.Path[0] != '/':
+			errs = append(errs, fmt.Errorf("hook[%d]: path must start with '/'", i))
+		default:
+			if prev, exists := seenPaths[hook.Path]; exists {
+				errs = append(errs, fmt.Errorf("hook[%d]: path %q already defined by hook[%d]", i, hook.Path, prev))
+			}
+			seenPaths[hook.Path] = i
 		}
-		if hook.Path[0] != '/' {
-			return fmt.Errorf("hook[%d]: path must start with '/'", i)
+		if hook.Admit == nil && hook.AdmitContext == nil && !certOnly && !uninstall {
+			errs = append(errs, fmt.Errorf("hook[%d]: admit function is required", i))
 		}
-		if prev, exists := seenPaths[hook.Path]; exists {
-			return fmt.Errorf("hook[%d]: path %q already defined by hook[%d]", i, hook.Path, prev)
+		if hook.Admit != nil && hook.AdmitContext != nil {
+			errs = append(errs, fmt.Errorf("hook[%d]: only one of Admit and AdmitContext can be set", i))
 		}
-		seenPaths[hook.Path] = i
-		if hook.Admit == nil {
-			return fmt.Errorf("hook[%d]: admit function is required", i)
+		if hook.MaxRequestBodyBytes < 0 {
+			errs = append(errs, fmt.Errorf("hook[%d]: max request body bytes must not be negative, got %d", i, hook.MaxRequestBodyBytes))
+		}
+		if hook.HandlerTimeout < 0 {
+			errs = append(errs, fmt.Errorf("hook[%d]: handler timeout must not be negative, got %v", i, hook.HandlerTimeout))
+		}
+		if hook.ResponseCacheTTL < 0 {
+			errs = append(errs, fmt.Errorf("hook[%d]: response cache TTL must not be negative, got %v", i, hook.ResponseCacheTTL))
 		}
 		if hook.Type != Mutating && hook.Type != Validating {
-			return fmt.Errorf("hook[%d]: type must be Mutating or Validating", i)
+			errs = append(errs, fmt.Errorf("hook[%d]: type must be Mutating or Validating", i))
+		}
+		if hook.WebhookConfigurationName != "" {
+			if msgs := validation.IsDNS1123Subdomain(hook.WebhookConfigurationName); len(msgs) > 0 {
+				errs = append(errs, fmt.Errorf("hook[%d]: invalid webhook configuration name %q: %s", i, hook.WebhookConfigurationName, strings.Join(msgs, "; ")))
+			}
+		}
+		if policy := hook.ReinvocationPolicy; policy != nil {
+			if hook.Type != Mutating {
+				errs = append(errs, fmt.Errorf("hook[%d]: reinvocation policy is only valid for Mutating hooks", i))
+			} else if *policy != admissionregistrationv1.NeverReinvocationPolicy && *policy != admissionregistrationv1.IfNeededReinvocationPolicy {
+				errs = append(errs, fmt.Errorf("hook[%d]: reinvocation policy must be Never or IfNeeded, got %q", i, *policy))
+			}
+		}
+		if hook.ServicePort < 0 || hook.ServicePort > 65535 {
+			errs = append(errs, fmt.Errorf("hook[%d]: service port must be between 1 and 65535, got %d", i, hook.ServicePort))
+		}
+		if hook.Port < 0 || hook.Port > 65535 {
+			errs = append(errs, fmt.Errorf("hook[%d]: port must be between 1 and 65535, got %d", i, hook.Port))
+		}
+		if hook.ServicePath != "" && hook.ServicePath[0] != '/' {
+			errs = append(errs, fmt.Errorf("hook[%d]: service path must start with '/'", i))
+		}
+		if hook.Exempt != nil {
+			if err := validateAccessCheck(hook.Exempt); err != nil {
+				errs = append(errs, fmt.Errorf("hook[%d]: exempt: %w", i, err))
+			}
+		}
+		if manageWebhooks && len(hook.Rules) == 0 && !slices.Contains(cfg.DisabledHooks, hook.Path) {
+			errs = append(errs, fmt.Errorf("hook[%d]: rules are required when webhook configurations are managed", i))
+		}
+	}
+
+	overrides, err := parseWebhookOverrides(&cfg, manageWebhooks)
+	errs = appendErr(errs, err)
+	for _, ns := range cfg.ExcludeNamespaces {
+		if msgs := validation.IsDNS1123Label(ns); len(msgs) > 0 {
+			errs = append(errs, fieldErrorf("ExcludeNamespaces", "invalid excluded namespace %q: %s", ns, strings.Join(msgs, "; ")))
+		}
+	}
+	if cfg.ExcludeOwnPodsLabel != "" {
+		if err := validateLabel(cfg.ExcludeOwnPodsLabel); err != nil {
+			errs = append(errs, fieldErrorf("ExcludeOwnPodsLabel", "exclude own pods label: %v", err))
+		}
+	}
+
+	for _, path := range cfg.DisabledHooks {
+		if _, ok := seenPaths[path]; !ok {
+			errs = append(errs, fieldErrorf("DisabledHooks", "disabled hook %q is not the path of a hook", path))
 		}
 	}
 
@@ -88,126 +222,444 @@ func RunWithContext(ctx context.Context, admission Admission) error {
 	applyDefaults(&cfg)
 
 	// Validate certificate durations
-	if err := validateCertDurations(&cfg); err != nil {
-		return err
+	errs = appendErr(errs, validateCertDurations(&cfg))
+	errs = appendErr(errs, validateCertSyncInterval(&cfg))
+	if !slices.Contains(certmanager.KeyRotationPolicies, cfg.ServingKeyRotationPolicy) {
+		errs = append(errs, fieldErrorf("ServingKeyRotationPolicy", "serving key rotation policy must be %q or %q, got %q",
+			certmanager.KeyRotationPolicyAlways, certmanager.KeyRotationPolicyNever, cfg.ServingKeyRotationPolicy))
+	}
+	if !slices.Contains(certmanager.SignatureAlgorithms, cfg.SignatureAlgorithm) {
+		errs = append(errs, fieldErrorf("SignatureAlgorithm", "signature algorithm must be one of %q, got %q",
+			certmanager.SignatureAlgorithms, cfg.SignatureAlgorithm))
+	}
+	if err := caSubject(&cfg).Validate(true); err != nil {
+		errs = append(errs, fieldErrorf("CASubjectCommonName", "CA subject: %v", err))
+	}
+	if err := servingSubject(&cfg).Validate(false); err != nil {
+		errs = append(errs, fieldErrorf("ServingSubjectCommonName", "serving certificate subject: %v", err))
 	}
 
-	klog.Infof("Starting webhook %s in namespace %s", cfg.Name, cfg.Namespace)
+	errs = appendErr(errs, validateDataKeys(&cfg))
+	errs = appendErr(errs, validateCABundleNamespaces(&cfg))
+	errs = appendErr(errs, validateStatusConfigMap(&cfg))
+	errs = appendErr(errs, validateHeadlessService(&cfg))
+	if err := validateExternalHostnames(cfg.ExternalHostnames); err != nil {
+		errs = append(errs, withFields(err, "ExternalHostnames"))
+	}
 
-	// Create Kubernetes client
-	k8sCfg, err := rest.InClusterConfig()
-	if err != nil {
-		return fmt.Errorf("failed to get in-cluster config: %w", err)
+	var caBackup []byte
+	if cfg.CABackupFile != "" {
+		if caBackup, err = os.ReadFile(cfg.CABackupFile); err != nil {
+			errs = append(errs, fieldErrorf("CABackupFile", "failed to read CA backup: %v", err))
+		}
+	}
+	errs = appendErr(errs, validateServices(&cfg))
+
+	if cfg.ReadyzRequireCABundle != nil && *cfg.ReadyzRequireCABundle && certOnly {
+		errs = append(errs, withFields(errors.New("the CA bundle readiness check requires the webhook server, not cert-only mode"), "ReadyzRequireCABundle", "CertOnly"))
+	}
+	if cfg.ReadyzRequireWebhookConfigurations != nil && *cfg.ReadyzRequireWebhookConfigurations && certOnly {
+		errs = append(errs, withFields(errors.New("the webhook configuration readiness check requires the webhook server, not cert-only mode"), "ReadyzRequireWebhookConfigurations", "CertOnly"))
+	}
+
+	if cfg.ReadyzRequireCertSync != nil && *cfg.ReadyzRequireCertSync && certOnly {
+		errs = append(errs, withFields(errors.New("the certificate sync readiness check requires the webhook server, not cert-only mode"), "ReadyzRequireCertSync", "CertOnly"))
 	}
 
-	client, err := kubernetes.NewForConfig(k8sCfg)
+	errs = appendErr(errs, validateHealthChecks("ReadinessChecks", cfg.ReadinessChecks, certOnly))
+	errs = appendErr(errs, validateHealthChecks("LivenessChecks", cfg.LivenessChecks, certOnly))
+
+	errs = appendErr(errs, validateSelfTest(&cfg, certOnly))
+
+	if cfg.ShutdownDelay < 0 {
+		errs = append(errs, fieldErrorf("ShutdownDelay", "shutdown delay must not be negative, got %v", cfg.ShutdownDelay))
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		errs = append(errs, fieldErrorf("ShutdownTimeout", "shutdown timeout must be positive, got %v", cfg.ShutdownTimeout))
+	}
+
+	if cfg.MaxRestarts < 0 {
+		errs = append(errs, fieldErrorf("MaxRestarts", "max restarts must not be negative, got %d", cfg.MaxRestarts))
+	}
+
+	if cfg.APIWriteBurst <= 0 {
+		errs = append(errs, fieldErrorf("APIWriteBurst", "API write burst must be positive, got %d", cfg.APIWriteBurst))
+	}
+
+	if cfg.ClientBurst <= 0 {
+		errs = append(errs, fieldErrorf("ClientBurst", "client burst must be positive, got %d", cfg.ClientBurst))
+	}
+	if cfg.ClientTimeout < 0 {
+		errs = append(errs, fieldErrorf("ClientTimeout", "client timeout must not be negative, got %v", cfg.ClientTimeout))
+	}
+
+	errs = appendErr(errs, validateRateLimit(&cfg))
+
+	tlsPolicy, err := buildTLSPolicy(&cfg)
+	errs = appendErr(errs, err)
+
+	errs = appendErr(errs, withFields(validateBindAddress(cfg.BindAddress), "BindAddress"))
+
+	if !certOnly {
+		errs = appendErr(errs, validateHookPorts(&cfg, hooks))
+	}
+	if manageWebhooks {
+		errs = appendErr(errs, validateWebhookEntryNames(&cfg, hooks))
+	}
+
+	network, err := listenNetwork(cfg.IPFamily)
+	errs = appendErr(errs, withFields(err, "IPFamily"))
+
+	clientCANamespace, clientCAName, err := parseClientCAConfigMap(&cfg)
+	errs = appendErr(errs, err)
+
+	errs = appendErr(errs, validateMetrics(&cfg, hooks, certOnly))
+	errs = appendErr(errs, validateHandlers(&cfg, hooks, certOnly))
+	errs = appendErr(errs, validateServeHandler(&cfg, hooks, certOnly))
+
+	if manageWebhooks && (cfg.ServicePort <= 0 || cfg.ServicePort > 65535) {
+		errs = append(errs, fieldErrorf("ServicePort", "service port must be between 1 and 65535, got %d", cfg.ServicePort))
+	}
+
+	if len(errs) > 0 {
+		return sources.aggregate(errs)
+	}
+
+	if p, ok := profiles[cfg.Profile]; ok && p.verbosity > 0 {
+		raiseVerbosity(p.verbosity)
+	}
+
+	klog.Infof("Starting webhook %s in namespace %s", cfg.Name, cfg.Namespace)
+
+	// Registration overrides also apply to the fail-open behavior of the hooks
+	hooks = overrides.apply(hooks)
+
+	// Create Kubernetes client
+	client, err := newClient(&cfg)
 	if err != nil {
-		return fmt.Errorf("failed to create Kubernetes client: %w", err)
+		return err
 	}
 
-	errCh := make(chan error, 6) // Buffer for: certProvider, server, metrics, certManager, caBundleSyncer, leaderElection
+	sup := newSupervisor(cfg.MaxRestarts)
 
 	// Determine webhook refs for CA bundle syncer
 	webhookRefs := determineWebhookRefs(cfg.Name, hooks)
+	if manageWebhooks {
+		for i := range webhookRefs {
+			webhookRefs[i].Entries = buildWebhookEntries(&cfg, hooks, webhookRefs[i])
+		}
+	}
+
+	if uninstall {
+		klog.Infof("Uninstalling webhook %s", cfg.Name)
+		return cleanup(ctx, client, &cfg, webhookRefs)
+	}
+
+	// Secrets and ConfigMaps of the webhook namespace are watched once and
+	// shared by the certificate provider, manager and CA bundle syncer
+	informerFactory := newInformerFactory(client, &cfg)
 
 	// Create certificate provider (runs on all pods)
+	metrics.SetWebhookName(cfg.Name)
+	metrics.SetAdmissionDurationBuckets(cfg.MetricsLatencyBuckets, cfg.MetricsNativeHistograms != nil && *cfg.MetricsNativeHistograms)
+
 	certProvider := certprovider.New(client, cfg.Namespace, cfg.CertSecretName)
+	if cfg.CertDir != "" {
+		certProvider.SetCertDir(cfg.CertDir, cfg.CABundleConfigMapName)
+	}
+	certProvider.SetDataKeys(certprovider.DataKeys{
+		Cert:     cfg.CertDataKey,
+		Key:      cfg.KeyDataKey,
+		CA:       cfg.CADataKey,
+		CABundle: cfg.CABundleDataKey,
+	})
+	if cfg.OnCertRotate != nil {
+		certProvider.OnRotate(cfg.OnCertRotate)
+	}
+	certProvider.SetInformerFactory(informerFactory)
+	certProvider.SetResyncPeriod(cfg.InformerResyncPeriod)
 
 	// Start certificate provider in background
-	go func() {
-		if err := certProvider.Start(ctx); err != nil {
-			klog.Errorf("Certificate provider error: %v", err)
-			errCh <- err
-		}
-	}()
-
-	// Create and start HTTP server (runs on all pods)
-	srv := server.New(certProvider, server.Config{
-		Port:        cfg.Port,
-		HealthzPath: cfg.HealthzPath,
-		ReadyzPath:  cfg.ReadyzPath,
-	})
+	sup.Go(ctx, "cert-provider", certProvider.Start)
 
-	// Register webhook handlers
-	for _, hook := range hooks {
-		srv.RegisterHook(hook.Path, string(hook.Type), hook.Admit)
-		klog.Infof("Registered %s webhook at path %s", hook.Type, hook.Path)
+	// Start metrics server if enabled. With MetricsOnWebhookServer, metrics
+	// are served by the webhook server instead.
+	metricsEnabled := cfg.MetricsEnabled == nil || *cfg.MetricsEnabled
+	metricsOnWebhookServer := cfg.MetricsOnWebhookServer != nil && *cfg.MetricsOnWebhookServer
+	var metricsSrv *metrics.Server
+	if metricsEnabled {
+		metricsCfg := metrics.ServerConfig{
+			Port:            cfg.MetricsPort,
+			Network:         network,
+			Path:            cfg.MetricsPath,
+			ShutdownTimeout: cfg.ShutdownTimeout,
+		}
+		if cfg.MetricsTLS != nil && *cfg.MetricsTLS {
+			metricsCfg.TLSConfig = &tls.Config{GetCertificate: certProvider.GetCertificate}
+			tlsPolicy.Apply(metricsCfg.TLSConfig)
+		}
+		if cfg.MetricsClientCAFile != "" {
+			metricsCA, err := clientca.NewFile(cfg.MetricsClientCAFile)
+			if err != nil {
+				return err
+			}
+			metricsCfg.ClientCAs = metricsCA.ClientCAs
+			sup.Go(ctx, "metrics-client-ca", metricsCA.Start)
+		}
+		if cfg.MetricsTokenReviewAuthentication != nil && *cfg.MetricsTokenReviewAuthentication {
+			metricsCfg.Authenticator = authn.NewTokenReviewer(client, authn.Config{
+				Audiences: cfg.TokenReviewAudiences,
+				CacheTTL:  cfg.TokenReviewCacheTTL,
+			})
+		}
+		metricsSrv = metrics.NewServer(metricsCfg)
+		if !metricsOnWebhookServer {
+			sup.Go(ctx, "metrics-server", metricsSrv.Start)
+		}
 	}
 
-	// Start HTTP server in background
-	go func() {
-		if err := srv.Start(ctx); err != nil {
-			klog.Errorf("Server error: %v", err)
-			errCh <- err
+	// Certificate managers run on the leader only, but every pod reports
+	// their health
+	certMgrs := newCertManagers(client, &cfg, caBackup)
+
+	// The admission server is skipped in cert-only mode, where an adjacent
+	// process serves the hooks with the provisioned certificate
+	if !certOnly {
+		// Load the client CA used to authenticate the API server, if configured
+		var clientCA *clientca.Provider
+		switch {
+		case cfg.ClientCAFile != "":
+			if clientCA, err = clientca.NewFile(cfg.ClientCAFile); err != nil {
+				return err
+			}
+		case clientCAName != "":
+			clientCA = clientca.NewConfigMap(client, clientCANamespace, clientCAName, cfg.ClientCAConfigMapKey)
+			clientCA.SetResyncPeriod(cfg.InformerResyncPeriod)
+		}
+		var clientCAs func() *x509.CertPool
+		if clientCA != nil {
+			clientCAs = clientCA.ClientCAs
+			sup.Go(ctx, "client-ca", clientCA.Start)
 		}
-	}()
 
-	// Start metrics server if enabled
-	metricsEnabled := cfg.MetricsEnabled == nil || *cfg.MetricsEnabled
-	if metricsEnabled {
-		metricsSrv := metrics.NewServer(metrics.ServerConfig{
-			Port: cfg.MetricsPort,
-			Path: cfg.MetricsPath,
+		var authenticator server.Authenticator
+		if cfg.TokenReviewAuthentication != nil && *cfg.TokenReviewAuthentication {
+			authenticator = authn.NewTokenReviewer(client, authn.Config{
+				Audiences: cfg.TokenReviewAudiences,
+				CacheTTL:  cfg.TokenReviewCacheTTL,
+			})
+		}
+
+		// Create and start HTTP server (runs on all pods)
+		srv := server.New(certProvider, server.Config{
+			BindAddress:               cfg.BindAddress,
+			Port:                      cfg.Port,
+			Network:                   network,
+			HealthzPath:               cfg.HealthzPath,
+			ReadyzPath:                cfg.ReadyzPath,
+			ShutdownDelay:             cfg.ShutdownDelay,
+			ShutdownTimeout:           cfg.ShutdownTimeout,
+			AllowYAML:                 cfg.AllowYAMLRequests != nil && *cfg.AllowYAMLRequests,
+			ExcludeNamespaces:         cfg.ExcludeNamespaces,
+			TLS:                       tlsPolicy,
+			ClientCAs:                 clientCAs,
+			Authenticator:             authenticator,
+			DisableHTTP2:              cfg.DisableHTTP2 != nil && *cfg.DisableHTTP2,
+			HTTP2MaxConcurrentStreams: cfg.HTTP2MaxConcurrentStreams,
+			MaxInFlight:               cfg.MaxInFlightRequests,
+			RateLimit: server.RateLimitConfig{
+				QPS:   cfg.RateLimitQPS,
+				Burst: cfg.RateLimitBurst,
+				By:    cfg.RateLimitBy,
+			},
+			LoadShedding: server.LoadSheddingConfig{
+				InFlight: cfg.LoadSheddingInFlight,
+				Latency:  cfg.LoadSheddingLatency,
+			},
 		})
-		go func() {
-			if err := metricsSrv.Start(ctx); err != nil {
-				klog.Errorf("Metrics server error: %v", err)
-				errCh <- err
+
+		// Register webhook handlers
+		var accessReviewer *authz.Reviewer
+		for _, hook := range hooks {
+			admit := hook.AdmitContext
+			if admit == nil {
+				plain := hook.Admit
+				admit = func(_ context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+					return plain(ar)
+				}
+			}
+			if hook.Exempt != nil {
+				if accessReviewer == nil {
+					accessReviewer = authz.New(client, authz.Config{CacheTTL: cfg.SubjectAccessReviewCacheTTL})
+				}
+				admit = exemptByRBAC(accessReviewer, *hook.Exempt, admit)
 			}
-		}()
+			disabled := slices.Contains(cfg.DisabledHooks, hook.Path)
+			srv.RegisterHook(hook.Path, string(hook.Type), admit, server.HookOptions{
+				Port:        hook.Port,
+				FailOpen:    hook.FailurePolicy != nil && *hook.FailurePolicy == admissionregistrationv1.Ignore,
+				Disabled:    disabled,
+				Timeout:     hook.HandlerTimeout,
+				MaxBodySize: hook.MaxRequestBodyBytes,
+				CacheTTL:    hook.ResponseCacheTTL,
+			})
+			if disabled {
+				klog.Infof("Registered %s webhook at path %s, disabled", hook.Type, hook.Path)
+				continue
+			}
+			klog.Infof("Registered %s webhook at path %s", hook.Type, hook.Path)
+		}
+
+		requireCABundle := cfg.ReadyzRequireCABundle != nil && *cfg.ReadyzRequireCABundle
+		if requireCABundle || (cfg.ReadyzRequireWebhookConfigurations != nil && *cfg.ReadyzRequireWebhookConfigurations) {
+			var getCert func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+			if requireCABundle {
+				getCert = certProvider.GetCertificate
+			}
+			webhookReady := newWebhookReadiness(client, webhookRefs, getCert, cfg.CertSyncInterval)
+			srv.AddReadinessCheck("webhook configurations", func(context.Context) error { return webhookReady.Ready() })
+			sup.Go(ctx, "webhook-readiness", webhookReady.Run)
+		}
+
+		if cfg.ReadyzRequireCertSync != nil && *cfg.ReadyzRequireCertSync {
+			srv.AddReadinessCheck("certificate sync", func(context.Context) error {
+				var errs []error
+				for _, certMgr := range certMgrs {
+					if err := certMgr.Healthy(); err != nil {
+						errs = append(errs, err)
+					}
+				}
+				return errors.Join(errs...)
+			})
+		}
+
+		for _, c := range cfg.ReadinessChecks {
+			srv.AddReadinessCheck(c.Name, c.Check)
+		}
+		for _, c := range cfg.LivenessChecks {
+			srv.AddLivenessCheck(c.Name, c.Check)
+		}
+
+		if metricsSrv != nil && metricsOnWebhookServer {
+			srv.Handle(cfg.MetricsPath, metricsSrv.Handler())
+			klog.Infof("Serving metrics at path %s of the webhook server", cfg.MetricsPath)
+		}
+
+		for _, h := range cfg.Handlers {
+			srv.Handle(h.Path, h.Handler)
+			klog.Infof("Serving custom handler at path %s of the webhook server", h.Path)
+		}
+
+		if cfg.ServeHandler != nil {
+			// The application serves the hooks from its own server
+			cfg.ServeHandler(srv.Handler(), certProvider.GetCertificate)
+			klog.Info("Passed the admission handler to ServeHandler instead of starting the webhook server")
+		} else {
+			// Start HTTP server in background
+			sup.Go(ctx, "webhook-server", srv.Start)
+		}
 	}
 
-	// Create certificate manager and CA bundle syncer (runs on leader only)
-	certMgr := certmanager.New(client, certmanager.Config{
-		Namespace:             cfg.Namespace,
-		ServiceName:           cfg.ServiceName,
-		CASecretName:          cfg.CASecretName,
-		CertSecretName:        cfg.CertSecretName,
-		CABundleConfigMapName: cfg.CABundleConfigMapName,
-		CAValidity:            cfg.CAValidity,
-		CARefresh:             cfg.CARefresh,
-		CertValidity:          cfg.CertValidity,
-		CertRefresh:           cfg.CertRefresh,
-		SyncInterval:          cfg.CertSyncInterval,
-	})
+	// Set up the certificate managers and create the CA bundle syncer (runs
+	// on leader only)
+	eventRecorder := newEventRecorder(ctx, client, &cfg)
+	for _, certMgr := range certMgrs {
+		certMgr.SetEventRecorder(eventRecorder)
+		// Managers for services in other namespaces use their own informers
+		if certMgr.Namespace() == cfg.Namespace {
+			certMgr.SetInformerFactory(informerFactory)
+		}
+	}
 
 	caBundleSyncer := cabundle.NewSyncer(client, cfg.Namespace, cfg.CABundleConfigMapName, webhookRefs)
+	caBundleSyncer.SetCABundleKey(cfg.CABundleDataKey)
+	caBundleSyncer.SetResyncInterval(cfg.CABundleResyncInterval)
+	caBundleSyncer.SetInformerFactory(informerFactory)
+	caBundleSyncer.SetEventRecorder(eventRecorder)
+	identity := leaderelection.Identity()
+	caBundleSyncer.SetIdentity(identity)
+
+	var selfTest *selfTester
+	if cfg.SelfTestInterval > 0 {
+		credentials := selfTestCredentials{certFile: cfg.SelfTestClientCertFile, keyFile: cfg.SelfTestClientKeyFile}
+		if cfg.TokenReviewAuthentication != nil && *cfg.TokenReviewAuthentication {
+			credentials.tokenFile = serviceAccountTokenFile
+		}
+		selfTest = newSelfTester(client, webhookRefs, cfg.Namespace, cfg.SelfTestInterval, credentials, eventRecorder)
+	}
 
 	leaderElectionEnabled := cfg.LeaderElection == nil || *cfg.LeaderElection
+	var statusReport *statusReporter
+	if cfg.StatusConfigMapName != "" {
+		statusReport = &statusReporter{
+			client:       client,
+			namespace:    cfg.Namespace,
+			name:         cfg.StatusConfigMapName,
+			interval:     cfg.CertSyncInterval,
+			syncer:       caBundleSyncer,
+			caBundles:    informerFactory.Core().V1().ConfigMaps().Lister(),
+			caBundleName: cfg.CABundleConfigMapName,
+			caBundleKey:  cfg.CABundleDataKey,
+		}
+		for _, certMgr := range certMgrs {
+			statusReport.certMgrs = append(statusReport.certMgrs, certMgr)
+		}
+		if leaderElectionEnabled {
+			statusReport.identity = identity
+		}
+	}
+
+	// Every pod watches for certificates that were not rotated in time
+	expiryMon := newExpiryMonitor(informerFactory.Core().V1().Secrets().Informer(), &cfg)
+	sup.Go(ctx, "expiry-monitor", expiryMon.Run)
+
+	// Start the shared informers once all of them are requested. They run
+	// on every pod and outlive leadership terms.
+	sup.GoOnce(ctx, "informers", func(ctx context.Context) error {
+		informerFactory.Start(ctx.Done())
+		<-ctx.Done()
+		informerFactory.Shutdown()
+		return nil
+	})
+
 	if leaderElectionEnabled {
-		// Run with leader election
-		go func() {
-			if err := leaderelection.Run(ctx, client, leaderelection.Config{
+		// Run with leader election. It only fails on invalid configuration,
+		// which a restart does not fix.
+		sup.GoOnce(ctx, "leader-election", func(ctx context.Context) error {
+			return leaderelection.Run(ctx, client, leaderelection.Config{
 				Namespace:     cfg.Namespace,
 				Name:          cfg.LeaderElectionID,
 				LeaseDuration: cfg.LeaseDuration,
 				RenewDeadline: cfg.RenewDeadline,
 				RetryPeriod:   cfg.RetryPeriod,
+				EventRecorder: eventRecorder,
+				Identity:      identity,
 			}, leaderelection.Callbacks{
 				OnStartedLeading: func(leaderCtx context.Context) {
 					klog.Info("Became leader, starting certificate management")
-					startCertManagement(leaderCtx, certMgr, caBundleSyncer, errCh)
+					startCertManagement(leaderCtx, sup, certMgrs, caBundleSyncer, selfTest, statusReport)
 				},
 				OnStoppedLeading: func() {
 					klog.Info("Lost leadership")
 				},
-			}); err != nil {
-				klog.Errorf("Leader election error: %v", err)
-				errCh <- err
-			}
-		}()
+			})
+		})
 	} else {
 		// Run without leader election (single replica mode)
 		klog.Info("Running without leader election")
-		startCertManagement(ctx, certMgr, caBundleSyncer, errCh)
+		startCertManagement(ctx, sup, certMgrs, caBundleSyncer, selfTest, statusReport)
 	}
 
 	// Wait for context cancellation or error
 	select {
 	case <-ctx.Done():
 		klog.Info("Shutting down")
+		// Wait for the subsystems to stop before returning, as callers
+		// typically exit the process right after. All of them share one
+		// deadline: the drain delay plus the shutdown timeout.
+		sup.Wait(cfg.ShutdownDelay + cfg.ShutdownTimeout)
 		return nil
-	case err := <-errCh:
+	case err := <-sup.Errors():
 		klog.Errorf("Error: %v", err)
 		return err
 	}
@@ -287,6 +739,26 @@ func applyDefaults(cfg *Config) {
 	if cfg.LeaderElectionID == "" {
 		cfg.LeaderElectionID = cfg.Name + "-leader"
 	}
+
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = defaultUserAgent()
+	}
+
+	for i := range cfg.Services {
+		svc := &cfg.Services[i]
+		if svc.Namespace == "" {
+			svc.Namespace = cfg.Namespace
+		}
+		if svc.CASecretName == "" {
+			svc.CASecretName = svc.ServiceName + "-ca"
+		}
+		if svc.CertSecretName == "" {
+			svc.CertSecretName = svc.ServiceName + "-cert"
+		}
+		if svc.CABundleConfigMapName == "" {
+			svc.CABundleConfigMapName = svc.ServiceName + "-ca-bundle"
+		}
+	}
 }
 
 // getNamespace returns the namespace from:
@@ -316,70 +788,803 @@ func getNamespace() string {
 	return defaultNamespace
 }
 
-func startCertManagement(ctx context.Context, certMgr *certmanager.Manager, caBundleSyncer *cabundle.Syncer, errCh chan error) {
-	go func() {
-		if err := certMgr.Start(ctx); err != nil {
-			klog.Errorf("Certificate manager error: %v", err)
-			errCh <- err
+// newInformerFactory creates the informer factory for the webhook namespace.
+// Secrets and ConfigMaps other than the ones this library manages are cached
+// without their data, so that the cache stays small in busy namespaces.
+func newInformerFactory(client kubernetes.Interface, cfg *Config) informers.SharedInformerFactory {
+	factory := informers.NewSharedInformerFactoryWithOptions(
+		client,
+		max(cfg.InformerResyncPeriod, 0),
+		informers.WithNamespace(cfg.Namespace),
+		informers.WithTransform(trimUnmanagedData(cfg)),
+	)
+	_ = factory.Core().V1().Secrets().Informer().SetWatchErrorHandlerWithContext(metrics.WatchErrorHandler("secrets"))
+	_ = factory.Core().V1().ConfigMaps().Informer().SetWatchErrorHandlerWithContext(metrics.WatchErrorHandler("configmaps"))
+	return factory
+}
+
+// trimUnmanagedData returns an informer transform that drops the data of
+// secrets and ConfigMaps not managed by this library.
+func trimUnmanagedData(cfg *Config) cache.TransformFunc {
+	secrets := sets.New(cfg.CASecretName, cfg.CertSecretName)
+	if cfg.KeystorePasswordSecretName != "" {
+		secrets.Insert(cfg.KeystorePasswordSecretName)
+	}
+	configMaps := sets.New(cfg.CABundleConfigMapName)
+	for _, svc := range cfg.Services {
+		if svc.Namespace == cfg.Namespace {
+			secrets.Insert(svc.CASecretName, svc.CertSecretName)
+			configMaps.Insert(svc.CABundleConfigMapName)
+		}
+	}
+
+	return func(obj interface{}) (interface{}, error) {
+		switch o := obj.(type) {
+		case *corev1.Secret:
+			if !secrets.Has(o.Name) {
+				o.Data = nil
+				o.StringData = nil
+			}
+		case *corev1.ConfigMap:
+			if !configMaps.Has(o.Name) {
+				o.Data = nil
+				o.BinaryData = nil
+			}
 		}
-	}()
+		return obj, nil
+	}
+}
 
-	go func() {
-		if err := caBundleSyncer.Start(ctx); err != nil {
-			klog.Errorf("CA bundle syncer error: %v", err)
-			errCh <- err
+// newEventRecorder creates the recorder of the certificate and leadership
+// events, attached to cfg.EventObject or else to the controller of the current
+// pod, e.g. its Deployment.
+func newEventRecorder(ctx context.Context, client kubernetes.Interface, cfg *Config) events.Recorder {
+	ref := cfg.EventObject
+	if ref == nil {
+		var err error
+		ref, err = events.GetControllerReferenceForCurrentPod(ctx, client, cfg.Namespace, nil)
+		if err != nil {
+			klog.V(4).Infof("Unable to get controller reference: %v", err)
 		}
-	}()
+	}
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = cfg.Namespace
+	}
+	return events.NewRecorder(client.CoreV1().Events(namespace), cfg.Name, ref, clock.RealClock{})
+}
+
+// newCertManagers creates the certificate manager of the webhook, which
+// restores caBackup if its CA secret does not exist and writes the keystore,
+// followed by one for each additional service.
+func newCertManagers(client kubernetes.Interface, cfg *Config, caBackup []byte) []*certmanager.Manager {
+	base := certmanager.Config{
+		Namespace:             cfg.Namespace,
+		ServiceName:           cfg.ServiceName,
+		ServiceIPSANs:         cfg.ServiceIPSANs != nil && *cfg.ServiceIPSANs,
+		CASecretName:          cfg.CASecretName,
+		CertSecretName:        cfg.CertSecretName,
+		CABundleConfigMapName: cfg.CABundleConfigMapName,
+		CAValidity:            cfg.CAValidity,
+		CARefresh:             cfg.CARefresh,
+		CAOverlap:             cfg.CAOverlap,
+		CABundleMaxCerts:      cfg.CABundleMaxCerts,
+		CertValidity:          cfg.CertValidity,
+		CertRefresh:           cfg.CertRefresh,
+		SyncInterval:          cfg.CertSyncInterval,
+		CertDataKey:           cfg.CertDataKey,
+		KeyDataKey:            cfg.KeyDataKey,
+		CABundleDataKey:       cfg.CABundleDataKey,
+		SecretType:            corev1.SecretType(cfg.SecretType),
+
+		ServingKeyRotationPolicy: cfg.ServingKeyRotationPolicy,
+		SignatureAlgorithm:       cfg.SignatureAlgorithm,
+		CASubject:                caSubject(cfg),
+		ServingSubject:           servingSubject(cfg),
+	}
+
+	webhookCfg := base
+	webhookCfg.CABackup = caBackup
+	webhookCfg.CABackupPassphrase = []byte(cfg.CABackupPassphrase)
+	webhookCfg.KeystorePasswordSecretName = cfg.KeystorePasswordSecretName
+	webhookCfg.KeystorePasswordSecretKey = cfg.KeystorePasswordSecretKey
+	webhookCfg.HeadlessServiceName = cfg.HeadlessServiceName
+	webhookCfg.StatefulSetName = cfg.StatefulSetName
+	webhookCfg.StatefulSetReplicas = cfg.StatefulSetReplicas
+	webhookCfg.ExternalHostnames = cfg.ExternalHostnames
+	webhookCfg.CABundleNamespaces = cfg.CABundleNamespaces
+	// The selector is validated by validateCABundleNamespaces
+	webhookCfg.CABundleNamespaceSelector, _ = caBundleNamespaceSelector(cfg)
+
+	managers := []*certmanager.Manager{certmanager.New(client, webhookCfg)}
+	for _, svc := range cfg.Services {
+		svcCfg := base
+		svcCfg.Namespace = svc.Namespace
+		svcCfg.ServiceName = svc.ServiceName
+		svcCfg.CASecretName = svc.CASecretName
+		svcCfg.CertSecretName = svc.CertSecretName
+		svcCfg.CABundleConfigMapName = svc.CABundleConfigMapName
+		svcCfg.ExternalHostnames = svc.ExternalHostnames
+		managers = append(managers, certmanager.New(client, svcCfg))
+	}
+	return managers
+}
+
+// validateHeadlessService checks that the headless service and StatefulSet
+// names are DNS labels and that the pods to name are known.
+func validateHeadlessService(cfg *Config) error {
+	var errs []error
+	for _, name := range []struct{ field, value string }{
+		{"HeadlessServiceName", cfg.HeadlessServiceName},
+		{"StatefulSetName", cfg.StatefulSetName},
+	} {
+		if name.value == "" {
+			continue
+		}
+		if msgs := validation.IsDNS1123Label(name.value); len(msgs) > 0 {
+			errs = append(errs, fieldErrorf(name.field, "invalid name %q: %s", name.value, strings.Join(msgs, "; ")))
+		}
+	}
+	switch {
+	case cfg.StatefulSetName != "" && cfg.HeadlessServiceName == "":
+		errs = append(errs, withFields(errors.New("StatefulSet pod names require the headless service name"), "StatefulSetName", "HeadlessServiceName"))
+	case cfg.StatefulSetName != "" && cfg.StatefulSetReplicas <= 0:
+		errs = append(errs, withFields(fmt.Errorf("StatefulSet replicas must be positive to name its pods, got %d", cfg.StatefulSetReplicas), "StatefulSetReplicas", "StatefulSetName"))
+	case cfg.StatefulSetName == "" && cfg.StatefulSetReplicas != 0:
+		errs = append(errs, withFields(errors.New("StatefulSet replicas require the StatefulSet name"), "StatefulSetReplicas", "StatefulSetName"))
+	}
+	return errors.Join(errs...)
+}
+
+// validateExternalHostnames checks that the external hostnames are IP
+// addresses or DNS names, possibly wildcards.
+func validateExternalHostnames(hostnames []string) error {
+	var errs []error
+	for _, hostname := range hostnames {
+		if net.ParseIP(hostname) != nil {
+			continue
+		}
+		msgs := validation.IsDNS1123Subdomain(hostname)
+		if strings.HasPrefix(hostname, "*.") {
+			msgs = validation.IsWildcardDNS1123Subdomain(hostname)
+		}
+		if len(msgs) > 0 {
+			errs = append(errs, fmt.Errorf("invalid external hostname %q: %s", hostname, strings.Join(msgs, "; ")))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateCABundleNamespaces checks that the namespaces the CA bundle is
+// published to are valid namespace names and that their selector parses.
+func validateCABundleNamespaces(cfg *Config) error {
+	var errs []error
+	for _, ns := range cfg.CABundleNamespaces {
+		if msgs := validation.IsDNS1123Label(ns); len(msgs) > 0 {
+			errs = append(errs, fieldErrorf("CABundleNamespaces", "invalid CA bundle namespace %q: %s", ns, strings.Join(msgs, "; ")))
+		}
+	}
+	if _, err := caBundleNamespaceSelector(cfg); err != nil {
+		errs = append(errs, fieldErrorf("CABundleNamespaceSelector", "invalid CA bundle namespace selector: %v", err))
+	}
+	return errors.Join(errs...)
+}
+
+// validateStatusConfigMap checks that the status ConfigMap has a valid name
+// that is not used by a CA bundle.
+func validateStatusConfigMap(cfg *Config) error {
+	name := cfg.StatusConfigMapName
+	if name == "" {
+		return nil
+	}
+	if msgs := validation.IsDNS1123Subdomain(name); len(msgs) > 0 {
+		return fieldErrorf("StatusConfigMapName", "invalid status ConfigMap name %q: %s", name, strings.Join(msgs, ", "))
+	}
+	used := name == cfg.CABundleConfigMapName
+	for _, svc := range cfg.Services {
+		used = used || (svc.Namespace == cfg.Namespace && name == svc.CABundleConfigMapName)
+	}
+	if used {
+		return withFields(errors.New("the status ConfigMap must not be a CA bundle ConfigMap"), "StatusConfigMapName", "CABundleConfigMapName")
+	}
+	return nil
+}
+
+// caBundleNamespaceSelector returns the selector of the namespaces the CA
+// bundle is published to, nil if there is none.
+func caBundleNamespaceSelector(cfg *Config) (labels.Selector, error) {
+	if cfg.CABundleNamespaceSelector == "" {
+		return nil, nil
+	}
+	selector, err := parseLabelSelector(cfg.CABundleNamespaceSelector)
+	if err != nil {
+		return nil, err
+	}
+	return metav1.LabelSelectorAsSelector(selector)
+}
+
+// caSubject returns the subject of the CA certificates.
+func caSubject(cfg *Config) certmanager.Subject {
+	return certmanager.Subject{
+		Organization:       cfg.CASubjectOrganization,
+		OrganizationalUnit: cfg.CASubjectOrganizationalUnit,
+		CommonName:         cfg.CASubjectCommonName,
+	}
+}
+
+// servingSubject returns the subject of the serving certificates.
+func servingSubject(cfg *Config) certmanager.Subject {
+	return certmanager.Subject{
+		Organization:       cfg.ServingSubjectOrganization,
+		OrganizationalUnit: cfg.ServingSubjectOrganizationalUnit,
+		CommonName:         cfg.ServingSubjectCommonName,
+	}
+}
+
+// validateServices checks that the additional services are valid and do not
+// share certificate resources with each other or the webhook.
+func validateServices(cfg *Config) error {
+	type resource struct{ kind, namespace, name string }
+	seen := map[resource]string{
+		{"secret", cfg.Namespace, cfg.CASecretName}:             "webhook",
+		{"secret", cfg.Namespace, cfg.CertSecretName}:           "webhook",
+		{"configmap", cfg.Namespace, cfg.CABundleConfigMapName}: "webhook",
+	}
+	var errs []error
+	for i, svc := range cfg.Services {
+		if svc.ServiceName == "" {
+			errs = append(errs, fmt.Errorf("services[%d]: service name is required", i))
+		} else if msgs := validation.IsDNS1035Label(svc.ServiceName); len(msgs) > 0 {
+			errs = append(errs, fmt.Errorf("services[%d]: invalid service name %q: %s", i, svc.ServiceName, strings.Join(msgs, ", ")))
+		}
+		owner := fmt.Sprintf("services[%d]", i)
+		if err := validateExternalHostnames(svc.ExternalHostnames); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", owner, err))
+		}
+		for _, r := range []resource{
+			{"secret", svc.Namespace, svc.CASecretName},
+			{"secret", svc.Namespace, svc.CertSecretName},
+			{"configmap", svc.Namespace, svc.CABundleConfigMapName},
+		} {
+			if prev, ok := seen[r]; ok {
+				errs = append(errs, fmt.Errorf("%s: %s %s/%s is already used by %s", owner, r.kind, r.namespace, r.name, prev))
+				continue
+			}
+			seen[r] = owner
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateSelfTest validates the self-test settings, which must provide the
+// credentials the hooks require.
+func validateSelfTest(cfg *Config, certOnly bool) error {
+	var errs []error
+	if cfg.SelfTestInterval < 0 {
+		errs = append(errs, fieldErrorf("SelfTestInterval", "self-test interval must not be negative, got %v", cfg.SelfTestInterval))
+	} else if cfg.SelfTestInterval > 0 && certOnly {
+		errs = append(errs, withFields(errors.New("self-tests require the hooks to be served, not cert-only mode"), "SelfTestInterval", "CertOnly"))
+	}
+	if (cfg.SelfTestClientCertFile == "") != (cfg.SelfTestClientKeyFile == "") {
+		errs = append(errs, withFields(errors.New("self-test client certificate and key must be set together"), "SelfTestClientCertFile", "SelfTestClientKeyFile"))
+	} else if cfg.SelfTestInterval > 0 && cfg.SelfTestClientCertFile == "" && (cfg.ClientCAFile != "" || cfg.ClientCAConfigMap != "") {
+		errs = append(errs, withFields(errors.New("self-tests require a client certificate when client certificates are required"), "SelfTestInterval", "SelfTestClientCertFile"))
+	}
+	return errors.Join(errs...)
+}
+
+// validateHealthChecks validates the readiness or liveness checks in the
+// Config field named field.
+func validateHealthChecks(field string, checks []HealthCheck, certOnly bool) error {
+	if len(checks) > 0 && certOnly {
+		return withFields(fmt.Errorf("%s require the webhook server, not cert-only mode", field), field, "CertOnly")
+	}
+	var errs []error
+	seen := map[string]bool{}
+	for i, c := range checks {
+		switch {
+		case c.Name == "":
+			errs = append(errs, fieldErrorf(field, "%s[%d]: name is required", field, i))
+		case seen[c.Name]:
+			errs = append(errs, fieldErrorf(field, "%s[%d]: duplicate name %q", field, i, c.Name))
+		}
+		seen[c.Name] = true
+		if c.Check == nil {
+			errs = append(errs, fieldErrorf(field, "%s[%d]: check is required", field, i))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func startCertManagement(ctx context.Context, sup *supervisor, certMgrs []*certmanager.Manager, caBundleSyncer *cabundle.Syncer, selfTest *selfTester, statusReport *statusReporter) {
+	for i, certMgr := range certMgrs {
+		name := "cert-manager"
+		if i > 0 {
+			name = "cert-manager/" + certMgr.Namespace() + "/" + certMgr.ServiceName()
+		}
+		sup.Go(ctx, name, certMgr.Start)
+	}
+	sup.Go(ctx, "cabundle-syncer", caBundleSyncer.Start)
+	if selfTest != nil {
+		sup.Go(ctx, "self-test", selfTest.Run)
+	}
+	if statusReport != nil {
+		sup.Go(ctx, "status-report", statusReport.Run)
+	}
 }
 
 // validateCertDurations validates that certificate duration configurations are valid.
 func validateCertDurations(cfg *Config) error {
+	var errs []error
 	if cfg.CAValidity <= 0 {
-		return fmt.Errorf("CA validity must be positive, got %v", cfg.CAValidity)
+		errs = append(errs, fieldErrorf("CAValidity", "CA validity must be positive, got %v", cfg.CAValidity))
 	}
 	if cfg.CARefresh <= 0 {
-		return fmt.Errorf("CA refresh must be positive, got %v", cfg.CARefresh)
+		errs = append(errs, fieldErrorf("CARefresh", "CA refresh must be positive, got %v", cfg.CARefresh))
 	}
 	if cfg.CertValidity <= 0 {
-		return fmt.Errorf("cert validity must be positive, got %v", cfg.CertValidity)
+		errs = append(errs, fieldErrorf("CertValidity", "cert validity must be positive, got %v", cfg.CertValidity))
 	}
 	if cfg.CertRefresh <= 0 {
-		return fmt.Errorf("cert refresh must be positive, got %v", cfg.CertRefresh)
+		errs = append(errs, fieldErrorf("CertRefresh", "cert refresh must be positive, got %v", cfg.CertRefresh))
+	}
+	if cfg.CAValidity > 0 && cfg.CARefresh > 0 && cfg.CARefresh >= cfg.CAValidity {
+		errs = append(errs, withFields(fmt.Errorf("CA refresh (%v) must be less than CA validity (%v)", cfg.CARefresh, cfg.CAValidity), "CARefresh", "CAValidity"))
 	}
-	if cfg.CARefresh >= cfg.CAValidity {
-		return fmt.Errorf("CA refresh (%v) must be less than CA validity (%v)", cfg.CARefresh, cfg.CAValidity)
+	if cfg.CertValidity > 0 && cfg.CertRefresh > 0 && cfg.CertRefresh >= cfg.CertValidity {
+		errs = append(errs, withFields(fmt.Errorf("cert refresh (%v) must be less than cert validity (%v)", cfg.CertRefresh, cfg.CertValidity), "CertRefresh", "CertValidity"))
 	}
-	if cfg.CertRefresh >= cfg.CertValidity {
-		return fmt.Errorf("cert refresh (%v) must be less than cert validity (%v)", cfg.CertRefresh, cfg.CertValidity)
+	if cfg.CAOverlap < 0 {
+		errs = append(errs, fieldErrorf("CAOverlap", "CA overlap must not be negative, got %v", cfg.CAOverlap))
+	} else if cfg.CAOverlap > 0 && cfg.CARefresh > 0 && cfg.CARefresh < cfg.CAValidity && cfg.CAOverlap > cfg.CAValidity-cfg.CARefresh {
+		errs = append(errs, withFields(fmt.Errorf("CA overlap (%v) must not exceed CA validity minus CA refresh (%v), when the previous CA expires", cfg.CAOverlap, cfg.CAValidity-cfg.CARefresh), "CAOverlap", "CAValidity", "CARefresh"))
+	}
+	return errors.Join(errs...)
+}
+
+// validateCertSyncInterval checks that the certificates are synced often
+// enough to be rotated between their refresh and their expiry.
+func validateCertSyncInterval(cfg *Config) error {
+	if cfg.CertSyncInterval <= 0 {
+		return fieldErrorf("CertSyncInterval", "cert sync interval must be positive, got %v", cfg.CertSyncInterval)
+	}
+	var errs []error
+	check := func(kind string, validity, refresh time.Duration, fields ...string) {
+		// Invalid durations are reported by validateCertDurations
+		if refresh <= 0 || refresh >= validity || cfg.CertSyncInterval < validity-refresh {
+			return
+		}
+		errs = append(errs, withFields(fmt.Errorf("cert sync interval (%v) must be less than %s validity minus refresh (%v), or certificates may expire between syncs",
+			cfg.CertSyncInterval, kind, validity-refresh), append([]string{"CertSyncInterval"}, fields...)...))
+	}
+	check("CA", cfg.CAValidity, cfg.CARefresh, "CAValidity", "CARefresh")
+	check("cert", cfg.CertValidity, cfg.CertRefresh, "CertValidity", "CertRefresh")
+	return errors.Join(errs...)
+}
+
+// validateDataKeys checks that the data keys are valid Secret and ConfigMap
+// keys, that the certificate and key do not share one, and that they fit the
+// secret type.
+func validateDataKeys(cfg *Config) error {
+	var errs []error
+	for _, key := range []struct{ field, value string }{
+		{"CertDataKey", cfg.CertDataKey},
+		{"KeyDataKey", cfg.KeyDataKey},
+		{"CADataKey", cfg.CADataKey},
+		{"CABundleDataKey", cfg.CABundleDataKey},
+	} {
+		if msgs := validation.IsConfigMapKey(key.value); len(msgs) > 0 {
+			errs = append(errs, fieldErrorf(key.field, "invalid data key %q: %s", key.value, strings.Join(msgs, "; ")))
+		}
+	}
+	if cfg.CertDataKey == cfg.KeyDataKey {
+		errs = append(errs, withFields(fmt.Errorf("certificate and key data keys must differ, both are %q", cfg.CertDataKey), "CertDataKey", "KeyDataKey"))
+	}
+	if cfg.CADataKey == cfg.CertDataKey || cfg.CADataKey == cfg.KeyDataKey {
+		errs = append(errs, fieldErrorf("CADataKey", "CA data key %q must differ from the certificate and key data keys", cfg.CADataKey))
+	}
+	switch secretType := corev1.SecretType(cfg.SecretType); {
+	case secretType != "" && !slices.Contains(certmanager.SecretTypes, secretType):
+		errs = append(errs, fieldErrorf("SecretType", "secret type must be one of %q, got %q", certmanager.SecretTypes, cfg.SecretType))
+	case secretType == corev1.SecretTypeTLS && (cfg.CertDataKey != corev1.TLSCertKey || cfg.KeyDataKey != corev1.TLSPrivateKeyKey):
+		errs = append(errs, withFields(fmt.Errorf("secrets of type %s must use the data keys %s and %s", secretType, corev1.TLSCertKey, corev1.TLSPrivateKeyKey),
+			"SecretType", "CertDataKey", "KeyDataKey"))
+	}
+	return errors.Join(errs...)
+}
+
+// validateRateLimit validates the admission request rate limit configuration.
+func validateRateLimit(cfg *Config) error {
+	var errs []error
+	switch cfg.RateLimitBy {
+	case server.RateLimitByPath, server.RateLimitByUser, server.RateLimitByNamespace:
+	default:
+		errs = append(errs, fieldErrorf("RateLimitBy", "rate limit key must be one of %q, %q or %q, got %q",
+			server.RateLimitByPath, server.RateLimitByUser, server.RateLimitByNamespace, cfg.RateLimitBy))
+	}
+	if cfg.RateLimitQPS > 0 && cfg.RateLimitBurst <= 0 {
+		errs = append(errs, fieldErrorf("RateLimitBurst", "rate limit burst must be positive, got %d", cfg.RateLimitBurst))
+	}
+	return errors.Join(errs...)
+}
+
+// buildTLSPolicy parses and validates the TLS settings of the webhook server.
+func buildTLSPolicy(cfg *Config) (server.TLSPolicy, error) {
+	var errs []error
+	minVersion, err := server.ParseTLSVersion(cfg.TLSMinVersion)
+	if err != nil {
+		errs = append(errs, fieldErrorf("TLSMinVersion", "invalid TLS min version: %w", err))
+	}
+	maxVersion, err := server.ParseTLSVersion(cfg.TLSMaxVersion)
+	if err != nil {
+		errs = append(errs, fieldErrorf("TLSMaxVersion", "invalid TLS max version: %w", err))
+	}
+	if minVersion != 0 && maxVersion != 0 && minVersion > maxVersion {
+		errs = append(errs, withFields(fmt.Errorf("TLS min version %s must not exceed max version %s", cfg.TLSMinVersion, cfg.TLSMaxVersion), "TLSMinVersion", "TLSMaxVersion"))
+	}
+	cipherSuites, err := server.ParseCipherSuites(cfg.TLSCipherSuites)
+	if err != nil {
+		errs = append(errs, fieldErrorf("TLSCipherSuites", "invalid TLS cipher suites: %w", err))
+	}
+	curves, err := server.ParseCurvePreferences(cfg.TLSCurvePreferences)
+	if err != nil {
+		errs = append(errs, fieldErrorf("TLSCurvePreferences", "invalid TLS curve preferences: %w", err))
+	}
+	if len(errs) > 0 {
+		return server.TLSPolicy{}, errors.Join(errs...)
+	}
+
+	return server.TLSPolicy{
+		MinVersion:       minVersion,
+		MaxVersion:       maxVersion,
+		CipherSuites:     cipherSuites,
+		CurvePreferences: curves,
+	}, nil
+}
+
+// validateBindAddress validates the bind address of the webhook server.
+func validateBindAddress(address string) error {
+	if path, ok := strings.CutPrefix(address, "unix://"); ok {
+		if path == "" {
+			return fmt.Errorf("bind address %q has no socket path", address)
+		}
+		return nil
+	}
+	if address == "" {
+		return nil
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(address, "["), "]")
+	if net.ParseIP(host) == nil && len(validation.IsDNS1123Subdomain(host)) > 0 {
+		return fmt.Errorf("bind address must be an IP address, a host name or unix://<path>, got %q", address)
 	}
 	return nil
 }
 
-// determineWebhookRefs determines webhook references for CA bundle syncing.
+// validateHookPorts validates that hook ports can be listened on alongside
+// the main and metrics ports.
+func validateHookPorts(cfg *Config, hooks []Hook) error {
+	metricsEnabled := cfg.MetricsEnabled == nil || *cfg.MetricsEnabled
+	if cfg.MetricsOnWebhookServer != nil && *cfg.MetricsOnWebhookServer {
+		metricsEnabled = false
+	}
+	var errs []error
+	for i, hook := range hooks {
+		if hook.Port == 0 || hook.Port == cfg.Port {
+			continue
+		}
+		if strings.HasPrefix(cfg.BindAddress, "unix://") {
+			errs = append(errs, fieldErrorf("BindAddress", "hook[%d]: port cannot be combined with a Unix domain socket bind address", i))
+		}
+		if metricsEnabled && hook.Port == cfg.MetricsPort {
+			errs = append(errs, fieldErrorf("MetricsPort", "hook[%d]: port %d is already used by the metrics server", i, hook.Port))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateWebhookEntryNames validates that the managed webhook entries, one
+// per hook, have valid names, and distinct ones within their configuration.
+// Entries are named after the hook paths, which may map to the same name,
+// e.g. "/validate_pods" and "/validate-pods".
+func validateWebhookEntryNames(cfg *Config, hooks []Hook) error {
+	var errs []error
+	seen := make(map[webhookConfigurationKey]map[string]int)
+	for i, hook := range hooks {
+		if hook.Path == "" || hook.Path[0] != '/' {
+			continue
+		}
+		name := cabundle.EntryName(hook.Path, cfg.Name, cfg.Namespace)
+		if msgs := validation.IsDNS1123Subdomain(name); len(msgs) > 0 {
+			errs = append(errs, fmt.Errorf("hook[%d]: invalid webhook entry name %q derived from path %q: %s", i, name, hook.Path, strings.Join(msgs, "; ")))
+			continue
+		}
+		webhookType, _ := toWebhookType(hook.Type)
+		key := webhookConfigurationKey{name: webhookConfigurationName(cfg.Name, hook), webhookType: webhookType}
+		if seen[key] == nil {
+			seen[key] = make(map[string]int)
+		}
+		// Duplicate paths are reported on their own
+		if prev, exists := seen[key][name]; exists && hooks[prev].Path != hook.Path {
+			errs = append(errs, fmt.Errorf("hook[%d]: path %q maps to the webhook entry name %q of hook[%d] (path %q)", i, hook.Path, name, prev, hooks[prev].Path))
+			continue
+		}
+		seen[key][name] = i
+	}
+	return errors.Join(errs...)
+}
+
+// listenNetwork returns the listener network for an IP family preference.
+func listenNetwork(ipFamily string) (string, error) {
+	switch strings.ToLower(ipFamily) {
+	case "":
+		return "tcp", nil
+	case "ipv4":
+		return "tcp4", nil
+	case "ipv6":
+		return "tcp6", nil
+	default:
+		return "", fmt.Errorf("IP family must be IPv4 or IPv6, got %q", ipFamily)
+	}
+}
+
+// validateMetrics checks the latency buckets, that scrapes are only
+// authenticated over TLS, so that bearer tokens are not sent in plaintext, and
+// that metrics served by the webhook server do not collide with its other
+// paths.
+func validateMetrics(cfg *Config, hooks []Hook, certOnly bool) error {
+	var errs []error
+	for i, bucket := range cfg.MetricsLatencyBuckets {
+		if bucket <= 0 || (i > 0 && bucket <= cfg.MetricsLatencyBuckets[i-1]) {
+			errs = append(errs, fieldErrorf("MetricsLatencyBuckets", "metrics latency buckets must be positive and increasing, got %v", cfg.MetricsLatencyBuckets))
+			break
+		}
+	}
+
+	onWebhookServer := cfg.MetricsOnWebhookServer != nil && *cfg.MetricsOnWebhookServer
+	authenticated := cfg.MetricsClientCAFile != "" ||
+		(cfg.MetricsTokenReviewAuthentication != nil && *cfg.MetricsTokenReviewAuthentication)
+	if authenticated && !onWebhookServer && (cfg.MetricsTLS == nil || !*cfg.MetricsTLS) {
+		errs = append(errs, fieldErrorf("MetricsTLS", "metrics authentication requires metrics TLS"))
+	}
+	if !onWebhookServer || (cfg.MetricsEnabled != nil && !*cfg.MetricsEnabled) {
+		return errors.Join(errs...)
+	}
+
+	if certOnly {
+		errs = append(errs, withFields(fmt.Errorf("metrics cannot be served on the webhook server in cert-only mode"), "MetricsOnWebhookServer", "CertOnly"))
+	}
+	if cfg.MetricsClientCAFile != "" {
+		errs = append(errs, fieldErrorf("MetricsClientCAFile", "metrics client CA file cannot be combined with metrics on the webhook server"))
+	}
+	if cfg.MetricsPath == cfg.HealthzPath || cfg.MetricsPath == cfg.ReadyzPath {
+		errs = append(errs, fieldErrorf("MetricsPath", "metrics path %s is already used by a health endpoint", cfg.MetricsPath))
+	}
+	for i, hook := range hooks {
+		if hook.Path == cfg.MetricsPath && (hook.Port == 0 || hook.Port == cfg.Port) {
+			errs = append(errs, fieldErrorf("MetricsPath", "hook[%d]: path %s is already used by metrics", i, hook.Path))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateHandlers validates the custom handlers and that their paths do not
+// collide with the other paths of the main listener.
+func validateHandlers(cfg *Config, hooks []Hook, certOnly bool) error {
+	if len(cfg.Handlers) == 0 {
+		return nil
+	}
+	if certOnly {
+		return withFields(errors.New("custom handlers require the webhook server, not cert-only mode"), "Handlers", "CertOnly")
+	}
+
+	used := map[string]string{
+		cfg.HealthzPath: "a health endpoint",
+		cfg.ReadyzPath:  "a health endpoint",
+	}
+	if cfg.MetricsOnWebhookServer != nil && *cfg.MetricsOnWebhookServer && (cfg.MetricsEnabled == nil || *cfg.MetricsEnabled) {
+		used[cfg.MetricsPath] = "metrics"
+	}
+	for i, hook := range hooks {
+		if hook.Port == 0 || hook.Port == cfg.Port {
+			used[hook.Path] = fmt.Sprintf("hook[%d]", i)
+		}
+	}
+	var errs []error
+	for i, h := range cfg.Handlers {
+		switch {
+		case !strings.HasPrefix(h.Path, "/"):
+			errs = append(errs, fieldErrorf("Handlers", "handlers[%d]: path must start with /, got %q", i, h.Path))
+		case used[h.Path] != "":
+			errs = append(errs, fieldErrorf("Handlers", "handlers[%d]: path %s is already used by %s", i, h.Path, used[h.Path]))
+		default:
+			used[h.Path] = fmt.Sprintf("handlers[%d]", i)
+		}
+		if h.Handler == nil {
+			errs = append(errs, fieldErrorf("Handlers", "handlers[%d]: handler is required", i))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateServeHandler checks that nothing configured with ServeHandler
+// needs the built-in webhook server.
+func validateServeHandler(cfg *Config, hooks []Hook, certOnly bool) error {
+	if cfg.ServeHandler == nil {
+		return nil
+	}
+	var errs []error
+	if certOnly {
+		errs = append(errs, withFields(errors.New("ServeHandler requires the admission handler, not cert-only mode"), "ServeHandler", "CertOnly"))
+	}
+	if cfg.ClientCAFile != "" || cfg.ClientCAConfigMap != "" {
+		errs = append(errs, withFields(errors.New("client certificates are verified by the built-in webhook server and cannot be combined with ServeHandler"), "ServeHandler", "ClientCAFile", "ClientCAConfigMap"))
+	}
+	for i, hook := range hooks {
+		if hook.Port != 0 && hook.Port != cfg.Port {
+			errs = append(errs, fieldErrorf("ServeHandler", "hook[%d]: port %d requires the built-in webhook server", i, hook.Port))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// parseClientCAConfigMap validates the client CA settings and splits
+// ClientCAConfigMap into its namespace and name.
+func parseClientCAConfigMap(cfg *Config) (namespace, name string, err error) {
+	if cfg.ClientCAConfigMap == "" {
+		return "", "", nil
+	}
+	if cfg.ClientCAFile != "" {
+		return "", "", withFields(fmt.Errorf("client CA file and client CA configmap are mutually exclusive"), "ClientCAFile", "ClientCAConfigMap")
+	}
+	namespace, name, ok := strings.Cut(cfg.ClientCAConfigMap, "/")
+	if !ok || namespace == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", fieldErrorf("ClientCAConfigMap", "client CA configmap must be \"namespace/name\", got %q", cfg.ClientCAConfigMap)
+	}
+	return namespace, name, nil
+}
+
+// determineWebhookRefs determines webhook references for CA bundle syncing:
+// one per webhook configuration the hooks belong to. name is the default
+// configuration name.
 func determineWebhookRefs(name string, hooks []Hook) []cabundle.WebhookRef {
 	var refs []cabundle.WebhookRef
-	seen := make(map[HookType]bool)
+	seen := make(map[webhookConfigurationKey]bool)
 
 	for _, hook := range hooks {
-		if seen[hook.Type] {
+		webhookType, ok := toWebhookType(hook.Type)
+		if !ok {
 			continue
 		}
-		seen[hook.Type] = true
-
-		var webhookType cabundle.WebhookType
-		switch hook.Type {
-		case Mutating:
-			webhookType = cabundle.MutatingWebhook
-		case Validating:
-			webhookType = cabundle.ValidatingWebhook
-		default:
+		key := webhookConfigurationKey{name: webhookConfigurationName(name, hook), webhookType: webhookType}
+		if seen[key] {
 			continue
 		}
+		seen[key] = true
 		refs = append(refs, cabundle.WebhookRef{
-			Name: name,
+			Name: key.name,
 			Type: webhookType,
 		})
 	}
 
 	return refs
 }
+
+// webhookConfigurationKey identifies a webhook configuration.
+type webhookConfigurationKey struct {
+	name        string
+	webhookType cabundle.WebhookType
+}
+
+// webhookConfigurationName returns the name of the webhook configuration of
+// a hook, given the default name.
+func webhookConfigurationName(name string, hook Hook) string {
+	if hook.WebhookConfigurationName != "" {
+		return hook.WebhookConfigurationName
+	}
+	return name
+}
+
+// toWebhookType converts a hook type to the corresponding cabundle webhook type.
+func toWebhookType(hookType HookType) (cabundle.WebhookType, bool) {
+	switch hookType {
+	case Mutating:
+		return cabundle.MutatingWebhook, true
+	case Validating:
+		return cabundle.ValidatingWebhook, true
+	default:
+		return "", false
+	}
+}
+
+// buildWebhookEntries builds the managed webhook entries of a webhook
+// configuration, one per enabled hook. The result is non-nil even if all hooks
+// are disabled, so that their entries are removed.
+func buildWebhookEntries(cfg *Config, hooks []Hook, ref cabundle.WebhookRef) []cabundle.WebhookEntry {
+	entries := []cabundle.WebhookEntry{}
+	for _, hook := range hooks {
+		if t, ok := toWebhookType(hook.Type); !ok || t != ref.Type || webhookConfigurationName(cfg.Name, hook) != ref.Name {
+			continue
+		}
+		if slices.Contains(cfg.DisabledHooks, hook.Path) {
+			continue
+		}
+
+		serviceName := hook.ServiceName
+		if serviceName == "" {
+			serviceName = cfg.ServiceName
+		}
+		servicePort := hook.ServicePort
+		if servicePort == 0 {
+			servicePort = cfg.ServicePort
+		}
+		servicePath := hook.ServicePath
+		if servicePath == "" {
+			servicePath = hook.Path
+		}
+
+		namespaceSelector := hook.NamespaceSelector
+		if excluded := excludedNamespaces(cfg); len(excluded) > 0 {
+			namespaceSelector = excludeLabel(namespaceSelector, corev1.LabelMetadataName, excluded...)
+		}
+		objectSelector := hook.ObjectSelector
+		if key, value, ok := strings.Cut(cfg.ExcludeOwnPodsLabel, "="); ok {
+			objectSelector = excludeLabel(objectSelector, key, value)
+		}
+
+		entries = append(entries, cabundle.WebhookEntry{
+			Name:               cabundle.EntryName(hook.Path, cfg.Name, cfg.Namespace),
+			ServiceName:        serviceName,
+			ServiceNamespace:   cfg.Namespace,
+			ServicePort:        servicePort,
+			ServicePath:        servicePath,
+			Rules:              hook.Rules,
+			FailurePolicy:      hook.FailurePolicy,
+			SideEffects:        hook.SideEffects,
+			TimeoutSeconds:     hook.TimeoutSeconds,
+			NamespaceSelector:  namespaceSelector,
+			ObjectSelector:     objectSelector,
+			ReinvocationPolicy: hook.ReinvocationPolicy,
+		})
+	}
+	return entries
+}
+
+// excludedNamespaces returns the namespaces excluded from managed webhook
+// entries: Config.ExcludeNamespaces and, unless disabled, the webhook's own.
+func excludedNamespaces(cfg *Config) []string {
+	excluded := slices.Clone(cfg.ExcludeNamespaces)
+	if (cfg.ExcludeOwnNamespace == nil || *cfg.ExcludeOwnNamespace) && !slices.Contains(excluded, cfg.Namespace) {
+		excluded = append(excluded, cfg.Namespace)
+	}
+	return excluded
+}
+
+// excludeLabel returns a copy of selector that also requires the label key
+// not to have any of the given values. Objects without the label still match.
+func excludeLabel(selector *metav1.LabelSelector, key string, values ...string) *metav1.LabelSelector {
+	if selector == nil {
+		selector = &metav1.LabelSelector{}
+	}
+	selector = selector.DeepCopy()
+	selector.MatchExpressions = append(selector.MatchExpressions, metav1.LabelSelectorRequirement{
+		Key:      key,
+		Operator: metav1.LabelSelectorOpNotIn,
+		Values:   values,
+	})
+	return selector
+}
+
+// validateLabel validates a "key=value" label.
+func validateLabel(label string) error {
+	key, value, ok := strings.Cut(label, "=")
+	if !ok {
+		return fmt.Errorf("label must be key=value, got %q", label)
+	}
+	if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+		return fmt.Errorf("invalid label key %q: %s", key, strings.Join(errs, "; "))
+	}
+	if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+		return fmt.Errorf("invalid label value %q: %s", value, strings.Join(errs, "; "))
+	}
+	return nil
+}
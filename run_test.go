@@ -1,10 +1,23 @@
 package autocertwebhook
 
 import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+
 	"github.com/jimyag/auto-cert-webhook/internal/cabundle"
 )
 
@@ -123,6 +136,38 @@ func TestApplyEnvConfig_Priority(t *testing.T) {
 	})
 }
 
+func TestApplyEnvConfig_CABundleResyncInterval(t *testing.T) {
+	defer os.Unsetenv("ACW_CA_BUNDLE_RESYNC_INTERVAL")
+
+	tests := []struct {
+		name string
+		env  string
+		code time.Duration
+		want time.Duration
+	}{
+		{name: "default", want: time.Hour},
+		{name: "disabled in code", code: -1, want: -1},
+		{name: "disabled in env", env: "-1s", want: -time.Second},
+		{name: "env", env: "5m", want: 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Unsetenv("ACW_CA_BUNDLE_RESYNC_INTERVAL")
+			if tt.env != "" {
+				os.Setenv("ACW_CA_BUNDLE_RESYNC_INTERVAL", tt.env)
+			}
+			cfg := Config{CABundleResyncInterval: tt.code}
+			if err := applyEnvConfig(&cfg); err != nil {
+				t.Fatalf("applyEnvConfig failed: %v", err)
+			}
+			// SetResyncInterval disables resyncs for values that are not positive
+			if cfg.CABundleResyncInterval != tt.want {
+				t.Errorf("CABundleResyncInterval: got %v, want %v", cfg.CABundleResyncInterval, tt.want)
+			}
+		})
+	}
+}
+
 func TestApplyEnvConfig_BoolPointers(t *testing.T) {
 	defer func() {
 		os.Unsetenv("ACW_METRICS_ENABLED")
@@ -190,6 +235,9 @@ func TestApplyDefaults_DynamicValues(t *testing.T) {
 		if cfg.LeaderElectionID != "my-webhook-leader" {
 			t.Errorf("LeaderElectionID: got %q, want %q", cfg.LeaderElectionID, "my-webhook-leader")
 		}
+		if !strings.HasPrefix(cfg.UserAgent, "auto-cert-webhook/") {
+			t.Errorf("UserAgent: got %q, want prefix %q", cfg.UserAgent, "auto-cert-webhook/")
+		}
 	})
 
 	t.Run("explicit values not overwritten", func(t *testing.T) {
@@ -200,6 +248,7 @@ func TestApplyDefaults_DynamicValues(t *testing.T) {
 			CertSecretName:        "custom-cert",
 			CABundleConfigMapName: "custom-bundle",
 			LeaderElectionID:      "custom-leader",
+			UserAgent:             "custom-agent",
 		}
 
 		applyDefaults(&cfg)
@@ -219,6 +268,9 @@ func TestApplyDefaults_DynamicValues(t *testing.T) {
 		if cfg.LeaderElectionID != "custom-leader" {
 			t.Errorf("LeaderElectionID: got %q, want %q", cfg.LeaderElectionID, "custom-leader")
 		}
+		if cfg.UserAgent != "custom-agent" {
+			t.Errorf("UserAgent: got %q, want %q", cfg.UserAgent, "custom-agent")
+		}
 	})
 }
 
@@ -507,6 +559,26 @@ func TestDetermineWebhookRefs(t *testing.T) {
 		}
 	})
 
+	t.Run("custom configuration names", func(t *testing.T) {
+		hooks := []Hook{
+			{Path: "/validate-pods", Type: Validating},
+			{Path: "/validate-images", Type: Validating, WebhookConfigurationName: "images"},
+			{Path: "/validate-quotas", Type: Validating, WebhookConfigurationName: "images"},
+			{Path: "/mutate-pods", Type: Mutating, WebhookConfigurationName: "images"},
+		}
+
+		refs := determineWebhookRefs("my-webhook", hooks)
+
+		want := []cabundle.WebhookRef{
+			{Name: "my-webhook", Type: cabundle.ValidatingWebhook},
+			{Name: "images", Type: cabundle.ValidatingWebhook},
+			{Name: "images", Type: cabundle.MutatingWebhook},
+		}
+		if !reflect.DeepEqual(refs, want) {
+			t.Errorf("Refs: got %+v, want %+v", refs, want)
+		}
+	})
+
 	t.Run("empty hooks", func(t *testing.T) {
 		refs := determineWebhookRefs("my-webhook", nil)
 
@@ -515,3 +587,935 @@ func TestDetermineWebhookRefs(t *testing.T) {
 		}
 	})
 }
+
+func TestBuildWebhookEntries(t *testing.T) {
+	cfg := &Config{
+		Name:        "my-webhook",
+		Namespace:   "webhook-system",
+		ServiceName: "my-webhook-svc",
+		ServicePort: 443,
+	}
+	rules := []admissionregistrationv1.RuleWithOperations{
+		{
+			Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+			Rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"pods"},
+			},
+		},
+	}
+	hooks := []Hook{
+		{Path: "/mutate-pods", Type: Mutating, Rules: rules, ReinvocationPolicy: ptr.To(admissionregistrationv1.IfNeededReinvocationPolicy)},
+		{Path: "/validate-pods", Type: Validating, Rules: rules},
+		{
+			Path:        "/validate-deployments",
+			Type:        Validating,
+			Rules:       rules,
+			ServiceName: "other-svc",
+			ServicePort: 9443,
+			ServicePath: "/proxy/validate-deployments",
+		},
+	}
+
+	t.Run("defaults from config", func(t *testing.T) {
+		entries := buildWebhookEntries(cfg, hooks, cabundle.WebhookRef{Name: "my-webhook", Type: cabundle.MutatingWebhook})
+
+		if len(entries) != 1 {
+			t.Fatalf("Expected 1 entry, got %d", len(entries))
+		}
+		entry := entries[0]
+		if entry.Name != "mutate-pods.my-webhook.webhook-system.svc" {
+			t.Errorf("Name: got %q", entry.Name)
+		}
+		if entry.ServiceName != "my-webhook-svc" || entry.ServiceNamespace != "webhook-system" {
+			t.Errorf("Service: got %s/%s", entry.ServiceNamespace, entry.ServiceName)
+		}
+		if entry.ServicePort != 443 {
+			t.Errorf("ServicePort: got %d, want %d", entry.ServicePort, 443)
+		}
+		if entry.ServicePath != "/mutate-pods" {
+			t.Errorf("ServicePath: got %q, want %q", entry.ServicePath, "/mutate-pods")
+		}
+		if len(entry.Rules) != 1 {
+			t.Errorf("Rules: got %d, want %d", len(entry.Rules), 1)
+		}
+		if entry.ReinvocationPolicy == nil || *entry.ReinvocationPolicy != admissionregistrationv1.IfNeededReinvocationPolicy {
+			t.Errorf("ReinvocationPolicy: got %v, want %v", entry.ReinvocationPolicy, admissionregistrationv1.IfNeededReinvocationPolicy)
+		}
+	})
+
+	t.Run("per-hook overrides", func(t *testing.T) {
+		entries := buildWebhookEntries(cfg, hooks, cabundle.WebhookRef{Name: "my-webhook", Type: cabundle.ValidatingWebhook})
+
+		if len(entries) != 2 {
+			t.Fatalf("Expected 2 entries, got %d", len(entries))
+		}
+		entry := entries[1]
+		if entry.ServiceName != "other-svc" {
+			t.Errorf("ServiceName: got %q, want %q", entry.ServiceName, "other-svc")
+		}
+		if entry.ServicePort != 9443 {
+			t.Errorf("ServicePort: got %d, want %d", entry.ServicePort, 9443)
+		}
+		if entry.ServicePath != "/proxy/validate-deployments" {
+			t.Errorf("ServicePath: got %q, want %q", entry.ServicePath, "/proxy/validate-deployments")
+		}
+	})
+
+	t.Run("disabled hooks", func(t *testing.T) {
+		cfg := *cfg
+		cfg.DisabledHooks = []string{"/mutate-pods", "/validate-pods"}
+
+		entries := buildWebhookEntries(&cfg, hooks, cabundle.WebhookRef{Name: "my-webhook", Type: cabundle.ValidatingWebhook})
+		if len(entries) != 1 || entries[0].ServicePath != "/proxy/validate-deployments" {
+			t.Errorf("Expected only the enabled validating hook, got %+v", entries)
+		}
+		// No entries still manages the configuration, removing the disabled ones
+		if entries := buildWebhookEntries(&cfg, hooks, cabundle.WebhookRef{Name: "my-webhook", Type: cabundle.MutatingWebhook}); entries == nil || len(entries) != 0 {
+			t.Errorf("Expected empty non-nil entries, got %#v", entries)
+		}
+	})
+
+	t.Run("custom configuration names", func(t *testing.T) {
+		hooks := []Hook{
+			{Path: "/validate-pods", Type: Validating, Rules: rules},
+			{Path: "/validate-images", Type: Validating, Rules: rules, WebhookConfigurationName: "images"},
+		}
+
+		entries := buildWebhookEntries(cfg, hooks, cabundle.WebhookRef{Name: "images", Type: cabundle.ValidatingWebhook})
+		if len(entries) != 1 || entries[0].ServicePath != "/validate-images" {
+			t.Errorf("Expected only the hook of the configuration, got %+v", entries)
+		}
+		entries = buildWebhookEntries(cfg, hooks, cabundle.WebhookRef{Name: "my-webhook", Type: cabundle.ValidatingWebhook})
+		if len(entries) != 1 || entries[0].ServicePath != "/validate-pods" {
+			t.Errorf("Expected only the hook of the default configuration, got %+v", entries)
+		}
+	})
+
+	t.Run("self exclusion", func(t *testing.T) {
+		hooks := []Hook{{
+			Path:              "/mutate-pods",
+			Type:              Mutating,
+			Rules:             rules,
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+		}}
+		cfg := *cfg
+		cfg.ExcludeNamespaces = []string{"kube-system"}
+		cfg.ExcludeOwnPodsLabel = "app=my-webhook"
+
+		entry := buildWebhookEntries(&cfg, hooks, cabundle.WebhookRef{Name: "my-webhook", Type: cabundle.MutatingWebhook})[0]
+		wantNamespaces := &metav1.LabelSelector{
+			MatchLabels: map[string]string{"team": "a"},
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "kubernetes.io/metadata.name", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"kube-system", "webhook-system"}},
+			},
+		}
+		if !reflect.DeepEqual(entry.NamespaceSelector, wantNamespaces) {
+			t.Errorf("NamespaceSelector: got %+v, want %+v", entry.NamespaceSelector, wantNamespaces)
+		}
+		wantObjects := &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "app", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"my-webhook"}},
+		}}
+		if !reflect.DeepEqual(entry.ObjectSelector, wantObjects) {
+			t.Errorf("ObjectSelector: got %+v, want %+v", entry.ObjectSelector, wantObjects)
+		}
+		if len(hooks[0].NamespaceSelector.MatchExpressions) != 0 {
+			t.Error("The selector of the hook was modified")
+		}
+
+		cfg.ExcludeOwnNamespace = ptr.To(false)
+		cfg.ExcludeNamespaces = []string{}
+		if entry := buildWebhookEntries(&cfg, hooks, cabundle.WebhookRef{Name: "my-webhook", Type: cabundle.MutatingWebhook})[0]; entry.NamespaceSelector != hooks[0].NamespaceSelector {
+			t.Errorf("NamespaceSelector: got %+v, want the hook's", entry.NamespaceSelector)
+		}
+	})
+}
+
+func TestValidateLabel(t *testing.T) {
+	for label, valid := range map[string]bool{
+		"app.kubernetes.io/name=my-webhook": true,
+		"app=":                              true,
+		"app":                               false,
+		"-app=x":                            false,
+		"app=-x-":                           false,
+	} {
+		if err := validateLabel(label); (err == nil) != valid {
+			t.Errorf("validateLabel(%q) = %v, want valid %v", label, err, valid)
+		}
+	}
+}
+
+func TestValidateRateLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "disabled", cfg: Config{RateLimitBy: "path"}},
+		{name: "per user", cfg: Config{RateLimitQPS: 10, RateLimitBurst: 20, RateLimitBy: "user"}},
+		{name: "per namespace", cfg: Config{RateLimitQPS: 10, RateLimitBurst: 20, RateLimitBy: "namespace"}},
+		{name: "unknown key", cfg: Config{RateLimitBy: "pod"}, wantErr: true},
+		{name: "zero burst", cfg: Config{RateLimitQPS: 10, RateLimitBy: "path"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRateLimit(&tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateRateLimit: got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildTLSPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "defaults", cfg: Config{TLSMinVersion: "1.2"}},
+		{name: "TLS 1.3 only", cfg: Config{TLSMinVersion: "1.3", TLSMaxVersion: "1.3"}},
+		{name: "suites and curves", cfg: Config{
+			TLSCipherSuites:     []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"},
+			TLSCurvePreferences: []string{"X25519", "P384"},
+		}},
+		{name: "min above max", cfg: Config{TLSMinVersion: "1.3", TLSMaxVersion: "1.2"}, wantErr: true},
+		{name: "invalid min", cfg: Config{TLSMinVersion: "2"}, wantErr: true},
+		{name: "invalid suite", cfg: Config{TLSCipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}}, wantErr: true},
+		{name: "invalid curve", cfg: Config{TLSCurvePreferences: []string{"P224"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := buildTLSPolicy(&tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("buildTLSPolicy: got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseClientCAConfigMap(t *testing.T) {
+	tests := []struct {
+		name          string
+		cfg           Config
+		wantNamespace string
+		wantName      string
+		wantErr       bool
+	}{
+		{name: "unset", cfg: Config{}},
+		{name: "file only", cfg: Config{ClientCAFile: "/etc/ca.crt"}},
+		{
+			name:          "configmap",
+			cfg:           Config{ClientCAConfigMap: "kube-system/extension-apiserver-authentication"},
+			wantNamespace: "kube-system",
+			wantName:      "extension-apiserver-authentication",
+		},
+		{name: "both", cfg: Config{ClientCAFile: "/etc/ca.crt", ClientCAConfigMap: "kube-system/ca"}, wantErr: true},
+		{name: "missing namespace", cfg: Config{ClientCAConfigMap: "ca"}, wantErr: true},
+		{name: "empty name", cfg: Config{ClientCAConfigMap: "kube-system/"}, wantErr: true},
+		{name: "extra segment", cfg: Config{ClientCAConfigMap: "kube-system/ca/x"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			namespace, name, err := parseClientCAConfigMap(&tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseClientCAConfigMap: got error %v, want error %v", err, tt.wantErr)
+			}
+			if namespace != tt.wantNamespace || name != tt.wantName {
+				t.Errorf("parseClientCAConfigMap: got %s/%s, want %s/%s", namespace, name, tt.wantNamespace, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestValidateMetrics(t *testing.T) {
+	webhookServer := Config{Port: 8443, MetricsPath: "/metrics", HealthzPath: "/healthz", ReadyzPath: "/readyz", MetricsOnWebhookServer: ptr.To(true)}
+	withConfig := func(modify func(*Config)) Config {
+		cfg := webhookServer
+		modify(&cfg)
+		return cfg
+	}
+	tests := []struct {
+		name     string
+		cfg      Config
+		hooks    []Hook
+		certOnly bool
+		wantErr  bool
+	}{
+		{name: "unset", cfg: Config{}},
+		{name: "tls only", cfg: Config{MetricsTLS: ptr.To(true)}},
+		{name: "client CA over TLS", cfg: Config{MetricsTLS: ptr.To(true), MetricsClientCAFile: "/etc/ca.crt"}},
+		{name: "token over TLS", cfg: Config{MetricsTLS: ptr.To(true), MetricsTokenReviewAuthentication: ptr.To(true)}},
+		{name: "client CA without TLS", cfg: Config{MetricsClientCAFile: "/etc/ca.crt"}, wantErr: true},
+		{name: "token without TLS", cfg: Config{MetricsTLS: ptr.To(false), MetricsTokenReviewAuthentication: ptr.To(true)}, wantErr: true},
+		{name: "webhook server", cfg: webhookServer, hooks: []Hook{{Path: "/validate"}, {Path: "/metrics", Port: 9443}}},
+		{name: "token on webhook server", cfg: withConfig(func(c *Config) { c.MetricsTokenReviewAuthentication = ptr.To(true) })},
+		{name: "client CA on webhook server", cfg: withConfig(func(c *Config) { c.MetricsClientCAFile = "/etc/ca.crt" }), wantErr: true},
+		{name: "webhook server in cert-only mode", cfg: webhookServer, certOnly: true, wantErr: true},
+		{name: "health path", cfg: withConfig(func(c *Config) { c.MetricsPath = "/healthz" }), wantErr: true},
+		{name: "hook path", cfg: webhookServer, hooks: []Hook{{Path: "/metrics"}}, wantErr: true},
+		{name: "disabled", cfg: withConfig(func(c *Config) { c.MetricsEnabled = ptr.To(false) }), certOnly: true},
+		{name: "latency buckets", cfg: Config{MetricsLatencyBuckets: []float64{0.0005, 0.001, 0.01}}},
+		{name: "unsorted latency buckets", cfg: Config{MetricsLatencyBuckets: []float64{0.01, 0.001}}, wantErr: true},
+		{name: "zero latency bucket", cfg: Config{MetricsLatencyBuckets: []float64{0, 0.001}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateMetrics(&tt.cfg, tt.hooks, tt.certOnly); (err != nil) != tt.wantErr {
+				t.Errorf("validateMetrics: got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateHandlers(t *testing.T) {
+	handler := http.NotFoundHandler()
+	base := Config{Port: 8443, MetricsPath: "/metrics", HealthzPath: "/healthz", ReadyzPath: "/readyz"}
+	withHandlers := func(handlers ...HTTPHandler) Config {
+		cfg := base
+		cfg.Handlers = handlers
+		return cfg
+	}
+	tests := []struct {
+		name     string
+		cfg      Config
+		hooks    []Hook
+		certOnly bool
+		wantErr  bool
+	}{
+		{name: "unset", cfg: base, certOnly: true},
+		{name: "handlers", cfg: withHandlers(HTTPHandler{Path: "/version", Handler: handler}, HTTPHandler{Path: "/docs/", Handler: handler}), hooks: []Hook{{Path: "/validate"}}},
+		{name: "metrics path without metrics on the webhook server", cfg: withHandlers(HTTPHandler{Path: "/metrics", Handler: handler})},
+		{name: "hook path on another port", cfg: withHandlers(HTTPHandler{Path: "/validate", Handler: handler}), hooks: []Hook{{Path: "/validate", Port: 9443}}},
+		{name: "cert-only mode", cfg: withHandlers(HTTPHandler{Path: "/version", Handler: handler}), certOnly: true, wantErr: true},
+		{name: "relative path", cfg: withHandlers(HTTPHandler{Path: "version", Handler: handler}), wantErr: true},
+		{name: "missing handler", cfg: withHandlers(HTTPHandler{Path: "/version"}), wantErr: true},
+		{name: "health path", cfg: withHandlers(HTTPHandler{Path: "/readyz", Handler: handler}), wantErr: true},
+		{name: "hook path", cfg: withHandlers(HTTPHandler{Path: "/validate", Handler: handler}), hooks: []Hook{{Path: "/validate"}}, wantErr: true},
+		{name: "duplicate path", cfg: withHandlers(HTTPHandler{Path: "/version", Handler: handler}, HTTPHandler{Path: "/version", Handler: handler}), wantErr: true},
+		{name: "metrics path", cfg: func() Config {
+			cfg := withHandlers(HTTPHandler{Path: "/metrics", Handler: handler})
+			cfg.MetricsOnWebhookServer = ptr.To(true)
+			return cfg
+		}(), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateHandlers(&tt.cfg, tt.hooks, tt.certOnly); (err != nil) != tt.wantErr {
+				t.Errorf("validateHandlers: got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateBindAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		wantErr bool
+	}{
+		{address: ""},
+		{address: "127.0.0.1"},
+		{address: "::1"},
+		{address: "[::1]"},
+		{address: "localhost"},
+		{address: "unix:///var/run/webhook.sock"},
+		{address: "unix://", wantErr: true},
+		{address: "127.0.0.1:8443", wantErr: true},
+		{address: "not a host", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			err := validateBindAddress(tt.address)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateBindAddress(%q): got error %v, want error %v", tt.address, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestListenNetwork(t *testing.T) {
+	tests := map[string]string{
+		"":     "tcp",
+		"IPv4": "tcp4",
+		"ipv6": "tcp6",
+		"IPv6": "tcp6",
+	}
+	for family, want := range tests {
+		got, err := listenNetwork(family)
+		if err != nil {
+			t.Errorf("listenNetwork(%q) failed: %v", family, err)
+		}
+		if got != want {
+			t.Errorf("listenNetwork(%q): got %q, want %q", family, got, want)
+		}
+	}
+
+	if _, err := listenNetwork("dual"); err == nil {
+		t.Error("Expected error for unknown family")
+	}
+}
+
+func TestValidateHookPorts(t *testing.T) {
+	disabled := false
+	tests := []struct {
+		name    string
+		cfg     Config
+		hooks   []Hook
+		wantErr bool
+	}{
+		{name: "main port", cfg: Config{Port: 8443, MetricsPort: 8080}, hooks: []Hook{{Port: 0}, {Port: 8443}}},
+		{name: "separate port", cfg: Config{Port: 8443, MetricsPort: 8080}, hooks: []Hook{{Port: 9443}}},
+		{name: "metrics port", cfg: Config{Port: 8443, MetricsPort: 8080}, hooks: []Hook{{Port: 8080}}, wantErr: true},
+		{name: "metrics disabled", cfg: Config{Port: 8443, MetricsPort: 8080, MetricsEnabled: &disabled}, hooks: []Hook{{Port: 8080}}},
+		{name: "metrics on webhook server", cfg: Config{Port: 8443, MetricsPort: 8080, MetricsOnWebhookServer: ptr.To(true)}, hooks: []Hook{{Port: 8080}}},
+		{name: "unix socket", cfg: Config{BindAddress: "unix:///tmp/wh.sock", Port: 8443}, hooks: []Hook{{Port: 9443}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateHookPorts(&tt.cfg, tt.hooks)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateHookPorts: got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateWebhookEntryNames(t *testing.T) {
+	cfg := &Config{Name: "wh", Namespace: "ns"}
+	tests := []struct {
+		name    string
+		hooks   []Hook
+		wantErr bool
+	}{
+		{name: "distinct paths", hooks: []Hook{{Path: "/validate-pods", Type: Validating}, {Path: "/validate-deployments", Type: Validating}}},
+		{name: "same name of different types", hooks: []Hook{{Path: "/pods", Type: Validating}, {Path: "/pods/", Type: Mutating}}},
+		{name: "colliding paths", hooks: []Hook{{Path: "/validate-pods", Type: Validating}, {Path: "/validate_pods", Type: Validating}}, wantErr: true},
+		{name: "colliding paths in different configurations", hooks: []Hook{{Path: "/validate-pods", Type: Validating}, {Path: "/validate_pods", Type: Validating, WebhookConfigurationName: "other"}}},
+		{name: "duplicate path", hooks: []Hook{{Path: "/validate", Type: Validating}, {Path: "/validate", Type: Validating}}},
+		{name: "name too long", hooks: []Hook{{Path: "/" + strings.Repeat("a", 250), Type: Validating}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateWebhookEntryNames(cfg, tt.hooks)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateWebhookEntryNames: got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTrimUnmanagedData(t *testing.T) {
+	cfg := &Config{
+		CASecretName:          "webhook-ca",
+		CertSecretName:        "webhook-cert",
+		CABundleConfigMapName: "webhook-ca-bundle",
+		Namespace:             "ns",
+		Services: []ServiceCertificate{
+			{ServiceName: "svc", Namespace: "ns", CASecretName: "svc-ca", CertSecretName: "svc-cert", CABundleConfigMapName: "svc-ca-bundle"},
+		},
+		KeystorePasswordSecretName: "webhook-keystore",
+	}
+	transform := trimUnmanagedData(cfg)
+
+	tests := []struct {
+		name     string
+		obj      interface{}
+		wantData bool
+	}{
+		{"CA secret", &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "webhook-ca"}, Data: map[string][]byte{"k": nil}}, true},
+		{"cert secret", &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "webhook-cert"}, Data: map[string][]byte{"k": nil}}, true},
+		{"service cert secret", &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "svc-cert"}, Data: map[string][]byte{"k": nil}}, true},
+		{"keystore password secret", &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "webhook-keystore"}, Data: map[string][]byte{"k": nil}}, true},
+		{"other secret", &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "other"}, Data: map[string][]byte{"k": nil}}, false},
+		{"CA bundle configmap", &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "webhook-ca-bundle"}, Data: map[string]string{"k": ""}}, true},
+		{"other configmap", &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "other"}, Data: map[string]string{"k": ""}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj, err := transform(tt.obj)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			var hasData bool
+			switch o := obj.(type) {
+			case *corev1.Secret:
+				hasData = o.Data != nil
+			case *corev1.ConfigMap:
+				hasData = o.Data != nil
+			}
+			if hasData != tt.wantData {
+				t.Errorf("Data kept: got %v, want %v", hasData, tt.wantData)
+			}
+		})
+	}
+}
+
+func TestValidateServices(t *testing.T) {
+	base := func(services ...ServiceCertificate) *Config {
+		cfg := &Config{Name: "my-webhook", Namespace: "ns", Services: services}
+		applyDefaults(cfg)
+		return cfg
+	}
+
+	tests := []struct {
+		name    string
+		cfg     *Config
+		wantErr bool
+	}{
+		{"no services", base(), false},
+		{"valid services", base(ServiceCertificate{ServiceName: "svc-a"}, ServiceCertificate{ServiceName: "svc-b", Namespace: "other"}), false},
+		{"same names in other namespaces", base(ServiceCertificate{ServiceName: "my-webhook", Namespace: "other"}), false},
+		{"missing service name", base(ServiceCertificate{CertSecretName: "cert"}), true},
+		{"invalid service name", base(ServiceCertificate{ServiceName: "Svc_A"}), true},
+		{"secret shared with webhook", base(ServiceCertificate{ServiceName: "svc-a", CertSecretName: "my-webhook-cert"}), true},
+		{"configmap shared between services", base(ServiceCertificate{ServiceName: "svc-a", CABundleConfigMapName: "bundle"}, ServiceCertificate{ServiceName: "svc-b", CABundleConfigMapName: "bundle"}), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateServices(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateServices() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSelfTest(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      Config
+		certOnly bool
+		wantErr  bool
+	}{
+		{name: "disabled", cfg: Config{ClientCAFile: "/etc/ca.crt"}},
+		{name: "enabled", cfg: Config{SelfTestInterval: time.Minute}},
+		{name: "negative interval", cfg: Config{SelfTestInterval: -time.Minute}, wantErr: true},
+		{name: "cert-only mode", cfg: Config{SelfTestInterval: time.Minute}, certOnly: true, wantErr: true},
+		{
+			name: "client certificate",
+			cfg:  Config{SelfTestInterval: time.Minute, ClientCAFile: "/etc/ca.crt", SelfTestClientCertFile: "/etc/tls.crt", SelfTestClientKeyFile: "/etc/tls.key"},
+		},
+		{name: "client CA file without client certificate", cfg: Config{SelfTestInterval: time.Minute, ClientCAFile: "/etc/ca.crt"}, wantErr: true},
+		{name: "client CA configmap without client certificate", cfg: Config{SelfTestInterval: time.Minute, ClientCAConfigMap: "kube-system/ca"}, wantErr: true},
+		{name: "certificate without key", cfg: Config{SelfTestClientCertFile: "/etc/tls.crt"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSelfTest(&tt.cfg, tt.certOnly)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateSelfTest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateHealthChecks(t *testing.T) {
+	check := func(context.Context) error { return nil }
+
+	tests := []struct {
+		name     string
+		checks   []HealthCheck
+		certOnly bool
+		wantErr  bool
+	}{
+		{"no checks", nil, false, false},
+		{"no checks in cert-only mode", nil, true, false},
+		{"valid checks", []HealthCheck{{Name: "opa", Check: check}, {Name: "database", Check: check}}, false, false},
+		{"missing name", []HealthCheck{{Check: check}}, false, true},
+		{"missing check", []HealthCheck{{Name: "opa"}}, false, true},
+		{"duplicate name", []HealthCheck{{Name: "opa", Check: check}, {Name: "opa", Check: check}}, false, true},
+		{"cert-only mode", []HealthCheck{{Name: "opa", Check: check}}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateHealthChecks("ReadinessChecks", tt.checks, tt.certOnly)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateHealthChecks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyDefaults_Services(t *testing.T) {
+	cfg := Config{
+		Name:      "my-webhook",
+		Namespace: "ns",
+		Services: []ServiceCertificate{
+			{ServiceName: "svc-a"},
+			{ServiceName: "svc-b", Namespace: "other", CertSecretName: "custom-cert"},
+		},
+	}
+	applyDefaults(&cfg)
+
+	want := []ServiceCertificate{
+		{ServiceName: "svc-a", Namespace: "ns", CASecretName: "svc-a-ca", CertSecretName: "svc-a-cert", CABundleConfigMapName: "svc-a-ca-bundle"},
+		{ServiceName: "svc-b", Namespace: "other", CASecretName: "svc-b-ca", CertSecretName: "custom-cert", CABundleConfigMapName: "svc-b-ca-bundle"},
+	}
+	for i := range want {
+		if !reflect.DeepEqual(cfg.Services[i], want[i]) {
+			t.Errorf("Services[%d]: got %+v, want %+v", i, cfg.Services[i], want[i])
+		}
+	}
+}
+
+func TestNewCertManagers(t *testing.T) {
+	cfg := Config{
+		Name:      "my-webhook",
+		Namespace: "ns",
+		Services:  []ServiceCertificate{{ServiceName: "svc-a", Namespace: "other"}},
+	}
+	applyDefaults(&cfg)
+
+	managers := newCertManagers(fake.NewSimpleClientset(), &cfg, nil)
+	if len(managers) != 2 {
+		t.Fatalf("Managers: got %d, want 2", len(managers))
+	}
+	if managers[0].Namespace() != "ns" || managers[0].ServiceName() != "my-webhook" {
+		t.Errorf("Webhook manager: got %s/%s, want ns/my-webhook", managers[0].Namespace(), managers[0].ServiceName())
+	}
+	if managers[1].Namespace() != "other" || managers[1].ServiceName() != "svc-a" {
+		t.Errorf("Service manager: got %s/%s, want other/svc-a", managers[1].Namespace(), managers[1].ServiceName())
+	}
+}
+
+// testAdmission is an Admission with a fixed configuration and hooks.
+type testAdmission struct {
+	cfg   Config
+	hooks []Hook
+}
+
+func (a *testAdmission) Configure() Config { return a.cfg }
+func (a *testAdmission) Webhooks() []Hook  { return a.hooks }
+
+func TestRunWithClient_CertOnly(t *testing.T) {
+	newAdmission := func(certOnly bool) *testAdmission {
+		falseVal := false
+		return &testAdmission{
+			cfg: Config{
+				Name:           "my-webhook",
+				Namespace:      "ns",
+				CertOnly:       &certOnly,
+				MetricsEnabled: &falseVal,
+				LeaderElection: &falseVal,
+				ShutdownDelay:  time.Millisecond,
+			},
+			hooks: []Hook{{Path: "/validate", Type: Validating}},
+		}
+	}
+
+	t.Run("hooks without admit functions", func(t *testing.T) {
+		// A cancelled context makes Run stop right after starting
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := RunWithClient(ctx, fake.NewSimpleClientset(), newAdmission(true)); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("admit functions required without cert-only mode", func(t *testing.T) {
+		err := RunWithClient(context.Background(), fake.NewSimpleClientset(), newAdmission(false))
+		if err == nil || !strings.Contains(err.Error(), "admit function is required") {
+			t.Errorf("Expected admit function error, got %v", err)
+		}
+	})
+}
+
+func TestRunWithClient_InvalidHooks(t *testing.T) {
+	admit := func(admissionv1.AdmissionReview) *admissionv1.AdmissionResponse { return Allowed() }
+	admitContext := func(context.Context, admissionv1.AdmissionReview) *admissionv1.AdmissionResponse { return Allowed() }
+	err := RunWithClient(context.Background(), fake.NewSimpleClientset(), &testAdmission{
+		cfg: Config{Name: "my-webhook", Namespace: "ns"},
+		hooks: []Hook{
+			{Path: "/validate", Type: Validating, Admit: admit, AdmitContext: admitContext},
+			{Path: "/mutate", Type: Mutating, AdmitContext: admitContext, HandlerTimeout: -time.Second, MaxRequestBodyBytes: -1, ResponseCacheTTL: -time.Second,
+				ReinvocationPolicy: ptr.To(admissionregistrationv1.ReinvocationPolicyType("Always"))},
+			{Path: "/validate-pods", Type: Validating, Admit: admit, ReinvocationPolicy: ptr.To(admissionregistrationv1.NeverReinvocationPolicy), WebhookConfigurationName: "Pods"},
+		},
+	})
+	for _, want := range []string{
+		"only one of Admit and AdmitContext",
+		"handler timeout must not be negative",
+		"max request body bytes must not be negative",
+		"response cache TTL must not be negative",
+		"reinvocation policy must be Never or IfNeeded",
+		"reinvocation policy is only valid for Mutating hooks",
+		"invalid webhook configuration name",
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in error, got %v", want, err)
+		}
+	}
+}
+
+func TestRunWithClient_ServeHandler(t *testing.T) {
+	falseVal := false
+	type served struct {
+		handler        http.Handler
+		getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+	}
+	servedCh := make(chan served, 1)
+	admission := &testAdmission{
+		cfg: Config{
+			Name:           "my-webhook",
+			Namespace:      "ns",
+			MetricsEnabled: &falseVal,
+			LeaderElection: &falseVal,
+			ShutdownDelay:  time.Millisecond,
+			ServeHandler: func(handler http.Handler, getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) {
+				servedCh <- served{handler, getCertificate}
+			},
+		},
+		hooks: []Hook{{Path: "/validate", Type: Validating, Admit: func(admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+			return &admissionv1.AdmissionResponse{Allowed: true}
+		}}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- RunWithClient(ctx, fake.NewSimpleClientset(), admission)
+	}()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("RunWithClient() error = %v", err)
+		}
+	}()
+
+	var s served
+	select {
+	case s = <-servedCh:
+	case err := <-done:
+		t.Fatalf("RunWithClient() returned before calling ServeHandler: %v", err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for ServeHandler")
+	}
+
+	review := `{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview","request":{"uid":"1","operation":"CREATE"}}`
+	req := httptest.NewRequest(http.MethodPost, "/validate", strings.NewReader(review))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"allowed":true`) {
+		t.Errorf("Got status %d and body %s", rec.Code, rec.Body.String())
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if cert, err := s.getCertificate(nil); err == nil && cert != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the serving certificate")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestValidateServeHandler(t *testing.T) {
+	serve := func(http.Handler, func(*tls.ClientHelloInfo) (*tls.Certificate, error)) {}
+	tests := []struct {
+		name     string
+		cfg      Config
+		hooks    []Hook
+		certOnly bool
+		wantErr  bool
+	}{
+		{name: "unset", cfg: Config{Port: 8443, ClientCAFile: "/etc/ca.crt"}, hooks: []Hook{{Path: "/validate", Port: 9443}}},
+		{name: "set", cfg: Config{Port: 8443, ServeHandler: serve}, hooks: []Hook{{Path: "/validate"}, {Path: "/mutate", Port: 8443}}},
+		{name: "cert-only mode", cfg: Config{Port: 8443, ServeHandler: serve}, certOnly: true, wantErr: true},
+		{name: "client CA", cfg: Config{Port: 8443, ServeHandler: serve, ClientCAConfigMap: "ns/ca"}, wantErr: true},
+		{name: "hook port", cfg: Config{Port: 8443, ServeHandler: serve}, hooks: []Hook{{Path: "/validate", Port: 9443}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateServeHandler(&tt.cfg, tt.hooks, tt.certOnly); (err != nil) != tt.wantErr {
+				t.Errorf("validateServeHandler: got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCertDurations_CAOverlap(t *testing.T) {
+	base := Config{CAValidity: 48 * time.Hour, CARefresh: 24 * time.Hour, CertValidity: 24 * time.Hour, CertRefresh: 12 * time.Hour}
+	tests := []struct {
+		name    string
+		overlap time.Duration
+		wantErr bool
+	}{
+		{"until expiry", 0, false},
+		{"within the previous CA's lifetime", 24 * time.Hour, false},
+		{"beyond the previous CA's lifetime", 25 * time.Hour, true},
+		{"negative", -time.Hour, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base
+			cfg.CAOverlap = tt.overlap
+			if err := validateCertDurations(&cfg); (err != nil) != tt.wantErr {
+				t.Errorf("validateCertDurations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDataKeys(t *testing.T) {
+	base := Config{CertDataKey: "tls.crt", KeyDataKey: "tls.key", CADataKey: "ca.crt", CABundleDataKey: "ca-bundle.crt"}
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr bool
+	}{
+		{"defaults", func(*Config) {}, false},
+		{"custom keys", func(c *Config) { c.CertDataKey, c.KeyDataKey, c.CABundleDataKey = "cert.pem", "key.pem", "ca.pem" }, false},
+		{"invalid key", func(c *Config) { c.CABundleDataKey = "ca/bundle" }, true},
+		{"empty key", func(c *Config) { c.KeyDataKey = "" }, true},
+		{"certificate and key share a key", func(c *Config) { c.KeyDataKey = "tls.crt" }, true},
+		{"CA and certificate share a key", func(c *Config) { c.CADataKey = "tls.crt" }, true},
+		{"Opaque secrets", func(c *Config) { c.SecretType = "Opaque" }, false},
+		{"unsupported secret type", func(c *Config) { c.SecretType = "kubernetes.io/basic-auth" }, true},
+		{"TLS secrets with custom keys", func(c *Config) { c.SecretType, c.CertDataKey = "kubernetes.io/tls", "cert.pem" }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base
+			tt.modify(&cfg)
+			if err := validateDataKeys(&cfg); (err != nil) != tt.wantErr {
+				t.Errorf("validateDataKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateHeadlessService(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"none", Config{}, false},
+		{"headless service", Config{HeadlessServiceName: "wh-pods"}, false},
+		{"StatefulSet pods", Config{HeadlessServiceName: "wh-pods", StatefulSetName: "wh", StatefulSetReplicas: 3}, false},
+		{"invalid service name", Config{HeadlessServiceName: "wh.pods"}, true},
+		{"StatefulSet without headless service", Config{StatefulSetName: "wh", StatefulSetReplicas: 3}, true},
+		{"StatefulSet without replicas", Config{HeadlessServiceName: "wh-pods", StatefulSetName: "wh"}, true},
+		{"replicas without StatefulSet", Config{HeadlessServiceName: "wh-pods", StatefulSetReplicas: 3}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateHeadlessService(&tt.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("validateHeadlessService() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateExternalHostnames(t *testing.T) {
+	tests := []struct {
+		name      string
+		hostnames []string
+		wantErr   bool
+	}{
+		{"none", nil, false},
+		{"DNS name", []string{"webhook.example.com"}, false},
+		{"wildcard", []string{"*.webhooks.example.com"}, false},
+		{"IP addresses", []string{"203.0.113.10", "2001:db8::1"}, false},
+		{"invalid DNS name", []string{"Webhook_Example.com"}, true},
+		{"wildcard in the middle", []string{"webhook.*.example.com"}, true},
+		{"URL", []string{"https://webhook.example.com"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateExternalHostnames(tt.hostnames); (err != nil) != tt.wantErr {
+				t.Errorf("validateExternalHostnames() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCABundleNamespaces(t *testing.T) {
+	tests := []struct {
+		name       string
+		namespaces []string
+		selector   string
+		wantErr    bool
+	}{
+		{"none", nil, "", false},
+		{"namespaces", []string{"team-a", "team-b"}, "", false},
+		{"kubectl selector", nil, "ca-bundle=true,team in (a,b)", false},
+		{"JSON selector", nil, `{"matchLabels":{"ca-bundle":"true"}}`, false},
+		{"invalid namespace", []string{"Team_A"}, "", true},
+		{"invalid selector", nil, "ca-bundle in", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{CABundleNamespaces: tt.namespaces, CABundleNamespaceSelector: tt.selector}
+			if err := validateCABundleNamespaces(cfg); (err != nil) != tt.wantErr {
+				t.Errorf("validateCABundleNamespaces() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCertSyncInterval(t *testing.T) {
+	base := Config{CAValidity: 48 * time.Hour, CARefresh: 24 * time.Hour, CertValidity: time.Hour, CertRefresh: 50 * time.Minute}
+	tests := []struct {
+		name     string
+		interval time.Duration
+		wantErr  bool
+	}{
+		{"default", time.Minute, false},
+		{"zero", 0, true},
+		{"negative", -time.Minute, true},
+		{"beyond the cert rotation window", 10 * time.Minute, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base
+			cfg.CertSyncInterval = tt.interval
+			if err := validateCertSyncInterval(&cfg); (err != nil) != tt.wantErr {
+				t.Errorf("validateCertSyncInterval() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateStatusConfigMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"disabled", Config{CABundleConfigMapName: "wh-ca-bundle"}, false},
+		{"valid", Config{CABundleConfigMapName: "wh-ca-bundle", StatusConfigMapName: "wh-status"}, false},
+		{"invalid name", Config{CABundleConfigMapName: "wh-ca-bundle", StatusConfigMapName: "WH_status"}, true},
+		{"CA bundle", Config{CABundleConfigMapName: "wh-ca-bundle", StatusConfigMapName: "wh-ca-bundle"}, true},
+		{"service CA bundle", Config{
+			Namespace:             "ns",
+			CABundleConfigMapName: "wh-ca-bundle",
+			StatusConfigMapName:   "svc-ca-bundle",
+			Services:              []ServiceCertificate{{Namespace: "ns", CABundleConfigMapName: "svc-ca-bundle"}},
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateStatusConfigMap(&tt.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("validateStatusConfigMap() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package autocertwebhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+
+	"github.com/jimyag/auto-cert-webhook/internal/cabundle"
+	"github.com/jimyag/auto-cert-webhook/internal/metrics"
+)
+
+// selfTestTimeout bounds each self-test request.
+const selfTestTimeout = 10 * time.Second
+
+// selfTester periodically sends a synthetic AdmissionReview to each entry of
+// the webhook configurations the way the API server calls them: through the
+// Service DNS name, verifying the serving certificate with the entry's
+// caBundle, and with the credentials the hooks require. It runs on the leader.
+type selfTester struct {
+	client        kubernetes.Interface
+	refs          []cabundle.WebhookRef
+	namespace     string
+	interval      time.Duration
+	credentials   selfTestCredentials
+	eventRecorder events.Recorder
+
+	// failing holds the entries whose last self-test failed.
+	failing map[string]bool
+}
+
+// selfTestCredentials are the credentials self-tests present, which are read
+// for every self-test so that rotated files are picked up.
+type selfTestCredentials struct {
+	// certFile and keyFile hold a client certificate, if the hooks require one.
+	certFile, keyFile string
+	// tokenFile holds a bearer token, if the hooks require one.
+	tokenFile string
+}
+
+// newSelfTester creates the self-tester of the given webhook configurations.
+// The synthetic requests create a ConfigMap in namespace.
+func newSelfTester(client kubernetes.Interface, refs []cabundle.WebhookRef, namespace string, interval time.Duration, credentials selfTestCredentials, eventRecorder events.Recorder) *selfTester {
+	return &selfTester{
+		client:        client,
+		refs:          refs,
+		namespace:     namespace,
+		interval:      interval,
+		credentials:   credentials,
+		eventRecorder: eventRecorder,
+		failing:       map[string]bool{},
+	}
+}
+
+// Run tests the webhook entries every interval until ctx is cancelled. The
+// first test runs after one interval, leaving time for the CA bundle to be
+// injected.
+func (t *selfTester) Run(ctx context.Context) error {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			t.test(ctx)
+		}
+	}
+}
+
+// test probes every entry of the webhook configurations once.
+func (t *selfTester) test(ctx context.Context) {
+	for _, ref := range t.refs {
+		configs, err := webhookClientConfigs(ctx, t.client, ref)
+		if err != nil {
+			t.record(ref.Name, fmt.Errorf("failed to get %s webhook configuration %s: %w", ref.Type, ref.Name, err))
+			continue
+		}
+		for name, cc := range configs {
+			t.record(name, t.probe(ctx, cc))
+		}
+	}
+}
+
+// webhookClientConfigs returns the client configurations of the entries of a
+// webhook configuration, by entry name.
+func webhookClientConfigs(ctx context.Context, client kubernetes.Interface, ref cabundle.WebhookRef) (map[string]admissionregistrationv1.WebhookClientConfig, error) {
+	configs := make(map[string]admissionregistrationv1.WebhookClientConfig)
+	switch ref.Type {
+	case cabundle.ValidatingWebhook:
+		cfg, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, wh := range cfg.Webhooks {
+			configs[wh.Name] = wh.ClientConfig
+		}
+	case cabundle.MutatingWebhook:
+		cfg, err := client.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, wh := range cfg.Webhooks {
+			configs[wh.Name] = wh.ClientConfig
+		}
+	default:
+		return nil, fmt.Errorf("unknown webhook type: %s", ref.Type)
+	}
+	return configs, nil
+}
+
+// record updates the metrics of an entry and raises an event when its
+// self-test starts or stops failing.
+func (t *selfTester) record(entry string, err error) {
+	metrics.RecordSelfTest(entry, err == nil)
+	if err != nil {
+		klog.Errorf("Self-test of webhook %s failed: %v", entry, err)
+		if !t.failing[entry] && t.eventRecorder != nil {
+			t.eventRecorder.Warningf("SelfTestFailed", "Self-test of webhook %s failed: %v", entry, err)
+		}
+		t.failing[entry] = true
+		return
+	}
+	klog.V(2).Infof("Self-test of webhook %s succeeded", entry)
+	if t.failing[entry] && t.eventRecorder != nil {
+		t.eventRecorder.Eventf("SelfTestSucceeded", "Self-test of webhook %s succeeded again", entry)
+	}
+	delete(t.failing, entry)
+}
+
+// probe sends a synthetic AdmissionReview to a webhook entry and checks that
+// it is answered. The request is the dry-run creation of an empty ConfigMap,
+// which hooks evaluate like any other request; whether it is allowed does not
+// matter.
+func (t *selfTester) probe(ctx context.Context, cc admissionregistrationv1.WebhookClientConfig) error {
+	url, err := clientConfigURL(cc)
+	if err != nil {
+		return err
+	}
+	if len(cc.CABundle) == 0 {
+		return errors.New("caBundle is empty")
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(cc.CABundle) {
+		return errors.New("caBundle contains no certificate")
+	}
+	tlsConfig := &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+	if t.credentials.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.credentials.certFile, t.credentials.keyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport := &http.Transport{TLSClientConfig: tlsConfig}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport, Timeout: selfTestTimeout}
+
+	object, err := json.Marshal(&corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Name: "self-test", Namespace: t.namespace},
+	})
+	if err != nil {
+		return err
+	}
+	uid := types.UID("self-test-" + string(uuid.NewUUID()))
+	body, err := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: admissionv1.SchemeGroupVersion.String(), Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       uid,
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+			Resource:  metav1.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+			Name:      "self-test",
+			Namespace: t.namespace,
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: object},
+			DryRun:    ptr.To(true),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if t.credentials.tokenFile != "" {
+		token, err := os.ReadFile(t.credentials.tokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var review admissionv1.AdmissionReview
+	if err := json.NewDecoder(resp.Body).Decode(&review); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if review.Response == nil || review.Response.UID != uid {
+		return errors.New("response does not answer the request")
+	}
+	return nil
+}
+
+// clientConfigURL returns the URL the API server calls for a client config.
+func clientConfigURL(cc admissionregistrationv1.WebhookClientConfig) (string, error) {
+	if cc.URL != nil {
+		return *cc.URL, nil
+	}
+	if cc.Service == nil {
+		return "", errors.New("neither url nor service is set")
+	}
+	port := int32(443)
+	if cc.Service.Port != nil {
+		port = *cc.Service.Port
+	}
+	path := "/"
+	if cc.Service.Path != nil {
+		path = *cc.Service.Path
+	}
+	host := cc.Service.Name + "." + cc.Service.Namespace + ".svc"
+	return "https://" + net.JoinHostPort(host, strconv.Itoa(int(port))) + path, nil
+}
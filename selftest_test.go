@@ -0,0 +1,185 @@
+package autocertwebhook
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/clock"
+	"k8s.io/utils/ptr"
+
+	"github.com/jimyag/auto-cert-webhook/internal/cabundle"
+)
+
+func TestSelfTester(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 || r.Header.Get("Authorization") != "Bearer self-test-token" {
+			http.Error(w, "unauthenticated", http.StatusUnauthorized)
+			return
+		}
+		var review admissionv1.AdmissionReview
+		if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var cm corev1.ConfigMap
+		if err := json.Unmarshal(review.Request.Object.Raw, &cm); err != nil || cm.Namespace != "ns" || review.Request.Namespace != "ns" {
+			http.Error(w, "unexpected object", http.StatusBadRequest)
+			return
+		}
+		review.Response = &admissionv1.AdmissionResponse{UID: review.Request.UID, Allowed: true}
+		json.NewEncoder(w).Encode(review)
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+
+	client := fake.NewSimpleClientset(&admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "wh"},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+			Name:         "validate.wh.ns.svc",
+			ClientConfig: admissionregistrationv1.WebhookClientConfig{URL: ptr.To(ts.URL + "/validate"), CABundle: caBundle},
+		}},
+	})
+	refs := []cabundle.WebhookRef{
+		{Name: "wh", Type: cabundle.ValidatingWebhook},
+		{Name: "wh", Type: cabundle.MutatingWebhook},
+	}
+	ctx := context.Background()
+
+	// Self-tests without the credentials the hook requires fail
+	tester := newSelfTester(client, refs, "ns", 0, selfTestCredentials{}, nil)
+	tester.test(ctx)
+	if !tester.failing["validate.wh.ns.svc"] {
+		t.Error("Expected the self-test without credentials to fail")
+	}
+
+	recorder := events.NewInMemoryRecorder("test", clock.RealClock{})
+	tester = newSelfTester(client, refs, "ns", 0, writeSelfTestCredentials(t), recorder)
+	tester.test(ctx)
+	if tester.failing["validate.wh.ns.svc"] {
+		t.Error("Expected the self-test of the entry to succeed")
+	}
+	// The mutating configuration does not exist
+	if !tester.failing["wh"] {
+		t.Error("Expected the self-test of the missing configuration to fail")
+	}
+
+	// A caBundle that does not match the serving certificate fails the test
+	cfg, _ := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, "wh", metav1.GetOptions{})
+	cfg.Webhooks[0].ClientConfig.CABundle = testCertSecret(t, "other-ca", time.Hour).Data["tls.crt"]
+	client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Update(ctx, cfg, metav1.UpdateOptions{})
+
+	tester.test(ctx)
+	tester.test(ctx)
+	if !tester.failing["validate.wh.ns.svc"] {
+		t.Error("Expected the self-test with a wrong caBundle to fail")
+	}
+
+	reasons := map[string]int{}
+	for _, event := range recorder.Events() {
+		reasons[event.Reason]++
+	}
+	// One event when each entry starts failing, not on every failure
+	if reasons["SelfTestFailed"] != 2 {
+		t.Errorf("Expected 2 SelfTestFailed events, got %v", reasons)
+	}
+}
+
+// writeSelfTestCredentials writes a self-signed client certificate and a
+// bearer token to files and returns them as self-test credentials.
+func writeSelfTestCredentials(t *testing.T) selfTestCredentials {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "self-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	dir := t.TempDir()
+	credentials := selfTestCredentials{
+		certFile:  filepath.Join(dir, "tls.crt"),
+		keyFile:   filepath.Join(dir, "tls.key"),
+		tokenFile: filepath.Join(dir, "token"),
+	}
+	files := map[string][]byte{
+		credentials.certFile:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		credentials.keyFile:   pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		credentials.tokenFile: []byte("self-test-token\n"),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(name, data, 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	return credentials
+}
+
+func TestClientConfigURL(t *testing.T) {
+	tests := []struct {
+		name string
+		cc   admissionregistrationv1.WebhookClientConfig
+		want string
+	}{
+		{
+			name: "service",
+			cc: admissionregistrationv1.WebhookClientConfig{Service: &admissionregistrationv1.ServiceReference{
+				Name: "svc", Namespace: "ns", Port: ptr.To[int32](8443), Path: ptr.To("/validate"),
+			}},
+			want: "https://svc.ns.svc:8443/validate",
+		},
+		{
+			name: "service defaults",
+			cc:   admissionregistrationv1.WebhookClientConfig{Service: &admissionregistrationv1.ServiceReference{Name: "svc", Namespace: "ns"}},
+			want: "https://svc.ns.svc:443/",
+		},
+		{
+			name: "url",
+			cc:   admissionregistrationv1.WebhookClientConfig{URL: ptr.To("https://example.com/validate")},
+			want: "https://example.com/validate",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := clientConfigURL(tt.cc)
+			if err != nil || got != tt.want {
+				t.Errorf("clientConfigURL() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package autocertwebhook
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// StatusBuilder builds a denial response whose Result carries structured
+// details, so that clients can react to a denial programmatically instead of
+// parsing its message. Create one with Deny.
+type StatusBuilder struct {
+	status   metav1.Status
+	warnings []string
+}
+
+// Deny starts building a response that denies the request with the given message.
+// Without further options it is equivalent to Denied(message).
+func Deny(message string) *StatusBuilder {
+	return &StatusBuilder{
+		status: metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: message,
+			Reason:  metav1.StatusReasonForbidden,
+			Code:    http.StatusForbidden,
+		},
+	}
+}
+
+// details returns the status details, creating them if needed.
+func (b *StatusBuilder) details() *metav1.StatusDetails {
+	if b.status.Details == nil {
+		b.status.Details = &metav1.StatusDetails{}
+	}
+	return b.status.Details
+}
+
+// Reason sets the status reason and HTTP code. The reason may be one of the
+// metav1.StatusReason constants or a custom CamelCase value.
+func (b *StatusBuilder) Reason(reason metav1.StatusReason, code int32) *StatusBuilder {
+	b.status.Reason = reason
+	b.status.Code = code
+	return b
+}
+
+// Object sets the group, kind and name of the object the denial refers to.
+func (b *StatusBuilder) Object(gk schema.GroupKind, name string) *StatusBuilder {
+	details := b.details()
+	details.Group = gk.Group
+	details.Kind = gk.Kind
+	details.Name = name
+	return b
+}
+
+// ForRequest sets the group, kind and name of the object from the admission request.
+func (b *StatusBuilder) ForRequest(ar admissionv1.AdmissionReview) *StatusBuilder {
+	if ar.Request == nil {
+		return b
+	}
+	return b.Object(schema.GroupKind{Group: ar.Request.Kind.Group, Kind: ar.Request.Kind.Kind}, ar.Request.Name)
+}
+
+// UID sets the UID of the object the denial refers to.
+func (b *StatusBuilder) UID(uid types.UID) *StatusBuilder {
+	b.details().UID = uid
+	return b
+}
+
+// RetryAfter tells the client it may retry the request after the given
+// duration, rounded up to whole seconds.
+func (b *StatusBuilder) RetryAfter(d time.Duration) *StatusBuilder {
+	seconds := int32(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	b.details().RetryAfterSeconds = seconds
+	return b
+}
+
+// Cause adds a cause to the status details. Field is optional.
+func (b *StatusBuilder) Cause(causeType metav1.CauseType, field, message string) *StatusBuilder {
+	details := b.details()
+	details.Causes = append(details.Causes, metav1.StatusCause{
+		Type:    causeType,
+		Field:   field,
+		Message: message,
+	})
+	return b
+}
+
+// Warnings adds warnings returned to the API client.
+// Warnings are passed through SanitizeWarnings.
+func (b *StatusBuilder) Warnings(warnings ...string) *StatusBuilder {
+	b.warnings = append(b.warnings, warnings...)
+	return b
+}
+
+// Response returns the admission response.
+func (b *StatusBuilder) Response() *admissionv1.AdmissionResponse {
+	status := b.status
+	if b.status.Details != nil {
+		details := *b.status.Details
+		details.Causes = append([]metav1.StatusCause(nil), details.Causes...)
+		status.Details = &details
+	}
+	return &admissionv1.AdmissionResponse{
+		Allowed:  false,
+		Result:   &status,
+		Warnings: SanitizeWarnings(b.warnings),
+	}
+}
@@ -0,0 +1,89 @@
+package autocertwebhook
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestDeny_Defaults(t *testing.T) {
+	resp := Deny("request denied").Response()
+
+	if !reflect.DeepEqual(resp, Denied("request denied")) {
+		t.Errorf("Deny(): got %+v, want %+v", resp, Denied("request denied"))
+	}
+}
+
+func TestDeny_Details(t *testing.T) {
+	resp := Deny("quota exceeded").
+		Reason("QuotaExceeded", http.StatusTooManyRequests).
+		Object(schema.GroupKind{Group: "apps", Kind: "Deployment"}, "web").
+		UID("1234").
+		RetryAfter(1500*time.Millisecond).
+		Cause(metav1.CauseTypeFieldValueInvalid, "spec.replicas", "must be at most 10").
+		Warnings("consider a smaller deployment", "").
+		Response()
+
+	if resp.Allowed {
+		t.Error("Deny() should return Allowed=false")
+	}
+	if resp.Result.Reason != "QuotaExceeded" {
+		t.Errorf("Reason: got %q, want %q", resp.Result.Reason, "QuotaExceeded")
+	}
+	if resp.Result.Code != http.StatusTooManyRequests {
+		t.Errorf("Code: got %d, want %d", resp.Result.Code, http.StatusTooManyRequests)
+	}
+
+	want := &metav1.StatusDetails{
+		Group:             "apps",
+		Kind:              "Deployment",
+		Name:              "web",
+		UID:               "1234",
+		RetryAfterSeconds: 2,
+		Causes: []metav1.StatusCause{
+			{Type: metav1.CauseTypeFieldValueInvalid, Field: "spec.replicas", Message: "must be at most 10"},
+		},
+	}
+	if !reflect.DeepEqual(resp.Result.Details, want) {
+		t.Errorf("Details: got %+v, want %+v", resp.Result.Details, want)
+	}
+	if !reflect.DeepEqual(resp.Warnings, []string{"consider a smaller deployment"}) {
+		t.Errorf("Warnings: got %q", resp.Warnings)
+	}
+}
+
+func TestDeny_ForRequest(t *testing.T) {
+	ar := admissionv1.AdmissionReview{
+		Request: &admissionv1.AdmissionRequest{
+			Kind: metav1.GroupVersionKind{Group: "", Version: "v1", Kind: "Pod"},
+			Name: "nginx",
+		},
+	}
+	resp := Deny("denied").ForRequest(ar).RetryAfter(0).Response()
+
+	details := resp.Result.Details
+	if details == nil {
+		t.Fatal("Expected details")
+	}
+	if details.Kind != "Pod" || details.Name != "nginx" {
+		t.Errorf("Details: got %s/%s, want Pod/nginx", details.Kind, details.Name)
+	}
+	if details.RetryAfterSeconds != 1 {
+		t.Errorf("RetryAfterSeconds: got %d, want 1", details.RetryAfterSeconds)
+	}
+}
+
+func TestDeny_ResponseIsIndependent(t *testing.T) {
+	builder := Deny("denied").Cause(metav1.CauseTypeFieldValueRequired, "spec", "required")
+	first := builder.Response()
+	builder.Cause(metav1.CauseTypeFieldValueInvalid, "metadata", "invalid")
+
+	if len(first.Result.Details.Causes) != 1 {
+		t.Errorf("Expected earlier response to keep 1 cause, got %d", len(first.Result.Details.Causes))
+	}
+}
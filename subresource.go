@@ -0,0 +1,92 @@
+package autocertwebhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// SubresourceStatus is the status subresource.
+	SubresourceStatus = "status"
+	// SubresourceScale is the scale subresource; its object is an autoscaling/v1 Scale.
+	SubresourceScale = "scale"
+	// SubresourceEphemeralContainers is the pods/ephemeralcontainers subresource;
+	// its object is the whole Pod.
+	SubresourceEphemeralContainers = "ephemeralcontainers"
+)
+
+// IsSubresource returns true if the request targets a subresource. If names are
+// given, it only returns true for those subresources.
+func IsSubresource(ar admissionv1.AdmissionReview, names ...string) bool {
+	if ar.Request == nil || ar.Request.SubResource == "" {
+		return false
+	}
+	if len(names) == 0 {
+		return true
+	}
+	for _, name := range names {
+		if ar.Request.SubResource == name {
+			return true
+		}
+	}
+	return false
+}
+
+// DecodeScale decodes the Scale object of a scale subresource request.
+// On DELETE requests, where the object is empty, it decodes the old object.
+func DecodeScale(ar admissionv1.AdmissionReview) (*autoscalingv1.Scale, error) {
+	if !IsSubresource(ar, SubresourceScale) {
+		return nil, fmt.Errorf("request is not for the scale subresource")
+	}
+	if ar.Request.Kind.Kind != "Scale" {
+		return nil, fmt.Errorf("unsupported scale kind %s, expected autoscaling/v1 Scale", ar.Request.Kind.String())
+	}
+
+	raw := ar.Request.Object.Raw
+	if len(raw) == 0 {
+		raw = ar.Request.OldObject.Raw
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("request contains no scale object")
+	}
+
+	scale := &autoscalingv1.Scale{}
+	if err := json.Unmarshal(raw, scale); err != nil {
+		return nil, fmt.Errorf("failed to decode scale: %w", err)
+	}
+	return scale, nil
+}
+
+// SkipSubresources wraps an admit function so that subresource requests are
+// allowed without calling it. Use it for handlers that decode the request
+// object as the parent kind, which fails for subresources such as scale.
+func SkipSubresources(admit AdmitFunc) AdmitFunc {
+	return func(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		if IsSubresource(ar) {
+			return Allowed()
+		}
+		return admit(ar)
+	}
+}
+
+// DeniedUnsupportedSubresource returns an admission response that denies a
+// subresource request the hook does not handle, explaining how to exclude it
+// from the webhook rules.
+func DeniedUnsupportedSubresource(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+	resource, subresource := "", ""
+	if ar.Request != nil {
+		resource = ar.Request.Resource.Resource
+		subresource = ar.Request.SubResource
+	}
+	return DeniedWithReason(
+		fmt.Sprintf("subresource %q of %q is not handled by this webhook; list %q instead of %q in the webhook rules",
+			subresource, resource, resource, resource+"/*"),
+		metav1.StatusReasonBadRequest,
+		http.StatusBadRequest,
+	)
+}
@@ -0,0 +1,131 @@
+package autocertwebhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func subresourceReview(t *testing.T, subresource string, obj interface{}) admissionv1.AdmissionReview {
+	t.Helper()
+
+	var raw []byte
+	if obj != nil {
+		var err error
+		raw, err = json.Marshal(obj)
+		if err != nil {
+			t.Fatalf("Failed to marshal object: %v", err)
+		}
+	}
+
+	kind := metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	if subresource == SubresourceScale {
+		kind = metav1.GroupVersionKind{Group: "autoscaling", Version: "v1", Kind: "Scale"}
+	}
+
+	return admissionv1.AdmissionReview{
+		Request: &admissionv1.AdmissionRequest{
+			UID:         "test-uid",
+			Kind:        kind,
+			Resource:    metav1.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+			SubResource: subresource,
+			Object:      runtime.RawExtension{Raw: raw},
+		},
+	}
+}
+
+func TestIsSubresource(t *testing.T) {
+	main := subresourceReview(t, "", nil)
+	scale := subresourceReview(t, SubresourceScale, nil)
+
+	if IsSubresource(admissionv1.AdmissionReview{}) {
+		t.Error("Expected false for nil request")
+	}
+	if IsSubresource(main) {
+		t.Error("Expected false for main resource")
+	}
+	if !IsSubresource(scale) {
+		t.Error("Expected true for any subresource")
+	}
+	if !IsSubresource(scale, SubresourceStatus, SubresourceScale) {
+		t.Error("Expected true for matching subresource")
+	}
+	if IsSubresource(scale, SubresourceStatus) {
+		t.Error("Expected false for other subresource")
+	}
+}
+
+func TestDecodeScale(t *testing.T) {
+	t.Run("decodes scale", func(t *testing.T) {
+		ar := subresourceReview(t, SubresourceScale, autoscalingv1.Scale{
+			ObjectMeta: metav1.ObjectMeta{Name: "web"},
+			Spec:       autoscalingv1.ScaleSpec{Replicas: 5},
+		})
+
+		scale, err := DecodeScale(ar)
+		if err != nil {
+			t.Fatalf("DecodeScale failed: %v", err)
+		}
+		if scale.Name != "web" || scale.Spec.Replicas != 5 {
+			t.Errorf("Unexpected scale: %+v", scale)
+		}
+	})
+
+	t.Run("not a scale request", func(t *testing.T) {
+		if _, err := DecodeScale(subresourceReview(t, SubresourceStatus, nil)); err == nil {
+			t.Error("Expected error for status subresource")
+		}
+	})
+
+	t.Run("unexpected kind", func(t *testing.T) {
+		ar := subresourceReview(t, SubresourceScale, autoscalingv1.Scale{})
+		ar.Request.Kind.Kind = "Deployment"
+		if _, err := DecodeScale(ar); err == nil {
+			t.Error("Expected error for unexpected kind")
+		}
+	})
+
+	t.Run("empty object", func(t *testing.T) {
+		if _, err := DecodeScale(subresourceReview(t, SubresourceScale, nil)); err == nil {
+			t.Error("Expected error for empty object")
+		}
+	})
+}
+
+func TestSkipSubresources(t *testing.T) {
+	called := false
+	admit := SkipSubresources(func(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+		called = true
+		return Denied("denied")
+	})
+
+	resp := admit(subresourceReview(t, SubresourceStatus, nil))
+	if !resp.Allowed || called {
+		t.Error("Expected subresource request to be allowed without calling admit")
+	}
+
+	resp = admit(subresourceReview(t, "", nil))
+	if resp.Allowed || !called {
+		t.Error("Expected main resource request to reach admit")
+	}
+}
+
+func TestDeniedUnsupportedSubresource(t *testing.T) {
+	resp := DeniedUnsupportedSubresource(subresourceReview(t, SubresourceScale, nil))
+
+	if resp.Allowed {
+		t.Error("Expected Allowed=false")
+	}
+	if resp.Result.Code != http.StatusBadRequest {
+		t.Errorf("Code: got %d, want %d", resp.Result.Code, http.StatusBadRequest)
+	}
+	if !strings.Contains(resp.Result.Message, `"scale"`) || !strings.Contains(resp.Result.Message, `"deployments/*"`) {
+		t.Errorf("Unexpected message: %s", resp.Result.Message)
+	}
+}
@@ -0,0 +1,132 @@
+package autocertwebhook
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/jimyag/auto-cert-webhook/internal/metrics"
+)
+
+const (
+	// restartInitialBackoff is the delay before the first restart of a
+	// failed subsystem.
+	restartInitialBackoff = time.Second
+
+	// restartMaxBackoff caps the delay between restarts. A subsystem that
+	// ran at least this long before failing is considered recovered, and
+	// its failure count and backoff are reset.
+	restartMaxBackoff = time.Minute
+)
+
+// supervisor runs the long-running subsystems and restarts those that fail
+// with exponential backoff, so that a transient error does not terminate the
+// process. A subsystem that keeps failing is reported on errCh.
+type supervisor struct {
+	wg    sync.WaitGroup
+	errCh chan error
+
+	// maxRestarts is how many consecutive failures of a subsystem are
+	// retried before the failure is reported.
+	maxRestarts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+// newSupervisor creates a supervisor that retries maxRestarts consecutive
+// failures per subsystem.
+func newSupervisor(maxRestarts int) *supervisor {
+	return &supervisor{
+		// Only the first reported error matters; later ones are dropped.
+		errCh:          make(chan error, 1),
+		maxRestarts:    maxRestarts,
+		initialBackoff: restartInitialBackoff,
+		maxBackoff:     restartMaxBackoff,
+	}
+}
+
+// Go runs the named subsystem in the background until ctx is done, restarting
+// it when it fails. A subsystem that returns nil before ctx is done is not
+// restarted.
+func (s *supervisor) Go(ctx context.Context, name string, run func(context.Context) error) {
+	s.wg.Go(func() {
+		failures := 0
+		backoff := s.initialBackoff
+		for {
+			started := time.Now()
+			err := run(ctx)
+			if err == nil || ctx.Err() != nil {
+				return
+			}
+
+			if time.Since(started) >= s.maxBackoff {
+				failures = 0
+				backoff = s.initialBackoff
+			}
+			failures++
+			if failures > s.maxRestarts {
+				klog.Errorf("Subsystem %s failed %d times in a row, giving up: %v", name, failures, err)
+				s.fail(fmt.Errorf("%s: %w", name, err))
+				return
+			}
+
+			klog.Errorf("Subsystem %s failed, restarting in %v (%d/%d): %v", name, backoff, failures, s.maxRestarts, err)
+			metrics.RecordSubsystemRestart(name)
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			backoff = min(2*backoff, s.maxBackoff)
+		}
+	})
+}
+
+// GoOnce runs a subsystem in the background and reports its failure without
+// restarting it, for errors that a restart cannot fix.
+func (s *supervisor) GoOnce(ctx context.Context, name string, run func(context.Context) error) {
+	s.wg.Go(func() {
+		if err := run(ctx); err != nil && ctx.Err() == nil {
+			klog.Errorf("Subsystem %s failed: %v", name, err)
+			s.fail(fmt.Errorf("%s: %w", name, err))
+		}
+	})
+}
+
+// fail reports a fatal subsystem error.
+func (s *supervisor) fail(err error) {
+	select {
+	case s.errCh <- err:
+	default:
+	}
+}
+
+// Errors returns the channel fatal subsystem errors are reported on.
+func (s *supervisor) Errors() <-chan error {
+	return s.errCh
+}
+
+// Wait waits for all subsystems to stop, or the timeout, whichever comes
+// first. It returns false if the timeout expired.
+func (s *supervisor) Wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		klog.Warningf("Shutdown did not complete within %v", timeout)
+		return false
+	}
+}
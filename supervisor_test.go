@@ -0,0 +1,161 @@
+package autocertwebhook
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestSupervisor returns a supervisor with short backoffs.
+func newTestSupervisor(maxRestarts int) *supervisor {
+	s := newSupervisor(maxRestarts)
+	s.initialBackoff = time.Millisecond
+	s.maxBackoff = 10 * time.Millisecond
+	return s
+}
+
+func TestSupervisor_Go(t *testing.T) {
+	t.Run("restarts a failed subsystem", func(t *testing.T) {
+		s := newTestSupervisor(3)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var runs atomic.Int32
+		s.Go(ctx, "test", func(ctx context.Context) error {
+			if runs.Add(1) < 3 {
+				return errors.New("transient")
+			}
+			<-ctx.Done()
+			return nil
+		})
+
+		deadline := time.After(time.Second)
+		for runs.Load() < 3 {
+			select {
+			case err := <-s.Errors():
+				t.Fatalf("Unexpected error: %v", err)
+			case <-deadline:
+				t.Fatalf("Expected 3 runs, got %d", runs.Load())
+			case <-time.After(time.Millisecond):
+			}
+		}
+
+		cancel()
+		if !s.Wait(time.Second) {
+			t.Error("Expected subsystem to stop")
+		}
+	})
+
+	t.Run("reports repeated failures", func(t *testing.T) {
+		s := newTestSupervisor(2)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var runs atomic.Int32
+		s.Go(ctx, "test", func(context.Context) error {
+			runs.Add(1)
+			return errors.New("permanent")
+		})
+
+		select {
+		case err := <-s.Errors():
+			if err.Error() != "test: permanent" {
+				t.Errorf("Error: got %q, want %q", err, "test: permanent")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected the failure to be reported")
+		}
+		if got := runs.Load(); got != 3 {
+			t.Errorf("Runs: got %d, want 3", got)
+		}
+	})
+
+	t.Run("zero max restarts reports the first failure", func(t *testing.T) {
+		s := newTestSupervisor(0)
+		s.Go(context.Background(), "test", func(context.Context) error {
+			return errors.New("failed")
+		})
+
+		select {
+		case <-s.Errors():
+		case <-time.After(time.Second):
+			t.Fatal("Expected the failure to be reported")
+		}
+	})
+
+	t.Run("does not restart after shutdown", func(t *testing.T) {
+		s := newTestSupervisor(3)
+		ctx, cancel := context.WithCancel(context.Background())
+
+		var runs atomic.Int32
+		s.Go(ctx, "test", func(ctx context.Context) error {
+			runs.Add(1)
+			<-ctx.Done()
+			return errors.New("stopped")
+		})
+		cancel()
+
+		if !s.Wait(time.Second) {
+			t.Fatal("Expected subsystem to stop")
+		}
+		if got := runs.Load(); got != 1 {
+			t.Errorf("Runs: got %d, want 1", got)
+		}
+		select {
+		case err := <-s.Errors():
+			t.Errorf("Unexpected error: %v", err)
+		default:
+		}
+	})
+}
+
+func TestSupervisor_GoOnce(t *testing.T) {
+	s := newTestSupervisor(3)
+	var runs atomic.Int32
+	s.GoOnce(context.Background(), "test", func(context.Context) error {
+		runs.Add(1)
+		return errors.New("invalid")
+	})
+
+	select {
+	case <-s.Errors():
+	case <-time.After(time.Second):
+		t.Fatal("Expected the failure to be reported")
+	}
+	if got := runs.Load(); got != 1 {
+		t.Errorf("Runs: got %d, want 1", got)
+	}
+}
+
+func TestSupervisor_Wait(t *testing.T) {
+	t.Run("returns when all subsystems stop", func(t *testing.T) {
+		s := newTestSupervisor(0)
+		s.Go(context.Background(), "test", func(context.Context) error {
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		})
+		if !s.Wait(time.Second) {
+			t.Error("Expected wait to complete before the timeout")
+		}
+	})
+
+	t.Run("gives up after the timeout", func(t *testing.T) {
+		s := newTestSupervisor(0)
+		release := make(chan struct{})
+		defer close(release)
+		s.Go(context.Background(), "test", func(context.Context) error {
+			<-release
+			return nil
+		})
+
+		start := time.Now()
+		if s.Wait(50 * time.Millisecond) {
+			t.Error("Expected wait to time out")
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Wait returned after %v, want about 50ms", elapsed)
+		}
+	})
+}
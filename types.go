@@ -3,9 +3,15 @@
 package autocertwebhook
 
 import (
+	"context"
+	"crypto/tls"
+	"net/http"
 	"time"
 
 	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // HookType defines the type of admission webhook.
@@ -21,6 +27,13 @@ const (
 // AdmitFunc is the function signature for handling admission requests.
 type AdmitFunc func(ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse
 
+// AdmitContextFunc handles admission requests like AdmitFunc. ctx is
+// cancelled when the API server abandons the request or Hook.HandlerTimeout
+// expires; pass it to calls to other systems so that they stop too. It also
+// carries the request's audit ID, see AuditID, and klog.FromContext(ctx)
+// returns a logger that logs the audit ID and UID with every message.
+type AdmitContextFunc func(ctx context.Context, ar admissionv1.AdmissionReview) *admissionv1.AdmissionResponse
+
 // Hook defines a single admission webhook endpoint.
 type Hook struct {
 	// Path is the URL path for this webhook, e.g., "/mutate-pods".
@@ -29,12 +42,180 @@ type Hook struct {
 	// Type is the webhook type: Mutating or Validating.
 	Type HookType
 
-	// Admit handles the admission request.
+	// Admit handles the admission request. Either Admit or AdmitContext is
+	// required.
 	Admit AdmitFunc
+
+	// AdmitContext handles the admission request with a context, instead of
+	// Admit.
+	AdmitContext AdmitContextFunc
+
+	// HandlerTimeout, if positive, bounds the time the server waits for the
+	// admit function, independently of the API server's TimeoutSeconds. Once
+	// it expires, the context of AdmitContext is cancelled and the request is
+	// answered right away: allowed with a warning if FailurePolicy is Ignore,
+	// denied with a 504 Timeout status otherwise. The admit function keeps
+	// its in-flight slot until it returns. Keep it below TimeoutSeconds, so
+	// that the API server gets the deterministic response rather than timing
+	// out itself.
+	HandlerTimeout time.Duration
+
+	// MaxRequestBodyBytes caps the size of the AdmissionReviews of this
+	// hook, e.g. lower for Pods or higher for large custom resources. Larger
+	// requests are answered without calling the admit function: allowed
+	// with a warning if FailurePolicy is Ignore, denied with a 413 status
+	// otherwise. If zero, defaults to 10 MiB.
+	MaxRequestBodyBytes int64
+
+	// ResponseCacheTTL, if positive, caches the responses of the admit
+	// function for this long, keyed by request UID and object, so that API
+	// server retries and reinvocations of the same request are answered
+	// without calling it again. Only set it for admit functions whose answer
+	// does not depend on state that may change within the TTL.
+	ResponseCacheTTL time.Duration
+
+	// Port serves the hook on a separate TLS listener on this port, so that
+	// hooks can be isolated and firewalled from each other, e.g. high-risk
+	// validating hooks from latency-sensitive mutating ones. Hooks with the
+	// same Port share a listener; health endpoints are only served on
+	// Config.Port. If zero, the hook is served on Config.Port.
+	Port int
+
+	// Exempt, if set, allows requests of users whom RBAC grants the described
+	// permission without calling Admit, as checked with a SubjectAccessReview
+	// in the request's namespace. This moves exemption policies out of code
+	// into Roles and RoleBindings. If the review fails, Admit is called.
+	// Requires the create verb on subjectaccessreviews.
+	Exempt *AccessCheck
+
+	// The following fields are only used when Config.ManageWebhookConfigurations
+	// is enabled, to generate this hook's entry in the webhook configuration.
+
+	// Rules describes which operations on which resources the hook handles.
+	// Required when webhook configurations are managed.
+	Rules []admissionregistrationv1.RuleWithOperations
+
+	// FailurePolicy defines how errors calling the hook are handled.
+	// If nil, defaults to Fail.
+	FailurePolicy *admissionregistrationv1.FailurePolicyType
+
+	// SideEffects states whether the hook has side effects.
+	// If nil, defaults to None.
+	SideEffects *admissionregistrationv1.SideEffectClass
+
+	// TimeoutSeconds is the timeout the API server applies when calling the hook.
+	// If nil, defaults to 10.
+	TimeoutSeconds *int32
+
+	// NamespaceSelector limits the hook to matching namespaces.
+	NamespaceSelector *metav1.LabelSelector
+
+	// ObjectSelector limits the hook to matching objects.
+	ObjectSelector *metav1.LabelSelector
+
+	// ReinvocationPolicy states whether the API server calls a mutating hook
+	// again after later mutating webhooks changed the object: IfNeeded for
+	// mutations that must hold on the final object, e.g. injecting into
+	// containers added by others, Never for those that must not be repeated.
+	// Only valid for Mutating hooks. If nil, defaults to Never.
+	ReinvocationPolicy *admissionregistrationv1.ReinvocationPolicyType
+
+	// WebhookConfigurationName puts the hook into the webhook configuration
+	// of this name instead of the one named Config.Name, so that hooks can
+	// be split across configurations, e.g. per team, while sharing one
+	// server and certificate. The CA bundle is injected into every
+	// configuration, and managed ones get the entries of their hooks.
+	// If empty, defaults to Config.Name.
+	WebhookConfigurationName string
+
+	// ServiceName overrides the Service the API server calls for this hook.
+	// If empty, defaults to Config.ServiceName.
+	ServiceName string
+
+	// ServicePort overrides the Service port the API server calls for this hook.
+	// If zero, defaults to Config.ServicePort.
+	ServicePort int32
+
+	// ServicePath overrides the URL path the API server calls for this hook,
+	// e.g. when a proxy in front of the server rewrites paths.
+	// If empty, defaults to Path.
+	ServicePath string
+}
+
+// AccessCheck describes a permission checked with a SubjectAccessReview,
+// e.g. verb "bypass" on resource "exemptions" in group "policy.example.com".
+// The resource does not need to exist; RBAC rules may name any resource.
+type AccessCheck struct {
+	// Verb is the checked verb. Required.
+	Verb string
+
+	// Group is the API group of the resource.
+	Group string
+
+	// Resource is the checked resource. Required.
+	Resource string
+
+	// Subresource is the checked subresource.
+	Subresource string
+
+	// Name is the checked resource name. If empty, all names are checked.
+	Name string
+}
+
+// HealthCheck is a named check run on every request to the readiness or
+// health endpoint. Check gets the context of the probe request and should
+// return quickly, within the probe's timeout; cache the state of slow
+// dependencies instead of querying them on every probe.
+type HealthCheck struct {
+	// Name identifies the check in the endpoint's response. Required.
+	Name string
+
+	// Check returns an error while the check fails. Required.
+	Check func(ctx context.Context) error
+}
+
+// HTTPHandler is an additional endpoint of the webhook server.
+type HTTPHandler struct {
+	// Path is the path of the endpoint, or of a subtree if it ends with a
+	// slash, e.g. "/docs/". Required.
+	Path string
+
+	// Handler serves the requests. Required.
+	Handler http.Handler
+}
+
+// ServiceCertificate describes an additional service whose CA, serving
+// certificate and CA bundle the leader maintains next to the webhook's own,
+// e.g. for a central deployment that provisions certificates for several
+// webhooks. Each service gets its own CA.
+type ServiceCertificate struct {
+	// ServiceName is the name of the service. Required.
+	ServiceName string
+
+	// Namespace is the namespace of the service and its secrets and
+	// ConfigMap. Defaults to Config.Namespace.
+	Namespace string
+
+	// CASecretName is the name of the CA secret.
+	// Defaults to "<ServiceName>-ca".
+	CASecretName string
+
+	// CertSecretName is the name of the serving certificate secret.
+	// Defaults to "<ServiceName>-cert".
+	CertSecretName string
+
+	// CABundleConfigMapName is the name of the CA bundle ConfigMap.
+	// Defaults to "<ServiceName>-ca-bundle".
+	CABundleConfigMapName string
+
+	// ExternalHostnames are added to the serving certificate, like
+	// Config.ExternalHostnames.
+	ExternalHostnames []string
 }
 
 // Config contains all configuration for the webhook server.
-// Configuration priority: code > environment variables > defaults.
+// Configuration priority: command-line flags (see ConfigFlags) > code >
+// environment variables > profile (see Profile) > defaults.
 // All environment variables use the "ACW_" prefix.
 //
 // IMPORTANT: The framework expects the MutatingWebhookConfiguration and/or
@@ -48,6 +229,13 @@ type Config struct {
 	// Required. Env: ACW_NAME
 	Name string `envconfig:"NAME"`
 
+	// Profile selects a preset of settings for the values that are not set in
+	// code, environment variables or flags: "dev" uses short-lived certificates,
+	// verbose logs (-v=4) and no leader election; "prod" uses long-lived
+	// certificates and conservative request and API write limits.
+	// Env: ACW_PROFILE
+	Profile string `envconfig:"PROFILE"`
+
 	// Namespace is the namespace where the webhook is deployed.
 	// If empty, auto-detected from ServiceAccount or defaults to "default".
 	// Env: ACW_NAMESPACE
@@ -58,10 +246,334 @@ type Config struct {
 	// Env: ACW_SERVICE_NAME
 	ServiceName string `envconfig:"SERVICE_NAME"`
 
+	// ServiceIPSANs adds the ClusterIPs of the service (both families on
+	// dual-stack clusters) to the serving certificate as IP SANs, for clients
+	// that call the webhook by IP. Requires the get verb on services.
+	// Env: ACW_SERVICE_IP_SANS
+	ServiceIPSANs *bool `envconfig:"SERVICE_IP_SANS"`
+
+	// HeadlessServiceName is a headless service in Namespace, e.g. the
+	// governing service of a StatefulSet running the webhook, whose names
+	// are added to the serving certificate so that the webhook can be called
+	// through it or per pod. The pods are covered by the wildcard
+	// *.<HeadlessServiceName>.<Namespace>.svc, or, with StatefulSetName, by
+	// their names <StatefulSetName>-<N>.<HeadlessServiceName>.<Namespace>.svc
+	// for N below StatefulSetReplicas, for clients that reject wildcards.
+	// Env: ACW_HEADLESS_SERVICE_NAME, ACW_STATEFULSET_NAME, ACW_STATEFULSET_REPLICAS
+	HeadlessServiceName string `envconfig:"HEADLESS_SERVICE_NAME"`
+	StatefulSetName     string `envconfig:"STATEFULSET_NAME"`
+	StatefulSetReplicas int    `envconfig:"STATEFULSET_REPLICAS"`
+
+	// ExternalHostnames are DNS names, possibly wildcards like
+	// "*.webhooks.example.com", and IP addresses added to the serving
+	// certificate, e.g. of an ingress or LoadBalancer, so that other clusters
+	// and controllers outside the cluster can call the webhook with TLS
+	// verification against the CA bundle.
+	// Env: ACW_EXTERNAL_HOSTNAMES (comma-separated)
+	ExternalHostnames []string `envconfig:"EXTERNAL_HOSTNAMES"`
+
+	// ServicePort is the port of the Kubernetes service for the webhook.
+	// Only used when ManageWebhookConfigurations is enabled.
+	// Env: ACW_SERVICE_PORT
+	ServicePort int32 `envconfig:"SERVICE_PORT" default:"443"`
+
+	// ManageWebhookConfigurations makes the framework create and update the
+	// MutatingWebhookConfiguration and/or ValidatingWebhookConfiguration named
+	// Config.Name from the hook definitions, instead of only patching caBundle
+	// on existing resources.
+	// Env: ACW_MANAGE_WEBHOOK_CONFIGURATIONS
+	ManageWebhookConfigurations *bool `envconfig:"MANAGE_WEBHOOK_CONFIGURATIONS"`
+
+	// DisabledHooks lists the paths of hooks to turn off without code changes.
+	// Disabled hooks allow every request without calling Admit, and are left
+	// out of managed webhook configurations.
+	// Env: ACW_HOOK_DISABLED (e.g., "/validate-pods,/mutate-pods")
+	DisabledHooks []string `envconfig:"HOOK_DISABLED"`
+
+	// The following fields override the registration settings of all hooks in
+	// managed webhook configurations, so that operators can tune a prebuilt
+	// image without rebuilding it. Empty values keep the hooks' own settings.
+
+	// WebhookFailurePolicy overrides Hook.FailurePolicy: "Fail" or "Ignore".
+	// Env: ACW_WEBHOOK_FAILURE_POLICY
+	WebhookFailurePolicy string `envconfig:"WEBHOOK_FAILURE_POLICY"`
+
+	// WebhookTimeoutSeconds overrides Hook.TimeoutSeconds, between 1 and 30.
+	// Env: ACW_WEBHOOK_TIMEOUT_SECONDS
+	WebhookTimeoutSeconds int32 `envconfig:"WEBHOOK_TIMEOUT_SECONDS"`
+
+	// WebhookNamespaceSelector overrides Hook.NamespaceSelector with a label
+	// selector, either in kubectl syntax or as a JSON LabelSelector.
+	// Env: ACW_WEBHOOK_NAMESPACE_SELECTOR (e.g., "env in (prod),!exempt")
+	WebhookNamespaceSelector string `envconfig:"WEBHOOK_NAMESPACE_SELECTOR"`
+
+	// WebhookSideEffects overrides Hook.SideEffects: "None" or "NoneOnDryRun".
+	// Env: ACW_WEBHOOK_SIDE_EFFECTS
+	WebhookSideEffects string `envconfig:"WEBHOOK_SIDE_EFFECTS"`
+
+	// SelfTestInterval makes the leader send a synthetic AdmissionReview to
+	// each entry of the webhook configurations at this interval, the way the
+	// API server calls them: through the Service DNS name, verifying the
+	// serving certificate with the entry's caBundle. Failures are logged,
+	// exported in admission_webhook_selftest_success and reported as events,
+	// catching a broken caBundle or Service before real requests hit them.
+	// Self-tests are authenticated like the API server and evaluated by the
+	// hooks as the dry-run creation of a ConfigMap in Namespace; any answer
+	// passes. With TokenReviewAuthentication, they carry the token of the
+	// pod's ServiceAccount. Zero disables.
+	// Env: ACW_SELF_TEST_INTERVAL (e.g., "5m")
+	SelfTestInterval time.Duration `envconfig:"SELF_TEST_INTERVAL"`
+
+	// SelfTestClientCertFile is the PEM client certificate that self-tests
+	// present, signed by a CA of ClientCAFile or ClientCAConfigMap. It is
+	// required for self-tests when client certificates are, and reloaded for
+	// every self-test.
+	// Env: ACW_SELF_TEST_CLIENT_CERT_FILE
+	SelfTestClientCertFile string `envconfig:"SELF_TEST_CLIENT_CERT_FILE"`
+
+	// SelfTestClientKeyFile is the private key of SelfTestClientCertFile.
+	// Env: ACW_SELF_TEST_CLIENT_KEY_FILE
+	SelfTestClientKeyFile string `envconfig:"SELF_TEST_CLIENT_KEY_FILE"`
+
+	// ExcludeNamespaces are namespaces the hooks never evaluate, protecting
+	// control-plane components from a misbehaving policy. They are excluded
+	// through the namespaceSelector of managed webhook entries, and requests
+	// from them that still reach the server are allowed without calling Admit.
+	// Requests on the excluded Namespace objects themselves are still evaluated.
+	// Set to an empty, non-nil slice in code to exclude no namespace.
+	// Env: ACW_EXCLUDE_NAMESPACES (default: "kube-system")
+	ExcludeNamespaces []string `envconfig:"EXCLUDE_NAMESPACES" default:"kube-system"`
+
+	// ExcludeOwnNamespace excludes the webhook's namespace through the
+	// namespaceSelector of managed webhook entries, so that the webhook's pods
+	// never have to be admitted by the webhook itself to start.
+	// Env: ACW_EXCLUDE_OWN_NAMESPACE (default: true)
+	ExcludeOwnNamespace *bool `envconfig:"EXCLUDE_OWN_NAMESPACE" default:"true"`
+
+	// ExcludeOwnPodsLabel excludes objects with this "key=value" label, such
+	// as the webhook's own pods, through the objectSelector of managed webhook
+	// entries, e.g. for webhooks that have to cover their own namespace.
+	// Env: ACW_EXCLUDE_OWN_PODS_LABEL (e.g., "app.kubernetes.io/name=my-webhook")
+	ExcludeOwnPodsLabel string `envconfig:"EXCLUDE_OWN_PODS_LABEL"`
+
+	// BindAddress is the host or IP address the webhook server listens on,
+	// e.g. "127.0.0.1" to only accept connections from a sidecar, or
+	// "unix:///var/run/webhook/webhook.sock" to listen on a Unix domain socket
+	// (Port is then ignored). Connections are served over TLS either way.
+	// If empty, the server listens on all interfaces.
+	// Env: ACW_BIND_ADDRESS
+	BindAddress string `envconfig:"BIND_ADDRESS"`
+
 	// Port is the port the webhook server listens on.
 	// Env: ACW_PORT
 	Port int `envconfig:"PORT" default:"8443"`
 
+	// IPFamily restricts the webhook and metrics servers to one address
+	// family: "IPv4" or "IPv6". If empty, they accept both on dual-stack hosts
+	// and whichever is available on single-stack hosts.
+	// Env: ACW_IP_FAMILY
+	IPFamily string `envconfig:"IP_FAMILY"`
+
+	// AllowYAMLRequests makes the webhook server also accept AdmissionReviews
+	// sent as application/yaml, which is convenient for test tooling.
+	// The API server always sends JSON.
+	// Env: ACW_ALLOW_YAML_REQUESTS
+	AllowYAMLRequests *bool `envconfig:"ALLOW_YAML_REQUESTS"`
+
+	// TLSMinVersion is the minimum TLS version of the webhook server:
+	// "1.0", "1.1", "1.2" or "1.3".
+	// Env: ACW_TLS_MIN_VERSION
+	TLSMinVersion string `envconfig:"TLS_MIN_VERSION" default:"1.2"`
+
+	// TLSMaxVersion is the maximum TLS version of the webhook server.
+	// If empty, the Go default (currently TLS 1.3) is used.
+	// Env: ACW_TLS_MAX_VERSION
+	TLSMaxVersion string `envconfig:"TLS_MAX_VERSION"`
+
+	// TLSCipherSuites lists the IANA names of the TLS 1.2 cipher suites the
+	// webhook server accepts, e.g. "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256".
+	// TLS 1.3 cipher suites are not configurable. If empty, the Go defaults are used.
+	// Env: ACW_TLS_CIPHER_SUITES (comma-separated)
+	TLSCipherSuites []string `envconfig:"TLS_CIPHER_SUITES"`
+
+	// TLSCurvePreferences lists the key exchange groups of the webhook server in
+	// preference order: X25519, X25519MLKEM768, P256, P384 or P521.
+	// If empty, the Go defaults are used.
+	// Env: ACW_TLS_CURVE_PREFERENCES (comma-separated)
+	TLSCurvePreferences []string `envconfig:"TLS_CURVE_PREFERENCES"`
+
+	// ClientCAFile is a PEM file of the CAs that must have signed the client
+	// certificate of admission requests, so that only the API server, and not
+	// any pod in the cluster, can call the hooks. The file is reloaded when it
+	// changes. Health endpoints do not require a client certificate.
+	// The API server presents a client certificate only if configured to,
+	// through the kubeconfig of its AdmissionConfiguration.
+	// If empty, client certificates are not required.
+	// Env: ACW_CLIENT_CA_FILE
+	ClientCAFile string `envconfig:"CLIENT_CA_FILE"`
+
+	// ClientCAConfigMap is the "namespace/name" of a ConfigMap holding the
+	// client CA, as an alternative to ClientCAFile, e.g.
+	// "kube-system/extension-apiserver-authentication". The ConfigMap is watched
+	// for changes, which requires get, list and watch on it.
+	// Env: ACW_CLIENT_CA_CONFIGMAP
+	ClientCAConfigMap string `envconfig:"CLIENT_CA_CONFIGMAP"`
+
+	// ClientCAConfigMapKey is the key of the client CA in ClientCAConfigMap.
+	// Env: ACW_CLIENT_CA_CONFIGMAP_KEY
+	ClientCAConfigMapKey string `envconfig:"CLIENT_CA_CONFIGMAP_KEY" default:"client-ca-file"`
+
+	// TokenReviewAuthentication requires admission requests to carry a bearer
+	// token that the API server accepts in a TokenReview, for setups that put
+	// an authenticating proxy in front of the webhook. Unauthenticated callers
+	// are answered with 401 before the request is decoded. Requires the create
+	// verb on tokenreviews, e.g. through the system:auth-delegator ClusterRole.
+	// Env: ACW_TOKEN_REVIEW_AUTHENTICATION
+	TokenReviewAuthentication *bool `envconfig:"TOKEN_REVIEW_AUTHENTICATION"`
+
+	// TokenReviewAudiences lists the audiences the bearer token must be valid
+	// for. If empty, the API server's audiences are used.
+	// Env: ACW_TOKEN_REVIEW_AUDIENCES (comma-separated)
+	TokenReviewAudiences []string `envconfig:"TOKEN_REVIEW_AUDIENCES"`
+
+	// TokenReviewCacheTTL is how long TokenReview results are cached.
+	// A negative value disables caching.
+	// Env: ACW_TOKEN_REVIEW_CACHE_TTL (e.g., "10s")
+	TokenReviewCacheTTL time.Duration `envconfig:"TOKEN_REVIEW_CACHE_TTL" default:"10s"`
+
+	// SubjectAccessReviewCacheTTL is how long the results of Hook.Exempt
+	// SubjectAccessReviews are cached. A negative value disables caching.
+	// Env: ACW_SUBJECT_ACCESS_REVIEW_CACHE_TTL (e.g., "10s")
+	SubjectAccessReviewCacheTTL time.Duration `envconfig:"SUBJECT_ACCESS_REVIEW_CACHE_TTL" default:"10s"`
+
+	// DisableHTTP2 restricts the webhook server to HTTP/1.1, e.g. when
+	// middleboxes mishandle HTTP/2 or to sidestep HTTP/2 rapid-reset attacks.
+	// Env: ACW_DISABLE_HTTP2
+	DisableHTTP2 *bool `envconfig:"DISABLE_HTTP2"`
+
+	// HTTP2MaxConcurrentStreams limits the number of concurrent streams per
+	// HTTP/2 connection. Zero uses the Go default (250).
+	// Env: ACW_HTTP2_MAX_CONCURRENT_STREAMS
+	HTTP2MaxConcurrentStreams int `envconfig:"HTTP2_MAX_CONCURRENT_STREAMS"`
+
+	// MaxInFlightRequests caps the number of admission requests handled
+	// concurrently across all hooks. Requests beyond the limit are answered with
+	// 503, or allowed without evaluation for hooks whose FailurePolicy is Ignore.
+	// Zero or a negative value disables the limit.
+	// Env: ACW_MAX_IN_FLIGHT_REQUESTS
+	MaxInFlightRequests int `envconfig:"MAX_IN_FLIGHT_REQUESTS"`
+
+	// RateLimitQPS is the sustained number of admission requests per second
+	// allowed per rate limit key. Requests beyond the limit are answered with
+	// 429 and a Retry-After header.
+	// Zero or a negative value disables request rate limiting.
+	// Env: ACW_RATE_LIMIT_QPS
+	RateLimitQPS float32 `envconfig:"RATE_LIMIT_QPS"`
+
+	// RateLimitBurst is the maximum number of admission requests allowed at once
+	// per rate limit key.
+	// Env: ACW_RATE_LIMIT_BURST
+	RateLimitBurst int `envconfig:"RATE_LIMIT_BURST" default:"20"`
+
+	// RateLimitBy selects the rate limit key: "path" (one bucket per hook),
+	// "user" (per hook and requesting user) or "namespace" (per hook and
+	// request namespace).
+	// Env: ACW_RATE_LIMIT_BY
+	RateLimitBy string `envconfig:"RATE_LIMIT_BY" default:"path"`
+
+	// LoadSheddingInFlight is the number of concurrently handled admission
+	// requests at which the server is considered overloaded. While overloaded,
+	// requests of hooks with FailurePolicy Ignore are allowed with a warning
+	// without evaluation, so best-effort hooks never delay fail-closed ones.
+	// Zero disables this threshold.
+	// Env: ACW_LOAD_SHEDDING_IN_FLIGHT
+	LoadSheddingInFlight int `envconfig:"LOAD_SHEDDING_IN_FLIGHT"`
+
+	// LoadSheddingLatency is the average admission latency over the last second
+	// at which the server is considered overloaded; see LoadSheddingInFlight.
+	// Zero disables this threshold.
+	// Env: ACW_LOAD_SHEDDING_LATENCY (e.g., "500ms")
+	LoadSheddingLatency time.Duration `envconfig:"LOAD_SHEDDING_LATENCY"`
+
+	// ShutdownDelay is how long the webhook server keeps serving after a
+	// shutdown signal while its readiness endpoint fails, so that the pod is
+	// removed from the Service endpoints before the listeners close and the
+	// API server does not hit closed connections during rollouts. In-flight
+	// requests are drained afterwards. Keep it below the pod's
+	// terminationGracePeriodSeconds.
+	// Env: ACW_SHUTDOWN_DELAY (e.g., "5s")
+	ShutdownDelay time.Duration `envconfig:"SHUTDOWN_DELAY" default:"5s"`
+
+	// ShutdownTimeout bounds how long in-flight requests are drained once
+	// the listeners close. The webhook server, metrics server and informers
+	// share one deadline of ShutdownDelay plus ShutdownTimeout; requests
+	// still running after it are cut off. ShutdownDelay plus ShutdownTimeout
+	// should stay below the pod's terminationGracePeriodSeconds.
+	// Env: ACW_SHUTDOWN_TIMEOUT (e.g., "10s")
+	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"5s"`
+
+	// MaxRestarts is how many consecutive failures of a subsystem (certificate
+	// provider, webhook and metrics servers, certificate manager, CA bundle
+	// syncer) are retried with exponential backoff, from 1s up to 1m, before
+	// RunWithContext returns the error. A subsystem that ran for a minute
+	// before failing starts counting again. Zero returns on the first failure.
+	// Env: ACW_MAX_RESTARTS
+	MaxRestarts int `envconfig:"MAX_RESTARTS" default:"5"`
+
+	// CertOnly runs only certificate management (certificate provider,
+	// manager and CA bundle syncer) without the admission server, to
+	// provision rotating TLS for a webhook served by another process in the
+	// same pod, e.g. a sidecar mounting the certificate secret. Hooks then
+	// only describe the webhook configurations; Admit is not required and
+	// hook listener and authentication options are ignored.
+	// Env: ACW_CERT_ONLY
+	CertOnly *bool `envconfig:"CERT_ONLY"`
+
+	// Uninstall deletes the resources the webhook manages and returns instead
+	// of running it: managed webhook configurations (the CA bundle of
+	// unmanaged ones is cleared), the CA bundle ConfigMaps, the CA and serving
+	// certificate secrets, including those of Services, and the leader
+	// election lease. See also Cleanup and CleanupWithClient.
+	// Env: ACW_UNINSTALL
+	Uninstall *bool `envconfig:"UNINSTALL"`
+
+	// CertDir, if set, is a directory every pod also writes the serving
+	// certificate (tls.crt), key (tls.key) and CA bundle (ca.crt) to, e.g. an
+	// emptyDir shared with a process that only reads TLS material from files.
+	// Files are replaced atomically on rotation.
+	// Env: ACW_CERT_DIR
+	CertDir string `envconfig:"CERT_DIR"`
+
+	// OnCertRotate, if set, is called with every newly loaded serving
+	// certificate, including the first one, e.g. to update other servers or
+	// clients that share it. It must not block. Only settable in code.
+	OnCertRotate func(tls.Certificate) `ignored:"true"`
+
+	// ExpiryAlertThreshold raises an expiry alert when the CA or serving
+	// certificate expires within this duration, which means its rotation is
+	// stuck. Zero uses half the time a certificate has left when it is
+	// normally rotated (by default 6h for the serving certificate and 12h for
+	// the CA). Alerts are logged and sent to ExpiryAlertWebhookURL and
+	// OnExpiryAlert, once per certificate and pod; the
+	// certificate_expiring metric reports the same condition.
+	// Env: ACW_EXPIRY_ALERT_THRESHOLD
+	ExpiryAlertThreshold time.Duration `envconfig:"EXPIRY_ALERT_THRESHOLD"`
+
+	// ExpiryAlertWebhookURL, if set, receives expiry alerts as a JSON POST
+	// whose "text" field makes it work with Slack-style incoming webhooks.
+	// Env: ACW_EXPIRY_ALERT_WEBHOOK_URL
+	ExpiryAlertWebhookURL string `envconfig:"EXPIRY_ALERT_WEBHOOK_URL"`
+
+	// OnExpiryAlert, if set, is called with every expiry alert. It must not
+	// block. Only settable in code.
+	OnExpiryAlert func(ExpiryAlert) `ignored:"true"`
+
+	// EventObject is the object the leader records certificate, CA bundle
+	// and leadership events on, e.g. a custom resource representing the
+	// webhook. Defaults to the controller of the pod, usually its Deployment,
+	// so that they show in kubectl describe. Only settable in code.
+	EventObject *corev1.ObjectReference `ignored:"true"`
+
 	// MetricsEnabled enables the metrics server.
 	// Env: ACW_METRICS_ENABLED
 	MetricsEnabled *bool `envconfig:"METRICS_ENABLED" default:"true"`
@@ -74,6 +586,47 @@ type Config struct {
 	// Env: ACW_METRICS_PATH
 	MetricsPath string `envconfig:"METRICS_PATH" default:"/metrics"`
 
+	// MetricsTLS serves metrics over HTTPS with the webhook's rotating serving
+	// certificate and TLS settings, for regimes that forbid plaintext metrics
+	// endpoints. Scrapers must trust the CA bundle.
+	// Env: ACW_METRICS_TLS
+	MetricsTLS *bool `envconfig:"METRICS_TLS"`
+
+	// MetricsClientCAFile is a PEM file of the CAs that sign the client
+	// certificates scrapers authenticate with. The file is reloaded when it
+	// changes. Requires MetricsTLS.
+	// Env: ACW_METRICS_CLIENT_CA_FILE
+	MetricsClientCAFile string `envconfig:"METRICS_CLIENT_CA_FILE"`
+
+	// MetricsTokenReviewAuthentication requires scrapes to carry a bearer
+	// token that the API server accepts in a TokenReview, such as the
+	// ServiceAccount token of Prometheus. With MetricsClientCAFile, either
+	// suffices. Uses TokenReviewAudiences and TokenReviewCacheTTL, and
+	// requires MetricsTLS.
+	// Env: ACW_METRICS_TOKEN_REVIEW_AUTHENTICATION
+	MetricsTokenReviewAuthentication *bool `envconfig:"METRICS_TOKEN_REVIEW_AUTHENTICATION"`
+
+	// MetricsOnWebhookServer serves metrics at MetricsPath of the webhook
+	// server instead of on MetricsPort, so that only one port needs to be
+	// exposed. Metrics are then always served over TLS; MetricsClientCAFile is
+	// not supported, as client certificates are verified against the client CA
+	// of the hooks. Cannot be combined with CertOnly.
+	// Env: ACW_METRICS_ON_WEBHOOK_SERVER
+	MetricsOnWebhookServer *bool `envconfig:"METRICS_ON_WEBHOOK_SERVER"`
+
+	// MetricsLatencyBuckets are the upper bounds in seconds of the admission
+	// latency histogram buckets, in increasing order, e.g. finer buckets for
+	// sub-millisecond hooks or coarser ones for slow policy engines.
+	// If empty, the Prometheus default buckets (5ms to 10s) are used.
+	// Env: ACW_METRICS_LATENCY_BUCKETS (comma-separated, e.g. "0.0005,0.001,0.005")
+	MetricsLatencyBuckets []float64 `envconfig:"METRICS_LATENCY_BUCKETS"`
+
+	// MetricsNativeHistograms also exposes the admission latency histogram as
+	// a native histogram, whose buckets adapt to the observed latencies.
+	// Prometheus must scrape with native histograms enabled to ingest it.
+	// Env: ACW_METRICS_NATIVE_HISTOGRAMS
+	MetricsNativeHistograms *bool `envconfig:"METRICS_NATIVE_HISTOGRAMS"`
+
 	// HealthzPath is the path for health check endpoint.
 	// Env: ACW_HEALTHZ_PATH
 	HealthzPath string `envconfig:"HEALTHZ_PATH" default:"/healthz"`
@@ -82,6 +635,56 @@ type Config struct {
 	// Env: ACW_READYZ_PATH
 	ReadyzPath string `envconfig:"READYZ_PATH" default:"/readyz"`
 
+	// ReadyzRequireCABundle makes the readiness endpoint fail until the
+	// caBundle of every entry of the webhook configurations trusts the pod's
+	// serving certificate, so that a rollout does not report ready while the
+	// API server still rejects the webhook's certificate. Requires the get
+	// verb on the webhook configurations.
+	// Env: ACW_READYZ_REQUIRE_CA_BUNDLE
+	ReadyzRequireCABundle *bool `envconfig:"READYZ_REQUIRE_CA_BUNDLE"`
+
+	// ReadyzRequireWebhookConfigurations makes the readiness endpoint fail
+	// until the MutatingWebhookConfiguration and/or
+	// ValidatingWebhookConfiguration named Config.Name exist, catching a
+	// forgotten webhook configuration. Implied by ReadyzRequireCABundle.
+	// Env: ACW_READYZ_REQUIRE_WEBHOOK_CONFIGURATIONS
+	ReadyzRequireWebhookConfigurations *bool `envconfig:"READYZ_REQUIRE_WEBHOOK_CONFIGURATIONS"`
+
+	// ReadyzRequireCertSync makes the readiness endpoint of the leader fail
+	// while its certificate syncs keep failing, from the third failure in a
+	// row, so that rotations silently failing on a live leader show up in the
+	// rollout status and alerts. Replicas that are not the leader stay
+	// ready.
+	// Env: ACW_READYZ_REQUIRE_CERT_SYNC
+	ReadyzRequireCertSync *bool `envconfig:"READYZ_REQUIRE_CERT_SYNC"`
+
+	// ReadinessChecks make the readiness endpoint fail while one of them
+	// returns an error, e.g. until a policy bundle is loaded or while a
+	// database the hooks depend on is unreachable. Only settable in code.
+	ReadinessChecks []HealthCheck `ignored:"true"`
+
+	// LivenessChecks make the health endpoint fail while one of them returns
+	// an error, so that the kubelet restarts a pod that cannot recover by
+	// itself. Only settable in code.
+	LivenessChecks []HealthCheck `ignored:"true"`
+
+	// Handlers are additional endpoints served on the main listener of the
+	// webhook server, e.g. a version endpoint or policy documentation. Like
+	// the health endpoints, they are not subject to the admission limits or
+	// caller authentication of the hooks. Only settable in code.
+	Handlers []HTTPHandler `ignored:"true"`
+
+	// ServeHandler, if set, is called once with the admission handler
+	// instead of starting the built-in webhook server, so that an
+	// application with an existing HTTPS server can mount the hooks itself
+	// while certificates are still managed. handler serves the hooks, the
+	// health endpoints and Handlers with the same limits and caller
+	// authentication; getCertificate returns the current serving
+	// certificate, for tls.Config.GetCertificate. The listener settings
+	// (Port, BindAddress, TLS and HTTP/2 options, ShutdownDelay) do not
+	// apply. It must not block. Only settable in code.
+	ServeHandler func(handler http.Handler, getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) `ignored:"true"`
+
 	// CASecretName is the name of the secret containing the CA certificate.
 	// If empty, defaults to "<Name>-ca".
 	// Env: ACW_CA_SECRET_NAME
@@ -97,6 +700,54 @@ type Config struct {
 	// Env: ACW_CA_BUNDLE_CONFIGMAP_NAME
 	CABundleConfigMapName string `envconfig:"CA_BUNDLE_CONFIGMAP_NAME"`
 
+	// CABundleNamespaces and the namespaces matching
+	// CABundleNamespaceSelector get a copy of the CA bundle ConfigMap, so
+	// that workloads calling the webhook service directly can mount the
+	// current CA without reading ConfigMaps across namespaces. Copies are
+	// labeled with certmanager.CABundleSourceLabel and deleted once their
+	// namespace is no longer targeted; existing ConfigMaps of the same name
+	// are left alone. The selector is in kubectl syntax, e.g.
+	// "ca-bundle=true", or a JSON metav1.LabelSelector.
+	// Env: ACW_CA_BUNDLE_NAMESPACES, ACW_CA_BUNDLE_NAMESPACE_SELECTOR
+	CABundleNamespaces        []string `envconfig:"CA_BUNDLE_NAMESPACES"`
+	CABundleNamespaceSelector string   `envconfig:"CA_BUNDLE_NAMESPACE_SELECTOR"`
+
+	// StatusConfigMapName is the name of a ConfigMap in Namespace that the
+	// leader keeps up to date with the health of the webhook, for GitOps
+	// tools and dashboards: its StatusConfigMapKey holds a WebhookStatus with
+	// the CertificatesReady, BundleSynced and Leader conditions. It is
+	// checked every CertSyncInterval and only written when it changes.
+	// Empty disables it.
+	// Env: ACW_STATUS_CONFIGMAP_NAME
+	StatusConfigMapName string `envconfig:"STATUS_CONFIGMAP_NAME"`
+
+	// CertDataKey and KeyDataKey are the keys of the certificate and private
+	// key in the CA and certificate secrets, for tooling that expects other
+	// key conventions, e.g. "cert.pem" and "key.pem". Secrets with other keys
+	// than the defaults are of type Opaque, as the API server requires the
+	// defaults in kubernetes.io/tls secrets. They are also the names of the
+	// files in CertDir.
+	// Env: ACW_CERT_DATA_KEY, ACW_KEY_DATA_KEY
+	CertDataKey string `envconfig:"CERT_DATA_KEY" default:"tls.crt"`
+	KeyDataKey  string `envconfig:"KEY_DATA_KEY" default:"tls.key"`
+
+	// CADataKey is the name of the CA bundle file in CertDir.
+	// Env: ACW_CA_DATA_KEY
+	CADataKey string `envconfig:"CA_DATA_KEY" default:"ca.crt"`
+
+	// CABundleDataKey is the key of the CA bundle in CABundleConfigMapName.
+	// Env: ACW_CA_BUNDLE_DATA_KEY
+	CABundleDataKey string `envconfig:"CA_BUNDLE_DATA_KEY" default:"ca-bundle.crt"`
+
+	// SecretType is the type of the CA and certificate secrets created by the
+	// leader: "kubernetes.io/tls" or "Opaque", e.g. for admission policies
+	// restricting secret types. Existing secrets, e.g. created beforehand to
+	// hold other material, keep their type and their other keys. If empty,
+	// it is "kubernetes.io/tls", or "Opaque" with custom CertDataKey or
+	// KeyDataKey.
+	// Env: ACW_SECRET_TYPE
+	SecretType string `envconfig:"SECRET_TYPE"`
+
 	// CAValidity is the validity duration of the CA certificate.
 	// Env: ACW_CA_VALIDITY (e.g., "48h")
 	CAValidity time.Duration `envconfig:"CA_VALIDITY" default:"48h"`
@@ -105,6 +756,33 @@ type Config struct {
 	// Env: ACW_CA_REFRESH (e.g., "24h")
 	CARefresh time.Duration `envconfig:"CA_REFRESH" default:"24h"`
 
+	// CAOverlap is how long the previous CA stays in the CA bundle after a
+	// CA rotation, so that certificates it signed stay trusted while the new
+	// bundle propagates to the API servers and other clients. It must not
+	// exceed CAValidity minus CARefresh, when the previous CA expires anyway.
+	// Zero keeps it until it expires.
+	// Env: ACW_CA_OVERLAP (e.g., "1h")
+	CAOverlap time.Duration `envconfig:"CA_OVERLAP"`
+
+	// CABundleMaxCerts caps the number of CAs kept in the CA bundle, removing
+	// the ones that expire first, so that CAs accumulated by frequent
+	// rotations do not bloat every webhook configuration. The current CA is
+	// always kept and expired CAs are always removed. Zero means no limit.
+	// Env: ACW_CA_BUNDLE_MAX_CERTS
+	CABundleMaxCerts int `envconfig:"CA_BUNDLE_MAX_CERTS"`
+
+	// CABackupFile is a CA exported with "acw backup" or certmanager.ExportCA
+	// that is restored when the CA secret does not exist, instead of creating
+	// a new CA, so that rebuilding the cluster or deleting the secret by
+	// accident does not invalidate the trust of external clients.
+	// Env: ACW_CA_BACKUP_FILE
+	CABackupFile string `envconfig:"CA_BACKUP_FILE"`
+
+	// CABackupPassphrase decrypts the private key of CABackupFile if it was
+	// exported with a passphrase.
+	// Env: ACW_CA_BACKUP_PASSPHRASE
+	CABackupPassphrase string `envconfig:"CA_BACKUP_PASSPHRASE"`
+
 	// CertValidity is the validity duration of the server certificate.
 	// Env: ACW_CERT_VALIDITY (e.g., "24h")
 	CertValidity time.Duration `envconfig:"CERT_VALIDITY" default:"24h"`
@@ -113,10 +791,126 @@ type Config struct {
 	// Env: ACW_CERT_REFRESH (e.g., "12h")
 	CertRefresh time.Duration `envconfig:"CERT_REFRESH" default:"12h"`
 
-	// CertSyncInterval is the interval between certificate sync checks.
+	// ServingKeyRotationPolicy is "Always" to generate a new private key with
+	// every serving certificate, as some HSM and compliance setups require, or
+	// "Never" to keep the existing key across rotations, e.g. for clients that
+	// pin it. A rotation requested with "acw rotate" always generates a new
+	// key.
+	// Env: ACW_SERVING_KEY_ROTATION_POLICY
+	ServingKeyRotationPolicy string `envconfig:"SERVING_KEY_ROTATION_POLICY" default:"Always"`
+
+	// KeystorePasswordSecretName names a secret in Namespace holding a
+	// password. If set, the serving certificate and key are also written as
+	// a PKCS#12 keystore, encrypted with the password, to the "keystore.p12"
+	// key of the serving certificate secret on every rotation, for Java-based
+	// consumers of the secret. Additional services get no keystore.
+	// Env: ACW_KEYSTORE_PASSWORD_SECRET_NAME
+	KeystorePasswordSecretName string `envconfig:"KEYSTORE_PASSWORD_SECRET_NAME"`
+
+	// KeystorePasswordSecretKey is the key of the password in
+	// KeystorePasswordSecretName.
+	// Env: ACW_KEYSTORE_PASSWORD_SECRET_KEY
+	KeystorePasswordSecretKey string `envconfig:"KEYSTORE_PASSWORD_SECRET_KEY" default:"password"`
+
+	// SignatureAlgorithm is the signature algorithm of the CA and serving
+	// certificates: "SHA256-RSA", "SHA384-RSA", "ECDSA-SHA256" or
+	// "ECDSA-SHA384". ECDSA uses P-256 or P-384 keys and RSA 2048-bit keys.
+	// Changing it replaces the CA, whose predecessor stays in the CA bundle
+	// like after a rotation, and the serving certificate.
+	// Env: ACW_SIGNATURE_ALGORITHM
+	SignatureAlgorithm string `envconfig:"SIGNATURE_ALGORITHM" default:"SHA256-RSA"`
+
+	// CASubjectOrganization and CASubjectOrganizationalUnit are the O and OU
+	// of the CA certificates, e.g. to satisfy auditing conventions. CAs with
+	// others are replaced on the next sync.
+	// Env: ACW_CA_SUBJECT_ORGANIZATION, ACW_CA_SUBJECT_ORGANIZATIONAL_UNIT (comma-separated)
+	CASubjectOrganization       []string `envconfig:"CA_SUBJECT_ORGANIZATION"`
+	CASubjectOrganizationalUnit []string `envconfig:"CA_SUBJECT_ORGANIZATIONAL_UNIT"`
+
+	// CASubjectCommonName is a Go template of the common name of the CA
+	// certificates, executed with certmanager.SubjectData, e.g.
+	// "{{.ServiceName}} CA {{.Timestamp}}". It must include {{.Timestamp}}
+	// so that each CA gets its own name. If empty, CAs are named
+	// "<namespace>_<secret name>@<Unix time>".
+	// Env: ACW_CA_SUBJECT_COMMON_NAME
+	CASubjectCommonName string `envconfig:"CA_SUBJECT_COMMON_NAME"`
+
+	// ServingSubjectOrganization and ServingSubjectOrganizationalUnit are the
+	// O and OU of the serving certificates. Certificates with others are
+	// replaced on the next sync.
+	// Env: ACW_SERVING_SUBJECT_ORGANIZATION, ACW_SERVING_SUBJECT_ORGANIZATIONAL_UNIT (comma-separated)
+	ServingSubjectOrganization       []string `envconfig:"SERVING_SUBJECT_ORGANIZATION"`
+	ServingSubjectOrganizationalUnit []string `envconfig:"SERVING_SUBJECT_ORGANIZATIONAL_UNIT"`
+
+	// ServingSubjectCommonName is a Go template of the common name of the
+	// serving certificates, executed with certmanager.SubjectData. If empty,
+	// it is the first DNS name, e.g. "<service>.<namespace>.svc".
+	// Env: ACW_SERVING_SUBJECT_COMMON_NAME
+	ServingSubjectCommonName string `envconfig:"SERVING_SUBJECT_COMMON_NAME"`
+
+	// Services are additional services whose certificates the leader
+	// maintains, with the same validity and refresh settings as the
+	// webhook's own. Code only; not configurable from the environment.
+	Services []ServiceCertificate `ignored:"true"`
+
+	// CertSyncInterval is the interval between certificate sync checks of
+	// the leader, and between the expiry checks of every pod. It must be
+	// positive and shorter than the time between the refresh and the expiry
+	// of the CA and serving certificate. Syncs are spread by up to 20% of
+	// jitter, and failed syncs are retried with a backoff from one second up
+	// to CertSyncInterval.
 	// Env: ACW_CERT_SYNC_INTERVAL (e.g., "1m")
 	CertSyncInterval time.Duration `envconfig:"CERT_SYNC_INTERVAL" default:"1m"`
 
+	// InformerResyncPeriod is how often informers redeliver cached secrets and
+	// ConfigMaps to their handlers, and how often the serving certificate is
+	// re-read from the API server bypassing the cache, so that a broken watch
+	// cannot leave a stale certificate in use indefinitely. Failed watches are
+	// logged, counted in admission_webhook_informer_watch_errors_total and
+	// relisted with client-go's backoff (800ms up to 30s). A negative value
+	// disables resyncs.
+	// Env: ACW_INFORMER_RESYNC_PERIOD (e.g., "10m", "0" to disable)
+	InformerResyncPeriod time.Duration `envconfig:"INFORMER_RESYNC_PERIOD" default:"10m"`
+
+	// CABundleResyncInterval is the interval at which the CA bundle is re-injected
+	// into the webhook configurations even without ConfigMap changes.
+	// A negative value disables periodic re-injection; zero means the default.
+	// Env: ACW_CA_BUNDLE_RESYNC_INTERVAL (e.g., "1h", "-1s" to disable)
+	CABundleResyncInterval time.Duration `envconfig:"CA_BUNDLE_RESYNC_INTERVAL" default:"1h"`
+
+	// APIWriteQPS is the sustained rate of Kubernetes API writes (secret, configmap,
+	// webhook configuration and event writes) shared by all subsystems.
+	// Certificate writes are prioritized over webhook patches, and both over events.
+	// Leader election lease renewals are not limited.
+	// A negative value disables write rate limiting.
+	// Env: ACW_API_WRITE_QPS
+	APIWriteQPS float32 `envconfig:"API_WRITE_QPS" default:"5"`
+
+	// APIWriteBurst is the maximum number of Kubernetes API writes issued at once.
+	// Env: ACW_API_WRITE_BURST
+	APIWriteBurst int `envconfig:"API_WRITE_BURST" default:"10"`
+
+	// ClientQPS is the sustained rate of all Kubernetes API requests of the
+	// client, on top of the write limit. A negative value disables client-side
+	// throttling.
+	// Env: ACW_CLIENT_QPS
+	ClientQPS float32 `envconfig:"CLIENT_QPS" default:"5"`
+
+	// ClientBurst is the maximum number of Kubernetes API requests issued at once.
+	// Env: ACW_CLIENT_BURST
+	ClientBurst int `envconfig:"CLIENT_BURST" default:"10"`
+
+	// ClientTimeout bounds each Kubernetes API request other than watches.
+	// Zero disables the timeout.
+	// Env: ACW_CLIENT_TIMEOUT (e.g., "30s")
+	ClientTimeout time.Duration `envconfig:"CLIENT_TIMEOUT" default:"30s"`
+
+	// UserAgent is the user agent of the Kubernetes client, which identifies
+	// the library's requests in API server logs and audit events.
+	// Defaults to "auto-cert-webhook/<version>".
+	// Env: ACW_USER_AGENT
+	UserAgent string `envconfig:"USER_AGENT"`
+
 	// LeaderElection enables leader election for certificate rotation.
 	// Env: ACW_LEADER_ELECTION
 	LeaderElection *bool `envconfig:"LEADER_ELECTION"`
@@ -137,6 +931,11 @@ type Config struct {
 	// RetryPeriod is the period between leader election retries.
 	// Env: ACW_RETRY_PERIOD (e.g., "5s")
 	RetryPeriod time.Duration `envconfig:"RETRY_PERIOD" default:"5s"`
+
+	// Flags, if set, overrides fields with the command-line flags that were
+	// set, taking priority over code, environment variables and defaults.
+	// Only settable in code.
+	Flags *ConfigFlags `ignored:"true"`
 }
 
 // Admission is the main interface that users need to implement.
@@ -0,0 +1,55 @@
+package autocertwebhook
+
+import (
+	"slices"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+const (
+	// serviceAccountUsernamePrefix prefixes the usernames of service
+	// accounts, which are "system:serviceaccount:<namespace>:<name>".
+	serviceAccountUsernamePrefix = "system:serviceaccount:"
+
+	// SystemMastersGroup is the group whose members bypass authorization.
+	SystemMastersGroup = "system:masters"
+)
+
+// IsServiceAccount returns true if the request was made by the service
+// account name in namespace. An empty name matches any service account of
+// namespace, and an empty namespace any service account at all.
+func IsServiceAccount(ar admissionv1.AdmissionReview, namespace, name string) bool {
+	if ar.Request == nil {
+		return false
+	}
+	rest, ok := strings.CutPrefix(ar.Request.UserInfo.Username, serviceAccountUsernamePrefix)
+	if !ok {
+		return false
+	}
+	saNamespace, saName, ok := strings.Cut(rest, ":")
+	if !ok || saNamespace == "" || saName == "" {
+		return false
+	}
+	return (namespace == "" || namespace == saNamespace) && (name == "" || name == saName)
+}
+
+// InGroup returns true if the user making the request is a member of any of
+// the groups.
+func InGroup(ar admissionv1.AdmissionReview, groups ...string) bool {
+	if ar.Request == nil {
+		return false
+	}
+	return slices.ContainsFunc(ar.Request.UserInfo.Groups, func(group string) bool {
+		return slices.Contains(groups, group)
+	})
+}
+
+// IsClusterAdminLike returns true if the user making the request is a member
+// of the system:masters group, like the admin credentials of kubeadm and most
+// managed clusters, which bypass authorization altogether. Users granted the
+// cluster-admin ClusterRole through RBAC are not recognized from the request;
+// use Hook.Exempt to let RBAC decide.
+func IsClusterAdminLike(ar admissionv1.AdmissionReview) bool {
+	return InGroup(ar, SystemMastersGroup)
+}
@@ -0,0 +1,67 @@
+package autocertwebhook
+
+import (
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+// userReview returns an AdmissionReview of a request made by user.
+func userReview(username string, groups ...string) admissionv1.AdmissionReview {
+	return admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{
+		UserInfo: authenticationv1.UserInfo{Username: username, Groups: groups},
+	}}
+}
+
+func TestIsServiceAccount(t *testing.T) {
+	tests := []struct {
+		name      string
+		username  string
+		namespace string
+		saName    string
+		want      bool
+	}{
+		{name: "exact", username: "system:serviceaccount:kube-system:replicaset-controller", namespace: "kube-system", saName: "replicaset-controller", want: true},
+		{name: "other name", username: "system:serviceaccount:kube-system:replicaset-controller", namespace: "kube-system", saName: "job-controller"},
+		{name: "other namespace", username: "system:serviceaccount:default:replicaset-controller", namespace: "kube-system", saName: "replicaset-controller"},
+		{name: "any in namespace", username: "system:serviceaccount:kube-system:job-controller", namespace: "kube-system", want: true},
+		{name: "any", username: "system:serviceaccount:team-a:deployer", want: true},
+		{name: "user", username: "alice", namespace: "kube-system"},
+		{name: "lookalike user", username: "system:serviceaccount:kube-system", namespace: "kube-system"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsServiceAccount(userReview(tt.username), tt.namespace, tt.saName); got != tt.want {
+				t.Errorf("IsServiceAccount: got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if IsServiceAccount(admissionv1.AdmissionReview{}, "", "") {
+		t.Error("IsServiceAccount: got true for a nil request")
+	}
+}
+
+func TestInGroup(t *testing.T) {
+	ar := userReview("alice", "system:authenticated", "platform")
+	if !InGroup(ar, "platform", "security") {
+		t.Error("InGroup: expected a member of platform")
+	}
+	if InGroup(ar, "security") || InGroup(ar) {
+		t.Error("InGroup: expected no member of security or of no groups")
+	}
+	if InGroup(admissionv1.AdmissionReview{}, "platform") {
+		t.Error("InGroup: got true for a nil request")
+	}
+}
+
+func TestIsClusterAdminLike(t *testing.T) {
+	if !IsClusterAdminLike(userReview("kubernetes-admin", SystemMastersGroup, "system:authenticated")) {
+		t.Error("IsClusterAdminLike: expected true for a member of system:masters")
+	}
+	if IsClusterAdminLike(userReview("alice", "system:authenticated")) {
+		t.Error("IsClusterAdminLike: expected false for an ordinary user")
+	}
+}
@@ -0,0 +1,119 @@
+package autocertwebhook
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// fieldError is a configuration error caused by the values of Config
+// fields, which are reported along with where each value came from.
+type fieldError struct {
+	fields []string
+	err    error
+}
+
+func (e *fieldError) Error() string { return e.err.Error() }
+
+func (e *fieldError) Unwrap() error { return e.err }
+
+// withFields attributes err to the given Config fields. A nil err stays nil.
+func withFields(err error, fields ...string) error {
+	if err == nil {
+		return nil
+	}
+	return &fieldError{fields: fields, err: err}
+}
+
+// fieldErrorf returns an error caused by the value of a Config field.
+func fieldErrorf(field, format string, args ...any) error {
+	return withFields(fmt.Errorf(format, args...), field)
+}
+
+// appendErr appends err, if any, to errs, flattening joined errors so that
+// each problem is reported on its own.
+func appendErr(errs []error, err error) []error {
+	if err == nil {
+		return errs
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return append(errs, joined.Unwrap()...)
+	}
+	return append(errs, err)
+}
+
+// configError reports all problems of a configuration at once.
+type configError struct {
+	errs []error
+	// lines holds the message of each error with the source of its fields.
+	lines []string
+}
+
+func (e *configError) Error() string {
+	if len(e.lines) == 1 {
+		return "invalid configuration: " + e.lines[0]
+	}
+	return fmt.Sprintf("invalid configuration (%d problems):\n  %s", len(e.lines), strings.Join(e.lines, "\n  "))
+}
+
+func (e *configError) Unwrap() []error { return e.errs }
+
+// configSources tells where the values of Config fields came from.
+type configSources struct {
+	// code is the configuration returned by Configure.
+	code  *Config
+	flags *ConfigFlags
+	// profile is the profile whose values were applied, if any.
+	profile string
+}
+
+func newConfigSources(code *Config, flags *ConfigFlags) configSources {
+	return configSources{code: code, flags: flags}
+}
+
+// source returns where the value of a Config field came from: a flag, code,
+// an environment variable, the profile or the default.
+func (s configSources) source(field string) string {
+	f, ok := reflect.TypeOf(Config{}).FieldByName(field)
+	if !ok {
+		return "code"
+	}
+	env, hasEnv := f.Tag.Lookup("envconfig")
+	if hasEnv && f.Tag.Get("ignored") == "true" {
+		hasEnv = false
+	}
+	if hasEnv && s.flags.changed(env) {
+		return "--" + flagName(env)
+	}
+	if !reflect.ValueOf(s.code).Elem().FieldByIndex(f.Index).IsZero() || !hasEnv {
+		return "code"
+	}
+	if _, ok := os.LookupEnv(envPrefix + "_" + env); ok {
+		return envPrefix + "_" + env
+	}
+	if _, ok := profiles[s.profile].values[field]; ok {
+		return "profile " + s.profile
+	}
+	return "default"
+}
+
+// aggregate combines errs into a single error that lists the source of the
+// values of the fields each one is attributed to.
+func (s configSources) aggregate(errs []error) error {
+	lines := make([]string, 0, len(errs))
+	for _, err := range errs {
+		line := err.Error()
+		var fe *fieldError
+		if errors.As(err, &fe) {
+			sources := make([]string, 0, len(fe.fields))
+			for _, field := range fe.fields {
+				sources = append(sources, field+" from "+s.source(field))
+			}
+			line += " (" + strings.Join(sources, ", ") + ")"
+		}
+		lines = append(lines, line)
+	}
+	return &configError{errs: errs, lines: lines}
+}
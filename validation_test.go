@@ -0,0 +1,95 @@
+package autocertwebhook
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRunWithClient_AggregatesConfigErrors(t *testing.T) {
+	t.Setenv("ACW_CERT_REFRESH", "-1h")
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags := NewConfigFlags()
+	flags.AddFlags(fs)
+	if err := fs.Parse([]string{"--rate-limit-by=pod"}); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	admission := &testAdmission{
+		cfg: Config{
+			Namespace:       "ns",
+			ShutdownTimeout: -time.Second,
+			Flags:           flags,
+		},
+		hooks: []Hook{{Path: "validate", Type: Validating}},
+	}
+	err := RunWithClient(context.Background(), fake.NewSimpleClientset(), admission)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	msg := err.Error()
+	for _, want := range []string{
+		"(6 problems)",
+		"webhook name is required in Configure(), ACW_NAME or --name (Name from default)",
+		"hook[0]: path must start with '/'",
+		"hook[0]: admit function is required",
+		"cert refresh must be positive, got -1h0m0s (CertRefresh from ACW_CERT_REFRESH)",
+		"shutdown timeout must be positive, got -1s (ShutdownTimeout from code)",
+		`got "pod" (RateLimitBy from --rate-limit-by)`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Expected error to contain %q, got:\n%s", want, msg)
+		}
+	}
+}
+
+func TestConfigSources_aggregate(t *testing.T) {
+	sources := newConfigSources(&Config{}, nil)
+
+	err := sources.aggregate([]error{fieldErrorf("MaxRestarts", "max restarts must not be negative, got -1")})
+	if got, want := err.Error(), "invalid configuration: max restarts must not be negative, got -1 (MaxRestarts from default)"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+
+	// Fields without an environment variable can only be set in code
+	err = sources.aggregate([]error{fieldErrorf("Services", "services[0]: service name is required")})
+	if !strings.HasSuffix(err.Error(), "(Services from code)") {
+		t.Errorf("Error() = %q, want the source to be code", err.Error())
+	}
+}
+
+func TestAppendErr(t *testing.T) {
+	errs := appendErr(nil, nil)
+	errs = appendErr(errs, validateCertDurations(&Config{CAValidity: time.Hour, CARefresh: 2 * time.Hour}))
+	// Zero cert validity and refresh, and CA refresh above CA validity
+	if len(errs) != 3 {
+		t.Errorf("Expected joined errors to be flattened into 3, got %d: %v", len(errs), errs)
+	}
+}
+
+func TestRunWithClient_UnknownDisabledHook(t *testing.T) {
+	t.Setenv("ACW_HOOK_DISABLED", "/validate,/mutate")
+
+	admission := &testAdmission{
+		cfg: Config{Name: "wh", Namespace: "ns"},
+		hooks: []Hook{{
+			Path: "/validate",
+			Type: Validating,
+			Admit: func(admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+				return Allowed()
+			},
+		}},
+	}
+	err := RunWithClient(context.Background(), fake.NewSimpleClientset(), admission)
+	want := `invalid configuration: disabled hook "/mutate" is not the path of a hook (DisabledHooks from ACW_HOOK_DISABLED)`
+	if err == nil || err.Error() != want {
+		t.Errorf("RunWithClient() error = %v, want %q", err, want)
+	}
+}
@@ -0,0 +1,238 @@
+package autocertwebhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	listerscorev1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/jimyag/auto-cert-webhook/pkg/certmanager"
+)
+
+// StatusConfigMapKey is the key of the WebhookStatus, as JSON, in the
+// ConfigMap named by Config.StatusConfigMapName.
+const StatusConfigMapKey = "status.json"
+
+// Condition types of a WebhookStatus.
+const (
+	// ConditionCertificatesReady is true when the CA and serving
+	// certificates of every service exist, are valid and syncing them
+	// succeeds.
+	ConditionCertificatesReady = "CertificatesReady"
+	// ConditionBundleSynced is true when the current CA bundle is injected
+	// into all webhook configurations.
+	ConditionBundleSynced = "BundleSynced"
+	// ConditionLeader is true when a replica manages the certificates; its
+	// message names it.
+	ConditionLeader = "Leader"
+)
+
+// WebhookStatus is the health of the webhook as reported by the leader in the
+// ConfigMap named by Config.StatusConfigMapName, for GitOps tools and
+// dashboards to read declaratively.
+type WebhookStatus struct {
+	// Leader is the identity of the replica managing the certificates.
+	Leader string `json:"leader,omitempty"`
+
+	// LastRotation is when the newest CA or serving certificate was issued.
+	LastRotation *metav1.Time `json:"lastRotation,omitempty"`
+
+	// Conditions are the CertificatesReady, BundleSynced and Leader
+	// conditions.
+	Conditions []metav1.Condition `json:"conditions"`
+}
+
+// certStatusSource is the part of a certmanager.Manager the status reporter
+// reads.
+type certStatusSource interface {
+	Namespace() string
+	ServiceName() string
+	Status() (certmanager.Status, error)
+	Healthy() error
+}
+
+// bundleStatusSource is the part of a cabundle.Syncer the status reporter
+// reads.
+type bundleStatusSource interface {
+	Status() (injected string, err error)
+}
+
+// statusReporter keeps the status ConfigMap up to date with the state of the
+// certificate managers and the CA bundle syncer. It runs on the leader and
+// only writes the ConfigMap when the status changes.
+type statusReporter struct {
+	client    kubernetes.Interface
+	namespace string
+	name      string
+	interval  time.Duration
+
+	certMgrs []certStatusSource
+	syncer   bundleStatusSource
+
+	// caBundles reads the CA bundle ConfigMap, caBundleName, whose
+	// caBundleKey the syncer injects.
+	caBundles    listerscorev1.ConfigMapLister
+	caBundleName string
+	caBundleKey  string
+
+	// identity is the leader identity, empty without leader election.
+	identity string
+}
+
+// Run reports the status every interval until ctx is cancelled.
+func (r *statusReporter) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.report(ctx); err != nil {
+			klog.Errorf("Failed to report status to ConfigMap %s/%s: %v", r.namespace, r.name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// report writes the current status to the status ConfigMap if it changed.
+func (r *statusReporter) report(ctx context.Context) error {
+	client := r.client.CoreV1().ConfigMaps(r.namespace)
+	cm, err := client.Get(ctx, r.name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+
+	// Conditions keep their transition time while their status holds
+	var status WebhookStatus
+	if exists && cm.Data[StatusConfigMapKey] != "" {
+		if err := json.Unmarshal([]byte(cm.Data[StatusConfigMapKey]), &status); err != nil {
+			klog.Warningf("Replacing invalid status in ConfigMap %s/%s: %v", r.namespace, r.name, err)
+			status = WebhookStatus{}
+		}
+	}
+	r.update(&status, time.Now())
+
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	if exists && cm.Data[StatusConfigMapKey] == string(data) {
+		return nil
+	}
+
+	if !exists {
+		_, err = client.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: r.name, Namespace: r.namespace},
+			Data:       map[string]string{StatusConfigMapKey: string(data)},
+		}, metav1.CreateOptions{})
+		return err
+	}
+	cm = cm.DeepCopy()
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[StatusConfigMapKey] = string(data)
+	_, err = client.Update(ctx, cm, metav1.UpdateOptions{})
+	return err
+}
+
+// update sets the leader, last rotation and conditions of status.
+func (r *statusReporter) update(status *WebhookStatus, now time.Time) {
+	status.Leader = r.identity
+
+	status.LastRotation = nil
+	ready := metav1.Condition{Type: ConditionCertificatesReady, Status: metav1.ConditionTrue, Reason: "Issued"}
+	var problems, expiries []string
+	for _, certMgr := range r.certMgrs {
+		service := certMgr.Namespace() + "/" + certMgr.ServiceName()
+		st, err := certMgr.Status()
+		if err != nil {
+			setFalse(&ready, "NotStarted", &problems, fmt.Sprintf("service %s: %v", service, err))
+			continue
+		}
+		for _, cert := range []*certmanager.CertificateInfo{st.CA, st.ServingCertificate} {
+			if cert != nil && (status.LastRotation == nil || cert.NotBefore.After(status.LastRotation.Time)) {
+				status.LastRotation = &metav1.Time{Time: cert.NotBefore.UTC()}
+			}
+		}
+		switch {
+		case st.CA == nil || st.ServingCertificate == nil:
+			setFalse(&ready, "NotIssued", &problems, fmt.Sprintf("service %s: certificates not issued yet", service))
+		case !now.Before(st.CA.NotAfter) || !now.Before(st.ServingCertificate.NotAfter):
+			setFalse(&ready, "Expired", &problems, fmt.Sprintf("service %s: certificate expired", service))
+		default:
+			if err := certMgr.Healthy(); err != nil {
+				setFalse(&ready, "SyncFailing", &problems, err.Error())
+				continue
+			}
+			expiries = append(expiries, fmt.Sprintf("service %s: serving certificate expires at %s",
+				service, st.ServingCertificate.NotAfter.UTC().Format(time.RFC3339)))
+		}
+	}
+	if ready.Status == metav1.ConditionTrue {
+		ready.Message = strings.Join(expiries, "; ")
+	} else {
+		ready.Message = strings.Join(problems, "; ")
+	}
+	meta.SetStatusCondition(&status.Conditions, ready)
+
+	meta.SetStatusCondition(&status.Conditions, r.bundleCondition())
+
+	leader := metav1.Condition{Type: ConditionLeader, Status: metav1.ConditionTrue, Reason: "LeaderElected",
+		Message: fmt.Sprintf("%s manages the certificates", r.identity)}
+	if r.identity == "" {
+		leader.Reason = "LeaderElectionDisabled"
+		leader.Message = "the single replica manages the certificates"
+	}
+	meta.SetStatusCondition(&status.Conditions, leader)
+}
+
+// bundleCondition returns the BundleSynced condition.
+func (r *statusReporter) bundleCondition() metav1.Condition {
+	cond := metav1.Condition{Type: ConditionBundleSynced, Status: metav1.ConditionFalse}
+	cm, err := r.caBundles.ConfigMaps(r.namespace).Get(r.caBundleName)
+	if err != nil && !apierrors.IsNotFound(err) {
+		cond.Reason, cond.Message = "Unknown", err.Error()
+		return cond
+	}
+	if err != nil || cm.Data[r.caBundleKey] == "" {
+		cond.Reason = "CABundleMissing"
+		cond.Message = fmt.Sprintf("CA bundle ConfigMap %s/%s has no %s yet", r.namespace, r.caBundleName, r.caBundleKey)
+		return cond
+	}
+
+	injected, err := r.syncer.Status()
+	switch {
+	case err != nil:
+		cond.Reason, cond.Message = "InjectionFailed", err.Error()
+	case injected != cm.Data[r.caBundleKey]:
+		cond.Reason, cond.Message = "Pending", "the current CA bundle is not injected yet"
+	default:
+		cond.Status = metav1.ConditionTrue
+		cond.Reason, cond.Message = "Injected", "the current CA bundle is injected into all webhook configurations"
+	}
+	return cond
+}
+
+// setFalse marks cond false with the reason of its first problem and records
+// message.
+func setFalse(cond *metav1.Condition, reason string, problems *[]string, message string) {
+	if cond.Status == metav1.ConditionTrue {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = reason
+	}
+	*problems = append(*problems, message)
+}
@@ -0,0 +1,145 @@
+package autocertwebhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/jimyag/auto-cert-webhook/pkg/certmanager"
+)
+
+// fakeCertStatus is a certStatusSource with a fixed status.
+type fakeCertStatus struct {
+	status  certmanager.Status
+	err     error
+	healthy error
+}
+
+func (f *fakeCertStatus) Namespace() string                   { return "ns" }
+func (f *fakeCertStatus) ServiceName() string                 { return "wh" }
+func (f *fakeCertStatus) Status() (certmanager.Status, error) { return f.status, f.err }
+func (f *fakeCertStatus) Healthy() error                      { return f.healthy }
+
+// fakeBundleStatus is a bundleStatusSource with a fixed status.
+type fakeBundleStatus struct {
+	injected string
+	err      error
+}
+
+func (f *fakeBundleStatus) Status() (string, error) { return f.injected, f.err }
+
+func TestStatusReporter(t *testing.T) {
+	caBundle := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "wh-ca-bundle", Namespace: "ns"},
+		Data:       map[string]string{"ca-bundle.crt": "ca-1"},
+	}
+	client := fake.NewSimpleClientset(caBundle)
+	factory := informers.NewSharedInformerFactory(client, 0)
+	configMaps := factory.Core().V1().ConfigMaps()
+	if err := configMaps.Informer().GetIndexer().Add(caBundle); err != nil {
+		t.Fatalf("Failed to add CA bundle: %v", err)
+	}
+
+	issued := time.Now().Add(-time.Hour).Truncate(time.Second)
+	certs := &fakeCertStatus{err: errors.New("certificate manager not started")}
+	bundle := &fakeBundleStatus{}
+	reporter := &statusReporter{
+		client:       client,
+		namespace:    "ns",
+		name:         "wh-status",
+		certMgrs:     []certStatusSource{certs},
+		syncer:       bundle,
+		caBundles:    configMaps.Lister(),
+		caBundleName: "wh-ca-bundle",
+		caBundleKey:  "ca-bundle.crt",
+		identity:     "wh-0",
+	}
+	ctx := context.Background()
+
+	read := func() WebhookStatus {
+		t.Helper()
+		cm, err := client.CoreV1().ConfigMaps("ns").Get(ctx, "wh-status", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get status ConfigMap: %v", err)
+		}
+		var status WebhookStatus
+		if err := json.Unmarshal([]byte(cm.Data[StatusConfigMapKey]), &status); err != nil {
+			t.Fatalf("Failed to decode status: %v", err)
+		}
+		return status
+	}
+	reason := func(status WebhookStatus, conditionType string, want metav1.ConditionStatus) string {
+		t.Helper()
+		cond := meta.FindStatusCondition(status.Conditions, conditionType)
+		if cond == nil {
+			t.Fatalf("Missing condition %s", conditionType)
+		}
+		if cond.Status != want {
+			t.Errorf("Condition %s: got %s (%s: %s), want %s", conditionType, cond.Status, cond.Reason, cond.Message, want)
+		}
+		return cond.Reason
+	}
+
+	// Before the first sync
+	if err := reporter.report(ctx); err != nil {
+		t.Fatalf("report() error = %v", err)
+	}
+	status := read()
+	if got := reason(status, ConditionCertificatesReady, metav1.ConditionFalse); got != "NotStarted" {
+		t.Errorf("CertificatesReady reason: got %s, want NotStarted", got)
+	}
+	if got := reason(status, ConditionBundleSynced, metav1.ConditionFalse); got != "Pending" {
+		t.Errorf("BundleSynced reason: got %s, want Pending", got)
+	}
+	reason(status, ConditionLeader, metav1.ConditionTrue)
+	if status.Leader != "wh-0" || status.LastRotation != nil {
+		t.Errorf("Got leader %q and last rotation %v", status.Leader, status.LastRotation)
+	}
+
+	// Issued certificates and an injected bundle
+	info := &certmanager.CertificateInfo{NotBefore: issued, NotAfter: issued.Add(48 * time.Hour)}
+	certs.status, certs.err = certmanager.Status{CA: info, ServingCertificate: info}, nil
+	bundle.injected = "ca-1"
+	if err := reporter.report(ctx); err != nil {
+		t.Fatalf("report() error = %v", err)
+	}
+	status = read()
+	reason(status, ConditionCertificatesReady, metav1.ConditionTrue)
+	reason(status, ConditionBundleSynced, metav1.ConditionTrue)
+	if status.LastRotation == nil || !status.LastRotation.Equal(&metav1.Time{Time: issued}) {
+		t.Errorf("Last rotation: got %v, want %v", status.LastRotation, issued)
+	}
+
+	// An unchanged status is not written again
+	client.ClearActions()
+	if err := reporter.report(ctx); err != nil {
+		t.Fatalf("report() error = %v", err)
+	}
+	for _, action := range client.Actions() {
+		if action.GetVerb() != "get" {
+			t.Errorf("Unexpected %s of an unchanged status", action.GetVerb())
+		}
+	}
+
+	// Failing syncs
+	certs.healthy = errors.New("sync failed 3 times in a row")
+	bundle.err = errors.New("conflict")
+	if err := reporter.report(ctx); err != nil {
+		t.Fatalf("report() error = %v", err)
+	}
+	status = read()
+	if got := reason(status, ConditionCertificatesReady, metav1.ConditionFalse); got != "SyncFailing" {
+		t.Errorf("CertificatesReady reason: got %s, want SyncFailing", got)
+	}
+	if got := reason(status, ConditionBundleSynced, metav1.ConditionFalse); got != "InjectionFailed" {
+		t.Errorf("BundleSynced reason: got %s, want InjectionFailed", got)
+	}
+}